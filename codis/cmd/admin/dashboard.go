@@ -28,6 +28,9 @@ func (t *cmdDashboard) Main(d map[string]interface{}) {
 	default:
 		t.handleOverview(d)
 
+	case d["--watch"].(bool):
+		t.handleWatch(d)
+
 	case d["--shutdown"].(bool):
 		t.handleShutdown(d)
 	case d["--reload"].(bool):
@@ -84,6 +87,13 @@ func (t *cmdDashboard) Main(d map[string]interface{}) {
 	case d["--rebalance"].(bool):
 		t.handleSlotRebalance(d)
 
+	case d["--doctor"].(bool):
+		t.handleDoctor(d)
+	case d["--config-drift"].(bool):
+		t.handleConfigDrift(d)
+	case d["--keyspace-diff"].(bool):
+		t.handleKeyspaceDiff(d)
+
 	}
 }
 
@@ -164,6 +174,22 @@ func (t *cmdDashboard) handleOverview(d map[string]interface{}) {
 	fmt.Println(string(b))
 }
 
+func (t *cmdDashboard) handleWatch(d map[string]interface{}) {
+	c := t.newTopomClient()
+
+	watchLoop(watchInterval(d), true, func() (*watchStats, error) {
+		log.Debugf("call rpc overview to dashboard %s", t.addr)
+		o, err := c.Overview()
+		if err != nil {
+			return nil, err
+		}
+		if o.Stats == nil {
+			return nil, fmt.Errorf("dashboard %s has no stats", t.addr)
+		}
+		return topomWatchStats(o.Stats), nil
+	})
+}
+
 func (t *cmdDashboard) handleLogLevel(d map[string]interface{}) {
 	c := t.newTopomClient()
 
@@ -0,0 +1,259 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/topom"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+const (
+	FindingError = "ERROR"
+	FindingWarn  = "WARN"
+)
+
+type doctorFinding struct {
+	Level   string
+	Check   string
+	Message string
+	Advice  string
+}
+
+type doctorFindings []*doctorFinding
+
+func (p *doctorFindings) add(level, check, advice string, format string, args ...interface{}) {
+	*p = append(*p, &doctorFinding{
+		Level: level, Check: check, Advice: advice,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+//proxy与本机的时钟差超过该值时报警
+const maxClockSkew = time.Second * 5
+
+func (t *cmdDashboard) handleDoctor(d map[string]interface{}) {
+	c := t.newTopomClient()
+
+	log.Debugf("call rpc overview to dashboard %s", t.addr)
+	o, err := c.Overview()
+	if err != nil {
+		log.PanicErrorf(err, "call rpc overview to dashboard %s failed", t.addr)
+	}
+	log.Debugf("call rpc overview OK")
+
+	if o.Stats == nil || o.Config == nil {
+		log.Panicf("dashboard %s has no stats", t.addr)
+	}
+	stats := o.Stats
+
+	var findings doctorFindings
+	checkSlots(&findings, stats)
+	checkGroups(&findings, stats)
+	checkProxies(&findings, stats)
+	checkSentinels(&findings, o.Config.ProductName, stats)
+
+	var skews = make(map[string]time.Duration)
+	for _, p := range stats.Proxy.Models {
+		if skew, err := proxyClockSkew(p.AdminAddr); err != nil {
+			log.Debugf("proxy-[%s] check clock failed: %s", p.Token, err)
+		} else {
+			skews[p.Token] = skew
+		}
+	}
+	checkClockSkew(&findings, skews, maxClockSkew)
+
+	var errs int
+	for _, x := range findings {
+		if x.Level == FindingError {
+			errs++
+		}
+		fmt.Printf("[%s] %s: %s\n", x.Level, x.Check, x.Message)
+		if x.Advice != "" {
+			fmt.Printf("        -> %s\n", x.Advice)
+		}
+	}
+	if len(findings) == 0 {
+		fmt.Println("no problems found")
+	} else {
+		fmt.Printf("%d problems found, %d errors\n", len(findings), errs)
+	}
+}
+
+func checkSlots(p *doctorFindings, stats *topom.Stats) {
+	var groups = make(map[int]*models.Group)
+	for _, g := range stats.Group.Models {
+		groups[g.Id] = g
+	}
+	var offline, missing []int
+	for _, m := range stats.Slots {
+		if m.Action.State != models.ActionNothing {
+			continue
+		}
+		switch g := groups[m.GroupId]; {
+		case m.GroupId == 0:
+			offline = append(offline, m.Id)
+		case g == nil || len(g.Servers) == 0:
+			missing = append(missing, m.Id)
+		}
+	}
+	if len(offline) != 0 {
+		p.add(FindingError, "slots", "assign them with --slots-assign --beg=ID --end=ID --gid=ID --confirm",
+			"%d slots are not assigned to any group: %s", len(offline), formatSlotRanges(offline))
+	}
+	if len(missing) != 0 {
+		p.add(FindingError, "slots", "add servers to the group or migrate the slots with --slot-action --create-range",
+			"%d slots belong to groups without servers: %s", len(missing), formatSlotRanges(missing))
+	}
+}
+
+func checkGroups(p *doctorFindings, stats *topom.Stats) {
+	for _, g := range stats.Group.Models {
+		if g.OutOfSync {
+			p.add(FindingWarn, "group", fmt.Sprintf("resync it with --resync-group --gid=%d", g.Id),
+				"group-[%d] is out of sync", g.Id)
+		}
+		switch h := stats.Group.Health[strconv.Itoa(g.Id)]; h {
+		case "", topom.GroupHealthy, topom.GroupEmpty:
+		case topom.GroupDegradedNoReplica:
+			p.add(FindingWarn, "group", "check the replication of its slaves",
+				"group-[%d] has no replica in sync with master", g.Id)
+		default:
+			p.add(FindingError, "group", "check the servers of the group and promote a healthy slave if needed",
+				"group-[%d] is %s", g.Id, h)
+		}
+	}
+}
+
+func checkProxies(p *doctorFindings, stats *topom.Stats) {
+	for _, m := range stats.Proxy.Models {
+		x := stats.Proxy.Stats[m.Token]
+		switch {
+		case x == nil:
+			p.add(FindingWarn, "proxy", "wait for the next stats refresh of dashboard",
+				"proxy-[%s] %s has no stats yet", m.Token, m.AdminAddr)
+		case x.Error != nil && strings.Contains(x.Error.Cause, "xauth"):
+			p.add(FindingError, "proxy", "make sure product_name & product_auth of proxy match the dashboard",
+				"proxy-[%s] %s rejects dashboard: %s", m.Token, m.AdminAddr, x.Error.Cause)
+		case x.Error != nil || x.Timeout:
+			p.add(FindingError, "proxy", fmt.Sprintf("restart it or remove it with --remove-proxy --token=%s --force", m.Token),
+				"proxy-[%s] %s is unreachable", m.Token, m.AdminAddr)
+		case x.Stats != nil && x.Stats.Closed:
+			p.add(FindingError, "proxy", fmt.Sprintf("remove it with --remove-proxy --token=%s --force", m.Token),
+				"proxy-[%s] %s is closed", m.Token, m.AdminAddr)
+		case x.Stats != nil && !x.Stats.Online:
+			p.add(FindingWarn, "proxy", fmt.Sprintf("online it with --reinit-proxy --token=%s", m.Token),
+				"proxy-[%s] %s is not online", m.Token, m.AdminAddr)
+		}
+	}
+}
+
+func checkSentinels(p *doctorFindings, product string, stats *topom.Stats) {
+	if stats.HA.Model == nil || len(stats.HA.Model.Servers) == 0 {
+		return
+	}
+	if stats.HA.Model.OutOfSync {
+		p.add(FindingWarn, "sentinel", "resync sentinels with --sentinel-resync", "sentinels are out of sync")
+	}
+	var masters = make(map[int]string)
+	for _, g := range stats.Group.Models {
+		if len(g.Servers) != 0 {
+			masters[g.Id] = g.Servers[0].Addr
+		}
+	}
+	for _, server := range stats.HA.Model.Servers {
+		x := stats.HA.Stats[server]
+		if x == nil || x.Error != nil || x.Timeout {
+			p.add(FindingWarn, "sentinel", "check the sentinel process", "sentinel %s is unreachable", server)
+			continue
+		}
+		var gids []int
+		for gid := range masters {
+			gids = append(gids, gid)
+		}
+		sort.Ints(gids)
+		for _, gid := range gids {
+			name := fmt.Sprintf("%s-%d", product, gid)
+			g := x.Sentinel[name]
+			if g == nil {
+				p.add(FindingWarn, "sentinel", "resync sentinels with --sentinel-resync",
+					"sentinel %s doesn't monitor group-[%d]", server, gid)
+				continue
+			}
+			addr := net.JoinHostPort(g.Master["ip"], g.Master["port"])
+			if addr != masters[gid] {
+				p.add(FindingError, "sentinel", "check whether a failover is in progress, then resync sentinels",
+					"sentinel %s thinks master of group-[%d] is %s, but dashboard has %s", server, gid, addr, masters[gid])
+			}
+		}
+	}
+}
+
+//proxy上报的时间最多落后1秒采样周期，按请求的中间时间计算时钟差
+func proxyClockSkew(addr string) (time.Duration, error) {
+	c := proxy.NewApiClient(addr)
+	beg := time.Now()
+	o, err := c.Overview()
+	if err != nil {
+		return 0, err
+	}
+	end := time.Now()
+	if o.Stats == nil || o.Stats.Rusage.Now == "" {
+		return 0, fmt.Errorf("proxy %s has no rusage", addr)
+	}
+	now, err := parseProxyTime(o.Stats.Rusage.Now)
+	if err != nil {
+		return 0, err
+	}
+	return now.Sub(beg.Add(end.Sub(beg) / 2)), nil
+}
+
+func parseProxyTime(s string) (time.Time, error) {
+	//去掉time.Time.String()附带的单调时钟部分
+	if i := strings.Index(s, " m="); i >= 0 {
+		s = s[:i]
+	}
+	return time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", s)
+}
+
+func checkClockSkew(p *doctorFindings, skews map[string]time.Duration, max time.Duration) {
+	var tokens []string
+	for token := range skews {
+		tokens = append(tokens, token)
+	}
+	sort.Strings(tokens)
+	for _, token := range tokens {
+		skew := skews[token]
+		if skew > max || skew < -max {
+			p.add(FindingWarn, "clock", "sync the clock with ntp",
+				"proxy-[%s] clock differs from local by %s", token, skew)
+		}
+	}
+}
+
+//将有序的slot id压缩为区间，例如0-3,7
+func formatSlotRanges(sids []int) string {
+	var list []string
+	for i := 0; i < len(sids); {
+		j := i
+		for j+1 < len(sids) && sids[j+1] == sids[j]+1 {
+			j++
+		}
+		if i == j {
+			list = append(list, strconv.Itoa(sids[i]))
+		} else {
+			list = append(list, fmt.Sprintf("%d-%d", sids[i], sids[j]))
+		}
+		i = j + 1
+	}
+	return strings.Join(list, ",")
+}
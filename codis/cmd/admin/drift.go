@@ -0,0 +1,121 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//每个proxy各不相同的配置，不参与对比
+var driftIgnoredKeys = map[string]bool{
+	"proxy_addr": true, "admin_addr": true, "proxy_datacenter": true,
+	"config_file_name": true, "Log": true, "PidFile": true,
+}
+
+type configDrift struct {
+	Key string
+	//配置值到proxy token的映射
+	Values map[string][]string
+}
+
+func (t *cmdDashboard) handleConfigDrift(d map[string]interface{}) {
+	c := t.newTopomClient()
+
+	log.Debugf("call rpc stats to dashboard %s", t.addr)
+	s, err := c.Stats()
+	if err != nil {
+		log.PanicErrorf(err, "call rpc stats to dashboard %s failed", t.addr)
+	}
+	log.Debugf("call rpc stats OK")
+
+	var configs = make(map[string]map[string]interface{})
+	for _, p := range s.Proxy.Models {
+		log.Debugf("call rpc overview to proxy %s", p.AdminAddr)
+		o, err := proxy.NewApiClient(p.AdminAddr).Overview()
+		if err != nil {
+			log.WarnErrorf(err, "call rpc overview to proxy-[%s] %s failed", p.Token, p.AdminAddr)
+			continue
+		}
+		m, err := flattenConfig(&o.Config)
+		if err != nil {
+			log.PanicErrorf(err, "decode config of proxy-[%s] failed", p.Token)
+		}
+		configs[p.Token] = m
+	}
+
+	drifts := diffConfigs(configs)
+	if len(drifts) == 0 {
+		fmt.Printf("no config drift found among %d proxies\n", len(configs))
+		return
+	}
+	for _, x := range drifts {
+		fmt.Printf("%s:\n", x.Key)
+		var values []string
+		for v := range x.Values {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		for _, v := range values {
+			fmt.Printf("    %-24s %s\n", v, strings.Join(x.Values[v], ","))
+		}
+	}
+	fmt.Printf("%d config keys differ among %d proxies\n", len(drifts), len(configs))
+}
+
+func flattenConfig(config *proxy.Config) (map[string]interface{}, error) {
+	b, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+//返回在不同proxy上取值不同的配置，按key排序；proxy缺少的配置显示为<missing>
+func diffConfigs(configs map[string]map[string]interface{}) []*configDrift {
+	var tokens []string
+	var keys = make(map[string]bool)
+	for token, m := range configs {
+		tokens = append(tokens, token)
+		for k := range m {
+			keys[k] = true
+		}
+	}
+	sort.Strings(tokens)
+
+	var drifts []*configDrift
+	for k := range keys {
+		if driftIgnoredKeys[k] {
+			continue
+		}
+		x := &configDrift{Key: k, Values: make(map[string][]string)}
+		for _, token := range tokens {
+			v := "<missing>"
+			if val, ok := configs[token][k]; ok {
+				v = fmt.Sprint(val)
+			}
+			x.Values[v] = append(x.Values[v], token)
+		}
+		if len(x.Values) > 1 {
+			drifts = append(drifts, x)
+		}
+	}
+	sort.Sort(configDriftSorter(drifts))
+	return drifts
+}
+
+type configDriftSorter []*configDrift
+
+func (s configDriftSorter) Len() int           { return len(s) }
+func (s configDriftSorter) Less(i, j int) bool { return s[i].Key < s[j].Key }
+func (s configDriftSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -13,6 +13,7 @@ func main() {
 	const usage = `
 Usage:
 	codis-admin [-v] --proxy=ADDR [--auth=AUTH] [config|model|stats|slots]
+	codis-admin [-v] --proxy=ADDR [--auth=AUTH] [stats] --watch [--watch-interval=SECONDS]
 	codis-admin [-v] --proxy=ADDR [--auth=AUTH]  --start
 	codis-admin [-v] --proxy=ADDR [--auth=AUTH]  --shutdown
 	codis-admin [-v] --proxy=ADDR [--auth=AUTH]  --log-level=LEVEL
@@ -20,6 +21,7 @@ Usage:
 	codis-admin [-v] --proxy=ADDR [--auth=AUTH]  --reset-stats
 	codis-admin [-v] --proxy=ADDR [--auth=AUTH]  --forcegc
 	codis-admin [-v] --dashboard=ADDR           [config|model|stats|slots|group|proxy]
+	codis-admin [-v] --dashboard=ADDR           [stats] --watch [--watch-interval=SECONDS]
 	codis-admin [-v] --dashboard=ADDR            --shutdown
 	codis-admin [-v] --dashboard=ADDR            --reload
 	codis-admin [-v] --dashboard=ADDR            --log-level=LEVEL
@@ -52,6 +54,9 @@ Usage:
 	codis-admin [-v] --dashboard=ADDR            --sentinel-add   --addr=ADDR
 	codis-admin [-v] --dashboard=ADDR            --sentinel-del   --addr=ADDR [--force]
 	codis-admin [-v] --dashboard=ADDR            --sentinel-resync
+	codis-admin [-v] --dashboard=ADDR            --doctor
+	codis-admin [-v] --dashboard=ADDR            --config-drift
+	codis-admin [-v] --dashboard=ADDR            --keyspace-diff  --product=NAME [--full] [--samples=N] [--ttl-tolerance=MS]
 	codis-admin [-v] --remove-lock               --product=NAME (--zookeeper=ADDR [--zookeeper-auth=USR:PWD]|--etcd=ADDR [--etcd-auth=USR:PWD]|--filesystem=ROOT)
 	codis-admin [-v] --config-dump               --product=NAME (--zookeeper=ADDR [--zookeeper-auth=USR:PWD]|--etcd=ADDR [--etcd-auth=USR:PWD]|--filesystem=ROOT) [-1]
 	codis-admin [-v] --config-convert=FILE
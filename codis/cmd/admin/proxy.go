@@ -26,6 +26,8 @@ func (t *cmdProxy) Main(d map[string]interface{}) {
 	switch {
 	default:
 		t.handleOverview(d)
+	case d["--watch"].(bool):
+		t.handleWatch(d)
 	case d["--start"].(bool):
 		t.handleStart(d)
 	case d["--shutdown"].(bool):
@@ -104,6 +106,19 @@ func (t *cmdProxy) handleOverview(d map[string]interface{}) {
 	fmt.Println(string(b))
 }
 
+func (t *cmdProxy) handleWatch(d map[string]interface{}) {
+	c := t.newProxyClient(false)
+
+	watchLoop(watchInterval(d), false, func() (*watchStats, error) {
+		log.Debugf("call rpc overview to proxy %s", t.addr)
+		o, err := c.Overview()
+		if err != nil {
+			return nil, err
+		}
+		return proxyWatchStats(o.Stats), nil
+	})
+}
+
 func (t *cmdProxy) handleStart(d map[string]interface{}) {
 	c := t.newProxyClient(true)
 
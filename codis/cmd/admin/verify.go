@@ -0,0 +1,78 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/topom"
+	"github.com/CodisLabs/codis/pkg/utils"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//比较dashboard所在product与--product中的key，等待任务结束后输出不一致的key
+func (t *cmdDashboard) handleKeyspaceDiff(d map[string]interface{}) {
+	c := t.newTopomClient()
+
+	req := &topom.KeyspaceDiffRequest{
+		Product: utils.ArgumentMust(d, "--product"),
+		Full:    d["--full"].(bool),
+	}
+	if n, ok := utils.ArgumentInteger(d, "--samples"); ok {
+		req.Samples = n
+	}
+	if n, ok := utils.ArgumentInteger(d, "--ttl-tolerance"); ok {
+		req.TTLTolerance = int64(n)
+	}
+
+	log.Debugf("call rpc keyspace-diff to dashboard %s", t.addr)
+	id, err := c.KeyspaceDiffJob(req)
+	if err != nil {
+		log.PanicErrorf(err, "call rpc keyspace-diff to dashboard %s failed", t.addr)
+	}
+	log.Debugf("call rpc keyspace-diff OK, job-[%d]", id)
+
+	var j *topom.Job
+	for {
+		if j, err = c.GetJob(id); err != nil {
+			log.PanicErrorf(err, "call rpc job-[%d] to dashboard %s failed", id, t.addr)
+		}
+		if j.State != topom.JobRunning {
+			break
+		}
+		log.Debugf("job-[%d] %s %d%%", id, j.Step, j.Progress)
+		time.Sleep(time.Second)
+	}
+
+	var detail topom.KeyspaceDiffDetail
+	if err := json.Unmarshal(j.Detail, &detail); err != nil {
+		log.PanicErrorf(err, "decode detail of job-[%d] failed", id)
+	}
+	fmt.Printf("%s -> %s, slots = %d, skipped = %d, compared = %d, matched = %d\n",
+		detail.Source, detail.Dest, detail.Slots, len(detail.Skipped), detail.Compared, detail.Matched)
+	for _, x := range []struct {
+		name  string
+		count int64
+		keys  []string
+	}{
+		{"missing", detail.MissingCount, detail.Missing},
+		{"extra", detail.ExtraCount, detail.Extra},
+		{"type", detail.TypeCount, detail.TypeMismatched},
+		{"value", detail.ValueCount, detail.ValueMismatched},
+		{"ttl", detail.TTLCount, detail.TTLMismatched},
+	} {
+		if x.count == 0 {
+			continue
+		}
+		fmt.Printf("%s: %d\n", x.name, x.count)
+		for _, key := range x.keys {
+			fmt.Printf("    %q\n", key)
+		}
+	}
+	if j.State != topom.JobFinished {
+		log.Panicf("job-[%d] %s: %s", id, j.State, j.Error)
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/topom"
+	"github.com/CodisLabs/codis/pkg/utils"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//--watch模式下每次拉取的关键指标
+type watchStats struct {
+	QPS   int64
+	Total int64
+	Fails int64
+	//每个命令延迟超过50ms的调用次数
+	Slow map[string]int64
+
+	Pending   int
+	Migrating int
+	Progress  string
+}
+
+func newWatchStats() *watchStats {
+	return &watchStats{Slow: make(map[string]int64)}
+}
+
+func (w *watchStats) addProxy(s *proxy.Stats) {
+	if s == nil {
+		return
+	}
+	w.QPS += s.Ops.QPS
+	w.Total += s.Ops.Total
+	w.Fails += s.Ops.Fails
+	for _, c := range s.Ops.Cmd {
+		n := c.Delay50ms + c.Delay100ms + c.Delay200ms + c.Delay300ms + c.Delay500ms + c.Delay1s + c.Delay2s + c.Delay3s
+		if n != 0 {
+			w.Slow[c.OpStr] += n
+		}
+	}
+}
+
+func (w *watchStats) addSlots(slots []*models.SlotMapping) {
+	for _, m := range slots {
+		switch m.Action.State {
+		case models.ActionNothing:
+		case models.ActionPending:
+			w.Pending++
+		default:
+			w.Migrating++
+		}
+	}
+}
+
+func proxyWatchStats(s *proxy.Stats) *watchStats {
+	w := newWatchStats()
+	w.addProxy(s)
+	return w
+}
+
+func topomWatchStats(s *topom.Stats) *watchStats {
+	w := newWatchStats()
+	for _, p := range s.Proxy.Stats {
+		w.addProxy(p.Stats)
+	}
+	w.addSlots(s.Slots)
+	w.Progress = s.SlotAction.Progress.Status
+	return w
+}
+
+//与上一次的结果对比，返回一行变化情况
+func formatWatchDelta(last, now *watchStats, slots bool) string {
+	var fields []string
+	fields = append(fields, fmt.Sprintf("qps=%d (%+d)", now.QPS, now.QPS-last.QPS))
+	fields = append(fields, fmt.Sprintf("ops=+%d", now.Total-last.Total))
+	fields = append(fields, fmt.Sprintf("fails=+%d", now.Fails-last.Fails))
+
+	//计数器减少时proxy已经重启，不输出该命令
+	var slow []string
+	for cmd, n := range now.Slow {
+		if d := n - last.Slow[cmd]; d > 0 {
+			slow = append(slow, fmt.Sprintf("%s+%d", cmd, d))
+		}
+	}
+	sort.Strings(slow)
+	if len(slow) != 0 {
+		fields = append(fields, "slow=["+strings.Join(slow, " ")+"]")
+	}
+
+	if slots {
+		var done = last.Pending + last.Migrating - now.Pending - now.Migrating
+		if done < 0 {
+			done = 0
+		}
+		x := fmt.Sprintf("slots: pending=%d migrating=%d done=+%d", now.Pending, now.Migrating, done)
+		if now.Progress != "" {
+			x += " (" + now.Progress + ")"
+		}
+		fields = append(fields, x)
+	}
+	return strings.Join(fields, "  ")
+}
+
+func watchInterval(d map[string]interface{}) time.Duration {
+	n, ok := utils.ArgumentInteger(d, "--watch-interval")
+	if !ok {
+		return time.Second
+	}
+	if n <= 0 {
+		log.Panicf("option --watch-interval = %d", n)
+	}
+	return time.Second * time.Duration(n)
+}
+
+//按interval重复拉取，第一次只记录基准，之后每次输出一行变化
+func watchLoop(interval time.Duration, slots bool, fetch func() (*watchStats, error)) {
+	var last *watchStats
+	for {
+		now, err := fetch()
+		if err != nil {
+			log.WarnErrorf(err, "watch: fetch stats failed")
+		} else {
+			if last != nil {
+				fmt.Printf("%s  %s\n", time.Now().Format("15:04:05"), formatWatchDelta(last, now, slots))
+			}
+			last = now
+		}
+		time.Sleep(interval)
+	}
+}
@@ -82,7 +82,7 @@ func killProcess(pidfile string) error {
 func main() {
 	const usage = `
 Usage:
-	codis-dashboard [--ncpu=N] [--config=CONF] [--log=FILE] [--log-level=LEVEL] [--host-admin=ADDR] [--pidfile=FILE] [--zookeeper=ADDR|--etcd=ADDR|--filesystem=ROOT] [--product_name=NAME] [--product_auth=AUTH] [--remove-lock]
+	codis-dashboard [--ncpu=N] [--config=CONF] [--log=FILE] [--log-level=LEVEL] [--host-admin=ADDR] [--pidfile=FILE] [--zookeeper=ADDR|--etcd=ADDR|--etcdv3=ADDR|--filesystem=ROOT] [--product_name=NAME] [--product_auth=AUTH] [--remove-lock]
 	codis-dashboard  --default-config
 	codis-dashboard  -c CONF [-s (start|stop|restart)]
 	codis-dashboard  --version
@@ -173,6 +173,11 @@ Options:
 		config.CoordinatorAddr = utils.ArgumentMust(d, "--etcd")
 		log.Warnf("option --etcd = %s", config.CoordinatorAddr)
 
+	case d["--etcdv3"] != nil:
+		config.CoordinatorName = "etcdv3"
+		config.CoordinatorAddr = utils.ArgumentMust(d, "--etcdv3")
+		log.Warnf("option --etcdv3 = %s", config.CoordinatorAddr)
+
 	case d["--filesystem"] != nil:
 		config.CoordinatorName = "filesystem"
 		config.CoordinatorAddr = utils.ArgumentMust(d, "--filesystem")
@@ -189,10 +194,12 @@ Options:
 		log.Warnf("option --product_auth = %s", s)
 	}
 
-	// 只用mysql作为配置中心
+	// 没有配置coordinator_name时用mysql作为配置中心
 	client, err := NewClient(config)
-	//client, err := models.NewClient(config.CoordinatorName, config.CoordinatorAddr, config.CoordinatorAuth, time.Minute)
 	if err != nil {
+		if config.CoordinatorName != "" {
+			log.PanicErrorf(err, "create '%s' client to '%s' failed", config.CoordinatorName, config.CoordinatorAddr)
+		}
 		log.PanicErrorf(err, "create mysql client to '%s' failed", config.MysqlAddr)
 	}
 	defer client.Close()
@@ -256,6 +263,18 @@ Options:
 
 	log.Warnf("create topom with config\n%s", config)
 
+	//每个product使用单独的client，关闭product时一起关闭
+	for _, p := range config.Products {
+		c, err := NewClient(config)
+		if err != nil {
+			log.PanicErrorf(err, "create client of product %s failed", p.ProductName)
+		}
+		if _, err := s.AddProduct(c, p); err != nil {
+			c.Close()
+			log.PanicErrorf(err, "add product %s failed", p.ProductName)
+		}
+	}
+
 	if s, ok := utils.Argument(d, "--pidfile"); ok {
 		config.PidFile = s
 	}
@@ -292,6 +311,17 @@ Options:
 		}
 	}
 
+	for i := 0; !s.IsClosed() && len(config.Products) != 0; i++ {
+		if err := s.StartProducts(true); err == nil {
+			break
+		} else if i <= 15 {
+			log.WarnErrorf(err, "[%p] dashboard products online failed [%d]", s, i)
+		} else {
+			log.Panicf("dashboard products online failed, give up & abort :'(")
+		}
+		time.Sleep(time.Second * 2)
+	}
+
 	log.Warnf("[%p] dashboard is working ...", s)
 
 	for !s.IsClosed() {
@@ -302,7 +332,9 @@ Options:
 }
 
 func NewClient(config *topom.Config) (models.Client, error) {
-	//client, err := models.NewClient(config.CoordinatorName, config.CoordinatorAddr, config.CoordinatorAuth, time.Minute)
+	if config.CoordinatorName != "" {
+		return models.NewClient(config.CoordinatorName, config.CoordinatorAddr, config.CoordinatorAuth, time.Minute)
+	}
 	return models.NewSqlClient(config.MysqlAddr, config.MysqlUsername, config.MysqlPassword, config.MysqlDatabase)
 	/*if  config.MasterProduct == "" {
 		return models.NewSqlClient(config.MysqlAddr, config.MysqlUsername, config.MysqlPassword, config.MysqlDatabase)
@@ -32,6 +32,7 @@ import (
 	"github.com/CodisLabs/codis/pkg/fe"
 	"github.com/CodisLabs/codis/pkg/utils"
 	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/topom"
 	"github.com/CodisLabs/codis/pkg/utils/errors"
 	"github.com/CodisLabs/codis/pkg/utils/log"
 	"github.com/CodisLabs/codis/pkg/utils/rpc"
@@ -430,6 +431,41 @@ Options:
 		return rpc.ApiResponseJson(codiss)
 	})
 
+	r.Get("/overview/all", sessionauth.LoginRequired, func(user sessionauth.User) (int, string) {
+		loginuser := &UserModel{}
+		err := loginuser.GetById(user.UniqueId())
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		addrs := router.GetAddrs(dbmap, loginuser.Username)
+		return rpc.ApiResponseJson(LoadMultiOverview(addrs, time.Second*5))
+	})
+
+	r.Get("/audit/:codis", sessionauth.LoginRequired, func(user sessionauth.User, params martini.Params, req *http.Request) (int, string) {
+		loginuser := &UserModel{}
+		err := loginuser.GetById(user.UniqueId())
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		name := params["codis"]
+		addr := router.GetAddrs(dbmap, loginuser.Username)[name]
+		if addr == "" {
+			return rpc.ApiResponseError(fmt.Errorf("codis %s doesn't exist or permission denied", name))
+		}
+		query := req.URL.Query()
+		limit, _ := strconv.Atoi(query.Get("limit"))
+		if limit <= 0 {
+			limit = 100
+		}
+		client := topom.NewApiClient(addr)
+		client.SetXAuth(name)
+		list, err := client.AuditLog(limit, query.Get("user"))
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		return rpc.ApiResponseJson(list)
+	})
+
 	r.Get("/", func(r render.Render) {
 		r.Redirect("index")
 	})
@@ -630,8 +666,18 @@ Options:
 		p.ServeHTTP(w, req)
 	})
 
-	r.Any("/**", sessionauth.LoginRequired, func(w http.ResponseWriter, req *http.Request) {
+	r.Any("/**", sessionauth.LoginRequired, func(w http.ResponseWriter, req *http.Request, user sessionauth.User) {
 		name := req.URL.Query().Get("forward")
+		//修改类的请求带上登录的用户名，由dashboard记录到审计日志
+		req.Header.Del(topom.AuditUserHeader)
+		//同一个dashboard可能管理多个product
+		req.Header.Set(topom.ProductHeader, name)
+		if req.Method != "GET" {
+			loginuser := &UserModel{}
+			if err := loginuser.GetById(user.UniqueId()); err == nil {
+				req.Header.Set(topom.AuditUserHeader, loginuser.Username)
+			}
+		}
 		if p := router.GetProxy(name); p != nil {
 			p.ServeHTTP(w, req)
 		} else {
@@ -802,6 +848,7 @@ type ReverseProxy struct {
 	loadAt time.Time
 	loader ConfigLoader
 	routes map[string]*httputil.ReverseProxy
+	addrs  map[string]string
 }
 
 func NewReverseProxy(loader ConfigLoader) *ReverseProxy {
@@ -816,6 +863,7 @@ func (r *ReverseProxy) reload(d time.Duration, dbmap *gorp.DbMap, username strin
 		return
 	}
 	r.routes = make(map[string]*httputil.ReverseProxy)
+	r.addrs = make(map[string]string)
 	if m, err := r.loader.Reload(dbmap, username); err != nil {
 		log.WarnErrorf(err, "reload reverse proxy failed")
 	} else {
@@ -827,6 +875,7 @@ func (r *ReverseProxy) reload(d time.Duration, dbmap *gorp.DbMap, username strin
 			p := httputil.NewSingleHostReverseProxy(u)
 			p.Transport = roundTripper
 			r.routes[name] = p
+			r.addrs[name] = host
 		}
 	}
 	r.loadAt = time.Now()
@@ -849,6 +898,102 @@ func (r *ReverseProxy) GetNames(dbmap *gorp.DbMap, username string) []string {
 	return names
 }
 
+//返回用户有权限的集群及其dashboard地址
+func (r *ReverseProxy) GetAddrs(dbmap *gorp.DbMap, username string) map[string]string {
+	r.Lock()
+	defer r.Unlock()
+	r.reload(time.Second * 0, dbmap, username)
+	var m = make(map[string]string)
+	for name, addr := range r.addrs {
+		m[name] = addr
+	}
+	return m
+}
+
+type ProductOverview struct {
+	Name      string         `json:"name"`
+	Dashboard string         `json:"dashboard"`
+	Summary   *topom.Summary `json:"summary,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+type MultiOverview struct {
+	Products []*ProductOverview `json:"products"`
+
+	Total struct {
+		Products    int     `json:"products"`
+		Unreachable int     `json:"unreachable"`
+		Unhealthy   int     `json:"unhealthy"`
+		Migrating   int     `json:"migrating"`
+		QPS         int64   `json:"qps"`
+		OpsTotal    int64   `json:"ops_total"`
+		OpsFails    int64   `json:"ops_fails"`
+		ErrorRate   float64 `json:"error_rate"`
+	} `json:"total"`
+}
+
+type productOverviewSorter []*ProductOverview
+
+func (s productOverviewSorter) Len() int           { return len(s) }
+func (s productOverviewSorter) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s productOverviewSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+//并发拉取各个dashboard的摘要，超时或出错的集群只返回错误信息
+func LoadMultiOverview(addrs map[string]string, timeout time.Duration) *MultiOverview {
+	var o = &MultiOverview{}
+	var wg sync.WaitGroup
+	for name, addr := range addrs {
+		p := &ProductOverview{Name: name, Dashboard: addr}
+		o.Products = append(o.Products, p)
+		wg.Add(1)
+		go func(p *ProductOverview) {
+			defer wg.Done()
+			var ch = make(chan error, 1)
+			var x *topom.Summary
+			go func() {
+				var err error
+				x, err = topom.NewApiClient(p.Dashboard).Summary()
+				ch <- err
+			}()
+			select {
+			case err := <-ch:
+				if err != nil {
+					p.Error = err.Error()
+				} else {
+					p.Summary = x
+				}
+			case <-time.After(timeout):
+				p.Error = fmt.Sprintf("timeout after %s", timeout)
+			}
+		}(p)
+	}
+	wg.Wait()
+	sort.Sort(productOverviewSorter(o.Products))
+
+	o.Total.Products = len(o.Products)
+	for _, p := range o.Products {
+		x := p.Summary
+		if x == nil {
+			o.Total.Unreachable++
+			continue
+		}
+		if x.Closed || !x.Online || len(x.Group.Unhealthy) != 0 || x.Proxy.Healthy != x.Proxy.Total ||
+			x.HA.Unreachable != 0 || x.HA.OutOfSync {
+			o.Total.Unhealthy++
+		}
+		if x.Migration.Pending != 0 || x.Migration.Migrating != 0 {
+			o.Total.Migrating++
+		}
+		o.Total.QPS += x.Ops.QPS
+		o.Total.OpsTotal += x.Ops.Total
+		o.Total.OpsFails += x.Ops.Fails
+	}
+	if o.Total.OpsTotal != 0 {
+		o.Total.ErrorRate = float64(o.Total.OpsFails) / float64(o.Total.OpsTotal)
+	}
+	return o
+}
+
 func SqlQuery(params martini.Params) (int, string) {
 	sql := params["sql"]
 	if sql == "" {
@@ -234,6 +234,24 @@ Options:
 		log.Warnf("[%p] proxy receive signal = '%v'", s, sig)
 	}()
 
+	go func() {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, syscall.SIGHUP)
+
+		for range c {
+			log.Warnf("[%p] proxy receive signal = 'hangup', reload config", s)
+			applied, restart, err := s.ReloadConfig()
+			if err != nil {
+				log.WarnErrorf(err, "[%p] proxy reload config failed", s)
+				continue
+			}
+			log.Warnf("[%p] proxy reload config, applied = %v", s, applied)
+			if len(restart) != 0 {
+				log.Warnf("[%p] proxy reload config, require restart = %v", s, restart)
+			}
+		}
+	}()
+
 	switch {
 	case dashboard != "":
 		go AutoOnlineWithDashboard(s, dashboard)
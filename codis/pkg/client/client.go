@@ -0,0 +1,56 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package client
+
+import (
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type Options struct {
+	ProductName string
+	ProductAuth string
+
+	//只读请求在网络错误时的重试次数，修改操作不重试以免重复执行
+	Retries       int
+	RetryInterval time.Duration
+}
+
+func (o *Options) retries() int {
+	if o.Retries < 0 {
+		return 0
+	}
+	return o.Retries
+}
+
+func (o *Options) retryInterval() time.Duration {
+	if o.RetryInterval <= 0 {
+		return time.Millisecond * 200
+	}
+	return o.RetryInterval
+}
+
+//连接失败或超时等网络错误才重试，服务端返回的错误不会因为重试而改变
+func isRetryable(err error) bool {
+	switch errors.Cause(err).(type) {
+	case *url.Error, net.Error:
+		return true
+	}
+	return false
+}
+
+func retry(o *Options, addr string, fn func() error) error {
+	var err error
+	for i := 0; ; i++ {
+		if err = fn(); err == nil || !isRetryable(err) || i >= o.retries() {
+			return err
+		}
+		log.Debugf("client: request to %s failed, retry %d/%d: %s", addr, i+1, o.retries(), err)
+		time.Sleep(o.retryInterval())
+	}
+}
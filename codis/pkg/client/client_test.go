@@ -0,0 +1,66 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package client
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/testcluster"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+)
+
+func TestRetry(x *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	addr := l.Addr().String()
+	l.Close()
+
+	var start = time.Now()
+	c := NewDashboard(addr, &Options{Retries: 2, RetryInterval: time.Millisecond * 50})
+	_, err = c.Stats()
+	assert.Must(err != nil && isRetryable(err))
+	assert.Must(time.Since(start) >= time.Millisecond*100)
+
+	assert.Must(!isRetryable(errors.New("remote error")))
+}
+
+func TestClient(x *testing.T) {
+	o := &Options{ProductName: "client-test", Retries: 1}
+	cluster, err := testcluster.New(&testcluster.Options{ProductName: o.ProductName, Groups: 2, Proxies: 1})
+	assert.MustNoError(err)
+	defer cluster.Close()
+
+	c := NewDashboard(cluster.Topom.Model().AdminAddr, o)
+	groups, err := c.Groups()
+	assert.MustNoError(err)
+	assert.Must(len(groups) == 2)
+	g, err := c.Group(2)
+	assert.MustNoError(err)
+	assert.Must(len(g.Servers) == 1)
+	_, err = c.Group(3)
+	assert.Must(err != nil)
+
+	slots, err := c.Slots()
+	assert.MustNoError(err)
+	assert.Must(len(slots) == testcluster.MaxSlotNum)
+
+	_, err = c.GetJob(1)
+	assert.Must(err != nil && !isRetryable(err))
+
+	proxies, err := c.Proxies()
+	assert.MustNoError(err)
+	assert.Must(len(proxies) == 1)
+	p, err := c.Proxy(proxies[0].Token)
+	assert.MustNoError(err)
+	assert.MustNoError(p.XPing())
+
+	p, err = NewProxy(proxies[0].AdminAddr, o)
+	assert.MustNoError(err)
+	stats, err := p.StatsSimple()
+	assert.MustNoError(err)
+	assert.Must(stats.Online)
+}
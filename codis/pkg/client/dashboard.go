@@ -0,0 +1,163 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package client
+
+import (
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/topom"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+)
+
+//dashboard管理接口的客户端，未重新定义的接口直接使用topom.ApiClient，不会重试
+type Dashboard struct {
+	*topom.ApiClient
+
+	addr string
+	opts Options
+}
+
+func NewDashboard(addr string, o *Options) *Dashboard {
+	c := &Dashboard{addr: addr}
+	if o != nil {
+		c.opts = *o
+	}
+	c.ApiClient = topom.NewApiClient(addr)
+	c.ApiClient.SetXAuth(c.opts.ProductName)
+	return c
+}
+
+func (c *Dashboard) retry(fn func() error) error {
+	return retry(&c.opts, c.addr, fn)
+}
+
+func (c *Dashboard) Overview() (o *topom.Overview, err error) {
+	err = c.retry(func() error {
+		o, err = c.ApiClient.Overview()
+		return err
+	})
+	return
+}
+
+func (c *Dashboard) Model() (m *models.Topom, err error) {
+	err = c.retry(func() error {
+		m, err = c.ApiClient.Model()
+		return err
+	})
+	return
+}
+
+func (c *Dashboard) Stats() (stats *topom.Stats, err error) {
+	err = c.retry(func() error {
+		stats, err = c.ApiClient.Stats()
+		return err
+	})
+	return
+}
+
+func (c *Dashboard) Slots() (slots []*models.Slot, err error) {
+	err = c.retry(func() error {
+		slots, err = c.ApiClient.Slots()
+		return err
+	})
+	return
+}
+
+func (c *Dashboard) Groups() ([]*models.Group, error) {
+	stats, err := c.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return stats.Group.Models, nil
+}
+
+func (c *Dashboard) Group(gid int) (*models.Group, error) {
+	groups, err := c.Groups()
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.Id == gid {
+			return g, nil
+		}
+	}
+	return nil, errors.Errorf("group-[%d] doesn't exist", gid)
+}
+
+func (c *Dashboard) GroupHealth() (health map[int]string, err error) {
+	err = c.retry(func() error {
+		health, err = c.ApiClient.GroupHealth()
+		return err
+	})
+	return
+}
+
+func (c *Dashboard) Proxies() ([]*models.Proxy, error) {
+	stats, err := c.Stats()
+	if err != nil {
+		return nil, err
+	}
+	return stats.Proxy.Models, nil
+}
+
+//返回token对应proxy的客户端，使用dashboard的product信息生成xauth
+func (c *Dashboard) Proxy(token string) (*Proxy, error) {
+	proxies, err := c.Proxies()
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range proxies {
+		if p.Token == token {
+			return newProxy(p.AdminAddr, p.Token, &c.opts), nil
+		}
+	}
+	return nil, errors.Errorf("proxy-[%s] doesn't exist", token)
+}
+
+func (c *Dashboard) ListJobs() (list []*topom.Job, err error) {
+	err = c.retry(func() error {
+		list, err = c.ApiClient.ListJobs()
+		return err
+	})
+	return
+}
+
+func (c *Dashboard) GetJob(id int) (j *topom.Job, err error) {
+	err = c.retry(func() error {
+		j, err = c.ApiClient.GetJob(id)
+		return err
+	})
+	return
+}
+
+//等待后台任务结束，任务失败或被取消时返回错误
+func (c *Dashboard) WaitJob(id int, timeout time.Duration) (*topom.Job, error) {
+	var deadline = time.Now().Add(timeout)
+	for {
+		j, err := c.GetJob(id)
+		if err != nil {
+			return nil, err
+		}
+		switch j.State {
+		case topom.JobFinished:
+			return j, nil
+		case topom.JobFailed, topom.JobCancelled:
+			return j, errors.Errorf("job-[%d] %s %s: %s", j.Id, j.Type, j.State, j.Error)
+		}
+		if timeout > 0 && time.Now().After(deadline) {
+			return j, errors.Errorf("job-[%d] %s timeout, progress = %d%%", j.Id, j.Type, j.Progress)
+		}
+		time.Sleep(c.opts.retryInterval())
+	}
+}
+
+//生成报表，dashboard配置了report_webhook_url时发送的也是同样的内容
+func (c *Dashboard) Report(period string) (r *topom.Report, err error) {
+	err = c.retry(func() error {
+		r, err = c.ApiClient.Report(period)
+		return err
+	})
+	return
+}
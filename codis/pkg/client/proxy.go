@@ -0,0 +1,98 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package client
+
+import (
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy"
+)
+
+//proxy管理接口的客户端，未重新定义的接口直接使用proxy.ApiClient，不会重试
+type Proxy struct {
+	*proxy.ApiClient
+
+	addr string
+	opts Options
+}
+
+//从proxy获取token后生成xauth
+func NewProxy(addr string, o *Options) (*Proxy, error) {
+	c := newProxy(addr, "", o)
+	m, err := c.Model()
+	if err != nil {
+		return nil, err
+	}
+	c.ApiClient.SetXAuth(c.opts.ProductName, c.opts.ProductAuth, m.Token)
+	return c, nil
+}
+
+func newProxy(addr, token string, o *Options) *Proxy {
+	c := &Proxy{addr: addr}
+	if o != nil {
+		c.opts = *o
+	}
+	c.ApiClient = proxy.NewApiClient(addr)
+	c.ApiClient.SetXAuth(c.opts.ProductName, c.opts.ProductAuth, token)
+	return c
+}
+
+func (c *Proxy) retry(fn func() error) error {
+	return retry(&c.opts, c.addr, fn)
+}
+
+func (c *Proxy) Overview() (o *proxy.Overview, err error) {
+	err = c.retry(func() error {
+		o, err = c.ApiClient.Overview()
+		return err
+	})
+	return
+}
+
+func (c *Proxy) Model() (m *models.Proxy, err error) {
+	err = c.retry(func() error {
+		m, err = c.ApiClient.Model()
+		return err
+	})
+	return
+}
+
+func (c *Proxy) StatsSimple() (stats *proxy.Stats, err error) {
+	err = c.retry(func() error {
+		stats, err = c.ApiClient.StatsSimple()
+		return err
+	})
+	return
+}
+
+func (c *Proxy) Stats(flags proxy.StatsFlags) (stats *proxy.Stats, err error) {
+	err = c.retry(func() error {
+		stats, err = c.ApiClient.Stats(flags)
+		return err
+	})
+	return
+}
+
+func (c *Proxy) Slots() (slots []*models.Slot, err error) {
+	err = c.retry(func() error {
+		slots, err = c.ApiClient.Slots()
+		return err
+	})
+	return
+}
+
+func (c *Proxy) ClientStats() (list []*proxy.ClientStats, err error) {
+	err = c.retry(func() error {
+		list, err = c.ApiClient.ClientStats()
+		return err
+	})
+	return
+}
+
+func (c *Proxy) SLO() (list []*proxy.SLOStatus, err error) {
+	err = c.retry(func() error {
+		list, err = c.ApiClient.SLO()
+		return err
+	})
+	return
+}
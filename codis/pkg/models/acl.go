@@ -0,0 +1,73 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+import (
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+)
+
+//proxy的多用户认证，客户端用AUTH <name> <password>认证，名为default的用户也可以用AUTH <password>认证
+//Password以#开头时为密码的sha256摘要(十六进制)
+//Commands按顺序匹配，后面的规则覆盖前面的，"+get"、"-flushdb"允许或禁止单个命令，
+//"+@all"、"+@read"、"+@write"按类别，没有匹配任何规则的命令被拒绝
+//KeyPatterns为redis通配符，请求中的每个key都需要匹配其中一个，为空表示不限制key
+type ACLUser struct {
+	Name        string   `json:"name"`
+	Password    string   `json:"password"`
+	Disabled    bool     `json:"disabled,omitempty"`
+	Commands    []string `json:"commands"`
+	KeyPatterns []string `json:"key_patterns,omitempty"`
+}
+
+type ACLUsers struct {
+	Users []*ACLUser `json:"users"`
+}
+
+func (p *ACLUsers) Encode() []byte {
+	return jsonEncode(p)
+}
+
+const (
+	ACLCategoryAll   = "@all"
+	ACLCategoryRead  = "@read"
+	ACLCategoryWrite = "@write"
+)
+
+func (u *ACLUser) Validate() error {
+	if u.Name == "" || strings.ContainsAny(u.Name, " \t\r\n") {
+		return errors.Errorf("invalid acl user name = %q", u.Name)
+	}
+	if u.Password == "" {
+		return errors.Errorf("invalid password of acl user-[%s]", u.Name)
+	}
+	for _, rule := range u.Commands {
+		if _, _, err := ParseACLRule(rule); err != nil {
+			return errors.Errorf("invalid rule of acl user-[%s]: %s", u.Name, err)
+		}
+	}
+	for _, pattern := range u.KeyPatterns {
+		if pattern == "" {
+			return errors.Errorf("invalid key pattern of acl user-[%s]", u.Name)
+		}
+	}
+	return nil
+}
+
+//返回是否允许以及大写的命令名或者类别
+func ParseACLRule(rule string) (bool, string, error) {
+	if len(rule) < 2 || (rule[0] != '+' && rule[0] != '-') {
+		return false, "", errors.Errorf("bad rule %q", rule)
+	}
+	name := strings.ToUpper(rule[1:])
+	if strings.HasPrefix(name, "@") {
+		switch name = strings.ToLower(name); name {
+		case ACLCategoryAll, ACLCategoryRead, ACLCategoryWrite:
+		default:
+			return false, "", errors.Errorf("bad category %q", rule)
+		}
+	}
+	return rule[0] == '+', name, nil
+}
@@ -0,0 +1,23 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//一次修改类的admin api调用，User为fe登录的用户名，直接调用dashboard时为空
+type AuditEntry struct {
+	Time   int64  `json:"time"`
+	User   string `json:"user,omitempty"`
+	Source string `json:"source"`
+	Addr   string `json:"addr"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Status int    `json:"status"`
+}
+
+type AuditLog struct {
+	Entries []*AuditEntry `json:"entries"`
+}
+
+func (p *AuditLog) Encode() []byte {
+	return jsonEncode(p)
+}
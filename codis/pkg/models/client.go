@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/CodisLabs/codis/pkg/models/etcd"
+	"github.com/CodisLabs/codis/pkg/models/etcdv3"
 	"github.com/CodisLabs/codis/pkg/models/fs"
 	"github.com/CodisLabs/codis/pkg/models/zk"
 	"github.com/CodisLabs/codis/pkg/models/sql"
@@ -35,6 +36,8 @@ func NewClient(coordinator string, addrlist string, auth string, timeout time.Du
 		return zkclient.New(addrlist, auth, timeout)
 	case "etcd":
 		return etcdclient.New(addrlist, auth, timeout)
+	case "etcdv3":
+		return etcdv3client.New(addrlist, auth, timeout)
 	case "fs", "filesystem":
 		return fsclient.New(addrlist)
 	}
@@ -0,0 +1,13 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//运行时通过api修改的dashboard配置，key为配置文件中的名字，启动时覆盖配置文件中的值
+type ConfigOverrides struct {
+	Values map[string]string `json:"values"`
+}
+
+func (p *ConfigOverrides) Encode() []byte {
+	return jsonEncode(p)
+}
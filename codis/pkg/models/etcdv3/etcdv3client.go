@@ -0,0 +1,626 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package etcdv3client
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+var ErrClosedClient = errors.New("use of closed etcdv3 client")
+
+var (
+	ErrNodeExists = errors.New("etcdv3: node already exists")
+	ErrNoNode     = errors.New("etcdv3: node does not exist")
+	ErrLeaseLost  = errors.New("etcdv3: lease expired")
+)
+
+//grpc的Unauthenticated，token过期后需要重新认证
+const codeUnauthenticated = 16
+
+//通过etcd v3的grpc-gateway(json over http)访问，不依赖grpc
+//v3中没有目录，List返回以path/为前缀的key中的下一级路径
+type Client struct {
+	sync.Mutex
+	endpoints []string
+	username  string
+	password  string
+
+	//token在请求失败后重新认证时刷新，单独加锁，不依赖调用方是否持有c.Lock
+	token struct {
+		sync.Mutex
+		value string
+	}
+
+	client *http.Client
+	stream *http.Client
+
+	closed  bool
+	timeout time.Duration
+	leases  map[int64]bool
+
+	cancel  context.CancelFunc
+	context context.Context
+}
+
+func New(addrlist string, auth string, timeout time.Duration) (*Client, error) {
+	var endpoints []string
+	for _, s := range strings.Split(addrlist, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+			s = "http://" + s
+		}
+		endpoints = append(endpoints, strings.TrimSuffix(s, "/"))
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.Errorf("invalid etcdv3 address")
+	}
+	if timeout <= 0 {
+		timeout = time.Second * 5
+	}
+
+	c := &Client{
+		endpoints: endpoints, timeout: timeout,
+		client: &http.Client{Timeout: time.Second * 5},
+		stream: &http.Client{},
+		leases: make(map[int64]bool),
+	}
+	if auth != "" {
+		split := strings.SplitN(auth, ":", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, errors.Errorf("invalid auth")
+		}
+		c.username, c.password = split[0], split[1]
+	}
+	c.context, c.cancel = context.WithCancel(context.Background())
+
+	if c.username != "" {
+		if err := c.authenticate(); err != nil {
+			c.cancel()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+//dashboard锁用租约持有
+func (c *Client) LeaseLock() bool {
+	return true
+}
+
+func (c *Client) Close() error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil
+	}
+	for id := range c.leases {
+		c.revokeLease(id)
+	}
+	c.closed = true
+	c.cancel()
+	return nil
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Error   string `json:"error"`
+}
+
+func (c *Client) post(path string, req, resp interface{}) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	return c.postBytes(path, b, resp, c.username != "" && path != "/v3/auth/authenticate")
+}
+
+//依次尝试每个endpoint，只有连接失败时才换下一个，reauth表示token过期时重新认证后再试一次
+func (c *Client) postBytes(path string, b []byte, resp interface{}, reauth bool) error {
+	var lastErr error
+	for _, endpoint := range c.endpoints {
+		code, body, err := c.doPost(endpoint+path, b)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if code/100 != 2 {
+			var e rpcError
+			json.Unmarshal(body, &e)
+			if e.Code == codeUnauthenticated && reauth {
+				if err := c.authenticate(); err != nil {
+					return err
+				}
+				return c.postBytes(path, b, resp, false)
+			}
+			if e.Message == "" {
+				e.Message = e.Error
+			}
+			return errors.Errorf("etcdv3 %s failed: code = %d, %s", path, code, e.Message)
+		}
+		if resp == nil {
+			return nil
+		}
+		if err := json.Unmarshal(body, resp); err != nil {
+			return errors.Trace(err)
+		}
+		return nil
+	}
+	return errors.Trace(lastErr)
+}
+
+func (c *Client) doPost(url string, b []byte) (int, []byte, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(b))
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := c.getToken(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	return resp.StatusCode, body, nil
+}
+
+func (c *Client) authenticate() error {
+	var resp struct {
+		Token string `json:"token"`
+	}
+	c.setToken("")
+	err := c.post("/v3/auth/authenticate", map[string]string{
+		"name": c.username, "password": c.password,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	c.setToken(resp.Token)
+	return nil
+}
+
+func (c *Client) getToken() string {
+	c.token.Lock()
+	defer c.token.Unlock()
+	return c.token.value
+}
+
+func (c *Client) setToken(token string) {
+	c.token.Lock()
+	defer c.token.Unlock()
+	c.token.value = token
+}
+
+func encode(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func decode(s string) []byte {
+	b, _ := base64.StdEncoding.DecodeString(s)
+	return b
+}
+
+//前缀查询的range_end，即前缀最后一个字节加1
+func prefixEnd(prefix string) string {
+	b := []byte(prefix)
+	for i := len(b) - 1; i >= 0; i-- {
+		if b[i] < 0xff {
+			b[i]++
+			return string(b[:i+1])
+		}
+	}
+	return "\x00"
+}
+
+type keyValue struct {
+	Key            string `json:"key"`
+	Value          string `json:"value"`
+	CreateRevision int64  `json:"create_revision,string"`
+	ModRevision    int64  `json:"mod_revision,string"`
+	Lease          int64  `json:"lease,string"`
+}
+
+type responseHeader struct {
+	Revision int64 `json:"revision,string"`
+}
+
+type rangeRequest struct {
+	Key        string `json:"key"`
+	RangeEnd   string `json:"range_end,omitempty"`
+	KeysOnly   bool   `json:"keys_only,omitempty"`
+	SortOrder  string `json:"sort_order,omitempty"`
+	SortTarget string `json:"sort_target,omitempty"`
+}
+
+type rangeResponse struct {
+	Header responseHeader `json:"header"`
+	Kvs    []*keyValue    `json:"kvs"`
+}
+
+func (c *Client) get(path string) (*keyValue, error) {
+	var resp rangeResponse
+	if err := c.post("/v3/kv/range", &rangeRequest{Key: encode(path)}, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, nil
+	}
+	return resp.Kvs[0], nil
+}
+
+//按创建顺序返回以prefix为前缀的所有key
+func (c *Client) getPrefix(prefix string) ([]*keyValue, int64, error) {
+	var resp rangeResponse
+	err := c.post("/v3/kv/range", &rangeRequest{
+		Key: encode(prefix), RangeEnd: encode(prefixEnd(prefix)), KeysOnly: true,
+		SortOrder: "ASCEND", SortTarget: "CREATE",
+	}, &resp)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resp.Kvs, resp.Header.Revision, nil
+}
+
+func (c *Client) put(path string, data []byte, lease int64) error {
+	req := map[string]interface{}{
+		"key": encode(path), "value": base64.StdEncoding.EncodeToString(data),
+	}
+	if lease != 0 {
+		req["lease"] = fmt.Sprintf("%d", lease)
+	}
+	return c.post("/v3/kv/put", req, nil)
+}
+
+//key不存在时才写入
+func (c *Client) create(path string, data []byte, lease int64) error {
+	put := map[string]interface{}{
+		"key": encode(path), "value": base64.StdEncoding.EncodeToString(data),
+	}
+	if lease != 0 {
+		put["lease"] = fmt.Sprintf("%d", lease)
+	}
+	req := map[string]interface{}{
+		"compare": []interface{}{map[string]interface{}{
+			"key": encode(path), "target": "CREATE", "result": "EQUAL", "create_revision": "0",
+		}},
+		"success": []interface{}{map[string]interface{}{"request_put": put}},
+	}
+	var resp struct {
+		Succeeded bool `json:"succeeded"`
+	}
+	if err := c.post("/v3/kv/txn", req, &resp); err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return errors.Trace(ErrNodeExists)
+	}
+	return nil
+}
+
+func (c *Client) Create(path string, data []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return errors.Trace(ErrClosedClient)
+	}
+	log.Debugf("etcdv3 create node %s", path)
+	if err := c.create(path, data, 0); err != nil {
+		log.Debugf("etcdv3 create node %s failed: %s", path, err)
+		return err
+	}
+	log.Debugf("etcdv3 create OK")
+	return nil
+}
+
+func (c *Client) Update(path string, data []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return errors.Trace(ErrClosedClient)
+	}
+	log.Debugf("etcdv3 update node %s", path)
+	if err := c.put(path, data, 0); err != nil {
+		log.Debugf("etcdv3 update node %s failed: %s", path, err)
+		return err
+	}
+	log.Debugf("etcdv3 update OK")
+	return nil
+}
+
+func (c *Client) Delete(path string) error {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return errors.Trace(ErrClosedClient)
+	}
+	log.Debugf("etcdv3 delete node %s", path)
+	if err := c.post("/v3/kv/deleterange", &rangeRequest{Key: encode(path)}, nil); err != nil {
+		log.Debugf("etcdv3 delete node %s failed: %s", path, err)
+		return err
+	}
+	log.Debugf("etcdv3 delete OK")
+	return nil
+}
+
+func (c *Client) Read(path string, must bool) ([]byte, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil, errors.Trace(ErrClosedClient)
+	}
+	kv, err := c.get(path)
+	switch {
+	case err != nil:
+		log.Debugf("etcdv3 read node %s failed: %s", path, err)
+		return nil, err
+	case kv == nil:
+		if !must {
+			return nil, nil
+		}
+		return nil, errors.Trace(ErrNoNode)
+	default:
+		return decode(kv.Value), nil
+	}
+}
+
+//返回下一级路径，按创建顺序排列
+func children(path string, kvs []*keyValue) []string {
+	var prefix = path + "/"
+	var paths []string
+	var exists = make(map[string]bool)
+	for _, kv := range kvs {
+		name := strings.TrimPrefix(string(decode(kv.Key)), prefix)
+		if i := strings.IndexByte(name, '/'); i >= 0 {
+			name = name[:i]
+		}
+		if name == "" || exists[name] {
+			continue
+		}
+		exists[name] = true
+		paths = append(paths, prefix+name)
+	}
+	return paths
+}
+
+func (c *Client) List(path string, must bool) ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil, errors.Trace(ErrClosedClient)
+	}
+	kvs, _, err := c.getPrefix(path + "/")
+	if err != nil {
+		log.Debugf("etcdv3 list node %s failed: %s", path, err)
+		return nil, err
+	}
+	if len(kvs) == 0 && must {
+		return nil, errors.Trace(ErrNoNode)
+	}
+	paths := children(path, kvs)
+	sort.Strings(paths)
+	return paths, nil
+}
+
+//租约的TTL，单位为秒，至少为1
+func leaseTTL(timeout time.Duration) int64 {
+	if ttl := int64(timeout / time.Second); ttl > 1 {
+		return ttl
+	}
+	return 1
+}
+
+func (c *Client) grantLease() (int64, error) {
+	ttl := leaseTTL(c.timeout)
+	var resp struct {
+		ID  int64 `json:"ID,string"`
+		TTL int64 `json:"TTL,string"`
+	}
+	if err := c.post("/v3/lease/grant", map[string]string{"TTL": fmt.Sprintf("%d", ttl)}, &resp); err != nil {
+		return 0, err
+	}
+	c.leases[resp.ID] = true
+	return resp.ID, nil
+}
+
+func (c *Client) revokeLease(id int64) error {
+	delete(c.leases, id)
+	return c.post("/v3/lease/revoke", map[string]string{"ID": fmt.Sprintf("%d", id)}, nil)
+}
+
+//返回续期之后租约剩余的时间
+func (c *Client) keepAlive(id int64) (time.Duration, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return 0, errors.Trace(ErrClosedClient)
+	}
+	var resp struct {
+		Result struct {
+			TTL int64 `json:"TTL,string"`
+		} `json:"result"`
+	}
+	if err := c.post("/v3/lease/keepalive", map[string]string{"ID": fmt.Sprintf("%d", id)}, &resp); err != nil {
+		return 0, err
+	}
+	if resp.Result.TTL <= 0 {
+		delete(c.leases, id)
+		return 0, errors.Trace(ErrLeaseLost)
+	}
+	return time.Duration(resp.Result.TTL) * time.Second, nil
+}
+
+//租约过期或者client关闭后signal被关闭
+//续期请求失败时在租约到期之前一直重试，etcd短暂不可用或者切换leader不会让dashboard退出
+func runKeepAlive(c *Client, id int64) <-chan struct{} {
+	signal := make(chan struct{})
+	go func() {
+		defer close(signal)
+		var expire = time.Now().Add(time.Duration(leaseTTL(c.timeout)) * time.Second)
+		var wait = c.timeout / 3
+		for {
+			select {
+			case <-c.context.Done():
+				return
+			case <-time.After(wait):
+			}
+			start := time.Now()
+			ttl, err := c.keepAlive(id)
+			switch {
+			case err == nil:
+				expire, wait = start.Add(ttl), c.timeout/3
+			case errors.Equal(err, ErrLeaseLost) || errors.Equal(err, ErrClosedClient):
+				log.Debugf("etcdv3 keepalive lease %x failed: %s", id, err)
+				return
+			case time.Now().After(expire):
+				log.Warnf("etcdv3 keepalive lease %x failed until expired: %s", id, err)
+				return
+			default:
+				log.Warnf("etcdv3 keepalive lease %x failed, retry: %s", id, err)
+				wait = c.timeout / 10
+			}
+		}
+	}()
+	return signal
+}
+
+func (c *Client) CreateEphemeral(path string, data []byte) (<-chan struct{}, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil, errors.Trace(ErrClosedClient)
+	}
+	log.Debugf("etcdv3 create-ephemeral node %s", path)
+	id, err := c.grantLease()
+	if err != nil {
+		log.Debugf("etcdv3 create-ephemeral node %s failed: %s", path, err)
+		return nil, err
+	}
+	if err := c.create(path, data, id); err != nil {
+		c.revokeLease(id)
+		log.Debugf("etcdv3 create-ephemeral node %s failed: %s", path, err)
+		return nil, err
+	}
+	log.Debugf("etcdv3 create-ephemeral OK")
+	return runKeepAlive(c, id), nil
+}
+
+//v3没有顺序节点，用租约ID作为节点名，顺序由创建的revision决定
+func (c *Client) CreateEphemeralInOrder(path string, data []byte) (<-chan struct{}, string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil, "", errors.Trace(ErrClosedClient)
+	}
+	log.Debugf("etcdv3 create-ephemeral-inorder node %s", path)
+	id, err := c.grantLease()
+	if err != nil {
+		log.Debugf("etcdv3 create-ephemeral-inorder node %s failed: %s", path, err)
+		return nil, "", err
+	}
+	node := fmt.Sprintf("%s/%016x", path, id)
+	if err := c.create(node, data, id); err != nil {
+		c.revokeLease(id)
+		log.Debugf("etcdv3 create-ephemeral-inorder node %s failed: %s", path, err)
+		return nil, "", err
+	}
+	log.Debugf("etcdv3 create-ephemeral-inorder OK, node = %s", node)
+	return runKeepAlive(c, id), node, nil
+}
+
+//返回当前的下一级路径(按创建顺序)，path下有任何修改时signal被关闭
+func (c *Client) WatchInOrder(path string) (<-chan struct{}, []string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.closed {
+		return nil, nil, errors.Trace(ErrClosedClient)
+	}
+	log.Debugf("etcdv3 watch-inorder node %s", path)
+	kvs, revision, err := c.getPrefix(path + "/")
+	if err != nil {
+		log.Debugf("etcdv3 watch-inorder node %s failed: %s", path, err)
+		return nil, nil, err
+	}
+	paths := children(path, kvs)
+
+	b, err := json.Marshal(map[string]interface{}{
+		"create_request": map[string]string{
+			"key": encode(path + "/"), "range_end": encode(prefixEnd(path + "/")),
+			"start_revision": fmt.Sprintf("%d", revision+1),
+		},
+	})
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	req, err := http.NewRequest("POST", c.endpoints[0]+"/v3/watch", bytes.NewReader(b))
+	if err != nil {
+		return nil, nil, errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token := c.getToken(); token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	req = req.WithContext(c.context)
+
+	signal := make(chan struct{})
+	go func() {
+		defer close(signal)
+		resp, err := c.stream.Do(req)
+		if err != nil {
+			log.Debugf("etcdv3 watch-inorder node %s failed: %s", path, err)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			log.Debugf("etcdv3 watch-inorder node %s failed: %s", path, resp.Status)
+			return
+		}
+		dec := json.NewDecoder(bufio.NewReader(resp.Body))
+		for {
+			var r struct {
+				Result struct {
+					Canceled bool              `json:"canceled"`
+					Events   []json.RawMessage `json:"events"`
+				} `json:"result"`
+				Error *rpcError `json:"error"`
+			}
+			if err := dec.Decode(&r); err != nil {
+				log.Debugf("etcdv3 watch-inorder node %s failed: %s", path, err)
+				return
+			}
+			switch {
+			case r.Error != nil || r.Result.Canceled:
+				log.Debugf("etcdv3 watch-inorder node %s canceled", path)
+				return
+			case len(r.Result.Events) != 0:
+				log.Debugf("etcdv3 watch-inorder node %s update", path)
+				return
+			}
+		}
+	}()
+	log.Debugf("etcdv3 watch-inorder OK")
+	return signal, paths, nil
+}
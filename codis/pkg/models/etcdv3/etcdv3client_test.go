@@ -0,0 +1,262 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package etcdv3client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//只实现了client用到的接口
+type fakeEtcd struct {
+	sync.Mutex
+	revision int64
+	kvs      map[string]*keyValue
+	leases   map[int64]bool
+	nextId   int64
+	watchers []chan struct{}
+	//接下来失败的续期请求数
+	keepAliveFails int
+}
+
+func newFakeEtcd() *fakeEtcd {
+	return &fakeEtcd{kvs: make(map[string]*keyValue), leases: make(map[int64]bool), nextId: 100}
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&req)
+	str := func(m map[string]interface{}, k string) string {
+		s, _ := m[k].(string)
+		return s
+	}
+	num := func(m map[string]interface{}, k string) int64 {
+		n, _ := strconv.ParseInt(str(m, k), 10, 64)
+		return n
+	}
+	reply := func(v interface{}) {
+		json.NewEncoder(w).Encode(v)
+	}
+
+	if r.URL.Path == "/v3/watch" {
+		c := make(chan struct{})
+		f.Lock()
+		f.watchers = append(f.watchers, c)
+		f.Unlock()
+		reply(map[string]interface{}{"result": map[string]interface{}{"created": true}})
+		w.(http.Flusher).Flush()
+		select {
+		case <-c:
+		case <-r.Context().Done():
+			return
+		}
+		reply(map[string]interface{}{"result": map[string]interface{}{"events": []interface{}{map[string]string{}}}})
+		return
+	}
+
+	f.Lock()
+	defer f.Unlock()
+	put := func(m map[string]interface{}) {
+		f.revision++
+		key := string(decode(str(m, "key")))
+		kv := f.kvs[key]
+		if kv == nil {
+			kv = &keyValue{Key: str(m, "key"), CreateRevision: f.revision}
+			f.kvs[key] = kv
+		}
+		kv.Value, kv.ModRevision, kv.Lease = str(m, "value"), f.revision, num(m, "lease")
+		for _, c := range f.watchers {
+			close(c)
+		}
+		f.watchers = nil
+	}
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		key := string(decode(str(req, "key")))
+		var kvs []*keyValue
+		if end := str(req, "range_end"); end == "" {
+			if kv := f.kvs[key]; kv != nil {
+				kvs = append(kvs, kv)
+			}
+		} else {
+			end := string(decode(end))
+			for k, kv := range f.kvs {
+				if k >= key && k < end {
+					kvs = append(kvs, kv)
+				}
+			}
+			sort.Slice(kvs, func(i, j int) bool { return kvs[i].CreateRevision < kvs[j].CreateRevision })
+		}
+		reply(map[string]interface{}{"header": map[string]string{"revision": fmt.Sprint(f.revision)}, "kvs": kvs})
+	case "/v3/kv/put":
+		put(req)
+		reply(map[string]interface{}{})
+	case "/v3/kv/txn":
+		cmp := req["compare"].([]interface{})[0].(map[string]interface{})
+		if f.kvs[string(decode(str(cmp, "key")))] != nil {
+			reply(map[string]interface{}{})
+			return
+		}
+		success := req["success"].([]interface{})[0].(map[string]interface{})
+		put(success["request_put"].(map[string]interface{}))
+		reply(map[string]interface{}{"succeeded": true})
+	case "/v3/kv/deleterange":
+		delete(f.kvs, string(decode(str(req, "key"))))
+		reply(map[string]interface{}{})
+	case "/v3/lease/grant":
+		f.nextId++
+		f.leases[f.nextId] = true
+		reply(map[string]string{"ID": fmt.Sprint(f.nextId), "TTL": str(req, "TTL")})
+	case "/v3/lease/keepalive":
+		if f.keepAliveFails > 0 {
+			f.keepAliveFails--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			reply(map[string]interface{}{"code": 14, "message": "unavailable"})
+			return
+		}
+		if f.leases[num(req, "ID")] {
+			reply(map[string]interface{}{"result": map[string]string{"TTL": "1"}})
+		} else {
+			reply(map[string]interface{}{"result": map[string]string{}})
+		}
+	case "/v3/lease/revoke":
+		id := num(req, "ID")
+		delete(f.leases, id)
+		for k, kv := range f.kvs {
+			if kv.Lease == id {
+				delete(f.kvs, k)
+			}
+		}
+		reply(map[string]interface{}{})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		reply(map[string]interface{}{"code": 5, "message": "not found"})
+	}
+}
+
+func TestEtcdv3Client(x *testing.T) {
+	f := newFakeEtcd()
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	c, err := New(ts.URL, "", time.Second*3)
+	assert.MustNoError(err)
+	defer c.Close()
+
+	assert.MustNoError(c.Create("/codis3/demo/topom", []byte("lock")))
+	assert.Must(c.Create("/codis3/demo/topom", []byte("lock")) != nil)
+	b, err := c.Read("/codis3/demo/topom", true)
+	assert.MustNoError(err)
+	assert.Must(string(b) == "lock")
+
+	assert.MustNoError(c.Update("/codis3/demo/group/group-0002", []byte("g2")))
+	assert.MustNoError(c.Update("/codis3/demo/group/group-0001", []byte("g1")))
+	assert.MustNoError(c.Update("/codis3/demo/group/group-0001/extra", []byte("x")))
+	paths, err := c.List("/codis3/demo/group", false)
+	assert.MustNoError(err)
+	assert.Must(len(paths) == 2 && paths[0] == "/codis3/demo/group/group-0001")
+
+	assert.MustNoError(c.Delete("/codis3/demo/topom"))
+	b, err = c.Read("/codis3/demo/topom", false)
+	assert.Must(err == nil && b == nil)
+	_, err = c.Read("/codis3/demo/topom", true)
+	assert.Must(err != nil)
+	_, err = c.List("/codis3/demo/proxy", true)
+	assert.Must(err != nil)
+}
+
+func TestEtcdv3Ephemeral(x *testing.T) {
+	f := newFakeEtcd()
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	c, err := New(ts.URL, "", time.Millisecond*300)
+	assert.MustNoError(err)
+	defer c.Close()
+
+	signal, err := c.CreateEphemeral("/codis3/demo/topom", []byte("lock"))
+	assert.MustNoError(err)
+	assert.Must(c.LeaseLock())
+	_, err = c.CreateEphemeral("/codis3/demo/topom", []byte("lock"))
+	assert.Must(err != nil)
+
+	watch, paths, err := c.WatchInOrder("/jodis/demo")
+	assert.MustNoError(err)
+	assert.Must(len(paths) == 0)
+	_, node, err := c.CreateEphemeralInOrder("/jodis/demo", []byte("proxy"))
+	assert.MustNoError(err)
+	select {
+	case <-watch:
+	case <-time.After(time.Second * 3):
+		x.Fatal("watch timeout")
+	}
+	_, paths, err = c.WatchInOrder("/jodis/demo")
+	assert.MustNoError(err)
+	assert.Must(len(paths) == 1 && paths[0] == node)
+
+	//租约还在续期
+	time.Sleep(time.Millisecond * 400)
+	select {
+	case <-signal:
+		x.Fatal("lease should be alive")
+	default:
+	}
+
+	//续期请求失败时在租约到期之前重试
+	f.Lock()
+	f.keepAliveFails = 3
+	f.Unlock()
+	time.Sleep(time.Millisecond * 600)
+	select {
+	case <-signal:
+		x.Fatal("lease should be alive after retries")
+	default:
+	}
+
+	//租约过期后signal被关闭
+	f.Lock()
+	for id := range f.leases {
+		delete(f.leases, id)
+	}
+	f.Unlock()
+	select {
+	case <-signal:
+	case <-time.After(time.Second * 3):
+		x.Fatal("lease lost timeout")
+	}
+}
+
+func TestEtcdv3KeepAliveExpire(x *testing.T) {
+	f := newFakeEtcd()
+	ts := httptest.NewServer(f)
+	defer ts.Close()
+
+	c, err := New(ts.URL, "", time.Millisecond*300)
+	assert.MustNoError(err)
+	defer c.Close()
+
+	signal, err := c.CreateEphemeral("/codis3/demo/topom", []byte("lock"))
+	assert.MustNoError(err)
+
+	//续期一直失败，租约到期之后signal被关闭
+	f.Lock()
+	f.keepAliveFails = 1 << 20
+	f.Unlock()
+	var start = time.Now()
+	select {
+	case <-signal:
+	case <-time.After(time.Second * 3):
+		x.Fatal("lease expire timeout")
+	}
+	assert.Must(time.Since(start) >= time.Millisecond*500)
+}
@@ -0,0 +1,13 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//按产品配置的lua过滤脚本，proxy对每个请求调用脚本中的filter函数，空脚本表示不过滤
+type RequestFilter struct {
+	Script string `json:"script"`
+}
+
+func (p *RequestFilter) Encode() []byte {
+	return jsonEncode(p)
+}
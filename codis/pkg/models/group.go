@@ -15,6 +15,8 @@ type Group struct {
 	} `json:"promoting"`
 
 	OutOfSync bool `json:"out_of_sync"`
+
+	Template string `json:"template,omitempty"`
 }
 
 type GroupServer struct {
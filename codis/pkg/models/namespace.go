@@ -0,0 +1,22 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//同一个产品内的租户，客户端用Password认证后只能访问以Prefix开头的key
+//Ops为每秒命令数，BytesPerDay为每天写入的字节数(按命令参数长度估算)，0表示不限制，每个proxy独立计数
+type Namespace struct {
+	Name        string `json:"name"`
+	Password    string `json:"password"`
+	Prefix      string `json:"prefix"`
+	Ops         int64  `json:"ops,omitempty"`
+	BytesPerDay int64  `json:"bytes_per_day,omitempty"`
+}
+
+type Namespaces struct {
+	Namespaces []*Namespace `json:"namespaces"`
+}
+
+func (p *Namespaces) Encode() []byte {
+	return jsonEncode(p)
+}
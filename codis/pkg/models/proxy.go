@@ -17,6 +17,9 @@ type Proxy struct {
 
 	ProductName string `json:"product_name"`
 
+	SlotHash string `json:"slot_hash,omitempty"`
+	HashTag  string `json:"hash_tag,omitempty"`
+
 	Pid int    `json:"pid"`
 	Pwd string `json:"pwd"`
 	Sys string `json:"sys"`
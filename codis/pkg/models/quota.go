@@ -0,0 +1,20 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//按key前缀限制写入，Ops为每秒写命令数，BytesPerDay为每天写入的字节数(按命令参数长度估算)，0表示不限制
+//每个proxy独立计数，限制对单个proxy生效
+type KeyQuota struct {
+	Prefix      string `json:"prefix"`
+	Ops         int64  `json:"ops,omitempty"`
+	BytesPerDay int64  `json:"bytes_per_day,omitempty"`
+}
+
+type KeyQuotas struct {
+	Quotas []*KeyQuota `json:"quotas"`
+}
+
+func (p *KeyQuotas) Encode() []byte {
+	return jsonEncode(p)
+}
@@ -0,0 +1,21 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//令牌桶限流，Type为cmd、user或ip，Name为命令名、用户名或客户端IP，*表示每个命令、用户或IP分别限流
+//QPS为每秒放入的令牌数，Burst为桶的容量，0表示与QPS相同；每个proxy独立计数
+type RateLimit struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	QPS   int64  `json:"qps"`
+	Burst int64  `json:"burst,omitempty"`
+}
+
+type RateLimits struct {
+	Limits []*RateLimit `json:"limits"`
+}
+
+func (p *RateLimits) Encode() []byte {
+	return jsonEncode(p)
+}
@@ -0,0 +1,22 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+const (
+	ReplicationSyncing  = "syncing"
+	ReplicationPromoted = "promoted"
+)
+
+//跨机房复制链路，当前product为主，Secondary为备product，两者group一一对应
+type ReplicationLink struct {
+	Secondary string `json:"secondary"`
+	State     string `json:"state"`
+
+	CreateTime  string `json:"create_time"`
+	PromoteTime string `json:"promote_time,omitempty"`
+}
+
+func (r *ReplicationLink) Encode() []byte {
+	return jsonEncode(r)
+}
@@ -0,0 +1,18 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//按key前缀把请求固定路由到一个group，不再按slot哈希，匹配时最长的前缀优先
+type PrefixRoute struct {
+	Prefix  string `json:"prefix"`
+	GroupId int    `json:"group_id"`
+}
+
+type PrefixRoutes struct {
+	Routes []*PrefixRoute `json:"routes"`
+}
+
+func (p *PrefixRoutes) Encode() []byte {
+	return jsonEncode(p)
+}
@@ -0,0 +1,50 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+const (
+	ScalingAddGroup    = "add-group"
+	ScalingRemoveGroup = "remove-group"
+)
+
+const (
+	ScalingRunning  = "running"
+	ScalingFinished = "finished"
+	ScalingFailed   = "failed"
+	ScalingAborted  = "aborted"
+)
+
+const (
+	ScalingStepCreate    = "create"
+	ScalingStepAddServer = "add-server"
+	ScalingStepSync      = "sync"
+	ScalingStepPlan      = "plan"
+	ScalingStepMigrate   = "migrate"
+	ScalingStepVerify    = "verify"
+	ScalingStepFinalize  = "finalize"
+)
+
+//扩缩容流程，每完成一步写入协调服务，dashboard重启后从记录的步骤继续执行
+type ScalingWorkflow struct {
+	Id    int    `json:"id"`
+	Type  string `json:"type"`
+	State string `json:"state"`
+	Step  string `json:"step"`
+
+	GroupId    int      `json:"group_id"`
+	Servers    []string `json:"servers,omitempty"`
+	DataCenter string   `json:"datacenter,omitempty"`
+	MaxSlots   int      `json:"max_slots,omitempty"`
+
+	Plans    map[int]int `json:"plans,omitempty"`
+	Migrated int         `json:"migrated"`
+	Error    string      `json:"error,omitempty"`
+
+	CreateTime string `json:"create_time"`
+	UpdateTime string `json:"update_time"`
+}
+
+func (w *ScalingWorkflow) Encode() []byte {
+	return jsonEncode(w)
+}
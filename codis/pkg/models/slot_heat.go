@@ -0,0 +1,21 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//slot在最近一段时间内的平均负载，由dashboard汇总所有proxy上报的数据
+type SlotLoad struct {
+	Id  int     `json:"id"`
+	QPS float64 `json:"qps"`
+	BPS float64 `json:"bps"`
+}
+
+type SlotHeat struct {
+	Samples    int         `json:"samples"`
+	UpdateTime string      `json:"update_time"`
+	Slots      []*SlotLoad `json:"slots,omitempty"`
+}
+
+func (p *SlotHeat) Encode() []byte {
+	return jsonEncode(p)
+}
@@ -0,0 +1,30 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+const (
+	SlotTransitionDone      = "done"
+	SlotTransitionCancelled = "cancelled"
+	SlotTransitionAssigned  = "assigned"
+)
+
+//slot的一次状态变化，State为action的状态或上面的结束状态
+type SlotTransition struct {
+	Time     int64  `json:"time"`
+	State    string `json:"state"`
+	GroupId  int    `json:"group_id"`
+	TargetId int    `json:"target_id,omitempty"`
+	Actor    string `json:"actor,omitempty"`
+	//迁移完成时为从pending开始的耗时，单位为秒
+	Elapsed float64 `json:"elapsed,omitempty"`
+}
+
+type SlotHistory struct {
+	Id          int               `json:"id"`
+	Transitions []*SlotTransition `json:"transitions"`
+}
+
+func (h *SlotHistory) Encode() []byte {
+	return jsonEncode(h)
+}
@@ -0,0 +1,32 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//slot迁移的一致性校验结果，迁移开始时在源端抽样key，每轮迁移之后与目标端比较
+type SlotVerifyReport struct {
+	Slot    int    `json:"slot"`
+	From    string `json:"from"`
+	Dest    string `json:"dest"`
+	Sampled int    `json:"sampled"`
+
+	Missing    []string `json:"missing,omitempty"`
+	Mismatched []string `json:"mismatched,omitempty"`
+	//迁移期间被客户端删除或者过期的key，不算校验失败
+	Deleted int `json:"deleted,omitempty"`
+
+	UpdateTime string `json:"update_time"`
+}
+
+func (r *SlotVerifyReport) Failed() bool {
+	return len(r.Missing) != 0 || len(r.Mismatched) != 0
+}
+
+//校验失败并且还没有确认的slot，dashboard重启之后这些slot仍然不能完成迁移
+type SlotVerifyFailures struct {
+	Reports []*SlotVerifyReport `json:"reports,omitempty"`
+}
+
+func (p *SlotVerifyFailures) Encode() []byte {
+	return jsonEncode(p)
+}
@@ -3,7 +3,11 @@
 
 package models
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+)
 
 const (
 	ForwardSync = iota
@@ -12,6 +16,29 @@ const (
 
 const MaxSlotNum = 1024
 
+//key到slot的哈希函数，crc16与redis cluster相同，crc16 % 16384 % MaxSlotNum
+//使同一个cluster slot的key总是落在同一个codis slot
+const (
+	SlotHashCRC32 = "crc32"
+	SlotHashCRC16 = "crc16"
+)
+
+//codis-server计算slot时固定使用的hash tag
+const DefaultHashTag = "{}"
+
+//hash tag由两个字符组成，为空时不使用hash tag
+func ValidateSlotHash(hash, tag string) error {
+	switch hash {
+	case SlotHashCRC32, SlotHashCRC16:
+	default:
+		return errors.Errorf("invalid slot hash = %s", hash)
+	}
+	if tag != "" && len(tag) != 2 {
+		return errors.Errorf("invalid hash tag = %s", tag)
+	}
+	return nil
+}
+
 type Slot struct {
 	Id     int  `json:"id"`
 	Locked bool `json:"locked,omitempty"`
@@ -24,6 +51,9 @@ type Slot struct {
 	ForwardMethod int `json:"forward_method,omitempty"`
 
 	ReplicaGroups [][]string `json:"replica_groups,omitempty"`
+
+	//不为空时是按key前缀的路由，优先于slot哈希，此时忽略Id；BackendAddrGroupId为0表示删除该路由
+	Prefix string `json:"prefix,omitempty"`
 }
 
 func ParseForwardMethod(s string) (int, bool) {
@@ -45,9 +75,28 @@ type SlotMapping struct {
 		Index    int    `json:"index,omitempty"`
 		State    string `json:"state,omitempty"`
 		TargetId int    `json:"target_id,omitempty"`
+		Priority int    `json:"priority,omitempty"`
+
+		//只在dashboard内存中记录，不会保存到协调服务
+		Progress *SlotActionProgress `json:"progress,omitempty"`
 	} `json:"action"`
 }
 
+//迁移中的slot的进度，字节数按源group的平均key大小估算，速度和剩余时间按开始迁移以来的平均速度计算
+type SlotActionProgress struct {
+	Keys    int64 `json:"keys"`
+	Remains int64 `json:"remains"`
+	Bytes   int64 `json:"bytes"`
+
+	KeysPerSec  float64 `json:"keys_per_sec"`
+	BytesPerSec float64 `json:"bytes_per_sec"`
+
+	StartTime int64   `json:"start_time"`
+	Elapsed   float64 `json:"elapsed"`
+	//还没有迁移任何key时为-1
+	ETA float64 `json:"eta"`
+}
+
 func (m *SlotMapping) Encode() []byte {
 	return jsonEncode(m)
 }
@@ -177,7 +177,24 @@ func (c *Client) List(path string, must bool) ([]string, error) {
 	return children, nil
 }
 
-func (c *Client) exec(path string, data []byte, opt string) (string, error) {
+//product下只有一个节点的类型，例如/codis3/<product>/topom
+var singleNodeTypes = map[string]bool{
+	"topom": true, "sentinel": true, "standby": true, "slotheat": true, "audit": true,
+	"quota": true, "ttlrule": true, "filter": true, "namespace": true, "route": true,
+	"acl": true,
+	"config": true,
+	"scaling": true,
+	"switchover": true,
+	"slotverify": true,
+}
+
+//product下有多个节点的类型，例如/codis3/<product>/group/group-0001
+var multiNodeTypes = map[string]bool{
+	"proxy": true, "group": true, "slots": true, "template": true, "replication": true, "slothistory": true,
+}
+
+//将路径转换为sql，新增的节点类型需要加入上面的列表，否则读写都会返回invalid path
+func pathSql(path string, data []byte, opt string) (string, error) {
 	pathList := strings.Split(path[1:], "/")
 	pathDeep := len(pathList)
 	if  pathDeep < 3 || pathDeep > 4 {
@@ -188,25 +205,33 @@ func (c *Client) exec(path string, data []byte, opt string) (string, error) {
 	nodeType := "codis3_" + pathList[2]
 	sql := ""
 	if pathDeep == 3 {
-		switch pathList[2] {
-		case "topom", "sentinel":
+		switch {
+		case singleNodeTypes[pathList[2]]:
 			sql = formatSql(table, productName, nodeType, "", string(data[:]), opt)
 
 		default:
 			return "", errors.New("invalid path")
 		}
 	} else if pathDeep == 4 {
-		switch pathList[2] {
-		case "topom","sentinel" :
+		switch {
+		case singleNodeTypes[pathList[2]]:
 			;
 
-		case "proxy", "group", "slots" :
+		case multiNodeTypes[pathList[2]]:
 			sql = formatSql(table, productName, nodeType, pathList[3], string(data[:]), opt)
 
 		default:
 			return "", errors.New("invalid path")
 		}
 	}
+	return sql, nil
+}
+
+func (c *Client) exec(path string, data []byte, opt string) (string, error) {
+	sql, err := pathSql(path, data, opt)
+	if err != nil {
+		return "", err
+	}
 
 	resp := ""
 	if opt == ReadNode {
@@ -0,0 +1,60 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package sqlclient
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+// models.Store中用到的路径都需要能转换为sql
+func TestPathSql(x *testing.T) {
+	const product = "codis-demo"
+	for _, path := range []string{
+		"/codis3/" + product + "/topom",
+		"/codis3/" + product + "/sentinel",
+		"/codis3/" + product + "/standby",
+		"/codis3/" + product + "/slotheat",
+		"/codis3/" + product + "/audit",
+		"/codis3/" + product + "/quota",
+		"/codis3/" + product + "/ttlrule",
+		"/codis3/" + product + "/filter",
+		"/codis3/" + product + "/namespace",
+		"/codis3/" + product + "/route",
+		"/codis3/" + product + "/acl",
+		"/codis3/" + product + "/config",
+		"/codis3/" + product + "/scaling",
+		"/codis3/" + product + "/switchover",
+		"/codis3/" + product + "/slotverify",
+		"/codis3/" + product + "/slots/slot-0001",
+		"/codis3/" + product + "/group/group-0001",
+		"/codis3/" + product + "/proxy/proxy-token",
+		"/codis3/" + product + "/template/name",
+		"/codis3/" + product + "/replication/secondary",
+		"/codis3/" + product + "/slothistory/slot-0001",
+	} {
+		sql, err := pathSql(path, []byte("{}"), ReadNode)
+		assert.MustNoError(err)
+		assert.Must(strings.Contains(sql, "product_name='"+product+"'"))
+	}
+
+	sql, err := pathSql("/codis3/"+product+"/route", []byte("{}"), UpdateNode)
+	assert.MustNoError(err)
+	assert.Must(strings.Contains(sql, "node_type='codis3_route'") && strings.Contains(sql, "node_name=''"))
+
+	sql, err = pathSql("/codis3/"+product+"/group/group-0001", nil, DeleteNode)
+	assert.MustNoError(err)
+	assert.Must(strings.Contains(sql, "node_type='codis3_group'") && strings.Contains(sql, "node_name='group-0001'"))
+
+	for _, path := range []string{
+		"/codis3",
+		"/codis3/" + product + "/unknown",
+		"/codis3/" + product + "/group/group-0001/x",
+	} {
+		_, err := pathSql(path, nil, ReadNode)
+		assert.Must(err != nil)
+	}
+}
@@ -0,0 +1,15 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//只读备集群，数据由Primary复制而来，proxy拒绝写命令，dashboard禁止slot迁移
+type Standby struct {
+	Enabled bool   `json:"enabled"`
+	Primary string `json:"primary,omitempty"`
+	Since   string `json:"since,omitempty"`
+}
+
+func (p *Standby) Encode() []byte {
+	return jsonEncode(p)
+}
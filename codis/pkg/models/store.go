@@ -54,10 +54,82 @@ func ProxyPath(product string, token string) string {
 	return filepath.Join(CodisDir, product, "proxy", fmt.Sprintf("proxy-%s", token))
 }
 
+func TemplateDir(product string) string {
+	return filepath.Join(CodisDir, product, "template")
+}
+
+func TemplatePath(product string, name string) string {
+	return filepath.Join(CodisDir, product, "template", name)
+}
+
+func ReplicationDir(product string) string {
+	return filepath.Join(CodisDir, product, "replication")
+}
+
+func ReplicationPath(product string, secondary string) string {
+	return filepath.Join(CodisDir, product, "replication", secondary)
+}
+
+func StandbyPath(product string) string {
+	return filepath.Join(CodisDir, product, "standby")
+}
+
+func SlotHeatPath(product string) string {
+	return filepath.Join(CodisDir, product, "slotheat")
+}
+
+func SlotHistoryPath(product string, sid int) string {
+	return filepath.Join(CodisDir, product, "slothistory", fmt.Sprintf("slot-%04d", sid))
+}
+
+func SlotVerifyPath(product string) string {
+	return filepath.Join(CodisDir, product, "slotverify")
+}
+
+func AuditLogPath(product string) string {
+	return filepath.Join(CodisDir, product, "audit")
+}
+
+func KeyQuotaPath(product string) string {
+	return filepath.Join(CodisDir, product, "quota")
+}
+
+func PrefixRoutePath(product string) string {
+	return filepath.Join(CodisDir, product, "route")
+}
+
+func ConfigOverridePath(product string) string {
+	return filepath.Join(CodisDir, product, "config")
+}
+
+func TTLRulePath(product string) string {
+	return filepath.Join(CodisDir, product, "ttlrule")
+}
+
+func RequestFilterPath(product string) string {
+	return filepath.Join(CodisDir, product, "filter")
+}
+
+func NamespacePath(product string) string {
+	return filepath.Join(CodisDir, product, "namespace")
+}
+
+func ACLPath(product string) string {
+	return filepath.Join(CodisDir, product, "acl")
+}
+
 func SentinelPath(product string) string {
 	return filepath.Join(CodisDir, product, "sentinel")
 }
 
+func ScalingPath(product string) string {
+	return filepath.Join(CodisDir, product, "scaling")
+}
+
+func SwitchoverPath(product string) string {
+	return filepath.Join(CodisDir, product, "switchover")
+}
+
 func LoadTopom(client Client, product string, must bool) (*Topom, error) {
 	b, err := client.Read(LockPath(product), must)
 	if err != nil || b == nil {
@@ -70,13 +142,21 @@ func LoadTopom(client Client, product string, must bool) (*Topom, error) {
 	return t, nil
 }
 
+//用租约持有dashboard锁的client，持有者退出或者与协调服务断开后锁自动释放，如etcdv3
+type LeaseLocker interface {
+	LeaseLock() bool
+}
+
 type Store struct {
 	client  Client
 	product string
+
+	//租约锁丢失后被关闭，不是租约锁时为nil
+	lost <-chan struct{}
 }
 
 func NewStore(client Client, product string) *Store {
-	return &Store{client, product}
+	return &Store{client: client, product: product}
 }
 
 func (s *Store) Close() error {
@@ -111,14 +191,98 @@ func (s *Store) ProxyPath(token string) string {
 	return ProxyPath(s.product, token)
 }
 
+func (s *Store) TemplateDir() string {
+	return TemplateDir(s.product)
+}
+
+func (s *Store) TemplatePath(name string) string {
+	return TemplatePath(s.product, name)
+}
+
+func (s *Store) ReplicationDir() string {
+	return ReplicationDir(s.product)
+}
+
+func (s *Store) ReplicationPath(secondary string) string {
+	return ReplicationPath(s.product, secondary)
+}
+
+func (s *Store) StandbyPath() string {
+	return StandbyPath(s.product)
+}
+
+func (s *Store) SlotHeatPath() string {
+	return SlotHeatPath(s.product)
+}
+
+func (s *Store) SlotHistoryPath(sid int) string {
+	return SlotHistoryPath(s.product, sid)
+}
+
+func (s *Store) SlotVerifyPath() string {
+	return SlotVerifyPath(s.product)
+}
+
+func (s *Store) AuditLogPath() string {
+	return AuditLogPath(s.product)
+}
+
+func (s *Store) KeyQuotaPath() string {
+	return KeyQuotaPath(s.product)
+}
+
+func (s *Store) PrefixRoutePath() string {
+	return PrefixRoutePath(s.product)
+}
+
+func (s *Store) ConfigOverridePath() string {
+	return ConfigOverridePath(s.product)
+}
+
+func (s *Store) TTLRulePath() string {
+	return TTLRulePath(s.product)
+}
+
+func (s *Store) RequestFilterPath() string {
+	return RequestFilterPath(s.product)
+}
+
+func (s *Store) NamespacePath() string {
+	return NamespacePath(s.product)
+}
+
+func (s *Store) ACLPath() string {
+	return ACLPath(s.product)
+}
+
 func (s *Store) SentinelPath() string {
 	return SentinelPath(s.product)
 }
 
+func (s *Store) ScalingPath() string {
+	return ScalingPath(s.product)
+}
+
+func (s *Store) SwitchoverPath() string {
+	return SwitchoverPath(s.product)
+}
+
 func (s *Store) Acquire(topom *Topom) error {
+	if l, ok := s.client.(LeaseLocker); ok && l.LeaseLock() {
+		w, err := s.client.CreateEphemeral(s.LockPath(), topom.Encode())
+		if err != nil {
+			return err
+		}
+		s.lost = w
+		return nil
+	}
 	return s.client.Create(s.LockPath(), topom.Encode())
 }
 
+func (s *Store) LockLost() <-chan struct{} {
+	return s.lost
+}
+
 func (s *Store) Release() error {
 	return s.client.Delete(s.LockPath())
 }
@@ -159,6 +323,10 @@ func (s *Store) UpdateSlotMapping(m *SlotMapping) error {
 	return s.client.Update(s.SlotPath(m.Id), m.Encode())
 }
 
+func (s *Store) DeleteSlotMapping(sid int) error {
+	return s.client.Delete(s.SlotPath(sid))
+}
+
 func (s *Store) ListGroup() (map[int]*Group, error) {
 	paths, err := s.client.List(s.GroupDir(), false)
 	if err != nil {
@@ -239,6 +407,74 @@ func (s *Store) DeleteProxy(token string) error {
 	return s.client.Delete(s.ProxyPath(token))
 }
 
+func (s *Store) ListTemplate() (map[string]*ConfigTemplate, error) {
+	paths, err := s.client.List(s.TemplateDir(), false)
+	if err != nil {
+		return nil, err
+	}
+	template := make(map[string]*ConfigTemplate)
+	for _, path := range paths {
+		b, err := s.client.Read(path, true)
+		if err != nil {
+			return nil, err
+		}
+		t := &ConfigTemplate{}
+		if err := jsonDecode(t, b); err != nil {
+			return nil, err
+		}
+		template[t.Name] = t
+	}
+	return template, nil
+}
+
+func (s *Store) LoadTemplate(name string, must bool) (*ConfigTemplate, error) {
+	b, err := s.client.Read(s.TemplatePath(name), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	t := &ConfigTemplate{}
+	if err := jsonDecode(t, b); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (s *Store) UpdateTemplate(t *ConfigTemplate) error {
+	return s.client.Update(s.TemplatePath(t.Name), t.Encode())
+}
+
+func (s *Store) DeleteTemplate(name string) error {
+	return s.client.Delete(s.TemplatePath(name))
+}
+
+func (s *Store) ListReplication() (map[string]*ReplicationLink, error) {
+	paths, err := s.client.List(s.ReplicationDir(), false)
+	if err != nil {
+		return nil, err
+	}
+	links := make(map[string]*ReplicationLink)
+	for _, path := range paths {
+		b, err := s.client.Read(path, true)
+		if err != nil {
+			return nil, err
+		}
+		r := &ReplicationLink{}
+		if err := jsonDecode(r, b); err != nil {
+			return nil, err
+		}
+		links[r.Secondary] = r
+	}
+	return links, nil
+}
+
+func (s *Store) UpdateReplication(r *ReplicationLink) error {
+	return s.client.Update(s.ReplicationPath(r.Secondary), r.Encode())
+}
+
+func (s *Store) DeleteReplication(secondary string) error {
+	return s.client.Delete(s.ReplicationPath(secondary))
+}
+
 func (s *Store) LoadSentinel(must bool) (*Sentinel, error) {
 	b, err := s.client.Read(s.SentinelPath(), must)
 	if err != nil || b == nil {
@@ -255,6 +491,230 @@ func (s *Store) UpdateSentinel(p *Sentinel) error {
 	return s.client.Update(s.SentinelPath(), p.Encode())
 }
 
+func (s *Store) LoadStandby(must bool) (*Standby, error) {
+	b, err := s.client.Read(s.StandbyPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &Standby{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateStandby(p *Standby) error {
+	return s.client.Update(s.StandbyPath(), p.Encode())
+}
+
+func (s *Store) LoadSlotHeat(must bool) (*SlotHeat, error) {
+	b, err := s.client.Read(s.SlotHeatPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &SlotHeat{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateSlotHeat(p *SlotHeat) error {
+	return s.client.Update(s.SlotHeatPath(), p.Encode())
+}
+
+func (s *Store) LoadSlotHistory(sid int, must bool) (*SlotHistory, error) {
+	b, err := s.client.Read(s.SlotHistoryPath(sid), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	h := &SlotHistory{}
+	if err := jsonDecode(h, b); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (s *Store) UpdateSlotHistory(h *SlotHistory) error {
+	return s.client.Update(s.SlotHistoryPath(h.Id), h.Encode())
+}
+
+func (s *Store) LoadSlotVerifyFailures(must bool) (*SlotVerifyFailures, error) {
+	b, err := s.client.Read(s.SlotVerifyPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &SlotVerifyFailures{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateSlotVerifyFailures(p *SlotVerifyFailures) error {
+	return s.client.Update(s.SlotVerifyPath(), p.Encode())
+}
+
+func (s *Store) LoadAuditLog(must bool) (*AuditLog, error) {
+	b, err := s.client.Read(s.AuditLogPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &AuditLog{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateAuditLog(p *AuditLog) error {
+	return s.client.Update(s.AuditLogPath(), p.Encode())
+}
+
+func (s *Store) LoadKeyQuotas(must bool) (*KeyQuotas, error) {
+	b, err := s.client.Read(s.KeyQuotaPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &KeyQuotas{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateKeyQuotas(p *KeyQuotas) error {
+	return s.client.Update(s.KeyQuotaPath(), p.Encode())
+}
+
+func (s *Store) LoadPrefixRoutes(must bool) (*PrefixRoutes, error) {
+	b, err := s.client.Read(s.PrefixRoutePath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &PrefixRoutes{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdatePrefixRoutes(p *PrefixRoutes) error {
+	return s.client.Update(s.PrefixRoutePath(), p.Encode())
+}
+
+func (s *Store) LoadConfigOverrides(must bool) (*ConfigOverrides, error) {
+	b, err := s.client.Read(s.ConfigOverridePath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &ConfigOverrides{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateConfigOverrides(p *ConfigOverrides) error {
+	return s.client.Update(s.ConfigOverridePath(), p.Encode())
+}
+
+func (s *Store) LoadTTLRules(must bool) (*TTLRules, error) {
+	b, err := s.client.Read(s.TTLRulePath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &TTLRules{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateTTLRules(p *TTLRules) error {
+	return s.client.Update(s.TTLRulePath(), p.Encode())
+}
+
+func (s *Store) LoadRequestFilter(must bool) (*RequestFilter, error) {
+	b, err := s.client.Read(s.RequestFilterPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &RequestFilter{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateRequestFilter(p *RequestFilter) error {
+	return s.client.Update(s.RequestFilterPath(), p.Encode())
+}
+
+func (s *Store) LoadNamespaces(must bool) (*Namespaces, error) {
+	b, err := s.client.Read(s.NamespacePath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &Namespaces{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateNamespaces(p *Namespaces) error {
+	return s.client.Update(s.NamespacePath(), p.Encode())
+}
+
+func (s *Store) LoadACLUsers(must bool) (*ACLUsers, error) {
+	b, err := s.client.Read(s.ACLPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &ACLUsers{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateACLUsers(p *ACLUsers) error {
+	return s.client.Update(s.ACLPath(), p.Encode())
+}
+
+func (s *Store) LoadScaling(must bool) (*ScalingWorkflow, error) {
+	b, err := s.client.Read(s.ScalingPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	w := &ScalingWorkflow{}
+	if err := jsonDecode(w, b); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (s *Store) UpdateScaling(w *ScalingWorkflow) error {
+	return s.client.Update(s.ScalingPath(), w.Encode())
+}
+
+func (s *Store) LoadSwitchover(must bool) (*Switchover, error) {
+	b, err := s.client.Read(s.SwitchoverPath(), must)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	p := &Switchover{}
+	if err := jsonDecode(p, b); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *Store) UpdateSwitchover(p *Switchover) error {
+	return s.client.Update(s.SwitchoverPath(), p.Encode())
+}
+
 func ValidateProduct(name string) error {
 	if regexp.MustCompile(`^\w[\w\.\-]*$`).MatchString(name) {
 		return nil
@@ -0,0 +1,14 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//proxy切换到的目标product，Product为空时proxy使用当前product的拓扑
+type Switchover struct {
+	Product    string `json:"product,omitempty"`
+	SwitchTime string `json:"switch_time,omitempty"`
+}
+
+func (p *Switchover) Encode() []byte {
+	return jsonEncode(p)
+}
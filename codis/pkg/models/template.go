@@ -0,0 +1,13 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+type ConfigTemplate struct {
+	Name   string            `json:"name"`
+	Config map[string]string `json:"config"`
+}
+
+func (t *ConfigTemplate) Encode() []byte {
+	return jsonEncode(t)
+}
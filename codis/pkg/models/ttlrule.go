@@ -0,0 +1,21 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package models
+
+//按key前缀限制写入的过期时间，单位为秒，0表示不限制
+//MinTTL和MaxTTL用于修正SET EX/PX、EXPIRE等命令的参数，DefaultTTL用于给没有过期时间的SET加上过期时间
+type TTLRule struct {
+	Prefix     string `json:"prefix"`
+	MinTTL     int64  `json:"min_ttl,omitempty"`
+	MaxTTL     int64  `json:"max_ttl,omitempty"`
+	DefaultTTL int64  `json:"default_ttl,omitempty"`
+}
+
+type TTLRules struct {
+	Rules []*TTLRule `json:"rules"`
+}
+
+func (p *TTLRules) Encode() []byte {
+	return jsonEncode(p)
+}
@@ -0,0 +1,260 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+const (
+	ACLSourceFile      = "file"
+	ACLSourceDashboard = "dashboard"
+)
+
+//AUTH <password>在session_auth和租户都不匹配时按该用户认证
+const aclDefaultUser = "default"
+
+type aclRule struct {
+	allow bool
+	name  string
+}
+
+//session认证后绑定用户名，每个请求按名字查找，用户被删除或禁用后请求会被拒绝
+type aclUser struct {
+	models.ACLUser
+	source string
+	rules  []aclRule
+
+	stats *aclCounters
+}
+
+type aclCounters struct {
+	authFails atomic2.Int64
+	rejected  atomic2.Int64
+}
+
+type ACLUserStatus struct {
+	Name        string   `json:"name"`
+	Source      string   `json:"source"`
+	Disabled    bool     `json:"disabled,omitempty"`
+	Commands    []string `json:"commands"`
+	KeyPatterns []string `json:"key_patterns,omitempty"`
+	AuthFails   int64    `json:"auth_fails"`
+	Rejected    int64    `json:"rejected"`
+}
+
+type aclTable struct {
+	list   []*aclUser
+	byName map[string]*aclUser
+}
+
+//session_acl_file和dashboard下发的用户，同名时以dashboard为准
+var aclUsers struct {
+	sync.Mutex
+	file      []*models.ACLUser
+	dashboard []*models.ACLUser
+	table     atomic.Value
+}
+
+func init() {
+	aclUsers.table.Store(&aclTable{})
+}
+
+func newACLUser(x *models.ACLUser, source string) (*aclUser, error) {
+	if err := x.Validate(); err != nil {
+		return nil, err
+	}
+	u := &aclUser{ACLUser: *x, source: source}
+	for _, rule := range x.Commands {
+		allow, name, _ := models.ParseACLRule(rule)
+		u.rules = append(u.rules, aclRule{allow: allow, name: name})
+	}
+	return u, nil
+}
+
+//更新用户时保留同名用户的计数
+func rebuildACLUsers() error {
+	var last = aclUsers.table.Load().(*aclTable)
+	var t = &aclTable{byName: make(map[string]*aclUser)}
+	for _, x := range []struct {
+		source string
+		users  []*models.ACLUser
+	}{
+		{ACLSourceFile, aclUsers.file}, {ACLSourceDashboard, aclUsers.dashboard},
+	} {
+		for _, p := range x.users {
+			if p == nil {
+				continue
+			}
+			u, err := newACLUser(p, x.source)
+			if err != nil {
+				return err
+			}
+			if o := last.byName[u.Name]; o != nil {
+				u.stats = o.stats
+			} else {
+				u.stats = &aclCounters{}
+			}
+			t.byName[u.Name] = u
+		}
+	}
+	for _, u := range t.byName {
+		t.list = append(t.list, u)
+	}
+	sort.Sort(sliceACLUser(t.list))
+	aclUsers.table.Store(t)
+	return nil
+}
+
+func SetACLUsers(list []*models.ACLUser) error {
+	aclUsers.Lock()
+	defer aclUsers.Unlock()
+	var last = aclUsers.dashboard
+	aclUsers.dashboard = list
+	if err := rebuildACLUsers(); err != nil {
+		aclUsers.dashboard = last
+		return err
+	}
+	return nil
+}
+
+//文件内容与dashboard下发的格式相同，path为空时清空文件中的用户
+func LoadACLFile(path string) error {
+	var p = &models.ACLUsers{}
+	if path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := json.Unmarshal(b, p); err != nil {
+			return errors.Errorf("invalid acl file %s: %s", path, err)
+		}
+	}
+	aclUsers.Lock()
+	defer aclUsers.Unlock()
+	var last = aclUsers.file
+	aclUsers.file = p.Users
+	if err := rebuildACLUsers(); err != nil {
+		aclUsers.file = last
+		return err
+	}
+	return nil
+}
+
+func GetACLUserStatus() []*ACLUserStatus {
+	var t = aclUsers.table.Load().(*aclTable)
+	var all = make([]*ACLUserStatus, 0, len(t.list))
+	for _, u := range t.list {
+		all = append(all, &ACLUserStatus{
+			Name: u.Name, Source: u.source, Disabled: u.Disabled,
+			Commands: u.Commands, KeyPatterns: u.KeyPatterns,
+			AuthFails: u.stats.authFails.Int64(), Rejected: u.stats.rejected.Int64(),
+		})
+	}
+	return all
+}
+
+func hasACLUsers() bool {
+	return len(aclUsers.table.Load().(*aclTable).list) != 0
+}
+
+func getACLUser(name string) *aclUser {
+	return aclUsers.table.Load().(*aclTable).byName[name]
+}
+
+//禁用的用户不能认证
+func authACLUser(name, password string) *aclUser {
+	u := getACLUser(name)
+	if u == nil || u.Disabled {
+		return nil
+	}
+	var expect, given = u.Password, password
+	if strings.HasPrefix(expect, "#") {
+		sum := sha256.Sum256([]byte(password))
+		expect, given = strings.ToLower(expect[1:]), hex.EncodeToString(sum[:])
+	}
+	if subtle.ConstantTimeCompare([]byte(expect), []byte(given)) != 1 {
+		u.stats.authFails.Incr()
+		return nil
+	}
+	return u
+}
+
+func (u *aclUser) allowCommand(r *Request) bool {
+	var allow bool
+	for _, rule := range u.rules {
+		switch rule.name {
+		case r.OpStr, models.ACLCategoryAll:
+		case models.ACLCategoryRead:
+			if !r.OpFlag.IsReadOnly() {
+				continue
+			}
+		case models.ACLCategoryWrite:
+			if r.OpFlag.IsReadOnly() {
+				continue
+			}
+		default:
+			continue
+		}
+		allow = rule.allow
+	}
+	return allow
+}
+
+func (u *aclUser) allowKey(key []byte) bool {
+	for _, pattern := range u.KeyPatterns {
+		if globMatch(pattern, string(key)) {
+			return true
+		}
+	}
+	return false
+}
+
+//检查用户是否可以执行该命令以及访问请求中的key，key的判断与租户相同
+func (u *aclUser) check(r *Request) *redis.Resp {
+	if u.Disabled {
+		return redis.NewErrorf("NOAUTH user '%s' is disabled", u.Name)
+	}
+	if !u.allowCommand(r) {
+		u.stats.rejected.Incr()
+		return redis.NewErrorf("NOPERM this user has no permissions to run the '%s' command", strings.ToLower(r.OpStr))
+	}
+	if len(u.KeyPatterns) == 0 || len(r.Multi) < 2 || namespaceKeylessCommands[r.OpStr] {
+		return nil
+	}
+	for _, key := range namespaceKeys(r) {
+		if !u.allowKey(key) {
+			u.stats.rejected.Incr()
+			return redis.NewErrorf("NOPERM this user has no permissions to access one of the keys used as arguments")
+		}
+	}
+	return nil
+}
+
+type sliceACLUser []*aclUser
+
+func (s sliceACLUser) Len() int {
+	return len(s)
+}
+
+func (s sliceACLUser) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceACLUser) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
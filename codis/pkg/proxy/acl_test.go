@@ -0,0 +1,86 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func newACLRequest(args ...string) *Request {
+	r := newQuotaRequest(args...)
+	opstr, flag, _, _, err := getOpInfo(r.Multi)
+	assert.MustNoError(err)
+	r.OpStr, r.OpFlag = opstr, flag
+	return r
+}
+
+func TestACLUsers(x *testing.T) {
+	defer SetACLUsers(nil)
+
+	sum := sha256.Sum256([]byte("pr"))
+	assert.MustNoError(SetACLUsers([]*models.ACLUser{
+		{Name: "app", Password: "pa", Commands: []string{"+@all", "-flushdb"}, KeyPatterns: []string{"app:*"}},
+		{Name: "reader", Password: "#" + hex.EncodeToString(sum[:]), Commands: []string{"+@read", "+ping"}},
+		{Name: "off", Password: "po", Disabled: true, Commands: []string{"+@all"}},
+	}))
+	assert.Must(hasACLUsers() && len(GetACLUserStatus()) == 3)
+	assert.Must(SetACLUsers([]*models.ACLUser{{Name: "bad", Password: "p", Commands: []string{"get"}}}) != nil)
+	assert.Must(getACLUser("app") != nil)
+
+	assert.Must(authACLUser("app", "pa") != nil)
+	assert.Must(authACLUser("app", "pb") == nil)
+	assert.Must(authACLUser("reader", "pr") != nil)
+	assert.Must(authACLUser("off", "po") == nil)
+	assert.Must(authACLUser("none", "pa") == nil)
+
+	u := getACLUser("app")
+	assert.Must(u.check(newACLRequest("GET", "app:1")) == nil)
+	assert.Must(u.check(newACLRequest("GET", "other:1")) != nil)
+	assert.Must(u.check(newACLRequest("MSET", "app:1", "v", "other:1", "v")) != nil)
+	assert.Must(u.check(newACLRequest("FLUSHDB")) != nil)
+	assert.Must(u.check(newACLRequest("PING")) == nil)
+
+	u = getACLUser("reader")
+	assert.Must(u.check(newACLRequest("GET", "any")) == nil)
+	assert.Must(u.check(newACLRequest("SET", "any", "v")) != nil)
+	assert.Must(u.check(newACLRequest("PING")) == nil)
+
+	status := GetACLUserStatus()
+	assert.Must(status[0].Name == "app" && status[0].Rejected == 3 && status[0].AuthFails == 1)
+	assert.Must(status[2].Name == "reader" && status[2].Source == ACLSourceDashboard)
+}
+
+func TestACLFile(x *testing.T) {
+	defer SetACLUsers(nil)
+	defer LoadACLFile("")
+
+	dir, err := ioutil.TempDir("", "acl")
+	assert.MustNoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "acl.json")
+	assert.MustNoError(ioutil.WriteFile(path, []byte(`{"users": [
+		{"name": "app", "password": "pa", "commands": ["+@all"]},
+		{"name": "ops", "password": "po", "commands": ["+@all"]}
+	]}`), 0644))
+	assert.MustNoError(LoadACLFile(path))
+	assert.Must(len(GetACLUserStatus()) == 2 && getACLUser("app").source == ACLSourceFile)
+
+	//dashboard下发的同名用户优先
+	assert.MustNoError(SetACLUsers([]*models.ACLUser{{Name: "app", Password: "pb", Commands: []string{"+get"}}}))
+	assert.Must(len(GetACLUserStatus()) == 2 && getACLUser("app").source == ACLSourceDashboard)
+	assert.Must(authACLUser("app", "pb") != nil)
+
+	assert.MustNoError(ioutil.WriteFile(path, []byte(`{"users": [{"name": "app"}]}`), 0644))
+	assert.Must(LoadACLFile(path) != nil)
+	assert.Must(getACLUser("ops") != nil)
+}
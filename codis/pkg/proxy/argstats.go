@@ -0,0 +1,133 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//按2的幂次划分桶，第i个桶统计 [2^(i-1), 2^i) 范围内的值，第0个桶统计0
+const SizeBucketNum = 40
+
+type sizeHistogram struct {
+	buckets [SizeBucketNum]atomic2.Int64
+	count   atomic2.Int64
+	max     atomic2.Int64
+}
+
+type SizeSummary struct {
+	P50 int64 `json:"p50"`
+	P90 int64 `json:"p90"`
+	P99 int64 `json:"p99"`
+	Max int64 `json:"max"`
+}
+
+func (h *sizeHistogram) incr(v int64) {
+	if v < 0 {
+		return
+	}
+	var index int
+	for x := v; x != 0 && index < SizeBucketNum-1; x >>= 1 {
+		index++
+	}
+	h.buckets[index].Incr()
+	h.count.Incr()
+	for {
+		lastMax := h.max.Int64()
+		if v <= lastMax || h.max.CompareAndSwap(lastMax, v) {
+			break
+		}
+	}
+}
+
+func (h *sizeHistogram) reset() {
+	for i := range h.buckets {
+		h.buckets[i].Set(0)
+	}
+	h.count.Set(0)
+	h.max.Set(0)
+}
+
+//返回百分位所在桶的上界，结果是一个估计值
+func (h *sizeHistogram) percentile(p float64) int64 {
+	total := h.count.Int64()
+	if total == 0 {
+		return 0
+	}
+	var target = int64(float64(total) * p)
+	if target < 1 {
+		target = 1
+	}
+	var count int64
+	for i := range h.buckets {
+		count += h.buckets[i].Int64()
+		if count >= target {
+			if i == 0 {
+				return 0
+			}
+			upper := int64(1)<<uint(i) - 1
+			if max := h.max.Int64(); upper > max {
+				return max
+			}
+			return upper
+		}
+	}
+	return h.max.Int64()
+}
+
+func (h *sizeHistogram) summary() *SizeSummary {
+	return &SizeSummary{
+		P50: h.percentile(0.5),
+		P90: h.percentile(0.9),
+		P99: h.percentile(0.99),
+		Max: h.max.Int64(),
+	}
+}
+
+//命令参数大小分布：key长度、value大小(除key外最大的参数)、参数个数
+type argStats struct {
+	keyLen    sizeHistogram
+	valueSize sizeHistogram
+	argCount  sizeHistogram
+}
+
+type ArgStats struct {
+	KeyLen    *SizeSummary `json:"key_len"`
+	ValueSize *SizeSummary `json:"value_size"`
+	ArgCount  *SizeSummary `json:"arg_count"`
+}
+
+func (s *argStats) incr(multi []*redis.Resp) {
+	if len(multi) == 0 {
+		return
+	}
+	s.argCount.incr(int64(len(multi) - 1))
+	if len(multi) >= 2 {
+		s.keyLen.incr(int64(len(multi[1].Value)))
+	}
+	if len(multi) >= 3 {
+		var size int
+		for _, arg := range multi[2:] {
+			if len(arg.Value) > size {
+				size = len(arg.Value)
+			}
+		}
+		s.valueSize.incr(int64(size))
+	}
+}
+
+func (s *argStats) reset() {
+	s.keyLen.reset()
+	s.valueSize.reset()
+	s.argCount.reset()
+}
+
+func (s *argStats) snapshot() *ArgStats {
+	return &ArgStats{
+		KeyLen:    s.keyLen.summary(),
+		ValueSize: s.valueSize.summary(),
+		ArgCount:  s.argCount.summary(),
+	}
+}
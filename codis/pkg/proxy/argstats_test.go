@@ -0,0 +1,37 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSizeHistogram(t *testing.T) {
+	var h sizeHistogram
+	assert.Must(h.percentile(0.5) == 0)
+	for i := 1; i <= 100; i++ {
+		h.incr(int64(i))
+	}
+	assert.Must(h.max.Int64() == 100)
+	assert.Must(h.percentile(0.5) == 63)
+	assert.Must(h.percentile(0.99) == 100)
+	h.reset()
+	assert.Must(h.count.Int64() == 0 && h.max.Int64() == 0)
+}
+
+func TestArgStats(t *testing.T) {
+	var s argStats
+	s.incr([]*redis.Resp{
+		redis.NewBulkBytes([]byte("SET")),
+		redis.NewBulkBytes([]byte("key")),
+		redis.NewBulkBytes(make([]byte, 1024)),
+	})
+	o := s.snapshot()
+	assert.Must(o.ArgCount.Max == 2)
+	assert.Must(o.KeyLen.Max == 3)
+	assert.Must(o.ValueSize.Max == 1024)
+}
@@ -10,6 +10,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/CodisLabs/codis/pkg/proxy/redis"
@@ -38,6 +39,25 @@ type BackendConn struct {
 	closed atomic2.Bool
 	config *Config
 
+	//已经加入队列但还没有收到响应的请求数
+	inflight atomic2.Int64
+
+	//loopReader正在等待响应的请求的接收时间，0表示没有在等待
+	waiting atomic2.Int64
+	reader  atomic.Value
+	stuck   atomic2.Bool
+	//阻塞命令的客户端已经断开，不再等待正在执行的请求
+	canceled atomic2.Bool
+
+	//连接池使用情况，见BackendPoolStats
+	pool struct {
+		reconnects atomic2.Int64
+		waits      atomic2.Int64
+		waitNsecs  atomic2.Int64
+	}
+	//按后端地址统计的延迟和错误，见BackendStats
+	stats *backendCounters
+
 	database int
 }
 
@@ -46,6 +66,7 @@ func NewBackendConn(addr string, database int, config *Config) *BackendConn {
 		addr: addr, config: config, database: database,
 	}
 	bc.input = make(chan *Request, 1024)
+	bc.stats = acquireBackendCounters(addr)
 	bc.retry.delay = &DelayExp2{
 		Min: 50, Max: 5000,
 		Unit: time.Millisecond,
@@ -63,6 +84,7 @@ func (bc *BackendConn) Addr() string {
 func (bc *BackendConn) Close() {
 	bc.stop.Do(func() {
 		close(bc.input)
+		releaseBackendCounters(bc.stats)
 	})
 	bc.closed.Set(true)
 }
@@ -81,9 +103,16 @@ func (bc *BackendConn) PushBack(r *Request) {
 	if r.Batch != nil {
 		r.Batch.Add(1)
 	}
+	bc.inflight.Incr()
+	r.EnqueueTime = time.Now().UnixNano()
+	r.BackendAddr = bc.addr
 	bc.input <- r
 }
 
+func (bc *BackendConn) Inflight() int64 {
+	return bc.inflight.Int64()
+}
+
 func (bc *BackendConn) KeepAlive() bool {
 	if len(bc.input) != 0 {
 		return false
@@ -161,9 +190,9 @@ func init() {
 }
 
 func (bc *BackendConn) newBackendReader(round int, config *Config) (*redis.Conn, chan<- *Request, error) {
-	c, err := redis.DialTimeout(bc.addr, time.Second*5,
+	c, err := redis.DialTLSTimeout(bc.addr, time.Second*5,
 		config.BackendRecvBufsize.AsInt(),
-		config.BackendSendBufsize.AsInt())
+		config.BackendSendBufsize.AsInt(), config.backendTLS)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -179,6 +208,12 @@ func (bc *BackendConn) newBackendReader(round int, config *Config) (*redis.Conn,
 		c.Close()
 		return nil, nil, err
 	}
+	if config.BackendRESP3 {
+		if err := bc.helloRESP3(c); err != nil {
+			c.Close()
+			return nil, nil, err
+		}
+	}
 
 	tasks := make(chan *Request, config.BackendMaxPipeline)
 	go bc.loopReader(tasks, c, round)
@@ -244,6 +279,32 @@ func (bc *BackendConn) selectDatabase(c *redis.Conn, database int) error {
 	}
 }
 
+//server不支持HELLO时继续使用RESP2
+func (bc *BackendConn) helloRESP3(c *redis.Conn) error {
+	multi := []*redis.Resp{
+		redis.NewBulkBytes([]byte("HELLO")),
+		redis.NewBulkBytes([]byte("3")),
+	}
+
+	if err := c.EncodeMultiBulk(multi, true); err != nil {
+		return err
+	}
+
+	resp, err := c.Decode()
+	switch {
+	case err != nil:
+		return err
+	case resp == nil:
+		return ErrRespIsRequired
+	case resp.IsError():
+		log.Warnf("backend conn [%p] to %s, db-%d hello 3 failed, fall back to resp2: %s",
+			bc, bc.addr, bc.database, resp.Value)
+		return nil
+	default:
+		return nil
+	}
+}
+
 //r.Group slot中命令数量
 //r.Batch 批量命令数量
 //准备加入队列前执行，这里只是将slot命令计数器减一
@@ -256,6 +317,11 @@ func SetResponse(r *Request, resp *redis.Resp, err error) error {
 	return err
 }
 func (bc *BackendConn) setResponse(r *Request, resp *redis.Resp, err error) error {	
+	bc.inflight.Decr()
+	if err != nil && bc.stats != nil {
+		bc.stats.fails.Incr()
+	}
+	bc.circuitResult(err)
 	r.Resp, r.Err = resp, err
 	if r.Group != nil {
 		r.Group.Done()
@@ -267,8 +333,10 @@ func (bc *BackendConn) setResponse(r *Request, resp *redis.Resp, err error) erro
 }
 
 var (
-	ErrBackendConnReset = errors.New("backend conn reset")
-	ErrRequestIsBroken  = errors.New("request is broken")
+	ErrBackendConnReset        = errors.New("backend conn reset")
+	ErrRequestIsBroken         = errors.New("request is broken")
+	ErrRequestDeadlineExceeded = errors.New("request deadline exceeded")
+	ErrRequestStuck            = errors.New("request is stuck in backend")
 )
 
 func (bc *BackendConn) run() {
@@ -281,6 +349,10 @@ func (bc *BackendConn) run() {
 			bc.delayBeforeRetry()
 		}
 	}
+	//还没有建立连接就被关闭时，队列中的请求直接失败
+	for r := range bc.input {
+		bc.setResponse(r, nil, ErrBackendConnReset)
+	}
 	log.Warnf("backend conn [%p] to %s, db-%d stop and exit",
 		bc, bc.addr, bc.database)
 }
@@ -300,10 +372,29 @@ func (bc *BackendConn) loopReader(tasks <-chan *Request, c *redis.Conn, round in
 		log.WarnErrorf(err, "backend conn [%p] to %s, db-%d reader-[%d] exit",
 			bc, bc.addr, bc.database, round)
 	}()
+	bc.stuck.Set(false)
+	bc.reader.Store(c)
+	if bc.canceled.IsTrue() {
+		c.Close()
+	}
 	for r := range tasks {
-		resp, err := c.Decode()
+		if r.ReceiveTime != 0 {
+			bc.waiting.Set(r.ReceiveTime)
+		} else {
+			bc.waiting.Set(time.Now().UnixNano())
+		}
+		resp, err := decodeReply(c)
+		//事务先收到MULTI和排队命令的响应，最后才是EXEC的响应
+		for i := 0; r.Tx != nil && i <= len(r.Tx) && err == nil; i++ {
+			resp, err = decodeReply(c)
+		}
+		bc.waiting.Set(0)
 		r.ReceiveFromServerTime = time.Now().UnixNano()
 		if err != nil {
+			if bc.stuck.CompareAndSwap(true, false) {
+				incrOpStuck()
+				return bc.setResponse(r, nil, ErrRequestStuck)
+			}
 			return bc.setResponse(r, nil, fmt.Errorf("backend conn failure, %s", err))
 		}
 		if resp != nil && resp.IsError() {
@@ -320,11 +411,48 @@ func (bc *BackendConn) loopReader(tasks <-chan *Request, c *redis.Conn, round in
 				}
 			}
 		}
+		if bc.stats != nil && r.SendToServerTime != 0 {
+			bc.stats.incr(resp, r.ReceiveFromServerTime-r.SendToServerTime)
+		}
 		bc.setResponse(r, resp, nil)
 	}
 	return nil
 }
 
+//RESP3的push消息不是请求的响应，直接丢弃
+func decodeReply(c *redis.Conn) (*redis.Resp, error) {
+	resp, err := c.Decode()
+	for err == nil && resp.IsPush() {
+		resp, err = c.Decode()
+	}
+	return resp, err
+}
+
+//等待响应的时间超过timeout
+func (bc *BackendConn) isStuck(timeout time.Duration, now int64) bool {
+	since := bc.waiting.Int64()
+	return since != 0 && now-since >= int64(timeout)
+}
+
+//关闭连接，卡住的请求以ErrRequestStuck失败，其后的请求以ErrBackendConnReset失败，然后重新建立连接
+func (bc *BackendConn) abortStuck() {
+	if c, ok := bc.reader.Load().(*redis.Conn); ok {
+		bc.stuck.Set(true)
+		c.Close()
+	}
+}
+
+//模拟后端不可达，等待backend_recv_timeout后以ErrBackendBlackholed失败
+func (bc *BackendConn) blackhole(r *Request) {
+	d := bc.config.BackendRecvTimeout.Duration()
+	if d <= 0 {
+		d = time.Second * 30
+	}
+	time.AfterFunc(d, func() {
+		bc.setResponse(r, nil, ErrBackendBlackholed)
+	})
+}
+
 func (bc *BackendConn) delayBeforeRetry() {
 	bc.retry.fails += 1
 	if bc.retry.fails <= 10 {
@@ -358,11 +486,15 @@ func (bc *BackendConn) loopWriter(round int) (err error) {
 	}()
 	c, tasks, err := bc.newBackendReader(round, bc.config)
 	if err != nil {
+		bc.circuitResult(err)
 		return err
 	}
 	defer close(tasks)
 
 	bc.state.Set(stateConnected)
+	if round != 0 {
+		bc.pool.reconnects.Incr()
+	}
 	bc.retry.fails = 0
 	bc.retry.delay.Reset()
 
@@ -371,23 +503,62 @@ func (bc *BackendConn) loopWriter(round int) (err error) {
 	p.MaxBuffered = cap(tasks) / 2
 
 	for r := range bc.input {
+		//连接已经关闭(摘除后端且排空超时)，队列中剩余的请求直接失败
+		if bc.closed.IsTrue() {
+			bc.setResponse(r, nil, ErrBackendConnReset)
+			continue
+		}
 		if r.IsReadOnly() && r.IsBroken() {
 			bc.setResponse(r, nil, ErrRequestIsBroken)
 			continue
 		}
-		if err := p.EncodeMultiBulk(r.Multi); err != nil {
+		//客户端已经超时放弃的请求不再发送给后端
+		if r.IsExpired() {
+			bc.setResponse(r, nil, ErrRequestDeadlineExceeded)
+			continue
+		}
+		//熔断器打开时直接失败
+		if !bc.circuitAllow() {
+			bc.setResponse(r, nil, bc.stats.circuit.err)
+			continue
+		}
+		//混沌测试中被黑洞化的后端，请求不发送给后端
+		if isBackendBlackholed(bc.addr) {
+			bc.blackhole(r)
+			continue
+		}
+		if err := encodeRequest(p, r); err != nil {
 			return bc.setResponse(r, nil, fmt.Errorf("backend conn failure, %s", err))
 		}
 		if err := p.Flush(len(bc.input) == 0); err != nil {
 			return bc.setResponse(r, nil, fmt.Errorf("backend conn failure, %s", err))
-		} else {
-			tasks <- r
 		}
+		//交给loopReader之后不能再访问r，loopReader会用发送时间统计延迟
 		r.SendToServerTime = time.Now().UnixNano()
+		bc.pool.waits.Incr()
+		bc.pool.waitNsecs.Add(r.SendToServerTime - r.EnqueueTime)
+		tasks <- r
 	}
 	return nil
 }
 
+var txMulti = []*redis.Resp{redis.NewBulkBytes([]byte("MULTI"))}
+
+//事务依次发送MULTI、排队的命令和EXEC，由同一个writer写入，中间不会插入其他请求
+func encodeRequest(p *redis.FlushEncoder, r *Request) error {
+	if r.Tx != nil {
+		if err := p.EncodeMultiBulk(txMulti); err != nil {
+			return err
+		}
+		for _, multi := range r.Tx {
+			if err := p.EncodeMultiBulk(multi); err != nil {
+				return err
+			}
+		}
+	}
+	return p.EncodeMultiBulk(r.Multi)
+}
+
 //sharedBackendConn表示一个后端server对应的一组后端连接，包括多个db，以及每个db中多个连接
 type sharedBackendConn struct {
 	addr string
@@ -395,10 +566,11 @@ type sharedBackendConn struct {
 	port []byte
 
 	owner *sharedBackendConnPool 	//sharedBackendConnPool中包括多个server对应的连接
-	conns [][]*BackendConn      //对应多个db，每个db又有多个连接
-
-	//如果后端并行连接数只有一个，则每个db只有一个连接，这里数组指向不同的db中的唯一一个连接
-	single []*BackendConn
+	//对应多个db，每个db又有多个连接；除db-0外，某个db第一次被使用时才建立连接，
+	//避免backend_number_databases较大时每个后端的连接数成倍增长
+	conns  []atomic.Value
+	mu     sync.Mutex
+	closed bool
 
 	refcnt int
 }
@@ -413,22 +585,41 @@ func newSharedBackendConn(addr string, pool *sharedBackendConnPool) *sharedBacke
 		host: []byte(host), port: []byte(port),
 	}
 	s.owner = pool
-	s.conns = make([][]*BackendConn, pool.config.BackendNumberDatabases)
-	for database := range s.conns {
-		parallel := make([]*BackendConn, pool.parallel)
-		for i := range parallel {
-			parallel[i] = NewBackendConn(addr, database, pool.config)
+	s.conns = make([]atomic.Value, pool.config.BackendNumberDatabases)
+	s.parallel(0)
+	s.refcnt = 1
+	return s
+}
+
+//返回某个db对应的一组连接，如果还没有建立则创建
+func (s *sharedBackendConn) parallel(database int32) []*BackendConn {
+	if parallel, ok := s.conns[database].Load().([]*BackendConn); ok {
+		return parallel
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if parallel, ok := s.conns[database].Load().([]*BackendConn); ok {
+		return parallel
+	}
+	parallel := make([]*BackendConn, s.owner.parallel)
+	for i := range parallel {
+		parallel[i] = NewBackendConn(s.addr, int(database), s.owner.config)
+		if s.closed {
+			parallel[i].Close()
 		}
-		s.conns[database] = parallel
 	}
-	if pool.parallel == 1 {
-		s.single = make([]*BackendConn, len(s.conns))
-		for database := range s.conns {
-			s.single[database] = s.conns[database][0]
+	s.conns[database].Store(parallel)
+	return parallel
+}
+
+//遍历所有已经建立的连接
+func (s *sharedBackendConn) forEach(fn func(bc *BackendConn)) {
+	for i := range s.conns {
+		parallel, _ := s.conns[i].Load().([]*BackendConn)
+		for _, bc := range parallel {
+			fn(bc)
 		}
 	}
-	s.refcnt = 1
-	return s
 }
 
 func (s *sharedBackendConn) Addr() string {
@@ -450,12 +641,43 @@ func (s *sharedBackendConn) Release() {
 	if s.refcnt != 0 {
 		return
 	}
-	for _, parallel := range s.conns {
-		for _, bc := range parallel {
-			bc.Close()
-		}
-	}
+	//先从pool中摘除，保证不会有新的请求发送到该后端，然后等待已发送的请求完成后再关闭连接
 	delete(s.owner.pool, s.addr)
+	if timeout := s.owner.config.BackendDrainTimeout.Duration(); timeout > 0 {
+		go s.drainAndClose(timeout)
+	} else {
+		s.closeAll()
+	}
+}
+
+func (s *sharedBackendConn) inflight() int64 {
+	var n int64
+	s.forEach(func(bc *BackendConn) {
+		n += bc.Inflight()
+	})
+	return n
+}
+
+func (s *sharedBackendConn) drainAndClose(timeout time.Duration) {
+	var deadline = time.Now().Add(timeout)
+	for s.inflight() != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	if n := s.inflight(); n != 0 {
+		log.Warnf("shared backend conn to %s drain timeout, %d requests will be reset", s.addr, n)
+	} else {
+		log.Infof("shared backend conn to %s drained", s.addr)
+	}
+	s.closeAll()
+}
+
+func (s *sharedBackendConn) closeAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	s.forEach(func(bc *BackendConn) {
+		bc.Close()
+	})
 }
 
 func (s *sharedBackendConn) Retain() *sharedBackendConn {
@@ -474,11 +696,9 @@ func (s *sharedBackendConn) KeepAlive() {
 	if s == nil {
 		return
 	}
-	for _, parallel := range s.conns {
-		for _, bc := range parallel {
-			bc.KeepAlive()
-		}
-	}
+	s.forEach(func(bc *BackendConn) {
+		bc.KeepAlive()
+	})
 }
 
 func (s *sharedBackendConn) BackendConn(database int32, seed uint, isQuick bool, must bool) *BackendConn {
@@ -486,16 +706,17 @@ func (s *sharedBackendConn) BackendConn(database int32, seed uint, isQuick bool,
 		return nil
 	}
 
+	var parallel = s.parallel(database)
+
 	//这种情况后端只有一个连接，不区分快慢连接
-	if s.single != nil {
-		bc := s.single[database]
+	if len(parallel) == 1 {
+		bc := parallel[0]
 		if must || bc.IsConnected() {
 			return bc
 		}
 		return nil
 	}
 
-	var parallel = s.conns[database]
 	var i = seed
 
 	//如果是快请求则变量parallel中前面的quick个连接，
@@ -0,0 +1,122 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//每个后端server的连接池使用情况，由一组BackendConn汇总得到
+type BackendPoolStats struct {
+	Addr    string `json:"addr"`
+	Replica bool   `json:"replica,omitempty"`
+
+	Conns  int `json:"conns"`
+	Active int `json:"active"`
+	//已发送但还没有收到响应的请求数，以及还在队列中等待发送的请求数
+	Inflight int64 `json:"inflight"`
+	Queue    int64 `json:"queue"`
+	//最近一个统计周期内请求在队列中的平均等待时间
+	WaitUsecs  int64 `json:"wait_usecs"`
+	Reconnects int64 `json:"reconnects"`
+
+	Alarms []string `json:"alarms,omitempty"`
+
+	waits     int64
+	waitNsecs int64
+}
+
+var backendPools atomic.Value
+
+//返回所有后端连接池的使用情况，按地址排序
+func GetBackendPoolStats() []*BackendPoolStats {
+	pools, _ := backendPools.Load().([]*BackendPoolStats)
+	return pools
+}
+
+func (s *Router) BackendPools() []*BackendPoolStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var pools []*BackendPoolStats
+	for _, p := range []*sharedBackendConnPool{s.pool.primary, s.pool.replica} {
+		for addr, shared := range p.pool {
+			x := &BackendPoolStats{Addr: addr, Replica: p == s.pool.replica}
+			shared.forEach(func(bc *BackendConn) {
+				x.Conns++
+				if bc.IsConnected() {
+					x.Active++
+				}
+				x.Inflight += bc.Inflight()
+				x.Queue += int64(len(bc.input))
+				x.Reconnects += bc.pool.reconnects.Int64()
+				x.waits += bc.pool.waits.Int64()
+				x.waitNsecs += bc.pool.waitNsecs.Int64()
+			})
+			//已发送的请求不再计入队列
+			x.Inflight -= x.Queue
+			pools = append(pools, x)
+		}
+	}
+	sort.Sort(sliceBackendPoolStats(pools))
+	return pools
+}
+
+//每秒刷新一次连接池统计，超过阈值时记录告警并打印日志
+func (s *Proxy) AutoRefreshBackendPools() {
+	var last = make(map[string]*BackendPoolStats)
+	for !s.IsClosed() {
+		time.Sleep(time.Second)
+
+		s.mu.Lock()
+		maxInflight := s.config.BackendPoolAlarmInflight
+		maxWait := s.config.BackendPoolAlarmWait.Duration()
+		s.mu.Unlock()
+
+		var pools = s.router.BackendPools()
+		var next = make(map[string]*BackendPoolStats, len(pools))
+		for _, x := range pools {
+			key := x.Addr
+			if x.Replica {
+				key = "replica:" + x.Addr
+			}
+			next[key] = x
+			if p := last[key]; p != nil && x.waits > p.waits {
+				x.WaitUsecs = (x.waitNsecs - p.waitNsecs) / (x.waits - p.waits) / 1e3
+			}
+			if maxInflight > 0 && x.Inflight+x.Queue >= int64(maxInflight) {
+				x.Alarms = append(x.Alarms, "inflight")
+			}
+			if maxWait > 0 && x.WaitUsecs >= int64(maxWait/time.Microsecond) {
+				x.Alarms = append(x.Alarms, "wait")
+			}
+			if len(x.Alarms) != 0 && (last[key] == nil || len(last[key].Alarms) == 0) {
+				log.Warnf("[%p] backend pool to %s alarm %v, inflight = %d, queue = %d, wait = %dus",
+					s, x.Addr, x.Alarms, x.Inflight, x.Queue, x.WaitUsecs)
+			}
+		}
+		backendPools.Store(pools)
+		last = next
+	}
+}
+
+type sliceBackendPoolStats []*BackendPoolStats
+
+func (s sliceBackendPoolStats) Len() int {
+	return len(s)
+}
+
+func (s sliceBackendPoolStats) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceBackendPoolStats) Less(i, j int) bool {
+	if s[i].Replica != s[j].Replica {
+		return !s[i].Replica
+	}
+	return s[i].Addr < s[j].Addr
+}
@@ -0,0 +1,176 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//按后端server地址统计，同一个地址的所有连接(包括各个db)共用一组计数
+type backendCounters struct {
+	conns atomic2.Int64
+
+	calls atomic2.Int64
+	nsecs atomic2.Int64
+	//连接失败、stuck等没有拿到响应的请求
+	fails atomic2.Int64
+	//后端返回的错误响应
+	errors atomic2.Int64
+	hist   latencyHistogram
+
+	qps       atomic2.Int64
+	tp99      atomic2.Int64
+	tp999     atomic2.Int64
+	lastCalls int64
+	lastHist  []int64
+	//最近一个有请求的统计周期内的平均延迟，单位为us，从库读取按延迟加权时使用
+	latency   atomic2.Int64
+	lastNsecs int64
+
+	circuit circuitBreaker
+}
+
+type BackendStats struct {
+	Addr         string `json:"addr"`
+	Conns        int64  `json:"conns"`
+	Calls        int64  `json:"calls"`
+	Usecs        int64  `json:"usecs"`
+	UsecsPerCall int64  `json:"usecs_percall"`
+	Fails        int64  `json:"fails"`
+	RedisErrors  int64  `json:"redis_errors"`
+	QPS          int64  `json:"qps"`
+	//最近一个统计周期的tp99和tp999，单位为ms，取所在延迟区间的上界
+	TP99  int64 `json:"tp99"`
+	TP999 int64 `json:"tp999"`
+	//从proxy启动或ResetStats开始累计的延迟分布，与HistBucketMark对应，最后一个为超过3s的请求
+	Histogram []int64 `json:"histogram"`
+}
+
+var backendStats struct {
+	sync.RWMutex
+	m map[string]*backendCounters
+}
+
+func init() {
+	backendStats.m = make(map[string]*backendCounters)
+}
+
+//创建BackendConn时获取，Close时调用releaseBackendCounters
+func acquireBackendCounters(addr string) *backendCounters {
+	backendStats.Lock()
+	defer backendStats.Unlock()
+	c := backendStats.m[addr]
+	if c == nil {
+		c = &backendCounters{}
+		c.circuit.err = newCircuitBreakerError(addr)
+		backendStats.m[addr] = c
+	}
+	c.conns.Incr()
+	return c
+}
+
+func releaseBackendCounters(c *backendCounters) {
+	if c != nil {
+		c.conns.Decr()
+	}
+}
+
+//duration为请求发送到收到响应的时间，单位为ns
+func (c *backendCounters) incr(resp *redis.Resp, duration int64) {
+	c.calls.Incr()
+	c.nsecs.Add(duration)
+	c.hist.incr(duration)
+	if resp != nil && resp.IsError() {
+		c.errors.Incr()
+	}
+}
+
+//由统计协程定期调用，elapsed为距离上次调用的时间
+func refreshBackendStats(elapsed time.Duration) {
+	backendStats.RLock()
+	for _, c := range backendStats.m {
+		calls := c.calls.Int64()
+		delta := calls - c.lastCalls
+		c.lastCalls = calls
+		normalized := math.Max(0, float64(delta)) / float64(elapsed) * float64(time.Second)
+		c.qps.Set(int64(normalized + 0.5))
+
+		nsecs := c.nsecs.Int64()
+		//ResetStats之后差值可能为负数，保留上一个周期的值
+		if d := nsecs - c.lastNsecs; delta > 0 && d >= 0 {
+			c.latency.Set(d / delta / 1e3)
+		}
+		c.lastNsecs = nsecs
+
+		hist := c.hist.snapshot()
+		c.tp99.Set(histPercentile(hist, c.lastHist, 0.99))
+		c.tp999.Set(histPercentile(hist, c.lastHist, 0.999))
+		c.lastHist = hist
+	}
+	backendStats.RUnlock()
+}
+
+//按地址排序
+func GetBackendStats() []*BackendStats {
+	backendStats.RLock()
+	var all = make([]*BackendStats, 0, len(backendStats.m))
+	for addr, c := range backendStats.m {
+		o := &BackendStats{
+			Addr:        addr,
+			Conns:       c.conns.Int64(),
+			Calls:       c.calls.Int64(),
+			Usecs:       c.nsecs.Int64() / 1e3,
+			Fails:       c.fails.Int64(),
+			RedisErrors: c.errors.Int64(),
+			QPS:         c.qps.Int64(),
+			TP99:        c.tp99.Int64(),
+			TP999:       c.tp999.Int64(),
+			Histogram:   c.hist.snapshot(),
+		}
+		if o.Calls != 0 {
+			o.UsecsPerCall = o.Usecs / o.Calls
+		}
+		all = append(all, o)
+	}
+	backendStats.RUnlock()
+	sort.Sort(sliceBackendStats(all))
+	return all
+}
+
+//已经没有连接的地址直接删除，其余的清零
+func resetBackendStats() {
+	backendStats.Lock()
+	defer backendStats.Unlock()
+	for addr, c := range backendStats.m {
+		if c.conns.Int64() == 0 {
+			delete(backendStats.m, addr)
+			continue
+		}
+		c.calls.Set(0)
+		c.nsecs.Set(0)
+		c.fails.Set(0)
+		c.errors.Set(0)
+		c.hist.reset()
+	}
+}
+
+type sliceBackendStats []*BackendStats
+
+func (s sliceBackendStats) Len() int {
+	return len(s)
+}
+
+func (s sliceBackendStats) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceBackendStats) Less(i, j int) bool {
+	return s[i].Addr < s[j].Addr
+}
@@ -0,0 +1,52 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestBackendStats(x *testing.T) {
+	defer resetBackendStats()
+
+	c := acquireBackendCounters("10.0.0.1:6379")
+	assert.Must(acquireBackendCounters("10.0.0.1:6379") == c)
+	d := acquireBackendCounters("10.0.0.2:6379")
+	assert.Must(d != c)
+
+	for i := 0; i < 998; i++ {
+		c.incr(redis.NewBulkBytes([]byte("value")), int64(time.Microsecond*100))
+	}
+	c.incr(redis.NewErrorf("ERR"), int64(time.Millisecond*40))
+	c.incr(nil, int64(time.Second*4))
+	c.fails.Incr()
+	d.incr(nil, int64(time.Millisecond*2))
+	refreshBackendStats(time.Second)
+
+	list := GetBackendStats()
+	assert.Must(len(list) == 2)
+	s := list[0]
+	assert.Must(s.Addr == "10.0.0.1:6379" && s.Conns == 2)
+	assert.Must(s.Calls == 1000 && s.QPS == 1000)
+	assert.Must(s.Fails == 1 && s.RedisErrors == 1)
+	assert.Must(s.TP99 == 1 && s.TP999 == HistBucketMark[4])
+	assert.Must(len(s.Histogram) == HistBucketNum && s.Histogram[HistBucketNum-1] == 1)
+	assert.Must(list[1].Addr == "10.0.0.2:6379" && list[1].TP99 == 5)
+
+	//上个周期之后没有请求
+	refreshBackendStats(time.Second)
+	assert.Must(GetBackendStats()[0].TP99 == 0)
+
+	releaseBackendCounters(d)
+	resetBackendStats()
+	list = GetBackendStats()
+	assert.Must(len(list) == 1 && list[0].Calls == 0 && list[0].Histogram[0] == 0)
+
+	releaseBackendCounters(c)
+	releaseBackendCounters(c)
+}
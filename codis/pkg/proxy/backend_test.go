@@ -7,6 +7,7 @@ import (
 	"log"
 	"net"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -79,4 +80,132 @@ func TestBackend(t *testing.T) {
 		assert.Must(r.Resp != nil)
 		assert.Must(string(r.Resp.Value) == strconv.Itoa(i))
 	}
+	assert.Must(bc.Inflight() == 0)
+	assert.Must(bc.pool.waits.Int64() == int64(len(array)))
+	assert.Must(bc.pool.waitNsecs.Int64() > 0 && bc.pool.reconnects.Int64() == 0)
+}
+
+func TestBackendDeadline(t *testing.T) {
+	config := NewDefaultConfig()
+	config.BackendSendTimeout.Set(time.Second)
+	config.BackendRecvTimeout.Set(time.Minute)
+
+	conn, bc := newConnPair(config)
+	defer bc.Close()
+
+	go func() {
+		defer conn.Close()
+		for _, op := range []string{"applied", "late", "normal"} {
+			m, err := conn.Decode()
+			assert.MustNoError(err)
+			//已经超时的请求不会发送给后端
+			assert.Must(string(m.Array[0].Value) == op)
+			if op == "late" {
+				time.Sleep(time.Millisecond * 300)
+			}
+			assert.MustNoError(conn.Encode(redis.NewString([]byte(op)), true))
+		}
+	}()
+
+	var newRequest = func(op string, deadline time.Duration) *Request {
+		r := &Request{Batch: &sync.WaitGroup{}}
+		r.Multi = []*redis.Resp{redis.NewBulkBytes([]byte(op))}
+		if deadline != 0 {
+			r.Deadline = time.Now().Add(deadline).UnixNano()
+		}
+		return r
+	}
+	var start = time.Now()
+	expired := newRequest("expired", -time.Second)
+	applied := newRequest("applied", time.Millisecond*50)
+	late := newRequest("late", time.Millisecond*200)
+	normal := newRequest("normal", 0)
+	for _, r := range []*Request{expired, applied, late, normal} {
+		bc.PushBack(r)
+		if r.Deadline != 0 {
+			r.waitBatchAsync()
+		}
+	}
+
+	//超时的请求返回错误，不会关闭session
+	s := &Session{}
+	resp, err := s.handleResponse(expired)
+	assert.MustNoError(err)
+	assert.Must(resp.IsError() && strings.Contains(string(resp.Value), "deadline exceeded"))
+
+	//截止时间之前已经收到的回复照常返回
+	time.Sleep(time.Millisecond * 100)
+	resp, err = s.handleResponse(applied)
+	assert.MustNoError(err)
+	assert.Must(string(resp.Value) == "applied")
+
+	//到截止时间时直接返回超时，不等待后端的回复
+	resp, err = s.handleResponse(late)
+	assert.MustNoError(err)
+	assert.Must(resp.IsError() && time.Since(start) < time.Millisecond*300)
+
+	resp, err = s.handleResponse(normal)
+	assert.MustNoError(err)
+	assert.Must(string(resp.Value) == "normal")
+}
+
+func TestBackendDrain(t *testing.T) {
+	for _, reply := range []bool{true, false} {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.MustNoError(err)
+
+		var release = make(chan struct{})
+		go func() {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn := redis.NewConn(c, 1024, 1024)
+			defer conn.Close()
+			for {
+				m, err := conn.Decode()
+				if err != nil {
+					return
+				}
+				//不回复时一直等到连接关闭
+				if !reply {
+					<-release
+					return
+				}
+				time.Sleep(time.Millisecond * 10)
+				assert.MustNoError(conn.Encode(redis.NewString(m.Array[0].Value), true))
+			}
+		}()
+
+		config := NewDefaultConfig()
+		config.BackendRecvTimeout.Set(time.Second)
+		config.BackendDrainTimeout.Set(time.Millisecond * 500)
+		pool := newSharedBackendConnPool(config, 1, 0)
+		s := pool.Retain(l.Addr().String())
+		bc := s.BackendConn(0, 0, false, true)
+
+		var array = make([]*Request, 16)
+		for i := range array {
+			r := &Request{Batch: &sync.WaitGroup{}}
+			r.Multi = []*redis.Resp{redis.NewBulkBytes([]byte(strconv.Itoa(i)))}
+			bc.PushBack(r)
+			array[i] = r
+		}
+
+		//释放后立即从pool中摘除，已经发送的请求都会收到回复或者错误
+		s.Release()
+		assert.Must(pool.Get(l.Addr().String()) == nil)
+		for i, r := range array {
+			r.Batch.Wait()
+			if reply {
+				assert.Must(r.Err == nil && string(r.Resp.Value) == strconv.Itoa(i))
+			} else {
+				assert.Must(r.Err != nil)
+			}
+		}
+		assert.Must(waitFor(bc.closed.Bool))
+
+		close(release)
+		l.Close()
+	}
 }
@@ -0,0 +1,179 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//记录的key最长保留的字节数
+const bigKeyMaxKeyLen = 128
+
+//响应超过阈值的key按模式聚合，模式由key中的连续数字替换为*得到
+type BigKeyPattern struct {
+	Pattern string `json:"pattern"`
+	//超过阈值的响应次数和字节数
+	Calls int64 `json:"calls"`
+	Bytes int64 `json:"bytes"`
+
+	//最大的一次响应
+	MaxBytes int64  `json:"max_bytes"`
+	MaxKey   string `json:"max_key"`
+	MaxOpStr string `json:"max_opstr"`
+
+	LastTime string `json:"last_time"`
+}
+
+type BigKeys struct {
+	Threshold   int64 `json:"threshold"`
+	MaxPatterns int   `json:"max_patterns"`
+	//模式数量达到上限后丢弃的响应次数
+	Dropped int64 `json:"dropped"`
+
+	Patterns []*BigKeyPattern `json:"patterns"`
+}
+
+var bigKeys struct {
+	sync.Mutex
+	m map[string]*BigKeyPattern
+
+	//0表示关闭
+	threshold atomic2.Int64
+	dropped   atomic2.Int64
+	max       int
+}
+
+func init() {
+	bigKeys.m = make(map[string]*BigKeyPattern)
+}
+
+func BigKeySetOptions(threshold int64, maxPatterns int) {
+	bigKeys.Lock()
+	defer bigKeys.Unlock()
+	bigKeys.max = maxPatterns
+	bigKeys.threshold.Set(threshold)
+}
+
+//把key中的连续数字替换为*，如user:1001:profile得到user:*:profile
+func bigKeyPattern(key []byte) string {
+	if len(key) > bigKeyMaxKeyLen {
+		key = key[:bigKeyMaxKeyLen]
+	}
+	var b = make([]byte, 0, len(key))
+	for i := 0; i < len(key); i++ {
+		if key[i] >= '0' && key[i] <= '9' {
+			if n := len(b); n == 0 || b[n-1] != '*' {
+				b = append(b, '*')
+			}
+			for i+1 < len(key) && key[i+1] >= '0' && key[i+1] <= '9' {
+				i++
+			}
+			continue
+		}
+		b = append(b, key[i])
+	}
+	return string(b)
+}
+
+//响应中所有bulk和status的字节数之和
+func respPayloadSize(resp *redis.Resp) int64 {
+	if resp == nil {
+		return 0
+	}
+	var n = int64(len(resp.Value))
+	for _, x := range resp.Array {
+		n += respPayloadSize(x)
+	}
+	return n
+}
+
+func recordBigKey(r *Request, resp *redis.Resp) {
+	threshold := bigKeys.threshold.Int64()
+	if threshold <= 0 || resp == nil || resp.IsError() || len(r.Multi) < 2 {
+		return
+	}
+	//MGET的响应按每个key分别统计
+	if r.OpStr == "MGET" && resp.IsArray() && len(resp.Array) == len(r.Multi)-1 {
+		for i, x := range resp.Array {
+			if n := respPayloadSize(x); n >= threshold {
+				addBigKey(r.Multi[i+1].Value, r.OpStr, n)
+			}
+		}
+		return
+	}
+	if n := respPayloadSize(resp); n >= threshold {
+		addBigKey(getHashKey(r.Multi, r.OpStr), r.OpStr, n)
+	}
+}
+
+func addBigKey(key []byte, opstr string, n int64) {
+	pattern := bigKeyPattern(key)
+
+	bigKeys.Lock()
+	defer bigKeys.Unlock()
+	p := bigKeys.m[pattern]
+	if p == nil {
+		if len(bigKeys.m) >= bigKeys.max {
+			bigKeys.dropped.Incr()
+			return
+		}
+		p = &BigKeyPattern{Pattern: pattern}
+		bigKeys.m[pattern] = p
+	}
+	p.Calls++
+	p.Bytes += n
+	if n >= p.MaxBytes {
+		if len(key) > bigKeyMaxKeyLen {
+			key = key[:bigKeyMaxKeyLen]
+		}
+		p.MaxBytes, p.MaxKey, p.MaxOpStr = n, string(key), opstr
+	}
+	p.LastTime = time.Now().Format("2006-01-02 15:04:05")
+}
+
+//按最大响应从大到小排序
+func GetBigKeys() *BigKeys {
+	bigKeys.Lock()
+	defer bigKeys.Unlock()
+	x := &BigKeys{
+		Threshold: bigKeys.threshold.Int64(), MaxPatterns: bigKeys.max,
+		Dropped: bigKeys.dropped.Int64(),
+	}
+	x.Patterns = make([]*BigKeyPattern, 0, len(bigKeys.m))
+	for _, p := range bigKeys.m {
+		c := *p
+		x.Patterns = append(x.Patterns, &c)
+	}
+	sort.Sort(sliceBigKeyPattern(x.Patterns))
+	return x
+}
+
+func resetBigKeys() {
+	bigKeys.Lock()
+	defer bigKeys.Unlock()
+	bigKeys.m = make(map[string]*BigKeyPattern)
+	bigKeys.dropped.Set(0)
+}
+
+type sliceBigKeyPattern []*BigKeyPattern
+
+func (s sliceBigKeyPattern) Len() int {
+	return len(s)
+}
+
+func (s sliceBigKeyPattern) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceBigKeyPattern) Less(i, j int) bool {
+	if s[i].MaxBytes != s[j].MaxBytes {
+		return s[i].MaxBytes > s[j].MaxBytes
+	}
+	return s[i].Pattern < s[j].Pattern
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestBigKeyPattern(t *testing.T) {
+	assert.Must(bigKeyPattern([]byte("user:1001:profile")) == "user:*:profile")
+	assert.Must(bigKeyPattern([]byte("order_20200101_3")) == "order_*_*")
+	assert.Must(bigKeyPattern([]byte("12ab34")) == "*ab*")
+	assert.Must(bigKeyPattern([]byte("abc")) == "abc")
+	assert.Must(len(bigKeyPattern([]byte(strings.Repeat("x", 1000)))) == bigKeyMaxKeyLen)
+}
+
+func TestRecordBigKey(t *testing.T) {
+	BigKeySetOptions(10, 2)
+	defer BigKeySetOptions(0, 0)
+	defer resetBigKeys()
+
+	newRequest := func(args ...string) *Request {
+		r := &Request{OpStr: args[0]}
+		for _, s := range args {
+			r.Multi = append(r.Multi, redis.NewBulkBytes([]byte(s)))
+		}
+		return r
+	}
+	big := redis.NewBulkBytes([]byte(strings.Repeat("x", 20)))
+	small := redis.NewBulkBytes([]byte("x"))
+
+	recordBigKey(newRequest("GET", "user:1"), big)
+	recordBigKey(newRequest("GET", "user:2"), small)
+	recordBigKey(newRequest("HGETALL", "user:3"), redis.NewArray([]*redis.Resp{small, big, small}))
+	recordBigKey(newRequest("MGET", "item:1", "item:2"), redis.NewArray([]*redis.Resp{small, big}))
+	recordBigKey(newRequest("GET", "other"), redis.NewErrorf("ERR %s", strings.Repeat("x", 20)))
+	//模式数量达到上限
+	recordBigKey(newRequest("GET", "other"), big)
+
+	x := GetBigKeys()
+	assert.Must(x.Threshold == 10 && x.MaxPatterns == 2 && x.Dropped == 1)
+	assert.Must(len(x.Patterns) == 2)
+	p := x.Patterns[0]
+	assert.Must(p.Pattern == "user:*" && p.Calls == 2 && p.Bytes == 42)
+	assert.Must(p.MaxBytes == 22 && p.MaxKey == "user:3" && p.MaxOpStr == "HGETALL")
+	p = x.Patterns[1]
+	assert.Must(p.Pattern == "item:*" && p.Calls == 1 && p.MaxKey == "item:2")
+
+	resetBigKeys()
+	x = GetBigKeys()
+	assert.Must(len(x.Patterns) == 0 && x.Dropped == 0)
+}
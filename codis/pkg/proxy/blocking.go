@@ -0,0 +1,137 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+	"github.com/CodisLabs/codis/pkg/utils/timesize"
+)
+
+//BLPOP key [key ...] timeout
+//BRPOP key [key ...] timeout
+//BRPOPLPUSH source destination timeout
+//BLMOVE source destination LEFT|RIGHT LEFT|RIGHT timeout
+//参数不合法时按普通命令发送，由后端返回错误
+func (s *Session) handleBlockingList(r *Request, d *Router) error {
+	var nargs = len(r.Multi) - 1
+	switch r.OpStr {
+	case "BLPOP", "BRPOP":
+		if nargs < 2 {
+			return d.dispatch(r)
+		}
+	case "BRPOPLPUSH":
+		if nargs != 3 {
+			return d.dispatch(r)
+		}
+	case "BLMOVE":
+		if nargs != 5 {
+			return d.dispatch(r)
+		}
+	}
+	timeout, err := strconv.ParseFloat(string(r.Multi[nargs].Value), 64)
+	if err != nil || timeout < 0 {
+		return d.dispatch(r)
+	}
+	keys := namespaceKeys(r)
+	slot, ok := d.keysSlot(keys)
+	if !ok {
+		r.Resp = redis.NewErrorf("CROSSSLOT Keys in request don't hash to the same slot")
+		return nil
+	}
+	return s.dispatchBlocking(r, d, slot, keys, time.Duration(timeout*float64(time.Second)))
+}
+
+//所有key属于同一个slot时返回该slot，前缀路由视为单独的slot
+func (s *Router) keysSlot(keys [][]byte) (*Slot, bool) {
+	var slot = s.keySlot(nil)
+	for i, key := range keys {
+		x := s.keySlot(key)
+		if i != 0 && x != slot {
+			return nil, false
+		}
+		slot = x
+	}
+	return slot, true
+}
+
+//阻塞命令使用独立的后端连接，避免阻塞共享连接上排在后面的请求，收到响应后关闭连接
+//slot正在迁移时先把key迁移到目标后端
+func (s *Session) dispatchBlocking(r *Request, d *Router, slot *Slot, keys [][]byte, block time.Duration) error {
+	s.blocking.Lock()
+	var n = len(s.blocking.conns)
+	s.blocking.Unlock()
+	if max := s.config.SessionMaxBlockingCommands; max != 0 && n >= max {
+		r.Resp = redis.NewErrorf("ERR too many blocking commands, max is %d", max)
+		return nil
+	}
+
+	addr, err := d.watchAddr(slot, keys, r)
+	if err != nil {
+		return err
+	}
+	//有截止时间时最多阻塞到截止时间，超时后后端连接读超时关闭
+	if r.Deadline != 0 {
+		if left := time.Until(time.Unix(0, r.Deadline)); block == 0 || left < block {
+			block = math2.MaxDuration(left, time.Millisecond)
+		}
+	}
+	bc := NewBackendConn(addr, int(s.database), blockingConfig(s.config, block))
+
+	s.blocking.Lock()
+	if s.blocking.conns == nil {
+		s.blocking.conns = make(map[*BackendConn]bool)
+	}
+	s.blocking.conns[bc] = true
+	s.blocking.Unlock()
+
+	bc.PushBack(r)
+	go func() {
+		r.Batch.Wait()
+		bc.Close()
+		//请求超时时不会执行Coalesce，在这里释放
+		s.blocking.Lock()
+		delete(s.blocking.conns, bc)
+		s.blocking.Unlock()
+	}()
+	//在返回响应之前释放，客户端收到响应后可以立即发送下一个阻塞命令
+	r.Coalesce = func() error {
+		s.blocking.Lock()
+		delete(s.blocking.conns, bc)
+		s.blocking.Unlock()
+		return nil
+	}
+	return nil
+}
+
+//客户端断开后不再等待阻塞命令的结果，关闭连接使正在等待的请求立即失败
+func (s *Session) cancelBlocking() {
+	s.blocking.Lock()
+	defer s.blocking.Unlock()
+	for bc := range s.blocking.conns {
+		bc.cancel()
+	}
+}
+
+func (bc *BackendConn) cancel() {
+	bc.canceled.Set(true)
+	bc.Close()
+	if c, ok := bc.reader.Load().(*redis.Conn); ok {
+		c.Close()
+	}
+}
+
+//读超时为阻塞时间加上backend_recv_timeout，一直阻塞时不设置读超时
+func blockingConfig(config *Config, block time.Duration) *Config {
+	var c = *config
+	if block == 0 || c.BackendRecvTimeout == 0 {
+		c.BackendRecvTimeout = 0
+	} else {
+		c.BackendRecvTimeout += timesize.Duration(block)
+	}
+	return &c
+}
@@ -0,0 +1,86 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/timesize"
+)
+
+//阻塞命令的timeout为0时一直不回复，否则等待timeout后返回nil
+func serveFakeBlocking(c *redis.Conn) {
+	defer c.Close()
+	for {
+		multi, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		var resp = RespOK
+		switch strings.ToUpper(string(multi[0].Value)) {
+		case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE":
+			timeout, _ := strconv.ParseFloat(string(multi[len(multi)-1].Value), 64)
+			if timeout == 0 {
+				continue
+			}
+			time.Sleep(time.Duration(timeout * float64(time.Second)))
+			resp = redis.NewArray(nil)
+		}
+		c.Encode(resp, true)
+	}
+}
+
+func TestBlockingList(x *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeBlocking(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+
+	c := newProxyConfig()
+	c.BackendRecvTimeout = timesize.Duration(time.Millisecond * 100)
+	c.SessionMaxBlockingCommands = 1
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("{l}a")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	bc := router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true)
+	assert.Must(waitFor(bc.IsConnected))
+
+	s := newHelloSession("")
+	s.config = c
+
+	assert.Must(strings.HasPrefix(string(doTxRequest(s, router, "BLPOP", "{l}a", "{m}a", "0").Value), "CROSSSLOT"))
+
+	//阻塞时间超过backend_recv_timeout
+	resp := doTxRequest(s, router, "BLMOVE", "{l}a", "{l}b", "LEFT", "RIGHT", "0.2")
+	assert.Must(resp.IsArray() && resp.Array == nil)
+
+	r := newACLRequest("BRPOP", "{l}a", "{l}b", "0")
+	r.Batch = &sync.WaitGroup{}
+	assert.MustNoError(s.handleRequest(r, router))
+	assert.Must(strings.Contains(string(doTxRequest(s, router, "BLPOP", "{l}a", "0").Value), "too many blocking commands"))
+
+	//客户端断开时取消阻塞的命令
+	s.cancelBlocking()
+	_, err = s.handleResponse(r)
+	s.blocking.Lock()
+	assert.Must(err != nil && len(s.blocking.conns) == 0)
+	s.blocking.Unlock()
+}
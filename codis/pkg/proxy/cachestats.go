@@ -0,0 +1,145 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//proxy本地缓存的命中统计，由缓存层在命中、未命中、返回过期数据、失效时调用
+type cacheCounters struct {
+	hits          atomic2.Int64
+	misses        atomic2.Int64
+	staleServes   atomic2.Int64
+	invalidations atomic2.Int64
+}
+
+type CacheStats struct {
+	Hits          int64   `json:"hits"`
+	Misses        int64   `json:"misses"`
+	StaleServes   int64   `json:"stale_serves"`
+	Invalidations int64   `json:"invalidations"`
+	HitRate       float64 `json:"hit_rate"`
+}
+
+type CachePrefixStats struct {
+	Prefix string `json:"prefix"`
+	CacheStats
+}
+
+func (c *cacheCounters) isZero() bool {
+	return c.hits.Int64() == 0 && c.misses.Int64() == 0 &&
+		c.staleServes.Int64() == 0 && c.invalidations.Int64() == 0
+}
+
+func (c *cacheCounters) reset() {
+	c.hits.Set(0)
+	c.misses.Set(0)
+	c.staleServes.Set(0)
+	c.invalidations.Set(0)
+}
+
+func (c *cacheCounters) snapshot() CacheStats {
+	o := CacheStats{
+		Hits:          c.hits.Int64(),
+		Misses:        c.misses.Int64(),
+		StaleServes:   c.staleServes.Int64(),
+		Invalidations: c.invalidations.Int64(),
+	}
+	if total := o.Hits + o.Misses; total != 0 {
+		o.HitRate = float64(o.Hits) / float64(total)
+	}
+	return o
+}
+
+const (
+	CacheHit = iota
+	CacheMiss
+	CacheStaleServe
+	CacheInvalidation
+)
+
+func (c *cacheCounters) incr(event int) {
+	switch event {
+	case CacheHit:
+		c.hits.Incr()
+	case CacheMiss:
+		c.misses.Incr()
+	case CacheStaleServe:
+		c.staleServes.Incr()
+	case CacheInvalidation:
+		c.invalidations.Incr()
+	}
+}
+
+//按key前缀统计，前缀为缓存配置中的前缀，数量有限
+var cachePrefixStats struct {
+	sync.RWMutex
+	m map[string]*cacheCounters
+}
+
+func init() {
+	cachePrefixStats.m = make(map[string]*cacheCounters)
+}
+
+func getCachePrefixCounters(prefix string) *cacheCounters {
+	cachePrefixStats.RLock()
+	c := cachePrefixStats.m[prefix]
+	cachePrefixStats.RUnlock()
+	if c != nil {
+		return c
+	}
+	cachePrefixStats.Lock()
+	if c = cachePrefixStats.m[prefix]; c == nil {
+		c = &cacheCounters{}
+		cachePrefixStats.m[prefix] = c
+	}
+	cachePrefixStats.Unlock()
+	return c
+}
+
+//记录一次缓存事件，同时计入命令、ALL以及key前缀的统计
+func incrCacheStats(opstr string, prefix string, event int) {
+	getOpStats(opstr, true).cache.incr(event)
+	getOpStats("ALL", true).cache.incr(event)
+	if prefix != "" {
+		getCachePrefixCounters(prefix).incr(event)
+	}
+}
+
+func GetCachePrefixStats() []*CachePrefixStats {
+	cachePrefixStats.RLock()
+	var all = make([]*CachePrefixStats, 0, len(cachePrefixStats.m))
+	for prefix, c := range cachePrefixStats.m {
+		all = append(all, &CachePrefixStats{Prefix: prefix, CacheStats: c.snapshot()})
+	}
+	cachePrefixStats.RUnlock()
+	sort.Sort(sliceCachePrefixStats(all))
+	return all
+}
+
+func resetCachePrefixStats() {
+	cachePrefixStats.RLock()
+	for _, c := range cachePrefixStats.m {
+		c.reset()
+	}
+	cachePrefixStats.RUnlock()
+}
+
+type sliceCachePrefixStats []*CachePrefixStats
+
+func (s sliceCachePrefixStats) Len() int {
+	return len(s)
+}
+
+func (s sliceCachePrefixStats) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceCachePrefixStats) Less(i, j int) bool {
+	return s[i].Prefix < s[j].Prefix
+}
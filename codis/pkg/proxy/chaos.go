@@ -0,0 +1,93 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+var ErrBackendBlackholed = errors.New("backend is blackholed")
+
+//混沌测试中被黑洞化的后端，到期后自动恢复
+type BackendBlackhole struct {
+	Addr   string `json:"addr"`
+	Expire int64  `json:"expire"`
+}
+
+var blackholes struct {
+	sync.RWMutex
+	m map[string]int64
+	//没有黑洞时请求路径上不需要加锁
+	n atomic2.Int64
+}
+
+func init() {
+	blackholes.m = make(map[string]int64)
+}
+
+//d为0时立即恢复
+func SetBackendBlackhole(addr string, d time.Duration) {
+	blackholes.Lock()
+	defer blackholes.Unlock()
+	if d > 0 {
+		blackholes.m[addr] = time.Now().Add(d).UnixNano()
+		log.Warnf("backend-[%s] blackholed for %s", addr, d)
+	} else {
+		delete(blackholes.m, addr)
+		log.Warnf("backend-[%s] blackhole recovered", addr)
+	}
+	pruneBackendBlackholes(time.Now().UnixNano())
+}
+
+//调用者需要持有blackholes锁
+func pruneBackendBlackholes(now int64) {
+	for addr, expire := range blackholes.m {
+		if expire <= now {
+			delete(blackholes.m, addr)
+		}
+	}
+	blackholes.n.Set(int64(len(blackholes.m)))
+}
+
+func isBackendBlackholed(addr string) bool {
+	if blackholes.n.Int64() == 0 {
+		return false
+	}
+	blackholes.RLock()
+	expire, ok := blackholes.m[addr]
+	blackholes.RUnlock()
+	return ok && time.Now().UnixNano() < expire
+}
+
+func GetBackendBlackholes() []*BackendBlackhole {
+	blackholes.Lock()
+	defer blackholes.Unlock()
+	pruneBackendBlackholes(time.Now().UnixNano())
+	var list = make([]*BackendBlackhole, 0, len(blackholes.m))
+	for addr, expire := range blackholes.m {
+		list = append(list, &BackendBlackhole{Addr: addr, Expire: expire / int64(time.Second)})
+	}
+	sort.Sort(sliceBackendBlackhole(list))
+	return list
+}
+
+type sliceBackendBlackhole []*BackendBlackhole
+
+func (s sliceBackendBlackhole) Len() int {
+	return len(s)
+}
+
+func (s sliceBackendBlackhole) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceBackendBlackhole) Less(i, j int) bool {
+	return s[i].Addr < s[j].Addr
+}
@@ -0,0 +1,32 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestBackendBlackhole(x *testing.T) {
+	const addr1 = "127.0.0.1:6379"
+	const addr2 = "127.0.0.1:6380"
+	assert.Must(!isBackendBlackholed(addr1))
+
+	SetBackendBlackhole(addr1, time.Minute)
+	SetBackendBlackhole(addr2, time.Millisecond*50)
+	assert.Must(isBackendBlackholed(addr1) && isBackendBlackholed(addr2))
+	assert.Must(len(GetBackendBlackholes()) == 2)
+
+	time.Sleep(time.Millisecond * 100)
+	assert.Must(!isBackendBlackholed(addr2))
+	list := GetBackendBlackholes()
+	assert.Must(len(list) == 1 && list[0].Addr == addr1)
+
+	SetBackendBlackhole(addr1, 0)
+	assert.Must(!isBackendBlackholed(addr1))
+	assert.Must(len(GetBackendBlackholes()) == 0)
+	assert.Must(blackholes.n.Int64() == 0)
+}
@@ -0,0 +1,213 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+const (
+	circuitClosed = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+var circuitStateNames = []string{"closed", "open", "half-open"}
+
+//按后端地址的熔断器，保存在backendCounters中，同一个地址的所有连接共用
+//连续失败backend_circuit_failures次后打开，打开期间请求直接失败；
+//超过backend_circuit_open_timeout后放行一个请求探测，成功则关闭，失败则重新打开
+type circuitBreaker struct {
+	mu    sync.Mutex
+	state atomic2.Int64
+	//打开状态下到until之前直接拒绝；探测状态下到until还没有结果时再放行一个请求
+	until    atomic2.Int64
+	failures atomic2.Int64
+
+	opens    atomic2.Int64
+	rejected atomic2.Int64
+
+	err error
+}
+
+type CircuitBreakerStatus struct {
+	Addr  string `json:"addr"`
+	State string `json:"state"`
+	//连续失败的次数
+	Failures int64 `json:"failures"`
+	Opens    int64 `json:"opens"`
+	Rejected int64 `json:"rejected"`
+	//打开状态下恢复探测的时间(unix秒)
+	Until int64 `json:"until,omitempty"`
+}
+
+func newCircuitBreakerError(addr string) error {
+	return errors.Errorf("circuit breaker of backend %s is open", addr)
+}
+
+//返回false表示熔断器打开，请求应当直接失败
+func (c *circuitBreaker) allow(timeout time.Duration) bool {
+	if c.state.Int64() == circuitClosed {
+		return true
+	}
+	now := time.Now().UnixNano()
+	if now < c.until.Int64() {
+		c.rejected.Incr()
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch {
+	case c.state.Int64() == circuitClosed:
+		return true
+	case now < c.until.Int64():
+		c.rejected.Incr()
+		return false
+	}
+	//打开超时或者上一次探测一直没有结果，放行一个请求探测后端是否恢复
+	c.state.Set(circuitHalfOpen)
+	c.until.Set(now + int64(timeout))
+	return true
+}
+
+func (c *circuitBreaker) success(addr string) {
+	if c.failures.Int64() != 0 {
+		c.failures.Set(0)
+	}
+	if c.state.Int64() != circuitHalfOpen {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.Int64() == circuitHalfOpen {
+		c.state.Set(circuitClosed)
+		c.until.Set(0)
+		log.Warnf("backend-[%s] circuit breaker closed", addr)
+	}
+}
+
+func (c *circuitBreaker) failure(addr string, max int, timeout time.Duration) {
+	n := c.failures.Incr()
+	switch c.state.Int64() {
+	case circuitClosed:
+		if max <= 0 || n < int64(max) {
+			return
+		}
+	case circuitOpen:
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.state.Int64() == circuitOpen {
+		return
+	}
+	c.state.Set(circuitOpen)
+	c.until.Set(time.Now().Add(timeout).UnixNano())
+	c.opens.Incr()
+	log.Warnf("backend-[%s] circuit breaker opened after %d consecutive failures", addr, n)
+}
+
+//手动关闭熔断器
+func (c *circuitBreaker) reset(addr string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures.Set(0)
+	if c.state.Int64() != circuitClosed {
+		c.state.Set(circuitClosed)
+		c.until.Set(0)
+		log.Warnf("backend-[%s] circuit breaker reset", addr)
+	}
+}
+
+//不是后端的问题导致的失败不计入连续失败次数
+func (bc *BackendConn) isCircuitFailure(err error) bool {
+	switch err {
+	case ErrBackendConnReset, ErrRequestIsBroken, ErrRequestDeadlineExceeded:
+		return false
+	}
+	return bc.stats == nil || err != bc.stats.circuit.err
+}
+
+func (bc *BackendConn) circuitAllow() bool {
+	if bc.stats == nil || bc.config.BackendCircuitFailures <= 0 {
+		return true
+	}
+	return bc.stats.circuit.allow(bc.config.BackendCircuitOpenTimeout.Duration())
+}
+
+func (bc *BackendConn) circuitResult(err error) {
+	if bc.stats == nil || bc.canceled.IsTrue() || bc.config.BackendCircuitFailures <= 0 {
+		return
+	}
+	switch {
+	case err == nil:
+		bc.stats.circuit.success(bc.addr)
+	case bc.isCircuitFailure(err):
+		bc.stats.circuit.failure(bc.addr, bc.config.BackendCircuitFailures, bc.config.BackendCircuitOpenTimeout.Duration())
+	}
+}
+
+//按地址排序，只返回有过失败的后端
+func GetCircuitBreakers() []*CircuitBreakerStatus {
+	backendStats.RLock()
+	var all []*CircuitBreakerStatus
+	for addr, x := range backendStats.m {
+		c := &x.circuit
+		o := &CircuitBreakerStatus{
+			Addr:     addr,
+			State:    circuitStateNames[c.state.Int64()],
+			Failures: c.failures.Int64(),
+			Opens:    c.opens.Int64(),
+			Rejected: c.rejected.Int64(),
+		}
+		if o.Failures == 0 && o.Opens == 0 && o.Rejected == 0 && o.State == "closed" {
+			continue
+		}
+		if until := c.until.Int64(); until != 0 && o.State == "open" {
+			o.Until = until / int64(time.Second)
+		}
+		all = append(all, o)
+	}
+	backendStats.RUnlock()
+	sort.Sort(sliceCircuitBreakerStatus(all))
+	return all
+}
+
+//addr为空时关闭所有熔断器
+func ResetCircuitBreaker(addr string) error {
+	backendStats.RLock()
+	defer backendStats.RUnlock()
+	if addr == "" {
+		for addr, x := range backendStats.m {
+			x.circuit.reset(addr)
+		}
+		return nil
+	}
+	x := backendStats.m[addr]
+	if x == nil {
+		return errors.Errorf("backend %s doesn't exist", addr)
+	}
+	x.circuit.reset(addr)
+	return nil
+}
+
+type sliceCircuitBreakerStatus []*CircuitBreakerStatus
+
+func (s sliceCircuitBreakerStatus) Len() int {
+	return len(s)
+}
+
+func (s sliceCircuitBreakerStatus) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceCircuitBreakerStatus) Less(i, j int) bool {
+	return s[i].Addr < s[j].Addr
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+func TestCircuitBreaker(x *testing.T) {
+	var c circuitBreaker
+	const timeout = time.Millisecond * 100
+
+	c.failure("a", 2, timeout)
+	assert.Must(c.allow(timeout) && c.state.Int64() == circuitClosed)
+	c.success("a")
+	c.failure("a", 2, timeout)
+	assert.Must(c.state.Int64() == circuitClosed)
+	c.failure("a", 2, timeout)
+	assert.Must(c.state.Int64() == circuitOpen && c.opens.Int64() == 1)
+	assert.Must(!c.allow(timeout) && c.rejected.Int64() == 1)
+
+	//超时后只放行一个探测请求，探测失败重新打开
+	time.Sleep(timeout)
+	assert.Must(c.allow(timeout) && c.state.Int64() == circuitHalfOpen)
+	assert.Must(!c.allow(timeout))
+	c.failure("a", 2, timeout)
+	assert.Must(c.state.Int64() == circuitOpen && c.opens.Int64() == 2)
+
+	//探测成功后关闭
+	time.Sleep(timeout)
+	assert.Must(c.allow(timeout))
+	c.success("a")
+	assert.Must(c.state.Int64() == circuitClosed && c.allow(timeout))
+
+	c.failure("a", 1, timeout)
+	assert.Must(c.state.Int64() == circuitOpen)
+	c.reset("a")
+	assert.Must(c.state.Int64() == circuitClosed && c.failures.Int64() == 0)
+}
+
+//healthy为false时读取请求后直接断开连接
+func newCircuitServer(healthy *atomic2.Bool) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c *redis.Conn) {
+				defer c.Close()
+				for {
+					if _, err := c.DecodeMultiBulk(); err != nil || !healthy.IsTrue() {
+						return
+					}
+					c.Encode(redis.NewString([]byte("OK")), true)
+				}
+			}(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return l
+}
+
+func doCircuitRequest(bc *BackendConn) error {
+	r := &Request{Batch: &sync.WaitGroup{}}
+	r.Multi = []*redis.Resp{redis.NewBulkBytes([]byte("PING"))}
+	bc.PushBack(r)
+	r.Batch.Wait()
+	return r.Err
+}
+
+func TestBackendCircuitBreaker(x *testing.T) {
+	var healthy atomic2.Bool
+	l := newCircuitServer(&healthy)
+	defer l.Close()
+
+	config := NewDefaultConfig()
+	config.BackendCircuitFailures = 2
+	config.BackendCircuitOpenTimeout.Set(time.Millisecond * 200)
+
+	bc := NewBackendConn(l.Addr().String(), 0, config)
+	defer bc.Close()
+
+	var cerr = bc.stats.circuit.err
+	assert.Must(waitFor(func() bool {
+		return doCircuitRequest(bc) == cerr
+	}))
+	list := GetCircuitBreakers()
+	assert.Must(len(list) == 1 && list[0].Addr == bc.addr && list[0].State == "open")
+
+	//后端恢复后探测请求成功，熔断器关闭
+	healthy.Set(true)
+	assert.Must(waitFor(func() bool {
+		return doCircuitRequest(bc) == nil
+	}))
+	assert.Must(bc.stats.circuit.state.Int64() == circuitClosed)
+
+	assert.MustNoError(ResetCircuitBreaker(bc.addr))
+	assert.Must(ResetCircuitBreaker("127.0.0.1:0") != nil)
+}
@@ -0,0 +1,234 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//按客户端IP统计，IP数量超过上限后新的IP都统计在ClientAddrOverflow中
+const ClientAddrOverflow = "*"
+
+type clientAddrCounters struct {
+	sessions atomic2.Int64
+
+	calls  atomic2.Int64
+	nsecs  atomic2.Int64
+	fails  atomic2.Int64
+	errors atomic2.Int64
+	//请求和响应的数据字节数，不含协议开销
+	bytesIn  atomic2.Int64
+	bytesOut atomic2.Int64
+	hist     latencyHistogram
+
+	qps       atomic2.Int64
+	tp99      atomic2.Int64
+	lastCalls int64
+	lastHist  []int64
+}
+
+type ClientAddrStats struct {
+	Addr         string `json:"addr"`
+	Sessions     int64  `json:"sessions"`
+	Calls        int64  `json:"calls"`
+	Usecs        int64  `json:"usecs"`
+	UsecsPerCall int64  `json:"usecs_percall"`
+	Fails        int64  `json:"fails"`
+	RedisErrors  int64  `json:"redis_errors"`
+	QPS          int64  `json:"qps"`
+	//最近一个统计周期的tp99，单位为ms，取所在延迟区间的上界
+	TP99     int64 `json:"tp99"`
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+var clientAddrStats struct {
+	sync.RWMutex
+	m map[string]*clientAddrCounters
+
+	//0表示关闭
+	max atomic2.Int64
+}
+
+func init() {
+	clientAddrStats.m = make(map[string]*clientAddrCounters)
+}
+
+func ClientAddrStatsSetMax(n int) {
+	clientAddrStats.max.Set(int64(n))
+}
+
+func clientHost(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+//session开始时获取，结束时调用releaseClientAddrCounters，关闭统计时返回nil
+func acquireClientAddrCounters(addr string) *clientAddrCounters {
+	max := clientAddrStats.max.Int64()
+	if max <= 0 {
+		return nil
+	}
+	host := clientHost(addr)
+
+	clientAddrStats.Lock()
+	defer clientAddrStats.Unlock()
+	c := clientAddrStats.m[host]
+	if c == nil {
+		if int64(len(clientAddrStats.m)) >= max {
+			host = ClientAddrOverflow
+			c = clientAddrStats.m[host]
+		}
+		if c == nil {
+			c = &clientAddrCounters{}
+			clientAddrStats.m[host] = c
+		}
+	}
+	c.sessions.Incr()
+	return c
+}
+
+func releaseClientAddrCounters(c *clientAddrCounters) {
+	if c != nil {
+		c.sessions.Decr()
+	}
+}
+
+//responseTime单位为ns
+func (c *clientAddrCounters) incr(r *Request, resp *redis.Resp, responseTime int64) {
+	c.calls.Incr()
+	c.nsecs.Add(responseTime)
+	c.hist.incr(responseTime)
+	if resp != nil && resp.IsError() {
+		c.errors.Incr()
+	}
+	var n int64
+	for _, x := range r.Multi {
+		n += int64(len(x.Value))
+	}
+	c.bytesIn.Add(n)
+	c.bytesOut.Add(respPayloadSize(resp))
+}
+
+//由统计协程定期调用，elapsed为距离上次调用的时间
+func refreshClientAddrStats(elapsed time.Duration) {
+	clientAddrStats.RLock()
+	for _, c := range clientAddrStats.m {
+		calls := c.calls.Int64()
+		delta := calls - c.lastCalls
+		c.lastCalls = calls
+		normalized := math.Max(0, float64(delta)) / float64(elapsed) * float64(time.Second)
+		c.qps.Set(int64(normalized + 0.5))
+
+		hist := c.hist.snapshot()
+		c.tp99.Set(histPercentile(hist, c.lastHist, 0.99))
+		c.lastHist = hist
+	}
+	clientAddrStats.RUnlock()
+}
+
+//根据两次直方图快照的差值计算分位数，超过最大区间时返回最大区间的上界
+func histPercentile(hist, last []int64, percent float64) int64 {
+	var delta = make([]int64, len(hist))
+	var total int64
+	for i := range hist {
+		delta[i] = hist[i]
+		if i < len(last) {
+			delta[i] -= last[i]
+		}
+		if delta[i] < 0 {
+			delta[i] = 0
+		}
+		total += delta[i]
+	}
+	if total == 0 {
+		return 0
+	}
+	target := int64(math.Ceil(float64(total) * percent))
+	var count int64
+	for i := range delta {
+		count += delta[i]
+		if count >= target && i < len(HistBucketMark) {
+			return HistBucketMark[i]
+		}
+	}
+	return HistBucketMark[len(HistBucketMark)-1]
+}
+
+//按QPS从大到小排序，top大于0时只返回前top个
+func GetClientAddrStats(top int) []*ClientAddrStats {
+	clientAddrStats.RLock()
+	var all = make([]*ClientAddrStats, 0, len(clientAddrStats.m))
+	for addr, c := range clientAddrStats.m {
+		o := &ClientAddrStats{
+			Addr:        addr,
+			Sessions:    c.sessions.Int64(),
+			Calls:       c.calls.Int64(),
+			Usecs:       c.nsecs.Int64() / 1e3,
+			Fails:       c.fails.Int64(),
+			RedisErrors: c.errors.Int64(),
+			QPS:         c.qps.Int64(),
+			TP99:        c.tp99.Int64(),
+			BytesIn:     c.bytesIn.Int64(),
+			BytesOut:    c.bytesOut.Int64(),
+		}
+		if o.Calls != 0 {
+			o.UsecsPerCall = o.Usecs / o.Calls
+		}
+		all = append(all, o)
+	}
+	clientAddrStats.RUnlock()
+	sort.Sort(sliceClientAddrStats(all))
+	if top > 0 && len(all) > top {
+		all = all[:top]
+	}
+	return all
+}
+
+//没有session的IP直接删除，其余的清零
+func resetClientAddrStats() {
+	clientAddrStats.Lock()
+	defer clientAddrStats.Unlock()
+	for addr, c := range clientAddrStats.m {
+		if c.sessions.Int64() == 0 {
+			delete(clientAddrStats.m, addr)
+			continue
+		}
+		c.calls.Set(0)
+		c.nsecs.Set(0)
+		c.fails.Set(0)
+		c.errors.Set(0)
+		c.bytesIn.Set(0)
+		c.bytesOut.Set(0)
+	}
+}
+
+type sliceClientAddrStats []*ClientAddrStats
+
+func (s sliceClientAddrStats) Len() int {
+	return len(s)
+}
+
+func (s sliceClientAddrStats) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceClientAddrStats) Less(i, j int) bool {
+	if s[i].QPS != s[j].QPS {
+		return s[i].QPS > s[j].QPS
+	}
+	if s[i].Calls != s[j].Calls {
+		return s[i].Calls > s[j].Calls
+	}
+	return s[i].Addr < s[j].Addr
+}
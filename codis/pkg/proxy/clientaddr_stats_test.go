@@ -0,0 +1,71 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestClientAddrStats(x *testing.T) {
+	ClientAddrStatsSetMax(0)
+	assert.Must(acquireClientAddrCounters("10.0.0.1:1234") == nil)
+
+	ClientAddrStatsSetMax(2)
+	defer ClientAddrStatsSetMax(0)
+	defer resetClientAddrStats()
+
+	c := acquireClientAddrCounters("10.0.0.1:1234")
+	assert.Must(acquireClientAddrCounters("10.0.0.1:5678") == c)
+	d := acquireClientAddrCounters("10.0.0.2:1234")
+	assert.Must(d != c)
+	o := acquireClientAddrCounters("10.0.0.3:1234")
+	assert.Must(o != c && o != d)
+	assert.Must(acquireClientAddrCounters("10.0.0.4:1234") == o)
+
+	r := &Request{Multi: []*redis.Resp{
+		redis.NewBulkBytes([]byte("GET")), redis.NewBulkBytes([]byte("key")),
+	}}
+	for i := 0; i < 99; i++ {
+		c.incr(r, redis.NewBulkBytes([]byte("value")), int64(time.Microsecond*100))
+	}
+	c.incr(r, redis.NewErrorf("ERR"), int64(time.Millisecond*40))
+	d.incr(r, nil, int64(time.Millisecond))
+	refreshClientAddrStats(time.Second)
+
+	list := GetClientAddrStats(0)
+	assert.Must(len(list) == 3)
+	s := list[0]
+	assert.Must(s.Addr == "10.0.0.1" && s.Sessions == 2)
+	assert.Must(s.Calls == 100 && s.QPS == 100 && s.RedisErrors == 1)
+	assert.Must(s.BytesIn == 600 && s.BytesOut == 99*5+3)
+	assert.Must(s.TP99 == 1)
+	assert.Must(list[1].Addr == "10.0.0.2" && list[2].Addr == ClientAddrOverflow)
+	assert.Must(len(GetClientAddrStats(1)) == 1)
+
+	//上个周期之后没有请求
+	refreshClientAddrStats(time.Second)
+	assert.Must(GetClientAddrStats(1)[0].TP99 == 0)
+
+	releaseClientAddrCounters(d)
+	resetClientAddrStats()
+	list = GetClientAddrStats(0)
+	assert.Must(len(list) == 2 && list[0].Calls == 0)
+}
+
+func TestHistPercentile(x *testing.T) {
+	hist := make([]int64, HistBucketNum)
+	assert.Must(histPercentile(hist, nil, 0.99) == 0)
+	hist[0], hist[3] = 90, 10
+	assert.Must(histPercentile(hist, nil, 0.9) == HistBucketMark[0])
+	assert.Must(histPercentile(hist, nil, 0.99) == HistBucketMark[3])
+	last := make([]int64, HistBucketNum)
+	last[3] = 10
+	assert.Must(histPercentile(hist, last, 0.99) == HistBucketMark[0])
+	hist[HistBucketNum-1] = 100
+	assert.Must(histPercentile(hist, last, 0.99) == HistBucketMark[len(HistBucketMark)-1])
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//按CLIENT SETNAME设置的名字统计，没有设置名字的session统计在""中
+const (
+	maxClientNames     = 1024
+	ClientNameOverflow = "*"
+)
+
+type clientCounters struct {
+	calls  atomic2.Int64
+	nsecs  atomic2.Int64
+	fails  atomic2.Int64
+	errors atomic2.Int64
+	slow   atomic2.Int64
+
+	qps       atomic2.Int64
+	lastCalls int64
+}
+
+type ClientStats struct {
+	Name         string `json:"name"`
+	Calls        int64  `json:"calls"`
+	Usecs        int64  `json:"usecs"`
+	UsecsPerCall int64  `json:"usecs_percall"`
+	Fails        int64  `json:"fails"`
+	RedisErrors  int64  `json:"redis_errors"`
+	Slow         int64  `json:"slow"`
+	QPS          int64  `json:"qps"`
+}
+
+var clientStats struct {
+	sync.RWMutex
+	m map[string]*clientCounters
+}
+
+func init() {
+	clientStats.m = make(map[string]*clientCounters)
+}
+
+//名字的数量超过maxClientNames后，新的名字都统计在ClientNameOverflow中
+func getClientCounters(name string) *clientCounters {
+	clientStats.RLock()
+	c := clientStats.m[name]
+	clientStats.RUnlock()
+	if c != nil {
+		return c
+	}
+	clientStats.Lock()
+	defer clientStats.Unlock()
+	if c = clientStats.m[name]; c != nil {
+		return c
+	}
+	if len(clientStats.m) >= maxClientNames {
+		name = ClientNameOverflow
+		if c = clientStats.m[name]; c != nil {
+			return c
+		}
+	}
+	c = &clientCounters{}
+	clientStats.m[name] = c
+	return c
+}
+
+//responseTime单位为ns，slowerThan单位为us，小于0时不统计慢请求
+func (c *clientCounters) incr(responseTime int64, redisError bool, slowerThan int64) {
+	c.calls.Incr()
+	c.nsecs.Add(responseTime)
+	if redisError {
+		c.errors.Incr()
+	}
+	if slowerThan >= 0 && responseTime/1e3 >= slowerThan {
+		c.slow.Incr()
+	}
+}
+
+//由统计协程定期调用，elapsed为距离上次调用的时间
+func refreshClientStats(elapsed time.Duration) {
+	clientStats.RLock()
+	for _, c := range clientStats.m {
+		calls := c.calls.Int64()
+		delta := calls - c.lastCalls
+		c.lastCalls = calls
+		normalized := math.Max(0, float64(delta)) / float64(elapsed) * float64(time.Second)
+		c.qps.Set(int64(normalized + 0.5))
+	}
+	clientStats.RUnlock()
+}
+
+func GetClientStats() []*ClientStats {
+	clientStats.RLock()
+	var all = make([]*ClientStats, 0, len(clientStats.m))
+	for name, c := range clientStats.m {
+		o := &ClientStats{
+			Name:        name,
+			Calls:       c.calls.Int64(),
+			Usecs:       c.nsecs.Int64() / 1e3,
+			Fails:       c.fails.Int64(),
+			RedisErrors: c.errors.Int64(),
+			Slow:        c.slow.Int64(),
+			QPS:         c.qps.Int64(),
+		}
+		if o.Calls != 0 {
+			o.UsecsPerCall = o.Usecs / o.Calls
+		}
+		all = append(all, o)
+	}
+	clientStats.RUnlock()
+	sort.Sort(sliceClientStats(all))
+	return all
+}
+
+func resetClientStats() {
+	clientStats.RLock()
+	for _, c := range clientStats.m {
+		c.calls.Set(0)
+		c.nsecs.Set(0)
+		c.fails.Set(0)
+		c.errors.Set(0)
+		c.slow.Set(0)
+	}
+	clientStats.RUnlock()
+}
+
+type sliceClientStats []*ClientStats
+
+func (s sliceClientStats) Len() int {
+	return len(s)
+}
+
+func (s sliceClientStats) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceClientStats) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
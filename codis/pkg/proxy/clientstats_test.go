@@ -0,0 +1,47 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestClientStats(x *testing.T) {
+	c := getClientCounters("order-service")
+	assert.Must(getClientCounters("order-service") == c)
+
+	c.incr(int64(time.Millisecond*2), false, 1000)
+	c.incr(int64(time.Microsecond*100), true, 1000)
+	c.incr(int64(time.Microsecond*100), false, -1)
+	refreshClientStats(time.Second)
+
+	var found *ClientStats
+	for _, o := range GetClientStats() {
+		if o.Name == "order-service" {
+			found = o
+		}
+	}
+	assert.Must(found != nil)
+	assert.Must(found.Calls == 3 && found.RedisErrors == 1 && found.Slow == 1)
+	assert.Must(found.Usecs == 2200 && found.QPS == 3)
+
+	for i := 0; i < maxClientNames; i++ {
+		getClientCounters(fmt.Sprintf("service-%d", i))
+	}
+	assert.Must(getClientCounters("one-more") == getClientCounters(ClientNameOverflow))
+
+	resetClientStats()
+	assert.Must(c.calls.Int64() == 0)
+}
+
+func TestIsValidClientName(x *testing.T) {
+	assert.Must(isValidClientName("order-service"))
+	assert.Must(isValidClientName(""))
+	assert.Must(!isValidClientName("order service"))
+	assert.Must(!isValidClientName("order\nservice"))
+}
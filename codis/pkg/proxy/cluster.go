@@ -0,0 +1,198 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+)
+
+//redis cluster的slot数
+const clusterSlotNum = 16384
+
+func parseClusterNodes(value string) []string {
+	var nodes []string
+	for _, addr := range strings.Split(value, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			nodes = append(nodes, addr)
+		}
+	}
+	return nodes
+}
+
+func clusterNodeIndex(nodes []string, addr string) int {
+	for i := range nodes {
+		if nodes[i] == addr {
+			return i
+		}
+	}
+	return -1
+}
+
+//与redis cluster相同，{}中间为空时使用整个key，与slot_hash无关
+func clusterKeySlot(key []byte) int {
+	if beg := bytes.IndexByte(key, '{'); beg >= 0 {
+		if end := bytes.IndexByte(key[beg+1:], '}'); end > 0 {
+			key = key[beg+1 : beg+1+end]
+		}
+	}
+	return int(crc16(key) % clusterSlotNum)
+}
+
+//第i个节点负责的slot范围
+func clusterNodeSlots(i, n int) (int, int) {
+	return i * clusterSlotNum / n, (i+1)*clusterSlotNum/n - 1
+}
+
+func clusterNodeId(addr string) string {
+	b := sha1.Sum([]byte(addr))
+	return hex.EncodeToString(b[:])
+}
+
+//返回给客户端的所有节点，没有配置cluster_nodes时只有proxy自己
+func (s *Session) clusterNodes() ([]string, int) {
+	if nodes := parseClusterNodes(s.config.ClusterNodes); len(nodes) != 0 {
+		return nodes, clusterNodeIndex(nodes, s.config.ClusterAnnounceAddr)
+	}
+	if addr := s.config.ClusterAnnounceAddr; addr != "" {
+		return []string{addr}, 0
+	}
+	return []string{s.Conn.LocalAddr()}, 0
+}
+
+//key不属于当前proxy时返回MOVED，只检查第一个key，和cluster客户端选择节点的方式一致
+func (s *Session) checkClusterMoved(r *Request) *redis.Resp {
+	if !s.config.ClusterMode || s.config.ClusterNodes == "" || len(r.Multi) < 2 {
+		return nil
+	}
+	switch r.OpStr {
+	case "PUBLISH", "ECHO", "ASKING":
+		return nil
+	case "EVAL", "EVALSHA":
+		if len(r.Multi) < 4 || string(r.Multi[2].Value) == "0" {
+			return nil
+		}
+	default:
+		if trackingLocalCommands[r.OpStr] {
+			return nil
+		}
+	}
+	nodes, self := s.clusterNodes()
+	slot := clusterKeySlot(getHashKey(r.Multi, r.OpStr))
+	owner := slot * len(nodes) / clusterSlotNum
+	if owner == self {
+		return nil
+	}
+	return redis.NewErrorf("MOVED %d %s", slot, nodes[owner])
+}
+
+func (s *Session) handleAsking(r *Request) error {
+	if !s.config.ClusterMode {
+		r.Resp = redis.NewErrorf("ERR This instance has cluster support disabled")
+		return nil
+	}
+	r.Resp = RespOK
+	return nil
+}
+
+func (s *Session) handleClusterMode(r *Request) error {
+	var nodes, self = s.clusterNodes()
+	var subCmd = strings.ToUpper(string(r.Multi[1].Value))
+	switch subCmd {
+	case "SLOTS":
+		var array []*redis.Resp
+		for i, addr := range nodes {
+			beg, end := clusterNodeSlots(i, len(nodes))
+			array = append(array, redis.NewArray([]*redis.Resp{
+				redis.NewInt([]byte(strconv.Itoa(beg))),
+				redis.NewInt([]byte(strconv.Itoa(end))),
+				clusterNodeResp(addr),
+			}))
+		}
+		r.Resp = redis.NewArray(array)
+	case "SHARDS":
+		var array []*redis.Resp
+		for i, addr := range nodes {
+			beg, end := clusterNodeSlots(i, len(nodes))
+			host, port := splitClusterAddr(addr)
+			node := redis.NewArray([]*redis.Resp{
+				redis.NewBulkBytes([]byte("id")), redis.NewBulkBytes([]byte(clusterNodeId(addr))),
+				redis.NewBulkBytes([]byte("port")), redis.NewInt([]byte(port)),
+				redis.NewBulkBytes([]byte("ip")), redis.NewBulkBytes([]byte(host)),
+				redis.NewBulkBytes([]byte("endpoint")), redis.NewBulkBytes([]byte(host)),
+				redis.NewBulkBytes([]byte("role")), redis.NewBulkBytes([]byte("master")),
+				redis.NewBulkBytes([]byte("replication-offset")), redis.NewInt([]byte("0")),
+				redis.NewBulkBytes([]byte("health")), redis.NewBulkBytes([]byte("online")),
+			})
+			array = append(array, redis.NewArray([]*redis.Resp{
+				redis.NewBulkBytes([]byte("slots")), redis.NewArray([]*redis.Resp{
+					redis.NewInt([]byte(strconv.Itoa(beg))), redis.NewInt([]byte(strconv.Itoa(end))),
+				}),
+				redis.NewBulkBytes([]byte("nodes")), redis.NewArray([]*redis.Resp{node}),
+			}))
+		}
+		r.Resp = redis.NewArray(array)
+	case "NODES":
+		var b bytes.Buffer
+		for i, addr := range nodes {
+			beg, end := clusterNodeSlots(i, len(nodes))
+			flags := "master"
+			if i == self {
+				flags = "myself,master"
+			}
+			_, port := splitClusterAddr(addr)
+			fmt.Fprintf(&b, "%s %s@%s %s - 0 0 %d connected %d-%d\n",
+				clusterNodeId(addr), addr, port, flags, i+1, beg, end)
+		}
+		r.Resp = redis.NewBulkBytes(b.Bytes())
+	case "INFO":
+		var b bytes.Buffer
+		fmt.Fprintf(&b, "cluster_enabled:1\r\n")
+		fmt.Fprintf(&b, "cluster_state:ok\r\n")
+		fmt.Fprintf(&b, "cluster_slots_assigned:%d\r\n", clusterSlotNum)
+		fmt.Fprintf(&b, "cluster_slots_ok:%d\r\n", clusterSlotNum)
+		fmt.Fprintf(&b, "cluster_slots_pfail:0\r\n")
+		fmt.Fprintf(&b, "cluster_slots_fail:0\r\n")
+		fmt.Fprintf(&b, "cluster_known_nodes:%d\r\n", len(nodes))
+		fmt.Fprintf(&b, "cluster_size:%d\r\n", len(nodes))
+		fmt.Fprintf(&b, "cluster_current_epoch:%d\r\n", len(nodes))
+		fmt.Fprintf(&b, "cluster_my_epoch:%d\r\n", self+1)
+		r.Resp = redis.NewBulkBytes(b.Bytes())
+	case "MYID":
+		r.Resp = redis.NewBulkBytes([]byte(clusterNodeId(nodes[self])))
+	case "KEYSLOT":
+		if len(r.Multi) != 3 {
+			r.Resp = redis.NewErrorf("ERR CLUSTER KEYSLOT parameters")
+			return nil
+		}
+		r.Resp = redis.NewInt([]byte(strconv.Itoa(clusterKeySlot(r.Multi[2].Value))))
+	default:
+		r.Resp = redis.NewErrorf("ERR unknown cluster subcommand '%s', only support slots, shards, nodes, info, myid, keyslot now", subCmd)
+	}
+	return nil
+}
+
+func splitClusterAddr(addr string) (string, string) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, "0"
+	}
+	return host, port
+}
+
+func clusterNodeResp(addr string) *redis.Resp {
+	host, port := splitClusterAddr(addr)
+	return redis.NewArray([]*redis.Resp{
+		redis.NewBulkBytes([]byte(host)),
+		redis.NewInt([]byte(port)),
+		redis.NewBulkBytes([]byte(clusterNodeId(addr))),
+	})
+}
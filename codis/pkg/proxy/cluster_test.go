@@ -0,0 +1,59 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestClusterMode(x *testing.T) {
+	server := newFakeAddrServer()
+	defer server.Close()
+
+	c := newProxyConfig()
+	c.ClusterMode = true
+	c.ClusterAnnounceAddr = "127.0.0.1:7001"
+	router := NewRouter(c)
+	defer router.Close()
+	for i := 0; i < MaxSlotNum; i++ {
+		assert.MustNoError(router.FillSlot(&models.Slot{Id: i, BackendAddr: server.Addr().String()}))
+	}
+	router.Start()
+	for _, slot := range router.allSlots() {
+		assert.Must(waitFor(slot.backend.bc.BackendConn(0, uint(slot.id), false, true).IsConnected))
+	}
+
+	s := newHelloSession("")
+	s.config = c
+
+	//单个proxy拥有所有slot
+	resp := doTxRequest(s, router, "CLUSTER", "SLOTS")
+	assert.Must(len(resp.Array) == 1 && string(resp.Array[0].Array[1].Value) == "16383")
+	assert.Must(string(resp.Array[0].Array[2].Array[0].Value) == "127.0.0.1")
+	assert.Must(string(doTxRequest(s, router, "CLUSTER", "KEYSLOT", "foo").Value) == "12182")
+	assert.Must(string(doTxRequest(s, router, "CLUSTER", "KEYSLOT", "{user1000}.following").Value) == "3443")
+	assert.Must(doTxRequest(s, router, "ASKING").Value != nil)
+	assert.Must(doReadRequest(s, router, "GET", "foo") == server.Addr().String())
+
+	//两个proxy平分slot，其他proxy的key返回MOVED
+	c.ClusterNodes = "127.0.0.1:7001, 127.0.0.1:7002"
+	assert.MustNoError(c.Validate())
+	resp = doTxRequest(s, router, "CLUSTER", "SLOTS")
+	assert.Must(len(resp.Array) == 2 && string(resp.Array[1].Array[0].Value) == "8192")
+	resp = doTxRequest(s, router, "CLUSTER", "NODES")
+	assert.Must(strings.Contains(string(resp.Value), "127.0.0.1:7001@7001 myself,master - 0 0 1 connected 0-8191\n"))
+	assert.Must(string(doTxRequest(s, router, "CLUSTER", "MYID").Value) == clusterNodeId("127.0.0.1:7001"))
+	assert.Must(len(doTxRequest(s, router, "CLUSTER", "SHARDS").Array) == 2)
+
+	assert.Must(string(doTxRequest(s, router, "GET", "foo").Value) == "MOVED 12182 127.0.0.1:7002")
+	assert.Must(doReadRequest(s, router, "GET", "bar") == server.Addr().String())
+	assert.Must(string(doTxRequest(s, router, "PING", "foo").Value) != "MOVED 12182 127.0.0.1:7002")
+
+	c.ClusterAnnounceAddr = "127.0.0.1:7003"
+	assert.Must(c.Validate() != nil)
+}
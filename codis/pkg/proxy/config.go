@@ -5,12 +5,16 @@ package proxy
 
 import (
 	"bytes"
+	"crypto/tls"
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils"
 	"github.com/CodisLabs/codis/pkg/utils/bytesize"
 	"github.com/CodisLabs/codis/pkg/utils/errors"
 	"github.com/CodisLabs/codis/pkg/utils/log"
+	utilredis "github.com/CodisLabs/codis/pkg/utils/redis"
 	"github.com/CodisLabs/codis/pkg/utils/timesize"
 )
 
@@ -22,6 +26,10 @@ const DefaultConfig = `
 ##################################################
 
 
+# Values can reference environment variables as ${NAME} or ${NAME:-default}, and
+# a line like: include "common.toml" inlines another file (relative to this one).
+# Files using them are never rewritten by config set commands.
+
 # Set runtime.GOMAXPROCS to N, default is 1.
 ncpu = 1
 
@@ -48,15 +56,46 @@ product_auth = ""
 #      to issue AUTH <PASSWORD> before processing any other commands.
 session_auth = ""
 
+# Set users of client session, clients issue AUTH <USERNAME> <PASSWORD> to authenticate as a user.
+# The file is in json: {"users": [{"name": "app", "password": "xxx", "commands": ["+@all", "-flushall"], "key_patterns": ["app:*"]}]},
+# users can also be managed by codis-dashboard, which take precedence over users with the same name in the file.
+session_acl_file = ""
+
+# Set the function mapping keys to slots, can be "crc32" or "crc16".
+#   1. "crc32" is the codis default, codis-server migrates slots with the same function.
+#   2. "crc16" is the function of redis cluster, keys in one cluster slot always map to the same codis slot,
+#      slots can't be migrated by codis-server then.
+#   3. hash_tag is the two delimiters of hash tags, empty to hash the whole key.
+# Must be the same on all proxies and codis-dashboard.
+slot_hash = "crc32"
+hash_tag = "{}"
+
 # Set bind address for admin(rpc), tcp only.
 admin_addr = "0.0.0.0:11080"
 
-# Set bind address for proxy, proto_type can be "tcp", "tcp4", "tcp6", "unix" or "unixpacket".
+# Set bind address for proxy, proto_type can be "tcp", "tcp4", "tcp6", "unix", "unixpacket" or "tls".
 proto_type = "tcp4"
 proxy_addr = "0.0.0.0:19000"
 
+# Set TLS for clients when proto_type = "tls", proxy listens on tcp.
+#   1. proxy_tls_cert_file & proxy_tls_key_file are PEM encoded certificate & private key of proxy.
+#   2. proxy_tls_client_auth can be "none", "request" or "verify", clients are required to present
+#      certificates signed by proxy_tls_client_ca_file if it's "verify".
+proxy_tls_cert_file = ""
+proxy_tls_key_file = ""
+proxy_tls_client_auth = "none"
+proxy_tls_client_ca_file = ""
+
+# Emulate redis cluster for cluster clients, CLUSTER SLOTS/SHARDS/NODES return proxies instead of backends.
+#   1. cluster_announce_addr is the ip:port of this proxy returned to clients, empty to use the address clients connect to.
+#   2. cluster_nodes is the announce addresses of all proxies sharing the 16384 slots evenly (including this one),
+#      keys of slots owned by other proxies get MOVED, empty to own all the slots.
+cluster_mode = false
+cluster_announce_addr = ""
+cluster_nodes = ""
+
 # Set jodis address & session timeout
-#   1. jodis_name is short for jodis_coordinator_name, only accept "zookeeper" & "etcd".
+#   1. jodis_name is short for jodis_coordinator_name, only accept "zookeeper", "etcd" & "etcdv3".
 #   2. jodis_addr is short for jodis_coordinator_addr
 #   3. jodis_auth is short for jodis_coordinator_auth, for zookeeper/etcd, "user:password" is accepted.
 #   4. proxy will be registered as node:
@@ -73,12 +112,38 @@ jodis_timeout = "20s"
 jodis_compatible = true
 jodis_proxy_subdir = ""
 
+# Set auth & tls for connecting to redis sentinels.
+#   1. sentinel_username is only required by ACL users (redis 6.0+), leave empty to use AUTH <password>.
+#   2. sentinel_tls_ca_file is the PEM encoded CA to verify sentinels, leave empty to use system roots.
+sentinel_username = ""
+sentinel_password = ""
+sentinel_tls = false
+sentinel_tls_skip_verify = false
+sentinel_tls_ca_file = ""
+
+# Set tls for connecting to redis servers.
+#   1. backend_tls_ca_file is the PEM encoded CA to verify servers, leave empty to use system roots.
+#   2. backend_tls_cert_file & backend_tls_key_file are required if servers verify client certificates.
+#   3. backend_tls_server_name is the name to verify, leave empty to use host of server address.
+backend_tls = false
+backend_tls_skip_verify = false
+backend_tls_ca_file = ""
+backend_tls_cert_file = ""
+backend_tls_key_file = ""
+backend_tls_server_name = ""
+
 # Set datacenter of proxy.
 proxy_datacenter = ""
 
 # Set max number of alive sessions.
 proxy_max_clients = 50000
 
+# Set max qps and max in-flight requests of the whole proxy, when exceeded proxy
+# stops reading new requests from clients until there is room again, instead of
+# returning errors. (0 to disable)
+proxy_max_qps = 0
+proxy_max_inflight = 0
+
 # Set max offheap memory size. (0 to disable)
 proxy_max_offheap_size = "1024mb"
 
@@ -102,9 +167,26 @@ backend_send_timeout = "3s"
 # Set backend pipeline buffer size.
 backend_max_pipeline = 20480
 
-# Set backend never read replica groups, default is true
+# Set backend never read replica groups by default, clients can still send READONLY
+# to read from replicas in the session, default is true
 backend_primary_only = true
 
+# Set load balancing of reads on replicas, used when backend_primary_only is false
+# or the client sends READONLY. Options: "locality" (prefer replicas in the same
+# datacenter), "round-robin", "latency" (weighted by recent latency of each replica).
+backend_replica_balance = "locality"
+
+# Replicas whose replication offset lags behind the master by more than this many
+# bytes, or whose link to the master is down, are skipped by replica reads until
+# they catch up. Lags are polled every backend_ping_period. (0 to disable)
+replica_max_lag = 0
+
+# Send a hedged duplicate of a read command to a replica if the master has not responded within
+# this percentile of the recent latencies of the command (e.g. 95 for p95), and return whichever
+# response arrives first. The hedge delay is at least backend_hedge_min_delay. (0 to disable)
+backend_hedge_percentile = 0
+backend_hedge_min_delay = "1ms"
+
 # Set backend parallel connections per server
 backend_primary_parallel = 8
 backend_primary_quick = 0
@@ -117,6 +199,29 @@ backend_keepalive_period = "75s"
 # Set number of databases of backend.
 backend_number_databases = 1
 
+# Use RESP3 (HELLO 3) on backend connections, falls back to RESP2 if the server rejects it.
+# Replies are converted to RESP2 for clients that did not send HELLO 3.
+backend_resp3 = false
+
+# Set how long a removed backend waits for in-flight requests before closing. (0 to close immediately)
+backend_drain_timeout = "5s"
+
+# Requests waiting for backend response longer than this are considered stuck: goroutine stacks are
+# dumped into log and the backend connection is reset to fail them. (0 to disable)
+backend_stuck_timeout = "60s"
+
+# Mark backend pools with an alarm in stats api and log a warning when inflight plus queued requests of a
+# backend reach backend_pool_alarm_inflight, or requests wait in queue longer than backend_pool_alarm_wait
+# on average within a second. (0 to disable)
+backend_pool_alarm_inflight = 0
+backend_pool_alarm_wait = "0ms"
+
+# Open the circuit breaker of a backend after this many consecutive failures (connection errors or
+# timeouts), requests to the backend fail fast until backend_circuit_open_timeout has passed, then
+# one request is let through to probe whether the backend has recovered. (0 to disable)
+backend_circuit_failures = 0
+backend_circuit_open_timeout = "5s"
+
 # If there is no request from client for a long time, the connection will be closed. (0 to disable)
 # Set session recv buffer size & timeout.
 session_recv_bufsize = "128kb"
@@ -136,6 +241,24 @@ session_keepalive_period = "75s"
 # Set session to be sensitive to failures. Default is false, instead of closing socket, proxy will send an error response to client.
 session_break_on_failure = false
 
+# Split MGET/MSET/DEL/EXISTS/UNLINK/TOUCH with more keys than this into sequential batches of this size,
+# the next batch is sent after the previous one finished. (0 to disable)
+session_max_batch_keys = 0
+
+# Split MGET/MSET/DEL/EXISTS/UNLINK/TOUCH into one request per key so that keys in different slots
+# can be used together, replies are merged in order. When disabled all keys must be in the same slot,
+# otherwise a CROSSSLOT error is returned.
+session_split_multi_keys = true
+
+# Set max number of in-flight blocking commands (BLPOP/BRPOP/BLMOVE/XREAD BLOCK ...) of each session,
+# every blocking command uses a dedicated backend connection. (0 means unlimited)
+session_max_blocking_commands = 16
+
+# Set max number of keys remembered for CLIENT TRACKING (default mode) of all sessions, when exceeded
+# some keys are evicted and invalidated. (0 means unlimited)
+# Invalidations are received from backends by CLIENT TRACKING BCAST.
+session_tracking_max_keys = 1000000
+
 # Slowlog-log-slower-than(us), from receive command to send response, 0 is allways print slow log
 slowlog_log_slower_than = 100000
 # set the number of slowlog in memory, max len is 10000000. (0 to disable)
@@ -147,6 +270,71 @@ slow_cmd_list = ""
 # auto set slow flag for command, when command timeout
 auto_set_slow_flag = false
 
+# SLOs per command, format is op:latency:objective and separated by comma, e.g. "GET:10ms:99,ALL:50ms:99.9"
+# burn rates over 1m/5m/30m/1h windows are exposed via admin api /api/proxy/slo
+slo_rules = ""
+
+# Require replica acks for writes on key prefixes, format is prefix:replicas:timeout and separated by comma,
+# e.g. "order:1:100ms". After the master replied a write on a matched key (the first key of the request),
+# proxy sends WAIT to the master and replies NOREPLICAS if fewer replicas acknowledged the write in time.
+wait_replicas_rules = ""
+
+# Commands with qps below this threshold are aggregated into an "OTHER" entry in stats api
+# and metrics exporters, append "?exact=1" to the stats api for exact per-command stats. (0 to disable)
+metrics_other_qps_threshold = 0
+
+# Half-life of the exponentially-decayed latency histogram behind admin api /api/proxy/tp/:opstr/:quantile,
+# which estimates arbitrary quantiles with a relative error of about 3%.
+metrics_quantile_halflife = "60s"
+
+# Sample one of every hotkey_sample_rate requests to track hot keys, the top hotkey_top_n keys by calls and by
+# total latency of every hotkey_interval are exposed via admin api /api/proxy/hotkeys. (0 to disable)
+hotkey_sample_rate = 0
+hotkey_top_n = 20
+hotkey_interval = "10s"
+
+# Cache GET and MGET replies of keys with the prefixes in hotcache_prefixes (separated by comma, "*" for all
+# keys) in proxy memory for hotcache_ttl to absorb read storms on hot keys. Writes through this proxy invalidate
+# the cached keys, writes through other proxies are seen after hotcache_ttl. At most hotcache_max_keys keys are
+# cached, evicted by hotcache_policy, "lru" or "lfu". Hits and misses are counted in the cache of ops stats.
+# (empty to disable)
+hotcache_prefixes = ""
+hotcache_ttl = "1s"
+hotcache_max_keys = 10000
+hotcache_policy = "lru"
+
+# Responses of at least bigkey_threshold bytes are aggregated by key pattern (digits in keys are replaced with "*"),
+# at most bigkey_max_patterns patterns are kept and exposed via admin api /api/proxy/bigkeys. (0 to disable)
+bigkey_threshold = "0"
+bigkey_max_patterns = 1000
+
+# Track calls, qps, tp99 and bytes in/out of at most client_addr_stats_max client ips,
+# exposed via admin api /api/proxy/clientaddrs. (0 to disable)
+client_addr_stats_max = 1024
+
+# Cache at most script_cache_max lua scripts loaded by EVAL or SCRIPT LOAD, cached scripts are loaded
+# into every backend so that EVALSHA keeps working after failover or scaling. (0 to disable)
+script_cache_max = 10000
+
+# Checkpoint total and per-command counters into stats_snapshot_file every stats_snapshot_interval and when
+# the proxy exits, and restore them at startup so that total calls survive restarts. (empty to disable)
+stats_snapshot_file = ""
+stats_snapshot_interval = "60s"
+
+# Capture cpu/heap/goroutine profiles into profile_capture_dir when tp99 of ALL exceeds
+# profile_latency_threshold for profile_latency_intervals consecutive seconds.
+# Captures are at least profile_capture_interval apart. Set threshold to 0ms to disable.
+profile_latency_threshold = "0ms"
+profile_latency_intervals = 3
+profile_capture_interval = "10m"
+profile_capture_dir = "profile"
+
+# Request middlewares, executed in order for every request, separated by comma.
+# Middlewares register themselves by name, either compiled into the proxy or
+# from go plugins listed in proxy_middleware_plugins (paths separated by comma).
+proxy_middlewares = ""
+proxy_middleware_plugins = ""
+
 # monitor big key big value
 # max length of single value
 monitor_max_value_len = 4096
@@ -187,11 +375,22 @@ type Config struct {
 	PidFile        string  `toml:"pidfile"`
 
 	ConfigFileName	string    		`toml:"-" json:"config_file_name"`
+	//配置文件使用了include或环境变量，不能被改写
+	ConfigTemplated bool `toml:"-" json:"config_templated,omitempty"`
 
 	ProtoType string `toml:"proto_type" json:"proto_type"`
 	ProxyAddr string `toml:"proxy_addr" json:"proxy_addr"`
 	AdminAddr string `toml:"admin_addr" json:"admin_addr"`
 
+	ProxyTLSCertFile     string `toml:"proxy_tls_cert_file" json:"proxy_tls_cert_file"`
+	ProxyTLSKeyFile      string `toml:"proxy_tls_key_file" json:"-"`
+	ProxyTLSClientAuth   string `toml:"proxy_tls_client_auth" json:"proxy_tls_client_auth"`
+	ProxyTLSClientCAFile string `toml:"proxy_tls_client_ca_file" json:"proxy_tls_client_ca_file"`
+
+	ClusterMode         bool   `toml:"cluster_mode" json:"cluster_mode"`
+	ClusterAnnounceAddr string `toml:"cluster_announce_addr" json:"cluster_announce_addr"`
+	ClusterNodes        string `toml:"cluster_nodes" json:"cluster_nodes"`
+
 	HostProxy string `toml:"-" json:"-"`
 	HostAdmin string `toml:"-" json:"-"`
 
@@ -202,12 +401,34 @@ type Config struct {
 	JodisCompatible bool              `toml:"jodis_compatible" json:"jodis_compatible"`
 	JodisProxySubDir  string         `toml:"jodis_proxy_subdir" json:"jodis_proxy_subdir"`
 
-	ProductName string `toml:"product_name" json:"product_name"`
-	ProductAuth string `toml:"product_auth" json:"-"`
-	SessionAuth string `toml:"session_auth" json:"-"`
+	SentinelUsername      string `toml:"sentinel_username" json:"sentinel_username"`
+	SentinelPassword      string `toml:"sentinel_password" json:"-"`
+	SentinelTLS           bool   `toml:"sentinel_tls" json:"sentinel_tls"`
+	SentinelTLSSkipVerify bool   `toml:"sentinel_tls_skip_verify" json:"sentinel_tls_skip_verify"`
+	SentinelTLSCAFile     string `toml:"sentinel_tls_ca_file" json:"sentinel_tls_ca_file"`
+
+	BackendTLS           bool   `toml:"backend_tls" json:"backend_tls"`
+	BackendTLSSkipVerify bool   `toml:"backend_tls_skip_verify" json:"backend_tls_skip_verify"`
+	BackendTLSCAFile     string `toml:"backend_tls_ca_file" json:"backend_tls_ca_file"`
+	BackendTLSCertFile   string `toml:"backend_tls_cert_file" json:"backend_tls_cert_file"`
+	BackendTLSKeyFile    string `toml:"backend_tls_key_file" json:"-"`
+	BackendTLSServerName string `toml:"backend_tls_server_name" json:"backend_tls_server_name"`
+
+	//由Proxy.setup根据backend_tls_*创建，所有后端连接共用
+	backendTLS *tls.Config
+
+	ProductName    string `toml:"product_name" json:"product_name"`
+	ProductAuth    string `toml:"product_auth" json:"-"`
+	SessionAuth    string `toml:"session_auth" json:"-"`
+	SessionACLFile string `toml:"session_acl_file" json:"session_acl_file"`
+
+	SlotHash string `toml:"slot_hash" json:"slot_hash"`
+	HashTag  string `toml:"hash_tag" json:"hash_tag"`
 
 	ProxyDataCenter      string         `toml:"proxy_datacenter" json:"proxy_datacenter"`
 	ProxyMaxClients      int            `toml:"proxy_max_clients" json:"proxy_max_clients"`
+	ProxyMaxQPS          int64          `toml:"proxy_max_qps" json:"proxy_max_qps"`
+	ProxyMaxInflight     int64          `toml:"proxy_max_inflight" json:"proxy_max_inflight"`
 	ProxyMaxOffheapBytes bytesize.Int64 `toml:"proxy_max_offheap_size" json:"proxy_max_offheap_size"`
 	ProxyHeapPlaceholder bytesize.Int64 `toml:"proxy_heap_placeholder" json:"proxy_heap_placeholder"`
 	ProxyRefreshStatePeriod timesize.Duration `toml:"proxy_refresh_state_period" json:"proxy_refresh_state_period"`
@@ -219,12 +440,23 @@ type Config struct {
 	BackendSendTimeout     timesize.Duration `toml:"backend_send_timeout" json:"backend_send_timeout"`
 	BackendMaxPipeline     int               `toml:"backend_max_pipeline" json:"backend_max_pipeline"`
 	BackendPrimaryOnly     bool              `toml:"backend_primary_only" json:"backend_primary_only"`
+	BackendReplicaBalance  string            `toml:"backend_replica_balance" json:"backend_replica_balance"`
+	ReplicaMaxLag          int64             `toml:"replica_max_lag" json:"replica_max_lag"`
+	BackendHedgePercentile int               `toml:"backend_hedge_percentile" json:"backend_hedge_percentile"`
+	BackendHedgeMinDelay   timesize.Duration `toml:"backend_hedge_min_delay" json:"backend_hedge_min_delay"`
 	BackendPrimaryParallel int               `toml:"backend_primary_parallel" json:"backend_primary_parallel"`
 	BackendPrimaryQuick    int               `toml:"backend_primary_quick" json:"backend_primary_quick"`
 	BackendReplicaParallel int               `toml:"backend_replica_parallel" json:"backend_replica_parallel"`
 	BackendReplicaQuick    int               `toml:"backend_replica_quick" json:"backend_replica_quick"`
 	BackendKeepAlivePeriod timesize.Duration `toml:"backend_keepalive_period" json:"backend_keepalive_period"`
 	BackendNumberDatabases int32             `toml:"backend_number_databases" json:"backend_number_databases"`
+	BackendRESP3           bool              `toml:"backend_resp3" json:"backend_resp3"`
+	BackendDrainTimeout    timesize.Duration `toml:"backend_drain_timeout" json:"backend_drain_timeout"`
+	BackendStuckTimeout    timesize.Duration `toml:"backend_stuck_timeout" json:"backend_stuck_timeout"`
+	BackendPoolAlarmInflight int             `toml:"backend_pool_alarm_inflight" json:"backend_pool_alarm_inflight"`
+	BackendPoolAlarmWait   timesize.Duration `toml:"backend_pool_alarm_wait" json:"backend_pool_alarm_wait"`
+	BackendCircuitFailures int               `toml:"backend_circuit_failures" json:"backend_circuit_failures"`
+	BackendCircuitOpenTimeout timesize.Duration `toml:"backend_circuit_open_timeout" json:"backend_circuit_open_timeout"`
 
 	SessionRecvBufsize     bytesize.Int64    `toml:"session_recv_bufsize" json:"session_recv_bufsize"`
 	SessionRecvTimeout     timesize.Duration `toml:"session_recv_timeout" json:"session_recv_timeout"`
@@ -233,12 +465,41 @@ type Config struct {
 	SessionMaxPipeline     int               `toml:"session_max_pipeline" json:"session_max_pipeline"`
 	SessionKeepAlivePeriod timesize.Duration `toml:"session_keepalive_period" json:"session_keepalive_period"`
 	SessionBreakOnFailure  bool              `toml:"session_break_on_failure" json:"session_break_on_failure"`
+	SessionMaxBatchKeys    int               `toml:"session_max_batch_keys" json:"session_max_batch_keys"`
+	SessionSplitMultiKeys  bool              `toml:"session_split_multi_keys" json:"session_split_multi_keys"`
+	SessionMaxBlockingCommands int           `toml:"session_max_blocking_commands" json:"session_max_blocking_commands"`
+	SessionTrackingMaxKeys int               `toml:"session_tracking_max_keys" json:"session_tracking_max_keys"`
 
 	SlowlogLogSlowerThan   int64 			 `toml:"slowlog_log_slower_than" json:"slowlog_log_slower_than"`
 	SlowlogMaxLen          int64 			 `toml:"slowlog_max_len" json:"slowlog_max_len"`
 	QuickCmdList		   string            	 `toml:"quick_cmd_list" json:"quick_cmd_list"`
 	SlowCmdList		   	   string        `toml:"slow_cmd_list" json:"slow_cmd_list"`
 	AutoSetSlowFlag		   bool			 `toml:"auto_set_slow_flag" json:"auto_set_slow_flag"`
+	SLORules               string            `toml:"slo_rules" json:"slo_rules"`
+	WaitReplicasRules      string            `toml:"wait_replicas_rules" json:"wait_replicas_rules"`
+	MetricsOtherQPSThreshold int64           `toml:"metrics_other_qps_threshold" json:"metrics_other_qps_threshold"`
+	MetricsQuantileHalfLife timesize.Duration `toml:"metrics_quantile_halflife" json:"metrics_quantile_halflife"`
+	HotKeySampleRate       int64             `toml:"hotkey_sample_rate" json:"hotkey_sample_rate"`
+	HotKeyTopN             int               `toml:"hotkey_top_n" json:"hotkey_top_n"`
+	HotKeyInterval         timesize.Duration `toml:"hotkey_interval" json:"hotkey_interval"`
+	HotCachePrefixes       string            `toml:"hotcache_prefixes" json:"hotcache_prefixes"`
+	HotCacheTTL            timesize.Duration `toml:"hotcache_ttl" json:"hotcache_ttl"`
+	HotCacheMaxKeys        int               `toml:"hotcache_max_keys" json:"hotcache_max_keys"`
+	HotCachePolicy         string            `toml:"hotcache_policy" json:"hotcache_policy"`
+	BigKeyThreshold        bytesize.Int64    `toml:"bigkey_threshold" json:"bigkey_threshold"`
+	BigKeyMaxPatterns      int               `toml:"bigkey_max_patterns" json:"bigkey_max_patterns"`
+	ClientAddrStatsMax     int               `toml:"client_addr_stats_max" json:"client_addr_stats_max"`
+	ScriptCacheMax         int               `toml:"script_cache_max" json:"script_cache_max"`
+	StatsSnapshotFile      string            `toml:"stats_snapshot_file" json:"stats_snapshot_file"`
+	StatsSnapshotInterval  timesize.Duration `toml:"stats_snapshot_interval" json:"stats_snapshot_interval"`
+
+	ProfileLatencyThreshold timesize.Duration `toml:"profile_latency_threshold" json:"profile_latency_threshold"`
+	ProfileLatencyIntervals int               `toml:"profile_latency_intervals" json:"profile_latency_intervals"`
+	ProfileCaptureInterval  timesize.Duration `toml:"profile_capture_interval" json:"profile_capture_interval"`
+	ProfileCaptureDir       string            `toml:"profile_capture_dir" json:"profile_capture_dir"`
+
+	ProxyMiddlewares       string `toml:"proxy_middlewares" json:"proxy_middlewares"`
+	ProxyMiddlewarePlugins string `toml:"proxy_middleware_plugins" json:"proxy_middleware_plugins"`
 
 	MonitorMaxValueLen         int64   `toml:"monitor_max_value_len" json:"monitor_max_value_len"`
 	MonitorMaxBatchsize        int64   `toml:"monitor_max_batchsize" json:"monitor_max_batchsize"`
@@ -268,10 +529,14 @@ func NewDefaultConfig() *Config {
 }
 
 func (c *Config) LoadFromFile(path string) error {
-	_, err := toml.DecodeFile(path, c)
+	data, templated, err := utils.ReadConfigFile(path)
 	if err != nil {
+		return err
+	}
+	if _, err := toml.Decode(data, c); err != nil {
 		return errors.Trace(err)
 	}
+	c.ConfigTemplated = templated
 	return c.Validate()
 }
 
@@ -293,6 +558,31 @@ func (c *Config) Validate() error {
 	if c.AdminAddr == "" {
 		return errors.New("invalid admin_addr")
 	}
+	if c.ProtoType == ProtoTypeTLS {
+		if c.ProxyTLSCertFile == "" || c.ProxyTLSKeyFile == "" {
+			return errors.New("invalid proxy_tls_cert_file or proxy_tls_key_file")
+		}
+		switch c.ProxyTLSClientAuth {
+		case "", TLSClientAuthNone, TLSClientAuthRequest:
+		case TLSClientAuthVerify:
+			if c.ProxyTLSClientCAFile == "" {
+				return errors.New("invalid proxy_tls_client_ca_file")
+			}
+		default:
+			return errors.New("invalid proxy_tls_client_auth")
+		}
+	}
+	if err := models.ValidateSlotHash(c.SlotHash, c.HashTag); err != nil {
+		return err
+	}
+	if nodes := parseClusterNodes(c.ClusterNodes); len(nodes) != 0 {
+		if c.ClusterAnnounceAddr == "" || clusterNodeIndex(nodes, c.ClusterAnnounceAddr) < 0 {
+			return errors.New("invalid cluster_announce_addr, must be one of cluster_nodes")
+		}
+	}
+	if (c.BackendTLSCertFile == "") != (c.BackendTLSKeyFile == "") {
+		return errors.New("invalid backend_tls_cert_file or backend_tls_key_file")
+	}
 	if c.JodisName != "" {
 		if c.JodisAddr == "" {
 			return errors.New("invalid jodis_addr")
@@ -307,6 +597,12 @@ func (c *Config) Validate() error {
 	if c.ProxyMaxClients <= 0 {
 		return errors.New("invalid proxy_max_clients")
 	}
+	if c.ProxyMaxQPS < 0 {
+		return errors.New("invalid proxy_max_qps")
+	}
+	if c.ProxyMaxInflight < 0 {
+		return errors.New("invalid proxy_max_inflight")
+	}
 
 	const MaxInt = bytesize.Int64(^uint(0) >> 1)
 
@@ -356,6 +652,24 @@ func (c *Config) Validate() error {
 	if c.BackendNumberDatabases < 1 {
 		return errors.New("invalid backend_number_databases")
 	}
+	if c.BackendDrainTimeout < 0 {
+		return errors.New("invalid backend_drain_timeout")
+	}
+	if c.BackendStuckTimeout < 0 {
+		return errors.New("invalid backend_stuck_timeout")
+	}
+	if c.BackendPoolAlarmInflight < 0 {
+		return errors.New("invalid backend_pool_alarm_inflight")
+	}
+	if c.BackendPoolAlarmWait < 0 {
+		return errors.New("invalid backend_pool_alarm_wait")
+	}
+	if c.BackendCircuitFailures < 0 {
+		return errors.New("invalid backend_circuit_failures")
+	}
+	if c.BackendCircuitOpenTimeout <= 0 {
+		return errors.New("invalid backend_circuit_open_timeout")
+	}
 
 	if d := c.SessionRecvBufsize; d < 0 || d > MaxInt {
 		return errors.New("invalid session_recv_bufsize")
@@ -375,13 +689,91 @@ func (c *Config) Validate() error {
 	if c.SessionKeepAlivePeriod < 0 {
 		return errors.New("invalid session_keepalive_period")
 	}
+	if c.SessionMaxBatchKeys < 0 {
+		return errors.New("invalid session_max_batch_keys")
+	}
+	if c.SessionMaxBlockingCommands < 0 {
+		return errors.New("invalid session_max_blocking_commands")
+	}
+	if c.SessionTrackingMaxKeys < 0 {
+		return errors.New("invalid session_tracking_max_keys")
+	}
 
 	if c.SlowlogLogSlowerThan < 0 {
 		return errors.New("invalid slowlog_log_slower_than")
 	}
+	if c.MetricsOtherQPSThreshold < 0 {
+		return errors.New("invalid metrics_other_qps_threshold")
+	}
+	if c.MetricsQuantileHalfLife <= 0 {
+		return errors.New("invalid metrics_quantile_halflife")
+	}
+	if c.HotKeySampleRate < 0 {
+		return errors.New("invalid hotkey_sample_rate")
+	}
+	if c.HotKeyTopN <= 0 {
+		return errors.New("invalid hotkey_top_n")
+	}
+	if c.HotKeyInterval <= 0 {
+		return errors.New("invalid hotkey_interval")
+	}
+	if c.HotCacheTTL <= 0 {
+		return errors.New("invalid hotcache_ttl")
+	}
+	if c.HotCacheMaxKeys <= 0 {
+		return errors.New("invalid hotcache_max_keys")
+	}
+	if _, err := parseHotCachePolicy(c.HotCachePolicy); err != nil {
+		return errors.New("invalid hotcache_policy")
+	}
+	if c.BigKeyThreshold < 0 {
+		return errors.New("invalid bigkey_threshold")
+	}
+	if c.BigKeyMaxPatterns <= 0 {
+		return errors.New("invalid bigkey_max_patterns")
+	}
+	if c.ClientAddrStatsMax < 0 {
+		return errors.New("invalid client_addr_stats_max")
+	}
+	if c.ScriptCacheMax < 0 {
+		return errors.New("invalid script_cache_max")
+	}
+	if c.StatsSnapshotInterval <= 0 {
+		return errors.New("invalid stats_snapshot_interval")
+	}
 	if c.SlowlogMaxLen < 0 {
 		return errors.New("invalid slowlog_max_len")
 	}
+	if _, err := parseSLORules(c.SLORules); err != nil {
+		return errors.New("invalid slo_rules")
+	}
+	if _, err := parseReplicaBalance(c.BackendReplicaBalance); err != nil {
+		return errors.New("invalid backend_replica_balance")
+	}
+	if c.BackendHedgePercentile < 0 || c.BackendHedgePercentile >= 100 {
+		return errors.New("invalid backend_hedge_percentile")
+	}
+	if c.BackendHedgeMinDelay < 0 {
+		return errors.New("invalid backend_hedge_min_delay")
+	}
+	if c.ReplicaMaxLag < 0 {
+		return errors.New("invalid replica_max_lag")
+	}
+	if _, err := parseWaitRules(c.WaitReplicasRules); err != nil {
+		return errors.New("invalid wait_replicas_rules")
+	}
+	if c.ProfileLatencyThreshold < 0 {
+		return errors.New("invalid profile_latency_threshold")
+	}
+	if c.ProfileLatencyIntervals <= 0 {
+		return errors.New("invalid profile_latency_intervals")
+	}
+	if c.ProfileCaptureInterval < 0 {
+		return errors.New("invalid profile_capture_interval")
+	}
+	if c.ProfileLatencyThreshold != 0 && c.ProfileCaptureDir == "" {
+		return errors.New("invalid profile_capture_dir")
+	}
 	if c.Ncpu <= 0 {
 		return errors.New("invalid ncpu")
 	}
@@ -423,3 +815,21 @@ func (c *Config) Validate() error {
 	}
 	return nil
 }
+
+//未配置认证与TLS时返回nil
+func (c *Config) SentinelDialOptions() (*utilredis.DialOptions, error) {
+	if c.SentinelUsername == "" && c.SentinelPassword == "" && !c.SentinelTLS {
+		return nil, nil
+	}
+	opts := &utilredis.DialOptions{
+		Username: c.SentinelUsername, Password: c.SentinelPassword,
+	}
+	if c.SentinelTLS {
+		config, err := utilredis.NewTLSConfig(c.SentinelTLSCAFile, c.SentinelTLSSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = config
+	}
+	return opts, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type ConfigReload struct {
+	Applied []string `json:"applied"`
+	Restart []string `json:"restart"`
+}
+
+//重新读取配置文件，能在运行时修改的配置直接生效，返回生效的配置和需要重启才能生效的配置
+func (s *Proxy) ReloadConfig() (applied, restart []string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.ConfigFileName == "" {
+		return nil, nil, errors.New("proxy is not started with a config file")
+	}
+	c := NewDefaultConfig()
+	if err := c.LoadFromFile(s.config.ConfigFileName); err != nil {
+		return nil, nil, err
+	}
+
+	last, err := configValues(s.config)
+	if err != nil {
+		return nil, nil, err
+	}
+	next, err := configValues(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, key := range diffConfigValues(last, next) {
+		switch err := s.setConfig(key, next[key]); {
+		case err == ErrInvalidConfigKey:
+			restart = append(restart, key)
+		case err != nil:
+			log.WarnErrorf(err, "[%p] reload config %s failed", s, key)
+		default:
+			applied = append(applied, key)
+		}
+	}
+	s.config.ConfigTemplated = c.ConfigTemplated
+	return applied, restart, nil
+}
+
+//按toml中的名字展开配置，product_auth等不输出到json的配置也要参与对比
+func configValues(c *Config) (map[string]string, error) {
+	var b bytes.Buffer
+	if err := toml.NewEncoder(&b).Encode(c); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var m map[string]interface{}
+	if _, err := toml.Decode(b.String(), &m); err != nil {
+		return nil, errors.Trace(err)
+	}
+	var values = make(map[string]string, len(m))
+	for k, v := range m {
+		values[k] = fmt.Sprint(v)
+	}
+	return values, nil
+}
+
+func diffConfigValues(last, next map[string]string) []string {
+	var keys []string
+	for k, v := range next {
+		if last[k] != v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
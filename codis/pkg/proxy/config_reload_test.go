@@ -0,0 +1,68 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestDiffConfigValues(x *testing.T) {
+	c := newProxyConfig()
+	last, err := configValues(c)
+	assert.MustNoError(err)
+	assert.Must(len(diffConfigValues(last, last)) == 0)
+
+	n := newProxyConfig()
+	n.ProxyMaxClients = c.ProxyMaxClients + 1
+	n.ProductAuth = "secret"
+	assert.MustNoError(n.SessionRecvTimeout.UnmarshalText([]byte("15m")))
+	next, err := configValues(n)
+	assert.MustNoError(err)
+
+	keys := diffConfigValues(last, next)
+	assert.Must(len(keys) == 3)
+	assert.Must(keys[0] == "product_auth" && keys[1] == "proxy_max_clients" && keys[2] == "session_recv_timeout")
+	assert.Must(next["session_recv_timeout"] == "15m")
+}
+
+func TestReloadConfigApi(x *testing.T) {
+	f, err := ioutil.TempFile("", "proxy_reload")
+	assert.MustNoError(err)
+	defer os.Remove(f.Name())
+	f.Close()
+
+	c := newProxyConfig()
+	c.ConfigFileName = f.Name()
+	assert.MustNoError(ioutil.WriteFile(f.Name(), []byte(`
+proxy_addr = "0.0.0.0:0"
+admin_addr = "0.0.0.0:0"
+proxy_heap_placeholder = "0"
+proxy_max_offheap_size = "0"
+`), 0644))
+	s, err := New(c)
+	assert.MustNoError(err)
+	defer s.Close()
+
+	api := NewApiClient(s.Model().AdminAddr)
+	api.SetXAuth(c.ProductName, c.ProductAuth, s.Model().Token)
+
+	assert.MustNoError(ioutil.WriteFile(f.Name(), []byte(`
+proxy_addr = "0.0.0.0:0"
+admin_addr = "0.0.0.0:0"
+proxy_heap_placeholder = "0"
+proxy_max_offheap_size = "0"
+proxy_max_clients = 100
+session_recv_timeout = "15m"
+slot_hash = "crc16"
+`), 0644))
+	reload, err := api.ReloadConfig()
+	assert.MustNoError(err)
+	assert.Must(len(reload.Applied) == 2 && reload.Applied[0] == "proxy_max_clients")
+	assert.Must(len(reload.Restart) == 1 && reload.Restart[0] == "slot_hash")
+	assert.Must(s.Config().ProxyMaxClients == 100)
+}
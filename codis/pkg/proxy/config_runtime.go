@@ -0,0 +1,140 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strconv"
+
+	"github.com/CodisLabs/codis/pkg/utils/bytesize"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/timesize"
+)
+
+//可以在运行时修改的连接配置，只对之后新建立的session与backend连接生效，熔断和hedge相关的配置立即生效
+var RuntimeConfigKeys = []string{
+	"backend_recv_bufsize",
+	"backend_recv_timeout",
+	"backend_send_bufsize",
+	"backend_send_timeout",
+	"backend_max_pipeline",
+	"backend_keepalive_period",
+	"backend_drain_timeout",
+	"backend_pool_alarm_inflight",
+	"backend_pool_alarm_wait",
+	"backend_circuit_failures",
+	"backend_circuit_open_timeout",
+	"backend_hedge_percentile",
+	"backend_hedge_min_delay",
+	"session_recv_bufsize",
+	"session_recv_timeout",
+	"session_send_bufsize",
+	"session_send_timeout",
+	"session_max_pipeline",
+	"session_keepalive_period",
+	"session_break_on_failure",
+}
+
+func (c *Config) runtimeField(key string) interface{} {
+	switch key {
+	case "backend_recv_bufsize":
+		return &c.BackendRecvBufsize
+	case "backend_recv_timeout":
+		return &c.BackendRecvTimeout
+	case "backend_send_bufsize":
+		return &c.BackendSendBufsize
+	case "backend_send_timeout":
+		return &c.BackendSendTimeout
+	case "backend_max_pipeline":
+		return &c.BackendMaxPipeline
+	case "backend_keepalive_period":
+		return &c.BackendKeepAlivePeriod
+	case "backend_drain_timeout":
+		return &c.BackendDrainTimeout
+	case "backend_pool_alarm_inflight":
+		return &c.BackendPoolAlarmInflight
+	case "backend_pool_alarm_wait":
+		return &c.BackendPoolAlarmWait
+	case "backend_circuit_failures":
+		return &c.BackendCircuitFailures
+	case "backend_circuit_open_timeout":
+		return &c.BackendCircuitOpenTimeout
+	case "backend_hedge_percentile":
+		return &c.BackendHedgePercentile
+	case "backend_hedge_min_delay":
+		return &c.BackendHedgeMinDelay
+	case "session_recv_bufsize":
+		return &c.SessionRecvBufsize
+	case "session_recv_timeout":
+		return &c.SessionRecvTimeout
+	case "session_send_bufsize":
+		return &c.SessionSendBufsize
+	case "session_send_timeout":
+		return &c.SessionSendTimeout
+	case "session_max_pipeline":
+		return &c.SessionMaxPipeline
+	case "session_keepalive_period":
+		return &c.SessionKeepAlivePeriod
+	case "session_break_on_failure":
+		return &c.SessionBreakOnFailure
+	}
+	return nil
+}
+
+func parseRuntimeField(field interface{}, value string) error {
+	switch p := field.(type) {
+	case *timesize.Duration:
+		return p.UnmarshalText([]byte(value))
+	case *bytesize.Int64:
+		return p.UnmarshalText([]byte(value))
+	case *int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		*p = n
+	case *bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		*p = b
+	default:
+		return errors.Errorf("unsupported field type %T", field)
+	}
+	return nil
+}
+
+func formatRuntimeField(field interface{}) string {
+	switch p := field.(type) {
+	case *timesize.Duration:
+		if text, err := p.MarshalText(); err == nil {
+			return string(text)
+		}
+	case *bytesize.Int64:
+		if text, err := p.MarshalText(); err == nil {
+			return string(text)
+		}
+	case *int:
+		return strconv.Itoa(*p)
+	case *bool:
+		return strconv.FormatBool(*p)
+	}
+	return ""
+}
+
+//先在副本上修改并校验整个配置，成功后再修改c；key不支持运行时修改时返回false
+func setRuntimeConfig(c *Config, key, value string) (bool, error) {
+	field := c.runtimeField(key)
+	if field == nil {
+		return false, nil
+	}
+	x := *c
+	if err := parseRuntimeField(x.runtimeField(key), value); err != nil {
+		return true, err
+	}
+	if err := x.Validate(); err != nil {
+		return true, err
+	}
+	return true, parseRuntimeField(field, value)
+}
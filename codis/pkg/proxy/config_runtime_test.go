@@ -0,0 +1,35 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSetRuntimeConfig(x *testing.T) {
+	c := newProxyConfig()
+
+	ok, err := setRuntimeConfig(c, "session_recv_timeout", "15m")
+	assert.Must(ok && err == nil)
+	assert.Must(c.SessionRecvTimeout.Duration() == time.Minute*15)
+	assert.Must(formatRuntimeField(c.runtimeField("session_recv_timeout")) == "15m")
+
+	ok, err = setRuntimeConfig(c, "backend_max_pipeline", "2048")
+	assert.Must(ok && err == nil && c.BackendMaxPipeline == 2048)
+
+	ok, err = setRuntimeConfig(c, "session_break_on_failure", "true")
+	assert.Must(ok && err == nil && c.SessionBreakOnFailure)
+
+	ok, err = setRuntimeConfig(c, "session_max_pipeline", "-1")
+	assert.Must(ok && err != nil && c.SessionMaxPipeline >= 0)
+
+	ok, err = setRuntimeConfig(c, "backend_recv_timeout", "abc")
+	assert.Must(ok && err != nil)
+
+	ok, err = setRuntimeConfig(c, "product_name", "demo")
+	assert.Must(!ok && err == nil)
+}
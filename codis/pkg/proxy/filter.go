@@ -0,0 +1,297 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//单次执行过滤脚本的最长时间，超时按脚本出错处理
+const filterScriptTimeout = time.Millisecond * 20
+
+//由dashboard下发的lua过滤脚本，脚本需要定义函数filter(req)：
+//  req.cmd为大写的命令名，req.args为命令参数（不含命令名）
+//  返回nil或"allow"放行；"deny", msg拒绝；"rewrite", args用新的参数替换；"tag", name放行并按name计数
+//脚本中可以用glob(pattern, s)按*和?通配符匹配key；脚本出错或超时时放行请求，并记录错误次数
+type requestFilter struct {
+	script string
+	proto  *lua.FunctionProto
+	states sync.Pool
+
+	calls     atomic2.Int64
+	denied    atomic2.Int64
+	rewritten atomic2.Int64
+	errors    atomic2.Int64
+
+	tags struct {
+		sync.Mutex
+		m map[string]int64
+	}
+}
+
+type RequestFilterStatus struct {
+	Script    string           `json:"script"`
+	Calls     int64            `json:"calls"`
+	Denied    int64            `json:"denied"`
+	Rewritten int64            `json:"rewritten"`
+	Errors    int64            `json:"errors"`
+	Tags      map[string]int64 `json:"tags,omitempty"`
+}
+
+var reqFilter atomic.Value
+
+func init() {
+	reqFilter.Store((*requestFilter)(nil))
+}
+
+func compileRequestFilter(script string) (*requestFilter, error) {
+	chunk, err := parse.Parse(strings.NewReader(script), "filter")
+	if err != nil {
+		return nil, errors.Errorf("parse filter script failed, %s", err)
+	}
+	proto, err := lua.Compile(chunk, "filter")
+	if err != nil {
+		return nil, errors.Errorf("compile filter script failed, %s", err)
+	}
+	f := &requestFilter{script: script, proto: proto}
+	f.tags.m = make(map[string]int64)
+
+	L, err := f.newState()
+	if err != nil {
+		return nil, err
+	}
+	f.states.Put(L)
+	return f, nil
+}
+
+//检查脚本能否编译以及是否定义了filter函数，供dashboard下发前校验
+func ValidateRequestFilter(script string) error {
+	if script == "" {
+		return nil
+	}
+	_, err := compileRequestFilter(script)
+	return err
+}
+
+//只打开base、table、string、math库，并去掉读文件和加载代码的函数
+func (f *requestFilter) newState() (*lua.LState, error) {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		if err := L.CallByParam(lua.P{Fn: L.NewFunction(lib.open), Protect: true}, lua.LString(lib.name)); err != nil {
+			L.Close()
+			return nil, errors.Errorf("open lua library %s failed, %s", lib.name, err)
+		}
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.SetGlobal("glob", L.NewFunction(luaGlob))
+
+	ctx, cancel := context.WithTimeout(context.Background(), filterScriptTimeout)
+	defer cancel()
+	L.SetContext(ctx)
+	defer L.RemoveContext()
+
+	L.Push(L.NewFunctionFromProto(f.proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		L.Close()
+		return nil, errors.Errorf("run filter script failed, %s", err)
+	}
+	if L.GetGlobal("filter").Type() != lua.LTFunction {
+		L.Close()
+		return nil, errors.New("function filter is not defined in filter script")
+	}
+	return L, nil
+}
+
+func (f *requestFilter) getState() (*lua.LState, error) {
+	if L, ok := f.states.Get().(*lua.LState); ok {
+		return L, nil
+	}
+	return f.newState()
+}
+
+func (f *requestFilter) incrTag(tag string) {
+	f.tags.Lock()
+	defer f.tags.Unlock()
+	if _, ok := f.tags.m[tag]; ok || len(f.tags.m) < maxClientNames {
+		f.tags.m[tag]++
+	}
+}
+
+//执行过滤脚本，返回非nil的响应表示请求被拒绝
+func (f *requestFilter) apply(r *Request) *redis.Resp {
+	f.calls.Incr()
+
+	L, err := f.getState()
+	if err != nil {
+		f.errors.Incr()
+		log.WarnErrorf(err, "create filter state failed")
+		return nil
+	}
+
+	args := L.NewTable()
+	for _, x := range r.Multi[1:] {
+		args.Append(lua.LString(x.Value))
+	}
+	req := L.NewTable()
+	req.RawSetString("cmd", lua.LString(r.OpStr))
+	req.RawSetString("args", args)
+
+	ctx, cancel := context.WithTimeout(context.Background(), filterScriptTimeout)
+	L.SetContext(ctx)
+	err = L.CallByParam(lua.P{Fn: L.GetGlobal("filter"), NRet: 2, Protect: true}, req)
+	L.RemoveContext()
+	cancel()
+
+	//出错的state可能处于不一致的状态，直接丢弃
+	if err != nil {
+		f.errors.Incr()
+		log.Debugf("run filter script failed, %s", err)
+		return nil
+	}
+	action, value := L.Get(-2), L.Get(-1)
+	L.Pop(2)
+	f.states.Put(L)
+
+	switch action {
+	case lua.LNil, lua.LString("allow"):
+		return nil
+	case lua.LString("deny"):
+		f.denied.Incr()
+		if msg, ok := value.(lua.LString); ok {
+			return redis.NewErrorf("ERR %s", string(msg))
+		}
+		return redis.NewErrorf("ERR request denied by filter")
+	case lua.LString("rewrite"):
+		t, ok := value.(*lua.LTable)
+		if !ok {
+			f.errors.Incr()
+			return nil
+		}
+		var multi = []*redis.Resp{r.Multi[0]}
+		for i := 1; i <= t.Len(); i++ {
+			multi = append(multi, redis.NewBulkBytes([]byte(lua.LVAsString(t.RawGetInt(i)))))
+		}
+		r.Multi = multi
+		f.rewritten.Incr()
+		return nil
+	case lua.LString("tag"):
+		f.incrTag(lua.LVAsString(value))
+		return nil
+	default:
+		f.errors.Incr()
+		return nil
+	}
+}
+
+func (f *requestFilter) status() *RequestFilterStatus {
+	o := &RequestFilterStatus{
+		Script:    f.script,
+		Calls:     f.calls.Int64(),
+		Denied:    f.denied.Int64(),
+		Rewritten: f.rewritten.Int64(),
+		Errors:    f.errors.Int64(),
+		Tags:      make(map[string]int64),
+	}
+	f.tags.Lock()
+	for tag, n := range f.tags.m {
+		o.Tags[tag] = n
+	}
+	f.tags.Unlock()
+	return o
+}
+
+//空脚本表示关闭过滤，脚本没有变化时保留计数
+func SetRequestFilter(script string) error {
+	if script == "" {
+		reqFilter.Store((*requestFilter)(nil))
+		return nil
+	}
+	if f := reqFilter.Load().(*requestFilter); f != nil && f.script == script {
+		return nil
+	}
+	f, err := compileRequestFilter(script)
+	if err != nil {
+		return err
+	}
+	reqFilter.Store(f)
+	return nil
+}
+
+func GetRequestFilterStatus() *RequestFilterStatus {
+	if f := reqFilter.Load().(*requestFilter); f != nil {
+		return f.status()
+	}
+	return &RequestFilterStatus{}
+}
+
+func checkRequestFilter(r *Request) *redis.Resp {
+	if f := reqFilter.Load().(*requestFilter); f != nil {
+		return f.apply(r)
+	}
+	return nil
+}
+
+func luaGlob(L *lua.LState) int {
+	pattern, s := L.CheckString(1), L.CheckString(2)
+	L.Push(lua.LBool(globMatch(pattern, s)))
+	return 1
+}
+
+//按redis的通配符规则匹配，支持*、?以及\转义
+func globMatch(pattern, s string) bool {
+	for len(pattern) != 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		case '\\':
+			if len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			fallthrough
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
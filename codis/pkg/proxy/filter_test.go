@@ -0,0 +1,62 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+const testFilterScript = `
+function filter(req)
+	if req.cmd == "DEL" and glob("prod:critical:*", req.args[1]) then
+		return "deny", "DEL of critical keys is not allowed"
+	end
+	if req.cmd == "KEYS" then
+		return "rewrite", {"user:*"}
+	end
+	if req.cmd == "HGETALL" then
+		return "tag", "hgetall"
+	end
+	if req.cmd == "LOOP" then
+		while true do end
+	end
+	return "allow"
+end
+`
+
+func TestRequestFilter(x *testing.T) {
+	defer SetRequestFilter("")
+
+	assert.Must(ValidateRequestFilter("function filter(req") != nil)
+	assert.Must(ValidateRequestFilter("local x = 1") != nil)
+	assert.Must(ValidateRequestFilter("os.exit(1)") != nil)
+	assert.MustNoError(SetRequestFilter(testFilterScript))
+
+	resp := checkRequestFilter(newQuotaRequest("DEL", "prod:critical:1"))
+	assert.Must(resp != nil && string(resp.Value) == "ERR DEL of critical keys is not allowed")
+	assert.Must(checkRequestFilter(newQuotaRequest("DEL", "prod:normal:1")) == nil)
+
+	r := newQuotaRequest("KEYS", "*")
+	assert.Must(checkRequestFilter(r) == nil)
+	assert.Must(len(r.Multi) == 2 && string(r.Multi[1].Value) == "user:*")
+
+	assert.Must(checkRequestFilter(newQuotaRequest("HGETALL", "h")) == nil)
+	assert.Must(checkRequestFilter(newQuotaRequest("LOOP")) == nil)
+
+	//脚本没有变化时保留计数
+	assert.MustNoError(SetRequestFilter(testFilterScript))
+	status := GetRequestFilterStatus()
+	assert.Must(status.Calls == 5 && status.Denied == 1 && status.Rewritten == 1 && status.Errors == 1)
+	assert.Must(status.Tags["hgetall"] == 1)
+}
+
+func TestGlobMatch(x *testing.T) {
+	assert.Must(globMatch("prod:*", "prod:1"))
+	assert.Must(globMatch("*", ""))
+	assert.Must(globMatch("a?c", "abc") && !globMatch("a?c", "ac"))
+	assert.Must(globMatch("a*b*c", "axxbyyc") && !globMatch("a*b*c", "axxbyy"))
+	assert.Must(globMatch(`a\*`, "a*") && !globMatch(`a\*`, "ab"))
+}
@@ -69,6 +69,11 @@ func (d *forwardSync) process(s *Slot, r *Request, hkey []byte) (*BackendConn, e
 			s.id, hkey)
 		return nil, ErrSlotIsNotReady
 	}
+	if s.migrate.bc != nil && r.Tx != nil {
+		if err := d.slotsmgrtTx(s, r); err != nil {
+			return nil, err
+		}
+	}
 	if s.migrate.bc != nil && len(hkey) != 0 {
 		if err := d.slotsmgrt(s, hkey, r.Database, r.Seed16()); err != nil {
 			log.Debugf("slot-%04d migrate from = %s to %s failed: hash key = '%s', database = %d, error = %s",
@@ -148,6 +153,11 @@ func (d *forwardSemiAsync) process(s *Slot, r *Request, hkey []byte) (_ *Backend
 			s.id, hkey)
 		return nil, false, ErrSlotIsNotReady
 	}
+	if s.migrate.bc != nil && r.Tx != nil {
+		if err := d.slotsmgrtTx(s, r); err != nil {
+			return nil, false, err
+		}
+	}
 	if s.migrate.bc != nil && len(hkey) != 0 {
 		resp, moved, err := d.slotsmgrtExecWrapper(s, hkey, r.Database, r.Seed16(), r.Multi)
 		switch {
@@ -212,6 +222,18 @@ func (d *forwardHelper) slotsmgrt(s *Slot, hkey []byte, database int32, seed uin
 	}
 }
 
+//事务中的命令无法用SLOTSMGRT-EXEC-WRAPPER包装，发送前先同步迁移事务中的所有key
+func (d *forwardHelper) slotsmgrtTx(s *Slot, r *Request) error {
+	for _, hkey := range r.TxKeys {
+		if err := d.slotsmgrt(s, hkey, r.Database, r.Seed16()); err != nil {
+			log.Debugf("slot-%04d migrate from = %s to %s failed: hash key = '%s', database = %d, error = %s",
+				s.id, s.migrate.bc.Addr(), s.backend.bc.Addr(), hkey, r.Database, err)
+			return err
+		}
+	}
+	return nil
+}
+
 func (d *forwardHelper) slotsmgrtExecWrapper(s *Slot, hkey []byte, database int32, seed uint, multi []*redis.Resp) (_ *redis.Resp, moved bool, _ error) {
 	m := &Request{}
 	m.Multi = make([]*redis.Resp, 0, 2+len(multi))
@@ -269,16 +291,9 @@ func (d *forwardHelper) slotsmgrtExecWrapper(s *Slot, hkey []byte, database int3
 
 func (d *forwardHelper) forward2(s *Slot, r *Request) *BackendConn {
 	var database = r.Database
-	if s.migrate.bc == nil && !r.IsMasterOnly() && len(s.replicaGroups) != 0 {
-		var seed = r.Seed16()
-		for _, group := range s.replicaGroups {
-			var i = seed
-			for range group {
-				i = (i + 1) % uint(len(group))
-				if bc := group[i].BackendConn(database, seed, r.OpFlag.IsQuick(), false); bc != nil {
-					return bc
-				}
-			}
+	if s.migrate.bc == nil && r.ReplicaRead && !r.IsMasterOnly() && len(s.replicaGroups) != 0 {
+		if bc := s.forwardReplica(r); bc != nil {
+			return bc
 		}
 	}
 
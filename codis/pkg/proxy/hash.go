@@ -0,0 +1,78 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"hash/crc32"
+	"sync/atomic"
+
+	"github.com/CodisLabs/codis/pkg/models"
+)
+
+type slotHasher struct {
+	crc16 bool
+	//beg为0时不使用hash tag
+	beg, end byte
+}
+
+var slotHash atomic.Value
+
+func init() {
+	slotHash.Store(&slotHasher{beg: '{', end: '}'})
+}
+
+//启动时根据slot_hash和hash_tag设置，运行中修改会使已有的key找不到
+func SetSlotHash(hash, tag string) error {
+	if err := models.ValidateSlotHash(hash, tag); err != nil {
+		return err
+	}
+	var h = &slotHasher{crc16: hash == models.SlotHashCRC16}
+	if tag != "" {
+		h.beg, h.end = tag[0], tag[1]
+	}
+	slotHash.Store(h)
+	return nil
+}
+
+//crc32时空的hash tag也会使用，与codis-server的slotsmgrt一致；crc16时与redis cluster一样使用整个key
+func Hash(key []byte) uint32 {
+	h := slotHash.Load().(*slotHasher)
+	if h.beg != 0 {
+		if beg := bytes.IndexByte(key, h.beg); beg >= 0 {
+			if end := bytes.IndexByte(key[beg+1:], h.end); end > 0 || (end == 0 && !h.crc16) {
+				key = key[beg+1 : beg+1+end]
+			}
+		}
+	}
+	if h.crc16 {
+		return uint32(crc16(key))
+	}
+	return crc32.ChecksumIEEE(key)
+}
+
+var crc16Table [256]uint16
+
+func init() {
+	for i := range crc16Table {
+		var crc = uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc = crc << 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+//CRC16-CCITT(XMODEM)，redis cluster使用的算法
+func crc16(b []byte) uint16 {
+	var crc uint16
+	for _, c := range b {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^c]
+	}
+	return crc
+}
@@ -0,0 +1,34 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"hash/crc32"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSlotHash(x *testing.T) {
+	defer SetSlotHash("crc32", "{}")
+
+	assert.Must(Hash([]byte("a{b}c")) == crc32.ChecksumIEEE([]byte("b")))
+	assert.Must(Hash([]byte("a{}c")) == crc32.ChecksumIEEE(nil))
+
+	assert.Must(SetSlotHash("md5", "{}") != nil)
+	assert.Must(SetSlotHash("crc16", "{") != nil)
+
+	//与redis cluster的CLUSTER KEYSLOT相同
+	assert.MustNoError(SetSlotHash("crc16", "{}"))
+	assert.Must(crc16([]byte("123456789")) == 0x31c3)
+	assert.Must(Hash([]byte("foo"))%16384 == 12182)
+	assert.Must(Hash([]byte("{user1000}.following")) == Hash([]byte("user1000")))
+	assert.Must(Hash([]byte("foo{}{bar}")) != Hash([]byte("bar")))
+	assert.Must(Hash([]byte("foo{}{bar}")) != Hash(nil))
+
+	assert.MustNoError(SetSlotHash("crc32", "[]"))
+	assert.Must(Hash([]byte("a[b]{c}")) == crc32.ChecksumIEEE([]byte("b")))
+	assert.MustNoError(SetSlotHash("crc32", ""))
+	assert.Must(Hash([]byte("a{b}")) == crc32.ChecksumIEEE([]byte("a{b}")))
+}
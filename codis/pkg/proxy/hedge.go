@@ -0,0 +1,170 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//读命令发送到主库后超过一定的延迟还没有响应时，再向从库发送一个相同的请求，返回先到的响应
+var hedgeStats struct {
+	//可以hedge的读请求数
+	requests atomic2.Int64
+	//发送了hedge请求的次数
+	hedged atomic2.Int64
+	//hedge请求先返回的次数
+	wins atomic2.Int64
+}
+
+type HedgeStats struct {
+	Requests int64 `json:"requests"`
+	Hedged   int64 `json:"hedged"`
+	Wins     int64 `json:"wins"`
+	//发送hedge请求的比例
+	Rate float64 `json:"rate"`
+}
+
+func GetHedgeStats() *HedgeStats {
+	o := &HedgeStats{
+		Requests: hedgeStats.requests.Int64(),
+		Hedged:   hedgeStats.hedged.Int64(),
+		Wins:     hedgeStats.wins.Int64(),
+	}
+	if o.Requests != 0 {
+		o.Rate = float64(o.Hedged) / float64(o.Requests)
+	}
+	return o
+}
+
+func resetHedgeStats() {
+	hedgeStats.requests.Set(0)
+	hedgeStats.hedged.Set(0)
+	hedgeStats.wins.Set(0)
+}
+
+//opStats中缓存的hedge延迟，单位为us，百分位变化或者超过1秒后重新计算
+type hedgeDelay struct {
+	percentile atomic2.Int64
+	usecs      atomic2.Int64
+	expire     atomic2.Int64
+}
+
+func (h *hedgeDelay) get(q *decayedHistogram, percentile int) int64 {
+	now := time.Now().UnixNano()
+	if h.percentile.Int64() == int64(percentile) && now < h.expire.Int64() {
+		return h.usecs.Int64()
+	}
+	usecs, _ := q.quantile(float64(percentile) / 100)
+	h.usecs.Set(usecs)
+	h.percentile.Set(int64(percentile))
+	h.expire.Set(now + int64(time.Second))
+	return usecs
+}
+
+//按命令最近延迟的百分位计算，不小于backend_hedge_min_delay
+func (s *Session) hedgeDelay(r *Request) time.Duration {
+	e := getOpStats(r.OpStr, true)
+	d := time.Duration(e.hedge.get(&e.quantile, s.config.BackendHedgePercentile)) * time.Microsecond
+	if min := s.config.BackendHedgeMinDelay.Duration(); d < min {
+		d = min
+	}
+	return d
+}
+
+//只hedge发送到主库的读命令，读从库的session不需要
+func (s *Session) isHedgeable(r *Request) bool {
+	if s.config.BackendHedgePercentile <= 0 || r.ReplicaRead {
+		return false
+	}
+	return r.IsReadOnly() && !r.IsMasterOnly() && r.Tx == nil
+}
+
+//请求所在的slot没有在迁移并且有从库时才发送hedge请求
+func (s *Router) hasReplica(r *Request) bool {
+	slot := s.keySlot(getHashKey(r.Multi, r.OpStr))
+	slot.lock.RLock()
+	defer slot.lock.RUnlock()
+	return slot.migrate.bc == nil && len(slot.replicaGroups) != 0
+}
+
+type hedgedRequest struct {
+	sync.Mutex
+	r *Request
+	//已经发送、还没有返回的子请求数
+	pending int
+	done    bool
+}
+
+//子请求失败时如果另一个还没有返回，等待另一个的结果
+func (h *hedgedRequest) finish(x *Request) bool {
+	h.Lock()
+	defer h.Unlock()
+	h.pending--
+	if h.done || (x.Err != nil && h.pending != 0) {
+		return false
+	}
+	h.done = true
+	r := h.r
+	r.Resp, r.Err, r.Replies = x.Resp, x.Err, x.Replies
+	r.BackendAddr = x.BackendAddr
+	r.SendToServerTime, r.ReceiveFromServerTime = x.SendToServerTime, x.ReceiveFromServerTime
+	r.TasksLen = x.TasksLen
+	r.Batch.Done()
+	return true
+}
+
+//已经有结果时不再发送hedge请求
+func (h *hedgedRequest) start() bool {
+	h.Lock()
+	defer h.Unlock()
+	if h.done {
+		return false
+	}
+	h.pending++
+	return true
+}
+
+//主库请求在延迟内返回时不发送hedge请求，r.Batch在第一个成功的响应返回时完成
+func (s *Session) dispatchHedged(r *Request, d *Router) error {
+	var delay = s.hedgeDelay(r)
+	var sub = r.MakeSubRequest(2)
+	for i := range sub {
+		sub[i].Multi = r.Multi
+		sub[i].Batch = &sync.WaitGroup{}
+	}
+	primary, hedge := &sub[0], &sub[1]
+	hedge.ReplicaRead = true
+
+	if err := d.dispatch(primary); err != nil {
+		return err
+	}
+	hedgeStats.requests.Incr()
+
+	var h = &hedgedRequest{r: r, pending: 1}
+	r.Batch.Add(1)
+
+	timer := time.AfterFunc(delay, func() {
+		if !d.hasReplica(hedge) || !h.start() {
+			return
+		}
+		hedgeStats.hedged.Incr()
+		if err := d.dispatch(hedge); err != nil {
+			hedge.Err = err
+		} else {
+			hedge.Batch.Wait()
+		}
+		if h.finish(hedge) {
+			hedgeStats.wins.Incr()
+		}
+	})
+	go func() {
+		primary.Batch.Wait()
+		timer.Stop()
+		h.finish(primary)
+	}()
+	return nil
+}
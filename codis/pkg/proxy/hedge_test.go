@@ -0,0 +1,47 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestHedge(x *testing.T) {
+	master, replica1, replica2 := newSlowAddrServer(time.Millisecond*300), newFakeAddrServer(), newFakeAddrServer()
+	defer master.Close()
+	defer replica1.Close()
+	defer replica2.Close()
+
+	c := newProxyConfig()
+	c.BackendHedgePercentile = 50
+	c.BackendHedgeMinDelay.Set(time.Millisecond * 20)
+	router, _ := newReplicaRouter(c, master, replica1, replica2)
+	defer router.Close()
+
+	s := newHelloSession("")
+	s.config = c
+
+	//主库超过延迟没有响应时返回从库的响应
+	var last = GetHedgeStats()
+	assert.Must(doReadRequest(s, router, "GET", "a") != master.Addr().String())
+	h := GetHedgeStats()
+	assert.Must(h.Requests == last.Requests+1 && h.Hedged == last.Hedged+1 && h.Wins == last.Wins+1)
+
+	//写命令不hedge
+	assert.Must(doReadRequest(s, router, "SET", "a", "1") == master.Addr().String())
+	assert.Must(GetHedgeStats().Requests == h.Requests)
+
+	//主库在延迟内返回时不发送hedge请求
+	c.BackendHedgeMinDelay.Set(time.Second * 5)
+	assert.Must(doReadRequest(s, router, "GET", "a") == master.Addr().String())
+	last, h = h, GetHedgeStats()
+	assert.Must(h.Requests == last.Requests+1 && h.Hedged == last.Hedged)
+
+	c.BackendHedgePercentile = 0
+	assert.Must(doReadRequest(s, router, "GET", "a") == master.Addr().String())
+	assert.Must(GetHedgeStats().Requests == h.Requests)
+}
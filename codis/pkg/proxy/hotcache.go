@@ -0,0 +1,304 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//淘汰策略
+const (
+	HotCacheLRU = iota
+	HotCacheLFU
+)
+
+func parseHotCachePolicy(value string) (int, error) {
+	switch value {
+	case "", "lru":
+		return HotCacheLRU, nil
+	case "lfu":
+		return HotCacheLFU, nil
+	}
+	return 0, fmt.Errorf("invalid hotcache policy '%s'", value)
+}
+
+//LFU淘汰时随机抽样的key数，淘汰其中命中次数最少的
+const hotCacheLFUSamples = 5
+
+//写入时递增key所在分段的版本，未命中的请求返回时版本已经变化则不写入缓存，避免缓存写之前读到的旧值
+const hotCacheVersionNum = 256
+
+type hotCacheKey struct {
+	database int32
+	key      string
+}
+
+type hotCacheEntry struct {
+	key    hotCacheKey
+	resp   *redis.Resp
+	expire int64
+	hits   int64
+}
+
+//proxy本地的GET/MGET响应缓存，只缓存配置的key前缀
+var hotCache struct {
+	sync.Mutex
+	enabled atomic2.Bool
+
+	//按长度从长到短排列，*匹配所有key
+	prefixes []string
+	ttl      time.Duration
+	max      int
+	policy   int
+
+	m map[hotCacheKey]*list.Element
+	//LRU时按最近访问从新到旧排列
+	lru *list.List
+
+	versions  [hotCacheVersionNum]atomic2.Int64
+	evictions atomic2.Int64
+}
+
+func init() {
+	hotCache.m = make(map[hotCacheKey]*list.Element)
+	hotCache.lru = list.New()
+}
+
+type HotCacheStats struct {
+	Keys      int    `json:"keys"`
+	MaxKeys   int    `json:"max_keys"`
+	Policy    string `json:"policy"`
+	Evictions int64  `json:"evictions"`
+}
+
+//重新设置时清空已经缓存的key，prefixes为空时关闭缓存
+func HotCacheSetOptions(prefixes string, ttl time.Duration, max int, policy string) error {
+	p, err := parseHotCachePolicy(policy)
+	if err != nil {
+		return err
+	}
+	var all []string
+	for _, prefix := range strings.Split(prefixes, ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			all = append(all, prefix)
+		}
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return len(all[i]) > len(all[j])
+	})
+	hotCache.Lock()
+	defer hotCache.Unlock()
+	hotCache.prefixes, hotCache.ttl, hotCache.max, hotCache.policy = all, ttl, max, p
+	hotCache.m = make(map[hotCacheKey]*list.Element)
+	hotCache.lru.Init()
+	hotCache.enabled.Set(len(all) != 0 && ttl > 0 && max > 0)
+	return nil
+}
+
+func GetHotCacheStats() *HotCacheStats {
+	hotCache.Lock()
+	defer hotCache.Unlock()
+	o := &HotCacheStats{
+		Keys: len(hotCache.m), MaxKeys: hotCache.max,
+		Policy: "lru", Evictions: hotCache.evictions.Int64(),
+	}
+	if hotCache.policy == HotCacheLFU {
+		o.Policy = "lfu"
+	}
+	return o
+}
+
+func lockedMatchHotCachePrefix(key []byte) string {
+	for _, prefix := range hotCache.prefixes {
+		if prefix == "*" || strings.HasPrefix(string(key), prefix) {
+			return prefix
+		}
+	}
+	return ""
+}
+
+func hotCacheVersion(key []byte) *atomic2.Int64 {
+	return &hotCache.versions[Hash(key)%hotCacheVersionNum]
+}
+
+//返回还没有过期的缓存，LRU时移到最前面
+func lockedGetHotCache(k hotCacheKey, now int64) *hotCacheEntry {
+	e := hotCache.m[k]
+	if e == nil {
+		return nil
+	}
+	x := e.Value.(*hotCacheEntry)
+	if now >= x.expire {
+		hotCache.lru.Remove(e)
+		delete(hotCache.m, k)
+		return nil
+	}
+	x.hits++
+	if hotCache.policy == HotCacheLRU {
+		hotCache.lru.MoveToFront(e)
+	}
+	return x
+}
+
+func lockedEvictHotCache() {
+	var e = hotCache.lru.Back()
+	if hotCache.policy == HotCacheLFU {
+		var n int
+		for _, x := range hotCache.m {
+			if e == nil || x.Value.(*hotCacheEntry).hits < e.Value.(*hotCacheEntry).hits {
+				e = x
+			}
+			if n++; n >= hotCacheLFUSamples {
+				break
+			}
+		}
+	}
+	if e == nil {
+		return
+	}
+	hotCache.lru.Remove(e)
+	delete(hotCache.m, e.Value.(*hotCacheEntry).key)
+	hotCache.evictions.Incr()
+}
+
+func lockedPutHotCache(k hotCacheKey, resp *redis.Resp, now int64) {
+	var x = &hotCacheEntry{
+		key: k, expire: now + int64(hotCache.ttl),
+		resp: &redis.Resp{Type: resp.Type},
+	}
+	//响应可能引用后端连接的读缓冲区，复制一份
+	if resp.Value != nil {
+		x.resp.Value = append([]byte{}, resp.Value...)
+	}
+	if e := hotCache.m[k]; e != nil {
+		x.hits = e.Value.(*hotCacheEntry).hits
+		e.Value = x
+		hotCache.lru.MoveToFront(e)
+		return
+	}
+	for len(hotCache.m) >= hotCache.max {
+		lockedEvictHotCache()
+	}
+	hotCache.m[k] = hotCache.lru.PushFront(x)
+}
+
+//只缓存字符串和nil
+func isHotCacheable(resp *redis.Resp) bool {
+	return resp != nil && resp.IsBulkBytes()
+}
+
+//所有key都命中时直接返回缓存的响应，否则在收到响应后写入未命中的key
+func (s *Session) serveHotCache(r *Request) bool {
+	if !hotCache.enabled.IsTrue() || len(r.Multi) < 2 {
+		return false
+	}
+	var keys = r.Multi[1:]
+	if r.OpStr == "GET" && len(keys) != 1 {
+		return false
+	}
+	type miss struct {
+		index   int
+		version int64
+	}
+	var misses []miss
+	var values = make([]*redis.Resp, len(keys))
+	var prefixes = make([]string, len(keys))
+	var hits int
+	var now = time.Now().UnixNano()
+
+	hotCache.Lock()
+	for i, key := range keys {
+		if prefixes[i] = lockedMatchHotCachePrefix(key.Value); prefixes[i] == "" {
+			continue
+		}
+		if x := lockedGetHotCache(hotCacheKey{r.Database, string(key.Value)}, now); x != nil {
+			values[i], hits = x.resp, hits+1
+			continue
+		}
+		misses = append(misses, miss{index: i, version: hotCacheVersion(key.Value).Int64()})
+	}
+	hotCache.Unlock()
+
+	//MGET有key未命中时整个请求发送到后端，命中的key也计为未命中
+	var event = CacheMiss
+	if hits == len(keys) {
+		event = CacheHit
+	}
+	for _, prefix := range prefixes {
+		if prefix != "" {
+			incrCacheStats(r.OpStr, prefix, event)
+		}
+	}
+	if event == CacheHit {
+		if r.OpStr == "GET" {
+			r.Resp = values[0]
+		} else {
+			r.Resp = redis.NewArray(values)
+		}
+		return true
+	}
+	if len(misses) == 0 {
+		return false
+	}
+	r.CacheFill = func(resp *redis.Resp) {
+		var results = []*redis.Resp{resp}
+		if r.OpStr == "MGET" {
+			if !resp.IsArray() || len(resp.Array) != len(keys) {
+				return
+			}
+			results = resp.Array
+		}
+		var now = time.Now().UnixNano()
+		hotCache.Lock()
+		defer hotCache.Unlock()
+		if !hotCache.enabled.IsTrue() {
+			return
+		}
+		for _, m := range misses {
+			key, value := keys[m.index].Value, results[m.index]
+			if !isHotCacheable(value) || hotCacheVersion(key).Int64() != m.version {
+				continue
+			}
+			lockedPutHotCache(hotCacheKey{r.Database, string(key)}, value, now)
+		}
+	}
+	return false
+}
+
+//经过proxy的写命令使缓存的key失效
+func invalidateHotCache(r *Request) {
+	if !hotCache.enabled.IsTrue() || len(r.Multi) < 2 {
+		return
+	}
+	invalidateHotCacheKeys(r.OpStr, r.Database, namespaceKeys(r))
+}
+
+func invalidateHotCacheKeys(opstr string, database int32, keys [][]byte) {
+	if !hotCache.enabled.IsTrue() {
+		return
+	}
+	hotCache.Lock()
+	defer hotCache.Unlock()
+	for _, key := range keys {
+		prefix := lockedMatchHotCachePrefix(key)
+		if prefix == "" {
+			continue
+		}
+		hotCacheVersion(key).Incr()
+		k := hotCacheKey{database, string(key)}
+		if e := hotCache.m[k]; e != nil {
+			hotCache.lru.Remove(e)
+			delete(hotCache.m, k)
+			incrCacheStats(opstr, prefix, CacheInvalidation)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//GET每次返回递增的计数，其他命令返回OK
+func newCounterServer() net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	go func() {
+		var n int
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c *redis.Conn) {
+				defer c.Close()
+				for {
+					multi, err := c.DecodeMultiBulk()
+					if err != nil {
+						return
+					}
+					var resp = RespOK
+					if string(multi[0].Value) == "GET" {
+						n++
+						resp = redis.NewBulkBytes([]byte(strconv.Itoa(n)))
+					}
+					c.Encode(resp, true)
+				}
+			}(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return l
+}
+
+func TestHotCache(x *testing.T) {
+	defer HotCacheSetOptions("", time.Second, 1, "lru")
+
+	l := newCounterServer()
+	defer l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("{h}")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	assert.Must(waitFor(router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true).IsConnected))
+
+	s := newHelloSession("")
+	var get = func(key string) string {
+		return string(doTxRequest(s, router, "GET", key).Value)
+	}
+
+	assert.Must(HotCacheSetOptions("{h}a", time.Second, 2, "random") != nil)
+	assert.MustNoError(HotCacheSetOptions("{h}a", time.Second, 2, "lru"))
+	assert.Must(get("{h}a1") == "1" && get("{h}a1") == "1")
+	assert.Must(get("{h}b") == "2" && get("{h}b") == "3")
+
+	//写命令使缓存失效
+	assert.Must(string(doTxRequest(s, router, "SET", "{h}a1", "x").Value) == "OK")
+	assert.Must(get("{h}a1") == "4" && get("{h}a1") == "4")
+
+	//所有key都命中时MGET由缓存返回
+	assert.Must(get("{h}a2") == "5")
+	resp := doTxRequest(s, router, "MGET", "{h}a1", "{h}a2")
+	assert.Must(resp.IsArray() && len(resp.Array) == 2)
+	assert.Must(string(resp.Array[0].Value) == "4" && string(resp.Array[1].Value) == "5")
+
+	//超过hotcache_max_keys时淘汰最久没有访问的key
+	assert.Must(get("{h}a3") == "6")
+	assert.Must(get("{h}a2") == "5" && get("{h}a1") == "7")
+	assert.Must(GetHotCacheStats().Keys == 2 && GetHotCacheStats().Evictions == 2)
+
+	o := getOpStats("GET", true).cache.snapshot()
+	assert.Must(o.Hits != 0 && o.Misses != 0)
+	assert.Must(getOpStats("SET", true).cache.snapshot().Invalidations == 1)
+	stats := GetCachePrefixStats()
+	assert.Must(len(stats) == 1 && stats[0].Prefix == "{h}a")
+
+	//过期后重新读取
+	assert.MustNoError(HotCacheSetOptions("{h}a", time.Millisecond*50, 2, "lfu"))
+	assert.Must(GetHotCacheStats().Keys == 0)
+	assert.Must(get("{h}a1") == "8" && get("{h}a1") == "8")
+	time.Sleep(time.Millisecond * 60)
+	assert.Must(get("{h}a1") == "9")
+}
@@ -0,0 +1,257 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"container/heap"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+const (
+	hotKeySketchDepth = 4
+	hotKeySketchWidth = 4096
+)
+
+type HotKey struct {
+	Key   string `json:"key"`
+	Calls int64  `json:"calls"`
+	Usecs int64  `json:"usecs"`
+}
+
+//一个统计周期内按访问次数和按总延迟排序的热点key，次数与延迟已经按采样率放大，是估计值
+type HotKeys struct {
+	Interval   int64  `json:"interval"`
+	SampleRate int64  `json:"sample_rate"`
+	UpdateTime string `json:"update_time"`
+
+	ByCalls []*HotKey `json:"by_calls"`
+	ByUsecs []*HotKey `json:"by_usecs"`
+}
+
+//count-min sketch估计每个key的累计权重，最小堆保留权重最大的k个key
+type hotKeyTopK struct {
+	sketch [hotKeySketchDepth][hotKeySketchWidth]int64
+	heap   hotKeyHeap
+	index  map[string]*hotKeyItem
+	k      int
+}
+
+type hotKeyItem struct {
+	key   string
+	score int64
+	calls int64
+	usecs int64
+	pos   int
+}
+
+func newHotKeyTopK(k int) *hotKeyTopK {
+	return &hotKeyTopK{index: make(map[string]*hotKeyItem, k), k: k}
+}
+
+func (t *hotKeyTopK) add(key []byte, weight, usecs int64) {
+	h := fnv.New64a()
+	h.Write(key)
+	sum := h.Sum64()
+	h1, h2 := uint32(sum), uint32(sum>>32)
+
+	var score int64 = -1
+	for i := range t.sketch {
+		j := (h1 + uint32(i)*h2) % hotKeySketchWidth
+		t.sketch[i][j] += weight
+		if v := t.sketch[i][j]; score < 0 || v < score {
+			score = v
+		}
+	}
+
+	if x := t.index[string(key)]; x != nil {
+		x.score, x.calls, x.usecs = score, x.calls+1, x.usecs+usecs
+		heap.Fix(&t.heap, x.pos)
+		return
+	}
+	if len(t.heap) >= t.k {
+		if t.heap[0].score >= score {
+			return
+		}
+		x := heap.Pop(&t.heap).(*hotKeyItem)
+		delete(t.index, x.key)
+	}
+	x := &hotKeyItem{key: string(key), score: score, calls: 1, usecs: usecs}
+	t.index[x.key] = x
+	heap.Push(&t.heap, x)
+}
+
+//按score从大到小返回前n个key，排序的维度使用sketch的估计值，另一个维度只统计进入候选之后的部分
+func (t *hotKeyTopK) top(n int, rate int64, byUsecs bool) []*HotKey {
+	var items = make([]*hotKeyItem, len(t.heap))
+	copy(items, t.heap)
+	sort.Sort(sliceHotKeyItem(items))
+	if len(items) > n {
+		items = items[:n]
+	}
+	var list = make([]*HotKey, 0, len(items))
+	for _, x := range items {
+		k := &HotKey{Key: x.key, Calls: x.calls * rate, Usecs: x.usecs * rate}
+		if byUsecs {
+			k.Usecs = x.score * rate
+		} else {
+			k.Calls = x.score * rate
+		}
+		list = append(list, k)
+	}
+	return list
+}
+
+type sliceHotKeyItem []*hotKeyItem
+
+func (s sliceHotKeyItem) Len() int {
+	return len(s)
+}
+
+func (s sliceHotKeyItem) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceHotKeyItem) Less(i, j int) bool {
+	if s[i].score != s[j].score {
+		return s[i].score > s[j].score
+	}
+	return s[i].key < s[j].key
+}
+
+type hotKeyHeap []*hotKeyItem
+
+func (h hotKeyHeap) Len() int {
+	return len(h)
+}
+
+func (h hotKeyHeap) Less(i, j int) bool {
+	return h[i].score < h[j].score
+}
+
+func (h hotKeyHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos = i
+	h[j].pos = j
+}
+
+func (h *hotKeyHeap) Push(x interface{}) {
+	item := x.(*hotKeyItem)
+	item.pos = len(*h)
+	*h = append(*h, item)
+}
+
+func (h *hotKeyHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+var hotKeys struct {
+	sync.Mutex
+	calls *hotKeyTopK
+	usecs *hotKeyTopK
+
+	//每rate个请求采样一个，0表示关闭
+	rate atomic2.Int64
+	seq  atomic2.Int64
+	topn int
+
+	last atomic.Value
+}
+
+//修改参数后重新开始统计
+func HotKeySetOptions(rate int64, topn int) {
+	hotKeys.Lock()
+	defer hotKeys.Unlock()
+	if topn <= 0 {
+		topn = 20
+	}
+	hotKeys.topn = topn
+	//候选key多保留一些，减少临界位置的key被挤出
+	hotKeys.calls = newHotKeyTopK(topn * 4)
+	hotKeys.usecs = newHotKeyTopK(topn * 4)
+	hotKeys.rate.Set(rate)
+}
+
+func recordHotKey(r *Request, responseTime int64) {
+	rate := hotKeys.rate.Int64()
+	if rate <= 0 || hotKeys.seq.Incr()%rate != 0 || len(r.Multi) < 2 {
+		return
+	}
+	var usecs = responseTime / 1e3
+	var keys [][]byte
+	switch r.OpStr {
+	case "MGET", "DEL", "EXISTS", "UNLINK", "TOUCH":
+		for _, x := range r.Multi[1:] {
+			keys = append(keys, x.Value)
+		}
+	case "MSET", "MSETNX":
+		for i := 1; i < len(r.Multi); i += 2 {
+			keys = append(keys, r.Multi[i].Value)
+		}
+	default:
+		keys = append(keys, r.Multi[1].Value)
+	}
+	hotKeys.Lock()
+	defer hotKeys.Unlock()
+	if hotKeys.calls == nil {
+		return
+	}
+	for _, key := range keys {
+		hotKeys.calls.add(key, 1, usecs)
+		hotKeys.usecs.add(key, usecs, usecs)
+	}
+}
+
+//生成本周期的热点key并开始新的周期
+func rotateHotKeys(interval time.Duration) {
+	hotKeys.Lock()
+	defer hotKeys.Unlock()
+	rate := hotKeys.rate.Int64()
+	if rate <= 0 || hotKeys.calls == nil {
+		hotKeys.last.Store(&HotKeys{})
+		return
+	}
+	x := &HotKeys{
+		Interval: int64(interval / time.Second), SampleRate: rate,
+		UpdateTime: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	x.ByCalls = hotKeys.calls.top(hotKeys.topn, rate, false)
+	x.ByUsecs = hotKeys.usecs.top(hotKeys.topn, rate, true)
+	hotKeys.calls = newHotKeyTopK(hotKeys.calls.k)
+	hotKeys.usecs = newHotKeyTopK(hotKeys.usecs.k)
+	hotKeys.last.Store(x)
+}
+
+//返回上一个统计周期的热点key
+func GetHotKeys() *HotKeys {
+	x, _ := hotKeys.last.Load().(*HotKeys)
+	if x == nil {
+		return &HotKeys{}
+	}
+	return x
+}
+
+func (s *Proxy) AutoRotateHotKeys() {
+	var last = time.Now()
+	for !s.IsClosed() {
+		time.Sleep(time.Second)
+
+		s.mu.Lock()
+		interval := s.config.HotKeyInterval.Duration()
+		s.mu.Unlock()
+
+		if time.Since(last) >= interval {
+			rotateHotKeys(time.Since(last))
+			last = time.Now()
+		}
+	}
+}
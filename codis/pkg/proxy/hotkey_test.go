@@ -0,0 +1,72 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestHotKeyTopK(t *testing.T) {
+	topk := newHotKeyTopK(4)
+	for i := 0; i < 100; i++ {
+		topk.add([]byte("hot"), 1, 10)
+		if i%2 == 0 {
+			topk.add([]byte("warm"), 1, 10)
+		}
+		//大量只出现一次的key不会挤掉热点key
+		topk.add([]byte(fmt.Sprintf("cold-%d", i)), 1, 10)
+	}
+	list := topk.top(2, 10, false)
+	assert.Must(len(list) == 2)
+	assert.Must(list[0].Key == "hot" && list[0].Calls == 1000)
+	assert.Must(list[1].Key == "warm" && list[1].Calls == 500)
+	assert.Must(len(topk.heap) == 4 && len(topk.index) == 4)
+}
+
+func TestHotKeyTopKByUsecs(t *testing.T) {
+	topk := newHotKeyTopK(4)
+	for i := 0; i < 10; i++ {
+		topk.add([]byte("fast"), 1, 1)
+	}
+	topk.add([]byte("slow"), 100, 100)
+	list := topk.top(4, 1, true)
+	assert.Must(len(list) == 2)
+	assert.Must(list[0].Key == "slow" && list[0].Usecs == 100 && list[0].Calls == 1)
+	assert.Must(list[1].Key == "fast" && list[1].Usecs == 10)
+}
+
+func TestRotateHotKeys(t *testing.T) {
+	HotKeySetOptions(1, 2)
+	defer HotKeySetOptions(0, 0)
+
+	newRequest := func(args ...string) *Request {
+		r := &Request{OpStr: args[0]}
+		for _, s := range args {
+			r.Multi = append(r.Multi, redis.NewBulkBytes([]byte(s)))
+		}
+		return r
+	}
+	recordHotKey(newRequest("GET", "a"), 1e6)
+	recordHotKey(newRequest("MGET", "a", "b"), 3e6)
+	recordHotKey(newRequest("MSET", "b", "1", "c", "2"), 1e6)
+	recordHotKey(newRequest("PING"), 1e6)
+
+	rotateHotKeys(time.Second * 10)
+	x := GetHotKeys()
+	assert.Must(x.Interval == 10 && x.SampleRate == 1)
+	assert.Must(len(x.ByCalls) == 2)
+	assert.Must(x.ByCalls[0].Key == "a" && x.ByCalls[0].Calls == 2)
+	assert.Must(x.ByCalls[1].Key == "b" && x.ByCalls[1].Calls == 2)
+	assert.Must(len(x.ByUsecs) == 2)
+	assert.Must(x.ByUsecs[0].Key == "a" && x.ByUsecs[0].Usecs == 4000)
+	assert.Must(x.ByUsecs[1].Key == "b" && x.ByUsecs[1].Usecs == 4000)
+
+	rotateHotKeys(time.Second * 10)
+	assert.Must(len(GetHotKeys().ByCalls) == 0)
+}
@@ -0,0 +1,162 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//由dashboard下发的按key前缀的写入配额，每个proxy独立计数
+type keyQuota struct {
+	models.KeyQuota
+	prefix []byte
+
+	mu    sync.Mutex
+	sec   int64
+	ops   int64
+	day   int64
+	bytes int64
+
+	rejected atomic2.Int64
+}
+
+type KeyQuotaStatus struct {
+	models.KeyQuota
+	BytesToday int64 `json:"bytes_today"`
+	Rejected   int64 `json:"rejected"`
+}
+
+//按前缀长度从长到短排列，匹配最长的前缀
+var keyQuotas atomic.Value
+
+func init() {
+	keyQuotas.Store([]*keyQuota{})
+}
+
+//更新配额时保留已有前缀的计数，避免每次下发都重置当天的写入量
+func SetKeyQuotas(quotas []*models.KeyQuota) {
+	var last = make(map[string]*keyQuota)
+	for _, q := range keyQuotas.Load().([]*keyQuota) {
+		last[q.Prefix] = q
+	}
+	var list = make([]*keyQuota, 0, len(quotas))
+	for _, x := range quotas {
+		if x == nil || x.Prefix == "" {
+			continue
+		}
+		q := last[x.Prefix]
+		if q == nil {
+			q = &keyQuota{prefix: []byte(x.Prefix)}
+		}
+		q.mu.Lock()
+		q.KeyQuota = *x
+		q.mu.Unlock()
+		list = append(list, q)
+	}
+	sort.Sort(sliceKeyQuota(list))
+	keyQuotas.Store(list)
+}
+
+func GetKeyQuotaStatus() []*KeyQuotaStatus {
+	var list = keyQuotas.Load().([]*keyQuota)
+	var all = make([]*KeyQuotaStatus, 0, len(list))
+	for _, q := range list {
+		q.mu.Lock()
+		o := &KeyQuotaStatus{KeyQuota: q.KeyQuota, Rejected: q.rejected.Int64()}
+		if q.day == quotaDay(time.Now()) {
+			o.BytesToday = q.bytes
+		}
+		q.mu.Unlock()
+		all = append(all, o)
+	}
+	return all
+}
+
+func quotaDay(now time.Time) int64 {
+	return int64(now.Year())*1000 + int64(now.YearDay())
+}
+
+func matchKeyQuota(list []*keyQuota, key []byte) *keyQuota {
+	for _, q := range list {
+		if bytes.HasPrefix(key, q.prefix) {
+			return q
+		}
+	}
+	return nil
+}
+
+func (q *keyQuota) allow(n int64, now time.Time) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if sec := now.Unix(); q.sec != sec {
+		q.sec, q.ops = sec, 0
+	}
+	if day := quotaDay(now); q.day != day {
+		q.day, q.bytes = day, 0
+	}
+	if q.Ops > 0 && q.ops >= q.Ops {
+		return false
+	}
+	if q.BytesPerDay > 0 && q.bytes+n > q.BytesPerDay {
+		return false
+	}
+	q.ops++
+	q.bytes += n
+	return true
+}
+
+//检查写命令是否超过配额，返回被拒绝的配额；MSET与MSETNX检查每一个key，其他命令只检查第一个key
+func checkKeyQuota(r *Request) *keyQuota {
+	var list = keyQuotas.Load().([]*keyQuota)
+	if len(list) == 0 || len(r.Multi) < 2 {
+		return nil
+	}
+	var now = time.Now()
+	switch r.OpStr {
+	case "MSET", "MSETNX":
+		for i := 1; i+1 < len(r.Multi); i += 2 {
+			key, value := r.Multi[i].Value, r.Multi[i+1].Value
+			if q := matchKeyQuota(list, key); q != nil && !q.allow(int64(len(key)+len(value)), now) {
+				q.rejected.Incr()
+				return q
+			}
+		}
+	default:
+		if q := matchKeyQuota(list, r.Multi[1].Value); q != nil {
+			var n int64
+			for _, x := range r.Multi[1:] {
+				n += int64(len(x.Value))
+			}
+			if !q.allow(n, now) {
+				q.rejected.Incr()
+				return q
+			}
+		}
+	}
+	return nil
+}
+
+type sliceKeyQuota []*keyQuota
+
+func (s sliceKeyQuota) Len() int {
+	return len(s)
+}
+
+func (s sliceKeyQuota) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceKeyQuota) Less(i, j int) bool {
+	if len(s[i].prefix) != len(s[j].prefix) {
+		return len(s[i].prefix) > len(s[j].prefix)
+	}
+	return s[i].Prefix < s[j].Prefix
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func newQuotaRequest(args ...string) *Request {
+	r := &Request{OpStr: args[0]}
+	for _, arg := range args {
+		r.Multi = append(r.Multi, redis.NewBulkBytes([]byte(arg)))
+	}
+	return r
+}
+
+func TestKeyQuota(x *testing.T) {
+	defer SetKeyQuotas(nil)
+
+	SetKeyQuotas([]*models.KeyQuota{
+		{Prefix: "user:", BytesPerDay: 14},
+		{Prefix: "user:vip:", BytesPerDay: 20},
+	})
+	assert.Must(checkKeyQuota(newQuotaRequest("SET", "user:1", "a")) == nil)
+	assert.Must(checkKeyQuota(newQuotaRequest("SET", "user:2", "a")) == nil)
+	q := checkKeyQuota(newQuotaRequest("SET", "user:3", "a"))
+	assert.Must(q != nil && q.Prefix == "user:")
+	assert.Must(checkKeyQuota(newQuotaRequest("SET", "feed:1", "a")) == nil)
+
+	//匹配最长的前缀
+	assert.Must(checkKeyQuota(newQuotaRequest("SET", "user:vip:1", "0123456789")) == nil)
+	q = checkKeyQuota(newQuotaRequest("MSET", "feed:1", "a", "user:vip:2", "0123456789"))
+	assert.Must(q != nil && q.Prefix == "user:vip:")
+
+	//更新配额时保留计数
+	SetKeyQuotas([]*models.KeyQuota{
+		{Prefix: "user:vip:", BytesPerDay: 40},
+	})
+	status := GetKeyQuotaStatus()
+	assert.Must(len(status) == 1 && status[0].BytesToday == 20 && status[0].Rejected == 1)
+}
+
+func TestKeyQuotaAllow(x *testing.T) {
+	q := &keyQuota{KeyQuota: models.KeyQuota{Prefix: "a", Ops: 1}}
+	now := time.Now()
+	assert.Must(q.allow(1, now) && !q.allow(1, now))
+	assert.Must(q.allow(1, now.Add(time.Second)))
+	assert.Must(q.allow(1, now.Add(time.Hour*24)) && q.bytes == 1)
+}
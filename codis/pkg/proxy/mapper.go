@@ -4,8 +4,6 @@
 package proxy
 
 import (
-	"bytes"
-	"hash/crc32"
 	"strconv"
 	"strings"
 	"sync"
@@ -153,7 +151,7 @@ var opTable = make(map[string]*OpInfo, 256)
 func init() {
 	var cmds = []OpInfo{
 		{"APPEND", FlagWrite, FlagReqKeyValues | FlagRespReturnValuesize, nil},
-		{"ASKING", FlagNotAllow, 0, nil},
+		{"ASKING", 0, 0, nil},
 		{"AUTH", 0, 0, nil},
 		{"BGREWRITEAOF", FlagNotAllow, 0, nil},
 		{"BGSAVE", FlagNotAllow, 0, nil},
@@ -161,10 +159,11 @@ func init() {
 		{"BITFIELD", FlagWrite, 0, nil},
 		{"BITOP", FlagWrite | FlagNotAllow, 0, nil},
 		{"BITPOS", 0, 0, nil},
-		{"BLPOP", FlagWrite | FlagNotAllow, 0, nil},
-		{"BRPOP", FlagWrite | FlagNotAllow, 0, nil},
-		{"BRPOPLPUSH", FlagWrite | FlagNotAllow, 0, nil},
-		{"CLIENT", FlagNotAllow, 0, nil},
+		{"BLMOVE", FlagWrite, 0, nil},
+		{"BLPOP", FlagWrite, 0, nil},
+		{"BRPOP", FlagWrite, 0, nil},
+		{"BRPOPLPUSH", FlagWrite, 0, nil},
+		{"CLIENT", 0, 0, nil},
 		{"CLUSTER", 0, 0, nil},
 		{"COMMAND", 0, 0, nil},
 		{"CONFIG", FlagNotAllow, 0, nil},
@@ -173,12 +172,12 @@ func init() {
 		{"DECR", FlagWrite, 0, nil},
 		{"DECRBY", FlagWrite, 0, nil},
 		{"DEL", FlagWrite, FlagReqKeys, nil},
-		{"DISCARD", FlagNotAllow, 0, nil},
+		{"DISCARD", 0, 0, nil},
 		{"DUMP", 0, 0, nil},
 		{"ECHO", 0, 0, nil},
 		{"EVAL", FlagWrite, 0, nil},
 		{"EVALSHA", FlagWrite, 0, nil},
-		{"EXEC", FlagNotAllow, 0, nil},
+		{"EXEC", FlagMasterOnly, 0, nil},
 		{"EXISTS", 0, 0, nil},
 		{"EXPIRE", FlagWrite, 0, nil},
 		{"EXPIREAT", FlagWrite, 0, nil},
@@ -195,6 +194,7 @@ func init() {
 		{"GETRANGE", 0, 0, nil},
 		{"GETSET", FlagWrite, FlagReqKeyValues | FlagRespReturnSingleValue, nil},
 		{"HDEL", FlagWrite, FlagReqKeyFields, nil},
+		{"HELLO", 0, 0, nil},
 		{"HEXISTS", 0, 0, nil},
 		{"HGET", 0, 0, &CheckHGET{}},
 		{"HGETALL", 0, FlagRespReturnArrayByPair | FlagHighRisk, nil},
@@ -219,6 +219,7 @@ func init() {
 		{"LINDEX", 0, 0, nil},
 		{"LINSERT", FlagWrite, 0, nil},
 		{"LLEN", 0, FlagRespReturnArraysize, nil},
+		{"LMOVE", FlagWrite, 0, nil},
 		{"LPOP", FlagWrite, 0, nil},
 		{"LPUSH", FlagWrite, FlagReqKeyFields | FlagRespReturnArraysize, nil},
 		{"LPUSHX", FlagWrite, FlagReqKeyFields | FlagRespReturnArraysize, nil},
@@ -232,7 +233,7 @@ func init() {
 		{"MOVE", FlagWrite | FlagNotAllow, 0, nil},
 		{"MSET", FlagWrite, FlagReqKeyValues, nil},
 		{"MSETNX", FlagWrite | FlagNotAllow, FlagReqKeyValues, nil},
-		{"MULTI", FlagNotAllow, 0, nil},
+		{"MULTI", 0, 0, nil},
 		{"OBJECT", FlagNotAllow, 0, nil},
 		{"PERSIST", FlagWrite, 0, nil},
 		{"PEXPIRE", FlagWrite, 0, nil},
@@ -244,16 +245,16 @@ func init() {
 		{"PFSELFTEST", 0, 0, nil},
 		{"PING", 0, 0, nil},
 		{"PSETEX", FlagWrite, FlagReqKeyTtlValue, nil},
-		{"PSUBSCRIBE", FlagNotAllow, 0, nil},
+		{"PSUBSCRIBE", 0, 0, nil},
 		{"PSYNC", FlagNotAllow, 0, nil},
 		{"PTTL", 0, 0, nil},
-		{"PUBLISH", FlagNotAllow, 0, nil},
+		{"PUBLISH", FlagMasterOnly, 0, nil},
 		{"PUBSUB", 0, 0, nil},
-		{"PUNSUBSCRIBE", FlagNotAllow, 0, nil},
+		{"PUNSUBSCRIBE", 0, 0, nil},
 		{"QUIT", 0, 0, nil},
 		{"RANDOMKEY", FlagNotAllow, 0, nil},
-		{"READONLY", FlagNotAllow, 0, nil},
-		{"READWRITE", FlagNotAllow, 0, nil},
+		{"READONLY", 0, 0, nil},
+		{"READWRITE", 0, 0, nil},
 		{"RENAME", FlagWrite | FlagNotAllow, 0, nil},
 		{"RENAMENX", FlagWrite | FlagNotAllow, 0, nil},
 		{"REPLCONF", FlagNotAllow, 0, nil},
@@ -266,9 +267,9 @@ func init() {
 		{"RPUSHX", FlagWrite, FlagReqKeyFields | FlagRespReturnArraysize, nil},
 		{"SADD", FlagWrite, FlagReqKeyFields, nil},
 		{"SAVE", FlagNotAllow, 0, nil},
-		{"SCAN", FlagMasterOnly | FlagNotAllow, 0, nil},
+		{"SCAN", FlagMasterOnly, 0, nil},
 		{"SCARD", 0, FlagRespReturnArraysize, nil},
-		{"SCRIPT", FlagNotAllow, 0, nil},
+		{"SCRIPT", FlagMasterOnly, 0, nil},
 		{"SDIFF", 0, FlagReqKeys, &CheckSDIFF{}},
 		{"SDIFFSTORE", FlagWrite, 0, nil},
 		{"SELECT", 0, 0, nil},
@@ -313,22 +314,39 @@ func init() {
 		{"SREM", FlagWrite, FlagReqKeyFields, nil},
 		{"SSCAN", FlagMasterOnly, 0, nil},
 		{"STRLEN", 0, FlagRespReturnValuesize, nil},
-		{"SUBSCRIBE", FlagNotAllow, 0, nil},
+		{"SUBSCRIBE", 0, 0, nil},
 		{"SUBSTR", 0, 0, nil},
 		{"SUNION", 0, FlagReqKeys, &CheckSETCOMPARE{}},
 		{"SUNIONSTORE", FlagWrite, FlagReqKeys, &CheckSETCOMPAREANDSTORE{}},
 		{"SYNC", FlagNotAllow, 0, nil},
 		{"TIME", FlagNotAllow, 0, nil},
-		{"TOUCH", FlagWrite, 0, nil},
+		{"TOUCH", FlagWrite, FlagReqKeys, nil},
 		{"TTL", 0, 0, nil},
 		{"TYPE", 0, 0, nil},
-		{"UNSUBSCRIBE", FlagNotAllow, 0, nil},
-		{"UNWATCH", FlagNotAllow, 0, nil},
-		{"WAIT", FlagNotAllow, 0, nil},
-		{"WATCH", FlagNotAllow, 0, nil},
+		{"UNLINK", FlagWrite, FlagReqKeys, nil},
+		{"UNSUBSCRIBE", 0, 0, nil},
+		{"UNWATCH", 0, 0, nil},
+		{"WAIT", FlagMasterOnly, 0, nil},
+		{"WATCH", 0, 0, nil},
+		{"XACK", FlagWrite, 0, nil},
+		{"XADD", FlagWrite, 0, nil},
+		{"XAUTOCLAIM", FlagWrite, 0, nil},
+		{"XCLAIM", FlagWrite, 0, nil},
+		{"XDEL", FlagWrite, FlagReqKeyFields, nil},
+		{"XGROUP", FlagWrite, 0, nil},
+		{"XINFO", 0, 0, nil},
+		{"XLEN", 0, FlagRespReturnArraysize, nil},
+		{"XPENDING", 0, 0, nil},
+		{"XRANGE", 0, 0, nil},
+		{"XREAD", 0, 0, nil},
+		{"XREADGROUP", FlagWrite, 0, nil},
+		{"XREVRANGE", 0, 0, nil},
+		{"XSETID", FlagWrite, 0, nil},
+		{"XTRIM", FlagWrite, 0, nil},
 		{"XSLOWLOG", 0, 0, nil},
 		{"XMONITOR", 0, 0, nil},
 		{"XCONFIG", 0, 0, nil},
+		{"XDEADLINE", 0, 0, nil},
 		{"ZADD", FlagWrite, 0, nil},  //特殊，因为需要解析，版本较高时接收多种参数
 		{"ZCARD", 0, FlagRespReturnArraysize, nil},
 		{"ZCOUNT", 0, 0, nil},
@@ -636,24 +654,21 @@ func clearMaySlowOpFlag(op string) error {
 	return nil
 }
 
-func Hash(key []byte) uint32 {
-	const (
-		TagBeg = '{'
-		TagEnd = '}'
-	)
-	if beg := bytes.IndexByte(key, TagBeg); beg >= 0 {
-		if end := bytes.IndexByte(key[beg+1:], TagEnd); end >= 0 {
-			key = key[beg+1 : beg+1+end]
-		}
-	}
-	return crc32.ChecksumIEEE(key)
-}
-
 func getHashKey(multi []*redis.Resp, opstr string) []byte {
 	var index = 1
 	switch opstr {
 	case "ZINTERSTORE", "ZUNIONSTORE", "EVAL", "EVALSHA":
 		index = 3
+	case "XGROUP", "XINFO":
+		index = 2
+	case "XREAD", "XREADGROUP":
+		var args = make([][]byte, 0, len(multi))
+		for _, x := range multi[1:] {
+			args = append(args, x.Value)
+		}
+		if keys := parseStreamRead(args).keys; len(keys) != 0 {
+			return keys[0]
+		}
 	}
 	if index < len(multi) {
 		return multi[index].Value
@@ -0,0 +1,202 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//prometheus直方图各个桶的上界，单位ms，包含DelayNumMark中的值
+var HistBucketMark = [...]int64{1, 5, 10, 20, 50, 100, 200, 300, 500, 1000, 2000, 3000}
+
+const HistBucketNum = len(HistBucketMark) + 1
+
+//从proxy启动或ResetStats开始累计，最后一个桶统计超过3s的请求
+type latencyHistogram struct {
+	buckets [HistBucketNum]atomic2.Int64
+}
+
+//duration单位为ns
+func (h *latencyHistogram) incr(duration int64) {
+	var i int
+	for i < len(HistBucketMark) && duration > HistBucketMark[i]*int64(time.Millisecond) {
+		i++
+	}
+	h.buckets[i].Incr()
+}
+
+func (h *latencyHistogram) reset() {
+	for i := range h.buckets {
+		h.buckets[i].Set(0)
+	}
+}
+
+func (h *latencyHistogram) snapshot() []int64 {
+	var b = make([]int64, HistBucketNum)
+	for i := range h.buckets {
+		b[i] = h.buckets[i].Int64()
+	}
+	return b
+}
+
+//按prometheus文本格式输出，同一个指标的样本需要连续输出
+type promWriter struct {
+	b bytes.Buffer
+}
+
+func (w *promWriter) family(name, typ, help string) {
+	fmt.Fprintf(&w.b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w *promWriter) sample(name string, value float64, labels ...string) {
+	w.b.WriteString(name)
+	if len(labels) != 0 {
+		w.b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i != 0 {
+				w.b.WriteByte(',')
+			}
+			fmt.Fprintf(&w.b, "%s=\"%s\"", labels[i], promEscaper.Replace(labels[i+1]))
+		}
+		w.b.WriteByte('}')
+	}
+	w.b.WriteByte(' ')
+	w.b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.b.WriteByte('\n')
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+//延迟统计的单位是ms，输出时转换为秒
+func msToSeconds(ms int64) float64 {
+	return float64(ms) / 1e3
+}
+
+//proxy的统计指标，命令维度的指标同样受metrics_other_qps_threshold控制
+func (s *Proxy) PrometheusMetrics() []byte {
+	var w promWriter
+
+	var online float64
+	if s.IsOnline() {
+		online = 1
+	}
+	w.family("codis_proxy_online", "gauge", "Whether the proxy is online.")
+	w.sample("codis_proxy_online", online)
+
+	w.family("codis_proxy_ops_total", "counter", "Total number of commands.")
+	w.sample("codis_proxy_ops_total", float64(OpTotal()))
+	w.family("codis_proxy_ops_fails_total", "counter", "Total number of failed commands.")
+	w.sample("codis_proxy_ops_fails_total", float64(OpFails()))
+	w.family("codis_proxy_ops_redis_errors_total", "counter", "Total number of error responses from backends.")
+	w.sample("codis_proxy_ops_redis_errors_total", float64(OpRedisErrors()))
+	w.family("codis_proxy_ops_stuck_total", "counter", "Total number of requests failed for being stuck in backends.")
+	w.sample("codis_proxy_ops_stuck_total", float64(OpStuck()))
+	w.family("codis_proxy_ops_split_total", "counter", "Total number of requests split into batches.")
+	w.sample("codis_proxy_ops_split_total", float64(OpSplit()))
+	w.family("codis_proxy_ops_rate_limited_total", "counter", "Total number of requests rejected by rate limits.")
+	w.sample("codis_proxy_ops_rate_limited_total", float64(OpRateLimited()))
+	w.family("codis_proxy_ops_hedged_total", "counter", "Total number of hedged read requests sent to replicas.")
+	w.sample("codis_proxy_ops_hedged_total", float64(GetHedgeStats().Hedged))
+	w.family("codis_proxy_ops_hedge_wins_total", "counter", "Total number of hedged read requests answered before the master.")
+	w.sample("codis_proxy_ops_hedge_wins_total", float64(GetHedgeStats().Wins))
+	w.family("codis_proxy_ops_qps", "gauge", "Commands per second.")
+	w.sample("codis_proxy_ops_qps", float64(OpQPS()))
+
+	w.family("codis_proxy_sessions_total", "counter", "Total number of accepted sessions.")
+	w.sample("codis_proxy_sessions_total", float64(SessionsTotal()))
+	w.family("codis_proxy_sessions_alive", "gauge", "Number of alive sessions.")
+	w.sample("codis_proxy_sessions_alive", float64(SessionsAlive()))
+
+	cmds := aggregateOpStats(GetOpStatsByInterval(1), otherQPSThreshold.Int64())
+
+	w.family("codis_proxy_cmd_calls_total", "counter", "Total number of calls per command.")
+	for _, o := range cmds {
+		w.sample("codis_proxy_cmd_calls_total", float64(o.TotalCalls), "cmd", o.OpStr)
+	}
+	w.family("codis_proxy_cmd_fails_total", "counter", "Total number of failed calls per command.")
+	for _, o := range cmds {
+		w.sample("codis_proxy_cmd_fails_total", float64(o.Fails), "cmd", o.OpStr)
+	}
+	w.family("codis_proxy_cmd_redis_errors_total", "counter", "Total number of error responses per command.")
+	for _, o := range cmds {
+		w.sample("codis_proxy_cmd_redis_errors_total", float64(o.RedisErrType), "cmd", o.OpStr)
+	}
+	w.family("codis_proxy_cmd_qps", "gauge", "Calls per second per command.")
+	for _, o := range cmds {
+		w.sample("codis_proxy_cmd_qps", float64(o.QPS), "cmd", o.OpStr)
+	}
+
+	w.family("codis_proxy_cmd_duration_seconds", "histogram", "Latency from receiving a command to sending its response.")
+	for _, o := range cmds {
+		var count int64
+		for i, n := range o.hist {
+			count += n
+			le := "+Inf"
+			if i < len(HistBucketMark) {
+				le = strconv.FormatFloat(msToSeconds(HistBucketMark[i]), 'g', -1, 64)
+			}
+			w.sample("codis_proxy_cmd_duration_seconds_bucket", float64(count), "cmd", o.OpStr, "le", le)
+		}
+		w.sample("codis_proxy_cmd_duration_seconds_sum", float64(o.TotalUsecs)/1e6, "cmd", o.OpStr)
+		w.sample("codis_proxy_cmd_duration_seconds_count", float64(count), "cmd", o.OpStr)
+	}
+
+	w.family("codis_proxy_cmd_tp_seconds", "gauge", "Latency percentiles of the last second per command.")
+	for _, o := range cmds {
+		for _, tp := range []struct {
+			quantile string
+			value    int64
+		}{
+			{"0.9", o.TP90}, {"0.99", o.TP99}, {"0.999", o.TP999}, {"0.9999", o.TP9999}, {"1", o.TP100},
+		} {
+			w.sample("codis_proxy_cmd_tp_seconds", msToSeconds(tp.value), "cmd", o.OpStr, "quantile", tp.quantile)
+		}
+	}
+
+	w.family("codis_proxy_cmd_slow_calls", "gauge", "Calls slower than the threshold in the last second per command.")
+	for _, o := range cmds {
+		for i, n := range []int64{
+			o.Delay50ms, o.Delay100ms, o.Delay200ms, o.Delay300ms,
+			o.Delay500ms, o.Delay1s, o.Delay2s, o.Delay3s,
+		} {
+			w.sample("codis_proxy_cmd_slow_calls", float64(n), "cmd", o.OpStr, "over", strconv.FormatInt(DelayNumMark[i], 10)+"ms")
+		}
+	}
+
+	pools := GetBackendPoolStats()
+	poolLabels := func(x *BackendPoolStats) []string {
+		return []string{"backend", x.Addr, "replica", strconv.FormatBool(x.Replica)}
+	}
+	w.family("codis_proxy_backend_conns", "gauge", "Number of connections to the backend.")
+	for _, x := range pools {
+		w.sample("codis_proxy_backend_conns", float64(x.Conns), poolLabels(x)...)
+	}
+	w.family("codis_proxy_backend_active_conns", "gauge", "Number of connected connections to the backend.")
+	for _, x := range pools {
+		w.sample("codis_proxy_backend_active_conns", float64(x.Active), poolLabels(x)...)
+	}
+	w.family("codis_proxy_backend_inflight", "gauge", "Requests sent to the backend and waiting for responses.")
+	for _, x := range pools {
+		w.sample("codis_proxy_backend_inflight", float64(x.Inflight), poolLabels(x)...)
+	}
+	w.family("codis_proxy_backend_queue", "gauge", "Requests queued for sending to the backend.")
+	for _, x := range pools {
+		w.sample("codis_proxy_backend_queue", float64(x.Queue), poolLabels(x)...)
+	}
+	w.family("codis_proxy_backend_wait_seconds", "gauge", "Average queue wait of the last second.")
+	for _, x := range pools {
+		w.sample("codis_proxy_backend_wait_seconds", float64(x.WaitUsecs)/1e6, poolLabels(x)...)
+	}
+	w.family("codis_proxy_backend_reconnects_total", "counter", "Total number of reconnections to the backend.")
+	for _, x := range pools {
+		w.sample("codis_proxy_backend_reconnects_total", float64(x.Reconnects), poolLabels(x)...)
+	}
+	return w.b.Bytes()
+}
@@ -0,0 +1,36 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	var h latencyHistogram
+	h.incr(0)
+	h.incr(int64(time.Millisecond))
+	h.incr(int64(time.Millisecond) + 1)
+	h.incr(int64(time.Millisecond * 50))
+	h.incr(int64(time.Second * 5))
+	b := h.snapshot()
+	assert.Must(len(b) == HistBucketNum)
+	assert.Must(b[0] == 2 && b[1] == 1 && b[4] == 1 && b[HistBucketNum-1] == 1)
+	h.reset()
+	assert.Must(h.snapshot()[0] == 0)
+}
+
+func TestPromWriter(t *testing.T) {
+	var w promWriter
+	w.family("codis_x", "gauge", "X.")
+	w.sample("codis_x", 1.5)
+	w.sample("codis_x", 2, "cmd", `A"B\`, "le", "+Inf")
+	expect := "# HELP codis_x X.\n# TYPE codis_x gauge\n" +
+		"codis_x 1.5\n" +
+		"codis_x{cmd=\"A\\\"B\\\\\",le=\"+Inf\"} 2\n"
+	assert.Must(w.b.String() == expect)
+}
@@ -0,0 +1,146 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"plugin"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//请求中间件，可以检查、修改或拒绝请求，以及检查、替换响应
+//OnRequest可以修改命令的参数，但不能修改命令名；返回非nil的响应时直接回复客户端，不再转发
+//OnResponse返回最终发给客户端的响应，不需要修改时返回resp本身
+//两个方法会被多个session并发调用
+type Middleware interface {
+	OnRequest(r *Request, s *Session) *redis.Resp
+	OnResponse(r *Request, s *Session, resp *redis.Resp) *redis.Resp
+}
+
+type MiddlewareFactory func(config *Config) (Middleware, error)
+
+var middlewareRegistry struct {
+	sync.Mutex
+	factory map[string]MiddlewareFactory
+}
+
+//注册中间件，可以在proxy中编译进来，也可以在go plugin的init中调用
+func RegisterMiddleware(name string, factory MiddlewareFactory) {
+	middlewareRegistry.Lock()
+	defer middlewareRegistry.Unlock()
+	if middlewareRegistry.factory == nil {
+		middlewareRegistry.factory = make(map[string]MiddlewareFactory)
+	}
+	if _, ok := middlewareRegistry.factory[name]; ok {
+		log.Panicf("middleware-[%s] already registered", name)
+	}
+	middlewareRegistry.factory[name] = factory
+}
+
+func RegisteredMiddlewares() []string {
+	middlewareRegistry.Lock()
+	defer middlewareRegistry.Unlock()
+	var names []string
+	for name := range middlewareRegistry.factory {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type middleware struct {
+	Middleware
+	name string
+
+	rejected atomic2.Int64
+}
+
+type MiddlewareStatus struct {
+	Name     string `json:"name"`
+	Rejected int64  `json:"rejected"`
+}
+
+//按配置的顺序执行
+var middlewares atomic.Value
+
+func init() {
+	middlewares.Store([]*middleware{})
+}
+
+func splitMiddlewareList(s string) []string {
+	var list []string
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			list = append(list, x)
+		}
+	}
+	return list
+}
+
+//加载proxy_middleware_plugins中的plugin，并按proxy_middlewares创建中间件
+func SetupMiddlewares(config *Config) error {
+	for _, path := range splitMiddlewareList(config.ProxyMiddlewarePlugins) {
+		if _, err := plugin.Open(path); err != nil {
+			return errors.Errorf("load middleware plugin %s failed, %s", path, err)
+		}
+		log.Warnf("load middleware plugin %s", path)
+	}
+
+	middlewareRegistry.Lock()
+	defer middlewareRegistry.Unlock()
+
+	var list []*middleware
+	for _, name := range splitMiddlewareList(config.ProxyMiddlewares) {
+		factory := middlewareRegistry.factory[name]
+		if factory == nil {
+			return errors.Errorf("middleware-[%s] is not registered", name)
+		}
+		m, err := factory(config)
+		if err != nil {
+			return errors.Errorf("create middleware-[%s] failed, %s", name, err)
+		}
+		list = append(list, &middleware{Middleware: m, name: name})
+	}
+	middlewares.Store(list)
+	log.Warnf("setup middlewares = %v", splitMiddlewareList(config.ProxyMiddlewares))
+	return nil
+}
+
+func GetMiddlewareStatus() []*MiddlewareStatus {
+	var list = middlewares.Load().([]*middleware)
+	var all = make([]*MiddlewareStatus, 0, len(list))
+	for _, m := range list {
+		all = append(all, &MiddlewareStatus{Name: m.name, Rejected: m.rejected.Int64()})
+	}
+	return all
+}
+
+//依次执行OnRequest，有中间件拒绝时返回其响应
+func onMiddlewareRequest(r *Request, s *Session) *redis.Resp {
+	for _, m := range middlewares.Load().([]*middleware) {
+		if resp := m.OnRequest(r, s); resp != nil {
+			m.rejected.Incr()
+			return resp
+		}
+	}
+	return nil
+}
+
+//按相反的顺序执行OnResponse
+func onMiddlewareResponse(r *Request, s *Session, resp *redis.Resp) *redis.Resp {
+	var list = middlewares.Load().([]*middleware)
+	for i := len(list) - 1; i >= 0; i-- {
+		if x := list[i].OnResponse(r, s, resp); x != nil {
+			resp = x
+		}
+	}
+	return resp
+}
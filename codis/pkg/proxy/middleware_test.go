@@ -0,0 +1,71 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+type denyMiddleware struct {
+	key string
+}
+
+func (m *denyMiddleware) OnRequest(r *Request, s *Session) *redis.Resp {
+	if len(r.Multi) > 1 && string(r.Multi[1].Value) == m.key {
+		return redis.NewErrorf("ERR key '%s' is denied", m.key)
+	}
+	return nil
+}
+
+func (m *denyMiddleware) OnResponse(r *Request, s *Session, resp *redis.Resp) *redis.Resp {
+	return nil
+}
+
+type tagMiddleware struct{}
+
+func (m *tagMiddleware) OnRequest(r *Request, s *Session) *redis.Resp {
+	return nil
+}
+
+func (m *tagMiddleware) OnResponse(r *Request, s *Session, resp *redis.Resp) *redis.Resp {
+	if resp.IsError() {
+		return redis.NewErrorf("%s (tagged)", resp.Value)
+	}
+	return resp
+}
+
+func init() {
+	RegisterMiddleware("test-deny", func(config *Config) (Middleware, error) {
+		return &denyMiddleware{key: "secret"}, nil
+	})
+	RegisterMiddleware("test-tag", func(config *Config) (Middleware, error) {
+		return &tagMiddleware{}, nil
+	})
+}
+
+func TestMiddleware(x *testing.T) {
+	config := &Config{}
+	defer SetupMiddlewares(config)
+
+	config.ProxyMiddlewares = "test-deny, unknown"
+	assert.Must(SetupMiddlewares(config) != nil)
+
+	config.ProxyMiddlewares = "test-tag,test-deny"
+	assert.MustNoError(SetupMiddlewares(config))
+
+	r := newQuotaRequest("GET", "public")
+	assert.Must(onMiddlewareRequest(r, nil) == nil)
+
+	r = newQuotaRequest("GET", "secret")
+	resp := onMiddlewareRequest(r, nil)
+	assert.Must(resp != nil && resp.IsError())
+	resp = onMiddlewareResponse(r, nil, resp)
+	assert.Must(string(resp.Value) == "ERR key 'secret' is denied (tagged)")
+
+	status := GetMiddlewareStatus()
+	assert.Must(len(status) == 2 && status[0].Name == "test-tag" && status[1].Rejected == 1)
+}
@@ -0,0 +1,221 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+)
+
+//由dashboard下发的租户，session认证后绑定租户名，每个请求按名字查找，租户被删除后请求会被拒绝
+type namespace struct {
+	models.Namespace
+	prefix []byte
+
+	quota *keyQuota
+	stats *namespaceCounters
+}
+
+type NamespaceStatus struct {
+	Name        string `json:"name"`
+	Prefix      string `json:"prefix"`
+	Ops         int64  `json:"ops,omitempty"`
+	BytesPerDay int64  `json:"bytes_per_day,omitempty"`
+	BytesToday  int64  `json:"bytes_today"`
+	Rejected    int64  `json:"rejected"`
+
+	Calls          int64   `json:"calls"`
+	QPS            int64   `json:"qps"`
+	Hits           int64   `json:"hits"`
+	Misses         int64   `json:"misses"`
+	HitRate        float64 `json:"hit_rate"`
+	MemoryEstimate int64   `json:"memory_estimate"`
+	//BytesToday占BytesPerDay的比例
+	BytesUsage float64 `json:"bytes_usage,omitempty"`
+}
+
+type namespaceTable struct {
+	list       []*namespace
+	byName     map[string]*namespace
+	byPassword map[string]*namespace
+}
+
+var namespaces atomic.Value
+
+func init() {
+	namespaces.Store(&namespaceTable{})
+}
+
+//更新租户时保留已有租户的配额计数和统计
+func SetNamespaces(list []*models.Namespace) {
+	var last = namespaces.Load().(*namespaceTable)
+	var t = &namespaceTable{
+		byName:     make(map[string]*namespace),
+		byPassword: make(map[string]*namespace),
+	}
+	for _, x := range list {
+		if x == nil || x.Name == "" || x.Password == "" || x.Prefix == "" {
+			continue
+		}
+		ns := &namespace{Namespace: *x, prefix: []byte(x.Prefix)}
+		if p := last.byName[x.Name]; p != nil && p.Prefix == x.Prefix {
+			ns.quota, ns.stats = p.quota, p.stats
+		} else {
+			ns.quota, ns.stats = &keyQuota{prefix: ns.prefix}, &namespaceCounters{}
+		}
+		ns.quota.mu.Lock()
+		ns.quota.KeyQuota = models.KeyQuota{Prefix: x.Prefix, Ops: x.Ops, BytesPerDay: x.BytesPerDay}
+		ns.quota.mu.Unlock()
+
+		t.list = append(t.list, ns)
+		t.byName[x.Name] = ns
+		t.byPassword[x.Password] = ns
+	}
+	sort.Sort(sliceNamespace(t.list))
+	namespaces.Store(t)
+}
+
+func GetNamespaceStatus() []*NamespaceStatus {
+	var t = namespaces.Load().(*namespaceTable)
+	var all = make([]*NamespaceStatus, 0, len(t.list))
+	for _, ns := range t.list {
+		o := &NamespaceStatus{
+			Name: ns.Name, Prefix: ns.Prefix,
+			Ops: ns.Ops, BytesPerDay: ns.BytesPerDay,
+			Rejected: ns.quota.rejected.Int64(),
+		}
+		ns.quota.mu.Lock()
+		if ns.quota.day == quotaDay(time.Now()) {
+			o.BytesToday = ns.quota.bytes
+		}
+		ns.quota.mu.Unlock()
+		if o.BytesPerDay != 0 {
+			o.BytesUsage = float64(o.BytesToday) / float64(o.BytesPerDay)
+		}
+		ns.stats.snapshot(o)
+		all = append(all, o)
+	}
+	return all
+}
+
+func hasNamespaces() bool {
+	return len(namespaces.Load().(*namespaceTable).list) != 0
+}
+
+func getNamespaceByPassword(password string) *namespace {
+	return namespaces.Load().(*namespaceTable).byPassword[password]
+}
+
+func getNamespace(name string) *namespace {
+	return namespaces.Load().(*namespaceTable).byName[name]
+}
+
+//租户可以执行的没有key的命令
+var namespaceKeylessCommands = map[string]bool{
+	"PING": true, "ECHO": true, "SELECT": true, "CLIENT": true, "XDEADLINE": true, "SCRIPT": true, "WAIT": true,
+	"READONLY": true, "READWRITE": true, "ASKING": true,
+}
+
+//返回请求中所有的key，无法确定key的命令只返回第一个参数，不以租户前缀开头时会被拒绝
+func namespaceKeys(r *Request) [][]byte {
+	var args = make([][]byte, 0, len(r.Multi))
+	for _, x := range r.Multi[1:] {
+		args = append(args, x.Value)
+	}
+	numkeys := func(i int) [][]byte {
+		if i >= len(args) {
+			return args[:1]
+		}
+		n, err := strconv.Atoi(string(args[i]))
+		if err != nil || n < 0 || i+1+n > len(args) {
+			return args[:1]
+		}
+		return args[i+1 : i+1+n]
+	}
+	switch r.OpStr {
+	case "MGET", "DEL", "UNLINK", "EXISTS", "TOUCH", "WATCH",
+		"SDIFF", "SDIFFSTORE", "SINTER", "SINTERSTORE", "SUNION", "SUNIONSTORE",
+		"PFCOUNT", "PFMERGE":
+		return args
+	case "MSET", "MSETNX":
+		var keys [][]byte
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	case "RENAME", "RENAMENX", "RPOPLPUSH", "BRPOPLPUSH", "LMOVE", "BLMOVE", "SMOVE":
+		if len(args) >= 2 {
+			return args[:2]
+		}
+	case "BLPOP", "BRPOP":
+		if len(args) >= 2 {
+			return args[:len(args)-1]
+		}
+	case "BITOP":
+		if len(args) >= 2 {
+			return args[1:]
+		}
+	case "ZINTERSTORE", "ZUNIONSTORE":
+		return append([][]byte{args[0]}, numkeys(1)...)
+	case "EVAL", "EVALSHA":
+		return numkeys(1)
+	case "XGROUP", "XINFO":
+		if len(args) >= 2 {
+			return args[1:2]
+		}
+	case "XREAD", "XREADGROUP":
+		if keys := parseStreamRead(args).keys; len(keys) != 0 {
+			return keys
+		}
+	}
+	return args[:1]
+}
+
+//检查请求的key是否属于租户，以及是否超过租户的配额
+func (ns *namespace) check(r *Request, now time.Time) *redis.Resp {
+	if len(r.Multi) < 2 {
+		if namespaceKeylessCommands[r.OpStr] {
+			return nil
+		}
+		return redis.NewErrorf("ERR command '%s' is not allowed in namespace '%s'", r.OpStr, ns.Name)
+	}
+	if !namespaceKeylessCommands[r.OpStr] {
+		for _, key := range namespaceKeys(r) {
+			if !bytes.HasPrefix(key, ns.prefix) {
+				return redis.NewErrorf("ERR key '%s' is out of namespace '%s'", key, ns.Name)
+			}
+		}
+	}
+	var n int64
+	if !r.OpFlag.IsReadOnly() {
+		for _, x := range r.Multi[1:] {
+			n += int64(len(x.Value))
+		}
+	}
+	if !ns.quota.allow(n, now) {
+		ns.quota.rejected.Incr()
+		return redis.NewErrorf("ERR quota exceeded for namespace '%s'", ns.Name)
+	}
+	return nil
+}
+
+type sliceNamespace []*namespace
+
+func (s sliceNamespace) Len() int {
+	return len(s)
+}
+
+func (s sliceNamespace) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceNamespace) Less(i, j int) bool {
+	return s[i].Name < s[j].Name
+}
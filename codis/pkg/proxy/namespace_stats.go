@@ -0,0 +1,96 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"math"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//租户的统计，更新租户时和配额一起保留
+type namespaceCounters struct {
+	calls  atomic2.Int64
+	hits   atomic2.Int64
+	misses atomic2.Int64
+	//写成功的key和value的字节数之和，不扣除删除和覆盖的数据，是内存占用的上限估算
+	written atomic2.Int64
+
+	qps       atomic2.Int64
+	lastCalls int64
+}
+
+//按响应统计，读命令返回nil计为未命中，MGET按每个key统计
+func (c *namespaceCounters) incr(r *Request, resp *redis.Resp) {
+	c.calls.Incr()
+	if resp == nil || resp.IsError() {
+		return
+	}
+	if !r.OpFlag.IsReadOnly() {
+		var n int64
+		for _, x := range r.Multi[1:] {
+			n += int64(len(x.Value))
+		}
+		c.written.Add(n)
+		return
+	}
+	switch {
+	case resp.IsBulkBytes():
+		if resp.Value == nil {
+			c.misses.Incr()
+		} else {
+			c.hits.Incr()
+		}
+	case resp.IsArray() && r.OpStr == "MGET":
+		for _, x := range resp.Array {
+			if x.IsBulkBytes() && x.Value == nil {
+				c.misses.Incr()
+			} else {
+				c.hits.Incr()
+			}
+		}
+	}
+}
+
+func (s *Session) incrNamespaceStats(r *Request, resp *redis.Resp) {
+	if s.namespace == "" {
+		return
+	}
+	if ns := getNamespace(s.namespace); ns != nil {
+		ns.stats.incr(r, resp)
+	}
+}
+
+//由统计协程定期调用，elapsed为距离上次调用的时间
+func refreshNamespaceStats(elapsed time.Duration) {
+	for _, ns := range namespaces.Load().(*namespaceTable).list {
+		c := ns.stats
+		calls := c.calls.Int64()
+		delta := calls - c.lastCalls
+		c.lastCalls = calls
+		normalized := math.Max(0, float64(delta)) / float64(elapsed) * float64(time.Second)
+		c.qps.Set(int64(normalized + 0.5))
+	}
+}
+
+func resetNamespaceStats() {
+	for _, ns := range namespaces.Load().(*namespaceTable).list {
+		ns.stats.calls.Set(0)
+		ns.stats.hits.Set(0)
+		ns.stats.misses.Set(0)
+	}
+}
+
+func (c *namespaceCounters) snapshot(o *NamespaceStatus) {
+	o.Calls = c.calls.Int64()
+	o.QPS = c.qps.Int64()
+	o.Hits = c.hits.Int64()
+	o.Misses = c.misses.Int64()
+	o.MemoryEstimate = c.written.Int64()
+	if total := o.Hits + o.Misses; total != 0 {
+		o.HitRate = float64(o.Hits) / float64(total)
+	}
+}
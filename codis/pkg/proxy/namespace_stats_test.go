@@ -0,0 +1,48 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestNamespaceStats(x *testing.T) {
+	defer SetNamespaces(nil)
+
+	SetNamespaces([]*models.Namespace{
+		{Name: "tenant-a", Password: "pa", Prefix: "a:", BytesPerDay: 100},
+	})
+	ns := getNamespace("tenant-a")
+
+	r := newQuotaRequest("SET", "a:1", "0123456789")
+	r.OpFlag = FlagWrite
+	assert.Must(ns.check(r, time.Now()) == nil)
+	ns.stats.incr(r, RespOK)
+
+	ns.stats.incr(newQuotaRequest("GET", "a:1"), redis.NewBulkBytes([]byte("0123456789")))
+	ns.stats.incr(newQuotaRequest("GET", "a:2"), redis.NewBulkBytes(nil))
+	ns.stats.incr(newQuotaRequest("MGET", "a:1", "a:2"), redis.NewArray([]*redis.Resp{
+		redis.NewBulkBytes([]byte("0123456789")), redis.NewBulkBytes(nil),
+	}))
+	refreshNamespaceStats(time.Second)
+
+	//更新租户时保留统计
+	SetNamespaces([]*models.Namespace{
+		{Name: "tenant-a", Password: "pa", Prefix: "a:", BytesPerDay: 100},
+	})
+	status := GetNamespaceStatus()
+	assert.Must(len(status) == 1)
+	o := status[0]
+	assert.Must(o.Calls == 4 && o.QPS == 4)
+	assert.Must(o.Hits == 2 && o.Misses == 2 && o.HitRate == 0.5)
+	assert.Must(o.MemoryEstimate == 13 && o.BytesToday == 13 && o.BytesUsage == 0.13)
+
+	resetNamespaceStats()
+	assert.Must(GetNamespaceStatus()[0].Calls == 0)
+}
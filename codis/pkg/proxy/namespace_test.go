@@ -0,0 +1,51 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestNamespace(x *testing.T) {
+	defer SetNamespaces(nil)
+
+	assert.Must(!hasNamespaces())
+	SetNamespaces([]*models.Namespace{
+		{Name: "tenant-a", Password: "pa", Prefix: "a:", Ops: 2},
+		{Name: "tenant-b", Password: "pb", Prefix: "b:"},
+		{Name: "invalid", Password: "", Prefix: "c:"},
+	})
+	assert.Must(hasNamespaces() && len(GetNamespaceStatus()) == 2)
+	assert.Must(getNamespaceByPassword("pa").Name == "tenant-a")
+	assert.Must(getNamespaceByPassword("pc") == nil)
+
+	now := time.Now()
+	ns := getNamespace("tenant-b")
+	assert.Must(ns.check(newQuotaRequest("GET", "b:1"), now) == nil)
+	assert.Must(ns.check(newQuotaRequest("GET", "a:1"), now) != nil)
+	assert.Must(ns.check(newQuotaRequest("MGET", "b:1", "a:1"), now) != nil)
+	assert.Must(ns.check(newQuotaRequest("MSET", "b:1", "a:1"), now) == nil)
+	assert.Must(ns.check(newQuotaRequest("EVAL", "return 1", "1", "b:1", "a:1"), now) == nil)
+	assert.Must(ns.check(newQuotaRequest("EVAL", "return 1", "2", "b:1", "a:1"), now) != nil)
+	assert.Must(ns.check(newQuotaRequest("PING"), now) == nil)
+	assert.Must(ns.check(newQuotaRequest("DBSIZE"), now) != nil)
+	assert.Must(ns.check(newQuotaRequest("INFO", "keyspace"), now) != nil)
+
+	ns = getNamespace("tenant-a")
+	assert.Must(ns.check(newQuotaRequest("GET", "a:1"), now) == nil)
+	assert.Must(ns.check(newQuotaRequest("GET", "a:2"), now) == nil)
+	assert.Must(ns.check(newQuotaRequest("GET", "a:3"), now) != nil)
+
+	//更新租户时保留配额计数
+	SetNamespaces([]*models.Namespace{
+		{Name: "tenant-a", Password: "pa2", Prefix: "a:", Ops: 10},
+	})
+	assert.Must(getNamespaceByPassword("pa") == nil && getNamespace("tenant-b") == nil)
+	status := GetNamespaceStatus()
+	assert.Must(len(status) == 1 && status[0].Rejected == 1 && status[0].Ops == 10)
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//每次抓取cpu profile的时长
+const profileCPUDuration = time.Second * 10
+
+//连续intervals秒tp99超过threshold时触发抓取，两次抓取至少间隔period
+type latencyWatchdog struct {
+	exceeded int
+	captured time.Time
+}
+
+//tp99单位为ms，返回是否需要抓取profile
+func (w *latencyWatchdog) observe(tp99 int64, threshold time.Duration, intervals int, period time.Duration, now time.Time) bool {
+	if threshold <= 0 || time.Duration(tp99)*time.Millisecond <= threshold {
+		w.exceeded = 0
+		return false
+	}
+	w.exceeded++
+	if w.exceeded < intervals {
+		return false
+	}
+	if !w.captured.IsZero() && now.Sub(w.captured) < period {
+		return false
+	}
+	w.exceeded = 0
+	w.captured = now
+	return true
+}
+
+func (s *Proxy) AutoCaptureProfile() {
+	var w latencyWatchdog
+	for !s.IsClosed() {
+		time.Sleep(time.Second)
+		dir, ok := s.checkProfileLatency(&w, time.Now())
+		if !ok {
+			continue
+		}
+		if err := captureProfiles(dir, time.Now()); err != nil {
+			log.WarnErrorf(err, "[%p] capture profiles failed", s)
+		}
+	}
+}
+
+//watchdog只由抓取协程访问，配置和tp99都在各自的锁内读取，与api的修改和统计协程的更新互斥
+func (s *Proxy) checkProfileLatency(w *latencyWatchdog, now time.Time) (string, bool) {
+	config := s.Config()
+	threshold := config.ProfileLatencyThreshold.Duration()
+	intervals := config.ProfileLatencyIntervals
+
+	var tp99 int64
+	if e := getOpStats("ALL", false); e != nil {
+		tp99 = e.delayInfo[0].getTP99()
+	}
+	if !w.observe(tp99, threshold, intervals, config.ProfileCaptureInterval.Duration(), now) {
+		return "", false
+	}
+	log.Warnf("[%p] tp99 of ALL = %dms exceeds %s for %d seconds, capture profiles into %s", s, tp99, threshold, intervals, config.ProfileCaptureDir)
+	return config.ProfileCaptureDir, true
+}
+
+//先保存heap和goroutine的快照，再抓取cpu profile
+func captureProfiles(dir string, now time.Time) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return errors.Trace(err)
+	}
+	prefix := filepath.Join(dir, now.Format("20060102-150405"))
+
+	if err := writeProfile(prefix+"-heap.pprof", "heap", 0); err != nil {
+		return err
+	}
+	if err := writeProfile(prefix+"-goroutine.txt", "goroutine", 2); err != nil {
+		return err
+	}
+
+	f, err := os.Create(prefix + "-cpu.pprof")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+	//通过/debug/pprof抓取cpu profile时会失败
+	if err := pprof.StartCPUProfile(f); err != nil {
+		return errors.Trace(err)
+	}
+	time.Sleep(profileCPUDuration)
+	pprof.StopCPUProfile()
+	return nil
+}
+
+func writeProfile(path, name string, debug int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer f.Close()
+	return errors.Trace(pprof.Lookup(name).WriteTo(f, debug))
+}
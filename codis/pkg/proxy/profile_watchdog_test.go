@@ -0,0 +1,63 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestLatencyWatchdog(x *testing.T) {
+	var w latencyWatchdog
+	var now = time.Now()
+	observe := func(tp99 int64) bool {
+		now = now.Add(time.Second)
+		return w.observe(tp99, time.Millisecond*100, 3, time.Minute, now)
+	}
+
+	assert.Must(!observe(200) && !observe(200))
+	assert.Must(!observe(50))
+	assert.Must(!observe(200) && !observe(200) && observe(200))
+
+	//在间隔内不会再次触发
+	for i := 0; i < 59; i++ {
+		assert.Must(!observe(200))
+	}
+	assert.Must(observe(200))
+
+	assert.Must(!w.observe(1000, 0, 1, 0, now))
+}
+
+func TestProfileLatencyConcurrent(x *testing.T) {
+	config := newProxyConfig()
+	config.ProfileLatencyThreshold.Set(time.Millisecond)
+	config.ProfileLatencyIntervals = 1
+	s := &Proxy{config: config}
+
+	e := getOpStats("ALL", true)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			e.incrOpStats(int64(time.Millisecond*200), 0)
+			e.rollOpStats(time.Now())
+		}
+	}()
+	//抓取协程与统计协程并发读写
+	var w latencyWatchdog
+	for i := 0; i < 100; i++ {
+		s.checkProfileLatency(&w, time.Now())
+	}
+	wg.Wait()
+
+	s.mu.Lock()
+	s.config.ProfileLatencyThreshold = 0
+	s.mu.Unlock()
+	_, ok := s.checkProfileLatency(&w, time.Now())
+	assert.Must(!ok)
+}
@@ -20,11 +20,13 @@ import (
 	"github.com/CodisLabs/codis/pkg/models"
 	"github.com/CodisLabs/codis/pkg/proxy/redis"
 	"github.com/CodisLabs/codis/pkg/utils"
+	"github.com/CodisLabs/codis/pkg/utils/bytesize"
 	"github.com/CodisLabs/codis/pkg/utils/errors"
 	"github.com/CodisLabs/codis/pkg/utils/log"
 	"github.com/CodisLabs/codis/pkg/utils/math2"
 	utilredis "github.com/CodisLabs/codis/pkg/utils/redis"
 	"github.com/CodisLabs/codis/pkg/utils/rpc"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
 	"github.com/CodisLabs/codis/pkg/utils/unsafe2"
 )
 
@@ -51,12 +53,24 @@ type Proxy struct {
 		monitor *utilredis.Sentinel
 		masters map[int]string
 		servers []string
+		options *utilredis.DialOptions
 	}
 	jodis *Jodis
+
+	tracking sync.Once
+	pubsub   *pubsubHub
+	scripts  sync.Once
 }
 
 var ErrClosedProxy = errors.New("use of closed proxy")
 
+//备集群只读模式，由dashboard设置，开启后拒绝所有写命令
+var readOnly atomic2.Bool
+
+func IsReadOnly() bool {
+	return readOnly.Bool()
+}
+
 func New(config *Config) (*Proxy, error) {
 	if err := config.Validate(); err != nil {
 		return nil, errors.Trace(err)
@@ -64,11 +78,27 @@ func New(config *Config) (*Proxy, error) {
 	if err := models.ValidateProduct(config.ProductName); err != nil {
 		return nil, errors.Trace(err)
 	}
+	if err := SetupMiddlewares(config); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := LoadACLFile(config.SessionACLFile); err != nil {
+		return nil, errors.Trace(err)
+	}
+	if err := SetSlotHash(config.SlotHash, config.HashTag); err != nil {
+		return nil, errors.Trace(err)
+	}
 
 	s := &Proxy{}
 	s.config = config
 	s.exit.C = make(chan struct{})
+
+	if path := config.StatsSnapshotFile; path != "" {
+		if err := restoreStatsSnapshotFile(path, config.ProductName); err != nil {
+			log.WarnErrorf(err, "restore stats snapshot %s failed", path)
+		}
+	}
 	s.router = NewRouter(config)
+	s.pubsub = newPubSubHub(s.router, config)
 	s.ignore = make([]byte, config.ProxyHeapPlaceholder.Int64())
 
 	s.model = &models.Proxy{
@@ -76,6 +106,8 @@ func New(config *Config) (*Proxy, error) {
 	}
 	s.model.ProductName = config.ProductName
 	s.model.DataCenter = config.ProxyDataCenter
+	s.model.SlotHash = config.SlotHash
+	s.model.HashTag = config.HashTag
 	s.model.Pid = os.Getpid()
 	s.model.Pwd, _ = os.Getwd()
 	if b, err := exec.Command("uname", "-a").Output(); err != nil {
@@ -97,22 +129,40 @@ func New(config *Config) (*Proxy, error) {
 	go s.serveAdmin()
 	go s.serveProxy()
 	go s.AutoPurgeLog()
+	go s.AutoCaptureProfile()
+	go s.AutoAbortStuck()
+	go s.AutoRefreshBackendPools()
+	go s.AutoRotateHotKeys()
+	go s.AutoSaveStatsSnapshot()
 
 	return s, nil
 }
 
 func (s *Proxy) setup(config *Config) error {
+	options, err := config.SentinelDialOptions()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	s.ha.options = options
+
+	if config.backendTLS, err = config.BackendTLSConfig(); err != nil {
+		return errors.Trace(err)
+	}
+
 	proto := config.ProtoType
-	if l, err := net.Listen(proto, config.ProxyAddr); err != nil {
+	if l, err := listenProxy(config); err != nil {
 		return errors.Trace(err)
 	} else {
 		s.lproxy = l
 
+		if proto == ProtoTypeTLS {
+			proto = "tcp"
+		}
 		x, err := utils.ReplaceUnspecifiedIP(proto, l.Addr().String(), config.HostProxy)
 		if err != nil {
 			return err
 		}
-		s.model.ProtoType = proto
+		s.model.ProtoType = config.ProtoType
 		s.model.ProxyAddr = x
 	}
 
@@ -203,9 +253,15 @@ func (s *Proxy) Close() error {
 	if s.router != nil {
 		s.router.Close()
 	}
+	if s.pubsub != nil {
+		s.pubsub.Close()
+	}
 	if s.ha.monitor != nil {
 		s.ha.monitor.Cancel()
 	}
+	if path := s.config.StatsSnapshotFile; path != "" {
+		s.saveStatsSnapshot(path)
+	}
 	return nil
 }
 
@@ -230,7 +286,28 @@ func (s *Proxy) SetConfig(key, value string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if err := s.setConfig(key, value); err != nil {
+		return err
+	}
+	return s.rewriteConfig()
+}
+
+var ErrInvalidConfigKey = errors.New("invalid key")
+
+func (s *Proxy) setConfig(key, value string) error {
 	switch key {
+	case "log_level":
+		if !log.SetLevelString(value) {
+			return errors.New("invalid log_level")
+		}
+		s.config.LogLevel = value
+
+	case "session_acl_file":
+		if err := LoadACLFile(value); err != nil {
+			return err
+		}
+		s.config.SessionACLFile = value
+
 	case "proxy_max_clients":
 		n, err := strconv.Atoi(value)
 		if err != nil {
@@ -243,6 +320,22 @@ func (s *Proxy) SetConfig(key, value string) error {
 			s.config.ProxyMaxClients = n
 		}
 
+	case "proxy_max_qps", "proxy_max_inflight":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.Errorf("invalid %s", key)
+		}
+		if key == "proxy_max_qps" {
+			ThrottleSetMaxQPS(n)
+			s.config.ProxyMaxQPS = n
+		} else {
+			ThrottleSetMaxInflight(n)
+			s.config.ProxyMaxInflight = n
+		}
+
 	case "proxy_refresh_state_period":
 		p := &(s.config.ProxyRefreshStatePeriod)
 		err :=  p.UnmarshalText([]byte(value))
@@ -320,11 +413,109 @@ func (s *Proxy) SetConfig(key, value string) error {
 		}
 		s.config.ExpireLogDays = intValue
 
+	case "slo_rules":
+		if err := SLOSetRules(value); err != nil {
+			return err
+		}
+		s.config.SLORules = value
+
+	case "backend_replica_balance":
+		if err := ReplicaSetBalance(value); err != nil {
+			return err
+		}
+		s.config.BackendReplicaBalance = value
+
+	case "replica_max_lag":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("invalid replica_max_lag")
+		}
+		ReplicaSetMaxLag(n)
+		s.config.ReplicaMaxLag = n
+
+	case "wait_replicas_rules":
+		if err := WaitSetRules(value); err != nil {
+			return err
+		}
+		s.config.WaitReplicasRules = value
+
+	case "metrics_other_qps_threshold":
+		i64, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if i64 < 0 {
+			return errors.New("invalid metrics_other_qps_threshold")
+		}
+		s.config.MetricsOtherQPSThreshold = i64
+		StatsSetOtherQPSThreshold(i64)
+
+	case "hotkey_sample_rate":
+		i64, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		if i64 < 0 {
+			return errors.New("invalid hotkey_sample_rate")
+		}
+		s.config.HotKeySampleRate = i64
+		HotKeySetOptions(s.config.HotKeySampleRate, s.config.HotKeyTopN)
+
+	case "hotkey_top_n":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if n <= 0 {
+			return errors.New("invalid hotkey_top_n")
+		}
+		s.config.HotKeyTopN = n
+		HotKeySetOptions(s.config.HotKeySampleRate, s.config.HotKeyTopN)
+
+	case "bigkey_threshold":
+		n, err := bytesize.Parse(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("invalid bigkey_threshold")
+		}
+		s.config.BigKeyThreshold = bytesize.Int64(n)
+		BigKeySetOptions(n, s.config.BigKeyMaxPatterns)
+
+	case "client_addr_stats_max":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("invalid client_addr_stats_max")
+		}
+		s.config.ClientAddrStatsMax = n
+		ClientAddrStatsSetMax(n)
+
+	case "script_cache_max":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return errors.New("invalid script_cache_max")
+		}
+		s.config.ScriptCacheMax = n
+		ScriptCacheSetMax(n)
+
 	default:
-		return errors.New("invalid key")
+		if ok, err := setRuntimeConfig(s.config, key, value); err != nil {
+			return err
+		} else if !ok {
+			return ErrInvalidConfigKey
+		}
 	}
-
-	return utils.RewriteConf(*(s.config), s.config.ConfigFileName, "=", true)
+	return nil
 }
 
 func (s *Proxy) ConfigSet(key, value string) *redis.Resp {
@@ -345,6 +536,23 @@ func (s *Proxy) ConfigSet(key, value string) *redis.Resp {
 			return redis.NewString([]byte("OK"))
 		}
 
+	case "proxy_max_qps", "proxy_max_inflight":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return redis.NewErrorf("err：%s.", err)
+		}
+		if n < 0 {
+			return redis.NewErrorf("invalid %s", key)
+		}
+		if key == "proxy_max_qps" {
+			ThrottleSetMaxQPS(n)
+			s.config.ProxyMaxQPS = n
+		} else {
+			ThrottleSetMaxInflight(n)
+			s.config.ProxyMaxInflight = n
+		}
+		return redis.NewString([]byte("OK"))
+
 	case "proxy_refresh_state_period":
 		p := &(s.config.ProxyRefreshStatePeriod)
 		err :=  p.UnmarshalText([]byte(value))
@@ -581,9 +789,45 @@ func (s *Proxy) ConfigSet(key, value string) *redis.Resp {
 		StoreKeyBlackListByBatch(value)
 		s.config.BreakerKeyBlackList = value
 		return redis.NewString([]byte("OK"))
+	case "slo_rules":
+		if err := SLOSetRules(value); err != nil {
+			return redis.NewErrorf("err：%s.", err)
+		}
+		s.config.SLORules = value
+		return redis.NewString([]byte("OK"))
+	case "backend_replica_balance":
+		if err := ReplicaSetBalance(value); err != nil {
+			return redis.NewErrorf("err：%s.", err)
+		}
+		s.config.BackendReplicaBalance = value
+		return redis.NewString([]byte("OK"))
+	case "replica_max_lag":
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return redis.NewErrorf("err：%s.", err)
+		}
+		if n < 0 {
+			return redis.NewErrorf("invalid replica_max_lag")
+		}
+		ReplicaSetMaxLag(n)
+		s.config.ReplicaMaxLag = n
+		return redis.NewString([]byte("OK"))
+	case "metrics_other_qps_threshold":
+		i64, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return redis.NewErrorf("err：%s.", err)
+		}
+		if i64 < 0 {
+			return redis.NewErrorf("invalid metrics_other_qps_threshold")
+		}
+		s.config.MetricsOtherQPSThreshold = i64
+		StatsSetOtherQPSThreshold(i64)
+		return redis.NewString([]byte("OK"))
 	case "*":
 		return redis.NewArray([]*redis.Resp{
 			redis.NewBulkBytes([]byte("proxy_max_clients")),
+			redis.NewBulkBytes([]byte("proxy_max_qps")),
+			redis.NewBulkBytes([]byte("proxy_max_inflight")),
 			redis.NewBulkBytes([]byte("proxy_refresh_state_period")),
 			redis.NewBulkBytes([]byte("backend_primary_quick")),
 			redis.NewBulkBytes([]byte("backend_replica_quick")),
@@ -612,9 +856,18 @@ func (s *Proxy) ConfigSet(key, value string) *redis.Resp {
 			redis.NewBulkBytes([]byte("breaker_key_white_list")),
 			redis.NewBulkBytes([]byte("breaker_key_black_list_enabled")),
 			redis.NewBulkBytes([]byte("breaker_key_black_list")),
+			redis.NewBulkBytes([]byte("slo_rules")),
+			redis.NewBulkBytes([]byte("backend_replica_balance")),
+			redis.NewBulkBytes([]byte("replica_max_lag")),
+			redis.NewBulkBytes([]byte("metrics_other_qps_threshold")),
 		})
 	default:
-		return redis.NewErrorf("unsurport key.")
+		if ok, err := setRuntimeConfig(s.config, key, value); err != nil {
+			return redis.NewErrorf("err：%s.", err)
+		} else if !ok {
+			return redis.NewErrorf("unsurport key.")
+		}
+		return redis.NewString([]byte("OK"))
 	}
 }
 
@@ -625,6 +878,10 @@ func (s *Proxy) ConfigGet(key string) *redis.Resp {
 	switch key {
 	case "proxy_max_clients":
 		return redis.NewBulkBytes([]byte(strconv.Itoa(s.config.ProxyMaxClients)))
+	case "proxy_max_qps":
+		return redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.ProxyMaxQPS, 10)))
+	case "proxy_max_inflight":
+		return redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.ProxyMaxInflight, 10)))
 	case "proxy_refresh_state_period":
 		if text, err := s.config.ProxyRefreshStatePeriod.MarshalText(); err == nil {
 			return redis.NewBulkBytes(text)
@@ -641,6 +898,12 @@ func (s *Proxy) ConfigGet(key string) *redis.Resp {
 		return redis.NewBulkBytes([]byte(strconv.Itoa(s.config.BackendReplicaParallel)))
 	case "backend_replica_quick":
 		return redis.NewBulkBytes([]byte(strconv.Itoa(s.config.BackendReplicaQuick)))
+	case "backend_drain_timeout":
+		if text, err := s.config.BackendDrainTimeout.MarshalText(); err == nil {
+			return redis.NewBulkBytes(text)
+		} else {
+			return redis.NewErrorf("cant get backend_drain_timeout value.")
+		}
 	case "slowlog_log_slower_than":
 		return redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.SlowlogLogSlowerThan,10)))
 	case "slowlog_max_len":
@@ -681,6 +944,14 @@ func (s *Proxy) ConfigGet(key string) *redis.Resp {
 		return redis.NewBulkBytes([]byte(s.config.BreakerKeyWhiteList))
 	case "breaker_key_black_list":
 		return redis.NewBulkBytes([]byte(s.config.BreakerKeyBlackList))
+	case "slo_rules":
+		return redis.NewBulkBytes([]byte(s.config.SLORules))
+	case "backend_replica_balance":
+		return redis.NewBulkBytes([]byte(s.config.BackendReplicaBalance))
+	case "replica_max_lag":
+		return redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.ReplicaMaxLag, 10)))
+	case "metrics_other_qps_threshold":
+		return redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.MetricsOtherQPSThreshold, 10)))
 	case "*":
 		var proxy_refresh_state_period_value string
 		if text, err := s.config.ProxyRefreshStatePeriod.MarshalText(); err == nil {
@@ -691,6 +962,10 @@ func (s *Proxy) ConfigGet(key string) *redis.Resp {
 		return redis.NewArray([]*redis.Resp{
 			redis.NewBulkBytes([]byte("proxy_max_clients")),
 			redis.NewBulkBytes([]byte(strconv.Itoa(s.config.ProxyMaxClients))),
+			redis.NewBulkBytes([]byte("proxy_max_qps")),
+			redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.ProxyMaxQPS, 10))),
+			redis.NewBulkBytes([]byte("proxy_max_inflight")),
+			redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.ProxyMaxInflight, 10))),
 			redis.NewBulkBytes([]byte("proxy_refresh_state_period")),
 			redis.NewBulkBytes([]byte(proxy_refresh_state_period_value)),
 			redis.NewBulkBytes([]byte("backend_primary_only")),
@@ -703,6 +978,10 @@ func (s *Proxy) ConfigGet(key string) *redis.Resp {
 			redis.NewBulkBytes([]byte(strconv.Itoa(s.config.BackendReplicaParallel))),
 			redis.NewBulkBytes([]byte("backend_replica_quick")),
 			redis.NewBulkBytes([]byte(strconv.Itoa(s.config.BackendReplicaQuick))),
+			redis.NewBulkBytes([]byte("backend_replica_balance")),
+			redis.NewBulkBytes([]byte(s.config.BackendReplicaBalance)),
+			redis.NewBulkBytes([]byte("replica_max_lag")),
+			redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.ReplicaMaxLag, 10))),
 			redis.NewBulkBytes([]byte("slowlog_log_slower_than")),
 			redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.SlowlogLogSlowerThan,10))),
 			redis.NewBulkBytes([]byte("slowlog_max_len")),
@@ -743,8 +1022,15 @@ func (s *Proxy) ConfigGet(key string) *redis.Resp {
 			redis.NewBulkBytes([]byte(s.config.BreakerKeyWhiteList)),
 			redis.NewBulkBytes([]byte("breaker_key_black_list")),
 			redis.NewBulkBytes([]byte(s.config.BreakerKeyBlackList)),
+			redis.NewBulkBytes([]byte("slo_rules")),
+			redis.NewBulkBytes([]byte(s.config.SLORules)),
+			redis.NewBulkBytes([]byte("metrics_other_qps_threshold")),
+			redis.NewBulkBytes([]byte(strconv.FormatInt(s.config.MetricsOtherQPSThreshold, 10))),
 		})
 	default:
+		if field := s.config.runtimeField(key); field != nil {
+			return redis.NewBulkBytes([]byte(formatRuntimeField(field)))
+		}
 		return redis.NewErrorf("unsurport key[%s].", key)
 	}
 }
@@ -961,10 +1247,21 @@ func (s *Proxy) ConfigRewrite() *redis.Resp {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	utils.RewriteConf(*(s.config), s.config.ConfigFileName, "=", true)
+	if err := s.rewriteConfig(); err != nil {
+		return redis.NewErrorf("err：%s.", err)
+	}
 	return redis.NewString([]byte("OK"))
 }
 
+//使用了include或环境变量的配置文件由部署系统生成，改写会把展开后的值写回文件
+func (s *Proxy) rewriteConfig() error {
+	if s.config.ConfigTemplated {
+		log.Warnf("[%p] config file %s uses includes or env vars, skip rewriting", s, s.config.ConfigFileName)
+		return nil
+	}
+	return utils.RewriteConf(*(s.config), s.config.ConfigFileName, "=", true)
+}
+
 func (s *Proxy) IsOnline() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -989,6 +1286,12 @@ func (s *Proxy) Slots() []*models.Slot {
 	return s.router.GetSlots()
 }
 
+func (s *Proxy) Routes() []*models.Slot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.router.GetRoutes()
+}
+
 func (s *Proxy) FillSlot(m *models.Slot) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1048,6 +1351,103 @@ func (s *Proxy) SetSentinels(servers []string) error {
 	return nil
 }
 
+func (s *Proxy) SetReadOnly(value bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	readOnly.Set(value)
+	log.Warnf("[%p] set readonly = %t", s, value)
+	return nil
+}
+
+func (s *Proxy) SetKeyQuotas(quotas []*models.KeyQuota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	SetKeyQuotas(quotas)
+	log.Warnf("[%p] set key quotas, total = %d", s, len(quotas))
+	return nil
+}
+
+func (s *Proxy) SetRateLimits(limits []*models.RateLimit) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	if err := SetRateLimits(limits); err != nil {
+		return err
+	}
+	log.Warnf("[%p] set rate limits, total = %d", s, len(limits))
+	return nil
+}
+
+func (s *Proxy) SetTTLRules(rules []*models.TTLRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	SetTTLRules(rules)
+	log.Warnf("[%p] set ttl rules, total = %d", s, len(rules))
+	return nil
+}
+
+func (s *Proxy) SetRequestFilter(script string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	if err := SetRequestFilter(script); err != nil {
+		return err
+	}
+	log.Warnf("[%p] set request filter, script size = %d", s, len(script))
+	return nil
+}
+
+func (s *Proxy) SetNamespaces(list []*models.Namespace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	SetNamespaces(list)
+	log.Warnf("[%p] set namespaces, total = %d", s, len(list))
+	return nil
+}
+
+func (s *Proxy) SetACLUsers(list []*models.ACLUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	if err := SetACLUsers(list); err != nil {
+		return err
+	}
+	log.Warnf("[%p] set acl users, total = %d", s, len(list))
+	return nil
+}
+
+//修改session_acl_file后重新加载
+func (s *Proxy) ReloadACLFile() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedProxy
+	}
+	if err := LoadACLFile(s.config.SessionACLFile); err != nil {
+		return err
+	}
+	log.Warnf("[%p] reload acl file %s", s, s.config.SessionACLFile)
+	return nil
+}
+
 func (s *Proxy) RewatchSentinels() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1068,6 +1468,7 @@ func (s *Proxy) rewatchSentinels(servers []string) {
 	}
 	if len(servers) != 0 {
 		s.ha.monitor = utilredis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+		s.ha.monitor.Options = s.ha.options
 		s.ha.monitor.LogFunc = log.Warnf
 		s.ha.monitor.ErrFunc = log.WarnErrorf
 		go func(p *utilredis.Sentinel) {
@@ -1169,6 +1570,7 @@ func (s *Proxy) serveProxy() {
 
 	if d := s.config.BackendPingPeriod.Duration(); d != 0 {
 		go s.keepAlive(d)
+		go s.refreshReplicaLags(d)
 	}
 
 	//设置命令快慢标志
@@ -1185,6 +1587,19 @@ func (s *Proxy) serveProxy() {
 	StatsSetRefreshPeriod(s.config.ProxyRefreshStatePeriod.Duration())
 	StatsSetLogSlowerThan(s.config.SlowlogLogSlowerThan)
 	StatsSetAutoSetSlowFlag(s.config.AutoSetSlowFlag)
+	SLOSetRules(s.config.SLORules)
+	WaitSetRules(s.config.WaitReplicasRules)
+	ReplicaSetBalance(s.config.BackendReplicaBalance)
+	ReplicaSetMaxLag(s.config.ReplicaMaxLag)
+	ThrottleSetMaxQPS(s.config.ProxyMaxQPS)
+	ThrottleSetMaxInflight(s.config.ProxyMaxInflight)
+	StatsSetOtherQPSThreshold(s.config.MetricsOtherQPSThreshold)
+	StatsSetQuantileHalfLife(s.config.MetricsQuantileHalfLife.Duration())
+	HotKeySetOptions(s.config.HotKeySampleRate, s.config.HotKeyTopN)
+	HotCacheSetOptions(s.config.HotCachePrefixes, s.config.HotCacheTTL.Duration(), s.config.HotCacheMaxKeys, s.config.HotCachePolicy)
+	BigKeySetOptions(s.config.BigKeyThreshold.Int64(), s.config.BigKeyMaxPatterns)
+	ClientAddrStatsSetMax(s.config.ClientAddrStatsMax)
+	ScriptCacheSetMax(s.config.ScriptCacheMax)
 
 	//设置内存慢日志参数
 	XSlowlogSetMaxLen(s.config.SlowlogMaxLen)
@@ -1266,12 +1681,16 @@ type CmdInfo struct {
 }
 
 type Stats struct {
-	Online bool `json:"online"`
-	Closed bool `json:"closed"`
+	Online   bool `json:"online"`
+	Closed   bool `json:"closed"`
+	ReadOnly bool `json:"readonly,omitempty"`
 
 	Sessions struct {
 		Total int64 `json:"total"`
 		Alive int64 `json:"alive"`
+
+		Tracking *TrackingStats `json:"tracking,omitempty"`
+		PubSub   *PubSubStats   `json:"pubsub,omitempty"`
 	} `json:"sessions"`
 
 	Rusage struct {
@@ -1282,9 +1701,22 @@ type Stats struct {
 	} `json:"rusage"`
 
 	Backend struct {
-		PrimaryOnly bool `json:"primary_only"`
+		PrimaryOnly bool          `json:"primary_only"`
+		Replicas    []*ReplicaLag `json:"replicas,omitempty"`
+		//从库延迟过大、读请求回退到主库的次数
+		StaleFallbacks int64 `json:"stale_fallbacks,omitempty"`
+
+		Pools []*BackendPoolStats `json:"pools,omitempty"`
 	} `json:"backend"`
 
+	HotKeys *HotKeys `json:"hotkeys,omitempty"`
+
+	HotCache *HotCacheStats `json:"hotcache,omitempty"`
+
+	Throttle *ThrottleStats `json:"throttle,omitempty"`
+
+	Hedge *HedgeStats `json:"hedge,omitempty"`
+
 	Runtime *RuntimeStats `json:"runtime,omitempty"`
 
 	Sentinels struct {
@@ -1299,8 +1731,17 @@ type Stats struct {
 		Redis struct {
 			Errors int64 `json:"errors"`
 		} `json:"redis"`
-		QPS int64      `json:"qps"`
-		Cmd []*OpStats `json:"cmd,omitempty"`
+		Stuck int64      `json:"stuck"`
+		Split int64      `json:"split"`
+		//被限流拒绝的请求数
+		RateLimited int64 `json:"rate_limited"`
+		QPS   int64      `json:"qps"`
+		Cmd   []*OpStats `json:"cmd,omitempty"`
+		//分页时为命令的总数
+		CmdTotal int `json:"cmd_total,omitempty"`
+
+		Cache   []*CachePrefixStats `json:"cache,omitempty"`
+		Scripts *ScriptStats        `json:"scripts,omitempty"`
 	} `json:"ops"`
 }
 
@@ -1378,6 +1819,7 @@ func (s *Proxy) Stats(flags StatsFlags) *Stats {
 	stats := &Stats{}
 	stats.Online = s.IsOnline()
 	stats.Closed = s.IsClosed()
+	stats.ReadOnly = IsReadOnly()
 
 	servers, masters := s.GetSentinels()
 	if servers != nil {
@@ -1394,15 +1836,28 @@ func (s *Proxy) Stats(flags StatsFlags) *Stats {
 	stats.Ops.Total = OpTotal()
 	stats.Ops.Fails = OpFails()
 	stats.Ops.Redis.Errors = OpRedisErrors()
+	stats.Ops.Stuck = OpStuck()
+	stats.Ops.Split = OpSplit()
+	stats.Ops.RateLimited = OpRateLimited()
 	stats.Ops.QPS = OpQPS()
 
 	//if flags.HasBit(StatsCmds) {
 	//stats.Ops.Cmd = GetOpStatsAll()GetOpStatsByInterval(interval)
 	stats.Ops.Cmd = GetOpStatsByInterval(1)
 	//}
+	stats.Ops.Cache = GetCachePrefixStats()
+	if x := GetScriptStats(); x.Scripts != 0 || x.Fallbacks != 0 {
+		stats.Ops.Scripts = x
+	}
 
 	stats.Sessions.Total = SessionsTotal()
 	stats.Sessions.Alive = SessionsAlive()
+	if t := GetTrackingStats(); t.Sessions != 0 || t.Invalidations != 0 {
+		stats.Sessions.Tracking = t
+	}
+	if p := s.pubsub.Stats(); p.Subscribers != 0 || p.Messages != 0 {
+		stats.Sessions.PubSub = p
+	}
 
 	if u := GetSysUsage(); u != nil {
 		stats.Rusage.Now = u.Now.String()
@@ -1412,6 +1867,21 @@ func (s *Proxy) Stats(flags StatsFlags) *Stats {
 	}
 
 	stats.Backend.PrimaryOnly = s.Config().BackendPrimaryOnly
+	stats.Backend.Replicas = GetReplicaLags()
+	stats.Backend.StaleFallbacks = ReplicaStaleFallbacks()
+	stats.Backend.Pools = GetBackendPoolStats()
+	if s.Config().HotKeySampleRate > 0 {
+		stats.HotKeys = GetHotKeys()
+	}
+	if s.Config().HotCachePrefixes != "" {
+		stats.HotCache = GetHotCacheStats()
+	}
+	if t := GetThrottleStats(); t.MaxQPS != 0 || t.MaxInflight != 0 || t.Delayed != 0 {
+		stats.Throttle = t
+	}
+	if h := GetHedgeStats(); h.Requests != 0 {
+		stats.Hedge = h
+	}
 
 	if flags.HasBit(StatsRuntime) {
 		var r runtime.MemStats
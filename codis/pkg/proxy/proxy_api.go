@@ -8,6 +8,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
 
 	_ "net/http/pprof"
 
@@ -60,6 +61,8 @@ func newApiServer(p *Proxy) http.Handler {
 		http.DefaultServeMux.ServeHTTP(w, req)
 	})
 
+	r.Get("/metrics", api.Metrics)
+
 	r.Group("/proxy", func(r martini.Router) {
 		r.Get("", api.Overview)
 		r.Get("/model", api.Model)
@@ -68,11 +71,25 @@ func newApiServer(p *Proxy) http.Handler {
 	})
 	r.Group("/api/proxy", func(r martini.Router) {
 		r.Get("/model", api.Model)
+		r.Get("/openapi", api.OpenAPI)
 		r.Get("/xping/:xauth", api.XPing)
 		r.Get("/stats/:xauth", api.Stats)
 		r.Get("/stats/:xauth/:flags", api.Stats)
 		r.Get("/cmdinfo/:xauth/:interval", api.CmdInfo)
 		r.Get("/slots/:xauth", api.Slots)
+		r.Get("/routes/:xauth", api.Routes)
+		r.Get("/slotheat/:xauth", api.SlotHeat)
+		r.Get("/tp/:xauth/:opstr/:quantile", api.TP)
+		r.Get("/hotkeys/:xauth", api.HotKeys)
+		r.Get("/bigkeys/:xauth", api.BigKeys)
+		r.Get("/slo/:xauth", api.SLO)
+		r.Get("/clients/:xauth", api.Clients)
+		r.Get("/clientaddrs/:xauth", api.ClientAddrs)
+		r.Get("/clientaddrs/:xauth/:top", api.ClientAddrs)
+		r.Get("/backends/:xauth", api.BackendStats)
+		r.Get("/slowlog/:xauth", api.Slowlog)
+		r.Get("/slowlog/:xauth/:num", api.Slowlog)
+		r.Put("/slowlog/reset/:xauth", api.ResetSlowlog)
 		r.Put("/start/:xauth", api.Start)
 		r.Put("/stats/reset/:xauth", api.ResetStats)
 		r.Put("/forcegc/:xauth", api.ForceGC)
@@ -81,7 +98,28 @@ func newApiServer(p *Proxy) http.Handler {
 		r.Put("/fillslots/:xauth", binding.Json([]*models.Slot{}), api.FillSlots)
 		r.Put("/sentinels/:xauth", binding.Json(models.Sentinel{}), api.SetSentinels)
 		r.Put("/sentinels/:xauth/rewatch", api.RewatchSentinels)
+		r.Put("/readonly/:xauth/:value", api.SetReadOnly)
+		r.Get("/chaos/blackhole/:xauth", api.BackendBlackholes)
+		r.Put("/chaos/blackhole/:xauth/:addr/:secs", api.SetBackendBlackhole)
+		r.Get("/breakers/:xauth", api.CircuitBreakers)
+		r.Put("/breakers/reset/:xauth", api.ResetCircuitBreaker)
+		r.Put("/breakers/reset/:xauth/:addr", api.ResetCircuitBreaker)
+		r.Get("/quotas/:xauth", api.KeyQuotas)
+		r.Put("/quotas/:xauth", binding.Json(models.KeyQuotas{}), api.SetKeyQuotas)
+		r.Get("/ratelimits/:xauth", api.RateLimits)
+		r.Put("/ratelimits/:xauth", binding.Json(models.RateLimits{}), api.SetRateLimits)
+		r.Get("/ttlrules/:xauth", api.TTLRules)
+		r.Get("/middlewares/:xauth", api.Middlewares)
+		r.Get("/namespaces/:xauth", api.Namespaces)
+		r.Put("/namespaces/:xauth", binding.Json(models.Namespaces{}), api.SetNamespaces)
+		r.Get("/acl/:xauth", api.ACLUsers)
+		r.Put("/acl/:xauth", binding.Json(models.ACLUsers{}), api.SetACLUsers)
+		r.Put("/acl/reload/:xauth", api.ReloadACLFile)
+		r.Get("/filter/:xauth", api.RequestFilter)
+		r.Put("/filter/:xauth", binding.Json(models.RequestFilter{}), api.SetRequestFilter)
+		r.Put("/ttlrules/:xauth", binding.Json(models.TTLRules{}), api.SetTTLRules)
 		r.Put("/configset/:xauth/:key/:value", api.SetConfig)
+		r.Put("/reload-config/:xauth", api.ReloadConfig)
 	})
 
 	m.MapTo(r, (*martini.Routes)(nil))
@@ -103,22 +141,97 @@ func (s *apiServer) verifyXAuth(params martini.Params) error {
 	return nil
 }
 
-func (s *apiServer) Overview() (int, string) {
-	return rpc.ApiResponseJson(s.proxy.Overview(StatsFull))
+func (s *apiServer) Overview(req *http.Request) (int, string) {
+	o := s.proxy.Overview(StatsFull)
+	aggregateStatsOps(o.Stats, req)
+	if err := pageOpStats(o.Stats, req); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJsonFields(o, req)
 }
 
 func (s *apiServer) Model() (int, string) {
 	return rpc.ApiResponseJson(s.proxy.Model())
 }
 
-func (s *apiServer) StatsNoXAuth() (int, string) {
-	return rpc.ApiResponseJson(s.proxy.Stats(StatsFull))
+func (s *apiServer) StatsNoXAuth(req *http.Request) (int, string) {
+	stats := s.proxy.Stats(StatsFull)
+	aggregateStatsOps(stats, req)
+	if err := pageOpStats(stats, req); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJsonFields(stats, req)
+}
+
+//请求中带exact参数时返回每个命令的精确统计，否则低QPS的命令合并为OTHER
+func aggregateStatsOps(stats *Stats, req *http.Request) {
+	if req.URL.Query().Get("exact") != "" {
+		return
+	}
+	stats.Ops.Cmd = aggregateOpStats(stats.Ops.Cmd, otherQPSThreshold.Int64())
+}
+
+//按cmd_offset、cmd_limit对命令统计分页
+func pageOpStats(stats *Stats, req *http.Request) error {
+	offset, limit, err := rpc.ParsePage(req, "cmd_")
+	if err != nil {
+		return err
+	}
+	if offset == 0 && limit == 0 {
+		return nil
+	}
+	beg, end := rpc.PageBounds(len(stats.Ops.Cmd), offset, limit)
+	stats.Ops.CmdTotal = len(stats.Ops.Cmd)
+	stats.Ops.Cmd = stats.Ops.Cmd[beg:end]
+	return nil
+}
+
+//prometheus抓取接口，与/proxy/stats一样不需要xauth
+func (s *apiServer) Metrics(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(s.proxy.PrometheusMetrics())
 }
 
 func (s *apiServer) SlotsNoXAuth() (int, string) {
 	return rpc.ApiResponseJson(s.proxy.Slots())
 }
 
+func (s *apiServer) SlotHeat(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(GetSlotHeat())
+}
+
+func (s *apiServer) TP(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	q, err := strconv.ParseFloat(params["quantile"], 64)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	tp, err := GetTP(params["opstr"], q)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(tp)
+}
+
+func (s *apiServer) HotKeys(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(GetHotKeys())
+}
+
+func (s *apiServer) BigKeys(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(GetBigKeys())
+}
+
 func (s *apiServer) XPing(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
@@ -127,7 +240,7 @@ func (s *apiServer) XPing(params martini.Params) (int, string) {
 	}
 }
 
-func (s *apiServer) CmdInfo(params martini.Params) (int, string) {
+func (s *apiServer) CmdInfo(params martini.Params, req *http.Request) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
@@ -139,11 +252,15 @@ func (s *apiServer) CmdInfo(params martini.Params) (int, string) {
 			}
 			interval = int64(n)
 		}
-		return rpc.ApiResponseJson(s.proxy.CmdInfo(interval))
+		cmdInfo := s.proxy.CmdInfo(interval)
+		if req.URL.Query().Get("exact") == "" {
+			cmdInfo.Cmd = aggregateOpStats(cmdInfo.Cmd, otherQPSThreshold.Int64())
+		}
+		return rpc.ApiResponseJson(cmdInfo)
 	}
 }
 
-func (s *apiServer) Stats(params martini.Params) (int, string) {
+func (s *apiServer) Stats(params martini.Params, req *http.Request) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
@@ -155,7 +272,12 @@ func (s *apiServer) Stats(params martini.Params) (int, string) {
 			}
 			flags = StatsFlags(n)
 		}
-		return rpc.ApiResponseJson(s.proxy.Stats(flags))
+		stats := s.proxy.Stats(flags)
+		aggregateStatsOps(stats, req)
+		if err := pageOpStats(stats, req); err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		return rpc.ApiResponseJsonFields(stats, req)
 	}
 }
 
@@ -167,6 +289,77 @@ func (s *apiServer) Slots(params martini.Params) (int, string) {
 	}
 }
 
+func (s *apiServer) Routes(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(s.proxy.Routes())
+}
+
+func (s *apiServer) SLO(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetSLOStatus())
+	}
+}
+
+func (s *apiServer) Clients(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetClientStats())
+	}
+}
+
+func (s *apiServer) ClientAddrs(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	var top int
+	if s := params["top"]; s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		top = n
+	}
+	return rpc.ApiResponseJson(GetClientAddrStats(top))
+}
+
+func (s *apiServer) BackendStats(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(GetBackendStats())
+}
+
+//num默认为10，为0时只返回慢日志数量
+func (s *apiServer) Slowlog(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	var num int64 = 10
+	if s := params["num"]; s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			return rpc.ApiResponseError(errors.Errorf("invalid slowlog number = %s", s))
+		}
+		num = n
+	}
+	x := GetSlowlog(num)
+	x.LogSlowerThan = s.proxy.Config().SlowlogLogSlowerThan
+	return rpc.ApiResponseJson(x)
+}
+
+func (s *apiServer) ResetSlowlog(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	ResetSlowlog()
+	return rpc.ApiResponseJson("OK")
+}
+
 func (s *apiServer) Start(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
@@ -253,6 +446,203 @@ func (s *apiServer) RewatchSentinels(params martini.Params) (int, string) {
 	return rpc.ApiResponseJson("OK")
 }
 
+func (s *apiServer) SetReadOnly(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	n, err := strconv.Atoi(params["value"])
+	if err != nil {
+		return rpc.ApiResponseError(errors.New("invalid readonly value"))
+	}
+	if err := s.proxy.SetReadOnly(n != 0); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) BackendBlackholes(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetBackendBlackholes())
+	}
+}
+
+//secs为0时立即恢复
+func (s *apiServer) SetBackendBlackhole(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	addr := params["addr"]
+	if addr == "" {
+		return rpc.ApiResponseError(errors.New("missing addr"))
+	}
+	secs, err := strconv.Atoi(params["secs"])
+	if err != nil || secs < 0 {
+		return rpc.ApiResponseError(errors.New("invalid secs"))
+	}
+	SetBackendBlackhole(addr, time.Second*time.Duration(secs))
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) CircuitBreakers(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetCircuitBreakers())
+	}
+}
+
+//没有addr时关闭所有后端的熔断器
+func (s *apiServer) ResetCircuitBreaker(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := ResetCircuitBreaker(params["addr"]); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) KeyQuotas(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetKeyQuotaStatus())
+	}
+}
+
+func (s *apiServer) SetKeyQuotas(quotas models.KeyQuotas, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.proxy.SetKeyQuotas(quotas.Quotas); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) RateLimits(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetRateLimitStatus())
+	}
+}
+
+func (s *apiServer) SetRateLimits(limits models.RateLimits, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.proxy.SetRateLimits(limits.Limits); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) TTLRules(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetTTLRuleStatus())
+	}
+}
+
+func (s *apiServer) Middlewares(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetMiddlewareStatus())
+	}
+}
+
+func (s *apiServer) RequestFilter(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetRequestFilterStatus())
+	}
+}
+
+func (s *apiServer) SetRequestFilter(filter models.RequestFilter, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.proxy.SetRequestFilter(filter.Script); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) Namespaces(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetNamespaceStatus())
+	}
+}
+
+func (s *apiServer) SetNamespaces(p models.Namespaces, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.proxy.SetNamespaces(p.Namespaces); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) ACLUsers(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(GetACLUserStatus())
+	}
+}
+
+func (s *apiServer) SetACLUsers(p models.ACLUsers, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.proxy.SetACLUsers(p.Users); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) ReloadACLFile(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.proxy.ReloadACLFile(); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+//与SIGHUP相同，重新读取启动时的配置文件
+func (s *apiServer) ReloadConfig(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	applied, restart, err := s.proxy.ReloadConfig()
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	log.Warnf("[%p] proxy reload config, applied = %v, require restart = %v", s.proxy, applied, restart)
+	return rpc.ApiResponseJson(&ConfigReload{Applied: applied, Restart: restart})
+}
+
+func (s *apiServer) SetTTLRules(rules models.TTLRules, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.proxy.SetTTLRules(rules.Rules); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
 func (s *apiServer) SetConfig(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
@@ -309,6 +699,15 @@ func (c *ApiClient) Model() (*models.Proxy, error) {
 	return model, nil
 }
 
+func (c *ApiClient) OpenAPI() (map[string]interface{}, error) {
+	url := c.encodeURL("/api/proxy/openapi")
+	var spec = make(map[string]interface{})
+	if err := rpc.ApiGetJson(url, &spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
 func (c *ApiClient) XPing() error {
 	url := c.encodeURL("/api/proxy/xping/%s", c.xauth)
 	return rpc.ApiGetJson(url, nil)
@@ -350,6 +749,65 @@ func (c *ApiClient) Slots() ([]*models.Slot, error) {
 	return slots, nil
 }
 
+func (c *ApiClient) Routes() ([]*models.Slot, error) {
+	url := c.encodeURL("/api/proxy/routes/%s", c.xauth)
+	routes := []*models.Slot{}
+	if err := rpc.ApiGetJson(url, &routes); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+func (c *ApiClient) SLO() ([]*SLOStatus, error) {
+	url := c.encodeURL("/api/proxy/slo/%s", c.xauth)
+	slo := []*SLOStatus{}
+	if err := rpc.ApiGetJson(url, &slo); err != nil {
+		return nil, err
+	}
+	return slo, nil
+}
+
+func (c *ApiClient) ClientStats() ([]*ClientStats, error) {
+	url := c.encodeURL("/api/proxy/clients/%s", c.xauth)
+	clients := []*ClientStats{}
+	if err := rpc.ApiGetJson(url, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (c *ApiClient) ClientAddrStats(top int) ([]*ClientAddrStats, error) {
+	url := c.encodeURL("/api/proxy/clientaddrs/%s/%d", c.xauth, top)
+	clients := []*ClientAddrStats{}
+	if err := rpc.ApiGetJson(url, &clients); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+func (c *ApiClient) BackendStats() ([]*BackendStats, error) {
+	url := c.encodeURL("/api/proxy/backends/%s", c.xauth)
+	backends := []*BackendStats{}
+	if err := rpc.ApiGetJson(url, &backends); err != nil {
+		return nil, err
+	}
+	return backends, nil
+}
+
+func (c *ApiClient) Slowlog(num int64) (*Slowlog, error) {
+	url := c.encodeURL("/api/proxy/slowlog/%s/%d", c.xauth, num)
+	slowlog := &Slowlog{}
+	if err := rpc.ApiGetJson(url, slowlog); err != nil {
+		return nil, err
+	}
+	return slowlog, nil
+}
+
+func (c *ApiClient) ResetSlowlog() error {
+	url := c.encodeURL("/api/proxy/slowlog/reset/%s", c.xauth)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
 func (c *ApiClient) ResetStats() error {
 	url := c.encodeURL("/api/proxy/stats/reset/%s", c.xauth)
 	return rpc.ApiPutJson(url, nil, nil)
@@ -375,11 +833,154 @@ func (c *ApiClient) Shutdown() error {
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
+func (c *ApiClient) GetTP(opstr string, quantile float64) (*TPQuantile, error) {
+	url := c.encodeURL("/api/proxy/tp/%s/%s/%s", c.xauth, opstr, strconv.FormatFloat(quantile, 'f', -1, 64))
+	tp := &TPQuantile{}
+	if err := rpc.ApiGetJson(url, tp); err != nil {
+		return nil, err
+	}
+	return tp, nil
+}
+
+func (c *ApiClient) HotKeys() (*HotKeys, error) {
+	url := c.encodeURL("/api/proxy/hotkeys/%s", c.xauth)
+	hotkeys := &HotKeys{}
+	if err := rpc.ApiGetJson(url, hotkeys); err != nil {
+		return nil, err
+	}
+	return hotkeys, nil
+}
+
+func (c *ApiClient) BigKeys() (*BigKeys, error) {
+	url := c.encodeURL("/api/proxy/bigkeys/%s", c.xauth)
+	bigkeys := &BigKeys{}
+	if err := rpc.ApiGetJson(url, bigkeys); err != nil {
+		return nil, err
+	}
+	return bigkeys, nil
+}
+
+func (c *ApiClient) SlotHeat() ([]*SlotHeat, error) {
+	url := c.encodeURL("/api/proxy/slotheat/%s", c.xauth)
+	list := []*SlotHeat{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
 func (c *ApiClient) FillSlots(slots ...*models.Slot) error {
 	url := c.encodeURL("/api/proxy/fillslots/%s", c.xauth)
 	return rpc.ApiPutJson(url, slots, nil)
 }
 
+func (c *ApiClient) KeyQuotas() ([]*KeyQuotaStatus, error) {
+	url := c.encodeURL("/api/proxy/quotas/%s", c.xauth)
+	quotas := []*KeyQuotaStatus{}
+	if err := rpc.ApiGetJson(url, &quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+func (c *ApiClient) SetKeyQuotas(quotas *models.KeyQuotas) error {
+	url := c.encodeURL("/api/proxy/quotas/%s", c.xauth)
+	return rpc.ApiPutJson(url, quotas, nil)
+}
+
+func (c *ApiClient) RateLimits() ([]*RateLimitStatus, error) {
+	url := c.encodeURL("/api/proxy/ratelimits/%s", c.xauth)
+	limits := []*RateLimitStatus{}
+	if err := rpc.ApiGetJson(url, &limits); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+func (c *ApiClient) SetRateLimits(limits *models.RateLimits) error {
+	url := c.encodeURL("/api/proxy/ratelimits/%s", c.xauth)
+	return rpc.ApiPutJson(url, limits, nil)
+}
+
+func (c *ApiClient) TTLRules() ([]*TTLRuleStatus, error) {
+	url := c.encodeURL("/api/proxy/ttlrules/%s", c.xauth)
+	rules := []*TTLRuleStatus{}
+	if err := rpc.ApiGetJson(url, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+func (c *ApiClient) Middlewares() ([]*MiddlewareStatus, error) {
+	url := c.encodeURL("/api/proxy/middlewares/%s", c.xauth)
+	list := []*MiddlewareStatus{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) RequestFilter() (*RequestFilterStatus, error) {
+	url := c.encodeURL("/api/proxy/filter/%s", c.xauth)
+	status := &RequestFilterStatus{}
+	if err := rpc.ApiGetJson(url, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *ApiClient) SetRequestFilter(filter *models.RequestFilter) error {
+	url := c.encodeURL("/api/proxy/filter/%s", c.xauth)
+	return rpc.ApiPutJson(url, filter, nil)
+}
+
+func (c *ApiClient) Namespaces() ([]*NamespaceStatus, error) {
+	url := c.encodeURL("/api/proxy/namespaces/%s", c.xauth)
+	list := []*NamespaceStatus{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) SetNamespaces(p *models.Namespaces) error {
+	url := c.encodeURL("/api/proxy/namespaces/%s", c.xauth)
+	return rpc.ApiPutJson(url, p, nil)
+}
+
+func (c *ApiClient) ACLUsers() ([]*ACLUserStatus, error) {
+	url := c.encodeURL("/api/proxy/acl/%s", c.xauth)
+	list := []*ACLUserStatus{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) SetACLUsers(p *models.ACLUsers) error {
+	url := c.encodeURL("/api/proxy/acl/%s", c.xauth)
+	return rpc.ApiPutJson(url, p, nil)
+}
+
+func (c *ApiClient) ReloadACLFile() error {
+	url := c.encodeURL("/api/proxy/acl/reload/%s", c.xauth)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) ReloadConfig() (*ConfigReload, error) {
+	url := c.encodeURL("/api/proxy/reload-config/%s", c.xauth)
+	reload := &ConfigReload{}
+	if err := rpc.ApiPutJson(url, nil, reload); err != nil {
+		return nil, err
+	}
+	return reload, nil
+}
+
+func (c *ApiClient) SetTTLRules(rules *models.TTLRules) error {
+	url := c.encodeURL("/api/proxy/ttlrules/%s", c.xauth)
+	return rpc.ApiPutJson(url, rules, nil)
+}
+
 func (c *ApiClient) SetSentinels(sentinel *models.Sentinel) error {
 	url := c.encodeURL("/api/proxy/sentinels/%s", c.xauth)
 	return rpc.ApiPutJson(url, sentinel, nil)
@@ -390,6 +991,47 @@ func (c *ApiClient) RewatchSentinels() error {
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
+func (c *ApiClient) SetReadOnly(value bool) error {
+	var n int
+	if value {
+		n = 1
+	}
+	url := c.encodeURL("/api/proxy/readonly/%s/%d", c.xauth, n)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) BackendBlackholes() ([]*BackendBlackhole, error) {
+	url := c.encodeURL("/api/proxy/chaos/blackhole/%s", c.xauth)
+	list := []*BackendBlackhole{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) SetBackendBlackhole(addr string, d time.Duration) error {
+	url := c.encodeURL("/api/proxy/chaos/blackhole/%s/%s/%d", c.xauth, addr, int(d/time.Second))
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) CircuitBreakers() ([]*CircuitBreakerStatus, error) {
+	url := c.encodeURL("/api/proxy/breakers/%s", c.xauth)
+	list := []*CircuitBreakerStatus{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) ResetCircuitBreaker(addr string) error {
+	if addr == "" {
+		url := c.encodeURL("/api/proxy/breakers/reset/%s", c.xauth)
+		return rpc.ApiPutJson(url, nil, nil)
+	}
+	url := c.encodeURL("/api/proxy/breakers/reset/%s/%s", c.xauth, addr)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
 func (c *ApiClient) SetConfig(key, value string) error {
 	url := c.encodeURL("/api/proxy/config/set/%s/%s/%s", c.xauth, key, value)
 	return rpc.ApiPutJson(url, nil, nil)
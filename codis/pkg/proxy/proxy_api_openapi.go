@@ -0,0 +1,66 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"github.com/go-martini/martini"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils"
+	"github.com/CodisLabs/codis/pkg/utils/rpc"
+)
+
+//OpenAPI文档中接口的请求和响应类型，新增使用binding.Json的接口时需要在这里补充请求类型
+var proxyApiOperations = map[string]*rpc.OpenAPIOperation{
+	"GET /proxy":       {Response: Overview{}},
+	"GET /proxy/model": {Response: models.Proxy{}},
+	"GET /proxy/stats": {Response: Stats{}},
+	"GET /proxy/slots": {Response: []*models.Slot{}},
+
+	"GET /api/proxy/model":                      {Response: models.Proxy{}},
+	"GET /api/proxy/stats/:xauth":               {Response: Stats{}},
+	"GET /api/proxy/stats/:xauth/:flags":        {Response: Stats{}},
+	"GET /api/proxy/cmdinfo/:xauth/:interval":   {Response: CmdInfo{}},
+	"GET /api/proxy/slots/:xauth":               {Response: []*models.Slot{}},
+	"GET /api/proxy/routes/:xauth":              {Response: []*models.Slot{}},
+	"GET /api/proxy/slotheat/:xauth":            {Response: []*SlotHeat{}},
+	"GET /api/proxy/hotkeys/:xauth":             {Response: HotKeys{}},
+	"GET /api/proxy/tp/:xauth/:opstr/:quantile": {Response: TPQuantile{}},
+	"GET /api/proxy/bigkeys/:xauth":             {Response: BigKeys{}},
+	"GET /api/proxy/slo/:xauth":                 {Response: []*SLOStatus{}},
+	"GET /api/proxy/clients/:xauth":             {Response: []*ClientStats{}},
+	"GET /api/proxy/clientaddrs/:xauth":         {Response: []*ClientAddrStats{}},
+	"GET /api/proxy/clientaddrs/:xauth/:top":    {Response: []*ClientAddrStats{}},
+	"GET /api/proxy/backends/:xauth":            {Response: []*BackendStats{}},
+	"GET /api/proxy/slowlog/:xauth":             {Response: Slowlog{}},
+	"GET /api/proxy/slowlog/:xauth/:num":        {Response: Slowlog{}},
+	"GET /api/proxy/chaos/blackhole/:xauth":     {Response: []*BackendBlackhole{}},
+	"GET /api/proxy/breakers/:xauth":            {Response: []*CircuitBreakerStatus{}},
+
+	"PUT /api/proxy/fillslots/:xauth":     {Request: []*models.Slot{}},
+	"PUT /api/proxy/sentinels/:xauth":     {Request: models.Sentinel{}},
+	"GET /api/proxy/quotas/:xauth":        {Response: []*KeyQuotaStatus{}},
+	"PUT /api/proxy/quotas/:xauth":        {Request: models.KeyQuotas{}},
+	"GET /api/proxy/ratelimits/:xauth":    {Response: []*RateLimitStatus{}},
+	"PUT /api/proxy/ratelimits/:xauth":    {Request: models.RateLimits{}},
+	"GET /api/proxy/ttlrules/:xauth":      {Response: []*TTLRuleStatus{}},
+	"PUT /api/proxy/ttlrules/:xauth":      {Request: models.TTLRules{}},
+	"GET /api/proxy/middlewares/:xauth":   {Response: []*MiddlewareStatus{}},
+	"GET /api/proxy/namespaces/:xauth":    {Response: []*NamespaceStatus{}},
+	"PUT /api/proxy/namespaces/:xauth":    {Request: models.Namespaces{}},
+	"GET /api/proxy/acl/:xauth":           {Response: []*ACLUserStatus{}},
+	"PUT /api/proxy/acl/:xauth":           {Request: models.ACLUsers{}},
+	"GET /api/proxy/filter/:xauth":        {Response: RequestFilterStatus{}},
+	"PUT /api/proxy/filter/:xauth":        {Request: models.RequestFilter{}},
+	"PUT /api/proxy/reload-config/:xauth": {Response: ConfigReload{}},
+}
+
+//由路由表生成，不需要xauth
+func (s *apiServer) OpenAPI(routes martini.Routes) (int, string) {
+	var list []rpc.OpenAPIRoute
+	for _, r := range routes.All() {
+		list = append(list, rpc.OpenAPIRoute{Method: r.Method(), Pattern: r.Pattern()})
+	}
+	return rpc.ApiResponseJson(rpc.NewOpenAPI("codis-proxy", utils.Version, list, proxyApiOperations))
+}
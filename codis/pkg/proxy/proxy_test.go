@@ -4,6 +4,7 @@
 package proxy
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/CodisLabs/codis/pkg/models"
@@ -59,6 +60,29 @@ func TestStats(x *testing.T) {
 	assert.MustNoError(err2)
 }
 
+func TestOpenAPI(x *testing.T) {
+	s, addr := openProxy()
+	defer s.Close()
+
+	var c = NewApiClient(addr)
+
+	spec, err := c.OpenAPI()
+	assert.MustNoError(err)
+	paths := spec["paths"].(map[string]interface{})
+	for key := range proxyApiOperations {
+		fields := strings.SplitN(key, " ", 2)
+		parts := strings.Split(fields[1], "/")
+		for i, s := range parts {
+			if strings.HasPrefix(s, ":") {
+				parts[i] = "{" + s[1:] + "}"
+			}
+		}
+		item, ok := paths[strings.Join(parts, "/")].(map[string]interface{})
+		assert.Must(ok)
+		assert.Must(item[strings.ToLower(fields[0])] != nil)
+	}
+}
+
 func verifySlots(c *ApiClient, expect map[int]*models.Slot) {
 	slots, err := c.Slots()
 	assert.MustNoError(err)
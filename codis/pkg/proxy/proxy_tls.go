@@ -0,0 +1,102 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	utilredis "github.com/CodisLabs/codis/pkg/utils/redis"
+)
+
+//proto_type为tls时在tcp上监听，model中的proto_type仍然是tls，客户端据此使用TLS连接
+const ProtoTypeTLS = "tls"
+
+const (
+	TLSClientAuthNone    = "none"
+	TLSClientAuthRequest = "request"
+	TLSClientAuthVerify  = "verify"
+)
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.Errorf("invalid ca file %s", path)
+	}
+	return pool, nil
+}
+
+func (c *Config) ProxyTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.ProxyTLSCertFile, c.ProxyTLSKeyFile)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	switch c.ProxyTLSClientAuth {
+	case TLSClientAuthRequest:
+		config.ClientAuth = tls.RequestClientCert
+	case TLSClientAuthVerify:
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if c.ProxyTLSClientCAFile != "" {
+		pool, err := loadCertPool(c.ProxyTLSClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		config.ClientCAs = pool
+	}
+	return config, nil
+}
+
+//未开启backend_tls时返回nil
+func (c *Config) BackendTLSConfig() (*tls.Config, error) {
+	if !c.BackendTLS {
+		return nil, nil
+	}
+	config, err := utilredis.NewTLSConfig(c.BackendTLSCAFile, c.BackendTLSSkipVerify)
+	if err != nil {
+		return nil, err
+	}
+	config.ServerName = c.BackendTLSServerName
+	if c.BackendTLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.BackendTLSCertFile, c.BackendTLSKeyFile)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func listenProxy(config *Config) (net.Listener, error) {
+	if config.ProtoType != ProtoTypeTLS {
+		return net.Listen(config.ProtoType, config.ProxyAddr)
+	}
+	tlsConfig, err := config.ProxyTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	l, err := net.Listen("tcp", config.ProxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(l, tlsConfig), nil
+}
+
+//探测后端状态等使用的临时连接，与BackendConn使用相同的认证和TLS配置
+func (s *Proxy) backendDialOptions() *utilredis.DialOptions {
+	return &utilredis.DialOptions{
+		Password: s.config.ProductAuth, TLSConfig: s.config.backendTLS,
+	}
+}
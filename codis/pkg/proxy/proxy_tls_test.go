@@ -0,0 +1,162 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//生成127.0.0.1的自签名证书，返回证书和私钥文件路径
+func newTestCert(dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.MustNoError(err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "codis-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.MustNoError(err)
+	b, err := x509.MarshalECPrivateKey(key)
+	assert.MustNoError(err)
+
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.MustNoError(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	assert.MustNoError(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: b}), 0600))
+	return certFile, keyFile
+}
+
+func TestTLSConfigValidate(x *testing.T) {
+	c := newProxyConfig()
+	c.ProtoType = ProtoTypeTLS
+	assert.Must(c.Validate() != nil)
+	c.ProxyTLSCertFile, c.ProxyTLSKeyFile = "cert.pem", "key.pem"
+	assert.MustNoError(c.Validate())
+	c.ProxyTLSClientAuth = TLSClientAuthVerify
+	assert.Must(c.Validate() != nil)
+	c.ProxyTLSClientCAFile = "ca.pem"
+	assert.MustNoError(c.Validate())
+	c.ProxyTLSClientAuth = "always"
+	assert.Must(c.Validate() != nil)
+
+	c = newProxyConfig()
+	c.BackendTLSCertFile = "cert.pem"
+	assert.Must(c.Validate() != nil)
+}
+
+func TestProxyTLS(x *testing.T) {
+	dir, err := ioutil.TempDir("", "proxy_tls")
+	assert.MustNoError(err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := newTestCert(dir)
+
+	c := newProxyConfig()
+	c.ProtoType = ProtoTypeTLS
+	c.ProxyAddr = "127.0.0.1:0"
+	c.ProxyTLSCertFile, c.ProxyTLSKeyFile = certFile, keyFile
+	c.ProxyTLSClientAuth = TLSClientAuthVerify
+	c.ProxyTLSClientCAFile = certFile
+
+	s, err := New(c)
+	assert.MustNoError(err)
+	defer s.Close()
+	assert.Must(s.Model().ProtoType == ProtoTypeTLS)
+
+	//没有客户端证书时握手失败
+	pool, err := loadCertPool(certFile)
+	assert.MustNoError(err)
+	conn, err := tls.Dial("tcp", s.Model().ProxyAddr, &tls.Config{RootCAs: pool})
+	if err == nil {
+		conn.SetDeadline(time.Now().Add(time.Second * 3))
+		conn.Write([]byte("PING\r\n"))
+		_, err = bufio.NewReader(conn).ReadString('\n')
+		conn.Close()
+	}
+	assert.Must(err != nil)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.MustNoError(err)
+	conn, err = tls.Dial("tcp", s.Model().ProxyAddr, &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{cert}})
+	assert.MustNoError(err)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second * 3))
+	_, err = conn.Write([]byte("PING\r\n"))
+	assert.MustNoError(err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.MustNoError(err)
+	assert.Must(len(line) != 0)
+}
+
+func TestBackendTLS(x *testing.T) {
+	dir, err := ioutil.TempDir("", "backend_tls")
+	assert.MustNoError(err)
+	defer os.RemoveAll(dir)
+	certFile, keyFile := newTestCert(dir)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.MustNoError(err)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	assert.MustNoError(err)
+	defer l.Close()
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				d := redis.NewDecoder(c)
+				for {
+					if _, err := d.Decode(); err != nil {
+						return
+					}
+					c.Write([]byte("+OK\r\n"))
+				}
+			}()
+		}
+	}()
+
+	c := newProxyConfig()
+	c.BackendTLS = true
+	config, err := c.BackendTLSConfig()
+	assert.MustNoError(err)
+	_, err = redis.DialTLSTimeout(l.Addr().String(), time.Second, 1024, 1024, config)
+	assert.Must(err != nil)
+
+	c.BackendTLSCAFile = certFile
+	config, err = c.BackendTLSConfig()
+	assert.MustNoError(err)
+	conn, err := redis.DialTLSTimeout(l.Addr().String(), time.Second, 1024, 1024, config)
+	assert.MustNoError(err)
+	defer conn.Close()
+	assert.MustNoError(conn.SetKeepAlivePeriod(time.Second))
+	assert.MustNoError(conn.EncodeMultiBulk([]*redis.Resp{redis.NewBulkBytes([]byte("PING"))}, true))
+	resp, err := conn.Decode()
+	assert.MustNoError(err)
+	assert.Must(resp.IsString() && string(resp.Value) == "OK")
+}
@@ -0,0 +1,449 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//频道按名字hash到slot，SUBSCRIBE和PUBLISH都发到该slot的主库；模式需要在所有主库上订阅。
+//每个主库只使用一个订阅连接，多个session订阅同一个频道时只向后端订阅一次
+type pubsubHub struct {
+	mu sync.Mutex
+
+	router *Router
+	config *Config
+
+	channels map[string]*pubsubChannel
+	patterns map[string]map[*Session]bool
+	sessions map[*Session]int
+	conns    map[string]*pubsubConn
+
+	start  sync.Once
+	closed bool
+	exit   chan struct{}
+
+	messages     atomic2.Int64
+	resubscribes atomic2.Int64
+}
+
+type pubsubChannel struct {
+	addr     string
+	sessions map[*Session]bool
+}
+
+//conn在连接成功并订阅完成后才设置，由hub的锁保护
+type pubsubConn struct {
+	addr string
+	conn *redis.Conn
+	stop chan struct{}
+}
+
+type PubSubStats struct {
+	Channels     int   `json:"channels"`
+	Patterns     int   `json:"patterns"`
+	Subscribers  int   `json:"subscribers"`
+	Backends     int   `json:"backends"`
+	Messages     int64 `json:"messages"`
+	Resubscribes int64 `json:"resubscribes"`
+}
+
+var ErrPubSubClosed = errors.New("use of closed pubsub")
+
+func newPubSubHub(router *Router, config *Config) *pubsubHub {
+	return &pubsubHub{
+		router: router, config: config,
+		channels: make(map[string]*pubsubChannel),
+		patterns: make(map[string]map[*Session]bool),
+		sessions: make(map[*Session]int),
+		conns:    make(map[string]*pubsubConn),
+		exit:     make(chan struct{}),
+	}
+}
+
+func (h *pubsubHub) Stats() *PubSubStats {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return &PubSubStats{
+		Channels: len(h.channels), Patterns: len(h.patterns),
+		Subscribers: len(h.sessions), Backends: len(h.conns),
+		Messages: h.messages.Int64(), Resubscribes: h.resubscribes.Int64(),
+	}
+}
+
+func (h *pubsubHub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	close(h.exit)
+	for addr, pc := range h.conns {
+		h.lockedCloseConn(pc)
+		delete(h.conns, addr)
+	}
+}
+
+func (h *pubsubHub) channelAddr(channel string) string {
+	return h.router.slotAddr(int(Hash([]byte(channel)) % MaxSlotNum))
+}
+
+func (h *pubsubHub) Subscribe(s *Session, channel string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrPubSubClosed
+	}
+	h.start.Do(func() {
+		go h.loopSync()
+	})
+	c := h.channels[channel]
+	if c == nil {
+		c = &pubsubChannel{addr: h.channelAddr(channel), sessions: make(map[*Session]bool)}
+		h.channels[channel] = c
+		h.lockedSend(c.addr, "SUBSCRIBE", channel)
+	}
+	if !c.sessions[s] {
+		c.sessions[s] = true
+		h.sessions[s]++
+	}
+	return nil
+}
+
+func (h *pubsubHub) Unsubscribe(s *Session, channel string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c := h.channels[channel]
+	if c == nil || !c.sessions[s] {
+		return
+	}
+	delete(c.sessions, s)
+	h.lockedRelease(s)
+	if len(c.sessions) == 0 {
+		delete(h.channels, channel)
+		h.lockedSend(c.addr, "UNSUBSCRIBE", channel)
+	}
+}
+
+func (h *pubsubHub) PSubscribe(s *Session, pattern string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return ErrPubSubClosed
+	}
+	h.start.Do(func() {
+		go h.loopSync()
+	})
+	m := h.patterns[pattern]
+	if m == nil {
+		m = make(map[*Session]bool)
+		h.patterns[pattern] = m
+		for _, addr := range h.router.MasterAddrs() {
+			h.lockedConn(addr)
+		}
+		for addr := range h.conns {
+			h.lockedSend(addr, "PSUBSCRIBE", pattern)
+		}
+	}
+	if !m[s] {
+		m[s] = true
+		h.sessions[s]++
+	}
+	return nil
+}
+
+func (h *pubsubHub) PUnsubscribe(s *Session, pattern string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m := h.patterns[pattern]
+	if m == nil || !m[s] {
+		return
+	}
+	delete(m, s)
+	h.lockedRelease(s)
+	if len(m) == 0 {
+		delete(h.patterns, pattern)
+		for addr := range h.conns {
+			h.lockedSend(addr, "PUNSUBSCRIBE", pattern)
+		}
+	}
+}
+
+func (h *pubsubHub) lockedRelease(s *Session) {
+	if h.sessions[s]--; h.sessions[s] <= 0 {
+		delete(h.sessions, s)
+	}
+}
+
+func (h *pubsubHub) lockedConn(addr string) *pubsubConn {
+	pc := h.conns[addr]
+	if pc == nil && addr != "" && !h.closed {
+		pc = &pubsubConn{addr: addr, stop: make(chan struct{})}
+		h.conns[addr] = pc
+		go h.runConn(pc)
+	}
+	return pc
+}
+
+func (h *pubsubHub) lockedCloseConn(pc *pubsubConn) {
+	close(pc.stop)
+	if pc.conn != nil {
+		pc.conn.Close()
+		pc.conn = nil
+	}
+}
+
+//还没有连接成功时不需要发送，连接成功后会重新订阅该主库上所有的频道
+func (h *pubsubHub) lockedSend(addr string, args ...string) {
+	pc := h.lockedConn(addr)
+	if pc == nil || pc.conn == nil {
+		return
+	}
+	var multi = make([]*redis.Resp, len(args))
+	for i, arg := range args {
+		multi[i] = redis.NewBulkBytes([]byte(arg))
+	}
+	if err := pc.conn.EncodeMultiBulk(multi, true); err != nil {
+		log.WarnErrorf(err, "pubsub conn to %s, send %s failed", addr, args[0])
+		pc.conn.Close()
+		pc.conn = nil
+	}
+}
+
+//slot迁移或者主从切换后频道所在的主库会变化，需要在新的主库上重新订阅
+func (h *pubsubHub) loopSync() {
+	var ticker = time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.exit:
+			return
+		case <-ticker.C:
+			h.sync()
+		}
+	}
+}
+
+func (h *pubsubHub) sync() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	var used = make(map[string]bool)
+	for channel, c := range h.channels {
+		if addr := h.channelAddr(channel); addr != c.addr {
+			h.lockedSend(c.addr, "UNSUBSCRIBE", channel)
+			c.addr = addr
+			h.lockedSend(c.addr, "SUBSCRIBE", channel)
+			h.resubscribes.Incr()
+		}
+		used[c.addr] = true
+	}
+	if len(h.patterns) != 0 {
+		for _, addr := range h.router.MasterAddrs() {
+			h.lockedConn(addr)
+			used[addr] = true
+		}
+	}
+	for addr, pc := range h.conns {
+		if !used[addr] {
+			h.lockedCloseConn(pc)
+			delete(h.conns, addr)
+		}
+	}
+}
+
+func (h *pubsubHub) runConn(pc *pubsubConn) {
+	for {
+		err := h.serveConn(pc)
+		select {
+		case <-pc.stop:
+			return
+		default:
+		}
+		log.WarnErrorf(err, "pubsub conn to %s failed", pc.addr)
+		select {
+		case <-pc.stop:
+			return
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (h *pubsubHub) serveConn(pc *pubsubConn) error {
+	c, err := redis.DialTLSTimeout(pc.addr, time.Second*5,
+		h.config.BackendRecvBufsize.AsInt(),
+		h.config.BackendSendBufsize.AsInt(), h.config.backendTLS)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	c.WriterTimeout = h.config.BackendSendTimeout.Duration()
+	c.SetKeepAlivePeriod(h.config.BackendKeepAlivePeriod.Duration())
+
+	if auth := h.config.ProductAuth; auth != "" {
+		if err := c.EncodeMultiBulk([]*redis.Resp{
+			redis.NewBulkBytes([]byte("AUTH")), redis.NewBulkBytes([]byte(auth)),
+		}, true); err != nil {
+			return err
+		}
+		resp, err := c.Decode()
+		if err != nil {
+			return err
+		}
+		if resp.IsError() {
+			return errors.Errorf("auth failed: %s", resp.Value)
+		}
+	}
+	if err := h.attachConn(pc, c); err != nil {
+		return err
+	}
+	for {
+		resp, err := c.Decode()
+		if err != nil {
+			h.detachConn(pc, c)
+			return err
+		}
+		if !resp.IsArray() || len(resp.Array) < 3 {
+			continue
+		}
+		switch string(resp.Array[0].Value) {
+		case "message":
+			h.deliver("", string(resp.Array[1].Value), resp.Array[2])
+		case "pmessage":
+			if len(resp.Array) == 4 {
+				h.deliver(string(resp.Array[1].Value), string(resp.Array[2].Value), resp.Array[3])
+			}
+		}
+	}
+}
+
+//订阅该主库上所有的频道和所有模式，之后的变化由lockedSend直接发送
+func (h *pubsubHub) attachConn(pc *pubsubConn, c *redis.Conn) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	select {
+	case <-pc.stop:
+		return ErrPubSubClosed
+	default:
+	}
+	var channels, patterns = []string{"SUBSCRIBE"}, []string{"PSUBSCRIBE"}
+	for channel, x := range h.channels {
+		if x.addr == pc.addr {
+			channels = append(channels, channel)
+		}
+	}
+	for pattern := range h.patterns {
+		patterns = append(patterns, pattern)
+	}
+	for _, args := range [][]string{channels, patterns} {
+		if len(args) == 1 {
+			continue
+		}
+		var multi = make([]*redis.Resp, len(args))
+		for i, arg := range args {
+			multi[i] = redis.NewBulkBytes([]byte(arg))
+		}
+		if err := c.EncodeMultiBulk(multi, true); err != nil {
+			return err
+		}
+	}
+	pc.conn = c
+	return nil
+}
+
+func (h *pubsubHub) detachConn(pc *pubsubConn, c *redis.Conn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if pc.conn == c {
+		pc.conn = nil
+	}
+}
+
+//客户端来不及接收时断开连接，与redis的client-output-buffer-limit类似
+func (h *pubsubHub) deliver(pattern, channel string, payload *redis.Resp) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var resp *redis.Resp
+	var sessions map[*Session]bool
+	if pattern == "" {
+		if c := h.channels[channel]; c != nil {
+			sessions = c.sessions
+		}
+		resp = newPubSubResp("message", channel, payload)
+	} else {
+		sessions = h.patterns[pattern]
+		resp = redis.NewPush([]*redis.Resp{
+			redis.NewBulkBytes([]byte("pmessage")),
+			redis.NewBulkBytes([]byte(pattern)),
+			redis.NewBulkBytes([]byte(channel)),
+			payload,
+		})
+	}
+	for s := range sessions {
+		if n := s.config.SessionMaxPipeline; n != 0 && s.tasks.Buffered() > n {
+			s.CloseWithError(ErrTooManyPipelinedRequests)
+			continue
+		}
+		s.pushMessage(resp)
+		h.messages.Incr()
+	}
+}
+
+//PUBSUB CHANNELS [pattern] | NUMSUB [channel ...] | NUMPAT，只统计通过当前proxy订阅的客户端
+func (h *pubsubHub) handlePubSub(r *Request) {
+	if len(r.Multi) < 2 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'PUBSUB' command")
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch sub := strings.ToUpper(string(r.Multi[1].Value)); {
+	case sub == "CHANNELS" && len(r.Multi) <= 3:
+		var names []string
+		for channel := range h.channels {
+			if len(r.Multi) == 2 || globMatch(string(r.Multi[2].Value), channel) {
+				names = append(names, channel)
+			}
+		}
+		sort.Strings(names)
+		var array = make([]*redis.Resp, len(names))
+		for i, name := range names {
+			array[i] = redis.NewBulkBytes([]byte(name))
+		}
+		r.Resp = redis.NewArray(array)
+	case sub == "NUMSUB":
+		var array []*redis.Resp
+		for _, x := range r.Multi[2:] {
+			var n int
+			if c := h.channels[string(x.Value)]; c != nil {
+				n = len(c.sessions)
+			}
+			array = append(array, redis.NewBulkBytes(x.Value), redis.NewInt([]byte(strconv.Itoa(n))))
+		}
+		r.Resp = redis.NewArray(array)
+	case sub == "NUMPAT" && len(r.Multi) == 2:
+		r.Resp = redis.NewInt([]byte(strconv.Itoa(len(h.patterns))))
+	default:
+		r.Resp = redis.NewErrorf("ERR Unknown PUBSUB subcommand or wrong number of arguments for '%s'", sub)
+	}
+}
+
+//返回slot当前的主库地址，slot下线时返回空
+func (s *Router) slotAddr(id int) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.slots[id].backend.bc.Addr()
+}
@@ -0,0 +1,189 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//只实现订阅相关命令的后端，publish时按频道和模式推送给订阅的连接
+type fakePubSubServer struct {
+	sync.Mutex
+	l     net.Listener
+	conns map[*redis.Conn]map[string]bool
+}
+
+func newFakePubSubServer() *fakePubSubServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	f := &fakePubSubServer{l: l, conns: make(map[*redis.Conn]map[string]bool)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return f
+}
+
+func (f *fakePubSubServer) serve(c *redis.Conn) {
+	defer c.Close()
+	for {
+		multi, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(string(multi[0].Value))
+		f.Lock()
+		subs := f.conns[c]
+		if subs == nil {
+			subs = make(map[string]bool)
+			f.conns[c] = subs
+		}
+		for _, x := range multi[1:] {
+			//模式以p:前缀保存
+			var name = string(x.Value)
+			if strings.HasPrefix(cmd, "P") {
+				name = "p:" + name
+			}
+			subs[name] = strings.HasSuffix(cmd, "SUBSCRIBE") && !strings.Contains(cmd, "UNSUB")
+			c.Encode(redis.NewArray([]*redis.Resp{
+				redis.NewBulkBytes([]byte(strings.ToLower(cmd))), x, redis.NewInt([]byte("1")),
+			}), true)
+		}
+		f.Unlock()
+	}
+}
+
+func (f *fakePubSubServer) subscribed(name string) bool {
+	f.Lock()
+	defer f.Unlock()
+	for _, subs := range f.conns {
+		if subs[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakePubSubServer) publish(channel, message string) {
+	f.Lock()
+	defer f.Unlock()
+	for c, subs := range f.conns {
+		for name, on := range subs {
+			switch {
+			case !on:
+			case name == channel:
+				c.Encode(redis.NewArray([]*redis.Resp{
+					redis.NewBulkBytes([]byte("message")),
+					redis.NewBulkBytes([]byte(channel)), redis.NewBulkBytes([]byte(message)),
+				}), true)
+			case strings.HasPrefix(name, "p:") && globMatch(name[2:], channel):
+				c.Encode(redis.NewArray([]*redis.Resp{
+					redis.NewBulkBytes([]byte("pmessage")), redis.NewBulkBytes([]byte(name[2:])),
+					redis.NewBulkBytes([]byte(channel)), redis.NewBulkBytes([]byte(message)),
+				}), true)
+			}
+		}
+	}
+}
+
+func waitFor(fn func() bool) bool {
+	var deadline = time.Now().Add(time.Second * 5)
+	for !fn() {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+	return true
+}
+
+func newPubSubSession(h *pubsubHub) *Session {
+	s := newTrackingSession(false)
+	s.proxy = &Proxy{pubsub: h}
+	return s
+}
+
+func popMessage(s *Session) *redis.Resp {
+	if !waitFor(func() bool { return !s.tasks.IsEmpty() }) {
+		return nil
+	}
+	r, _ := s.tasks.PopFront()
+	return r.Resp
+}
+
+func TestPubSub(x *testing.T) {
+	a, b := newFakePubSubServer(), newFakePubSubServer()
+	defer a.l.Close()
+	defer b.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("news")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: a.l.Addr().String()}))
+
+	h := newPubSubHub(router, c)
+	defer h.Close()
+	s1, s2 := newPubSubSession(h), newPubSubSession(h)
+
+	r := newACLRequest("SUBSCRIBE", "news", "other")
+	assert.MustNoError(s1.handleSubscribe(r))
+	assert.Must(r.Resp.IsPush() && len(r.Replies) == 1 && string(r.Replies[0].Array[2].Value) == "2")
+	assert.Must(waitFor(func() bool { return a.subscribed("news") }))
+
+	a.publish("news", "hello")
+	m := popMessage(s1)
+	assert.Must(m != nil && string(m.Array[0].Value) == "message" && string(m.Array[2].Value) == "hello")
+
+	r = newACLRequest("PSUBSCRIBE", "n*")
+	assert.MustNoError(s2.handlePSubscribe(r))
+	assert.Must(waitFor(func() bool { return a.subscribed("p:n*") }))
+	a.publish("news", "world")
+	m = popMessage(s2)
+	assert.Must(m != nil && string(m.Array[0].Value) == "pmessage" && string(m.Array[3].Value) == "world")
+	assert.Must(popMessage(s1) != nil)
+
+	r = newACLRequest("PUBSUB", "NUMSUB", "news", "none")
+	assert.MustNoError(s1.handlePubSub(r))
+	assert.Must(string(r.Resp.Array[1].Value) == "1" && string(r.Resp.Array[3].Value) == "0")
+	r = newACLRequest("PUBSUB", "NUMPAT")
+	assert.MustNoError(s1.handlePubSub(r))
+	assert.Must(string(r.Resp.Value) == "1")
+
+	//slot迁移后在新的主库上重新订阅
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: b.l.Addr().String()}))
+	h.sync()
+	assert.Must(waitFor(func() bool { return b.subscribed("news") && b.subscribed("p:n*") }))
+	assert.Must(waitFor(func() bool { return !a.subscribed("news") }))
+	b.publish("news", "again")
+	m = popMessage(s1)
+	assert.Must(m != nil && string(m.Array[2].Value) == "again")
+
+	stats := h.Stats()
+	assert.Must(stats.Channels == 2 && stats.Patterns == 1 && stats.Subscribers == 2 && stats.Resubscribes == 1)
+
+	r = newACLRequest("UNSUBSCRIBE")
+	assert.MustNoError(s1.handleUnsubscribe(r))
+	assert.Must(len(r.Replies) == 1 && s1.numSubscriptions() == 0)
+	r = newACLRequest("UNSUBSCRIBE")
+	assert.MustNoError(s1.handleUnsubscribe(r))
+	assert.Must(r.Resp.Array[1].Value == nil)
+
+	s2.unsubscribePubSub()
+	stats = h.Stats()
+	assert.Must(stats.Channels == 0 && stats.Patterns == 0 && stats.Subscribers == 0)
+}
@@ -0,0 +1,215 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//由管理接口下发的令牌桶限流规则
+type rateLimit struct {
+	models.RateLimit
+
+	limiter *rate.Limiter
+	//Name为*时每个命令、用户或IP使用独立的令牌桶，数量超过maxClientNames后共用limiter
+	buckets struct {
+		sync.Mutex
+		m map[string]*rate.Limiter
+	}
+
+	rejected atomic2.Int64
+}
+
+type RateLimitStatus struct {
+	models.RateLimit
+	Rejected int64 `json:"rejected"`
+}
+
+//按类型和名字索引，名字为*的规则对没有单独规则的命令、用户或IP生效
+type rateLimitTable struct {
+	list []*rateLimit
+	cmd  map[string]*rateLimit
+	user map[string]*rateLimit
+	ip   map[string]*rateLimit
+}
+
+var rateLimits atomic.Value
+
+func init() {
+	rateLimits.Store(&rateLimitTable{})
+}
+
+func ValidateRateLimits(limits []*models.RateLimit) error {
+	var exists = make(map[string]bool)
+	for _, x := range limits {
+		if x == nil {
+			continue
+		}
+		switch x.Type {
+		case "cmd", "user", "ip":
+		default:
+			return errors.Errorf("invalid rate limit type '%s'", x.Type)
+		}
+		switch {
+		case x.Name == "":
+			return errors.Errorf("invalid rate limit name of type '%s'", x.Type)
+		case x.QPS <= 0:
+			return errors.Errorf("invalid rate limit qps of %s '%s'", x.Type, x.Name)
+		case x.Burst < 0:
+			return errors.Errorf("invalid rate limit burst of %s '%s'", x.Type, x.Name)
+		}
+		key := x.Type + ":" + rateLimitName(x)
+		if exists[key] {
+			return errors.Errorf("duplicated rate limit of %s '%s'", x.Type, x.Name)
+		}
+		exists[key] = true
+	}
+	return nil
+}
+
+//命令名不区分大小写
+func rateLimitName(x *models.RateLimit) string {
+	if x.Type == "cmd" {
+		return strings.ToUpper(x.Name)
+	}
+	return x.Name
+}
+
+func newRateLimiter(x *models.RateLimit) *rate.Limiter {
+	var burst = x.Burst
+	if burst == 0 {
+		burst = x.QPS
+	}
+	return rate.NewLimiter(rate.Limit(x.QPS), int(burst))
+}
+
+//更新规则时保留已有规则的拒绝次数，令牌桶重新开始计数
+func SetRateLimits(limits []*models.RateLimit) error {
+	if err := ValidateRateLimits(limits); err != nil {
+		return err
+	}
+	var last = make(map[string]*rateLimit)
+	for _, l := range rateLimits.Load().(*rateLimitTable).list {
+		last[l.Type+":"+l.Name] = l
+	}
+	var t = &rateLimitTable{
+		cmd:  make(map[string]*rateLimit),
+		user: make(map[string]*rateLimit),
+		ip:   make(map[string]*rateLimit),
+	}
+	for _, x := range limits {
+		if x == nil {
+			continue
+		}
+		l := &rateLimit{RateLimit: *x}
+		l.Name = rateLimitName(x)
+		l.limiter = newRateLimiter(x)
+		if p := last[l.Type+":"+l.Name]; p != nil {
+			l.rejected.Set(p.rejected.Int64())
+		}
+		switch l.Type {
+		case "cmd":
+			t.cmd[l.Name] = l
+		case "user":
+			t.user[l.Name] = l
+		case "ip":
+			t.ip[l.Name] = l
+		}
+		t.list = append(t.list, l)
+	}
+	sort.Sort(sliceRateLimit(t.list))
+	rateLimits.Store(t)
+	return nil
+}
+
+func GetRateLimitStatus() []*RateLimitStatus {
+	var list = rateLimits.Load().(*rateLimitTable).list
+	var all = make([]*RateLimitStatus, 0, len(list))
+	for _, l := range list {
+		all = append(all, &RateLimitStatus{RateLimit: l.RateLimit, Rejected: l.rejected.Int64()})
+	}
+	return all
+}
+
+func matchRateLimit(m map[string]*rateLimit, name string) *rateLimit {
+	if l := m[name]; l != nil {
+		return l
+	}
+	return m["*"]
+}
+
+func (l *rateLimit) allow(name string) bool {
+	if l.Name != "*" {
+		return l.limiter.Allow()
+	}
+	l.buckets.Lock()
+	defer l.buckets.Unlock()
+	if l.buckets.m == nil {
+		l.buckets.m = make(map[string]*rate.Limiter)
+	}
+	limiter := l.buckets.m[name]
+	if limiter == nil {
+		if len(l.buckets.m) >= maxClientNames {
+			return l.limiter.Allow()
+		}
+		limiter = newRateLimiter(&l.RateLimit)
+		l.buckets.m[name] = limiter
+	}
+	return limiter.Allow()
+}
+
+//依次检查命令、用户和客户端IP的限流规则，被拒绝时返回-LIMIT错误
+func (s *Session) checkRateLimit(r *Request) *redis.Resp {
+	var t = rateLimits.Load().(*rateLimitTable)
+	if len(t.list) == 0 {
+		return nil
+	}
+	var reject = func(l *rateLimit, name string) *redis.Resp {
+		l.rejected.Incr()
+		incrOpRateLimited()
+		return redis.NewErrorf("LIMIT rate limit exceeded for %s '%s'", l.Type, name)
+	}
+	if l := matchRateLimit(t.cmd, r.OpStr); l != nil && !l.allow(r.OpStr) {
+		return reject(l, r.OpStr)
+	}
+	if s.user != "" {
+		if l := matchRateLimit(t.user, s.user); l != nil && !l.allow(s.user) {
+			return reject(l, s.user)
+		}
+	}
+	if len(t.ip) != 0 {
+		host := clientHost(s.Conn.RemoteAddr())
+		if l := matchRateLimit(t.ip, host); l != nil && !l.allow(host) {
+			return reject(l, host)
+		}
+	}
+	return nil
+}
+
+type sliceRateLimit []*rateLimit
+
+func (s sliceRateLimit) Len() int {
+	return len(s)
+}
+
+func (s sliceRateLimit) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceRateLimit) Less(i, j int) bool {
+	if s[i].Type != s[j].Type {
+		return s[i].Type < s[j].Type
+	}
+	return s[i].Name < s[j].Name
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestRateLimit(x *testing.T) {
+	defer SetRateLimits(nil)
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+	s := newHelloSession("")
+	s.Conn = redis.NewConn(c1, 1024, 1024)
+
+	assert.Must(SetRateLimits([]*models.RateLimit{{Type: "key", Name: "a", QPS: 1}}) != nil)
+	assert.Must(SetRateLimits([]*models.RateLimit{{Type: "cmd", Name: "get", QPS: 0}}) != nil)
+	assert.Must(SetRateLimits([]*models.RateLimit{
+		{Type: "cmd", Name: "get", QPS: 1}, {Type: "cmd", Name: "GET", QPS: 2},
+	}) != nil)
+
+	assert.MustNoError(SetRateLimits([]*models.RateLimit{
+		{Type: "cmd", Name: "get", QPS: 1, Burst: 2},
+		{Type: "user", Name: "*", QPS: 1},
+	}))
+	var limited = OpRateLimited()
+	assert.Must(s.checkRateLimit(newACLRequest("GET", "a")) == nil)
+	assert.Must(s.checkRateLimit(newACLRequest("GET", "a")) == nil)
+	resp := s.checkRateLimit(newACLRequest("GET", "a"))
+	assert.Must(resp != nil && strings.HasPrefix(string(resp.Value), "LIMIT "))
+	assert.Must(s.checkRateLimit(newACLRequest("SET", "a", "1")) == nil)
+	assert.Must(OpRateLimited() == limited+1)
+
+	//每个用户使用独立的令牌桶
+	s.user = "u1"
+	assert.Must(s.checkRateLimit(newACLRequest("SET", "a", "1")) == nil)
+	assert.Must(s.checkRateLimit(newACLRequest("SET", "a", "1")) != nil)
+	s.user = "u2"
+	assert.Must(s.checkRateLimit(newACLRequest("SET", "a", "1")) == nil)
+	s.user = ""
+
+	//更新规则时保留拒绝次数
+	assert.MustNoError(SetRateLimits([]*models.RateLimit{
+		{Type: "cmd", Name: "GET", QPS: 100},
+		{Type: "ip", Name: clientHost(s.Conn.RemoteAddr()), QPS: 1},
+	}))
+	assert.Must(s.checkRateLimit(newACLRequest("SET", "a", "1")) == nil)
+	resp = s.checkRateLimit(newACLRequest("SET", "a", "1"))
+	assert.Must(resp != nil && strings.Contains(string(resp.Value), "for ip"))
+
+	status := GetRateLimitStatus()
+	assert.Must(len(status) == 2 && status[0].Type == "cmd" && status[0].Rejected == 1)
+	assert.Must(status[1].Type == "ip" && status[1].Rejected == 1)
+}
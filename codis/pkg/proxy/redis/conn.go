@@ -4,6 +4,7 @@
 package redis
 
 import (
+	"crypto/tls"
 	"net"
 	"time"
 
@@ -32,6 +33,34 @@ func DialTimeout(addr string, timeout time.Duration, rbuf, wbuf int) (*Conn, err
 	return NewConn(c, rbuf, wbuf), nil
 }
 
+//config为nil时使用普通的tcp连接
+func DialTLSTimeout(addr string, timeout time.Duration, rbuf, wbuf int, config *tls.Config) (*Conn, error) {
+	if config == nil {
+		return DialTimeout(addr, timeout, rbuf, wbuf)
+	}
+	c, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			c.Close()
+			return nil, errors.Trace(err)
+		}
+		config.ServerName = host
+	}
+	t := tls.Client(c, config)
+	t.SetDeadline(time.Now().Add(timeout))
+	if err := t.Handshake(); err != nil {
+		c.Close()
+		return nil, errors.Trace(err)
+	}
+	t.SetDeadline(time.Time{})
+	return NewConn(t, rbuf, wbuf), nil
+}
+
 func NewConn(sock net.Conn, rbuf, wbuf int) *Conn {
 	conn := &Conn{Sock: sock}
 	conn.Decoder = newConnDecoder(conn, rbuf)
@@ -51,15 +80,23 @@ func (c *Conn) Close() error {
 	return c.Sock.Close()
 }
 
+//TLS连接返回底层的tcp连接
+func (c *Conn) netConn() net.Conn {
+	if t, ok := c.Sock.(*tls.Conn); ok {
+		return t.NetConn()
+	}
+	return c.Sock
+}
+
 func (c *Conn) CloseReader() error {
-	if t, ok := c.Sock.(*net.TCPConn); ok {
+	if t, ok := c.netConn().(*net.TCPConn); ok {
 		return t.CloseRead()
 	}
 	return c.Close()
 }
 
 func (c *Conn) SetKeepAlivePeriod(d time.Duration) error {
-	if t, ok := c.Sock.(*net.TCPConn); ok {
+	if t, ok := c.netConn().(*net.TCPConn); ok {
 		if err := t.SetKeepAlive(d != 0); err != nil {
 			return errors.Trace(err)
 		}
@@ -131,10 +131,70 @@ func (d *Decoder) decodeResp() (*Resp, error) {
 		r.Value, err = d.decodeBulkBytes()
 	case TypeArray:
 		r.Array, err = d.decodeArray()
+	case TypeDouble, TypeBoolean, TypeBigNumber:
+		r.Value, err = d.decodeTextBytes()
+	case TypeBlobError, TypeVerbatim:
+		r.Value, err = d.decodeBlobBytes()
+	case TypeNull:
+		var b []byte
+		if b, err = d.decodeTextBytes(); err == nil && len(b) != 0 {
+			err = errors.Errorf("bad null resp, %q", b)
+		}
+	case TypeSet, TypePush:
+		r.Array, err = d.decodeAggregate(1)
+	case TypeMap:
+		r.Array, err = d.decodeAggregate(2)
+	case TypeAttribute:
+		r.Array, err = d.decodeAttribute()
 	}
 	return r, err
 }
 
+//与bulk bytes不同，不允许-1
+func (d *Decoder) decodeBlobBytes() ([]byte, error) {
+	b, err := d.decodeBulkBytes()
+	if err == nil && b == nil {
+		return nil, errors.Trace(ErrBadBulkBytesLen)
+	}
+	return b, err
+}
+
+//map的长度是key-value的对数
+func (d *Decoder) decodeAggregate(m int64) ([]*Resp, error) {
+	n, err := d.decodeInt()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case n < 0:
+		return nil, errors.Trace(ErrBadArrayLen)
+	case n*m > MaxArrayLen:
+		return nil, errors.Trace(ErrBadArrayLenTooLong)
+	}
+	array := make([]*Resp, n*m)
+	for i := range array {
+		r, err := d.decodeResp()
+		if err != nil {
+			return nil, err
+		}
+		array[i] = r
+	}
+	return array, nil
+}
+
+//attribute之后紧跟着真正的响应，一起作为一个Resp返回
+func (d *Decoder) decodeAttribute() ([]*Resp, error) {
+	array, err := d.decodeAggregate(2)
+	if err != nil {
+		return nil, err
+	}
+	r, err := d.decodeResp()
+	if err != nil {
+		return nil, err
+	}
+	return append(array, r), nil
+}
+
 func (d *Decoder) decodeTextBytes() ([]byte, error) {
 	b, err := d.br.ReadBytes('\n')
 	if err != nil {
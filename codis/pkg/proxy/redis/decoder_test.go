@@ -154,3 +154,38 @@ func BenchmarkDecode4K(b *testing.B)   { benchmarkDecode(b, 1024*4) }
 func BenchmarkDecode16K(b *testing.B)  { benchmarkDecode(b, 1024*16) }
 func BenchmarkDecode32K(b *testing.B)  { benchmarkDecode(b, 1024*32) }
 func BenchmarkDecode128K(b *testing.B) { benchmarkDecode(b, 1024*128) }
+
+func TestDecodeRESP3(t *testing.T) {
+	test := map[string]RespType{
+		"_\r\n":                                TypeNull,
+		",3.14\r\n":                            TypeDouble,
+		"#t\r\n":                               TypeBoolean,
+		"!21\r\nSYNTAX invalid syntax\r\n":     TypeBlobError,
+		"=15\r\ntxt:Some string\r\n":           TypeVerbatim,
+		"(3492890328409238509324850943850\r\n": TypeBigNumber,
+		"%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n":       TypeMap,
+		"~2\r\n+a\r\n+b\r\n":                            TypeSet,
+		">3\r\n$7\r\nmessage\r\n$1\r\nc\r\n$1\r\nm\r\n": TypePush,
+		"|1\r\n+ttl\r\n:3600\r\n$1\r\nv\r\n":            TypeAttribute,
+	}
+	for s, typ := range test {
+		resp, err := DecodeFromBytes([]byte(s))
+		assert.MustNoError(err)
+		assert.Must(resp.Type == typ)
+		b, err := EncodeToBytes(resp)
+		assert.MustNoError(err)
+		assert.Must(string(b) == s)
+	}
+
+	resp, err := DecodeFromBytes([]byte("%2\r\n+first\r\n:1\r\n+second\r\n:2\r\n"))
+	assert.MustNoError(err)
+	assert.Must(len(resp.Array) == 4)
+	resp, err = DecodeFromBytes([]byte("|1\r\n+ttl\r\n:3600\r\n$1\r\nv\r\n"))
+	assert.MustNoError(err)
+	assert.Must(len(resp.Array) == 3 && string(resp.Array[2].Value) == "v")
+
+	for _, s := range []string{"_x\r\n", "!-1\r\n", "%1\r\n+a\r\n", "~-1\r\n", "|1\r\n+a\r\n:1\r\n"} {
+		_, err := DecodeFromBytes([]byte(s))
+		assert.Must(err != nil)
+	}
+}
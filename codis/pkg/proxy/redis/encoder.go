@@ -122,9 +122,43 @@ func (e *Encoder) encodeResp(r *Resp) error {
 		return e.encodeBulkBytes(r.Value)
 	case TypeArray:
 		return e.encodeArray(r.Array)
+	case TypeDouble, TypeBoolean, TypeBigNumber:
+		return e.encodeTextBytes(r.Value)
+	case TypeBlobError, TypeVerbatim:
+		return e.encodeBulkBytes(r.Value)
+	case TypeNull:
+		return e.encodeTextString("")
+	case TypeSet, TypePush:
+		return e.encodeAggregate(r.Array, 1)
+	case TypeMap:
+		return e.encodeAggregate(r.Array, 2)
+	case TypeAttribute:
+		if len(r.Array)%2 != 1 {
+			return errors.Errorf("bad attribute len %d", len(r.Array))
+		}
+		n := len(r.Array) - 1
+		if err := e.encodeAggregate(r.Array[:n], 2); err != nil {
+			return err
+		}
+		return e.encodeResp(r.Array[n])
 	}
 }
 
+func (e *Encoder) encodeAggregate(array []*Resp, m int) error {
+	if len(array)%m != 0 {
+		return errors.Errorf("bad aggregate len %d", len(array))
+	}
+	if err := e.encodeInt(int64(len(array) / m)); err != nil {
+		return err
+	}
+	for _, r := range array {
+		if err := e.encodeResp(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (e *Encoder) encodeMultiBulk(multi []*Resp) error {
 	if err := e.bw.WriteByte(byte(TypeArray)); err != nil {
 		return errors.Trace(err)
@@ -107,3 +107,34 @@ func BenchmarkEncode4K(b *testing.B)   { benchmarkEncode(b, 1024*4) }
 func BenchmarkEncode16K(b *testing.B)  { benchmarkEncode(b, 1024*16) }
 func BenchmarkEncode32K(b *testing.B)  { benchmarkEncode(b, 1024*32) }
 func BenchmarkEncode128K(b *testing.B) { benchmarkEncode(b, 1024*128) }
+
+func TestEncodeRESP3(t *testing.T) {
+	testEncodeAndCheck(t, NewNull(), []byte("_\r\n"))
+	testEncodeAndCheck(t, NewBoolean(false), []byte("#f\r\n"))
+	testEncodeAndCheck(t, NewDouble([]byte("1.5")), []byte(",1.5\r\n"))
+	testEncodeAndCheck(t, NewMap([]*Resp{
+		NewBulkBytes([]byte("k")), NewInt([]byte("1")),
+	}), []byte("%1\r\n$1\r\nk\r\n:1\r\n"))
+	testEncodeAndCheck(t, NewPush([]*Resp{NewBulkBytes([]byte("invalidate"))}), []byte(">1\r\n$10\r\ninvalidate\r\n"))
+
+	_, err := EncodeToBytes(NewMap([]*Resp{NewNull()}))
+	assert.Must(err != nil)
+}
+
+func TestRESP3ToRESP2(t *testing.T) {
+	resp := NewMap([]*Resp{
+		NewBulkBytes([]byte("a")), NewNull(),
+		NewBulkBytes([]byte("b")), NewBoolean(true),
+		NewBulkBytes([]byte("c")), NewSet([]*Resp{NewDouble([]byte("1.5"))}),
+		NewBulkBytes([]byte("d")), &Resp{Type: TypeVerbatim, Value: []byte("txt:hello")},
+	})
+	b, err := EncodeToBytes(resp.ToRESP2())
+	assert.MustNoError(err)
+	assert.Must(string(b) == "*8\r\n$1\r\na\r\n$-1\r\n$1\r\nb\r\n:1\r\n$1\r\nc\r\n*1\r\n$3\r\n1.5\r\n$1\r\nd\r\n$5\r\nhello\r\n")
+
+	array := NewArray([]*Resp{NewBulkBytes([]byte("x"))})
+	assert.Must(array.ToRESP2() == array)
+	assert.Must((&Resp{Type: TypeBlobError, Value: []byte("ERR x")}).ToRESP2().Type == TypeError)
+	attr := &Resp{Type: TypeAttribute, Array: []*Resp{NewString([]byte("ttl")), NewInt([]byte("1")), NewNull()}}
+	assert.Must(attr.ToRESP2().Type == TypeBulkBytes)
+}
@@ -15,6 +15,20 @@ const (
 	TypeArray     RespType = '*'
 )
 
+//RESP3新增的类型，只有通过HELLO 3切换协议的连接才会收到
+const (
+	TypeNull      RespType = '_'
+	TypeDouble    RespType = ','
+	TypeBoolean   RespType = '#'
+	TypeBlobError RespType = '!'
+	TypeVerbatim  RespType = '='
+	TypeBigNumber RespType = '('
+	TypeMap       RespType = '%'
+	TypeSet       RespType = '~'
+	TypeAttribute RespType = '|'
+	TypePush      RespType = '>'
+)
+
 func (t RespType) String() string {
 	switch t {
 	case TypeString:
@@ -27,11 +41,32 @@ func (t RespType) String() string {
 		return "<bulkbytes>"
 	case TypeArray:
 		return "<array>"
+	case TypeNull:
+		return "<null>"
+	case TypeDouble:
+		return "<double>"
+	case TypeBoolean:
+		return "<boolean>"
+	case TypeBlobError:
+		return "<bloberror>"
+	case TypeVerbatim:
+		return "<verbatim>"
+	case TypeBigNumber:
+		return "<bignumber>"
+	case TypeMap:
+		return "<map>"
+	case TypeSet:
+		return "<set>"
+	case TypeAttribute:
+		return "<attribute>"
+	case TypePush:
+		return "<push>"
 	default:
 		return fmt.Sprintf("<unknown-0x%02x>", byte(t))
 	}
 }
 
+//map的Array按key、value依次存放；attribute的Array先存放属性的key、value，最后一个元素是属性所附带的响应
 type Resp struct {
 	Type RespType
 
@@ -44,7 +79,7 @@ func (r *Resp) IsString() bool {
 }
 
 func (r *Resp) IsError() bool {
-	return r.Type == TypeError
+	return r.Type == TypeError || r.Type == TypeBlobError
 }
 
 func (r *Resp) IsInt() bool {
@@ -59,6 +94,67 @@ func (r *Resp) IsArray() bool {
 	return r.Type == TypeArray
 }
 
+func (r *Resp) IsPush() bool {
+	return r.Type == TypePush
+}
+
+//转换成RESP2客户端可以识别的响应，与redis对RESP2连接的回复方式相同
+func (r *Resp) ToRESP2() *Resp {
+	switch r.Type {
+	case TypeNull:
+		return NewBulkBytes(nil)
+	case TypeDouble, TypeBigNumber:
+		return NewBulkBytes(r.Value)
+	case TypeVerbatim:
+		//去掉"txt:"这样的格式前缀
+		if len(r.Value) >= 4 && r.Value[3] == ':' {
+			return NewBulkBytes(r.Value[4:])
+		}
+		return NewBulkBytes(r.Value)
+	case TypeBoolean:
+		if len(r.Value) == 1 && r.Value[0] == 't' {
+			return NewInt([]byte("1"))
+		}
+		return NewInt([]byte("0"))
+	case TypeBlobError:
+		return NewError(r.Value)
+	case TypeAttribute:
+		if len(r.Array) == 0 {
+			return NewBulkBytes(nil)
+		}
+		return r.Array[len(r.Array)-1].ToRESP2()
+	case TypeArray, TypeMap, TypeSet, TypePush:
+		if r.Type == TypeArray && !r.hasRESP3() {
+			return r
+		}
+		if r.Array == nil {
+			return NewArray(nil)
+		}
+		array := make([]*Resp, len(r.Array))
+		for i, x := range r.Array {
+			array[i] = x.ToRESP2()
+		}
+		return NewArray(array)
+	default:
+		return r
+	}
+}
+
+func (r *Resp) hasRESP3() bool {
+	for _, x := range r.Array {
+		switch x.Type {
+		case TypeString, TypeError, TypeInt, TypeBulkBytes:
+		case TypeArray:
+			if x.hasRESP3() {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
 func NewString(value []byte) *Resp {
 	r := &Resp{}
 	r.Type = TypeString
@@ -97,3 +193,56 @@ func NewArray(array []*Resp) *Resp {
 	r.Array = array
 	return r
 }
+
+func NewNull() *Resp {
+	r := &Resp{}
+	r.Type = TypeNull
+	return r
+}
+
+func NewDouble(value []byte) *Resp {
+	r := &Resp{}
+	r.Type = TypeDouble
+	r.Value = value
+	return r
+}
+
+func NewBoolean(value bool) *Resp {
+	r := &Resp{}
+	r.Type = TypeBoolean
+	if value {
+		r.Value = []byte("t")
+	} else {
+		r.Value = []byte("f")
+	}
+	return r
+}
+
+func NewBigNumber(value []byte) *Resp {
+	r := &Resp{}
+	r.Type = TypeBigNumber
+	r.Value = value
+	return r
+}
+
+//array中key、value依次存放
+func NewMap(array []*Resp) *Resp {
+	r := &Resp{}
+	r.Type = TypeMap
+	r.Array = array
+	return r
+}
+
+func NewSet(array []*Resp) *Resp {
+	r := &Resp{}
+	r.Type = TypeSet
+	r.Array = array
+	return r
+}
+
+func NewPush(array []*Resp) *Resp {
+	r := &Resp{}
+	r.Type = TypePush
+	r.Array = array
+	return r
+}
@@ -0,0 +1,174 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//从库读取的负载均衡策略
+const (
+	//按datacenter就近选择，同一个datacenter内随机
+	ReplicaBalanceLocality = iota
+	//在所有从库之间轮询
+	ReplicaBalanceRoundRobin
+	//按最近的平均延迟加权随机选择
+	ReplicaBalanceLatency
+)
+
+var replicaBalance atomic2.Int64
+
+func parseReplicaBalance(value string) (int, error) {
+	switch value {
+	case "", "locality":
+		return ReplicaBalanceLocality, nil
+	case "round-robin":
+		return ReplicaBalanceRoundRobin, nil
+	case "latency":
+		return ReplicaBalanceLatency, nil
+	}
+	return 0, fmt.Errorf("invalid replica balance '%s'", value)
+}
+
+func ReplicaSetBalance(value string) error {
+	balance, err := parseReplicaBalance(value)
+	if err != nil {
+		return err
+	}
+	replicaBalance.Set(int64(balance))
+	return nil
+}
+
+//session的读取方式，默认由backend_primary_only决定
+const (
+	readDefault = iota
+	readReplica
+	readPrimary
+)
+
+func (s *Session) replicaRead() bool {
+	switch s.readMode {
+	case readReplica:
+		return true
+	case readPrimary:
+		return false
+	}
+	return !s.config.BackendPrimaryOnly
+}
+
+//READONLY之后的读命令可以发送到从库，READWRITE之后只发送到主库
+func (s *Session) handleReadMode(r *Request) error {
+	if len(r.Multi) != 1 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for '%s' command", r.OpStr)
+		return nil
+	}
+	if r.OpStr == "READONLY" {
+		s.readMode = readReplica
+	} else {
+		s.readMode = readPrimary
+	}
+	r.Resp = RespOK
+	return nil
+}
+
+//延迟加权时加上的基数，避免还没有统计到延迟的从库权重过大，单位为us
+const replicaLatencyBase = 100
+
+//按replicaBalance选择一个可用的从库连接，都不可用时返回nil，跳过数据过旧的从库
+func (s *Slot) forwardReplica(r *Request) *BackendConn {
+	var groups, stale = freshReplicaGroups(s.replicaGroups)
+	if bc := selectReplica(s, groups, r); bc != nil {
+		return bc
+	}
+	if stale {
+		replicaStale.fallbacks.Incr()
+	}
+	return nil
+}
+
+//去掉数据过旧的从库，stale表示是否有从库被去掉
+func freshReplicaGroups(groups [][]*sharedBackendConn) (fresh [][]*sharedBackendConn, stale bool) {
+	if replicaStale.maxLag.Int64() <= 0 {
+		return groups, false
+	}
+	for _, group := range groups {
+		var g []*sharedBackendConn
+		for _, bc := range group {
+			if isReplicaStale(bc.Addr()) {
+				stale = true
+			} else {
+				g = append(g, bc)
+			}
+		}
+		if len(g) != 0 {
+			fresh = append(fresh, g)
+		}
+	}
+	return fresh, stale
+}
+
+func selectReplica(s *Slot, groups [][]*sharedBackendConn, r *Request) *BackendConn {
+	var database, quick, seed = r.Database, r.OpFlag.IsQuick(), r.Seed16()
+	switch replicaBalance.Int64() {
+	case ReplicaBalanceRoundRobin:
+		var all []*sharedBackendConn
+		for _, group := range groups {
+			all = append(all, group...)
+		}
+		var next = uint(atomic.AddUint32(&s.replicaNext, 1))
+		for i := range all {
+			if bc := all[(next+uint(i))%uint(len(all))].BackendConn(database, seed, quick, false); bc != nil {
+				return bc
+			}
+		}
+	case ReplicaBalanceLatency:
+		var candidates []*BackendConn
+		var weights []float64
+		var total float64
+		for _, group := range groups {
+			for _, shared := range group {
+				bc := shared.BackendConn(database, seed, quick, false)
+				if bc == nil {
+					continue
+				}
+				var latency int64
+				if bc.stats != nil {
+					latency = bc.stats.latency.Int64()
+				}
+				w := 1 / float64(latency+replicaLatencyBase)
+				candidates = append(candidates, bc)
+				weights = append(weights, w)
+				total += w
+			}
+		}
+		if len(candidates) == 0 {
+			return nil
+		}
+		//seed为16位的随机数
+		var x = float64(seed&0xffff) / 0x10000 * total
+		for i, w := range weights {
+			if x < w {
+				return candidates[i]
+			}
+			x -= w
+		}
+		return candidates[len(candidates)-1]
+	default:
+		//replicaGroups已经按datacenter排序，本地的从库都不可用时才使用其他datacenter的从库
+		for _, group := range groups {
+			var i = seed
+			for range group {
+				i = (i + 1) % uint(len(group))
+				if bc := group[i].BackendConn(database, seed, quick, false); bc != nil {
+					return bc
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+	utilredis "github.com/CodisLabs/codis/pkg/utils/redis"
+)
+
+type ReplicaLag struct {
+	Addr   string `json:"addr"`
+	Master string `json:"master"`
+
+	LinkStatus       string `json:"link_status,omitempty"`
+	LastIOSecondsAgo int64  `json:"last_io_seconds_ago"`
+
+	//redis为repl offset，pika为binlog_offset中的offset
+	MasterOffset  int64 `json:"master_offset"`
+	ReplicaOffset int64 `json:"replica_offset"`
+	//-1表示无法计算，例如pika主从binlog文件号不同
+	OffsetLag int64 `json:"offset_lag"`
+	FileLag   int64 `json:"file_lag,omitempty"`
+
+	Error      string `json:"error,omitempty"`
+	UpdateTime string `json:"update_time"`
+
+	//更新时按replica_max_lag判断数据过旧，从库读取会跳过该从库
+	Stale bool `json:"stale,omitempty"`
+}
+
+var replicaLags atomic.Value
+
+var replicaStale struct {
+	//0表示不检查复制延迟
+	maxLag    atomic2.Int64
+	fallbacks atomic2.Int64
+}
+
+func ReplicaSetMaxLag(n int64) {
+	replicaStale.maxLag.Set(n)
+}
+
+//从库都过旧、读请求回退到主库的次数
+func ReplicaStaleFallbacks() int64 {
+	return replicaStale.fallbacks.Int64()
+}
+
+//连接主库断开、无法获取复制信息或者延迟超过max时认为从库数据过旧
+//pika主从binlog文件号不同时无法计算偏移量差值，也认为过旧
+func (lag *ReplicaLag) isStale(max int64) bool {
+	switch {
+	case max <= 0:
+		return false
+	case lag.Error != "":
+		return true
+	case lag.LinkStatus != "" && lag.LinkStatus != "up":
+		return true
+	case lag.FileLag > 0:
+		return true
+	}
+	return lag.OffsetLag > max
+}
+
+//还没有获取到复制信息的从库不认为过旧
+func isReplicaStale(addr string) bool {
+	var max = replicaStale.maxLag.Int64()
+	if max <= 0 {
+		return false
+	}
+	lag := GetReplicaLag(addr)
+	return lag != nil && lag.isStale(max)
+}
+
+//返回所有从库的复制延迟，按地址排序
+func GetReplicaLags() []*ReplicaLag {
+	lags, _ := replicaLags.Load().(map[string]*ReplicaLag)
+	var all = make([]*ReplicaLag, 0, len(lags))
+	for _, v := range lags {
+		all = append(all, v)
+	}
+	sort.Sort(sliceReplicaLag(all))
+	return all
+}
+
+//返回某个从库的复制延迟，没有数据则返回nil
+func GetReplicaLag(addr string) *ReplicaLag {
+	lags, _ := replicaLags.Load().(map[string]*ReplicaLag)
+	return lags[addr]
+}
+
+type sliceReplicaLag []*ReplicaLag
+
+func (s sliceReplicaLag) Len() int {
+	return len(s)
+}
+
+func (s sliceReplicaLag) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceReplicaLag) Less(i, j int) bool {
+	return s[i].Addr < s[j].Addr
+}
+
+//返回从库地址到对应主库地址的映射
+func (s *Router) ReplicaMasters() map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var replicas = make(map[string]string)
+	for _, slot := range s.allSlots() {
+		master := slot.backend.bc.Addr()
+		if master == "" {
+			continue
+		}
+		for _, group := range slot.replicaGroups {
+			for _, bc := range group {
+				if addr := bc.Addr(); addr != master {
+					replicas[addr] = master
+				}
+			}
+		}
+	}
+	return replicas
+}
+
+func (s *Proxy) refreshReplicaLags(d time.Duration) {
+	var interval = math2.MaxDuration(d, time.Second)
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	//每次刷新复用连接，超时时间大于刷新间隔，避免空闲连接在两次刷新之间被回收
+	var redisp = utilredis.NewPoolOptions(s.backendDialOptions(), interval*2)
+	defer redisp.Close()
+	for {
+		select {
+		case <-s.exit.C:
+			return
+		case <-ticker.C:
+			replicas := s.router.ReplicaMasters()
+			if len(replicas) == 0 {
+				replicaLags.Store(map[string]*ReplicaLag{})
+				continue
+			}
+			var masters = make(map[string]map[string]string)
+			var lags = make(map[string]*ReplicaLag, len(replicas))
+			for addr, master := range replicas {
+				lag := &ReplicaLag{
+					Addr: addr, Master: master, OffsetLag: -1,
+					UpdateTime: time.Now().Format("2006-01-02 15:04:05"),
+				}
+				lags[addr] = lag
+
+				if _, ok := masters[master]; !ok {
+					info, err := redisp.Info(master)
+					if err != nil {
+						log.WarnErrorf(err, "get master %s info failed", master)
+					}
+					masters[master] = info
+				}
+				info, err := redisp.Info(addr)
+				if err != nil {
+					lag.Error = err.Error()
+					lag.Stale = lag.isStale(replicaStale.maxLag.Int64())
+					continue
+				}
+				fillReplicaLag(lag, masters[master], info)
+				lag.Stale = lag.isStale(replicaStale.maxLag.Int64())
+			}
+			replicaLags.Store(lags)
+		}
+	}
+}
+
+func fillReplicaLag(lag *ReplicaLag, master, replica map[string]string) {
+	lag.LinkStatus = replica["master_link_status"]
+	if v, err := strconv.ParseInt(replica["master_last_io_seconds_ago"], 10, 64); err == nil {
+		lag.LastIOSecondsAgo = v
+	}
+	if master == nil {
+		return
+	}
+
+	//redis
+	if s, ok := replica["slave_repl_offset"]; ok {
+		moffset, err1 := strconv.ParseInt(master["master_repl_offset"], 10, 64)
+		soffset, err2 := strconv.ParseInt(s, 10, 64)
+		if err1 == nil && err2 == nil {
+			lag.MasterOffset, lag.ReplicaOffset = moffset, soffset
+			lag.OffsetLag = moffset - soffset
+		}
+		return
+	}
+
+	//pika, binlog_offset:filenum offset
+	mfile, moffset, ok1 := parseBinlogOffset(master["binlog_offset"])
+	sfile, soffset, ok2 := parseBinlogOffset(replica["binlog_offset"])
+	if ok1 && ok2 {
+		lag.MasterOffset, lag.ReplicaOffset = moffset, soffset
+		lag.FileLag = mfile - sfile
+		if mfile == sfile {
+			lag.OffsetLag = moffset - soffset
+		}
+	}
+}
+
+func parseBinlogOffset(s string) (int64, int64, bool) {
+	fields := strings.Fields(s)
+	if len(fields) != 2 {
+		return 0, 0, false
+	}
+	filenum, err1 := strconv.ParseInt(fields[0], 10, 64)
+	offset, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return filenum, offset, true
+}
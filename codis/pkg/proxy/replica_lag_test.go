@@ -0,0 +1,83 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestParseBinlogOffset(x *testing.T) {
+	for _, t := range []struct {
+		s               string
+		filenum, offset int64
+		ok              bool
+	}{
+		{"3 1024", 3, 1024, true},
+		{" 0  0 ", 0, 0, true},
+		{"", 0, 0, false},
+		{"3", 0, 0, false},
+		{"3 1024 5", 0, 0, false},
+		{"a 1024", 0, 0, false},
+		{"3 b", 0, 0, false},
+	} {
+		filenum, offset, ok := parseBinlogOffset(t.s)
+		assert.Must(filenum == t.filenum && offset == t.offset && ok == t.ok)
+	}
+}
+
+func TestFillReplicaLag(x *testing.T) {
+	for _, t := range []struct {
+		master, replica map[string]string
+
+		link      string
+		lastIO    int64
+		moffset   int64
+		soffset   int64
+		offsetLag int64
+		fileLag   int64
+	}{
+		//redis
+		{
+			master:  map[string]string{"master_repl_offset": "1000"},
+			replica: map[string]string{"master_link_status": "up", "master_last_io_seconds_ago": "2", "slave_repl_offset": "900"},
+			link:    "up", lastIO: 2, moffset: 1000, soffset: 900, offsetLag: 100,
+		},
+		//主库信息获取失败
+		{
+			master:  nil,
+			replica: map[string]string{"master_link_status": "down", "slave_repl_offset": "900"},
+			link:    "down", offsetLag: -1,
+		},
+		{
+			master:    map[string]string{"master_repl_offset": "x"},
+			replica:   map[string]string{"slave_repl_offset": "900"},
+			offsetLag: -1,
+		},
+		//pika，binlog文件号相同
+		{
+			master:  map[string]string{"binlog_offset": "5 3000"},
+			replica: map[string]string{"master_link_status": "up", "binlog_offset": "5 1000"},
+			link:    "up", moffset: 3000, soffset: 1000, offsetLag: 2000,
+		},
+		//pika，binlog文件号不同时无法计算偏移量差值
+		{
+			master:  map[string]string{"binlog_offset": "6 100"},
+			replica: map[string]string{"binlog_offset": "5 1000"},
+			moffset: 100, soffset: 1000, offsetLag: -1, fileLag: 1,
+		},
+		{
+			master:    map[string]string{"binlog_offset": "6 100"},
+			replica:   map[string]string{"binlog_offset": "bad"},
+			offsetLag: -1,
+		},
+	} {
+		lag := &ReplicaLag{OffsetLag: -1}
+		fillReplicaLag(lag, t.master, t.replica)
+		assert.Must(lag.LinkStatus == t.link && lag.LastIOSecondsAgo == t.lastIO)
+		assert.Must(lag.MasterOffset == t.moffset && lag.ReplicaOffset == t.soffset)
+		assert.Must(lag.OffsetLag == t.offsetLag && lag.FileLag == t.fileLag)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//所有命令都返回自己的地址
+func newFakeAddrServer() net.Listener {
+	return newSlowAddrServer(0)
+}
+
+//每个请求延迟delay之后返回自己的地址
+func newSlowAddrServer(delay time.Duration) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	var addr = []byte(l.Addr().String())
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(c *redis.Conn) {
+				defer c.Close()
+				for {
+					if _, err := c.DecodeMultiBulk(); err != nil {
+						return
+					}
+					time.Sleep(delay)
+					c.Encode(redis.NewBulkBytes(addr), true)
+				}
+			}(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return l
+}
+
+//和loopReader一样按session的读取方式设置请求
+func doReadRequest(s *Session, d *Router, args ...string) string {
+	r := newACLRequest(args...)
+	r.Batch = &sync.WaitGroup{}
+	r.ReplicaRead = s.replicaRead()
+	assert.MustNoError(s.handleRequest(r, d))
+	resp, err := s.handleResponse(r)
+	assert.MustNoError(err)
+	return string(resp.Value)
+}
+
+//key a所在的slot有一个主库和两个从库
+func newReplicaRouter(c *Config, master, replica1, replica2 net.Listener) (*Router, int) {
+	router := NewRouter(c)
+	var slot = int(Hash([]byte("a")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{
+		Id: slot, BackendAddr: master.Addr().String(), ForwardMethod: models.ForwardSync,
+		ReplicaGroups: [][]string{{replica1.Addr().String(), replica2.Addr().String()}},
+	}))
+	assert.Must(waitFor(router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true).IsConnected))
+	for _, bc := range router.slots[slot].replicaGroups[0] {
+		assert.Must(waitFor(bc.BackendConn(0, 0, false, true).IsConnected))
+	}
+	return router, slot
+}
+
+func TestReplicaRead(x *testing.T) {
+	defer ReplicaSetBalance("locality")
+
+	master, replica1, replica2 := newFakeAddrServer(), newFakeAddrServer(), newFakeAddrServer()
+	defer master.Close()
+	defer replica1.Close()
+	defer replica2.Close()
+
+	c := newProxyConfig()
+	assert.Must(c.BackendPrimaryOnly)
+	router, slot := newReplicaRouter(c, master, replica1, replica2)
+	defer router.Close()
+
+	s := newHelloSession("")
+	s.config = c
+	assert.Must(doReadRequest(s, router, "GET", "a") == master.Addr().String())
+
+	assert.Must(doTxRequest(s, router, "READONLY") == RespOK)
+	assert.Must(doReadRequest(s, router, "SET", "a", "1") == master.Addr().String())
+	assert.Must(doReadRequest(s, router, "GET", "a") != master.Addr().String())
+
+	//轮询时两个从库都会被读到
+	assert.MustNoError(ReplicaSetBalance("round-robin"))
+	var seen = make(map[string]bool)
+	for i := 0; i < 4; i++ {
+		seen[doReadRequest(s, router, "GET", "a")] = true
+	}
+	assert.Must(len(seen) == 2 && !seen[master.Addr().String()])
+
+	//延迟高的从库几乎不会被选中
+	assert.MustNoError(ReplicaSetBalance("latency"))
+	slow := router.slots[slot].replicaGroups[0][1].BackendConn(0, 0, false, true)
+	slow.stats.latency.Set(1e9)
+	defer slow.stats.latency.Set(0)
+	for i := 0; i < 16; i++ {
+		assert.Must(doReadRequest(s, router, "GET", "a") == replica1.Addr().String())
+	}
+
+	assert.Must(doTxRequest(s, router, "READWRITE") == RespOK)
+	assert.Must(doReadRequest(s, router, "GET", "a") == master.Addr().String())
+
+	assert.Must(ReplicaSetBalance("random") != nil)
+}
+
+func TestReplicaStale(x *testing.T) {
+	defer replicaLags.Store(map[string]*ReplicaLag{})
+	defer ReplicaSetMaxLag(0)
+
+	master, replica1, replica2 := newFakeAddrServer(), newFakeAddrServer(), newFakeAddrServer()
+	defer master.Close()
+	defer replica1.Close()
+	defer replica2.Close()
+
+	c := newProxyConfig()
+	c.BackendPrimaryOnly = false
+	router, _ := newReplicaRouter(c, master, replica1, replica2)
+	defer router.Close()
+
+	s := newHelloSession("")
+	s.config = c
+
+	addr1, addr2 := replica1.Addr().String(), replica2.Addr().String()
+	replicaLags.Store(map[string]*ReplicaLag{
+		addr1: {Addr: addr1, LinkStatus: "up", OffsetLag: 1000},
+		addr2: {Addr: addr2, LinkStatus: "up", OffsetLag: 10},
+	})
+	ReplicaSetMaxLag(100)
+	for i := 0; i < 4; i++ {
+		assert.Must(doReadRequest(s, router, "GET", "a") == addr2)
+	}
+
+	//从库都过旧时读主库
+	var fallbacks = ReplicaStaleFallbacks()
+	replicaLags.Store(map[string]*ReplicaLag{
+		addr1: {Addr: addr1, LinkStatus: "up", OffsetLag: 1000},
+		addr2: {Addr: addr2, LinkStatus: "down", OffsetLag: 10},
+	})
+	assert.Must(doReadRequest(s, router, "GET", "a") == master.Addr().String())
+	assert.Must(ReplicaStaleFallbacks() == fallbacks+1)
+
+	ReplicaSetMaxLag(0)
+	assert.Must(doReadRequest(s, router, "GET", "a") != master.Addr().String())
+}
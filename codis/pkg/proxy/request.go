@@ -5,6 +5,7 @@ package proxy
 
 import (
 	"sync"
+	"time"
 	"unsafe"
 
 	"github.com/CodisLabs/codis/pkg/proxy/redis"
@@ -25,20 +26,52 @@ type Request struct {
 
 	Database int32
 	ReceiveTime int64
+	EnqueueTime int64 //进入后端连接队列的时间
 	SendToServerTime int64
 	ReceiveFromServerTime int64
 	TasksLen    int64
+	BackendAddr string //发送到的后端地址，拆分的请求只记录在子请求中
+	Deadline    int64 //客户端声明的截止时间(unix nano)，0表示不限制
+	RESP3       bool  //客户端使用RESP3，否则响应需要转换成RESP2
+	ReplicaRead bool  //读命令可以发送到从库
+	Inflight    bool  //计入了proxy正在处理的请求数，发送响应后减去
 
 	*redis.Resp
 	Err error
+	//在Resp之后依次发送，例如SUBSCRIBE多个频道时每个频道回复一次
+	Replies []*redis.Resp
+
+	//EXEC请求，后端连接在EXEC之前依次发送MULTI和Tx中的命令，TxKeys为这些命令中的key
+	Tx     [][]*redis.Resp
+	TxKeys [][]byte
 
 	Coalesce func() error
+	//有截止时间的请求在后台等待Batch，Batch完成时关闭
+	batchDone chan struct{}
+	//hot cache未命中时设置，收到成功的响应后写入缓存
+	CacheFill func(resp *redis.Resp)
 }
 
 func (r *Request) IsBroken() bool {
 	return r.Broken != nil && r.Broken.IsTrue()
 }
 
+func (r *Request) IsExpired() bool {
+	return r.Deadline != 0 && time.Now().UnixNano() > r.Deadline
+}
+
+//在后台等待Batch完成，读取请求之后调用，回复在截止时间之前到达时不会被误判为超时
+func (r *Request) waitBatchAsync() {
+	if r.batchDone != nil {
+		return
+	}
+	r.batchDone = make(chan struct{})
+	go func() {
+		r.Batch.Wait()
+		close(r.batchDone)
+	}()
+}
+
 func (r *Request) MakeSubRequest(n int) []Request {
 	var sub = make([]Request, n)
 	for i := range sub {
@@ -48,7 +81,9 @@ func (r *Request) MakeSubRequest(n int) []Request {
 		x.OpFlag = r.OpFlag
 		x.Broken = r.Broken
 		x.Database = r.Database
+		x.ReplicaRead = r.ReplicaRead
 		x.ReceiveTime = r.ReceiveTime
+		x.Deadline = r.Deadline
 	}
 	return sub
 }
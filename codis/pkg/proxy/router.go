@@ -4,7 +4,10 @@
 package proxy
 
 import (
+	"bytes"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/CodisLabs/codis/pkg/models"
@@ -23,6 +26,8 @@ type Router struct {
 		replica *sharedBackendConnPool
 	}
 	slots [MaxSlotNum]Slot
+	//按key前缀路由的slot，前缀从长到短排列，只在持有mu时替换
+	routes atomic.Value
 
 	config *Config
 	online bool
@@ -37,6 +42,7 @@ func NewRouter(config *Config) *Router {
 		s.slots[i].id = i
 		s.slots[i].method = &forwardSync{}
 	}
+	s.routes.Store([]*Slot{})
 	return s
 }
 
@@ -60,6 +66,9 @@ func (s *Router) Close() {
 	for i := range s.slots {
 		s.fillSlot(&models.Slot{Id: i}, false, nil)
 	}
+	for _, slot := range s.getRoutes() {
+		s.fillSlot(&models.Slot{Prefix: string(slot.prefix)}, false, nil)
+	}
 }
 
 func (s *Router) GetSlots() []*models.Slot {
@@ -72,6 +81,67 @@ func (s *Router) GetSlots() []*models.Slot {
 	return slots
 }
 
+func (s *Router) GetRoutes() []*models.Slot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var routes = s.getRoutes()
+	var list = make([]*models.Slot, len(routes))
+	for i, slot := range routes {
+		list[i] = slot.snapshot()
+	}
+	return list
+}
+
+func (s *Router) getRoutes() []*Slot {
+	return s.routes.Load().([]*Slot)
+}
+
+//所有slot以及前缀路由
+func (s *Router) allSlots() []*Slot {
+	var all = make([]*Slot, 0, MaxSlotNum)
+	for i := range s.slots {
+		all = append(all, &s.slots[i])
+	}
+	return append(all, s.getRoutes()...)
+}
+
+//返回key匹配的最长前缀的路由
+func (s *Router) matchRoute(key []byte) *Slot {
+	for _, slot := range s.getRoutes() {
+		if bytes.HasPrefix(key, slot.prefix) {
+			return slot
+		}
+	}
+	return nil
+}
+
+//按前缀查找路由，不存在时创建一个没有后端的路由
+func (s *Router) lockedRoute(prefix string) *Slot {
+	var routes = s.getRoutes()
+	for _, slot := range routes {
+		if string(slot.prefix) == prefix {
+			return slot
+		}
+	}
+	slot := &Slot{id: -1, prefix: []byte(prefix), method: &forwardSync{}}
+	routes = append(append([]*Slot{}, routes...), slot)
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+	s.routes.Store(routes)
+	return slot
+}
+
+func (s *Router) lockedRemoveRoute(prefix string) {
+	var routes []*Slot
+	for _, slot := range s.getRoutes() {
+		if string(slot.prefix) != prefix {
+			routes = append(routes, slot)
+		}
+	}
+	s.routes.Store(append([]*Slot{}, routes...))
+}
+
 func (s *Router) GetSlot(id int) *models.Slot {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -105,7 +175,7 @@ func (s *Router) FillSlot(m *models.Slot) error {
 	if s.closed {
 		return ErrClosedRouter
 	}
-	if m.Id < 0 || m.Id >= MaxSlotNum {
+	if m.Prefix == "" && (m.Id < 0 || m.Id >= MaxSlotNum) {
 		return ErrInvalidSlotId
 	}
 	var method forwardMethod
@@ -132,15 +202,39 @@ func (s *Router) KeepAlive() error {
 	return nil
 }
 
+//返回等待后端响应超过timeout的连接
+func (s *Router) StuckBackendConns(timeout time.Duration) []*BackendConn {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var now = time.Now().UnixNano()
+	var stuck []*BackendConn
+	for _, p := range []*sharedBackendConnPool{s.pool.primary, s.pool.replica} {
+		for _, shared := range p.pool {
+			shared.forEach(func(bc *BackendConn) {
+				if bc.isStuck(timeout, now) {
+					stuck = append(stuck, bc)
+				}
+			})
+		}
+	}
+	return stuck
+}
+
 func (s *Router) isOnline() bool {
 	return s.online && !s.closed
 }
 
 func (s *Router) dispatch(r *Request) error {
 	hkey := getHashKey(r.Multi, r.OpStr)
-	var id = Hash(hkey) % MaxSlotNum
-	slot := &s.slots[id]
-	return slot.forward(r, hkey)
+	return s.keySlot(hkey).forward(r, hkey)
+}
+
+//前缀路由优先于slot哈希
+func (s *Router) keySlot(hkey []byte) *Slot {
+	if slot := s.matchRoute(hkey); slot != nil {
+		return slot
+	}
+	return &s.slots[Hash(hkey)%MaxSlotNum]
 }
 
 func (s *Router) dispatchSlot(r *Request, id int) error {
@@ -166,9 +260,38 @@ func (s *Router) dispatchAddr(r *Request, addr string) bool {
 }
 
 func (s *Router) fillSlot(m *models.Slot, switched bool, method forwardMethod) {
+	if m.Prefix != "" {
+		s.fillRoute(m, switched, method)
+		return
+	}
 	slot := &s.slots[m.Id]
 	slot.blockAndWait()
+	var lastBackend, lastMigrate = slot.backend.bc.Addr(), slot.migrate.bc.Addr()
+	s.resetSlot(slot, m, switched, method)
 
+	if lastBackend != slot.backend.bc.Addr() || lastMigrate != slot.migrate.bc.Addr() {
+		notifySlotChanged(slot.id)
+	}
+	s.logFillSlot(slot, switched)
+}
+
+//路由不会迁移，BackendAddrGroupId为0时删除路由
+func (s *Router) fillRoute(m *models.Slot, switched bool, method forwardMethod) {
+	slot := s.lockedRoute(m.Prefix)
+	slot.blockAndWait()
+	var x = *m
+	x.MigrateFrom, x.MigrateFromGroupId = "", 0
+	s.resetSlot(slot, &x, switched, method)
+	if m.BackendAddrGroupId == 0 {
+		s.lockedRemoveRoute(m.Prefix)
+		log.Warnf("remove route prefix-[%s]", m.Prefix)
+	} else if !s.closed {
+		log.Warnf("fill route prefix-[%s], backend.addr = %s, locked = %t",
+			m.Prefix, slot.backend.bc.Addr(), slot.lock.hold)
+	}
+}
+
+func (s *Router) resetSlot(slot *Slot, m *models.Slot, switched bool, method forwardMethod) {
 	slot.backend.bc.Release()
 	slot.backend.bc = nil
 	slot.backend.id = 0
@@ -192,17 +315,16 @@ func (s *Router) fillSlot(m *models.Slot, switched bool, method forwardMethod) {
 		slot.migrate.bc = s.pool.primary.Retain(from)
 		slot.migrate.id = m.MigrateFromGroupId
 	}
-	if !s.config.BackendPrimaryOnly {
-		for i := range m.ReplicaGroups {
-			var group []*sharedBackendConn
-			for _, addr := range m.ReplicaGroups[i] {
-				group = append(group, s.pool.replica.Retain(addr))
-			}
-			if len(group) == 0 {
-				continue
-			}
-			slot.replicaGroups = append(slot.replicaGroups, group)
+	//backend_primary_only时session仍然可以通过READONLY读取从库
+	for i := range m.ReplicaGroups {
+		var group []*sharedBackendConn
+		for _, addr := range m.ReplicaGroups[i] {
+			group = append(group, s.pool.replica.Retain(addr))
+		}
+		if len(group) == 0 {
+			continue
 		}
+		slot.replicaGroups = append(slot.replicaGroups, group)
 	}
 	if method != nil {
 		slot.method = method
@@ -211,6 +333,9 @@ func (s *Router) fillSlot(m *models.Slot, switched bool, method forwardMethod) {
 	if !m.Locked {
 		slot.unblock()
 	}
+}
+
+func (s *Router) logFillSlot(slot *Slot, switched bool) {
 	if !s.closed {
 		if slot.migrate.bc != nil {
 			if switched {
@@ -251,14 +376,17 @@ func (s *Router) SwitchMasters(masters map[int]string) error {
 		Auth: s.config.ProductAuth, Timeout: time.Millisecond * 100,
 	}
 	for i := range s.slots {
-		s.trySwitchMaster(i, masters, cache)
+		s.trySwitchMaster(&s.slots[i], masters, cache)
+	}
+	for _, slot := range s.getRoutes() {
+		s.trySwitchMaster(slot, masters, cache)
 	}
 	return nil
 }
 
-func (s *Router) trySwitchMaster(id int, masters map[int]string, cache *redis.InfoCache) {
+func (s *Router) trySwitchMaster(slot *Slot, masters map[int]string, cache *redis.InfoCache) {
 	var switched bool
-	var m = s.slots[id].snapshot()
+	var m = slot.snapshot()
 
 	hasSameRunId := func(addr1, addr2 string) bool {
 		if addr1 != addr2 {
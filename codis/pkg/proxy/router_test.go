@@ -0,0 +1,62 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestPrefixRoute(x *testing.T) {
+	s1, s2, s3 := newFakeAddrServer(), newFakeAddrServer(), newFakeAddrServer()
+	defer s1.Close()
+	defer s2.Close()
+	defer s3.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	for i := 0; i < MaxSlotNum; i++ {
+		assert.MustNoError(router.FillSlot(&models.Slot{
+			Id: i, BackendAddr: s1.Addr().String(), BackendAddrGroupId: 1,
+		}))
+	}
+	assert.MustNoError(router.FillSlot(&models.Slot{
+		Prefix: "big", BackendAddr: s2.Addr().String(), BackendAddrGroupId: 2,
+	}))
+	assert.MustNoError(router.FillSlot(&models.Slot{
+		Prefix: "bigdata:", BackendAddr: s3.Addr().String(), BackendAddrGroupId: 3,
+	}))
+	router.Start()
+	for _, slot := range router.allSlots() {
+		assert.Must(waitFor(slot.backend.bc.BackendConn(0, uint(slot.id), false, true).IsConnected))
+	}
+
+	routes := router.GetRoutes()
+	assert.Must(len(routes) == 2 && routes[0].Prefix == "bigdata:" && routes[1].Prefix == "big")
+
+	//最长的前缀优先，没有匹配的key按slot哈希
+	s := newHelloSession("")
+	s.config = c
+	assert.Must(doReadRequest(s, router, "GET", "a") == s1.Addr().String())
+	assert.Must(doReadRequest(s, router, "GET", "bigkey") == s2.Addr().String())
+	assert.Must(doReadRequest(s, router, "GET", "bigdata:1") == s3.Addr().String())
+
+	//阻塞命令和XREAD同样按路由转发，路由和slot中的key不能同时使用
+	assert.Must(doReadRequest(s, router, "XREAD", "STREAMS", "bigkey", "0") == s2.Addr().String())
+	assert.Must(doReadRequest(s, router, "BLPOP", "bigkey", "1") == s2.Addr().String())
+	assert.Must(strings.HasPrefix(doReadRequest(s, router, "BLPOP", "bigkey", "a", "1"), "CROSSSLOT"))
+
+	//主库切换时路由也切换
+	assert.MustNoError(router.SwitchMasters(map[int]string{2: s1.Addr().String()}))
+	assert.Must(doReadRequest(s, router, "GET", "bigkey") == s1.Addr().String())
+
+	//group id为0时删除路由
+	assert.MustNoError(router.FillSlot(&models.Slot{Prefix: "bigdata:"}))
+	assert.Must(len(router.GetRoutes()) == 1)
+	assert.Must(doReadRequest(s, router, "GET", "bigdata:1") == s1.Addr().String())
+}
@@ -0,0 +1,325 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+)
+
+//一次SCAN最多扫描的slot步数，空的slot较多时避免单个请求耗时过长
+const scanMaxSteps = 64
+
+//前缀路由最多使用的不同后端数量，超过时SCAN返回错误
+const scanMaxRouteAddrs = 62
+
+//每个slot的扫描阶段数：迁移源、slot所在的后端以及前缀路由的后端
+const scanPhases = 2 + scanMaxRouteAddrs
+
+//虚拟游标 = (后端游标*scanPhases + 阶段)*MaxSlotNum + slot
+//阶段0扫描迁移源上的slot，slot没有迁移时直接跳过；阶段1扫描slot当前所在的后端
+//阶段2开始依次扫描前缀路由的后端，只返回路由到该后端的key，和slot所在后端相同时跳过
+//按slot用SLOTSSCAN扫描，迁移中的slot先扫迁移源再扫目标，迁移完成的key不会漏掉
+//扫描期间路由发生变化时，路由的key可能重复返回或者漏掉
+type scanCursor struct {
+	slot   int
+	phase  uint64
+	cursor uint64
+}
+
+//slot等于MaxSlotNum表示扫描结束
+func (c scanCursor) done() bool {
+	return c.slot >= MaxSlotNum
+}
+
+func (c scanCursor) encode() []byte {
+	if c.done() {
+		return []byte("0")
+	}
+	v := (c.cursor*scanPhases+c.phase)*MaxSlotNum + uint64(c.slot)
+	return strconv.AppendUint(nil, v, 10)
+}
+
+//nphase是当前的阶段数，阶段用完后扫描下一个slot
+func (c scanCursor) next(cursor uint64, nphase int) scanCursor {
+	switch {
+	case cursor != 0:
+		c.cursor = cursor
+	case c.phase+1 < uint64(nphase):
+		c.phase, c.cursor = c.phase+1, 0
+	default:
+		c.slot, c.phase, c.cursor = c.slot+1, 0, 0
+	}
+	return c
+}
+
+func parseScanCursor(b []byte) (scanCursor, bool) {
+	v, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return scanCursor{}, false
+	}
+	return scanCursor{
+		slot:   int(v % MaxSlotNum),
+		phase:  v / MaxSlotNum % scanPhases,
+		cursor: v / MaxSlotNum / scanPhases,
+	}, true
+}
+
+type scanOptions struct {
+	cursor scanCursor
+	match  string
+	count  int
+	typ    string
+}
+
+//SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]
+func parseScanOptions(multi []*redis.Resp) (*scanOptions, *redis.Resp) {
+	if len(multi) < 2 {
+		return nil, redis.NewErrorf("ERR wrong number of arguments for 'scan' command")
+	}
+	cursor, ok := parseScanCursor(multi[1].Value)
+	if !ok {
+		return nil, redis.NewErrorf("ERR invalid cursor")
+	}
+	var opt = &scanOptions{cursor: cursor, count: 10}
+	for i := 2; i < len(multi); i += 2 {
+		if i+1 >= len(multi) {
+			return nil, redis.NewErrorf("ERR syntax error")
+		}
+		var value = string(multi[i+1].Value)
+		switch strings.ToUpper(string(multi[i].Value)) {
+		case "MATCH":
+			opt.match = value
+		case "COUNT":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				return nil, redis.NewErrorf("ERR value is not an integer or out of range")
+			}
+			opt.count = n
+		case "TYPE":
+			opt.typ = value
+		default:
+			return nil, redis.NewErrorf("ERR syntax error")
+		}
+	}
+	return opt, nil
+}
+
+//把整个keyspace的SCAN转换为逐个slot的SLOTSSCAN，MATCH和TYPE在proxy上过滤
+func (s *Session) handleScan(r *Request, d *Router) error {
+	opt, resp := parseScanOptions(r.Multi)
+	if resp != nil {
+		r.Resp = resp
+		return nil
+	}
+
+	var keys [][]byte
+	var next = opt.cursor
+	var fail *redis.Resp
+	var err error
+
+	r.Batch.Add(1)
+	go func() {
+		defer r.Batch.Done()
+		for i := 0; i < scanMaxSteps && len(keys) < opt.count && !next.done(); i++ {
+			if r.IsBroken() {
+				err = ErrRequestIsBroken
+				return
+			}
+			var found [][]byte
+			if found, next, fail, err = s.scanStep(r, d, next, opt); fail != nil || err != nil {
+				return
+			}
+			keys = append(keys, found...)
+		}
+	}()
+
+	r.Coalesce = func() error {
+		switch {
+		case err != nil:
+			return err
+		case fail != nil:
+			r.Resp = fail
+			return nil
+		}
+		var array = make([]*redis.Resp, len(keys))
+		for i, key := range keys {
+			array[i] = redis.NewBulkBytes(key)
+		}
+		r.Resp = redis.NewArray([]*redis.Resp{
+			redis.NewBulkBytes(next.encode()), redis.NewArray(array),
+		})
+		return nil
+	}
+	return nil
+}
+
+//扫描一次slot，返回过滤后的key和下一个游标，后端返回错误时通过fail返回给客户端
+func (s *Session) scanStep(r *Request, d *Router, c scanCursor, opt *scanOptions) (keys [][]byte, next scanCursor, fail *redis.Resp, err error) {
+	var routes = d.routeAddrs()
+	if len(routes) > scanMaxRouteAddrs {
+		return nil, c, redis.NewErrorf("ERR too many route backends to scan, max is %d", scanMaxRouteAddrs), nil
+	}
+	var nphase = 2 + len(routes)
+
+	var from, addr string
+	for {
+		if c.done() {
+			return nil, c, nil, nil
+		}
+		var ok bool
+		if from, addr, ok = d.scanAddr(c, routes); ok {
+			break
+		}
+		c = c.next(0, nphase)
+	}
+
+	var batch = &sync.WaitGroup{}
+	sub := s.makeScanRequest(r, batch, "SLOTSSCAN",
+		strconv.Itoa(c.slot), strconv.FormatUint(c.cursor, 10), "COUNT", strconv.Itoa(opt.count))
+	if fail, err = s.dispatchScanRequest(sub, d, c.slot, from); fail != nil || err != nil {
+		return nil, c, fail, err
+	}
+	batch.Wait()
+	if err = checkScanResponse(sub); err != nil {
+		return nil, c, nil, err
+	}
+
+	var resp = sub.Resp
+	if resp.IsError() {
+		return nil, c, resp, nil
+	}
+	if !resp.IsArray() || len(resp.Array) != 2 || !resp.Array[1].IsArray() {
+		return nil, c, redis.NewErrorf("ERR bad slotsscan resp from backend"), nil
+	}
+	cursor, perr := strconv.ParseUint(string(resp.Array[0].Value), 10, 64)
+	if perr != nil || cursor > math.MaxUint64/MaxSlotNum/scanPhases {
+		return nil, c, redis.NewErrorf("ERR bad slotsscan cursor '%s' from backend", resp.Array[0].Value), nil
+	}
+
+	for _, x := range resp.Array[1].Array {
+		if !d.scanOwns(x.Value, c.phase, addr) {
+			continue
+		}
+		if opt.match == "" || globMatch(opt.match, string(x.Value)) {
+			keys = append(keys, x.Value)
+		}
+	}
+	if opt.typ != "" && len(keys) != 0 {
+		if keys, fail, err = s.filterScanType(r, d, keys, c.slot, from, opt.typ); fail != nil || err != nil {
+			return nil, c, fail, err
+		}
+	}
+	return keys, c.next(cursor, nphase), nil, nil
+}
+
+//用TYPE查询key的类型，和SLOTSSCAN发送到同一个后端
+func (s *Session) filterScanType(r *Request, d *Router, keys [][]byte, slot int, from, typ string) ([][]byte, *redis.Resp, error) {
+	var batch = &sync.WaitGroup{}
+	var sub = make([]*Request, len(keys))
+	for i, key := range keys {
+		sub[i] = s.makeScanRequest(r, batch, "TYPE", string(key))
+		if fail, err := s.dispatchScanRequest(sub[i], d, slot, from); fail != nil || err != nil {
+			batch.Wait()
+			return nil, fail, err
+		}
+	}
+	batch.Wait()
+
+	var filtered [][]byte
+	for i, key := range keys {
+		if err := checkScanResponse(sub[i]); err != nil {
+			return nil, nil, err
+		}
+		if resp := sub[i].Resp; !resp.IsError() && strings.EqualFold(string(resp.Value), typ) {
+			filtered = append(filtered, key)
+		}
+	}
+	return filtered, nil, nil
+}
+
+func (s *Session) makeScanRequest(r *Request, batch *sync.WaitGroup, opstr string, args ...string) *Request {
+	sub := &r.MakeSubRequest(1)[0]
+	sub.Batch = batch
+	sub.OpStr = opstr
+	sub.Multi = []*redis.Resp{redis.NewBulkBytes([]byte(opstr))}
+	for _, arg := range args {
+		sub.Multi = append(sub.Multi, redis.NewBulkBytes([]byte(arg)))
+	}
+	return sub
+}
+
+//from不为空时发送到迁移源，否则发送到slot当前所在的后端
+func (s *Session) dispatchScanRequest(sub *Request, d *Router, slot int, from string) (*redis.Resp, error) {
+	if from == "" {
+		return nil, d.dispatchSlot(sub, slot)
+	}
+	if !d.dispatchAddr(sub, from) {
+		return redis.NewErrorf("ERR backend %s is not available", from), nil
+	}
+	return nil, nil
+}
+
+func checkScanResponse(sub *Request) error {
+	switch {
+	case sub.Err != nil:
+		return sub.Err
+	case sub.Resp == nil:
+		return ErrRespIsRequired
+	}
+	return nil
+}
+
+//slot正在迁移时返回迁移源的地址
+func (s *Router) migrateAddr(id int) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.slots[id].migrate.bc.Addr()
+}
+
+//前缀路由后端的地址，排序后作为扫描阶段的顺序
+func (s *Router) routeAddrs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var addrs []string
+	var seen = make(map[string]bool)
+	for _, slot := range s.getRoutes() {
+		if addr := slot.backend.bc.Addr(); addr != "" && !seen[addr] {
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+//返回当前阶段要扫描的后端，from为空时发送到slot所在的后端，addr是实际扫描的后端地址
+func (s *Router) scanAddr(c scanCursor, routes []string) (from, addr string, ok bool) {
+	var owner = s.txSlotAddr(&s.slots[c.slot])
+	switch {
+	case c.phase == 0:
+		from = s.migrateAddr(c.slot)
+		return from, from, from != ""
+	case c.phase == 1:
+		return "", owner, true
+	case int(c.phase-2) < len(routes):
+		addr = routes[c.phase-2]
+		return addr, addr, addr != owner
+	}
+	return "", "", false
+}
+
+//路由的key只在路由后端上返回，其他key只在迁移源和slot所在的后端上返回
+func (s *Router) scanOwns(key []byte, phase uint64, addr string) bool {
+	slot := s.keySlot(key)
+	if slot.prefix == nil {
+		return phase <= 1
+	}
+	return phase != 0 && s.txSlotAddr(slot) == addr
+}
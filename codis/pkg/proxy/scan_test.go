@@ -0,0 +1,189 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//按slot保存key，SLOTSSCAN的游标是slot中key的下标
+type fakeScanServer struct {
+	l     net.Listener
+	slots map[int][]string
+	types map[string]string
+}
+
+func newFakeScanServer(types map[string]string) *fakeScanServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	f := &fakeScanServer{l: l, slots: make(map[int][]string), types: types}
+	for key := range types {
+		id := int(Hash([]byte(key)) % MaxSlotNum)
+		f.slots[id] = append(f.slots[id], key)
+	}
+	for _, keys := range f.slots {
+		sort.Strings(keys)
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return f
+}
+
+func (f *fakeScanServer) serve(c *redis.Conn) {
+	defer c.Close()
+	for {
+		multi, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		var resp = RespOK
+		switch strings.ToUpper(string(multi[0].Value)) {
+		case "SLOTSSCAN":
+			slot, _ := strconv.Atoi(string(multi[1].Value))
+			cursor, _ := strconv.Atoi(string(multi[2].Value))
+			count, _ := strconv.Atoi(string(multi[4].Value))
+			keys := f.slots[slot]
+			var next = cursor + count
+			if next >= len(keys) {
+				next = len(keys)
+			}
+			var array []*redis.Resp
+			for _, key := range keys[cursor:next] {
+				array = append(array, redis.NewBulkBytes([]byte(key)))
+			}
+			if next == len(keys) {
+				next = 0
+			}
+			resp = redis.NewArray([]*redis.Resp{
+				redis.NewBulkBytes([]byte(strconv.Itoa(next))), redis.NewArray(array),
+			})
+		case "TYPE":
+			typ, ok := f.types[string(multi[1].Value)]
+			if !ok {
+				typ = "none"
+			}
+			resp = redis.NewString([]byte(typ))
+		}
+		c.Encode(resp, true)
+	}
+}
+
+func scanAll(s *Session, d *Router, args ...string) []string {
+	var keys []string
+	var cursor = "0"
+	for {
+		resp := doTxRequest(s, d, append([]string{"SCAN", cursor}, args...)...)
+		assert.Must(resp.IsArray() && len(resp.Array) == 2)
+		for _, x := range resp.Array[1].Array {
+			keys = append(keys, string(x.Value))
+		}
+		if cursor = string(resp.Array[0].Value); cursor == "0" {
+			break
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestScan(x *testing.T) {
+	var migrating = "{m}1"
+	var slot = int(Hash([]byte(migrating)) % MaxSlotNum)
+
+	//slot迁移时一部分key还在迁移源上
+	f1 := newFakeScanServer(map[string]string{
+		"a1": "string", "a2": "list", "a3": "string", "{m}1": "hash",
+	})
+	defer f1.l.Close()
+	f2 := newFakeScanServer(map[string]string{
+		"{m}2": "string", "{m}3": "string",
+	})
+	defer f2.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	for i := 0; i < MaxSlotNum; i++ {
+		m := &models.Slot{Id: i, BackendAddr: f1.l.Addr().String(), ForwardMethod: models.ForwardSync}
+		if i == slot {
+			m.BackendAddr, m.MigrateFrom = f2.l.Addr().String(), f1.l.Addr().String()
+		}
+		assert.MustNoError(router.FillSlot(m))
+	}
+	for _, i := range []int{0, slot} {
+		bc := router.slots[i].backend.bc.BackendConn(0, uint(i), false, true)
+		assert.Must(waitFor(bc.IsConnected))
+	}
+
+	s := newHelloSession("")
+
+	keys := scanAll(s, router, "COUNT", "2")
+	assert.Must(strings.Join(keys, ",") == "a1,a2,a3,{m}1,{m}2,{m}3")
+
+	keys = scanAll(s, router, "MATCH", "{m}*")
+	assert.Must(strings.Join(keys, ",") == "{m}1,{m}2,{m}3")
+
+	keys = scanAll(s, router, "TYPE", "STRING")
+	assert.Must(strings.Join(keys, ",") == "a1,a3,{m}2,{m}3")
+
+	//游标包含slot和后端游标
+	var cursor = scanCursor{slot: slot, phase: 1, cursor: 1}
+	resp := doTxRequest(s, router, "SCAN", string(cursor.encode()), "COUNT", "1")
+	assert.Must(len(resp.Array[1].Array) == 1 && string(resp.Array[1].Array[0].Value) == "{m}3")
+
+	assert.Must(doTxRequest(s, router, "SCAN", "x").IsError())
+	assert.Must(doTxRequest(s, router, "SCAN", "0", "COUNT").IsError())
+	assert.Must(doTxRequest(s, router, "SCAN", "0", "COUNT", "0").IsError())
+}
+
+func TestScanPrefixRoute(x *testing.T) {
+	//big:0是添加路由之前写入的，通过路由已经访问不到
+	f1 := newFakeScanServer(map[string]string{
+		"a1": "string", "a2": "list", "big:0": "string",
+	})
+	defer f1.l.Close()
+	f2 := newFakeScanServer(map[string]string{
+		"big:1": "string", "big:2": "hash",
+	})
+	defer f2.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	for i := 0; i < MaxSlotNum; i++ {
+		assert.MustNoError(router.FillSlot(&models.Slot{Id: i, BackendAddr: f1.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	}
+	assert.MustNoError(router.FillSlot(&models.Slot{
+		Prefix: "big:", BackendAddr: f2.l.Addr().String(), BackendAddrGroupId: 2, ForwardMethod: models.ForwardSync,
+	}))
+	assert.MustNoError(router.FillSlot(&models.Slot{
+		Prefix: "a2", BackendAddr: f1.l.Addr().String(), BackendAddrGroupId: 1, ForwardMethod: models.ForwardSync,
+	}))
+	for _, slot := range []*Slot{&router.slots[0], router.getRoutes()[0]} {
+		assert.Must(waitFor(slot.backend.bc.BackendConn(0, 0, false, true).IsConnected))
+	}
+
+	s := newHelloSession("")
+
+	//路由到slot所在后端的key不会重复返回
+	keys := scanAll(s, router, "COUNT", "2")
+	assert.Must(strings.Join(keys, ",") == "a1,a2,big:1,big:2")
+
+	keys = scanAll(s, router, "TYPE", "string")
+	assert.Must(strings.Join(keys, ",") == "a1,big:1")
+}
@@ -0,0 +1,287 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+type ScriptStats struct {
+	Scripts   int   `json:"scripts"`
+	Loads     int64 `json:"loads"`
+	Fallbacks int64 `json:"fallbacks"`
+}
+
+//EVAL和SCRIPT LOAD执行过的脚本，由loopScripts加载到所有后端
+//后端重启后丢失了脚本时，EVALSHA返回NOSCRIPT后用EVAL重试
+var scriptCache struct {
+	sync.Mutex
+	scripts map[string][]byte
+	//SCRIPT FLUSH时加一，已经加载到后端的记录失效
+	epoch int64
+
+	//0表示关闭
+	max atomic2.Int64
+
+	loads     atomic2.Int64
+	fallbacks atomic2.Int64
+}
+
+func init() {
+	scriptCache.scripts = make(map[string][]byte)
+}
+
+func ScriptCacheSetMax(n int) {
+	scriptCache.max.Set(int64(n))
+}
+
+func GetScriptStats() *ScriptStats {
+	scriptCache.Lock()
+	defer scriptCache.Unlock()
+	return &ScriptStats{
+		Scripts:   len(scriptCache.scripts),
+		Loads:     scriptCache.loads.Int64(),
+		Fallbacks: scriptCache.fallbacks.Int64(),
+	}
+}
+
+func scriptSHA1(body []byte) string {
+	sum := sha1.Sum(body)
+	return hex.EncodeToString(sum[:])
+}
+
+//超过script_cache_max后不再缓存新的脚本，返回是否已经缓存
+func cacheScript(body []byte) bool {
+	sha := scriptSHA1(body)
+	scriptCache.Lock()
+	defer scriptCache.Unlock()
+	if scriptCache.scripts[sha] != nil {
+		return true
+	}
+	if int64(len(scriptCache.scripts)) >= scriptCache.max.Int64() {
+		return false
+	}
+	scriptCache.scripts[sha] = append([]byte{}, body...)
+	return true
+}
+
+func getScript(sha string) []byte {
+	scriptCache.Lock()
+	defer scriptCache.Unlock()
+	return scriptCache.scripts[strings.ToLower(sha)]
+}
+
+func flushScripts() {
+	scriptCache.Lock()
+	defer scriptCache.Unlock()
+	scriptCache.scripts = make(map[string][]byte)
+	scriptCache.epoch++
+}
+
+func snapshotScripts() (map[string][]byte, int64) {
+	scriptCache.Lock()
+	defer scriptCache.Unlock()
+	var scripts = make(map[string][]byte, len(scriptCache.scripts))
+	for sha, body := range scriptCache.scripts {
+		scripts[sha] = body
+	}
+	return scripts, scriptCache.epoch
+}
+
+func (s *Session) handleEval(r *Request, d *Router) error {
+	if len(r.Multi) >= 2 && cacheScript(r.Multi[1].Value) && s.proxy != nil {
+		s.proxy.startScripts()
+	}
+	return d.dispatch(r)
+}
+
+//后端重启或者新增的后端还没有加载脚本时返回NOSCRIPT，改用EVAL重试一次
+func (s *Session) handleEvalSha(r *Request, d *Router) error {
+	if len(r.Multi) < 2 {
+		return d.dispatch(r)
+	}
+	var sha = string(r.Multi[1].Value)
+	r.Coalesce = func() error {
+		if r.Err != nil || r.Resp == nil || !r.Resp.IsError() || !bytes.HasPrefix(r.Resp.Value, []byte("NOSCRIPT")) {
+			return nil
+		}
+		body := getScript(sha)
+		if body == nil {
+			return nil
+		}
+		scriptCache.fallbacks.Incr()
+
+		sub := &r.MakeSubRequest(1)[0]
+		sub.Multi = append([]*redis.Resp{
+			redis.NewBulkBytes([]byte("EVAL")), redis.NewBulkBytes(body),
+		}, r.Multi[2:]...)
+		sub.OpStr = "EVAL"
+		if err := d.dispatch(sub); err != nil {
+			return err
+		}
+		r.Batch.Wait()
+		r.Resp, r.Err = sub.Resp, sub.Err
+		return nil
+	}
+	return d.dispatch(r)
+}
+
+func (s *Session) handleScript(r *Request, d *Router) error {
+	if len(r.Multi) < 2 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'script' command")
+		return nil
+	}
+	switch subcmd := strings.ToUpper(string(r.Multi[1].Value)); subcmd {
+	case "LOAD":
+		if len(r.Multi) != 3 {
+			r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'script|load' command")
+			return nil
+		}
+		body := r.Multi[2].Value
+		if cacheScript(body) && s.proxy != nil {
+			s.proxy.startScripts()
+		}
+		var sha = scriptSHA1(body)
+		return s.broadcastScript(r, d, redis.NewBulkBytes([]byte(sha)))
+	case "EXISTS":
+		if len(r.Multi) < 3 {
+			r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'script|exists' command")
+			return nil
+		}
+		var array = make([]*redis.Resp, 0, len(r.Multi)-2)
+		for _, x := range r.Multi[2:] {
+			var n int64
+			if getScript(string(x.Value)) != nil {
+				n = 1
+			}
+			array = append(array, redis.NewInt(strconv.AppendInt(nil, n, 10)))
+		}
+		r.Resp = redis.NewArray(array)
+		return nil
+	case "FLUSH":
+		flushScripts()
+		return s.broadcastScript(r, d, RespOK)
+	default:
+		r.Resp = redis.NewErrorf("ERR unknown subcommand '%s'", strings.ToLower(subcmd))
+		return nil
+	}
+}
+
+//SCRIPT LOAD和SCRIPT FLUSH发送到所有后端，都执行成功后返回resp
+//当前不可用的后端由loopScripts在恢复后加载
+func (s *Session) broadcastScript(r *Request, d *Router, resp *redis.Resp) error {
+	addrs := d.BackendAddrs()
+	sub := r.MakeSubRequest(len(addrs))
+	var sent = make([]bool, len(sub))
+	for i := range sub {
+		sub[i].Multi = r.Multi
+		sent[i] = d.dispatchAddr(&sub[i], addrs[i])
+	}
+	r.Coalesce = func() error {
+		for i := range sub {
+			if !sent[i] {
+				continue
+			}
+			if err := sub[i].Err; err != nil {
+				return err
+			}
+			switch x := sub[i].Resp; {
+			case x == nil:
+				return ErrRespIsRequired
+			case x.IsError():
+				r.Resp = redis.NewErrorf("ERR backend %s: %s", addrs[i], x.Value)
+				return nil
+			}
+		}
+		r.Resp = resp
+		return nil
+	}
+	return nil
+}
+
+//包括主库和从库
+func (s *Router) BackendAddrs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var addrs []string
+	for _, p := range []*sharedBackendConnPool{s.pool.primary, s.pool.replica} {
+		for addr := range p.pool {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+//第一次缓存脚本时启动
+func (s *Proxy) startScripts() {
+	s.scripts.Do(func() {
+		go s.loopScripts()
+	})
+}
+
+//把缓存的脚本加载到还没有加载过的后端，包括扩容新增的后端和主从切换后的新主库
+func (s *Proxy) loopScripts() {
+	var loaded = make(map[string]map[string]bool)
+	var last int64
+	for !s.IsClosed() {
+		scripts, epoch := snapshotScripts()
+		if epoch != last {
+			loaded, last = make(map[string]map[string]bool), epoch
+		}
+		//摘除的后端重新加入时需要重新加载
+		var current = make(map[string]map[string]bool)
+		for _, addr := range s.router.BackendAddrs() {
+			if current[addr] = loaded[addr]; current[addr] == nil {
+				current[addr] = make(map[string]bool)
+			}
+			for sha, body := range scripts {
+				if current[addr][sha] {
+					continue
+				}
+				if err := s.router.loadScript(addr, body); err != nil {
+					log.WarnErrorf(err, "load script %s into backend %s failed", sha, addr)
+					break
+				}
+				current[addr][sha] = true
+			}
+		}
+		loaded = current
+		time.Sleep(time.Second)
+	}
+}
+
+func (s *Router) loadScript(addr string, body []byte) error {
+	r := &Request{Batch: &sync.WaitGroup{}}
+	r.Multi = []*redis.Resp{
+		redis.NewBulkBytes([]byte("SCRIPT")),
+		redis.NewBulkBytes([]byte("LOAD")),
+		redis.NewBulkBytes(body),
+	}
+	r.OpStr = "SCRIPT"
+	if !s.dispatchAddr(r, addr) {
+		return errors.Errorf("backend %s is not available", addr)
+	}
+	r.Batch.Wait()
+	switch {
+	case r.Err != nil:
+		return r.Err
+	case r.Resp == nil:
+		return ErrRespIsRequired
+	case r.Resp.IsError():
+		return errors.Errorf("bad script load resp: %s", r.Resp.Value)
+	}
+	scriptCache.loads.Incr()
+	return nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//按sha1保存脚本，EVALSHA找不到脚本时返回NOSCRIPT，执行脚本时返回脚本内容
+type fakeScriptServer struct {
+	sync.Mutex
+	l        net.Listener
+	scripts  map[string]string
+	commands []string
+}
+
+func newFakeScriptServer() *fakeScriptServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	f := &fakeScriptServer{l: l, scripts: make(map[string]string)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return f
+}
+
+func (f *fakeScriptServer) serve(c *redis.Conn) {
+	defer c.Close()
+	for {
+		multi, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, x := range multi {
+			args = append(args, string(x.Value))
+		}
+		f.Lock()
+		f.commands = append(f.commands, strings.ToUpper(strings.Join(args[:2], " ")))
+		var resp = RespOK
+		switch strings.ToUpper(args[0]) + " " + strings.ToUpper(args[1]) {
+		case "SCRIPT LOAD":
+			sha := scriptSHA1([]byte(args[2]))
+			f.scripts[sha] = args[2]
+			resp = redis.NewBulkBytes([]byte(sha))
+		case "SCRIPT FLUSH":
+			f.scripts = make(map[string]string)
+		default:
+			switch strings.ToUpper(args[0]) {
+			case "EVAL":
+				f.scripts[scriptSHA1([]byte(args[1]))] = args[1]
+				resp = redis.NewBulkBytes([]byte(args[1]))
+			case "EVALSHA":
+				if body, ok := f.scripts[args[1]]; ok {
+					resp = redis.NewBulkBytes([]byte(body))
+				} else {
+					resp = redis.NewErrorf("NOSCRIPT No matching script. Please use EVAL.")
+				}
+			}
+		}
+		f.Unlock()
+		c.Encode(resp, true)
+	}
+}
+
+func (f *fakeScriptServer) takeCommands() string {
+	f.Lock()
+	defer f.Unlock()
+	commands := strings.Join(f.commands, ";")
+	f.commands = nil
+	return commands
+}
+
+func TestScripts(x *testing.T) {
+	ScriptCacheSetMax(2)
+	defer ScriptCacheSetMax(0)
+	defer flushScripts()
+
+	f := newFakeScriptServer()
+	defer f.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("k")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: f.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	bc := router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true)
+	assert.Must(waitFor(bc.IsConnected))
+
+	s := newHelloSession("")
+
+	sha := scriptSHA1([]byte("one"))
+	resp := doTxRequest(s, router, "SCRIPT", "LOAD", "one")
+	assert.Must(string(resp.Value) == sha && f.takeCommands() == "SCRIPT LOAD")
+	resp = doTxRequest(s, router, "SCRIPT", "EXISTS", strings.ToUpper(sha), scriptSHA1([]byte("two")))
+	assert.Must(len(resp.Array) == 2 && string(resp.Array[0].Value) == "1" && string(resp.Array[1].Value) == "0")
+
+	//后端丢失了脚本，EVALSHA返回NOSCRIPT后用EVAL重试
+	f.Lock()
+	f.scripts = make(map[string]string)
+	f.Unlock()
+	resp = doTxRequest(s, router, "EVALSHA", sha, "1", "k")
+	assert.Must(string(resp.Value) == "one" && f.takeCommands() == "EVALSHA "+strings.ToUpper(sha)+";EVAL ONE")
+	assert.Must(GetScriptStats().Fallbacks == 1)
+
+	resp = doTxRequest(s, router, "EVALSHA", scriptSHA1([]byte("two")), "1", "k")
+	assert.Must(resp.IsError() && strings.HasPrefix(string(resp.Value), "NOSCRIPT"))
+
+	resp = doTxRequest(s, router, "EVAL", "two", "1", "k")
+	assert.Must(string(resp.Value) == "two" && getScript(scriptSHA1([]byte("two"))) != nil)
+	doTxRequest(s, router, "EVAL", "three", "1", "k")
+	assert.Must(getScript(scriptSHA1([]byte("three"))) == nil && GetScriptStats().Scripts == 2)
+
+	f.Lock()
+	f.scripts = make(map[string]string)
+	f.Unlock()
+	assert.MustNoError(router.loadScript(f.l.Addr().String(), []byte("one")))
+	f.Lock()
+	assert.Must(f.scripts[sha] == "one")
+	f.Unlock()
+
+	f.takeCommands()
+	assert.Must(doTxRequest(s, router, "SCRIPT", "FLUSH") == RespOK)
+	assert.Must(f.takeCommands() == "SCRIPT FLUSH" && GetScriptStats().Scripts == 0)
+	assert.Must(doTxRequest(s, router, "SCRIPT", "KILL").IsError())
+}
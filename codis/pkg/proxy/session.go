@@ -8,13 +8,16 @@ import (
 	"fmt"
 	"math/rand"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/CodisLabs/codis/pkg/models"
 	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils"
 	"github.com/CodisLabs/codis/pkg/utils/errors"
 	"github.com/CodisLabs/codis/pkg/utils/log"
 	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
@@ -51,6 +54,50 @@ type Session struct {
 	rand *rand.Rand
 
 	authorized bool
+
+	//客户端声明的单个请求截止时间，0表示不限制
+	deadline time.Duration
+
+	//CLIENT SETNAME设置的名字，client为该名字对应的统计
+	name   string
+	client atomic.Value
+	//客户端IP对应的统计，关闭时为nil
+	addr *clientAddrCounters
+
+	//用租户的密码认证后绑定的租户名，空表示不是租户
+	namespace string
+	//用AUTH <name> <password>认证后绑定的用户名
+	user string
+
+	//HELLO返回的连接id
+	id int64
+	//客户端通过HELLO 3切换到了RESP3，slot通知的goroutine也会读取
+	resp3 atomic2.Bool
+
+	//开启了CLIENT TRACKING，状态保存在trackingTable中
+	tracking bool
+
+	//订阅了slot变化通知、失效通知或者后端的频道，RESP2时只能执行(P)SUBSCRIBE、(P)UNSUBSCRIBE、PING和QUIT
+	subscribed    bool
+	invalidations bool
+	channels      map[string]bool
+	patterns      map[string]bool
+	tasks         *RequestChan
+
+	//MULTI/EXEC的状态，只在loopReader中访问
+	tx txState
+
+	//写过的slot及写入时所在的主库，WAIT只发送到这些主库，只在loopReader中访问
+	written map[int]string
+
+	//READONLY/READWRITE设置的读取方式，默认由backend_primary_only决定
+	readMode int
+
+	//阻塞命令使用的独立后端连接，客户端断开时取消
+	blocking struct {
+		sync.Mutex
+		conns map[*BackendConn]bool
+	}
 }
 
 func (s *Session) String() string {
@@ -67,6 +114,8 @@ func (s *Session) String() string {
 	return string(b)
 }
 
+var sessionId atomic2.Int64
+
 func NewSession(sock net.Conn, config *Config, proxy  *Proxy) *Session {
 	c := redis.NewConn(sock,
 		config.SessionRecvBufsize.AsInt(),
@@ -79,8 +128,10 @@ func NewSession(sock net.Conn, config *Config, proxy  *Proxy) *Session {
 	s := &Session{
 		Conn: c, config: config, proxy: proxy,
 		CreateUnix: time.Now().Unix(),
+		id:         sessionId.Incr(),
 	}
 	s.stats.opmap = make(map[string]*opStats, 16)
+	s.client.Store(getClientCounters(""))
 	s.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
 	log.Debugf("session [%p] create: %s", s, s)
 	return s
@@ -140,14 +191,23 @@ func (s *Session) Start(d *Router) {
 		}
 
 		tasks := NewRequestChanBuffer(1024)
+		s.tasks = tasks
+		s.addr = acquireClientAddrCounters(s.Conn.RemoteAddr())
 
 		go func() {
 			s.loopWriter(tasks)
 			decrSessions()
+			releaseClientAddrCounters(s.addr)
 		}()
 
 		go func() {
 			s.loopReader(tasks, d)
+			unsubscribeSlots(s)
+			unsubscribeInvalidate(s)
+			untrackSession(s)
+			s.unsubscribePubSub()
+			s.resetTx()
+			s.cancelBlocking()
 			tasks.Close()
 		}()
 	})
@@ -185,8 +245,18 @@ func (s *Session) loopReader(tasks *RequestChan, d *Router) (err error) {
 		r.Database = s.database
 		r.ReceiveTime = start.UnixNano()
 		r.TasksLen = int64(tasksLen)
+		r.RESP3 = s.resp3.IsTrue()
+		r.ReplicaRead = s.replicaRead()
+		if s.deadline > 0 {
+			r.Deadline = start.Add(s.deadline).UnixNano()
+		}
+		throttleWait(r)
 
-		if err := s.handleRequest(r, d); err != nil {
+		err = s.handleRequest(r, d)
+		if r.Deadline != 0 {
+			r.waitBatchAsync()
+		}
+		if err != nil {
 			r.Resp = redis.NewErrorf("ERR handle request, %s", err)
 			tasks.PushBack(r)
 			if breakOnFailure {
@@ -203,6 +273,7 @@ func (s *Session) loopWriter(tasks *RequestChan) (err error) {
 	defer func() {
 		s.CloseWithError(err)
 		tasks.PopFrontAllVoid(func(r *Request) {
+			throttleDone(r)
 			s.incrOpFails(r, nil)
 		})
 	}()
@@ -218,6 +289,7 @@ func (s *Session) loopWriter(tasks *RequestChan) (err error) {
 	p.MaxBuffered = maxPipelineLen / 2
 
 	return tasks.PopFrontAll(func(r *Request) error {
+		defer throttleDone(r)
 		resp, err := s.handleResponse(r)
 		if err != nil {
 			resp = redis.NewErrorf("ERR handle response, %s", err)
@@ -225,15 +297,33 @@ func (s *Session) loopWriter(tasks *RequestChan) (err error) {
 				s.Conn.Encode(resp, true)
 				return s.incrOpFails(r, err)
 			}
+		} else {
+			resp = onMiddlewareResponse(r, s, resp)
+		}
+		if !r.RESP3 {
+			resp = resp.ToRESP2()
 		}
 		if err := p.Encode(resp); err != nil {
 			return s.incrOpFails(r, err)
 		}
+		for _, x := range r.Replies {
+			if !r.RESP3 {
+				x = x.ToRESP2()
+			}
+			if err := p.Encode(x); err != nil {
+				return s.incrOpFails(r, err)
+			}
+		}
 		fflush := tasks.IsEmpty()
 		if err := p.Flush(fflush); err != nil {
 			return s.incrOpFails(r, err)
 		} else {
 			s.incrOpStats(r, resp.Type)
+			s.incrNamespaceStats(r, resp)
+			recordBigKey(r, resp)
+			if s.addr != nil {
+				s.addr.incr(r, resp, time.Now().UnixNano()-r.ReceiveTime)
+			}
 		}
 
 		//监控响应
@@ -267,7 +357,7 @@ func (s *Session) loopWriter(tasks *RequestChan) (err error) {
 					time.Unix(r.ReceiveTime/1e9, 0).Format("2006-01-02 15:04:05"), s.Conn.RemoteAddr(), r.ReceiveTime/1e3, d0, d1, d2, duration, r.TasksLen, string(cmd[:index]))
 				log.Warnf("%s", cmdLog)
 				if s.config.SlowlogMaxLen > 0 {
-					XSlowlogPushFront(&XSlowlogEntry{XSlowlogGetCurId(), r.ReceiveTime/1e3, duration, cmdLog})
+					XSlowlogPushFront(newXSlowlogEntry(r, s.Conn.RemoteAddr(), duration, cmdLog))
 				}
 			}
 		}
@@ -276,21 +366,52 @@ func (s *Session) loopWriter(tasks *RequestChan) (err error) {
 }
 
 func (s *Session) handleResponse(r *Request) (*redis.Resp, error) {
-	r.Batch.Wait()
-	if r.Coalesce != nil {
-		if err := r.Coalesce(); err != nil {
-			return nil, err
+	if err := s.waitResponse(r); err != nil {
+		//超时只影响当前请求，不关闭session
+		if err == ErrRequestDeadlineExceeded {
+			return redis.NewErrorf("ERR handle response, %s", err), nil
 		}
+		return nil, err
 	}
-	if err := r.Err; err != nil {
+	if err := r.Err; err == ErrRequestDeadlineExceeded {
+		return redis.NewErrorf("ERR handle response, %s", err), nil
+	} else if err != nil {
 		return nil, err
 	} else if r.Resp == nil {
 		return nil, ErrRespIsRequired
 	}
+	if r.CacheFill != nil {
+		r.CacheFill(r.Resp)
+	}
 
 	return r.Resp, nil
 }
 
+//有截止时间的请求最多等到截止时间，超时之后请求仍然可能在后端执行，回复被丢弃
+//截止时间之前已经收到的回复照常返回，已经执行的写命令不会返回超时
+func (s *Session) waitResponse(r *Request) error {
+	if r.Deadline == 0 {
+		r.Batch.Wait()
+	} else {
+		r.waitBatchAsync()
+		timer := time.NewTimer(time.Until(time.Unix(0, r.Deadline)))
+		defer timer.Stop()
+		select {
+		case <-r.batchDone:
+		case <-timer.C:
+			select {
+			case <-r.batchDone:
+			default:
+				return ErrRequestDeadlineExceeded
+			}
+		}
+	}
+	if r.Coalesce != nil {
+		return r.Coalesce()
+	}
+	return nil
+}
+
 func (s *Session) handleRequest(r *Request, d *Router) error {
 	opstr, flag, flagMonitor, customCheckFunc, err := getOpInfo(r.Multi)
 	if err != nil {
@@ -302,6 +423,10 @@ func (s *Session) handleRequest(r *Request, d *Router) error {
 	r.CustomCheckFunc = customCheckFunc
 	r.Broken = &s.broken
 
+	if s.tx.active && !txImmediateCommands[opstr] {
+		defer s.checkTxQueued(r)
+	}
+
 	if flag.IsNotAllowed() {
 		return fmt.Errorf("command '%s' is not allowed", opstr)
 	}
@@ -311,16 +436,96 @@ func (s *Session) handleRequest(r *Request, d *Router) error {
 		return s.handleQuit(r)
 	case "AUTH":
 		return s.handleAuth(r)
+	case "HELLO":
+		return s.handleHello(r)
 	}
 
 	if !s.authorized {
-		if s.config.SessionAuth != "" {
+		if s.config.SessionAuth != "" || hasNamespaces() || hasACLUsers() {
 			r.Resp = redis.NewErrorf("NOAUTH Authentication required")
 			return nil
 		}
 		s.authorized = true
 	}
 
+	if s.user != "" {
+		u := getACLUser(s.user)
+		if u == nil {
+			r.Resp = redis.NewErrorf("NOAUTH user '%s' doesn't exist", s.user)
+			return nil
+		}
+		if resp := u.check(r); resp != nil {
+			r.Resp = resp
+			return nil
+		}
+	}
+
+	if s.namespace != "" {
+		ns := getNamespace(s.namespace)
+		if ns == nil {
+			r.Resp = redis.NewErrorf("NOAUTH namespace '%s' doesn't exist", s.namespace)
+			return nil
+		}
+		if resp := ns.check(r, time.Now()); resp != nil {
+			r.Resp = resp
+			return nil
+		}
+	}
+
+	if resp := s.checkClusterMoved(r); resp != nil {
+		r.Resp = resp
+		return nil
+	}
+
+	if s.numSubscriptions() != 0 && !s.resp3.IsTrue() {
+		switch opstr {
+		case "SUBSCRIBE", "UNSUBSCRIBE", "PSUBSCRIBE", "PUNSUBSCRIBE":
+		case "PING":
+			r.Resp = redis.NewArray([]*redis.Resp{
+				redis.NewBulkBytes([]byte("pong")),
+				redis.NewBulkBytes([]byte{}),
+			})
+			return nil
+		default:
+			r.Resp = redis.NewErrorf("ERR only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+			return nil
+		}
+	}
+
+	if IsReadOnly() && !flag.IsReadOnly() {
+		r.Resp = redis.NewErrorf("READONLY You can't write against a read only standby.")
+		return nil
+	}
+
+	if resp := s.checkRateLimit(r); resp != nil {
+		r.Resp = resp
+		return nil
+	}
+
+	if resp := onMiddlewareRequest(r, s); resp != nil {
+		r.Resp = resp
+		return nil
+	}
+	if resp := checkRequestFilter(r); resp != nil {
+		r.Resp = resp
+		return nil
+	}
+
+	if !flag.IsReadOnly() {
+		if q := checkKeyQuota(r); q != nil {
+			r.Resp = redis.NewErrorf("ERR write quota exceeded for key prefix '%s'", q.Prefix)
+			return nil
+		}
+		rewriteTTL(r)
+		s.recordWrite(r, d)
+		invalidateHotCache(r)
+		if len(r.Multi) >= 2 {
+			if rule := matchWaitRule(r.Multi[1].Value); rule != nil {
+				defer s.requireReplicas(r, d, rule)
+			}
+		}
+	}
+
 	//监控请求
 	var isBigRequest bool = false
 	if IsMonitorEnable() {
@@ -333,6 +538,18 @@ func (s *Session) handleRequest(r *Request, d *Router) error {
 		}
 	}
 
+	if s.tracking {
+		s.trackRequest(r)
+	}
+
+	if s.tx.active && !txImmediateCommands[opstr] {
+		return s.queueTxRequest(r, d)
+	}
+
+	if (opstr == "GET" || opstr == "MGET") && s.serveHotCache(r) {
+		return nil
+	}
+
 	switch opstr {
 	case "SELECT":
 		return s.handleSelect(r)
@@ -342,6 +559,30 @@ func (s *Session) handleRequest(r *Request, d *Router) error {
 		return s.handleXSlowlog(r)
 	case "XCONFIG":
 		return s.handleXConfig(r)
+	case "XDEADLINE":
+		return s.handleXDeadline(r)
+	case "CLIENT":
+		return s.handleClient(r)
+	case "SUBSCRIBE":
+		return s.handleSubscribe(r)
+	case "UNSUBSCRIBE":
+		return s.handleUnsubscribe(r)
+	case "PSUBSCRIBE":
+		return s.handlePSubscribe(r)
+	case "PUNSUBSCRIBE":
+		return s.handlePUnsubscribe(r)
+	case "PUBSUB":
+		return s.handlePubSub(r)
+	case "MULTI":
+		return s.handleMulti(r)
+	case "EXEC":
+		return s.handleExec(r, d)
+	case "DISCARD":
+		return s.handleDiscard(r)
+	case "WATCH":
+		return s.handleWatch(r, d)
+	case "UNWATCH":
+		return s.handleUnwatch(r)
 	case "PING":
 		return s.handleRequestPing(r, d)
 	case "INFO":
@@ -356,13 +597,35 @@ func (s *Session) handleRequest(r *Request, d *Router) error {
 			return nil
 		}
 		return s.handleRequestMSet(r, d)
-	case "DEL":
+	case "DEL", "UNLINK", "TOUCH":
 		if IfDegradateService(r, isBigRequest, s.rand) { // 熔断降级
 			return nil
 		}
 		return s.handleRequestDel(r, d)
 	case "EXISTS":
 		return s.handleRequestExists(r, d)
+	case "EVAL":
+		if IfDegradateService(r, isBigRequest, s.rand) { // 熔断降级
+			return nil
+		}
+		return s.handleEval(r, d)
+	case "EVALSHA":
+		if IfDegradateService(r, isBigRequest, s.rand) { // 熔断降级
+			return nil
+		}
+		return s.handleEvalSha(r, d)
+	case "SCRIPT":
+		return s.handleScript(r, d)
+	case "SCAN":
+		return s.handleScan(r, d)
+	case "XREAD", "XREADGROUP":
+		return s.handleStreamRead(r, d)
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE":
+		return s.handleBlockingList(r, d)
+	case "WAIT":
+		return s.handleWait(r, d)
+	case "READONLY", "READWRITE":
+		return s.handleReadMode(r)
 	case "SLOTSINFO":
 		return s.handleRequestSlotsInfo(r, d)
 	case "SLOTSSCAN":
@@ -371,10 +634,15 @@ func (s *Session) handleRequest(r *Request, d *Router) error {
 		return s.handleRequestSlotsMapping(r, d)
 	case "CLUSTER":
 		return s.handleCluster(r)
+	case "ASKING":
+		return s.handleAsking(r)
 	default:
 		if IfDegradateService(r, isBigRequest, s.rand) { // 熔断降级
 			return nil
 		}
+		if s.isHedgeable(r) {
+			return s.dispatchHedged(r, d)
+		}
 		return d.dispatch(r)
 	}
 }
@@ -386,23 +654,122 @@ func (s *Session) handleQuit(r *Request) error {
 }
 
 func (s *Session) handleAuth(r *Request) error {
-	if len(r.Multi) != 2 {
+	switch len(r.Multi) {
+	case 2:
+	case 3:
+		return s.handleAuthUser(r, string(r.Multi[1].Value), string(r.Multi[2].Value))
+	default:
 		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'AUTH' command")
 		return nil
 	}
+	return s.handleAuthPassword(r, string(r.Multi[1].Value))
+}
+
+//AUTH <password>
+func (s *Session) handleAuthPassword(r *Request, password string) error {
 	switch {
-	case s.config.SessionAuth == "":
+	case s.config.SessionAuth != "" && s.config.SessionAuth == password:
+		s.authorized, s.namespace, s.user = true, "", ""
+		r.Resp = RespOK
+	case getNamespaceByPassword(password) != nil:
+		s.authorized, s.namespace, s.user = true, getNamespaceByPassword(password).Name, ""
+		r.Resp = RespOK
+	case getACLUser(aclDefaultUser) != nil:
+		return s.handleAuthUser(r, aclDefaultUser, password)
+	case s.config.SessionAuth == "" && !hasNamespaces() && !hasACLUsers():
 		r.Resp = redis.NewErrorf("ERR Client sent AUTH, but no password is set")
-	case s.config.SessionAuth != string(r.Multi[1].Value):
-		s.authorized = false
-		r.Resp = redis.NewErrorf("ERR invalid password")
 	default:
-		s.authorized = true
+		s.authorized, s.namespace, s.user = false, "", ""
+		r.Resp = redis.NewErrorf("ERR invalid password")
+	}
+	return nil
+}
+
+//AUTH <name> <password>
+func (s *Session) handleAuthUser(r *Request, name, password string) error {
+	if u := authACLUser(name, password); u != nil {
+		s.authorized, s.namespace, s.user = true, "", u.Name
 		r.Resp = RespOK
+	} else {
+		s.authorized, s.namespace, s.user = false, "", ""
+		r.Resp = redis.NewErrorf("WRONGPASS invalid username-password pair or user is disabled.")
 	}
 	return nil
 }
 
+//HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (s *Session) handleHello(r *Request) error {
+	var proto = 2
+	if s.resp3.IsTrue() {
+		proto = 3
+	}
+	var args = r.Multi[1:]
+	if len(args) != 0 {
+		v, err := redis.Btoi64(args[0].Value)
+		if err != nil {
+			r.Resp = redis.NewErrorf("ERR Protocol version is not an integer or out of range")
+			return nil
+		}
+		if v != 2 && v != 3 {
+			r.Resp = redis.NewErrorf("NOPROTO unsupported protocol version")
+			return nil
+		}
+		proto, args = int(v), args[1:]
+	}
+
+	var auth []string
+	var name *string
+	for len(args) != 0 {
+		switch opt := strings.ToUpper(string(args[0].Value)); {
+		case opt == "AUTH" && len(args) >= 3:
+			auth = []string{string(args[1].Value), string(args[2].Value)}
+			args = args[3:]
+		case opt == "SETNAME" && len(args) >= 2:
+			x := string(args[1].Value)
+			if !isValidClientName(x) {
+				r.Resp = redis.NewErrorf("ERR Client names cannot contain spaces, newlines or special characters.")
+				return nil
+			}
+			name, args = &x, args[2:]
+		default:
+			r.Resp = redis.NewErrorf("ERR Syntax error in HELLO option '%s'", opt)
+			return nil
+		}
+	}
+
+	if auth != nil {
+		//default用户与AUTH <password>相同，兼容只配置了密码的客户端
+		if auth[0] == aclDefaultUser {
+			s.handleAuthPassword(r, auth[1])
+		} else {
+			s.handleAuthUser(r, auth[0], auth[1])
+		}
+		if r.Resp.IsError() {
+			return nil
+		}
+	} else if !s.authorized && (s.config.SessionAuth != "" || hasNamespaces() || hasACLUsers()) {
+		r.Resp = redis.NewErrorf("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+		return nil
+	}
+	if name != nil {
+		s.name = *name
+		s.client.Store(getClientCounters(*name))
+	}
+
+	s.resp3.Set(proto == 3)
+	r.RESP3 = proto == 3
+	r.Resp = redis.NewMap([]*redis.Resp{
+		redis.NewBulkBytes([]byte("server")), redis.NewBulkBytes([]byte("codis-proxy")),
+		redis.NewBulkBytes([]byte("version")), redis.NewBulkBytes([]byte(utils.Version)),
+		redis.NewBulkBytes([]byte("proto")), redis.NewInt([]byte(strconv.Itoa(proto))),
+		redis.NewBulkBytes([]byte("id")), redis.NewInt([]byte(strconv.FormatInt(s.id, 10))),
+		redis.NewBulkBytes([]byte("mode")), redis.NewBulkBytes([]byte("standalone")),
+		redis.NewBulkBytes([]byte("role")), redis.NewBulkBytes([]byte("master")),
+		redis.NewBulkBytes([]byte("modules")), redis.NewArray([]*redis.Resp{}),
+	})
+	return nil
+}
+
 func (s *Session) handleSelect(r *Request) error {
 	if len(r.Multi) != 2 {
 		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'SELECT' command")
@@ -590,6 +957,266 @@ func (s *Session) handleXConfig(r *Request) error {
 	return nil
 }
 
+//xdeadline [milliseconds], 0 means no deadline
+func (s *Session) handleXDeadline(r *Request) error {
+	switch len(r.Multi) {
+	case 1:
+		r.Resp = redis.NewInt(strconv.AppendInt(nil, int64(s.deadline/time.Millisecond), 10))
+	case 2:
+		ms, err := strconv.ParseInt(string(r.Multi[1].Value), 10, 64)
+		if err != nil || ms < 0 {
+			r.Resp = redis.NewErrorf("ERR invalid xdeadline milliseconds")
+			return nil
+		}
+		s.deadline = time.Duration(ms) * time.Millisecond
+		r.Resp = RespOK
+	default:
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'XDEADLINE' command")
+	}
+	return nil
+}
+
+//CLIENT SETNAME和CLIENT GETNAME的名字用于按业务方统计，TRACKING等见tracking.go
+func (s *Session) handleClient(r *Request) error {
+	if len(r.Multi) < 2 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'CLIENT' command")
+		return nil
+	}
+	switch sub := strings.ToUpper(string(r.Multi[1].Value)); {
+	case sub == "SETNAME" && len(r.Multi) == 3:
+		name := string(r.Multi[2].Value)
+		if !isValidClientName(name) {
+			r.Resp = redis.NewErrorf("ERR Client names cannot contain spaces, newlines or special characters.")
+			return nil
+		}
+		s.name = name
+		s.client.Store(getClientCounters(name))
+		r.Resp = RespOK
+	case sub == "GETNAME" && len(r.Multi) == 2:
+		if s.name == "" {
+			r.Resp = redis.NewBulkBytes(nil)
+		} else {
+			r.Resp = redis.NewBulkBytes([]byte(s.name))
+		}
+	case sub == "ID" && len(r.Multi) == 2:
+		r.Resp = redis.NewInt([]byte(strconv.FormatInt(s.id, 10)))
+	case sub == "TRACKING":
+		return s.handleClientTracking(r)
+	case sub == "CACHING":
+		return s.handleClientCaching(r)
+	case sub == "GETREDIR" && len(r.Multi) == 2:
+		return s.handleClientGetRedir(r)
+	case sub == "TRACKINGINFO" && len(r.Multi) == 2:
+		return s.handleClientTrackingInfo(r)
+	default:
+		r.Resp = redis.NewErrorf("ERR Unknown CLIENT subcommand or wrong args. Try SETNAME, GETNAME, ID, TRACKING, CACHING, GETREDIR, TRACKINGINFO.")
+	}
+	return nil
+}
+
+//SlotsChannel和InvalidateChannel由proxy处理，其余频道通过pubsubHub订阅后端，每个频道回复一次
+func (s *Session) handleSubscribe(r *Request) error {
+	if len(r.Multi) < 2 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'SUBSCRIBE' command")
+		return nil
+	}
+	var replies []*redis.Resp
+	for _, x := range r.Multi[1:] {
+		switch ch := string(x.Value); {
+		case ch == SlotsChannel:
+			if !s.subscribed {
+				s.subscribed = true
+				subscribeSlots(s)
+			}
+		case ch == InvalidateChannel:
+			if !s.invalidations {
+				s.invalidations = true
+				subscribeInvalidate(s)
+			}
+		case !s.channels[ch]:
+			h := s.pubsub()
+			if h == nil {
+				r.Resp = redis.NewErrorf("ERR pubsub is not available")
+				return nil
+			}
+			if err := h.Subscribe(s, ch); err != nil {
+				return err
+			}
+			if s.channels == nil {
+				s.channels = make(map[string]bool)
+			}
+			s.channels[ch] = true
+		}
+		replies = append(replies, newPubSubResp("subscribe", string(x.Value), s.numSubscriptionsResp()))
+	}
+	r.Resp, r.Replies = replies[0], replies[1:]
+	return nil
+}
+
+//不带参数时取消所有频道的订阅
+func (s *Session) handleUnsubscribe(r *Request) error {
+	var channels []string
+	if len(r.Multi) > 1 {
+		for _, x := range r.Multi[1:] {
+			channels = append(channels, string(x.Value))
+		}
+	} else {
+		if s.subscribed {
+			channels = append(channels, SlotsChannel)
+		}
+		if s.invalidations {
+			channels = append(channels, InvalidateChannel)
+		}
+		channels = append(channels, sortedKeys(s.channels)...)
+	}
+	if len(channels) == 0 {
+		r.Resp = newPubSubNilResp("unsubscribe", s.numSubscriptionsResp())
+		return nil
+	}
+	var replies []*redis.Resp
+	for _, ch := range channels {
+		switch {
+		case ch == SlotsChannel && s.subscribed:
+			s.subscribed = false
+			unsubscribeSlots(s)
+		case ch == InvalidateChannel && s.invalidations:
+			s.invalidations = false
+			unsubscribeInvalidate(s)
+		case s.channels[ch]:
+			delete(s.channels, ch)
+			s.pubsub().Unsubscribe(s, ch)
+		}
+		replies = append(replies, newPubSubResp("unsubscribe", ch, s.numSubscriptionsResp()))
+	}
+	r.Resp, r.Replies = replies[0], replies[1:]
+	return nil
+}
+
+func (s *Session) handlePSubscribe(r *Request) error {
+	if len(r.Multi) < 2 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'PSUBSCRIBE' command")
+		return nil
+	}
+	h := s.pubsub()
+	if h == nil {
+		r.Resp = redis.NewErrorf("ERR pubsub is not available")
+		return nil
+	}
+	var replies []*redis.Resp
+	for _, x := range r.Multi[1:] {
+		if p := string(x.Value); !s.patterns[p] {
+			if err := h.PSubscribe(s, p); err != nil {
+				return err
+			}
+			if s.patterns == nil {
+				s.patterns = make(map[string]bool)
+			}
+			s.patterns[p] = true
+		}
+		replies = append(replies, newPubSubResp("psubscribe", string(x.Value), s.numSubscriptionsResp()))
+	}
+	r.Resp, r.Replies = replies[0], replies[1:]
+	return nil
+}
+
+func (s *Session) handlePUnsubscribe(r *Request) error {
+	var patterns []string
+	if len(r.Multi) > 1 {
+		for _, x := range r.Multi[1:] {
+			patterns = append(patterns, string(x.Value))
+		}
+	} else {
+		patterns = sortedKeys(s.patterns)
+	}
+	if len(patterns) == 0 {
+		r.Resp = newPubSubNilResp("punsubscribe", s.numSubscriptionsResp())
+		return nil
+	}
+	var replies []*redis.Resp
+	for _, p := range patterns {
+		if s.patterns[p] {
+			delete(s.patterns, p)
+			s.pubsub().PUnsubscribe(s, p)
+		}
+		replies = append(replies, newPubSubResp("punsubscribe", p, s.numSubscriptionsResp()))
+	}
+	r.Resp, r.Replies = replies[0], replies[1:]
+	return nil
+}
+
+func (s *Session) handlePubSub(r *Request) error {
+	h := s.pubsub()
+	if h == nil {
+		r.Resp = redis.NewErrorf("ERR pubsub is not available")
+		return nil
+	}
+	h.handlePubSub(r)
+	return nil
+}
+
+func (s *Session) pubsub() *pubsubHub {
+	if s.proxy == nil {
+		return nil
+	}
+	return s.proxy.pubsub
+}
+
+//session关闭时调用
+func (s *Session) unsubscribePubSub() {
+	if h := s.pubsub(); h != nil {
+		for ch := range s.channels {
+			h.Unsubscribe(s, ch)
+		}
+		for p := range s.patterns {
+			h.PUnsubscribe(s, p)
+		}
+	}
+	s.channels, s.patterns = nil, nil
+}
+
+func (s *Session) numSubscriptions() int {
+	var n = len(s.channels) + len(s.patterns)
+	if s.subscribed {
+		n++
+	}
+	if s.invalidations {
+		n++
+	}
+	return n
+}
+
+func (s *Session) numSubscriptionsResp() *redis.Resp {
+	return redis.NewInt([]byte(strconv.Itoa(s.numSubscriptions())))
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//由slot通知的goroutine调用，消息和普通响应一样按顺序由loopWriter发送
+func (s *Session) pushMessage(resp *redis.Resp) {
+	r := &Request{OpStr: "SUBSCRIBE", Batch: &sync.WaitGroup{}}
+	r.ReceiveTime = time.Now().UnixNano()
+	r.RESP3 = s.resp3.IsTrue()
+	r.Resp = resp
+	s.tasks.PushBack(r)
+}
+
+//与redis一致，名字中不能包含空格和不可见字符
+func isValidClientName(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] <= ' ' || name[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Session) handleRequestPing(r *Request, d *Router) error {
 	var addr string
 	var nblks = len(r.Multi) - 1
@@ -639,6 +1266,8 @@ func (s *Session) handleRequestMGet(r *Request, d *Router) error {
 		return nil
 	case nkeys == 1:
 		return d.dispatch(r)
+	case !s.config.SessionSplitMultiKeys:
+		return s.dispatchSameSlot(r, d)
 	}
 	var sub = r.MakeSubRequest(nkeys)
 	for i := range sub {
@@ -646,9 +1275,9 @@ func (s *Session) handleRequestMGet(r *Request, d *Router) error {
 			r.Multi[0],
 			r.Multi[i+1],
 		}
-		if err := d.dispatch(&sub[i]); err != nil {
-			return err
-		}
+	}
+	if err := s.dispatchSubRequests(r, sub, d); err != nil {
+		return err
 	}
 
 	//回调函数会通过r.Batch保证所有的子命令都执行完毕，然后通过Coalesce()检查每个子命令的执行结果
@@ -683,6 +1312,8 @@ func (s *Session) handleRequestMSet(r *Request, d *Router) error {
 		return nil
 	case nblks == 2:
 		return d.dispatch(r)
+	case !s.config.SessionSplitMultiKeys:
+		return s.dispatchSameSlot(r, d)
 	}
 	var sub = r.MakeSubRequest(nblks / 2)
 	for i := range sub {
@@ -691,9 +1322,9 @@ func (s *Session) handleRequestMSet(r *Request, d *Router) error {
 			r.Multi[i*2+1],
 			r.Multi[i*2+2],
 		}
-		if err := d.dispatch(&sub[i]); err != nil {
-			return err
-		}
+	}
+	if err := s.dispatchSubRequests(r, sub, d); err != nil {
+		return err
 	}
 	r.Coalesce = func() error {
 		for i := range sub {
@@ -718,10 +1349,12 @@ func (s *Session) handleRequestDel(r *Request, d *Router) error {
 	var nkeys = len(r.Multi) - 1
 	switch {
 	case nkeys == 0:
-		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'DEL' command")
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for '%s' command", r.OpStr)
 		return nil
 	case nkeys == 1:
 		return d.dispatch(r)
+	case !s.config.SessionSplitMultiKeys:
+		return s.dispatchSameSlot(r, d)
 	}
 	var sub = r.MakeSubRequest(nkeys)
 	for i := range sub {
@@ -729,9 +1362,9 @@ func (s *Session) handleRequestDel(r *Request, d *Router) error {
 			r.Multi[0],
 			r.Multi[i+1],
 		}
-		if err := d.dispatch(&sub[i]); err != nil {
-			return err
-		}
+	}
+	if err := s.dispatchSubRequests(r, sub, d); err != nil {
+		return err
 	}
 	r.Coalesce = func() error {
 		var n int
@@ -745,7 +1378,7 @@ func (s *Session) handleRequestDel(r *Request, d *Router) error {
 			case resp.IsInt() && len(resp.Value) == 1:
 				n += int(resp.Value[0] - '0')
 			default:
-				return fmt.Errorf("bad del resp: %s value.len = %d", resp.Type, len(resp.Value))
+				return fmt.Errorf("bad %s resp: %s value.len = %d", strings.ToLower(r.OpStr), resp.Type, len(resp.Value))
 			}
 		}
 		r.Resp = redis.NewInt(strconv.AppendInt(nil, int64(n), 10))
@@ -762,6 +1395,8 @@ func (s *Session) handleRequestExists(r *Request, d *Router) error {
 		return nil
 	case nkeys == 1:
 		return d.dispatch(r)
+	case !s.config.SessionSplitMultiKeys:
+		return s.dispatchSameSlot(r, d)
 	}
 	var sub = r.MakeSubRequest(nkeys)
 	for i := range sub {
@@ -769,9 +1404,9 @@ func (s *Session) handleRequestExists(r *Request, d *Router) error {
 			r.Multi[0],
 			r.Multi[i+1],
 		}
-		if err := d.dispatch(&sub[i]); err != nil {
-			return err
-		}
+	}
+	if err := s.dispatchSubRequests(r, sub, d); err != nil {
+		return err
 	}
 	r.Coalesce = func() error {
 		var n int
@@ -796,6 +1431,59 @@ func (s *Session) handleRequestExists(r *Request, d *Router) error {
 	return nil
 }
 
+//关闭session_split_multi_keys时不拆分，所有key必须属于同一个slot，前缀路由视为单独的slot
+func (s *Session) dispatchSameSlot(r *Request, d *Router) error {
+	var slot *Slot
+	for _, key := range namespaceKeys(r) {
+		x := d.keySlot(key)
+		if slot != nil && x != slot {
+			r.Resp = redis.NewErrorf("CROSSSLOT Keys in request don't hash to the same slot")
+			return nil
+		}
+		slot = x
+	}
+	return d.dispatch(r)
+}
+
+//子请求数量超过session_max_batch_keys时分批发送，上一批全部返回后再发送下一批，
+//避免一个巨大的multi命令长时间占满后端连接；在读取请求的goroutine中等待前面的批次，
+//最后一批发送之后才会读取后面的请求，保证同一个客户端的请求按顺序到达后端
+func (s *Session) dispatchSubRequests(r *Request, sub []Request, d *Router) error {
+	var size = s.config.SessionMaxBatchKeys
+	if size <= 0 || len(sub) <= size {
+		for i := range sub {
+			if err := d.dispatch(&sub[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	incrOpSplit()
+
+	for i := 0; i < len(sub); i += size {
+		//最后一批使用r.Batch，和不拆分时一样由Coalesce等待
+		var batch *sync.WaitGroup
+		if i+size < len(sub) {
+			batch = &sync.WaitGroup{}
+		}
+		for j := i; j < i+size && j < len(sub); j++ {
+			if r.IsBroken() {
+				return ErrRequestIsBroken
+			}
+			if batch != nil {
+				sub[j].Batch = batch
+			}
+			if err := d.dispatch(&sub[j]); err != nil {
+				return err
+			}
+		}
+		if batch != nil {
+			batch.Wait()
+		}
+	}
+	return nil
+}
+
 func (s *Session) handleRequestSlotsInfo(r *Request, d *Router) error {
 	var addr string
 	var nblks = len(r.Multi) - 1
@@ -887,6 +1575,9 @@ func (s *Session) handleCluster(r *Request) error {
 		r.Resp = redis.NewErrorf("ERR cluster parameters, only support nodes, slots, keyslot now")
 		return nil
 	}
+	if s.config.ClusterMode {
+		return s.handleClusterMode(r)
+	}
 
 	var subCmd = strings.ToUpper(string(r.Multi[1].Value))
 	switch subCmd {
@@ -944,12 +1635,18 @@ func (s *Session) incrOpStats(r *Request, t redis.RespType) {
 			s.stats.opmap[r.OpStr] = e
 		}
 		e.incrOpStats(responseTime, t)
+		e.args.incr(r.Multi)
 		e = s.stats.opmap["ALL"]
 		if e == nil {
 			e = getOpStats("ALL", true)
 			s.stats.opmap["ALL"] = e
 		}
 		e.incrOpStats(responseTime, t)
+		e.args.incr(r.Multi)
+
+		sloRecord(r.OpStr, responseTime, t == redis.TypeError)
+		recordHotKey(r, responseTime)
+		s.client.Load().(*clientCounters).incr(responseTime, t == redis.TypeError, s.config.SlowlogLogSlowerThan)
 
 		switch t {
 		case redis.TypeError:
@@ -969,6 +1666,12 @@ func (s *Session) incrOpFails(r *Request, err error) error {
 	}*/
 
 	incrOpFails(r, err)
+	if r != nil {
+		s.client.Load().(*clientCounters).fails.Incr()
+		if s.addr != nil {
+			s.addr.fails.Incr()
+		}
+	}
 	return err
 }
 
@@ -0,0 +1,221 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func newHelloSession(auth string) *Session {
+	config := newProxyConfig()
+	config.SessionAuth = auth
+	s := &Session{config: config, id: sessionId.Incr()}
+	s.client.Store(getClientCounters(""))
+	return s
+}
+
+func TestSessionHello(x *testing.T) {
+	s := newHelloSession("")
+
+	r := newQuotaRequest("HELLO")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.Type == redis.TypeMap && !r.RESP3 && !s.resp3.IsTrue())
+	assert.Must(r.Resp.ToRESP2().IsArray() && len(r.Resp.ToRESP2().Array) == 14)
+
+	r = newQuotaRequest("HELLO", "3", "SETNAME", "app")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.Type == redis.TypeMap && r.RESP3 && s.resp3.IsTrue() && s.name == "app")
+	assert.Must(string(r.Resp.Array[5].Value) == "3")
+
+	r = newQuotaRequest("HELLO", "4")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.IsError() && s.resp3.IsTrue())
+	r = newQuotaRequest("HELLO", "3", "SETNAME")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.IsError())
+
+	r = newQuotaRequest("HELLO", "2")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(!r.RESP3 && !s.resp3.IsTrue())
+}
+
+func TestSessionHelloAuth(x *testing.T) {
+	defer SetACLUsers(nil)
+	assert.MustNoError(SetACLUsers([]*models.ACLUser{
+		{Name: "app", Password: "pa", Commands: []string{"+@all"}},
+	}))
+
+	s := newHelloSession("secret")
+	r := newQuotaRequest("HELLO", "3")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.IsError() && !s.authorized && !s.resp3.IsTrue())
+
+	r = newQuotaRequest("HELLO", "3", "AUTH", "default", "wrong")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.IsError() && !s.authorized)
+
+	r = newQuotaRequest("HELLO", "3", "AUTH", "default", "secret")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.Type == redis.TypeMap && s.authorized && s.user == "")
+
+	r = newQuotaRequest("HELLO", "3", "AUTH", "app", "pa")
+	assert.MustNoError(s.handleHello(r))
+	assert.Must(r.Resp.Type == redis.TypeMap && s.authorized && s.user == "app")
+}
+
+//只支持GET/SET和多key命令的内存后端
+type fakeKVServer struct {
+	sync.Mutex
+	l    net.Listener
+	data map[string]string
+}
+
+func newFakeKVServer() *fakeKVServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	f := &fakeKVServer{l: l, data: make(map[string]string)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return f
+}
+
+func (f *fakeKVServer) serve(c *redis.Conn) {
+	defer c.Close()
+	for {
+		multi, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, x := range multi[1:] {
+			args = append(args, string(x.Value))
+		}
+		f.Lock()
+		var resp *redis.Resp
+		switch strings.ToUpper(string(multi[0].Value)) {
+		case "SET", "MSET":
+			for i := 0; i+1 < len(args); i += 2 {
+				f.data[args[i]] = args[i+1]
+			}
+			resp = RespOK
+		case "MGET":
+			var array []*redis.Resp
+			for _, key := range args {
+				if v, ok := f.data[key]; ok {
+					array = append(array, redis.NewBulkBytes([]byte(v)))
+				} else {
+					array = append(array, redis.NewBulkBytes(nil))
+				}
+			}
+			resp = redis.NewArray(array)
+		case "DEL", "UNLINK", "EXISTS", "TOUCH":
+			var n int
+			for _, key := range args {
+				if _, ok := f.data[key]; ok {
+					n++
+				}
+				if op := strings.ToUpper(string(multi[0].Value)); op == "DEL" || op == "UNLINK" {
+					delete(f.data, key)
+				}
+			}
+			resp = redis.NewInt([]byte(strconv.Itoa(n)))
+		default:
+			resp = redis.NewErrorf("ERR unknown command")
+		}
+		f.Unlock()
+		c.Encode(resp, true)
+	}
+}
+
+func TestSessionSplitMultiKeys(x *testing.T) {
+	a, b := newFakeKVServer(), newFakeKVServer()
+	defer a.l.Close()
+	defer b.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	for _, x := range []struct {
+		key string
+		f   *fakeKVServer
+	}{{"a", a}, {"b", b}} {
+		var slot = int(Hash([]byte(x.key)) % MaxSlotNum)
+		assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: x.f.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+		bc := router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true)
+		assert.Must(waitFor(bc.IsConnected))
+	}
+
+	s := newHelloSession("")
+	s.config = c
+	assert.Must(doTxRequest(s, router, "MSET", "a", "1", "b", "2").IsString())
+	assert.Must(a.data["a"] == "1" && b.data["b"] == "2")
+
+	resp := doTxRequest(s, router, "MGET", "b", "a", "a")
+	assert.Must(len(resp.Array) == 3 && string(resp.Array[0].Value) == "2" && string(resp.Array[2].Value) == "1")
+	assert.Must(string(doTxRequest(s, router, "EXISTS", "a", "b", "a").Value) == "3")
+	assert.Must(string(doTxRequest(s, router, "TOUCH", "a", "b").Value) == "2")
+	assert.Must(string(doTxRequest(s, router, "UNLINK", "a", "b").Value) == "2")
+	assert.Must(len(a.data) == 0 && len(b.data) == 0)
+
+	//不拆分时key必须属于同一个slot
+	c.SessionSplitMultiKeys = false
+	resp = doTxRequest(s, router, "MSET", "a", "1", "b", "2")
+	assert.Must(resp.IsError() && strings.HasPrefix(string(resp.Value), "CROSSSLOT"))
+	assert.Must(doTxRequest(s, router, "MSET", "{a}1", "1", "{a}2", "2").IsString())
+	assert.Must(a.data["{a}1"] == "1" && a.data["{a}2"] == "2")
+	assert.Must(string(doTxRequest(s, router, "DEL", "{a}1", "{a}2", "a").Value) == "2")
+
+	//hash tag相同但匹配了不同的前缀路由
+	assert.MustNoError(router.FillSlot(&models.Slot{Prefix: "{a}p", BackendAddr: b.l.Addr().String(), BackendAddrGroupId: 2}))
+	route := router.getRoutes()[0]
+	assert.Must(waitFor(route.backend.bc.BackendConn(0, uint(route.id), false, true).IsConnected))
+	resp = doTxRequest(s, router, "MSET", "{a}1", "1", "{a}p1", "2")
+	assert.Must(resp.IsError() && strings.HasPrefix(string(resp.Value), "CROSSSLOT"))
+	assert.Must(doTxRequest(s, router, "MSET", "{a}p1", "1", "{a}p2", "2").IsString())
+	assert.Must(b.data["{a}p1"] == "1" && b.data["{a}p2"] == "2")
+}
+
+func TestSessionSplitBatches(x *testing.T) {
+	f := newFakeKVServer()
+	defer f.l.Close()
+
+	c := newProxyConfig()
+	c.SessionMaxBatchKeys = 2
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("a")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: f.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	bc := router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true)
+	assert.Must(waitFor(bc.IsConnected))
+
+	s := newHelloSession("")
+	s.config = c
+
+	//拆分的MSET全部发送之后才会发送后面的SET，最后的值是SET写入的
+	r := newACLRequest("MSET", "a", "1", "a", "2", "a", "3", "a", "4", "a", "5")
+	r.Batch = &sync.WaitGroup{}
+	assert.MustNoError(s.handleRequest(r, router))
+	assert.Must(doTxRequest(s, router, "SET", "a", "x").IsString())
+	resp, err := s.handleResponse(r)
+	assert.MustNoError(err)
+	assert.Must(resp.IsString())
+	f.Lock()
+	defer f.Unlock()
+	assert.Must(f.data["a"] == "x")
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//SLO窗口，单位: s
+var SLOWindows = []int64{60, 300, 1800, 3600}
+
+const sloRingSize = 3600
+
+//一条SLO规则，例如 GET:10ms:99 表示99%的GET请求在10ms内成功返回
+type sloRule struct {
+	opstr     string
+	latency   time.Duration
+	objective float64
+
+	ring [sloRingSize]struct {
+		total sloCounter
+		bad   sloCounter
+	}
+}
+
+//高32位为计数所属的秒，低32位为计数，进入新的一秒时通过CAS清零，不需要加锁
+type sloCounter struct {
+	v atomic2.Int64
+}
+
+func (c *sloCounter) incr(sec int64) {
+	for {
+		o := c.v.Int64()
+		n := o + 1
+		if uint64(o)>>32 != uint64(uint32(sec)) {
+			n = int64(uint64(uint32(sec))<<32 | 1)
+		}
+		if c.v.CompareAndSwap(o, n) {
+			return
+		}
+	}
+}
+
+func (c *sloCounter) get(sec int64) int64 {
+	v := uint64(c.v.Int64())
+	if v>>32 != uint64(uint32(sec)) {
+		return 0
+	}
+	return int64(v & 0xffffffff)
+}
+
+type SLOWindow struct {
+	Window   int64   `json:"window"`
+	Total    int64   `json:"total"`
+	Bad      int64   `json:"bad"`
+	BurnRate float64 `json:"burn_rate"`
+}
+
+type SLOStatus struct {
+	OpStr     string       `json:"opstr"`
+	LatencyMs int64        `json:"latency_ms"`
+	Objective float64      `json:"objective"`
+	Windows   []*SLOWindow `json:"windows"`
+}
+
+var sloRules atomic.Value
+
+func init() {
+	sloRules.Store(map[string]*sloRule{})
+}
+
+//解析SLO规则，格式为 op:latency:objective，多个规则以逗号分隔，例如 GET:10ms:99,SET:20ms:99.9
+func parseSLORules(value string) (map[string]*sloRule, error) {
+	var rules = make(map[string]*sloRule)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, ":")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("invalid slo rule '%s'", item)
+		}
+		latency, err := time.ParseDuration(fields[1])
+		if err != nil || latency <= 0 {
+			return nil, fmt.Errorf("invalid slo latency '%s'", fields[1])
+		}
+		objective, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil || objective <= 0 || objective >= 100 {
+			return nil, fmt.Errorf("invalid slo objective '%s'", fields[2])
+		}
+		opstr := strings.ToUpper(fields[0])
+		rules[opstr] = &sloRule{
+			opstr: opstr, latency: latency, objective: objective / 100,
+		}
+	}
+	return rules, nil
+}
+
+func SLOSetRules(value string) error {
+	rules, err := parseSLORules(value)
+	if err != nil {
+		return err
+	}
+	sloRules.Store(rules)
+	return nil
+}
+
+//responseTime单位为ns
+func sloRecord(opstr string, responseTime int64, failed bool) {
+	rules := sloRules.Load().(map[string]*sloRule)
+	if len(rules) == 0 {
+		return
+	}
+	if rule := rules[opstr]; rule != nil {
+		rule.record(responseTime, failed)
+	}
+	if rule := rules["ALL"]; rule != nil {
+		rule.record(responseTime, failed)
+	}
+}
+
+func (s *sloRule) record(responseTime int64, failed bool) {
+	var sec = time.Now().Unix()
+	b := &s.ring[sec%sloRingSize]
+	b.total.incr(sec)
+	if failed || time.Duration(responseTime) > s.latency {
+		b.bad.incr(sec)
+	}
+}
+
+func (s *sloRule) status() *SLOStatus {
+	o := &SLOStatus{
+		OpStr:     s.opstr,
+		LatencyMs: int64(s.latency / time.Millisecond),
+		Objective: s.objective * 100,
+	}
+	var now = time.Now().Unix()
+	for _, window := range SLOWindows {
+		w := &SLOWindow{Window: window}
+		for sec := now - window + 1; sec <= now; sec++ {
+			b := &s.ring[sec%sloRingSize]
+			w.Total += b.total.get(sec)
+			w.Bad += b.bad.get(sec)
+		}
+		//burn rate = 错误率 / 错误预算
+		if w.Total != 0 {
+			w.BurnRate = float64(w.Bad) / float64(w.Total) / (1 - s.objective)
+		}
+		o.Windows = append(o.Windows, w)
+	}
+	return o
+}
+
+func GetSLOStatus() []*SLOStatus {
+	rules := sloRules.Load().(map[string]*sloRule)
+	var all = make([]*SLOStatus, 0, len(rules))
+	for _, rule := range rules {
+		all = append(all, rule.status())
+	}
+	sort.Sort(sliceSLOStatus(all))
+	return all
+}
+
+type sliceSLOStatus []*SLOStatus
+
+func (s sliceSLOStatus) Len() int {
+	return len(s)
+}
+
+func (s sliceSLOStatus) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceSLOStatus) Less(i, j int) bool {
+	return s[i].OpStr < s[j].OpStr
+}
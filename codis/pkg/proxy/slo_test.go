@@ -0,0 +1,63 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestParseSLORules(t *testing.T) {
+	rules, err := parseSLORules("get:10ms:99, ALL:50ms:99.9")
+	assert.MustNoError(err)
+	assert.Must(len(rules) == 2)
+	assert.Must(rules["GET"].latency == 10*time.Millisecond)
+	assert.Must(rules["ALL"].objective > 0.9989 && rules["ALL"].objective < 0.9991)
+
+	for _, value := range []string{"GET", "GET:10:99", "GET:10ms:100", "GET:10ms:x"} {
+		_, err := parseSLORules(value)
+		assert.Must(err != nil)
+	}
+}
+
+func TestSLOBurnRate(t *testing.T) {
+	rules, err := parseSLORules("GET:10ms:99")
+	assert.MustNoError(err)
+	rule := rules["GET"]
+	for i := 0; i < 98; i++ {
+		rule.record(int64(time.Millisecond), false)
+	}
+	rule.record(int64(time.Second), false)
+	rule.record(int64(time.Millisecond), true)
+
+	o := rule.status()
+	assert.Must(len(o.Windows) == len(SLOWindows))
+	assert.Must(o.Windows[0].Total == 100 && o.Windows[0].Bad == 2)
+	assert.Must(o.Windows[0].BurnRate > 1.99 && o.Windows[0].BurnRate < 2.01)
+}
+
+func TestSLORecordConcurrent(t *testing.T) {
+	rules, err := parseSLORules("GET:10ms:99")
+	assert.MustNoError(err)
+	rule := rules["GET"]
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				rule.record(int64(time.Millisecond), j%10 == 0)
+				if j%100 == 0 {
+					rule.status()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	o := rule.status()
+	assert.Must(o.Windows[0].Total == 8000 && o.Windows[0].Bad == 800)
+}
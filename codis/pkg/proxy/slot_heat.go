@@ -0,0 +1,41 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//slot的累计访问次数与请求字节数，dashboard定期拉取并按差值计算负载
+type SlotHeat struct {
+	Id    int   `json:"id"`
+	Ops   int64 `json:"ops"`
+	Bytes int64 `json:"bytes"`
+}
+
+var slotHeat [MaxSlotNum]struct {
+	ops, bytes atomic2.Int64
+}
+
+func incrSlotHeat(id int, r *Request) {
+	var n int
+	for _, x := range r.Multi {
+		n += len(x.Value)
+	}
+	slotHeat[id].ops.Incr()
+	slotHeat[id].bytes.Add(int64(n))
+}
+
+//只返回有访问的slot
+func GetSlotHeat() []*SlotHeat {
+	var list []*SlotHeat
+	for i := range slotHeat {
+		ops := slotHeat[i].ops.Int64()
+		if ops == 0 {
+			continue
+		}
+		list = append(list, &SlotHeat{Id: i, Ops: ops, Bytes: slotHeat[i].bytes.Int64()})
+	}
+	return list
+}
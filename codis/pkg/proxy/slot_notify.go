@@ -0,0 +1,148 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+)
+
+//订阅该频道的客户端在slot表变化（迁移、主从切换）后会收到消息，内容为变化的slot列表
+const SlotsChannel = "__codis__:slots"
+
+//迁移时会短时间内连续修改多个slot，合并后再通知
+const slotNotifyDelay = time.Millisecond * 100
+
+type SlotsChangedEvent struct {
+	Version int64 `json:"version"`
+	Slots   []int `json:"slots"`
+}
+
+type slotNotifier struct {
+	sync.Mutex
+	pending  map[int]bool
+	timer    *time.Timer
+	version  int64
+	sessions map[*Session]bool
+
+	listeners map[int]func(slots []int)
+	nextId    int
+}
+
+func newSlotNotifier() *slotNotifier {
+	return &slotNotifier{
+		pending:   make(map[int]bool),
+		sessions:  make(map[*Session]bool),
+		listeners: make(map[int]func(slots []int)),
+	}
+}
+
+var slotNotify = newSlotNotifier()
+
+//注册slot变化的回调，供本地缓存等在slot变化后清理对应的数据，返回取消注册的函数
+func RegisterSlotListener(fn func(slots []int)) func() {
+	return slotNotify.register(fn)
+}
+
+func subscribeSlots(s *Session) {
+	slotNotify.subscribe(s)
+}
+
+//取消订阅后不会再向session推送消息，session关闭前必须调用
+func unsubscribeSlots(s *Session) {
+	slotNotify.unsubscribe(s)
+}
+
+func notifySlotChanged(id int) {
+	slotNotify.notify(id)
+}
+
+func (n *slotNotifier) register(fn func(slots []int)) func() {
+	n.Lock()
+	defer n.Unlock()
+	n.nextId++
+	id := n.nextId
+	n.listeners[id] = fn
+	return func() {
+		n.Lock()
+		defer n.Unlock()
+		delete(n.listeners, id)
+	}
+}
+
+func (n *slotNotifier) subscribe(s *Session) {
+	n.Lock()
+	defer n.Unlock()
+	n.sessions[s] = true
+}
+
+func (n *slotNotifier) unsubscribe(s *Session) {
+	n.Lock()
+	defer n.Unlock()
+	delete(n.sessions, s)
+}
+
+func (n *slotNotifier) notify(id int) {
+	n.Lock()
+	defer n.Unlock()
+	n.pending[id] = true
+	if n.timer == nil {
+		n.timer = time.AfterFunc(slotNotifyDelay, n.flush)
+	}
+}
+
+//回调在锁外执行，回调中可以再次注册或者取消注册
+func (n *slotNotifier) flush() {
+	n.Lock()
+	n.timer = nil
+	if len(n.pending) == 0 {
+		n.Unlock()
+		return
+	}
+	var slots = make([]int, 0, len(n.pending))
+	for id := range n.pending {
+		slots = append(slots, id)
+	}
+	sort.Ints(slots)
+	n.pending = make(map[int]bool)
+	n.version++
+
+	if len(n.sessions) != 0 {
+		b, _ := json.Marshal(&SlotsChangedEvent{Version: n.version, Slots: slots})
+		for s := range n.sessions {
+			s.pushMessage(newPubSubResp("message", SlotsChannel, redis.NewBulkBytes(b)))
+		}
+	}
+	var listeners = make([]func(slots []int), 0, len(n.listeners))
+	for _, fn := range n.listeners {
+		listeners = append(listeners, fn)
+	}
+	n.Unlock()
+
+	for _, fn := range listeners {
+		fn(slots)
+	}
+}
+
+//没有订阅任何频道时UNSUBSCRIBE回复的频道为nil
+func newPubSubNilResp(kind string, value *redis.Resp) *redis.Resp {
+	return redis.NewPush([]*redis.Resp{
+		redis.NewBulkBytes([]byte(kind)),
+		redis.NewBulkBytes(nil),
+		value,
+	})
+}
+
+//RESP3客户端收到push类型，RESP2客户端由loopWriter转换成数组
+func newPubSubResp(kind, channel string, value *redis.Resp) *redis.Resp {
+	return redis.NewPush([]*redis.Resp{
+		redis.NewBulkBytes([]byte(kind)),
+		redis.NewBulkBytes([]byte(channel)),
+		value,
+	})
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSlotNotify(x *testing.T) {
+	n := newSlotNotifier()
+
+	var ch = make(chan []int, 1)
+	unregister := n.register(func(slots []int) {
+		ch <- slots
+	})
+	defer unregister()
+
+	s := &Session{tasks: NewRequestChan()}
+	n.subscribe(s)
+	defer n.unsubscribe(s)
+
+	n.notify(3)
+	n.notify(1)
+	n.notify(3)
+
+	select {
+	case slots := <-ch:
+		assert.Must(len(slots) == 2 && slots[0] == 1 && slots[1] == 3)
+	case <-time.After(time.Second * 5):
+		x.Fatalf("slot listener timeout")
+	}
+
+	r, ok := s.tasks.PopFront()
+	assert.Must(ok && r.Resp.IsPush() && len(r.Resp.Array) == 3 && !r.RESP3)
+	assert.Must(r.Resp.ToRESP2().IsArray())
+	assert.Must(string(r.Resp.Array[0].Value) == "message")
+	assert.Must(string(r.Resp.Array[1].Value) == SlotsChannel)
+
+	var e SlotsChangedEvent
+	assert.MustNoError(json.Unmarshal(r.Resp.Array[2].Value, &e))
+	assert.Must(e.Version == 1 && len(e.Slots) == 2)
+}
+
+func TestSlotNotifyUnregister(x *testing.T) {
+	n := newSlotNotifier()
+
+	var ch = make(chan []int, 4)
+	var unregister func()
+	//回调中取消注册不会死锁
+	unregister = n.register(func(slots []int) {
+		unregister()
+		ch <- slots
+	})
+
+	n.notify(1)
+	select {
+	case slots := <-ch:
+		assert.Must(len(slots) == 1 && slots[0] == 1)
+	case <-time.After(time.Second * 5):
+		x.Fatalf("slot listener timeout")
+	}
+
+	n.notify(2)
+	select {
+	case <-ch:
+		x.Fatalf("unregistered listener is called")
+	case <-time.After(slotNotifyDelay * 3):
+	}
+	n.Lock()
+	assert.Must(len(n.listeners) == 0 && n.version == 2)
+	n.Unlock()
+}
@@ -11,6 +11,8 @@ import (
 
 type Slot struct {
 	id   int
+	//按key前缀路由时的前缀，id为-1
+	prefix []byte
 	lock struct {
 		hold bool
 		sync.RWMutex
@@ -24,6 +26,8 @@ type Slot struct {
 		bc *sharedBackendConn
 	}
 	replicaGroups [][]*sharedBackendConn
+	//从库轮询时的计数
+	replicaNext uint32
 
 	method forwardMethod
 }
@@ -38,6 +42,8 @@ func (s *Slot) snapshot() *models.Slot {
 		MigrateFrom:        s.migrate.bc.Addr(),
 		MigrateFromGroupId: s.migrate.id,
 		ForwardMethod:      s.method.GetId(),
+
+		Prefix: string(s.prefix),
 	}
 	for i := range s.replicaGroups {
 		var group []string
@@ -66,5 +72,9 @@ func (s *Slot) unblock() {
 }
 
 func (s *Slot) forward(r *Request, hkey []byte) error {
+	//前缀路由没有slot id，不统计slot热度
+	if len(s.prefix) == 0 {
+		incrSlotHeat(s.id, r)
+	}
 	return s.method.Forward(s, r, hkey)
 }
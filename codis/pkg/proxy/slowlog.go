@@ -29,11 +29,42 @@ func (m *Mutex) TryLock() bool {
 	return atomic.CompareAndSwapInt32((*int32)(unsafe.Pointer(&m.Mutex)), 0, mutexLocked)
 }
 
+//记录的key最长保留的字节数
+const xSlowlogMaxKeyLen = 128
+
 type XSlowlogEntry struct {
 	id          int64
 	time        int64
 	duration    int64
 	cmd         string
+
+	opstr       string
+	key         string
+	argsSize    int64
+	clientAddr  string
+	backendAddr string
+}
+
+//通过admin接口返回的慢日志，time和duration单位为us
+type SlowlogEntry struct {
+	Id          int64  `json:"id"`
+	Time        int64  `json:"time"`
+	Duration    int64  `json:"duration"`
+	OpStr       string `json:"opstr"`
+	Key         string `json:"key,omitempty"`
+	ArgsSize    int64  `json:"args_size"`
+	ClientAddr  string `json:"client_addr"`
+	//请求被拆分到多个后端时为空
+	BackendAddr string `json:"backend_addr,omitempty"`
+	Cmd         string `json:"cmd"`
+}
+
+type Slowlog struct {
+	Len           int   `json:"len"`
+	MaxLen        int64 `json:"max_len"`
+	LogSlowerThan int64 `json:"log_slower_than"`
+
+	Entries []*SlowlogEntry `json:"entries"`
 }
 
 type XSlowlog struct {
@@ -95,13 +126,16 @@ func XSlowlogLen() *redis.Resp{
 }
 
 func XSlowlogReset() *redis.Resp{
+	ResetSlowlog()
+	return redis.NewString([]byte("OK"))
+}
+
+func ResetSlowlog() {
 	defer xSlowlog.Unlock()
-    xSlowlog.Lock()
+	xSlowlog.Lock()
 
 	xSlowlog.loglist.Init()
 	xSlowlog.logid.Swap(0)
-
-	return redis.NewString([]byte("OK"))
 }
 
 
@@ -116,9 +150,48 @@ func slowLogToResp(e *XSlowlogEntry) *redis.Resp {
 		redis.NewArray([]*redis.Resp{
 			redis.NewBulkBytes([]byte(e.cmd)),
 		}),	
+		redis.NewBulkBytes([]byte(e.clientAddr)),
+		redis.NewBulkBytes([]byte(e.backendAddr)),
 	})
 }
 
+func newXSlowlogEntry(r *Request, clientAddr string, duration int64, cmd string) *XSlowlogEntry {
+	e := &XSlowlogEntry{
+		id: XSlowlogGetCurId(), time: r.ReceiveTime/1e3, duration: duration, cmd: cmd,
+		opstr: r.OpStr, clientAddr: clientAddr, backendAddr: r.BackendAddr,
+	}
+	for _, x := range r.Multi {
+		e.argsSize += int64(len(x.Value))
+	}
+	if len(r.Multi) > 1 {
+		key := getHashKey(r.Multi, r.OpStr)
+		if len(key) > xSlowlogMaxKeyLen {
+			key = key[:xSlowlogMaxKeyLen]
+		}
+		e.key = string(key)
+	}
+	return e
+}
+
+//返回最近的num条慢日志，num为0时只返回数量
+func GetSlowlog(num int64) *Slowlog {
+	defer xSlowlog.Unlock()
+	xSlowlog.Lock()
+
+	x := &Slowlog{Len: xSlowlog.loglist.Len(), MaxLen: xSlowlog.maxlen.Int64()}
+	x.Entries = make([]*SlowlogEntry, 0)
+	for iter := xSlowlog.loglist.Front(); iter != nil && int64(len(x.Entries)) < num; iter = iter.Next() {
+		if e, ok := iter.Value.(*XSlowlogEntry); ok {
+			x.Entries = append(x.Entries, &SlowlogEntry{
+				Id: e.id, Time: e.time, Duration: e.duration,
+				OpStr: e.opstr, Key: e.key, ArgsSize: e.argsSize,
+				ClientAddr: e.clientAddr, BackendAddr: e.backendAddr, Cmd: e.cmd,
+			})
+		}
+	}
+	return x
+}
+
 
 func XSlowlogGetByNum(num int64) *redis.Resp{
 	defer xSlowlog.Unlock()
@@ -0,0 +1,46 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSlowlog(x *testing.T) {
+	XSlowlogSetMaxLen(2)
+	defer XSlowlogSetMaxLen(xSlowlogMaxLenDefault)
+	defer ResetSlowlog()
+	ResetSlowlog()
+
+	for i := 0; i < 3; i++ {
+		r := &Request{OpStr: "SET", ReceiveTime: 1e9, BackendAddr: "127.0.0.1:6379"}
+		r.Multi = []*redis.Resp{
+			redis.NewBulkBytes([]byte("SET")),
+			redis.NewBulkBytes([]byte("key")),
+			redis.NewBulkBytes([]byte("value")),
+		}
+		XSlowlogPushFront(newXSlowlogEntry(r, "10.0.0.1:1234", int64(100+i), "SET key value"))
+	}
+
+	x0 := GetSlowlog(0)
+	assert.Must(x0.Len == 2 && x0.MaxLen == 2 && len(x0.Entries) == 0)
+
+	x1 := GetSlowlog(10)
+	assert.Must(len(x1.Entries) == 2)
+	e := x1.Entries[0]
+	assert.Must(e.Id == 3 && e.Time == 1e6 && e.Duration == 102)
+	assert.Must(e.OpStr == "SET" && e.Key == "key" && e.ArgsSize == 11)
+	assert.Must(e.ClientAddr == "10.0.0.1:1234" && e.BackendAddr == "127.0.0.1:6379")
+	assert.Must(x1.Entries[1].Id == 2)
+
+	resp := XSlowlogGetByNum(1)
+	assert.Must(len(resp.Array) == 1 && len(resp.Array[0].Array) == 6)
+	assert.Must(string(resp.Array[0].Array[4].Value) == "10.0.0.1:1234")
+
+	ResetSlowlog()
+	assert.Must(GetSlowlog(10).Len == 0)
+}
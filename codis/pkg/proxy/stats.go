@@ -28,26 +28,32 @@ const IntervalNum = 5
 const DelayKindNum = 8
 // 单位: s
 var IntervalMark = [IntervalNum]int64{1, 10, 60, 600, 3600}
-var LastRefreshTime = [IntervalNum]time.Time{time.Now()}
 // 单位: ms
 var DelayNumMark = [DelayKindNum]int64{50, 100, 200, 300, 500, 1000, 2000, 3000}
 
 type delayInfo struct {
 	interval	int64	
+	//保护环形缓冲区和下面的非原子统计，统计协程更新时与api读取互斥
+	mu        sync.Mutex
+	//环形缓冲区，每个槽位统计interval/len(slots)秒，head为正在统计的槽位
+	slots     []delaySlot
+	head      int
+	headStart time.Time
+	since     time.Time
+
+	//以下为滑动窗口内的统计，由统计协程每秒更新
 	calls 		atomic2.Int64
 	nsecs 		atomic2.Int64
 	nsecsmax  	atomic2.Int64
 	avg 		int64
 	qps 		atomic2.Int64
 
-	tp    	[TPMaxNum]atomic2.Int64
 	tp90  	int64
 	tp99  	int64
 	tp999 	int64
 	tp9999 	int64
 	tp100 	int64
 
-	delayCount   [DelayKindNum]atomic2.Int64
 	delay50ms    int64
 	delay100ms   int64
 	delay200ms   int64
@@ -66,11 +72,19 @@ type opStats struct {
 	lastSetSlowTime 	int64
 	lastClearSlowTime 	int64
 
+	//请求路径上只更新cur，统计协程每秒把cur合并到各个时间窗口
+	cur          delayCounters
 	delayInfo    [IntervalNum]*delayInfo
 
 	redis 	struct {
 		errors atomic2.Int64
 	}
+
+	args argStats
+	cache cacheCounters
+	hist latencyHistogram
+	quantile decayedHistogram
+	hedge hedgeDelay
 }
 
 type OpStats struct {
@@ -100,6 +114,12 @@ type OpStats struct {
 	Delay1s      int64  `json:"delay1s"`
 	Delay2s      int64  `json:"delay2s"`
 	Delay3s      int64  `json:"delay3s"`
+
+	Args  *ArgStats  `json:"args,omitempty"`
+	Cache *CacheStats `json:"cache,omitempty"`
+
+	//累计的延迟直方图，只用于prometheus输出
+	hist []int64
 }
 
 var cmdstats struct {
@@ -111,6 +131,12 @@ var cmdstats struct {
 	redis struct {
 		errors atomic2.Int64
 	}
+	//因为卡住被强制失败的请求数
+	stuck atomic2.Int64
+	//因为key太多被分批发送的请求数
+	split atomic2.Int64
+	//被限流拒绝的请求数
+	limited atomic2.Int64
 
 	qps atomic2.Int64
 	tpdelay		[TPMaxNum]int64   //us
@@ -134,11 +160,6 @@ func init() {
 		}
 	}
 
-	// init LastRefreshTime array
-	for i := 0; i < IntervalNum; i++ {
-		LastRefreshTime[i] = time.Now()
-	}
-
 	//log.Debugf("cmdstats.tpdelay: %v", cmdstats.tpdelay)
 
 	//周期性设置命令慢标志和清理命令慢标志；
@@ -165,7 +186,7 @@ func init() {
 			//由于tp100最小单位是1ms，因此tp100 >= 1ms时才会生效；
 			if cmdstats.autoSetSlowFlag.IsTrue() {
 				for _, v := range cmdstats.opmap{
-					if v.delayInfo[0].tp100 * 1e3 > cmdstats.logSlowerThan.Int64() && v.opstr != "ALL" {
+					if v.delayInfo[0].getTP100() * 1e3 > cmdstats.logSlowerThan.Int64() && v.opstr != "ALL" {
 						setMaySlowOpFlag(v.opstr)
 						v.lastSetSlowTime = now
 					} else if v.lastSetSlowTime >= v.lastClearSlowTime && now - v.lastSetSlowTime >= clearSlowDuration {
@@ -196,145 +217,22 @@ func init() {
 			delta := cmdstats.total.Int64() - total
 			normalized := math.Max(0, float64(delta)) / float64(time.Since(start)) * float64(time.Second) 
 			cmdstats.qps.Set(int64(normalized + 0.5))
+			refreshClientStats(time.Since(start))
+			refreshClientAddrStats(time.Since(start))
+			refreshBackendStats(time.Since(start))
+			refreshQuantileStats(time.Since(start))
+			refreshNamespaceStats(time.Since(start))
 
+			now := time.Now()
 			cmdstats.RLock()
-
-			for i:=0; i<IntervalNum; i++ {
-
-				if int64(float64(time.Since(LastRefreshTime[i])) / float64(time.Second)) < IntervalMark[i] {
-					continue
-				}
-				for _, v := range cmdstats.opmap{
-					v.RefreshOpStats(i)
-				}
-				LastRefreshTime[i] = time.Now()
+			for _, v := range cmdstats.opmap {
+				v.rollOpStats(now)
 			}
 			cmdstats.RUnlock()
 		}
 	}()
 }
 
-func (s *delayInfo) refreshTpInfo(cmd string) {
-	s.refresh4TpInfo(cmd)
-	s.tp100 = s.nsecsmax.Int64() / 1e6
-
-	if calls := s.calls.Int64(); calls != 0 {
-		s.avg = s.nsecs.Int64() / 1e6 / calls
-	} else {
-		s.avg = 0
-	}
-}
-
-func (s *delayInfo) refresh4TpInfo(cmd string) {
-	persents1 := 0.9
-	persents2 := 0.99 
-	persents3 := 0.999
-	persents4 := 0.9999
-
-	if s.calls.Int64() == 0 {
-		s.tp90 = 0
-		s.tp99 = 0
-		s.tp999 = 0
-		s.tp9999 = 0
-		return
-	}
-
-	tpnum1 := int64( float64(s.calls.Int64()) * persents1 )
-	tpnum2 := int64( float64(s.calls.Int64()) * persents2 )
-	tpnum3 := int64( float64(s.calls.Int64()) * persents3 )
-	tpnum4 := int64( float64(s.calls.Int64()) * persents4 )
-
-	var index1, index2, index3, index4 int
-	var count int64
-	var i 	  int
-
-	for i = 0; i < len(s.tp); i++ {
-		count += s.tp[i].Int64()
-		if count >= tpnum1 || i == len(s.tp)-1 {
-			index1 = i
-			break
-		}
-	}
-
-	if count >= tpnum2 || i == len(s.tp)-1 {
-		index2 = i
-	} else {
-		for i = i+1; i < len(s.tp); i++ {
-			count += s.tp[i].Int64()
-			if count >= tpnum2 || i == len(s.tp)-1 {
-				index2 = i
-				break
-			}
-		}
-	}
-
-	if count >= tpnum3 || i == len(s.tp)-1 {
-		index3 = i
-	} else {
-		for i = i+1; i < len(s.tp); i++ {
-			count += s.tp[i].Int64()
-			if count >= tpnum3 || i == len(s.tp)-1 {
-				index3 = i
-				break
-			}
-		}
-	}
-
-	if count >= tpnum4 || i == len(s.tp)-1 {
-		index4 = i
-	} else {
-		for i = i+1; i < len(s.tp); i++ {
-			count += s.tp[i].Int64()
-			if count >= tpnum4 || i == len(s.tp)-1 {
-				index4 = i
-				break
-			}
-		}
-	}
-
-	// 统计出现异常,打印一行日志
-	if i == len(s.tp)-1 && s.tp[i].Int64() <= 0 {
-		log.Warnf("refreshTpInfo err: cmd-[%s] tpinfo is unavailable", cmd)
-	}
-
-	if index1 >= 0 && index2 >= index1 && index3 >= index2 && index4 >= index3 && index4 < TPMaxNum {
-		s.tp90 = cmdstats.tpdelay[index1]
-		s.tp99 = cmdstats.tpdelay[index2]
-		s.tp999 = cmdstats.tpdelay[index3]
-		s.tp9999 = cmdstats.tpdelay[index4]
-		return 
-	}
-
-	log.Warnf("refreshTpInfo err: cmd-[%s] reset exception tpinf", cmd)
-	s.tp90 = -1
-	s.tp99 = -1
-	s.tp999 = -1
-	s.tp9999 = -1
-	return	
-}
-
-func (s *delayInfo) resetTpInfo() {
-	s.calls.Set(0)
-	s.nsecs.Set(0)
-	s.nsecsmax.Set(0)
-	s.tp = [TPMaxNum]atomic2.Int64{0}
-}
-
-func (s *delayInfo) refreshDelayInfo() {
-	s.delay50ms = s.delayCount[0].Int64()
-	s.delay100ms = s.delayCount[1].Int64()
-	s.delay200ms = s.delayCount[2].Int64()
-	s.delay300ms = s.delayCount[3].Int64()
-	s.delay500ms = s.delayCount[4].Int64()
-	s.delay1s = s.delayCount[5].Int64()
-	s.delay2s = s.delayCount[6].Int64()
-	s.delay3s = s.delayCount[7].Int64()
-}
-
-func (s *delayInfo) resetDelayInfo() {
-	s.delayCount  = [DelayKindNum]atomic2.Int64{0}
-}
-
 //IncrTP()中duration单位为ns
 func (s *opStats) incrTP(duration int64) {
 	var index int64 = -1
@@ -360,29 +258,25 @@ func (s *opStats) incrTP(duration int64) {
 		return
 	}
 
-	for i := 0; i < IntervalNum; i++ {
-		s.delayInfo[i].calls.Incr()
-		s.delayInfo[i].nsecs.Add(duration)
-		lastMax := s.delayInfo[i].nsecsmax.Int64()
-		//max值最大误差设置为5ms，防止瞬间有多个线程同时进行更新
-		if duration >= lastMax + 5*1e6 {
-			for ; ; {
-				ok := s.delayInfo[i].nsecsmax.CompareAndSwap(lastMax, duration)
-				if ok {
-					break;
-				} else {
-					lastMax = s.delayInfo[i].nsecsmax.Int64()
-					if duration < lastMax + 5*1e6 {
-						//log.Warnf("CompareAndSwap return false and break, newMax is [%d] lastMax is [%d] now time is [%v], ",duration, lastMax, time.Now())
-						break
-
-					}
-					log.Warnf("CompareAndSwap return false and try again, newMax is [%d ns] lastMax is [%d ns]",duration, lastMax)
+	s.cur.calls.Incr()
+	s.cur.nsecs.Add(duration)
+	lastMax := s.cur.nsecsmax.Int64()
+	//max值最大误差设置为5ms，防止瞬间有多个线程同时进行更新
+	if duration >= lastMax + 5*1e6 {
+		for ; ; {
+			ok := s.cur.nsecsmax.CompareAndSwap(lastMax, duration)
+			if ok {
+				break;
+			} else {
+				lastMax = s.cur.nsecsmax.Int64()
+				if duration < lastMax + 5*1e6 {
+					break
 				}
+				log.Warnf("CompareAndSwap return false and try again, newMax is [%d ns] lastMax is [%d ns]",duration, lastMax)
 			}
 		}
-		s.delayInfo[i].tp[index].Incr()
 	}
+	s.cur.tp[index].Incr()
 }
 
 
@@ -481,28 +375,11 @@ func (s *opStats) incrTP(duration int64) {
 	return -1, -1, -1, -1
 }*/
 
-func (s *opStats) RefreshOpStats(index int) {
-	if index < 0 || index >= IntervalNum {
-		return
-	}
-	normalized := math.Max(0, float64(s.delayInfo[index].calls.Int64())) / float64(time.Since(LastRefreshTime[index])) * float64(time.Second)
-	s.delayInfo[index].qps.Set(int64(normalized + 0.5))
-
-	s.delayInfo[index].refreshTpInfo(s.opstr)
-	s.delayInfo[index].resetTpInfo()
-
-	// 统计超时命令数量
-	s.delayInfo[index].refreshDelayInfo()
-	s.delayInfo[index].resetDelayInfo()
-}
-
 //duration单位为ms
 func (s *opStats) incrDelayNum(duration int64) {
 	for i, v := range DelayNumMark {
 		if duration >= v {
-			for j, _ := range IntervalMark {
-				s.delayInfo[j].delayCount[i].Incr()
-			}
+			s.cur.delayCount[i].Incr()
 		} else {
 			break
 		}
@@ -527,29 +404,19 @@ func (s *opStats) GetOpStatsByInterval(interval int64) *OpStats {
 		TotalCalls: s.totalCalls.Int64(),
 		TotalUsecs: s.totalNsecs.Int64() / 1e3,
 		Fails: s.totalFails.Int64(),
-		Calls: s.delayInfo[index].calls.Int64(),
-		Usecs: s.delayInfo[index].nsecs.Int64() / 1e3,
-		QPS:   s.delayInfo[index].qps.Int64(),
-		AVG:   s.delayInfo[index].avg,
-		TP90:  s.delayInfo[index].tp90,
-		TP99:  s.delayInfo[index].tp99,
-		TP999:   s.delayInfo[index].tp999,
-		TP9999:  s.delayInfo[index].tp9999,
-		TP100:	 s.delayInfo[index].tp100,
-		Delay50ms: s.delayInfo[index].delay50ms,
-		Delay100ms: s.delayInfo[index].delay100ms,
-		Delay200ms: s.delayInfo[index].delay200ms,
-		Delay300ms: s.delayInfo[index].delay300ms,
-		Delay500ms: s.delayInfo[index].delay500ms,
-		Delay1s: s.delayInfo[index].delay1s,
-		Delay2s: s.delayInfo[index].delay2s,
-		Delay3s: s.delayInfo[index].delay3s,
 	}
+	s.delayInfo[index].fill(o)
 
 	if o.Calls != 0 {
 		o.UsecsPercall = o.Usecs / o.Calls
 	}
 	o.RedisErrType = s.redis.errors.Int64()
+	o.Args = s.args.snapshot()
+	o.hist = s.hist.snapshot()
+	if !s.cache.isZero() {
+		cache := s.cache.snapshot()
+		o.Cache = &cache
+	}
 
 	return o
 }
@@ -562,6 +429,8 @@ func (s *opStats)incrOpStats(responseTime int64, t redis.RespType) {
 			s.redis.errors.Incr()
 	}
 	
+	s.hist.incr(responseTime)
+	s.quantile.incr(responseTime / 1e3)
 	//统计tp数据
 	s.incrTP( responseTime )
 	//统计超时命令数量
@@ -607,6 +476,18 @@ func OpRedisErrors() int64 {
 	return cmdstats.redis.errors.Int64()
 }
 
+func OpStuck() int64 {
+	return cmdstats.stuck.Int64()
+}
+
+func OpSplit() int64 {
+	return cmdstats.split.Int64()
+}
+
+func OpRateLimited() int64 {
+	return cmdstats.limited.Int64()
+}
+
 func OpQPS() int64 {
 	return cmdstats.qps.Int64()
 }
@@ -625,7 +506,7 @@ func getOpStats(opstr string, create bool) *opStats {
 	if s == nil {
 		s = &opStats{opstr: opstr}
 		for i:=0; i<IntervalNum; i++ {
-			s.delayInfo[i] = &delayInfo{interval: IntervalMark[i]}
+			s.delayInfo[i] = newDelayInfo(IntervalMark[i], time.Now())
 		}
 		cmdstats.opmap[opstr] = s
 	}
@@ -678,12 +559,26 @@ func ResetStats() {
 		v.totalNsecs.Set(0)
 		v.totalFails.Set(0)
 		v.redis.errors.Set(0)
+		v.args.reset()
+		v.cache.reset()
+		v.hist.reset()
+		v.quantile.reset()
 	}
 	cmdstats.RUnlock()
+	resetCachePrefixStats()
+	resetClientStats()
+	resetNamespaceStats()
+	resetBigKeys()
+	resetClientAddrStats()
+	resetBackendStats()
+	resetHedgeStats()
 
 	cmdstats.total.Set(0)
 	cmdstats.fails.Set(0)
 	cmdstats.redis.errors.Set(0)
+	cmdstats.stuck.Set(0)
+	cmdstats.split.Set(0)
+	cmdstats.limited.Set(0)
 	sessions.total.Set(sessions.alive.Int64())
 }
 
@@ -695,6 +590,18 @@ func incrOpRedisErrors() {
 	cmdstats.redis.errors.Incr()
 }
 
+func incrOpStuck() {
+	cmdstats.stuck.Incr()
+}
+
+func incrOpSplit() {
+	cmdstats.split.Incr()
+}
+
+func incrOpRateLimited() {
+	cmdstats.limited.Incr()
+}
+
 func incrOpFails(r *Request, err error) {
 	if r != nil {
 		var s *opStats
@@ -714,8 +621,10 @@ func incrOpStats(r *Request, t redis.RespType) {
 
 		s = getOpStats(r.OpStr, true)
 		s.incrOpStats(responseTime, t)
+		s.args.incr(r.Multi)
 		s = getOpStats("ALL", true)
 		s.incrOpStats(responseTime, t)
+		s.args.incr(r.Multi)
 
 		switch t {
 			case redis.TypeError:
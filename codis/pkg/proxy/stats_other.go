@@ -0,0 +1,83 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sort"
+
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//QPS低于阈值的命令在输出时合并到该条目中
+const OtherOpStr = "OTHER"
+
+//0表示不合并
+var otherQPSThreshold atomic2.Int64
+
+func StatsSetOtherQPSThreshold(qps int64) {
+	if qps >= 0 {
+		otherQPSThreshold.Set(qps)
+	}
+}
+
+//只影响统计的输出，opmap中仍然保留每个命令的精确统计，ALL不会被合并
+func aggregateOpStats(all []*OpStats, threshold int64) []*OpStats {
+	if threshold <= 0 {
+		return all
+	}
+	var list = make([]*OpStats, 0, len(all))
+	var other *OpStats
+	for _, o := range all {
+		if o.OpStr == "ALL" || o.OpStr == OtherOpStr || o.QPS >= threshold {
+			list = append(list, o)
+			continue
+		}
+		if other == nil {
+			other = &OpStats{OpStr: OtherOpStr, Interval: o.Interval}
+			list = append(list, other)
+		}
+		mergeOtherOpStats(other, o)
+	}
+	if other != nil {
+		if other.Calls != 0 {
+			other.UsecsPercall = other.Usecs / other.Calls
+		}
+		sort.Sort(sliceOpStats(list))
+	}
+	return list
+}
+
+//延迟百分位无法精确合并，取各命令中的最大值
+func mergeOtherOpStats(other, o *OpStats) {
+	if qps := other.QPS + o.QPS; qps != 0 {
+		other.AVG = (other.AVG*other.QPS + o.AVG*o.QPS) / qps
+	}
+	other.QPS += o.QPS
+	other.TotalCalls += o.TotalCalls
+	other.TotalUsecs += o.TotalUsecs
+	other.Calls += o.Calls
+	other.Usecs += o.Usecs
+	other.Fails += o.Fails
+	other.RedisErrType += o.RedisErrType
+	other.TP90 = math2.MaxInt64(other.TP90, o.TP90)
+	other.TP99 = math2.MaxInt64(other.TP99, o.TP99)
+	other.TP999 = math2.MaxInt64(other.TP999, o.TP999)
+	other.TP9999 = math2.MaxInt64(other.TP9999, o.TP9999)
+	other.TP100 = math2.MaxInt64(other.TP100, o.TP100)
+	other.Delay50ms += o.Delay50ms
+	other.Delay100ms += o.Delay100ms
+	other.Delay200ms += o.Delay200ms
+	other.Delay300ms += o.Delay300ms
+	other.Delay500ms += o.Delay500ms
+	other.Delay1s += o.Delay1s
+	other.Delay2s += o.Delay2s
+	other.Delay3s += o.Delay3s
+	if len(other.hist) < len(o.hist) {
+		other.hist = append(other.hist, make([]int64, len(o.hist)-len(other.hist))...)
+	}
+	for i, n := range o.hist {
+		other.hist[i] += n
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestAggregateOpStats(t *testing.T) {
+	all := []*OpStats{
+		{OpStr: "ALL", QPS: 1, Calls: 100},
+		{OpStr: "GET", QPS: 100, Calls: 100, TP99: 5},
+		{OpStr: "OBJECT", QPS: 2, Calls: 2, Usecs: 20, AVG: 10, TP99: 50, Delay50ms: 1},
+		{OpStr: "TOUCH", QPS: 6, Calls: 6, Usecs: 120, AVG: 20, TP99: 30, Fails: 1},
+	}
+	assert.Must(len(aggregateOpStats(all, 0)) == 4)
+
+	list := aggregateOpStats(all, 10)
+	assert.Must(len(list) == 3)
+	assert.Must(list[0].OpStr == "ALL" && list[1].OpStr == "GET")
+	o := list[2]
+	assert.Must(o.OpStr == OtherOpStr)
+	assert.Must(o.QPS == 8 && o.Calls == 8 && o.Usecs == 140 && o.UsecsPercall == 17)
+	assert.Must(o.AVG == 17 && o.TP99 == 50)
+	assert.Must(o.Fails == 1 && o.Delay50ms == 1)
+
+	//原始统计不受影响
+	assert.Must(all[2].QPS == 2 && all[3].Calls == 6)
+}
@@ -0,0 +1,157 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"math"
+	"math/bits"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//对数线性的延迟直方图，单位为us，每个2的幂次区间再等分为quantileSubCount份，相对误差不超过1/quantileSubCount
+//超过2^quantileMaxBits us(约71分钟)的延迟计入最后一个区间
+const (
+	quantileSubBits   = 5
+	quantileSubCount  = 1 << quantileSubBits
+	quantileMaxBits   = 32
+	quantileBucketNum = quantileSubCount * (quantileMaxBits - quantileSubBits + 1)
+)
+
+//衰减的半衰期，默认60s
+var quantileHalfLife atomic2.Int64
+
+func init() {
+	quantileHalfLife.Set(int64(time.Minute))
+}
+
+func StatsSetQuantileHalfLife(d time.Duration) {
+	if d > 0 {
+		quantileHalfLife.Set(int64(d))
+	}
+}
+
+func quantileIndex(usecs int64) int {
+	if usecs < 0 {
+		usecs = 0
+	}
+	if usecs >= 1<<quantileMaxBits {
+		usecs = 1<<quantileMaxBits - 1
+	}
+	if usecs < quantileSubCount {
+		return int(usecs)
+	}
+	shift := uint(bits.Len64(uint64(usecs)) - quantileSubBits - 1)
+	return quantileSubCount + int(shift)*quantileSubCount + int(usecs>>shift) - quantileSubCount
+}
+
+//区间的中点
+func quantileValue(index int) int64 {
+	if index < quantileSubCount {
+		return int64(index)
+	}
+	shift := uint((index - quantileSubCount) / quantileSubCount)
+	top := int64(quantileSubCount + (index-quantileSubCount)%quantileSubCount)
+	low, high := top<<shift, (top+1)<<shift-1
+	return (low + high) / 2
+}
+
+//请求路径上只做原子计数，统计协程定期取走计数并按指数衰减合并到decayed中
+type decayedHistogram struct {
+	buckets [quantileBucketNum]atomic2.Int64
+
+	mu      sync.Mutex
+	decayed [quantileBucketNum]float64
+}
+
+func (h *decayedHistogram) incr(usecs int64) {
+	h.buckets[quantileIndex(usecs)].Incr()
+}
+
+//factor为已有权重保留的比例
+func (h *decayedHistogram) decay(factor float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.buckets {
+		delta := h.buckets[i].Swap(0)
+		h.decayed[i] = h.decayed[i]*factor + float64(delta)
+		if h.decayed[i] < 1e-6 {
+			h.decayed[i] = 0
+		}
+	}
+}
+
+//返回分位数和衰减后的样本数，没有样本时返回0
+func (h *decayedHistogram) quantile(q float64) (int64, float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var total float64
+	for i := range h.decayed {
+		total += h.decayed[i]
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	var target = total * q
+	var count float64
+	var last int
+	for i := range h.decayed {
+		if h.decayed[i] == 0 {
+			continue
+		}
+		count += h.decayed[i]
+		last = i
+		if count >= target {
+			return quantileValue(i), total
+		}
+	}
+	//浮点误差导致没有达到target
+	return quantileValue(last), total
+}
+
+func (h *decayedHistogram) reset() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.buckets {
+		h.buckets[i].Set(0)
+		h.decayed[i] = 0
+	}
+}
+
+//由统计协程定期调用，elapsed为距离上次调用的时间
+func refreshQuantileStats(elapsed time.Duration) {
+	factor := math.Pow(0.5, float64(elapsed)/float64(quantileHalfLife.Int64()))
+	cmdstats.RLock()
+	for _, v := range cmdstats.opmap {
+		v.quantile.decay(factor)
+	}
+	cmdstats.RUnlock()
+}
+
+type TPQuantile struct {
+	OpStr    string  `json:"opstr"`
+	Quantile float64 `json:"quantile"`
+	Usecs    int64   `json:"usecs"`
+	//衰减后的样本数
+	Samples float64 `json:"samples"`
+}
+
+//返回命令延迟的任意分位数，越近的请求权重越大，opstr为ALL时统计所有命令
+func GetTP(opstr string, quantile float64) (*TPQuantile, error) {
+	if quantile <= 0 || quantile > 1 {
+		return nil, errors.Errorf("invalid quantile = %v", quantile)
+	}
+	opstr = strings.ToUpper(opstr)
+	s := getOpStats(opstr, false)
+	if s == nil {
+		return nil, errors.Errorf("command %s not found", opstr)
+	}
+	o := &TPQuantile{OpStr: opstr, Quantile: quantile}
+	o.Usecs, o.Samples = s.quantile.quantile(quantile)
+	return o, nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestQuantileIndex(x *testing.T) {
+	assert.Must(quantileIndex(-1) == 0)
+	assert.Must(quantileIndex(quantileSubCount-1) == quantileSubCount-1)
+	assert.Must(quantileIndex(1<<40) == quantileBucketNum-1)
+	var last int
+	for _, v := range []int64{1, 31, 32, 33, 100, 1000, 12345, 1e6, 3e9} {
+		i := quantileIndex(v)
+		assert.Must(i >= last && i < quantileBucketNum)
+		last = i
+		diff := math.Abs(float64(quantileValue(i)-v)) / float64(v)
+		assert.Must(diff <= 1.0/quantileSubCount)
+	}
+}
+
+func TestDecayedHistogram(x *testing.T) {
+	h := &decayedHistogram{}
+	v, n := h.quantile(0.99)
+	assert.Must(v == 0 && n == 0)
+
+	for i := 1; i <= 10000; i++ {
+		h.incr(int64(i))
+	}
+	h.decay(0.5)
+	for _, q := range []float64{0.5, 0.9, 0.99, 0.999, 0.9999} {
+		v, n := h.quantile(q)
+		assert.Must(n == 10000)
+		diff := math.Abs(float64(v)-q*10000) / (q * 10000)
+		assert.Must(diff <= 2.0/quantileSubCount)
+	}
+	v, _ = h.quantile(1)
+	assert.Must(v >= 9800 && v <= 10200)
+
+	//旧的样本按factor衰减
+	for i := 0; i < 10000; i++ {
+		h.incr(1e6)
+	}
+	h.decay(0.5)
+	v, n = h.quantile(0.5)
+	assert.Must(n == 15000 && v > 9e5)
+	v, _ = h.quantile(0.3)
+	assert.Must(v < 1e4)
+
+	h.reset()
+	v, n = h.quantile(0.5)
+	assert.Must(v == 0 && n == 0)
+}
+
+func TestGetTP(x *testing.T) {
+	_, err := GetTP("ALL", 0)
+	assert.Must(err != nil)
+	_, err = GetTP("ALL", 1.5)
+	assert.Must(err != nil)
+	_, err = GetTP("NOT-EXISTS", 0.99)
+	assert.Must(err != nil)
+
+	s := getOpStats("TPTEST", true)
+	s.incrOpStats(2e6, redis.TypeBulkBytes)
+	refreshQuantileStats(time.Second)
+	tp, err := GetTP("tptest", 0.99)
+	assert.MustNoError(err)
+	assert.Must(tp.OpStr == "TPTEST" && tp.Samples == 1)
+	assert.Must(tp.Usecs >= 1950 && tp.Usecs <= 2050)
+}
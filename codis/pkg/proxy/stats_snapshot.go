@@ -0,0 +1,160 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//只保存累计值，时间窗口内的统计重启后重新开始
+type StatsSnapshot struct {
+	ProductName string `json:"product_name"`
+	UpdateTime  string `json:"update_time"`
+
+	Total       int64 `json:"total"`
+	Fails       int64 `json:"fails"`
+	RedisErrors int64 `json:"redis_errors"`
+	Stuck       int64 `json:"stuck"`
+	Split       int64 `json:"split"`
+
+	Ops []*OpSnapshot `json:"ops"`
+}
+
+type OpSnapshot struct {
+	OpStr       string `json:"opstr"`
+	TotalCalls  int64  `json:"total_calls"`
+	TotalNsecs  int64  `json:"total_nsecs"`
+	Fails       int64  `json:"fails"`
+	RedisErrors int64  `json:"redis_errors"`
+}
+
+func GetStatsSnapshot(productName string) *StatsSnapshot {
+	x := &StatsSnapshot{
+		ProductName: productName,
+		UpdateTime:  time.Now().Format("2006-01-02 15:04:05"),
+		Total:       cmdstats.total.Int64(),
+		Fails:       cmdstats.fails.Int64(),
+		RedisErrors: cmdstats.redis.errors.Int64(),
+		Stuck:       cmdstats.stuck.Int64(),
+		Split:       cmdstats.split.Int64(),
+	}
+	cmdstats.RLock()
+	for _, v := range cmdstats.opmap {
+		x.Ops = append(x.Ops, &OpSnapshot{
+			OpStr:       v.opstr,
+			TotalCalls:  v.totalCalls.Int64(),
+			TotalNsecs:  v.totalNsecs.Int64(),
+			Fails:       v.totalFails.Int64(),
+			RedisErrors: v.redis.errors.Int64(),
+		})
+	}
+	cmdstats.RUnlock()
+	return x
+}
+
+//在已有的计数上累加，proxy启动时调用
+func RestoreStatsSnapshot(x *StatsSnapshot) {
+	cmdstats.total.Add(x.Total)
+	cmdstats.fails.Add(x.Fails)
+	cmdstats.redis.errors.Add(x.RedisErrors)
+	cmdstats.stuck.Add(x.Stuck)
+	cmdstats.split.Add(x.Split)
+	for _, o := range x.Ops {
+		if o == nil || o.OpStr == "" {
+			continue
+		}
+		v := getOpStats(o.OpStr, true)
+		v.totalCalls.Add(o.TotalCalls)
+		v.totalNsecs.Add(o.TotalNsecs)
+		v.totalFails.Add(o.Fails)
+		v.redis.errors.Add(o.RedisErrors)
+	}
+}
+
+//先写临时文件再rename，避免进程退出时留下不完整的快照
+func SaveStatsSnapshot(path string, x *StatsSnapshot) error {
+	b, err := json.MarshalIndent(x, "", "    ")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return errors.Trace(err)
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return errors.Trace(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+//文件不存在时返回nil
+func LoadStatsSnapshot(path string) (*StatsSnapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Trace(err)
+	}
+	x := &StatsSnapshot{}
+	if err := json.Unmarshal(b, x); err != nil {
+		return nil, errors.Errorf("invalid stats snapshot %s: %s", path, err)
+	}
+	return x, nil
+}
+
+//其他product的快照不恢复
+func restoreStatsSnapshotFile(path, productName string) error {
+	x, err := LoadStatsSnapshot(path)
+	if err != nil || x == nil {
+		return err
+	}
+	if x.ProductName != productName {
+		log.Warnf("stats snapshot %s belongs to product %s, ignored", path, x.ProductName)
+		return nil
+	}
+	RestoreStatsSnapshot(x)
+	log.Warnf("restore stats snapshot %s, update_time = %s, total = %d", path, x.UpdateTime, x.Total)
+	return nil
+}
+
+//定期保存和proxy关闭时的保存可能同时进行
+var statsSnapshotLock sync.Mutex
+
+func (s *Proxy) saveStatsSnapshot(path string) {
+	statsSnapshotLock.Lock()
+	defer statsSnapshotLock.Unlock()
+	if err := SaveStatsSnapshot(path, GetStatsSnapshot(s.config.ProductName)); err != nil {
+		log.WarnErrorf(err, "[%p] save stats snapshot %s failed", s, path)
+	}
+}
+
+func (s *Proxy) AutoSaveStatsSnapshot() {
+	var last = time.Now()
+	for !s.IsClosed() {
+		time.Sleep(time.Second)
+
+		s.mu.Lock()
+		path := s.config.StatsSnapshotFile
+		interval := s.config.StatsSnapshotInterval.Duration()
+		s.mu.Unlock()
+
+		if path == "" || time.Since(last) < interval {
+			continue
+		}
+		last = time.Now()
+		s.saveStatsSnapshot(path)
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestStatsSnapshot(x *testing.T) {
+	dir, err := ioutil.TempDir("", "stats_snapshot")
+	assert.MustNoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sub", "stats.json")
+
+	x0, err := LoadStatsSnapshot(path)
+	assert.MustNoError(err)
+	assert.Must(x0 == nil)
+
+	snap := &StatsSnapshot{
+		ProductName: "snapshot-test", Total: 100, Fails: 3, Split: 1,
+		Ops: []*OpSnapshot{
+			{OpStr: "SNAPSHOT-GET", TotalCalls: 90, TotalNsecs: 9000, Fails: 2, RedisErrors: 1},
+			nil,
+		},
+	}
+	assert.MustNoError(SaveStatsSnapshot(path, snap))
+	_, err = os.Stat(path + ".tmp")
+	assert.Must(os.IsNotExist(err))
+
+	loaded, err := LoadStatsSnapshot(path)
+	assert.MustNoError(err)
+	assert.Must(loaded.ProductName == "snapshot-test" && loaded.Total == 100 && len(loaded.Ops) == 2)
+
+	total := cmdstats.total.Int64()
+	assert.MustNoError(restoreStatsSnapshotFile(path, "other-product"))
+	assert.Must(cmdstats.total.Int64() == total)
+	assert.Must(getOpStats("SNAPSHOT-GET", false) == nil)
+
+	assert.MustNoError(restoreStatsSnapshotFile(path, "snapshot-test"))
+	assert.Must(cmdstats.total.Int64() == total+100)
+	e := getOpStats("SNAPSHOT-GET", false)
+	assert.Must(e != nil && e.totalCalls.Int64() == 90 && e.totalNsecs.Int64() == 9000)
+	assert.Must(e.totalFails.Int64() == 2 && e.redis.errors.Int64() == 1)
+
+	var found *OpSnapshot
+	for _, o := range GetStatsSnapshot("snapshot-test").Ops {
+		if o.OpStr == "SNAPSHOT-GET" {
+			found = o
+		}
+	}
+	assert.Must(found != nil && found.TotalCalls == 90 && found.Fails == 2)
+
+	assert.MustNoError(ioutil.WriteFile(path, []byte("{"), 0644))
+	_, err = LoadStatsSnapshot(path)
+	assert.Must(err != nil)
+}
@@ -0,0 +1,199 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//每个时间窗口最多划分的槽位数，窗口每次滑动interval/DelaySlotNum秒
+const DelaySlotNum = 10
+
+//请求路径上更新的计数，统计协程每秒取走
+type delayCounters struct {
+	calls      atomic2.Int64
+	nsecs      atomic2.Int64
+	nsecsmax   atomic2.Int64
+	tp         [TPMaxNum]atomic2.Int64
+	delayCount [DelayKindNum]atomic2.Int64
+}
+
+//一个槽位内的统计，只由统计协程访问
+type delaySlot struct {
+	calls      int64
+	nsecs      int64
+	nsecsmax   int64
+	tp         [TPMaxNum]int64
+	delayCount [DelayKindNum]int64
+}
+
+func (c *delayCounters) swap(o *delaySlot) {
+	o.calls = c.calls.Swap(0)
+	o.nsecs = c.nsecs.Swap(0)
+	o.nsecsmax = c.nsecsmax.Swap(0)
+	for i := range c.tp {
+		o.tp[i] = c.tp[i].Swap(0)
+	}
+	for i := range c.delayCount {
+		o.delayCount[i] = c.delayCount[i].Swap(0)
+	}
+}
+
+func (s *delaySlot) add(o *delaySlot) {
+	s.calls += o.calls
+	s.nsecs += o.nsecs
+	if o.nsecsmax > s.nsecsmax {
+		s.nsecsmax = o.nsecsmax
+	}
+	for i := range s.tp {
+		s.tp[i] += o.tp[i]
+	}
+	for i := range s.delayCount {
+		s.delayCount[i] += o.delayCount[i]
+	}
+}
+
+func newDelayInfo(interval int64, now time.Time) *delayInfo {
+	n := interval
+	if n > DelaySlotNum {
+		n = DelaySlotNum
+	}
+	if n < 1 {
+		n = 1
+	}
+	return &delayInfo{interval: interval, slots: make([]delaySlot, n), headStart: now, since: now}
+}
+
+func (s *delayInfo) slotWidth() time.Duration {
+	return time.Duration(s.interval) * time.Second / time.Duration(len(s.slots))
+}
+
+//把最近一秒的计数加到当前槽位，用整个环重新计算窗口内的统计，当前槽位写满后滑动到下一个
+func (s *delayInfo) roll(d *delaySlot, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slots[s.head].add(d)
+
+	width := s.slotWidth()
+	elapsed := now.Sub(s.headStart)
+	if elapsed > width {
+		elapsed = width
+	}
+	//当前槽位之前的槽位都是完整的，刚创建时窗口还没有填满
+	covered := time.Duration(len(s.slots)-1)*width + elapsed
+	if d := now.Sub(s.since); d < covered {
+		covered = d
+	}
+
+	var sum delaySlot
+	for i := range s.slots {
+		sum.add(&s.slots[i])
+	}
+	s.refresh(&sum, covered)
+
+	if now.Sub(s.headStart) < width {
+		return
+	}
+	//统计协程停顿了整个窗口
+	if now.Sub(s.headStart) >= width*time.Duration(len(s.slots)) {
+		for i := range s.slots {
+			s.slots[i] = delaySlot{}
+		}
+		s.headStart = now
+		return
+	}
+	for now.Sub(s.headStart) >= width {
+		s.head = (s.head + 1) % len(s.slots)
+		s.slots[s.head] = delaySlot{}
+		s.headStart = s.headStart.Add(width)
+	}
+}
+
+//调用方需要持有s.mu
+func (s *delayInfo) refresh(sum *delaySlot, covered time.Duration) {
+	s.calls.Set(sum.calls)
+	s.nsecs.Set(sum.nsecs)
+	s.nsecsmax.Set(sum.nsecsmax)
+	if covered > 0 {
+		normalized := float64(sum.calls) / float64(covered) * float64(time.Second)
+		s.qps.Set(int64(normalized + 0.5))
+	}
+
+	s.tp100 = sum.nsecsmax / 1e6
+	if sum.calls != 0 {
+		s.avg = sum.nsecs / 1e6 / sum.calls
+	} else {
+		s.avg = 0
+	}
+	s.tp90, s.tp99, s.tp999, s.tp9999 = sum.percentiles(0.9, 0.99, 0.999, 0.9999)
+
+	s.delay50ms = sum.delayCount[0]
+	s.delay100ms = sum.delayCount[1]
+	s.delay200ms = sum.delayCount[2]
+	s.delay300ms = sum.delayCount[3]
+	s.delay500ms = sum.delayCount[4]
+	s.delay1s = sum.delayCount[5]
+	s.delay2s = sum.delayCount[6]
+	s.delay3s = sum.delayCount[7]
+}
+
+func (s *delayInfo) getTP99() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tp99
+}
+
+func (s *delayInfo) getTP100() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.tp100
+}
+
+func (s *delayInfo) fill(o *OpStats) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o.Calls = s.calls.Int64()
+	o.Usecs = s.nsecs.Int64() / 1e3
+	o.QPS = s.qps.Int64()
+	o.AVG = s.avg
+	o.TP90, o.TP99, o.TP999, o.TP9999, o.TP100 = s.tp90, s.tp99, s.tp999, s.tp9999, s.tp100
+	o.Delay50ms = s.delay50ms
+	o.Delay100ms = s.delay100ms
+	o.Delay200ms = s.delay200ms
+	o.Delay300ms = s.delay300ms
+	o.Delay500ms = s.delay500ms
+	o.Delay1s = s.delay1s
+	o.Delay2s = s.delay2s
+	o.Delay3s = s.delay3s
+}
+
+//p1~p4需要递增，返回对应tp区间的上界，单位为ms
+func (s *delaySlot) percentiles(p1, p2, p3, p4 float64) (int64, int64, int64, int64) {
+	if s.calls == 0 {
+		return 0, 0, 0, 0
+	}
+	var persents = [4]float64{p1, p2, p3, p4}
+	var tps [4]int64
+	var count int64
+	var j int
+	for i := 0; i < TPMaxNum && j < len(persents); i++ {
+		count += s.tp[i]
+		for j < len(persents) && (count >= int64(float64(s.calls)*persents[j]) || i == TPMaxNum-1) {
+			tps[j] = cmdstats.tpdelay[i]
+			j++
+		}
+	}
+	return tps[0], tps[1], tps[2], tps[3]
+}
+
+//由统计协程每秒调用
+func (s *opStats) rollOpStats(now time.Time) {
+	var d delaySlot
+	s.cur.swap(&d)
+	for _, v := range s.delayInfo {
+		v.roll(&d, now)
+	}
+}
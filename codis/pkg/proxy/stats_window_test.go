@@ -0,0 +1,132 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func newTestDelaySlot(calls int64, ms int64) *delaySlot {
+	d := &delaySlot{calls: calls, nsecs: calls * ms * 1e6, nsecsmax: ms * 1e6}
+	d.tp[0] = calls
+	return d
+}
+
+func TestDelayInfoSliding(x *testing.T) {
+	now := time.Unix(1000, 0)
+	s := newDelayInfo(60, now)
+	assert.Must(len(s.slots) == DelaySlotNum && s.slotWidth() == time.Second*6)
+
+	//窗口填满之前按实际经过的时间计算qps
+	for i := 1; i <= 30; i++ {
+		s.roll(newTestDelaySlot(10, 1), now.Add(time.Duration(i)*time.Second))
+	}
+	assert.Must(s.calls.Int64() == 300 && s.qps.Int64() == 10)
+
+	for i := 31; i <= 120; i++ {
+		s.roll(newTestDelaySlot(10, 1), now.Add(time.Duration(i)*time.Second))
+		assert.Must(s.qps.Int64() == 10)
+		//窗口每次滑动一个槽位，calls不会归零
+		if i >= 60 {
+			assert.Must(s.calls.Int64() >= 550 && s.calls.Int64() <= 600)
+		}
+	}
+	assert.Must(s.avg == 1 && s.tp100 == 1 && s.tp99 == cmdstats.tpdelay[0])
+
+	//没有新请求时逐步滑出窗口
+	for i := 121; i <= 150; i++ {
+		s.roll(&delaySlot{}, now.Add(time.Duration(i)*time.Second))
+	}
+	assert.Must(s.calls.Int64() > 0 && s.calls.Int64() <= 300 && s.qps.Int64() < 10)
+	for i := 151; i <= 180; i++ {
+		s.roll(&delaySlot{}, now.Add(time.Duration(i)*time.Second))
+	}
+	assert.Must(s.calls.Int64() == 0 && s.qps.Int64() == 0 && s.avg == 0)
+
+	//统计协程停顿超过整个窗口
+	s.roll(newTestDelaySlot(10, 1), now.Add(time.Second*1000))
+	s.roll(newTestDelaySlot(10, 1), now.Add(time.Second*1001))
+	assert.Must(s.calls.Int64() == 10)
+}
+
+func TestDelayInfoOneSecond(x *testing.T) {
+	now := time.Unix(1000, 0)
+	s := newDelayInfo(1, now)
+	assert.Must(len(s.slots) == 1)
+	for i := 1; i <= 5; i++ {
+		s.roll(newTestDelaySlot(int64(i), 1), now.Add(time.Duration(i)*time.Second))
+		assert.Must(s.calls.Int64() == int64(i) && s.qps.Int64() == int64(i))
+	}
+}
+
+func TestDelaySlotPercentiles(x *testing.T) {
+	var d delaySlot
+	tp90, tp99, tp999, tp9999 := d.percentiles(0.9, 0.99, 0.999, 0.9999)
+	assert.Must(tp90 == 0 && tp99 == 0 && tp999 == 0 && tp9999 == 0)
+
+	d.calls = 10000
+	d.tp[0] = 9000
+	d.tp[1] = 900
+	d.tp[10] = 90
+	d.tp[TPMaxNum-1] = 10
+	tp90, tp99, tp999, tp9999 = d.percentiles(0.9, 0.99, 0.999, 0.9999)
+	assert.Must(tp90 == cmdstats.tpdelay[0] && tp99 == cmdstats.tpdelay[1])
+	assert.Must(tp999 == cmdstats.tpdelay[10] && tp9999 == cmdstats.tpdelay[TPMaxNum-1])
+
+	var o delaySlot
+	o.add(&d)
+	o.add(newTestDelaySlot(1, 3000))
+	assert.Must(o.calls == 10001 && o.tp[0] == 9001 && o.nsecsmax == 3000*1e6)
+}
+
+func TestOpStatsRoll(x *testing.T) {
+	now := time.Now()
+	s := &opStats{opstr: "ROLLTEST"}
+	for i := range s.delayInfo {
+		s.delayInfo[i] = newDelayInfo(IntervalMark[i], now)
+	}
+	for i := 0; i < 5; i++ {
+		s.incrOpStats(int64(time.Millisecond*7), 0)
+	}
+	s.rollOpStats(now.Add(time.Second))
+	for i := range s.delayInfo {
+		assert.Must(s.delayInfo[i].calls.Int64() == 5)
+		assert.Must(s.delayInfo[i].tp90 == cmdstats.tpdelay[1] && s.delayInfo[i].tp100 == 7)
+		assert.Must(s.delayInfo[i].delay50ms == 0)
+	}
+	assert.Must(s.cur.calls.Int64() == 0)
+	o := s.GetOpStatsByInterval(10)
+	assert.Must(o.Calls == 5 && o.Interval == 10 && o.TP100 == 7)
+}
+
+func TestOpStatsRollConcurrent(x *testing.T) {
+	now := time.Now()
+	s := &opStats{opstr: "ROLLTEST"}
+	for i := range s.delayInfo {
+		s.delayInfo[i] = newDelayInfo(IntervalMark[i], now)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 1; i <= 100; i++ {
+			s.incrOpStats(int64(time.Millisecond*7), 0)
+			s.rollOpStats(now.Add(time.Duration(i) * time.Second))
+		}
+	}()
+	//api读取与统计协程并发
+	for i := 0; i < 100; i++ {
+		for _, interval := range IntervalMark {
+			s.GetOpStatsByInterval(interval)
+		}
+		s.delayInfo[0].getTP100()
+	}
+	wg.Wait()
+	o := s.GetOpStatsByInterval(3600)
+	assert.Must(o.Calls == 100 && o.TP100 == 7)
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+)
+
+type streamRead struct {
+	keys [][]byte
+
+	//带BLOCK参数时为true，block为0表示一直阻塞
+	blocking bool
+	block    time.Duration
+}
+
+//XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+//XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+//参数不合法时keys为空，由后端返回错误
+func parseStreamRead(args [][]byte) *streamRead {
+	var x = &streamRead{}
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "COUNT":
+			i++
+		case "GROUP":
+			i += 2
+		case "NOACK":
+		case "BLOCK":
+			if i+1 >= len(args) {
+				return &streamRead{}
+			}
+			n, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil || n < 0 {
+				return &streamRead{}
+			}
+			x.blocking, x.block = true, time.Duration(n)*time.Millisecond
+			i++
+		case "STREAMS":
+			var rest = args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				return &streamRead{}
+			}
+			x.keys = rest[:len(rest)/2]
+			return x
+		default:
+			return &streamRead{}
+		}
+	}
+	return &streamRead{}
+}
+
+//多个stream必须属于同一个slot，带BLOCK时使用独立的后端连接
+func (s *Session) handleStreamRead(r *Request, d *Router) error {
+	var args = make([][]byte, 0, len(r.Multi))
+	for _, x := range r.Multi[1:] {
+		args = append(args, x.Value)
+	}
+	x := parseStreamRead(args)
+	if len(x.keys) == 0 {
+		return d.dispatch(r)
+	}
+	slot, ok := d.keysSlot(x.keys)
+	if !ok {
+		r.Resp = redis.NewErrorf("CROSSSLOT Keys in request don't hash to the same slot")
+		return nil
+	}
+	//getHashKey可能取到ID等非key参数，按stream的key转发
+	if !x.blocking {
+		return slot.forward(r, x.keys[0])
+	}
+	return s.dispatchBlocking(r, d, slot, x.keys, x.block)
+}
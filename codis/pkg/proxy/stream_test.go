@@ -0,0 +1,105 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/timesize"
+)
+
+func TestParseStreamRead(x *testing.T) {
+	parse := func(args ...string) *streamRead {
+		var b [][]byte
+		for _, arg := range args {
+			b = append(b, []byte(arg))
+		}
+		return parseStreamRead(b)
+	}
+	var r = parse("COUNT", "1", "streams", "a", "b", "0", "0")
+	assert.Must(len(r.keys) == 2 && string(r.keys[1]) == "b" && !r.blocking)
+	r = parse("GROUP", "g", "c", "BLOCK", "100", "NOACK", "STREAMS", "a", ">")
+	assert.Must(len(r.keys) == 1 && r.blocking && r.block == time.Millisecond*100)
+	assert.Must(len(parse("STREAMS", "a", "b", "0").keys) == 0)
+	assert.Must(len(parse("BLOCK", "-1", "STREAMS", "a", "0").keys) == 0)
+	assert.Must(len(parse("COUNT", "1").keys) == 0)
+
+	var multi []*redis.Resp
+	for _, arg := range []string{"XREAD", "BLOCK", "0", "STREAMS", "{s}a", "{s}b", "$", "$"} {
+		multi = append(multi, redis.NewBulkBytes([]byte(arg)))
+	}
+	assert.Must(string(getHashKey(multi, "XREAD")) == "{s}a")
+	keys := namespaceKeys(&Request{Multi: multi, OpStr: "XREAD"})
+	assert.Must(len(keys) == 2 && string(keys[1]) == "{s}b")
+}
+
+//XREAD带BLOCK时延迟回复，其他命令立即回复
+type fakeStreamServer struct {
+	l     net.Listener
+	delay time.Duration
+}
+
+func (f *fakeStreamServer) serve(c *redis.Conn) {
+	defer c.Close()
+	for {
+		multi, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		var resp = RespOK
+		if strings.ToUpper(string(multi[0].Value)) == "XREAD" {
+			time.Sleep(f.delay)
+			resp = redis.NewArray(nil)
+		}
+		c.Encode(resp, true)
+	}
+}
+
+func TestStreamBlocking(x *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	defer l.Close()
+	f := &fakeStreamServer{l: l, delay: time.Millisecond * 300}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+
+	c := newProxyConfig()
+	c.BackendRecvTimeout = timesize.Duration(time.Millisecond * 100)
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("{s}a")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	bc := router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true)
+	assert.Must(waitFor(bc.IsConnected))
+
+	s := newHelloSession("")
+	s.config = c
+
+	assert.Must(strings.HasPrefix(string(doTxRequest(s, router, "XREAD", "STREAMS", "{s}a", "{t}a", "0", "0").Value), "CROSSSLOT"))
+
+	//阻塞的XREAD使用独立的连接，不影响共享连接上的其他请求，超过backend_recv_timeout也不会超时
+	r := newACLRequest("XREAD", "BLOCK", "300", "STREAMS", "{s}a", "$")
+	r.Batch = &sync.WaitGroup{}
+	assert.MustNoError(s.handleRequest(r, router))
+	var start = time.Now()
+	assert.Must(string(doTxRequest(s, router, "SET", "{s}b", "1").Value) == "OK")
+	assert.Must(time.Since(start) < f.delay)
+	resp, err := s.handleResponse(r)
+	assert.MustNoError(err)
+	assert.Must(resp.IsArray() && resp.Array == nil)
+}
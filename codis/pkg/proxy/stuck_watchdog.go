@@ -0,0 +1,52 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"runtime/pprof"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//定期检查卡住的后端请求，先把goroutine堆栈打印到日志，再重置连接让请求失败
+func (s *Proxy) AutoAbortStuck() {
+	var dumped time.Time
+	for !s.IsClosed() {
+		time.Sleep(time.Second)
+
+		s.mu.Lock()
+		timeout := s.config.BackendStuckTimeout.Duration()
+		s.mu.Unlock()
+		if timeout <= 0 {
+			continue
+		}
+
+		stuck := s.router.StuckBackendConns(timeout)
+		if len(stuck) == 0 {
+			continue
+		}
+		for _, bc := range stuck {
+			log.Warnf("[%p] backend conn [%p] to %s, db-%d has request stuck over %s, abort it",
+				s, bc, bc.addr, bc.database, timeout)
+		}
+		//同一个问题可能持续触发，每个timeout周期最多打印一次堆栈
+		if time.Since(dumped) >= timeout {
+			dumped = time.Now()
+			log.Warnf("[%p] goroutine stacks:\n%s", s, dumpGoroutines())
+		}
+		for _, bc := range stuck {
+			bc.abortStuck()
+		}
+	}
+}
+
+func dumpGoroutines() string {
+	var b bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&b, 2); err != nil {
+		log.WarnErrorf(err, "dump goroutines failed")
+	}
+	return b.String()
+}
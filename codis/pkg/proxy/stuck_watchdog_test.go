@@ -0,0 +1,27 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestBackendConnStuck(x *testing.T) {
+	bc := &BackendConn{}
+	now := time.Now().UnixNano()
+	assert.Must(!bc.isStuck(time.Second, now))
+
+	bc.waiting.Set(now - int64(time.Millisecond*500))
+	assert.Must(!bc.isStuck(time.Second, now))
+
+	bc.waiting.Set(now - int64(time.Second*2))
+	assert.Must(bc.isStuck(time.Second, now))
+
+	//还没有建立连接时不做任何处理
+	bc.abortStuck()
+	assert.Must(bc.stuck.IsFalse())
+}
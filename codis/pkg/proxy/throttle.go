@@ -0,0 +1,132 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//整个proxy的qps和正在处理的请求数限制，超过时session暂停读取新的请求，由TCP把压力反馈给客户端
+var throttle struct {
+	//为nil时不限制qps
+	limiter atomic.Value
+
+	maxQPS      atomic2.Int64
+	maxInflight atomic2.Int64
+	//已经读取、还没有返回响应的请求数
+	inflight atomic2.Int64
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	//正在等待的session数
+	waiting atomic2.Int64
+	//被延迟读取的请求数和累计的延迟时间
+	delayed atomic2.Int64
+	nsecs   atomic2.Int64
+}
+
+type ThrottleStats struct {
+	MaxQPS      int64 `json:"max_qps"`
+	MaxInflight int64 `json:"max_inflight"`
+	Inflight    int64 `json:"inflight"`
+	//正在被延迟读取的session数，大于0表示正在限流
+	Waiting int64 `json:"waiting"`
+	Delayed int64 `json:"delayed"`
+	Usecs   int64 `json:"usecs"`
+}
+
+func init() {
+	throttle.limiter.Store((*rate.Limiter)(nil))
+	throttle.cond = sync.NewCond(&throttle.mu)
+}
+
+//令牌桶的容量为0.1秒的请求数
+func ThrottleSetMaxQPS(n int64) {
+	throttle.maxQPS.Set(n)
+	if n <= 0 {
+		throttle.limiter.Store((*rate.Limiter)(nil))
+		return
+	}
+	var burst = n / BUCKET_FILL_INTERVAL
+	if burst < 1 {
+		burst = 1
+	}
+	throttle.limiter.Store(rate.NewLimiter(rate.Limit(n), int(burst)))
+}
+
+//调大或者取消限制时唤醒正在等待的session
+func ThrottleSetMaxInflight(n int64) {
+	throttle.maxInflight.Set(n)
+	throttle.mu.Lock()
+	throttle.cond.Broadcast()
+	throttle.mu.Unlock()
+}
+
+func GetThrottleStats() *ThrottleStats {
+	return &ThrottleStats{
+		MaxQPS:      throttle.maxQPS.Int64(),
+		MaxInflight: throttle.maxInflight.Int64(),
+		Inflight:    throttle.inflight.Int64(),
+		Waiting:     throttle.waiting.Int64(),
+		Delayed:     throttle.delayed.Int64(),
+		Usecs:       throttle.nsecs.Int64() / 1e3,
+	}
+}
+
+func isInflightFull() bool {
+	max := throttle.maxInflight.Int64()
+	return max > 0 && throttle.inflight.Int64() >= max
+}
+
+//在处理读取到的请求之前调用，超过限制时阻塞，session在此期间不再读取客户端的数据
+func throttleWait(r *Request) {
+	var start time.Time
+	if isInflightFull() {
+		start = time.Now()
+		throttle.mu.Lock()
+		throttle.waiting.Incr()
+		for isInflightFull() {
+			throttle.cond.Wait()
+		}
+		throttle.waiting.Decr()
+		throttle.mu.Unlock()
+	}
+	//令牌桶的容量至少为1，Reserve总是成功
+	if limiter := throttle.limiter.Load().(*rate.Limiter); limiter != nil {
+		if d := limiter.Reserve().Delay(); d > 0 {
+			if start.IsZero() {
+				start = time.Now()
+			}
+			throttle.waiting.Incr()
+			time.Sleep(d)
+			throttle.waiting.Decr()
+		}
+	}
+	if !start.IsZero() {
+		throttle.delayed.Incr()
+		throttle.nsecs.Add(int64(time.Since(start)))
+	}
+	throttle.inflight.Incr()
+	r.Inflight = true
+}
+
+//响应发送之后或者session关闭时调用
+func throttleDone(r *Request) {
+	if !r.Inflight {
+		return
+	}
+	r.Inflight = false
+	throttle.inflight.Decr()
+	if throttle.waiting.Int64() != 0 {
+		throttle.mu.Lock()
+		throttle.cond.Broadcast()
+		throttle.mu.Unlock()
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestThrottleInflight(x *testing.T) {
+	defer ThrottleSetMaxInflight(0)
+	ThrottleSetMaxInflight(2)
+
+	var inflight = GetThrottleStats().Inflight
+	r1, r2, r3 := &Request{}, &Request{}, &Request{}
+	throttleWait(r1)
+	throttleWait(r2)
+	assert.Must(GetThrottleStats().Inflight == inflight+2)
+
+	//超过max_inflight时等待之前的请求返回
+	var done = make(chan bool)
+	go func() {
+		throttleWait(r3)
+		close(done)
+	}()
+	assert.Must(waitFor(func() bool {
+		return GetThrottleStats().Waiting == 1
+	}))
+	throttleDone(r1)
+	<-done
+	throttleDone(r1)
+	throttleDone(r2)
+	throttleDone(r3)
+	assert.Must(GetThrottleStats().Inflight == inflight && !r3.Inflight)
+
+	//取消限制时唤醒等待的session
+	ThrottleSetMaxInflight(1)
+	throttleWait(r1)
+	done = make(chan bool)
+	go func() {
+		throttleWait(r2)
+		close(done)
+	}()
+	assert.Must(waitFor(func() bool {
+		return GetThrottleStats().Waiting == 1
+	}))
+	ThrottleSetMaxInflight(0)
+	<-done
+	throttleDone(r1)
+	throttleDone(r2)
+}
+
+func TestThrottleQPS(x *testing.T) {
+	defer ThrottleSetMaxQPS(0)
+	ThrottleSetMaxQPS(10)
+
+	var delayed = GetThrottleStats().Delayed
+	var start = time.Now()
+	for i := 0; i < 3; i++ {
+		r := &Request{}
+		throttleWait(r)
+		throttleDone(r)
+	}
+	assert.Must(time.Since(start) >= time.Millisecond*150)
+	assert.Must(GetThrottleStats().Delayed == delayed+2)
+}
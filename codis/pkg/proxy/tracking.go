@@ -0,0 +1,584 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//RESP2客户端通过REDIRECT把失效通知转发到订阅了该频道的连接
+const InvalidateChannel = "__redis__:invalidate"
+
+//后端连接是共享的，proxy对每个主库使用CLIENT TRACKING BCAST接收所有key的失效通知，
+//再按session读取过的key或者BCAST前缀转发给客户端
+type trackingState struct {
+	bcast    bool
+	prefixes []string
+	optin    bool
+	optout   bool
+	noloop   bool
+	redirect int64
+
+	//CLIENT CACHING yes/no，只对下一个命令有效
+	caching string
+	keys    map[string]bool
+	//NOLOOP时自己修改过的key，收到失效通知时不发给自己
+	written map[string]bool
+}
+
+//NOLOOP记录的key超过该数量时清空，避免后端没有通知时一直增长
+const trackingMaxWrittenKeys = 1024
+
+//proxy处理的命令，不需要记录key
+var trackingLocalCommands = map[string]bool{
+	"SELECT": true, "XMONITOR": true, "XSLOWLOG": true, "XCONFIG": true, "XDEADLINE": true,
+	"CLIENT": true, "SUBSCRIBE": true, "UNSUBSCRIBE": true, "PSUBSCRIBE": true, "PUNSUBSCRIBE": true,
+	"PUBSUB": true, "PING": true, "ECHO": true, "INFO": true,
+	"SLOTSINFO": true, "SLOTSSCAN": true, "SLOTSMAPPING": true, "CLUSTER": true, "SCRIPT": true, "SCAN": true, "WAIT": true,
+	"READONLY": true, "READWRITE": true, "ASKING": true,
+}
+
+var trackingTable struct {
+	sync.Mutex
+	sessions map[*Session]*trackingState
+	bcast    map[*Session]*trackingState
+	//订阅了__redis__:invalidate的session，REDIRECT按id查找
+	targets map[int64]*Session
+	keys    map[string]map[*Session]bool
+
+	invalidations atomic2.Int64
+}
+
+func init() {
+	trackingTable.sessions = make(map[*Session]*trackingState)
+	trackingTable.bcast = make(map[*Session]*trackingState)
+	trackingTable.targets = make(map[int64]*Session)
+	trackingTable.keys = make(map[string]map[*Session]bool)
+}
+
+type TrackingStats struct {
+	Sessions      int   `json:"sessions"`
+	Keys          int   `json:"keys"`
+	Invalidations int64 `json:"invalidations"`
+}
+
+func GetTrackingStats() *TrackingStats {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	return &TrackingStats{
+		Sessions: len(trackingTable.sessions), Keys: len(trackingTable.keys),
+		Invalidations: trackingTable.invalidations.Int64(),
+	}
+}
+
+func (t *trackingState) matchPrefix(key string) bool {
+	if len(t.prefixes) == 0 {
+		return true
+	}
+	for _, p := range t.prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
+
+//CLIENT TRACKING ON|OFF [REDIRECT id] [PREFIX prefix ...] [BCAST] [OPTIN] [OPTOUT] [NOLOOP]
+func (s *Session) handleClientTracking(r *Request) error {
+	if len(r.Multi) < 3 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'CLIENT|TRACKING' command")
+		return nil
+	}
+	switch strings.ToUpper(string(r.Multi[2].Value)) {
+	case "OFF":
+		untrackSession(s)
+		s.tracking = false
+		r.Resp = RespOK
+		return nil
+	case "ON":
+	default:
+		r.Resp = redis.NewErrorf("ERR syntax error")
+		return nil
+	}
+
+	t := &trackingState{}
+	for args := r.Multi[3:]; len(args) != 0; args = args[1:] {
+		switch opt := strings.ToUpper(string(args[0].Value)); {
+		case opt == "REDIRECT" && len(args) >= 2:
+			id, err := strconv.ParseInt(string(args[1].Value), 10, 64)
+			if err != nil {
+				r.Resp = redis.NewErrorf("ERR value is not an integer or out of range")
+				return nil
+			}
+			t.redirect, args = id, args[1:]
+		case opt == "PREFIX" && len(args) >= 2:
+			t.prefixes, args = append(t.prefixes, string(args[1].Value)), args[1:]
+		case opt == "BCAST":
+			t.bcast = true
+		case opt == "OPTIN":
+			t.optin = true
+		case opt == "OPTOUT":
+			t.optout = true
+		case opt == "NOLOOP":
+			t.noloop = true
+		default:
+			r.Resp = redis.NewErrorf("ERR syntax error")
+			return nil
+		}
+	}
+	switch {
+	case len(t.prefixes) != 0 && !t.bcast:
+		r.Resp = redis.NewErrorf("ERR PREFIX option requires BCAST mode to be enabled")
+		return nil
+	case t.optin && t.optout:
+		r.Resp = redis.NewErrorf("ERR You can't use both OPTIN and OPTOUT")
+		return nil
+	case t.bcast && (t.optin || t.optout):
+		r.Resp = redis.NewErrorf("ERR OPTIN and OPTOUT are not compatible with BCAST")
+		return nil
+	}
+	if err := trackSession(s, t); err != nil {
+		r.Resp = redis.NewErrorf("ERR %s", err)
+		return nil
+	}
+	s.tracking = true
+	if s.proxy != nil {
+		s.proxy.startTracking()
+	}
+	r.Resp = RespOK
+	return nil
+}
+
+//CLIENT CACHING YES|NO
+func (s *Session) handleClientCaching(r *Request) error {
+	if len(r.Multi) != 3 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'CLIENT|CACHING' command")
+		return nil
+	}
+	var caching = strings.ToUpper(string(r.Multi[2].Value))
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	t := trackingTable.sessions[s]
+	switch {
+	case caching != "YES" && caching != "NO":
+		r.Resp = redis.NewErrorf("ERR syntax error")
+	case t == nil || (caching == "YES" && !t.optin) || (caching == "NO" && !t.optout):
+		r.Resp = redis.NewErrorf("ERR CLIENT CACHING %s is only valid when tracking is enabled in %s mode.",
+			strings.ToLower(caching), map[string]string{"YES": "OPTIN", "NO": "OPTOUT"}[caching])
+	default:
+		t.caching = caching
+		r.Resp = RespOK
+	}
+	return nil
+}
+
+//没有开启tracking时返回-1，不转发时返回0
+func (s *Session) handleClientGetRedir(r *Request) error {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	var id int64 = -1
+	if t := trackingTable.sessions[s]; t != nil {
+		id = t.redirect
+	}
+	r.Resp = redis.NewInt([]byte(strconv.FormatInt(id, 10)))
+	return nil
+}
+
+func (s *Session) handleClientTrackingInfo(r *Request) error {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	var flags []*redis.Resp
+	var redirect int64 = -1
+	var prefixes = []*redis.Resp{}
+	if t := trackingTable.sessions[s]; t == nil {
+		flags = append(flags, redis.NewBulkBytes([]byte("off")))
+	} else {
+		flags = append(flags, redis.NewBulkBytes([]byte("on")))
+		for _, x := range []struct {
+			on   bool
+			flag string
+		}{
+			{t.bcast, "bcast"}, {t.optin, "optin"}, {t.optout, "optout"}, {t.noloop, "noloop"},
+			{t.caching == "YES", "caching-yes"}, {t.caching == "NO", "caching-no"},
+		} {
+			if x.on {
+				flags = append(flags, redis.NewBulkBytes([]byte(x.flag)))
+			}
+		}
+		if t.redirect != 0 && trackingTable.targets[t.redirect] == nil {
+			flags = append(flags, redis.NewBulkBytes([]byte("broken_redirect")))
+		}
+		redirect = t.redirect
+		for _, p := range t.prefixes {
+			prefixes = append(prefixes, redis.NewBulkBytes([]byte(p)))
+		}
+	}
+	r.Resp = redis.NewMap([]*redis.Resp{
+		redis.NewBulkBytes([]byte("flags")), redis.NewSet(flags),
+		redis.NewBulkBytes([]byte("redirect")), redis.NewInt([]byte(strconv.FormatInt(redirect, 10))),
+		redis.NewBulkBytes([]byte("prefixes")), redis.NewArray(prefixes),
+	})
+	return nil
+}
+
+func trackSession(s *Session, t *trackingState) error {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	if t.redirect != 0 && trackingTable.targets[t.redirect] == nil {
+		return errors.New("The client ID you want redirect to does not exist")
+	}
+	lockedUntrackSession(s)
+	trackingTable.sessions[s] = t
+	if t.bcast {
+		trackingTable.bcast[s] = t
+	}
+	return nil
+}
+
+func untrackSession(s *Session) {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	lockedUntrackSession(s)
+}
+
+func lockedUntrackSession(s *Session) {
+	t := trackingTable.sessions[s]
+	if t == nil {
+		return
+	}
+	for key := range t.keys {
+		lockedRemoveTrackingKey(key, s)
+	}
+	delete(trackingTable.sessions, s)
+	delete(trackingTable.bcast, s)
+}
+
+func lockedRemoveTrackingKey(key string, s *Session) {
+	if m := trackingTable.keys[key]; m != nil {
+		delete(m, s)
+		if len(m) == 0 {
+			delete(trackingTable.keys, key)
+		}
+	}
+}
+
+func subscribeInvalidate(s *Session) {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	trackingTable.targets[s.id] = s
+}
+
+func unsubscribeInvalidate(s *Session) {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	if trackingTable.targets[s.id] == s {
+		delete(trackingTable.targets, s.id)
+	}
+}
+
+//记录session读取的key，NOLOOP时记录自己修改的key
+func (s *Session) trackRequest(r *Request) {
+	if trackingLocalCommands[r.OpStr] {
+		return
+	}
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	t := trackingTable.sessions[s]
+	if t == nil {
+		return
+	}
+	var caching = t.caching
+	t.caching = ""
+	if len(r.Multi) < 2 {
+		return
+	}
+	if !r.OpFlag.IsReadOnly() {
+		if t.noloop {
+			if len(t.written) >= trackingMaxWrittenKeys {
+				t.written = nil
+			}
+			if t.written == nil {
+				t.written = make(map[string]bool)
+			}
+			for _, key := range namespaceKeys(r) {
+				t.written[string(key)] = true
+			}
+		}
+		return
+	}
+	switch {
+	case t.bcast:
+		return
+	case t.optin && caching != "YES":
+		return
+	case t.optout && caching == "NO":
+		return
+	}
+	if t.keys == nil {
+		t.keys = make(map[string]bool)
+	}
+	for _, key := range namespaceKeys(r) {
+		k := string(key)
+		if t.keys[k] {
+			continue
+		}
+		t.keys[k] = true
+		m := trackingTable.keys[k]
+		if m == nil {
+			m = make(map[*Session]bool)
+			trackingTable.keys[k] = m
+		}
+		m[s] = true
+	}
+	if n := s.config.SessionTrackingMaxKeys; n != 0 {
+		lockedEvictTrackingKeys(n)
+	}
+}
+
+//超过上限时随机淘汰key，并通知读取过的session
+func lockedEvictTrackingKeys(max int) {
+	if len(trackingTable.keys) <= max {
+		return
+	}
+	var keys = make([]string, 0, len(trackingTable.keys)-max)
+	for key := range trackingTable.keys {
+		if len(trackingTable.keys)-len(keys) <= max {
+			break
+		}
+		keys = append(keys, key)
+	}
+	lockedInvalidateKeys(keys, false)
+}
+
+//由后端的失效通知调用，keys为nil表示flush，所有session都需要清空本地缓存
+func invalidateTrackingKeys(keys []string) {
+	trackingTable.Lock()
+	defer trackingTable.Unlock()
+	lockedInvalidateKeys(keys, true)
+}
+
+//淘汰key时不通知BCAST模式的session
+func lockedInvalidateKeys(keys []string, bcast bool) {
+	var flush = keys == nil
+	var targets = make(map[*Session][]string)
+	if flush {
+		for s, t := range trackingTable.sessions {
+			targets[s], t.keys, t.written = nil, nil, nil
+		}
+		trackingTable.keys = make(map[string]map[*Session]bool)
+	}
+	for _, key := range keys {
+		for s := range trackingTable.keys[key] {
+			delete(trackingTable.sessions[s].keys, key)
+			targets[s] = append(targets[s], key)
+		}
+		delete(trackingTable.keys, key)
+		if !bcast {
+			continue
+		}
+		for s, t := range trackingTable.bcast {
+			if t.matchPrefix(key) {
+				targets[s] = append(targets[s], key)
+			}
+		}
+	}
+	for s, keys := range targets {
+		t := trackingTable.sessions[s]
+		if t.noloop && !flush {
+			var n int
+			for _, key := range keys {
+				if t.written[key] {
+					delete(t.written, key)
+				} else {
+					keys[n], n = key, n+1
+				}
+			}
+			if keys = keys[:n]; n == 0 {
+				continue
+			}
+		}
+		lockedSendInvalidation(s, t, keys, flush)
+	}
+}
+
+//RESP3客户端直接收到invalidate的push消息，REDIRECT时以频道消息发给目标连接
+func lockedSendInvalidation(s *Session, t *trackingState, keys []string, flush bool) {
+	var value = redis.NewNull()
+	if !flush {
+		var array = make([]*redis.Resp, len(keys))
+		for i, key := range keys {
+			array[i] = redis.NewBulkBytes([]byte(key))
+		}
+		value = redis.NewArray(array)
+	}
+	if t.redirect != 0 {
+		target := trackingTable.targets[t.redirect]
+		if target == nil {
+			if s.resp3.IsTrue() {
+				s.pushMessage(redis.NewPush([]*redis.Resp{
+					redis.NewBulkBytes([]byte("tracking-redir-broken")),
+					redis.NewInt([]byte(strconv.FormatInt(t.redirect, 10))),
+				}))
+			}
+			return
+		}
+		trackingTable.invalidations.Incr()
+		target.pushMessage(newPubSubResp("message", InvalidateChannel, value))
+		return
+	}
+	if s.resp3.IsTrue() {
+		trackingTable.invalidations.Incr()
+		s.pushMessage(redis.NewPush([]*redis.Resp{
+			redis.NewBulkBytes([]byte("invalidate")), value,
+		}))
+	}
+}
+
+//返回所有slot和前缀路由的主库地址
+func (s *Router) MasterAddrs() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var addrs []string
+	var exists = make(map[string]bool)
+	for _, slot := range s.allSlots() {
+		addr := slot.backend.bc.Addr()
+		if addr != "" && !exists[addr] {
+			exists[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+//第一个客户端开启tracking时才连接后端
+func (s *Proxy) startTracking() {
+	s.tracking.Do(func() {
+		go s.loopTracking()
+	})
+}
+
+func (s *Proxy) loopTracking() {
+	var listeners = make(map[string]chan struct{})
+	defer func() {
+		for _, stop := range listeners {
+			close(stop)
+		}
+	}()
+	for !s.IsClosed() {
+		var addrs = make(map[string]bool)
+		for _, addr := range s.router.MasterAddrs() {
+			addrs[addr] = true
+			if listeners[addr] == nil {
+				listeners[addr] = make(chan struct{})
+				go s.trackBackend(addr, listeners[addr])
+			}
+		}
+		for addr, stop := range listeners {
+			if !addrs[addr] {
+				close(stop)
+				delete(listeners, addr)
+			}
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+//连接断开后可能丢失了通知，重连前让所有客户端清空缓存
+func (s *Proxy) trackBackend(addr string, stop <-chan struct{}) {
+	for {
+		connected, err := s.listenInvalidations(addr, stop)
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		log.WarnErrorf(err, "tracking invalidations from backend %s failed", addr)
+		if connected {
+			invalidateTrackingKeys(nil)
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Second * 5):
+		}
+	}
+}
+
+func (s *Proxy) listenInvalidations(addr string, stop <-chan struct{}) (bool, error) {
+	c, err := redis.DialTLSTimeout(addr, time.Second*5, 8192, 8192, s.config.backendTLS)
+	if err != nil {
+		return false, err
+	}
+	defer c.Close()
+	c.WriterTimeout = s.config.BackendSendTimeout.Duration()
+
+	var cmds = [][]string{{"HELLO", "3"}, {"CLIENT", "TRACKING", "ON", "BCAST"}}
+	if auth := s.config.ProductAuth; auth != "" {
+		cmds[0] = append(cmds[0], "AUTH", "default", auth)
+	}
+	for _, args := range cmds {
+		var multi = make([]*redis.Resp, len(args))
+		for i, arg := range args {
+			multi[i] = redis.NewBulkBytes([]byte(arg))
+		}
+		if err := c.EncodeMultiBulk(multi, true); err != nil {
+			return false, err
+		}
+		resp, err := c.Decode()
+		if err != nil {
+			return false, err
+		}
+		if resp.IsError() {
+			return false, errors.Errorf("%s failed: %s", args[0], resp.Value)
+		}
+	}
+	log.Warnf("tracking invalidations from backend %s", addr)
+
+	//定期PING，避免空闲连接被后端断开
+	var done = make(chan struct{})
+	defer close(done)
+	go func() {
+		var ticker = time.NewTicker(math2.MaxDuration(s.config.BackendPingPeriod.Duration(), time.Second*5))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-stop:
+				c.Close()
+				return
+			case <-ticker.C:
+				c.EncodeMultiBulk([]*redis.Resp{redis.NewBulkBytes([]byte("PING"))}, true)
+			}
+		}
+	}()
+
+	for {
+		resp, err := c.Decode()
+		if err != nil {
+			return true, err
+		}
+		if !resp.IsPush() || len(resp.Array) < 2 || string(resp.Array[0].Value) != "invalidate" {
+			continue
+		}
+		if resp.Array[1].Type == redis.TypeNull || resp.Array[1].Array == nil {
+			invalidateTrackingKeys(nil)
+			continue
+		}
+		var keys = make([]string, 0, len(resp.Array[1].Array))
+		for _, x := range resp.Array[1].Array {
+			keys = append(keys, string(x.Value))
+		}
+		invalidateTrackingKeys(keys)
+	}
+}
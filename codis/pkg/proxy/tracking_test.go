@@ -0,0 +1,165 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func newTrackingSession(resp3 bool) *Session {
+	s := newHelloSession("")
+	s.tasks = NewRequestChan()
+	s.resp3.Set(resp3)
+	return s
+}
+
+func mustClient(s *Session, args ...string) *redis.Resp {
+	r := newACLRequest(append([]string{"CLIENT"}, args...)...)
+	assert.MustNoError(s.handleClient(r))
+	return r.Resp
+}
+
+func mustTrack(s *Session, args ...string) {
+	r := newACLRequest(args...)
+	s.trackRequest(r)
+}
+
+//返回推送的失效通知中的key，没有通知时返回nil
+func popInvalidation(s *Session) []string {
+	if s.tasks.IsEmpty() {
+		return nil
+	}
+	r, _ := s.tasks.PopFront()
+	resp := r.Resp
+	assert.Must(resp.IsPush())
+	var value = resp.Array[len(resp.Array)-1]
+	var keys = []string{}
+	for _, x := range value.Array {
+		keys = append(keys, string(x.Value))
+	}
+	return keys
+}
+
+func TestTracking(x *testing.T) {
+	s1 := newTrackingSession(true)
+	defer untrackSession(s1)
+	assert.Must(mustClient(s1, "TRACKING", "ON").IsString() && s1.tracking)
+	mustTrack(s1, "GET", "k1")
+	mustTrack(s1, "MGET", "k2", "k3")
+	assert.Must(GetTrackingStats().Keys == 3)
+
+	invalidateTrackingKeys([]string{"k1", "k3", "k4"})
+	keys := popInvalidation(s1)
+	assert.Must(len(keys) == 2 && keys[0] == "k1" && keys[1] == "k3")
+	invalidateTrackingKeys([]string{"k1"})
+	assert.Must(popInvalidation(s1) == nil)
+
+	//RESP2客户端通过REDIRECT转发到订阅了失效通知频道的连接
+	s2 := newTrackingSession(false)
+	defer unsubscribeInvalidate(s2)
+	r := newACLRequest("SUBSCRIBE", InvalidateChannel)
+	assert.MustNoError(s2.handleSubscribe(r))
+	assert.Must(s2.invalidations && string(r.Resp.Array[2].Value) == "1")
+
+	s3 := newTrackingSession(false)
+	defer untrackSession(s3)
+	assert.Must(mustClient(s3, "TRACKING", "ON", "REDIRECT", "12345").IsError())
+	assert.Must(mustClient(s3, "TRACKING", "ON", "PREFIX", "user:").IsError())
+	assert.Must(mustClient(s3, "TRACKING", "ON", "REDIRECT", strconv.FormatInt(s2.id, 10), "BCAST", "PREFIX", "user:").IsString())
+	assert.Must(string(mustClient(s3, "GETREDIR").Value) == strconv.FormatInt(s2.id, 10))
+
+	invalidateTrackingKeys([]string{"user:1", "other"})
+	r, ok := s2.tasks.PopFront()
+	assert.Must(ok && r.Resp.IsPush() && !r.RESP3)
+	assert.Must(string(r.Resp.Array[1].Value) == InvalidateChannel && len(r.Resp.Array[2].Array) == 1)
+	assert.Must(popInvalidation(s3) == nil)
+
+	//flush时所有session都收到通知
+	mustTrack(s1, "GET", "k5")
+	invalidateTrackingKeys(nil)
+	r, ok = s1.tasks.PopFront()
+	assert.Must(ok && r.Resp.Array[1].Type == redis.TypeNull)
+	assert.Must(GetTrackingStats().Keys == 0)
+	s2.tasks.PopFront()
+
+	assert.Must(mustClient(s3, "TRACKING", "OFF").IsString() && !s3.tracking)
+	assert.Must(string(mustClient(s3, "GETREDIR").Value) == "-1")
+}
+
+func TestTrackingOptions(x *testing.T) {
+	s := newTrackingSession(true)
+	defer untrackSession(s)
+	assert.Must(mustClient(s, "CACHING", "YES").IsError())
+	assert.Must(mustClient(s, "TRACKING", "ON", "OPTIN", "NOLOOP").IsString())
+	assert.Must(mustClient(s, "CACHING", "NO").IsError())
+	assert.Must(mustClient(s, "CACHING", "YES").IsString())
+
+	mustTrack(s, "GET", "a")
+	mustTrack(s, "GET", "b")
+	assert.Must(GetTrackingStats().Keys == 1)
+
+	//NOLOOP时自己的修改不通知
+	mustTrack(s, "SET", "a", "1")
+	invalidateTrackingKeys([]string{"a"})
+	assert.Must(popInvalidation(s) == nil)
+
+	info := mustClient(s, "TRACKINGINFO")
+	assert.Must(info.Type == redis.TypeMap && len(info.Array[1].Array) == 3)
+	untrackSession(s)
+
+	//超过上限时淘汰key并通知
+	s.config.SessionTrackingMaxKeys = 2
+	assert.Must(mustClient(s, "TRACKING", "ON").IsString())
+	mustTrack(s, "MGET", "x", "y", "z")
+	assert.Must(GetTrackingStats().Keys == 2)
+	assert.Must(len(popInvalidation(s)) == 1)
+}
+
+func TestTrackingBackend(x *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		conn := redis.NewConn(c, 1024, 1024)
+		for i := 0; i < 2; i++ {
+			if _, err := conn.DecodeMultiBulk(); err != nil {
+				return
+			}
+			conn.Encode(redis.NewString([]byte("OK")), true)
+		}
+		conn.Encode(redis.NewPush([]*redis.Resp{
+			redis.NewBulkBytes([]byte("invalidate")),
+			redis.NewArray([]*redis.Resp{redis.NewBulkBytes([]byte("bk"))}),
+		}), true)
+		conn.DecodeMultiBulk()
+	}()
+
+	s := newTrackingSession(true)
+	defer untrackSession(s)
+	assert.Must(mustClient(s, "TRACKING", "ON").IsString())
+	mustTrack(s, "GET", "bk")
+
+	p := &Proxy{config: newProxyConfig()}
+	stop := make(chan struct{})
+	defer close(stop)
+	go p.listenInvalidations(l.Addr().String(), stop)
+
+	var deadline = time.Now().Add(time.Second * 5)
+	for s.tasks.IsEmpty() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond * 10)
+	}
+	keys := popInvalidation(s)
+	assert.Must(len(keys) == 1 && keys[0] == "bk")
+}
@@ -0,0 +1,224 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+)
+
+var RespQueued = redis.NewString([]byte("QUEUED"))
+
+//MULTI之后的命令在session中排队，EXEC时和MULTI、EXEC一起发送到slot所在的后端
+//事务和WATCH的key必须属于同一个slot，匹配前缀路由的key属于对应的路由
+type txState struct {
+	active bool
+	//排队时有命令出错，EXEC返回EXECABORT
+	dirty bool
+
+	//为nil时还没有绑定slot
+	slot *Slot
+
+	queued [][]*redis.Resp
+	keys   [][]byte
+	seen   map[string]bool
+
+	//WATCH之后使用独立的后端连接，事务结束或UNWATCH后关闭，last为最后发送的请求
+	watch *BackendConn
+	last  *Request
+}
+
+//MULTI之后立即执行、不排队的命令
+var txImmediateCommands = map[string]bool{
+	"MULTI": true, "EXEC": true, "DISCARD": true, "WATCH": true,
+	"QUIT": true, "AUTH": true, "HELLO": true,
+}
+
+//由proxy处理的命令无法和事务一起发送到后端
+var txLocalCommands = map[string]bool{
+	"SELECT": true, "CLIENT": true, "CLUSTER": true,
+	"XMONITOR": true, "XSLOWLOG": true, "XCONFIG": true, "XDEADLINE": true,
+	"SUBSCRIBE": true, "UNSUBSCRIBE": true, "PSUBSCRIBE": true, "PUNSUBSCRIBE": true, "PUBSUB": true,
+	"SLOTSINFO": true, "SLOTSSCAN": true, "SLOTSMAPPING": true, "SCRIPT": true, "SCAN": true, "WAIT": true,
+	"READONLY": true, "READWRITE": true, "ASKING": true,
+}
+
+func (s *Session) handleMulti(r *Request) error {
+	if s.tx.active {
+		r.Resp = redis.NewErrorf("ERR MULTI calls can not be nested")
+		return nil
+	}
+	s.tx.active = true
+	r.Resp = RespOK
+	return nil
+}
+
+func (s *Session) handleDiscard(r *Request) error {
+	if !s.tx.active {
+		r.Resp = redis.NewErrorf("ERR DISCARD without MULTI")
+		return nil
+	}
+	s.resetTx()
+	r.Resp = RespOK
+	return nil
+}
+
+func (s *Session) handleExec(r *Request, d *Router) error {
+	if !s.tx.active {
+		r.Resp = redis.NewErrorf("ERR EXEC without MULTI")
+		return nil
+	}
+	var tx = s.tx
+	s.tx = txState{}
+
+	switch {
+	case tx.dirty:
+		r.Resp = redis.NewErrorf("EXECABORT Transaction discarded because of previous errors.")
+	case tx.watch != nil && (tx.watch.pool.reconnects.Int64() != 0 || d.txSlotAddr(tx.slot) != tx.watch.Addr()):
+		//WATCH的连接断开过或者slot已经迁移，无法确定key是否被修改过，按被修改处理
+		r.Resp = redis.NewArray(nil)
+	case len(tx.queued) == 0:
+		r.Resp = redis.NewArray([]*redis.Resp{})
+	}
+	if r.Resp != nil {
+		tx.closeWatch()
+		return nil
+	}
+
+	r.Tx, r.TxKeys = tx.queued, tx.keys
+	invalidateHotCacheKeys(r.OpStr, r.Database, tx.keys)
+	if tx.watch != nil {
+		tx.watch.PushBack(r)
+		tx.last = r
+		tx.closeWatch()
+		return nil
+	}
+	if tx.slot == nil {
+		tx.slot = d.keySlot(nil)
+	}
+	return tx.slot.forward(r, nil)
+}
+
+func (s *Session) handleWatch(r *Request, d *Router) error {
+	if s.tx.active {
+		r.Resp = redis.NewErrorf("ERR WATCH inside MULTI is not allowed")
+		return nil
+	}
+	if len(r.Multi) < 2 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'watch' command")
+		return nil
+	}
+	keys := namespaceKeys(r)
+	if resp := s.bindTxSlot(keys, d); resp != nil {
+		r.Resp = resp
+		return nil
+	}
+	addr, err := d.watchAddr(s.tx.slot, keys, r)
+	if err != nil {
+		return err
+	}
+	if s.tx.watch == nil {
+		s.tx.watch = NewBackendConn(addr, int(s.database), s.config)
+	}
+	s.tx.watch.PushBack(r)
+	s.tx.last = r
+	return nil
+}
+
+func (s *Session) handleUnwatch(r *Request) error {
+	s.resetTx()
+	r.Resp = RespOK
+	return nil
+}
+
+//排队时出错的命令会使EXEC失败，与redis一致
+func (s *Session) queueTxRequest(r *Request, d *Router) error {
+	if txLocalCommands[r.OpStr] {
+		r.Resp = redis.NewErrorf("ERR command '%s' is not allowed inside MULTI", strings.ToLower(r.OpStr))
+		return nil
+	}
+	var keys [][]byte
+	if len(r.Multi) >= 2 && !namespaceKeylessCommands[r.OpStr] && r.OpStr != "INFO" {
+		keys = namespaceKeys(r)
+	}
+	if resp := s.bindTxSlot(keys, d); resp != nil {
+		r.Resp = resp
+		return nil
+	}
+	if s.tx.seen == nil {
+		s.tx.seen = make(map[string]bool)
+	}
+	for _, key := range keys {
+		if !s.tx.seen[string(key)] {
+			s.tx.seen[string(key)] = true
+			s.tx.keys = append(s.tx.keys, key)
+		}
+	}
+	s.tx.queued = append(s.tx.queued, r.Multi)
+	r.Resp = RespQueued
+	return nil
+}
+
+func (s *Session) checkTxQueued(r *Request) {
+	if r.Resp != RespQueued {
+		s.tx.dirty = true
+	}
+}
+
+func (s *Session) bindTxSlot(keys [][]byte, d *Router) *redis.Resp {
+	var slot = s.tx.slot
+	for _, key := range keys {
+		x := d.keySlot(key)
+		switch {
+		case slot == nil:
+			slot = x
+		case x != slot:
+			return redis.NewErrorf("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	s.tx.slot = slot
+	return nil
+}
+
+func (s *Session) resetTx() {
+	s.tx.closeWatch()
+	s.tx = txState{}
+}
+
+//关闭后队列中的请求不会再发送，等最后一个请求收到响应后再关闭
+func (tx *txState) closeWatch() {
+	if tx.watch == nil {
+		return
+	}
+	var bc, r = tx.watch, tx.last
+	go func() {
+		r.Batch.Wait()
+		bc.Close()
+	}()
+}
+
+//WATCH和阻塞命令使用独立的连接，slot正在迁移时先把key迁移到目标后端
+func (s *Router) txSlotAddr(slot *Slot) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slot.backend.bc.Addr()
+}
+
+func (s *Router) watchAddr(slot *Slot, keys [][]byte, r *Request) (string, error) {
+	slot.lock.RLock()
+	defer slot.lock.RUnlock()
+	if slot.backend.bc == nil {
+		return "", ErrSlotIsNotReady
+	}
+	if slot.migrate.bc != nil {
+		var d forwardHelper
+		for _, hkey := range keys {
+			if err := d.slotsmgrt(slot, hkey, r.Database, r.Seed16()); err != nil {
+				return "", err
+			}
+		}
+	}
+	return slot.backend.bc.Addr(), nil
+}
@@ -0,0 +1,204 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//记录收到的命令，MULTI之后回复QUEUED，EXEC时WATCH的key被touch过则返回nil
+type fakeTxServer struct {
+	sync.Mutex
+	l        net.Listener
+	commands []string
+	touched  map[string]bool
+}
+
+func newFakeTxServer() *fakeTxServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	f := &fakeTxServer{l: l, touched: make(map[string]bool)}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return f
+}
+
+func (f *fakeTxServer) serve(c *redis.Conn) {
+	defer c.Close()
+	var queued int
+	var multi bool
+	var watched []string
+	for {
+		args, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		var cmd []string
+		for _, x := range args {
+			cmd = append(cmd, string(x.Value))
+		}
+		f.Lock()
+		f.commands = append(f.commands, strings.Join(cmd, " "))
+		var resp = RespOK
+		switch op := strings.ToUpper(cmd[0]); {
+		case op == "WATCH":
+			watched = append(watched, cmd[1:]...)
+		case op == "MULTI":
+			multi, queued = true, 0
+		case op == "EXEC":
+			var array = make([]*redis.Resp, queued)
+			for i := range array {
+				array[i] = RespOK
+			}
+			resp = redis.NewArray(array)
+			for _, key := range watched {
+				if f.touched[key] {
+					resp = redis.NewArray(nil)
+				}
+			}
+			multi, watched = false, nil
+		case multi:
+			queued++
+			resp = RespQueued
+		}
+		f.Unlock()
+		c.Encode(resp, true)
+	}
+}
+
+func (f *fakeTxServer) takeCommands() []string {
+	f.Lock()
+	defer f.Unlock()
+	commands := f.commands
+	f.commands = nil
+	return commands
+}
+
+func doTxRequest(s *Session, d *Router, args ...string) *redis.Resp {
+	r := newACLRequest(args...)
+	r.Batch = &sync.WaitGroup{}
+	assert.MustNoError(s.handleRequest(r, d))
+	resp, err := s.handleResponse(r)
+	assert.MustNoError(err)
+	return resp
+}
+
+func TestTransaction(x *testing.T) {
+	f := newFakeTxServer()
+	defer f.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("{t}a")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: f.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+
+	bc := router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true)
+	assert.Must(waitFor(bc.IsConnected))
+
+	s := newHelloSession("")
+	defer s.resetTx()
+
+	assert.Must(doTxRequest(s, router, "EXEC").IsError())
+	assert.Must(doTxRequest(s, router, "DISCARD").IsError())
+
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "MULTI").IsError())
+	assert.Must(doTxRequest(s, router, "SET", "{t}a", "1") == RespQueued)
+	assert.Must(doTxRequest(s, router, "INCR", "{t}b") == RespQueued)
+	resp := doTxRequest(s, router, "EXEC")
+	assert.Must(resp.IsArray() && len(resp.Array) == 2)
+	assert.Must(strings.Join(f.takeCommands(), ";") == "MULTI;SET {t}a 1;INCR {t}b;EXEC")
+
+	//key不在同一个slot时EXEC返回EXECABORT，不会发送给后端
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "SET", "{t}a", "1") == RespQueued)
+	assert.Must(strings.HasPrefix(string(doTxRequest(s, router, "GET", "{u}a").Value), "CROSSSLOT"))
+	assert.Must(doTxRequest(s, router, "SELECT", "1").IsError())
+	assert.Must(strings.HasPrefix(string(doTxRequest(s, router, "EXEC").Value), "EXECABORT"))
+
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "DEL", "{t}a") == RespQueued)
+	assert.Must(doTxRequest(s, router, "DISCARD") == RespOK)
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "EXEC").IsArray() && len(f.takeCommands()) == 0)
+
+	//WATCH使用独立的连接，EXEC在同一个连接上发送
+	assert.Must(string(doTxRequest(s, router, "WATCH", "{t}a").Value) == "OK")
+	assert.Must(strings.HasPrefix(string(doTxRequest(s, router, "WATCH", "{u}a").Value), "CROSSSLOT"))
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "WATCH", "{t}b").IsError())
+	assert.Must(doTxRequest(s, router, "SET", "{t}a", "2") == RespQueued)
+	resp = doTxRequest(s, router, "EXEC")
+	assert.Must(resp.IsArray() && len(resp.Array) == 1)
+	assert.Must(strings.Join(f.takeCommands(), ";") == "WATCH {t}a;MULTI;SET {t}a 2;EXEC")
+	assert.Must(s.tx.watch == nil)
+
+	f.Lock()
+	f.touched["{t}a"] = true
+	f.Unlock()
+	assert.Must(string(doTxRequest(s, router, "WATCH", "{t}a").Value) == "OK")
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "SET", "{t}a", "3") == RespQueued)
+	resp = doTxRequest(s, router, "EXEC")
+	assert.Must(resp.IsArray() && resp.Array == nil)
+
+	assert.Must(string(doTxRequest(s, router, "WATCH", "{t}a").Value) == "OK")
+	assert.Must(doTxRequest(s, router, "UNWATCH") == RespOK)
+	assert.Must(s.tx.watch == nil && s.tx.slot == nil)
+}
+
+func TestTransactionPrefixRoute(x *testing.T) {
+	f, g := newFakeTxServer(), newFakeTxServer()
+	defer f.l.Close()
+	defer g.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	var slot = int(Hash([]byte("{t}a")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot, BackendAddr: f.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	assert.MustNoError(router.FillSlot(&models.Slot{Prefix: "{t}p", BackendAddr: g.l.Addr().String(), BackendAddrGroupId: 2}))
+	for _, slot := range []*Slot{&router.slots[slot], router.getRoutes()[0]} {
+		assert.Must(waitFor(slot.backend.bc.BackendConn(0, uint(slot.id), false, true).IsConnected))
+	}
+
+	s := newHelloSession("")
+	defer s.resetTx()
+
+	//hash tag相同，但{t}p开头的key由前缀路由转发
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "SET", "{t}p1", "1") == RespQueued)
+	assert.Must(strings.HasPrefix(string(doTxRequest(s, router, "SET", "{t}a", "1").Value), "CROSSSLOT"))
+	assert.Must(doTxRequest(s, router, "DISCARD") == RespOK)
+
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "SET", "{t}p1", "1") == RespQueued)
+	assert.Must(doTxRequest(s, router, "INCR", "{t}p2") == RespQueued)
+	resp := doTxRequest(s, router, "EXEC")
+	assert.Must(resp.IsArray() && len(resp.Array) == 2)
+	assert.Must(strings.Join(g.takeCommands(), ";") == "MULTI;SET {t}p1 1;INCR {t}p2;EXEC")
+	assert.Must(len(f.takeCommands()) == 0)
+
+	assert.Must(string(doTxRequest(s, router, "WATCH", "{t}p1").Value) == "OK")
+	assert.Must(doTxRequest(s, router, "MULTI") == RespOK)
+	assert.Must(doTxRequest(s, router, "SET", "{t}p1", "2") == RespQueued)
+	resp = doTxRequest(s, router, "EXEC")
+	assert.Must(resp.IsArray() && len(resp.Array) == 1)
+	assert.Must(strings.Join(g.takeCommands(), ";") == "WATCH {t}p1;MULTI;SET {t}p1 2;EXEC")
+}
@@ -0,0 +1,164 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/sync2/atomic2"
+)
+
+//由dashboard下发的按key前缀的过期时间规则
+type ttlRule struct {
+	models.TTLRule
+	prefix []byte
+
+	rewrites atomic2.Int64
+}
+
+type TTLRuleStatus struct {
+	models.TTLRule
+	Rewrites int64 `json:"rewrites"`
+}
+
+//按前缀长度从长到短排列，匹配最长的前缀
+var ttlRules atomic.Value
+
+func init() {
+	ttlRules.Store([]*ttlRule{})
+}
+
+//更新规则时保留已有前缀的改写计数
+func SetTTLRules(rules []*models.TTLRule) {
+	var last = make(map[string]*ttlRule)
+	for _, t := range ttlRules.Load().([]*ttlRule) {
+		last[t.Prefix] = t
+	}
+	var list = make([]*ttlRule, 0, len(rules))
+	for _, x := range rules {
+		if x == nil || x.Prefix == "" {
+			continue
+		}
+		t := &ttlRule{TTLRule: *x, prefix: []byte(x.Prefix)}
+		if p := last[x.Prefix]; p != nil {
+			t.rewrites.Set(p.rewrites.Int64())
+		}
+		list = append(list, t)
+	}
+	sort.Sort(sliceTTLRule(list))
+	ttlRules.Store(list)
+}
+
+func GetTTLRuleStatus() []*TTLRuleStatus {
+	var list = ttlRules.Load().([]*ttlRule)
+	var all = make([]*TTLRuleStatus, 0, len(list))
+	for _, t := range list {
+		all = append(all, &TTLRuleStatus{TTLRule: t.TTLRule, Rewrites: t.rewrites.Int64()})
+	}
+	return all
+}
+
+func matchTTLRule(list []*ttlRule, key []byte) *ttlRule {
+	for _, t := range list {
+		if bytes.HasPrefix(key, t.prefix) {
+			return t
+		}
+	}
+	return nil
+}
+
+//按规则修正过期时间，unit为1表示秒、1000表示毫秒；ttl<=0表示删除key，不做修正
+func (t *ttlRule) clamp(ttl int64, unit int64) int64 {
+	if ttl <= 0 {
+		return ttl
+	}
+	if t.MinTTL > 0 && ttl < t.MinTTL*unit {
+		return t.MinTTL * unit
+	}
+	if t.MaxTTL > 0 && ttl > t.MaxTTL*unit {
+		return t.MaxTTL * unit
+	}
+	return ttl
+}
+
+//修正第i个参数表示的过期时间，返回是否改写
+func (t *ttlRule) rewriteArg(multi []*redis.Resp, i int, unit int64) bool {
+	ttl, err := strconv.ParseInt(string(multi[i].Value), 10, 64)
+	if err != nil {
+		return false
+	}
+	if x := t.clamp(ttl, unit); x != ttl {
+		multi[i] = redis.NewBulkBytes(strconv.AppendInt(nil, x, 10))
+		return true
+	}
+	return false
+}
+
+//改写SET EX/PX、SETEX、PSETEX、EXPIRE、PEXPIRE的过期时间，没有过期时间的SET加上DefaultTTL
+func rewriteTTL(r *Request) bool {
+	var list = ttlRules.Load().([]*ttlRule)
+	if len(list) == 0 || len(r.Multi) < 3 {
+		return false
+	}
+	t := matchTTLRule(list, r.Multi[1].Value)
+	if t == nil {
+		return false
+	}
+	var rewritten bool
+	switch r.OpStr {
+	case "SET":
+		rewritten = t.rewriteSet(r)
+	case "SETEX", "EXPIRE":
+		rewritten = t.rewriteArg(r.Multi, 2, 1)
+	case "PSETEX", "PEXPIRE":
+		rewritten = t.rewriteArg(r.Multi, 2, 1000)
+	}
+	if rewritten {
+		t.rewrites.Incr()
+	}
+	return rewritten
+}
+
+func (t *ttlRule) rewriteSet(r *Request) bool {
+	for i := 3; i < len(r.Multi); i++ {
+		switch opt := bytes.ToUpper(r.Multi[i].Value); {
+		case bytes.Equal(opt, []byte("EX")) && i+1 < len(r.Multi):
+			return t.rewriteArg(r.Multi, i+1, 1)
+		case bytes.Equal(opt, []byte("PX")) && i+1 < len(r.Multi):
+			return t.rewriteArg(r.Multi, i+1, 1000)
+		case bytes.Equal(opt, []byte("KEEPTTL")), bytes.Equal(opt, []byte("EXAT")), bytes.Equal(opt, []byte("PXAT")):
+			return false
+		}
+	}
+	if t.DefaultTTL <= 0 {
+		return false
+	}
+	r.Multi = append(r.Multi,
+		redis.NewBulkBytes([]byte("EX")),
+		redis.NewBulkBytes(strconv.AppendInt(nil, t.DefaultTTL, 10)),
+	)
+	return true
+}
+
+type sliceTTLRule []*ttlRule
+
+func (s sliceTTLRule) Len() int {
+	return len(s)
+}
+
+func (s sliceTTLRule) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceTTLRule) Less(i, j int) bool {
+	if len(s[i].prefix) != len(s[j].prefix) {
+		return len(s[i].prefix) > len(s[j].prefix)
+	}
+	return s[i].Prefix < s[j].Prefix
+}
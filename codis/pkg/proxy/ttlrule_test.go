@@ -0,0 +1,52 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestTTLRule(x *testing.T) {
+	defer SetTTLRules(nil)
+
+	SetTTLRules([]*models.TTLRule{
+		{Prefix: "session:", MinTTL: 60, MaxTTL: 3600, DefaultTTL: 600},
+		{Prefix: "session:tmp:", MaxTTL: 10},
+	})
+
+	r := newQuotaRequest("SET", "session:1", "a")
+	assert.Must(rewriteTTL(r))
+	assert.Must(len(r.Multi) == 5 && string(r.Multi[3].Value) == "EX" && string(r.Multi[4].Value) == "600")
+
+	r = newQuotaRequest("SET", "session:1", "a", "PX", "1000")
+	assert.Must(rewriteTTL(r) && string(r.Multi[4].Value) == "60000")
+
+	r = newQuotaRequest("SET", "session:1", "a", "KEEPTTL")
+	assert.Must(!rewriteTTL(r) && len(r.Multi) == 4)
+
+	r = newQuotaRequest("EXPIRE", "session:1", "86400")
+	assert.Must(rewriteTTL(r) && string(r.Multi[2].Value) == "3600")
+
+	r = newQuotaRequest("EXPIRE", "session:1", "-1")
+	assert.Must(!rewriteTTL(r))
+
+	//匹配最长的前缀，没有DefaultTTL时不追加过期时间
+	r = newQuotaRequest("SETEX", "session:tmp:1", "100", "a")
+	assert.Must(rewriteTTL(r) && string(r.Multi[2].Value) == "10")
+	r = newQuotaRequest("SET", "session:tmp:1", "a")
+	assert.Must(!rewriteTTL(r))
+
+	r = newQuotaRequest("SET", "user:1", "a")
+	assert.Must(!rewriteTTL(r) && len(r.Multi) == 3)
+
+	//更新规则时保留改写计数
+	SetTTLRules([]*models.TTLRule{
+		{Prefix: "session:", MaxTTL: 7200},
+	})
+	status := GetTTLRuleStatus()
+	assert.Must(len(status) == 1 && status[0].Rewrites == 3 && status[0].MaxTTL == 7200)
+}
@@ -0,0 +1,255 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+)
+
+//一条规则，例如 order:1:100ms 表示写order开头的key之后，至少1个从库要在100ms内确认
+type waitRule struct {
+	prefix   []byte
+	replicas int64
+	timeout  time.Duration
+}
+
+var waitRules atomic.Value
+
+func init() {
+	waitRules.Store([]*waitRule{})
+}
+
+//解析规则，格式为 prefix:replicas:timeout，多个规则以逗号分隔，前缀更长的规则优先匹配
+func parseWaitRules(value string) ([]*waitRule, error) {
+	var rules []*waitRule
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		fields := strings.Split(item, ":")
+		if len(fields) != 3 || fields[0] == "" {
+			return nil, fmt.Errorf("invalid wait rule '%s'", item)
+		}
+		replicas, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || replicas <= 0 {
+			return nil, fmt.Errorf("invalid wait replicas '%s'", fields[1])
+		}
+		timeout, err := time.ParseDuration(fields[2])
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid wait timeout '%s'", fields[2])
+		}
+		rules = append(rules, &waitRule{
+			prefix: []byte(fields[0]), replicas: replicas, timeout: timeout,
+		})
+	}
+	sort.SliceStable(rules, func(i, j int) bool {
+		return len(rules[i].prefix) > len(rules[j].prefix)
+	})
+	return rules, nil
+}
+
+func WaitSetRules(value string) error {
+	rules, err := parseWaitRules(value)
+	if err != nil {
+		return err
+	}
+	waitRules.Store(rules)
+	return nil
+}
+
+func matchWaitRule(key []byte) *waitRule {
+	for _, rule := range waitRules.Load().([]*waitRule) {
+		if bytes.HasPrefix(key, rule.prefix) {
+			return rule
+		}
+	}
+	return nil
+}
+
+//WAIT推进复制偏移量时使用的频道
+var waitChannel = []byte("__codis__:wait")
+
+//记录写过的slot及写入时slot所在的主库，只在loopReader中访问
+func (s *Session) recordWrite(r *Request, d *Router) {
+	if len(r.Multi) < 2 {
+		return
+	}
+	for _, key := range namespaceKeys(r) {
+		id := int(Hash(key) % MaxSlotNum)
+		if _, ok := s.written[id]; ok {
+			continue
+		}
+		if s.written == nil {
+			s.written = make(map[int]string)
+		}
+		s.written[id] = d.slotAddr(id)
+	}
+}
+
+//返回需要发送WAIT的主库，没有写过时发送到所有主库
+//写入之后slot所在的主库发生了变化，写入可能已经丢失，switched为true
+func (s *Session) waitAddrs(d *Router) (addrs []string, switched bool) {
+	if len(s.written) == 0 {
+		return d.MasterAddrs(), false
+	}
+	var exists = make(map[string]bool)
+	for id, addr := range s.written {
+		current := d.slotAddr(id)
+		if current != addr {
+			s.written[id], switched = current, true
+		}
+		if current != "" && !exists[current] {
+			exists[current] = true
+			addrs = append(addrs, current)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs, switched
+}
+
+//WAIT numreplicas timeout
+//发送到写过的所有主库，返回确认数的最小值；主库发生过切换时返回0
+func (s *Session) handleWait(r *Request, d *Router) error {
+	if len(r.Multi) != 3 {
+		r.Resp = redis.NewErrorf("ERR wrong number of arguments for 'wait' command")
+		return nil
+	}
+	replicas, err1 := strconv.ParseInt(string(r.Multi[1].Value), 10, 64)
+	timeout, err2 := strconv.ParseInt(string(r.Multi[2].Value), 10, 64)
+	switch {
+	case err1 != nil || err2 != nil:
+		r.Resp = redis.NewErrorf("ERR value is not an integer or out of range")
+		return nil
+	case timeout < 0:
+		r.Resp = redis.NewErrorf("ERR timeout is negative")
+		return nil
+	}
+	addrs, switched := s.waitAddrs(d)
+	if switched || len(addrs) == 0 {
+		r.Resp = redis.NewInt([]byte("0"))
+		return nil
+	}
+	sub := s.dispatchWait(r, addrs, replicas, time.Duration(timeout)*time.Millisecond)
+	r.Coalesce = func() error {
+		n, resp, err := waitReplies(sub)
+		switch {
+		case err != nil:
+			return err
+		case resp != nil:
+			r.Resp = resp
+		default:
+			r.Resp = redis.NewInt(strconv.AppendInt(nil, n, 10))
+		}
+		return nil
+	}
+	return nil
+}
+
+//写匹配规则的key时，收到主库的响应后再等待从库确认，确认数不足时返回NOREPLICAS
+//规则只按第一个key匹配，等待请求中所有key所在的主库
+func (s *Session) requireReplicas(r *Request, d *Router, rule *waitRule) {
+	var addrs []string
+	var exists = make(map[string]bool)
+	for _, key := range namespaceKeys(r) {
+		addr := d.slotAddr(int(Hash(key) % MaxSlotNum))
+		if addr != "" && !exists[addr] {
+			exists[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+	var coalesce = r.Coalesce
+	r.Coalesce = func() error {
+		if coalesce != nil {
+			if err := coalesce(); err != nil {
+				return err
+			}
+		}
+		//MULTI中排队的命令在EXEC时才执行
+		if r.Err != nil || r.Resp == nil || r.Resp.IsError() || r.Resp == RespQueued || len(addrs) == 0 {
+			return nil
+		}
+		//r.Batch已经完成，等待请求使用独立的WaitGroup
+		x := &Request{Batch: &sync.WaitGroup{}, Database: r.Database, OpStr: "WAIT", OpFlag: r.OpFlag}
+		sub := s.dispatchWait(x, addrs, rule.replicas, rule.timeout)
+		x.Batch.Wait()
+		n, resp, err := waitReplies(sub)
+		switch {
+		case err != nil:
+			return err
+		case resp != nil:
+			r.Resp = resp
+		case n < rule.replicas:
+			r.Resp = redis.NewErrorf("NOREPLICAS write was acknowledged by %d replicas, %d required", n, rule.replicas)
+		}
+		return nil
+	}
+}
+
+//WAIT会阻塞连接，每个主库使用一个独立的连接
+//先发送PUBLISH使连接的复制偏移量推进到主库当前的位置，WAIT等待的是在此之前的所有写入
+func (s *Session) dispatchWait(r *Request, addrs []string, replicas int64, timeout time.Duration) []Request {
+	sub := r.MakeSubRequest(len(addrs) * 2)
+	for i, addr := range addrs {
+		publish, wait := &sub[i*2], &sub[i*2+1]
+		publish.OpStr = "PUBLISH"
+		publish.Multi = []*redis.Resp{
+			redis.NewBulkBytes([]byte("PUBLISH")),
+			redis.NewBulkBytes(waitChannel),
+			redis.NewBulkBytes([]byte{}),
+		}
+		wait.OpStr = "WAIT"
+		wait.Multi = []*redis.Resp{
+			redis.NewBulkBytes([]byte("WAIT")),
+			redis.NewBulkBytes(strconv.AppendInt(nil, replicas, 10)),
+			redis.NewBulkBytes(strconv.AppendInt(nil, int64(timeout/time.Millisecond), 10)),
+		}
+		bc := NewBackendConn(addr, int(s.database), blockingConfig(s.config, timeout))
+		bc.PushBack(publish)
+		bc.PushBack(wait)
+		go func() {
+			r.Batch.Wait()
+			bc.Close()
+		}()
+	}
+	return sub
+}
+
+//返回所有主库确认数的最小值，后端返回错误时通过resp返回
+func waitReplies(sub []Request) (int64, *redis.Resp, error) {
+	var min int64 = -1
+	for i := range sub {
+		x := &sub[i]
+		switch {
+		case x.Err != nil:
+			return 0, nil, x.Err
+		case x.Resp == nil:
+			return 0, nil, ErrRespIsRequired
+		case x.Resp.IsError():
+			return 0, x.Resp, nil
+		case x.OpStr != "WAIT":
+			continue
+		}
+		n, err := strconv.ParseInt(string(x.Resp.Value), 10, 64)
+		if err != nil {
+			return 0, redis.NewErrorf("ERR bad wait resp '%s' from backend", x.Resp.Value), nil
+		}
+		if min < 0 || n < min {
+			min = n
+		}
+	}
+	if min < 0 {
+		min = 0
+	}
+	return min, nil, nil
+}
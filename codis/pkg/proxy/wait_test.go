@@ -0,0 +1,126 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package proxy
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+//WAIT返回固定的从库数
+type fakeWaitServer struct {
+	sync.Mutex
+	l        net.Listener
+	replicas int
+	commands []string
+}
+
+func newFakeWaitServer(replicas int) *fakeWaitServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	f := &fakeWaitServer{l: l, replicas: replicas}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(redis.NewConn(c, 1024, 1024))
+		}
+	}()
+	return f
+}
+
+func (f *fakeWaitServer) serve(c *redis.Conn) {
+	defer c.Close()
+	for {
+		multi, err := c.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		var resp = RespOK
+		var op = strings.ToUpper(string(multi[0].Value))
+		switch op {
+		case "PUBLISH":
+			resp = redis.NewInt([]byte("0"))
+		case "WAIT":
+			resp = redis.NewInt([]byte(strconv.Itoa(f.replicas)))
+		}
+		f.Lock()
+		f.commands = append(f.commands, op)
+		f.Unlock()
+		c.Encode(resp, true)
+	}
+}
+
+func (f *fakeWaitServer) takeCommands() string {
+	f.Lock()
+	defer f.Unlock()
+	commands := strings.Join(f.commands, ";")
+	f.commands = nil
+	return commands
+}
+
+func TestParseWaitRules(x *testing.T) {
+	rules, err := parseWaitRules("a:1:10ms, ab:2:1s")
+	assert.MustNoError(err)
+	assert.Must(len(rules) == 2 && string(rules[0].prefix) == "ab" && rules[0].replicas == 2)
+	for _, value := range []string{"a:1", ":1:10ms", "a:0:10ms", "a:1:0s", "a:x:10ms"} {
+		_, err := parseWaitRules(value)
+		assert.Must(err != nil)
+	}
+}
+
+func TestWait(x *testing.T) {
+	f1, f2 := newFakeWaitServer(2), newFakeWaitServer(1)
+	defer f1.l.Close()
+	defer f2.l.Close()
+
+	c := newProxyConfig()
+	router := NewRouter(c)
+	defer router.Close()
+	var slot1 = int(Hash([]byte("{a}")) % MaxSlotNum)
+	var slot2 = int(Hash([]byte("{b}")) % MaxSlotNum)
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot1, BackendAddr: f1.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot2, BackendAddr: f2.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	for _, slot := range []int{slot1, slot2} {
+		bc := router.slots[slot].backend.bc.BackendConn(0, uint(slot), false, true)
+		assert.Must(waitFor(bc.IsConnected))
+	}
+
+	s := newHelloSession("")
+
+	//没有写过时发送到所有主库
+	assert.Must(string(doTxRequest(s, router, "WAIT", "1", "0").Value) == "1")
+	assert.Must(f1.takeCommands() == "PUBLISH;WAIT" && f2.takeCommands() == "PUBLISH;WAIT")
+	assert.Must(doTxRequest(s, router, "WAIT", "1", "-1").IsError())
+
+	assert.Must(doTxRequest(s, router, "SET", "{a}k", "v").Value != nil)
+	assert.Must(string(doTxRequest(s, router, "WAIT", "2", "100").Value) == "2")
+	assert.Must(f1.takeCommands() == "SET;PUBLISH;WAIT" && f2.takeCommands() == "")
+
+	//写入之后主库发生了切换
+	assert.MustNoError(router.FillSlot(&models.Slot{Id: slot1, BackendAddr: f2.l.Addr().String(), ForwardMethod: models.ForwardSync}))
+	assert.Must(string(doTxRequest(s, router, "WAIT", "1", "100").Value) == "0")
+	assert.Must(string(doTxRequest(s, router, "WAIT", "1", "100").Value) == "1")
+	f2.takeCommands()
+
+	assert.MustNoError(WaitSetRules("{b}:2:100ms"))
+	defer WaitSetRules("")
+	resp := doTxRequest(s, router, "SET", "{b}k", "v")
+	assert.Must(resp.IsError() && strings.HasPrefix(string(resp.Value), "NOREPLICAS"))
+	assert.Must(f2.takeCommands() == "SET;PUBLISH;WAIT")
+	assert.MustNoError(WaitSetRules("{b}:1:100ms"))
+	assert.Must(string(doTxRequest(s, router, "SET", "{b}k", "v").Value) == "OK")
+	f2.takeCommands()
+	assert.Must(string(doTxRequest(s, router, "SET", "{a}k", "v").Value) == "OK")
+	assert.Must(f2.takeCommands() == "SET")
+}
@@ -0,0 +1,581 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package testcluster
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/proxy/redis"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+const (
+	MaxSlotNum = 1024
+
+	numDatabases = 16
+)
+
+//进程内的redis后端，只支持字符串类型的常用命令，以及dashboard和proxy用到的管理命令
+//slot迁移只支持同步方式(SLOTSMGRTTAGSLOT、SLOTSMGRTTAGONE)，目标必须是同一进程内的Backend
+//SLAVEOF只记录主从关系，不会复制数据
+type Backend struct {
+	mu   sync.Mutex
+	auth string
+	dbs  [numDatabases]map[string][]byte
+
+	master string
+	config map[string]string
+
+	l      net.Listener
+	addr   string
+	conns  map[net.Conn]bool
+	closed bool
+}
+
+var backends struct {
+	sync.Mutex
+	m map[string]*Backend
+}
+
+func init() {
+	backends.m = make(map[string]*Backend)
+}
+
+func lookupBackend(addr string) *Backend {
+	backends.Lock()
+	defer backends.Unlock()
+	return backends.m[addr]
+}
+
+//在127.0.0.1的随机端口上启动，auth为空时不需要认证
+func NewBackend(auth string) (*Backend, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	b := &Backend{
+		auth: auth, l: l, addr: l.Addr().String(),
+		conns: make(map[net.Conn]bool),
+		config: map[string]string{
+			"maxmemory": "0", "masterauth": "", "requirepass": auth,
+		},
+	}
+	for i := range b.dbs {
+		b.dbs[i] = make(map[string][]byte)
+	}
+
+	backends.Lock()
+	backends.m[b.addr] = b
+	backends.Unlock()
+
+	go b.serve()
+	return b, nil
+}
+
+func (b *Backend) Addr() string {
+	return b.addr
+}
+
+func (b *Backend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	for c := range b.conns {
+		c.Close()
+	}
+
+	backends.Lock()
+	delete(backends.m, b.addr)
+	backends.Unlock()
+	return b.l.Close()
+}
+
+//返回db中key的值，不存在时返回nil，用于测试中直接检查后端数据
+func (b *Backend) Get(db int, key string) []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.dbs[db][key]
+}
+
+//返回db中的key数
+func (b *Backend) DBSize(db int) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.dbs[db])
+}
+
+func (b *Backend) serve() {
+	for {
+		c, err := b.l.Accept()
+		if err != nil {
+			return
+		}
+		b.mu.Lock()
+		if b.closed {
+			b.mu.Unlock()
+			c.Close()
+			return
+		}
+		b.conns[c] = true
+		b.mu.Unlock()
+
+		go b.serveConn(c)
+	}
+}
+
+type backendSession struct {
+	db     int
+	authed bool
+}
+
+func (b *Backend) serveConn(c net.Conn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.conns, c)
+		b.mu.Unlock()
+		c.Close()
+	}()
+	conn := redis.NewConn(c, 1024*64, 1024*64)
+	session := &backendSession{authed: b.auth == ""}
+	for {
+		multi, err := conn.DecodeMultiBulk()
+		if err != nil {
+			return
+		}
+		resp := b.handle(session, multi)
+		if err := conn.Encode(resp, true); err != nil {
+			return
+		}
+	}
+}
+
+func newBulk(s string) *redis.Resp {
+	return redis.NewBulkBytes([]byte(s))
+}
+
+func newInt(n int) *redis.Resp {
+	return redis.NewInt([]byte(strconv.Itoa(n)))
+}
+
+var respOK = redis.NewString([]byte("OK"))
+
+func (b *Backend) handle(session *backendSession, multi []*redis.Resp) *redis.Resp {
+	if len(multi) == 0 {
+		return redis.NewErrorf("ERR empty command")
+	}
+	var args = make([][]byte, len(multi)-1)
+	for i, r := range multi[1:] {
+		args[i] = r.Value
+	}
+	cmd := strings.ToUpper(string(multi[0].Value))
+
+	switch cmd {
+	case "AUTH":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'auth' command")
+		}
+		if string(args[0]) != b.auth {
+			return redis.NewErrorf("ERR invalid password")
+		}
+		session.authed = true
+		return respOK
+	case "PING":
+		return redis.NewString([]byte("PONG"))
+	case "QUIT":
+		return respOK
+	}
+	if !session.authed {
+		return redis.NewErrorf("NOAUTH Authentication required.")
+	}
+
+	switch cmd {
+	case "SLOTSMGRTTAGSLOT":
+		return b.migrateTagSlot(session.db, args)
+	case "SLOTSMGRTTAGONE":
+		return b.migrateTagOne(session.db, args)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	db := b.dbs[session.db]
+
+	switch cmd {
+	case "SELECT":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'select' command")
+		}
+		n, err := strconv.Atoi(string(args[0]))
+		if err != nil || n < 0 || n >= numDatabases {
+			return redis.NewErrorf("ERR invalid DB index")
+		}
+		session.db = n
+		return respOK
+	case "ECHO":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'echo' command")
+		}
+		return redis.NewBulkBytes(args[0])
+	case "GET":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'get' command")
+		}
+		return redis.NewBulkBytes(db[string(args[0])])
+	case "SET":
+		if len(args) < 2 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'set' command")
+		}
+		db[string(args[0])] = append([]byte(nil), args[1]...)
+		return respOK
+	case "SETNX":
+		if len(args) != 2 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'setnx' command")
+		}
+		if _, ok := db[string(args[0])]; ok {
+			return newInt(0)
+		}
+		db[string(args[0])] = append([]byte(nil), args[1]...)
+		return newInt(1)
+	case "GETSET":
+		if len(args) != 2 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'getset' command")
+		}
+		old := db[string(args[0])]
+		db[string(args[0])] = append([]byte(nil), args[1]...)
+		return redis.NewBulkBytes(old)
+	case "MGET":
+		var array = make([]*redis.Resp, len(args))
+		for i, key := range args {
+			array[i] = redis.NewBulkBytes(db[string(key)])
+		}
+		return redis.NewArray(array)
+	case "MSET":
+		if len(args) == 0 || len(args)%2 != 0 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'mset' command")
+		}
+		for i := 0; i < len(args); i += 2 {
+			db[string(args[i])] = append([]byte(nil), args[i+1]...)
+		}
+		return respOK
+	case "DEL", "UNLINK":
+		var n int
+		for _, key := range args {
+			if _, ok := db[string(key)]; ok {
+				delete(db, string(key))
+				n++
+			}
+		}
+		return newInt(n)
+	case "EXISTS":
+		var n int
+		for _, key := range args {
+			if _, ok := db[string(key)]; ok {
+				n++
+			}
+		}
+		return newInt(n)
+	case "INCR", "DECR", "INCRBY", "DECRBY":
+		return b.incr(db, cmd, args)
+	case "APPEND":
+		if len(args) != 2 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'append' command")
+		}
+		v := append(db[string(args[0])], args[1]...)
+		db[string(args[0])] = v
+		return newInt(len(v))
+	case "STRLEN":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'strlen' command")
+		}
+		return newInt(len(db[string(args[0])]))
+	case "TYPE":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'type' command")
+		}
+		if _, ok := db[string(args[0])]; ok {
+			return redis.NewString([]byte("string"))
+		}
+		return redis.NewString([]byte("none"))
+	case "TTL", "PTTL":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))
+		}
+		if _, ok := db[string(args[0])]; ok {
+			return newInt(-1)
+		}
+		return newInt(-2)
+	case "KEYS":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'keys' command")
+		}
+		var array []*redis.Resp
+		for _, key := range sortedKeys(db) {
+			if ok, _ := filepath.Match(string(args[0]), key); ok {
+				array = append(array, newBulk(key))
+			}
+		}
+		return redis.NewArray(array)
+	case "DBSIZE":
+		return newInt(len(db))
+	case "FLUSHDB":
+		b.dbs[session.db] = make(map[string][]byte)
+		return respOK
+	case "FLUSHALL":
+		for i := range b.dbs {
+			b.dbs[i] = make(map[string][]byte)
+		}
+		return respOK
+	case "INFO":
+		return redis.NewBulkBytes(b.info())
+	case "ROLE":
+		if b.master != "" {
+			host, port, _ := net.SplitHostPort(b.master)
+			return redis.NewArray([]*redis.Resp{
+				newBulk("slave"), newBulk(host), newInt(atoi(port)), newBulk("connected"), newInt(0),
+			})
+		}
+		return redis.NewArray([]*redis.Resp{newBulk("master"), newInt(0), redis.NewArray(nil)})
+	case "CONFIG":
+		return b.configCommand(args)
+	case "SLAVEOF", "REPLICAOF":
+		if len(args) != 2 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'slaveof' command")
+		}
+		if strings.ToUpper(string(args[0])) == "NO" && strings.ToUpper(string(args[1])) == "ONE" {
+			b.master = ""
+		} else {
+			b.master = net.JoinHostPort(string(args[0]), string(args[1]))
+		}
+		return respOK
+	case "CLIENT", "SLOWLOG":
+		return respOK
+	case "SLOTSINFO":
+		var slots = make(map[int]int)
+		for key := range db {
+			slots[hashSlot(key)]++
+		}
+		var ids []int
+		for sid := range slots {
+			ids = append(ids, sid)
+		}
+		sort.Ints(ids)
+		var array []*redis.Resp
+		for _, sid := range ids {
+			array = append(array, redis.NewArray([]*redis.Resp{newInt(sid), newInt(slots[sid])}))
+		}
+		return redis.NewArray(array)
+	}
+	return redis.NewErrorf("ERR unknown command '%s'", strings.ToLower(cmd))
+}
+
+func (b *Backend) incr(db map[string][]byte, cmd string, args [][]byte) *redis.Resp {
+	var delta int64 = 1
+	switch cmd {
+	case "INCR", "DECR":
+		if len(args) != 1 {
+			return redis.NewErrorf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))
+		}
+	default:
+		if len(args) != 2 {
+			return redis.NewErrorf("ERR wrong number of arguments for '%s' command", strings.ToLower(cmd))
+		}
+		n, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return redis.NewErrorf("ERR value is not an integer or out of range")
+		}
+		delta = n
+	}
+	if cmd == "DECR" || cmd == "DECRBY" {
+		delta = -delta
+	}
+	var v int64
+	if p, ok := db[string(args[0])]; ok {
+		n, err := strconv.ParseInt(string(p), 10, 64)
+		if err != nil {
+			return redis.NewErrorf("ERR value is not an integer or out of range")
+		}
+		v = n
+	}
+	v += delta
+	db[string(args[0])] = []byte(strconv.FormatInt(v, 10))
+	return redis.NewInt([]byte(strconv.FormatInt(v, 10)))
+}
+
+//调用者需要持有b.mu
+func (b *Backend) info() []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Server\r\n")
+	fmt.Fprintf(&buf, "redis_version:testcluster\r\n")
+	fmt.Fprintf(&buf, "redis_mode:standalone\r\n")
+	fmt.Fprintf(&buf, "tcp_port:%s\r\n", b.addr[strings.LastIndex(b.addr, ":")+1:])
+	fmt.Fprintf(&buf, "\r\n# Memory\r\n")
+	var used int
+	for _, db := range b.dbs {
+		for k, v := range db {
+			used += len(k) + len(v)
+		}
+	}
+	fmt.Fprintf(&buf, "used_memory:%d\r\n", used)
+	fmt.Fprintf(&buf, "maxmemory:%s\r\n", b.config["maxmemory"])
+	fmt.Fprintf(&buf, "\r\n# Replication\r\n")
+	if b.master != "" {
+		host, port, _ := net.SplitHostPort(b.master)
+		fmt.Fprintf(&buf, "role:slave\r\n")
+		fmt.Fprintf(&buf, "master_host:%s\r\n", host)
+		fmt.Fprintf(&buf, "master_port:%s\r\n", port)
+		fmt.Fprintf(&buf, "master_link_status:up\r\n")
+	} else {
+		fmt.Fprintf(&buf, "role:master\r\n")
+		fmt.Fprintf(&buf, "connected_slaves:0\r\n")
+	}
+	fmt.Fprintf(&buf, "\r\n# Keyspace\r\n")
+	for i, db := range b.dbs {
+		if len(db) != 0 {
+			fmt.Fprintf(&buf, "db%d:keys=%d,expires=0,avg_ttl=0\r\n", i, len(db))
+		}
+	}
+	return buf.Bytes()
+}
+
+//调用者需要持有b.mu，CONFIG REWRITE和RESETSTAT直接返回成功
+func (b *Backend) configCommand(args [][]byte) *redis.Resp {
+	if len(args) == 0 {
+		return redis.NewErrorf("ERR wrong number of arguments for 'config' command")
+	}
+	switch strings.ToUpper(string(args[0])) {
+	case "GET":
+		if len(args) != 2 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'config get' command")
+		}
+		var array []*redis.Resp
+		var keys []string
+		for k := range b.config {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			if ok, _ := filepath.Match(strings.ToLower(string(args[1])), k); ok {
+				array = append(array, newBulk(k), newBulk(b.config[k]))
+			}
+		}
+		return redis.NewArray(array)
+	case "SET":
+		if len(args) != 3 {
+			return redis.NewErrorf("ERR wrong number of arguments for 'config set' command")
+		}
+		b.config[strings.ToLower(string(args[1]))] = string(args[2])
+		return respOK
+	case "REWRITE", "RESETSTAT":
+		return respOK
+	}
+	return redis.NewErrorf("ERR unknown subcommand '%s'", args[0])
+}
+
+//SLOTSMGRTTAGSLOT host port timeout slot，每次迁移slot中的一个key，返回[迁移的key数, slot中剩余的key数]
+func (b *Backend) migrateTagSlot(database int, args [][]byte) *redis.Resp {
+	if len(args) != 4 {
+		return redis.NewErrorf("ERR wrong number of arguments for 'slotsmgrttagslot' command")
+	}
+	sid, err := strconv.Atoi(string(args[3]))
+	if err != nil || sid < 0 || sid >= MaxSlotNum {
+		return redis.NewErrorf("ERR invalid slot number")
+	}
+	t, err := b.lockTarget(net.JoinHostPort(string(args[0]), string(args[1])))
+	if err != nil {
+		return redis.NewErrorf("ERR %s", err)
+	}
+	defer b.unlockTarget(t)
+
+	var keys []string
+	for key := range b.dbs[database] {
+		if hashSlot(key) == sid {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return redis.NewArray([]*redis.Resp{newInt(0), newInt(0)})
+	}
+	sort.Strings(keys)
+	b.moveKey(t, database, keys[0])
+	return redis.NewArray([]*redis.Resp{newInt(1), newInt(len(keys) - 1)})
+}
+
+//SLOTSMGRTTAGONE host port timeout key，返回迁移的key数
+func (b *Backend) migrateTagOne(database int, args [][]byte) *redis.Resp {
+	if len(args) != 4 {
+		return redis.NewErrorf("ERR wrong number of arguments for 'slotsmgrttagone' command")
+	}
+	t, err := b.lockTarget(net.JoinHostPort(string(args[0]), string(args[1])))
+	if err != nil {
+		return redis.NewErrorf("ERR %s", err)
+	}
+	defer b.unlockTarget(t)
+
+	key := string(args[3])
+	if _, ok := b.dbs[database][key]; !ok {
+		return newInt(0)
+	}
+	b.moveKey(t, database, key)
+	return newInt(1)
+}
+
+//按地址顺序同时锁住源和目标，避免两个Backend互相迁移时死锁
+func (b *Backend) lockTarget(target string) (*Backend, error) {
+	if target == b.addr {
+		return nil, errors.Errorf("target is myself")
+	}
+	t := lookupBackend(target)
+	if t == nil {
+		return nil, errors.Errorf("target %s is not a testcluster backend", target)
+	}
+	if b.addr < t.addr {
+		b.mu.Lock()
+		t.mu.Lock()
+	} else {
+		t.mu.Lock()
+		b.mu.Lock()
+	}
+	return t, nil
+}
+
+func (b *Backend) unlockTarget(t *Backend) {
+	t.mu.Unlock()
+	b.mu.Unlock()
+}
+
+//调用者需要持有b和t的锁
+func (b *Backend) moveKey(t *Backend, database int, key string) {
+	t.dbs[database][key] = b.dbs[database][key]
+	delete(b.dbs[database], key)
+	log.Debugf("testcluster: migrate key %s from %s to %s, db-%d", key, b.addr, t.addr, database)
+}
+
+func hashSlot(key string) int {
+	return int(proxy.Hash([]byte(key)) % MaxSlotNum)
+}
+
+func sortedKeys(db map[string][]byte) []string {
+	var keys = make([]string, 0, len(db))
+	for key := range db {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
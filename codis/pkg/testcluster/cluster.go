@@ -0,0 +1,198 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package testcluster
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/models/fs"
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/topom"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type Options struct {
+	ProductName string
+	ProductAuth string
+
+	//group数，每个group有1个master和Replicas个slave，slot平均分配到各个group
+	Groups   int
+	Replicas int
+	Proxies  int
+
+	//可以在创建前修改dashboard和proxy的配置，地址相关的配置会被覆盖
+	TopomConfig func(c *topom.Config)
+	ProxyConfig func(c *proxy.Config)
+}
+
+//进程内的完整集群：dashboard使用临时目录作为coordinator，proxy和后端都监听127.0.0.1的随机端口
+type Cluster struct {
+	Topom   *topom.Topom
+	Proxies []*proxy.Proxy
+	//Groups[i]为group-[i+1]的后端，第一个为master
+	Groups [][]*Backend
+
+	options Options
+	client  *fsclient.Client
+	dir     string
+}
+
+func New(o *Options) (*Cluster, error) {
+	c := &Cluster{options: *o}
+	if c.options.ProductName == "" {
+		c.options.ProductName = "testcluster"
+	}
+	if c.options.Groups <= 0 {
+		c.options.Groups = 1
+	}
+	if c.options.Proxies <= 0 {
+		c.options.Proxies = 1
+	}
+	if err := c.setup(); err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cluster) setup() error {
+	o := &c.options
+	dir, err := ioutil.TempDir("", "testcluster")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	c.dir = dir
+	if c.client, err = fsclient.New(dir); err != nil {
+		return err
+	}
+
+	config := topom.NewDefaultConfig()
+	if o.TopomConfig != nil {
+		o.TopomConfig(config)
+	}
+	config.AdminAddr = "127.0.0.1:0"
+	config.ProductName = o.ProductName
+	config.ProductAuth = o.ProductAuth
+	if c.Topom, err = topom.New(c.client, config); err != nil {
+		return err
+	}
+	if err := c.Topom.Start(true); err != nil {
+		return err
+	}
+
+	var slots []*models.SlotMapping
+	for i := 0; i < o.Groups; i++ {
+		gid := i + 1
+		if err := c.Topom.CreateGroup(gid); err != nil {
+			return err
+		}
+		var group []*Backend
+		for j := 0; j <= o.Replicas; j++ {
+			b, err := NewBackend(o.ProductAuth)
+			if err != nil {
+				return err
+			}
+			group = append(group, b)
+			if err := c.Topom.GroupAddServer(gid, "", b.Addr()); err != nil {
+				return err
+			}
+			if j != 0 {
+				b.mu.Lock()
+				b.master = group[0].Addr()
+				b.mu.Unlock()
+			}
+		}
+		c.Groups = append(c.Groups, group)
+
+		for sid := MaxSlotNum * i / o.Groups; sid < MaxSlotNum*(i+1)/o.Groups; sid++ {
+			slots = append(slots, &models.SlotMapping{Id: sid, GroupId: gid})
+		}
+	}
+	if err := c.Topom.SlotsAssignGroup(slots); err != nil {
+		return err
+	}
+
+	for i := 0; i < o.Proxies; i++ {
+		config := proxy.NewDefaultConfig()
+		if o.ProxyConfig != nil {
+			o.ProxyConfig(config)
+		}
+		config.ProxyAddr = "127.0.0.1:0"
+		config.AdminAddr = "127.0.0.1:0"
+		config.ProductName = o.ProductName
+		config.ProductAuth = o.ProductAuth
+		config.ProxyHeapPlaceholder = 0
+		config.ProxyMaxOffheapBytes = 0
+		p, err := proxy.New(config)
+		if err != nil {
+			return err
+		}
+		c.Proxies = append(c.Proxies, p)
+		if err := c.Topom.CreateProxy(p.Model().AdminAddr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//第i个proxy的客户端地址
+func (c *Cluster) ProxyAddr(i int) string {
+	return c.Proxies[i].Model().ProxyAddr
+}
+
+func (c *Cluster) TopomClient() *topom.ApiClient {
+	x := topom.NewApiClient(c.Topom.Model().AdminAddr)
+	x.SetXAuth(c.options.ProductName)
+	return x
+}
+
+func (c *Cluster) ProxyClient(i int) *proxy.ApiClient {
+	m := c.Proxies[i].Model()
+	x := proxy.NewApiClient(m.AdminAddr)
+	x.SetXAuth(c.options.ProductName, c.options.ProductAuth, m.Token)
+	return x
+}
+
+//返回key所在slot当前所属group的master
+func (c *Cluster) Master(key string) (*Backend, error) {
+	sid := hashSlot(key)
+	slots, err := c.Topom.Slots()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range slots {
+		if s.Id == sid && s.BackendAddr != "" {
+			if b := lookupBackend(s.BackendAddr); b != nil {
+				return b, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("slot-[%d] of key %s is offline", sid, key)
+}
+
+//依次关闭proxy、dashboard和后端，并删除coordinator的临时目录
+func (c *Cluster) Close() {
+	for _, p := range c.Proxies {
+		p.Close()
+	}
+	if c.Topom != nil {
+		if err := c.Topom.Close(); err != nil {
+			log.WarnErrorf(err, "testcluster: close topom failed")
+		}
+	}
+	if c.client != nil {
+		c.client.Close()
+	}
+	for _, group := range c.Groups {
+		for _, b := range group {
+			b.Close()
+		}
+	}
+	if c.dir != "" {
+		os.RemoveAll(c.dir)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package testcluster
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/redis"
+)
+
+func init() {
+	log.SetLevel(log.LevelError)
+}
+
+func TestCluster(x *testing.T) {
+	c, err := New(&Options{ProductAuth: "testcluster_auth", Groups: 2, Replicas: 1})
+	assert.MustNoError(err)
+	defer c.Close()
+
+	assert.Must(len(c.Groups) == 2 && len(c.Groups[0]) == 2)
+
+	client, err := redis.NewClient(c.ProxyAddr(0), "", time.Second*5)
+	assert.MustNoError(err)
+	defer client.Close()
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		_, err := client.Do("SET", key, i)
+		assert.MustNoError(err)
+		b, err := c.Master(key)
+		assert.MustNoError(err)
+		assert.Must(string(b.Get(0, key)) == fmt.Sprint(i))
+	}
+	assert.Must(c.Groups[0][0].DBSize(0)+c.Groups[1][0].DBSize(0) == 100)
+
+	v, err := client.Do("GET", "key-1")
+	assert.MustNoError(err)
+	assert.Must(string(v.([]byte)) == "1")
+}
+
+func TestClusterMigrate(x *testing.T) {
+	c, err := New(&Options{Groups: 2})
+	assert.MustNoError(err)
+	defer c.Close()
+
+	client, err := redis.NewClient(c.ProxyAddr(0), "", time.Second*5)
+	assert.MustNoError(err)
+	defer client.Close()
+
+	const key = "migrate"
+	_, err = client.Do("SET", key, "value")
+	assert.MustNoError(err)
+	from, err := c.Master(key)
+	assert.MustNoError(err)
+
+	sid, gid := hashSlot(key), 1
+	if from == c.Groups[0][0] {
+		gid = 2
+	}
+	assert.MustNoError(c.Topom.SlotCreateAction(sid, gid))
+	for i := 0; ; i++ {
+		b, err := c.Master(key)
+		assert.MustNoError(err)
+		if b != from {
+			break
+		}
+		assert.Must(i < 100)
+		time.Sleep(time.Millisecond * 100)
+	}
+	to := c.Groups[gid-1][0]
+	assert.Must(string(to.Get(0, key)) == "value" && from.Get(0, key) == nil)
+
+	v, err := client.Do("GET", key)
+	assert.MustNoError(err)
+	assert.Must(string(v.([]byte)) == "value")
+}
@@ -9,9 +9,11 @@ import (
 	"github.com/BurntSushi/toml"
 
 	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils"
 	"github.com/CodisLabs/codis/pkg/utils/bytesize"
 	"github.com/CodisLabs/codis/pkg/utils/errors"
 	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/redis"
 	"github.com/CodisLabs/codis/pkg/utils/timesize"
 )
 
@@ -22,6 +24,10 @@ const DefaultConfig = `
 #                                                #
 ##################################################
 
+# Values can reference environment variables as ${NAME} or ${NAME:-default}, and
+# a line like: include "common.toml" inlines another file (relative to this one).
+# Files using them are never rewritten by config set commands.
+
 # Set runtime.GOMAXPROCS to N, default is 1.
 ncpu = 1
 
@@ -43,13 +49,36 @@ mysql_username = ""
 mysql_password = ""
 mysql_database = ""
 
+# Set coordinator to store metadata instead of mysql above, only accept "etcdv3", "etcd", "zookeeper" & "filesystem", empty to use mysql.
+#   1. for zookeeper/etcd/etcdv3, coordinator_auth "user:password" is accepted.
+#   2. with "etcdv3", the lock of dashboard is held by a lease and released automatically after the dashboard is gone.
+coordinator_name = ""
+coordinator_addr = ""
+coordinator_auth = ""
+
 # Set Codis Product Name/Auth.
 product_name = "codis-demo"
 product_auth = ""
 
+# Set the function mapping keys to slots, must be the same as slot_hash & hash_tag of all proxies.
+# Slots can be migrated only with "crc32", which is used by codis-server.
+slot_hash = "crc32"
+hash_tag = "{}"
+
 # Set bind address for admin(rpc), tcp only.
 admin_addr = "0.0.0.0:18080"
 
+# Guard mutating admin apis (non-GET /api/*) against runaway automation.
+#   1. admin_rate_limit is max requests per second of each client (by remote ip), 0 to disable.
+#   2. admin_max_inflight is max number of mutating requests processed concurrently, 0 to disable.
+#   3. requests with header "Idempotency-Key" are executed once, retries within admin_idempotency_ttl get the same response.
+#   4. X-Real-IP/X-Forwarded-For are trusted only from admin_trusted_proxies (comma separated ip or cidr, such as "10.0.0.1,192.168.0.0/16").
+admin_rate_limit = 0.0
+admin_rate_burst = 20
+admin_max_inflight = 0
+admin_idempotency_ttl = "10m"
+admin_trusted_proxies = ""
+
 # Set influxdb server (such as http://localhost:8086), dashboard will report metrics to influxdb.
 # Dashboard use another two dastbases to record more cmd delay info, database suffix is "_extend_1" and "_extend_2"
 metrics_report_influxdb_server = ""
@@ -58,6 +87,30 @@ metrics_report_influxdb_username = ""
 metrics_report_influxdb_password = ""
 metrics_report_influxdb_database = ""
 
+# Select backend of the reporting above:
+#   1. "influxdb" reports to influxdb 1.x with username & password.
+#   2. "influxdb2" reports to influxdb 2.x with token, written into metrics_report_influxdb_bucket of org (extend buckets have the same suffixes).
+#   3. "otlp" pushes aggregated cluster metrics to an opentelemetry collector by OTLP/HTTP json (such as http://localhost:4318/v1/metrics),
+#      metrics_report_otlp_headers are extra request headers, such as "Authorization=Bearer xxx,X-Scope-OrgID=codis".
+metrics_report_backend = "influxdb"
+metrics_report_influxdb_token = ""
+metrics_report_influxdb_org = ""
+metrics_report_influxdb_bucket = ""
+metrics_report_otlp_url = ""
+metrics_report_otlp_period = "15s"
+metrics_report_otlp_headers = ""
+
+# Set mysql reporting of proxy stats (0 to disable), dashboard will write ops & cmd delay stats of each proxy
+# into tables "codis_proxy_stats" and "codis_proxy_cmd_stats" of mysql_database, rows older than retention are purged.
+metrics_report_mysql_period = "0s"
+metrics_report_mysql_retention = "168h"
+
+# Push aggregated cluster metrics to a prometheus remote-write endpoint (such as http://localhost:9090/api/v1/write), empty to disable.
+metrics_report_remote_write_url = ""
+metrics_report_remote_write_period = "15s"
+metrics_report_remote_write_username = ""
+metrics_report_remote_write_password = ""
+
 # Set arguments for data migration (only accept 'sync' & 'semi-async').
 migration_method = "sync"
 migration_parallel_slots = 100
@@ -66,6 +119,48 @@ migration_async_maxbytes = "32mb"
 migration_async_numkeys = 500
 migration_timeout = "30s"
 
+# Number of keys sampled per slot to verify migration with DUMP checksums, 0 to disable.
+migration_verify_keys = 0
+
+# Estimated migration throughput in bytes per second, used to project the duration of migration plans.
+migration_estimate_rate = "16mb"
+
+# Max migration bandwidth in bytes per second shared by all migrating slots, estimated from the average key size
+# of the source group, 0 to disable.
+migration_max_bandwidth = "0"
+
+# Time windows (local time) in which slots can be migrated, such as "01:00-06:00,22:00-23:30". Migrations are paused
+# outside the windows and resumed automatically. A window like "22:00-06:00" crosses midnight. Empty to allow any time.
+migration_windows = ""
+
+# Period of collecting per-slot ops/bytes from proxies for load-based rebalance, 0 to disable.
+slot_heat_period = "1m"
+# Number of collected samples kept as recent slot heat history.
+slot_heat_history = 60
+
+# Register online proxies to consul agent (such as http://localhost:8500) as service "codis-{PRODUCT_NAME}",
+# so clients can discover proxies by consul api or dns srv records. (empty to disable)
+discovery_consul_addr = ""
+discovery_consul_token = ""
+discovery_consul_period = "10s"
+
+# Generate cluster reports (capacity, top commands, slow requests, migration history), should be "daily", "weekly" or empty to disable.
+# Reports are generated at report_hour every day (daily) or every monday (weekly), then posted as json to report_webhook_url
+# and/or mailed as html to report_smtp_to (comma separated) via report_smtp_addr.
+report_period = ""
+report_hour = 8
+report_webhook_url = ""
+report_smtp_addr = ""
+report_smtp_username = ""
+report_smtp_password = ""
+report_smtp_from = ""
+report_smtp_to = ""
+
+# Allow chaos operations (simulate master down, blackhole proxy backends, delay sentinel notifications) for staging tests.
+# Every fault is recovered automatically after its duration, which can't exceed chaos_max_duration.
+chaos_enabled = false
+chaos_max_duration = "10m"
+
 # Set configs for redis sentinel.
 sentinel_client_timeout = "10s"
 sentinel_quorum = 2
@@ -75,16 +170,36 @@ sentinel_failover_timeout = "5m"
 sentinel_notification_script = ""
 sentinel_client_reconfig_script = ""
 
+# Set auth & tls for connecting to redis sentinels.
+#   1. sentinel_username is only required by ACL users (redis 6.0+), leave empty to use AUTH <password>.
+#   2. sentinel_tls_ca_file is the PEM encoded CA to verify sentinels, leave empty to use system roots.
+sentinel_username = ""
+sentinel_password = ""
+sentinel_tls = false
+sentinel_tls_skip_verify = false
+sentinel_tls_ca_file = ""
+
 # master mysql to reload 
 master_product = ""
 master_mysql_addr = ""
 master_mysql_username = ""
 master_mysql_password = ""
 master_mysql_database = ""
+# Set false to stop reloading slots from master product implicitly, use api "/api/topom/master/sync" instead.
+master_auto_sync = true
+
+# Manage more products in this dashboard, sharing admin_addr and coordinator with product_name above.
+# Admin api of a product is served under "/api/topom/{product}/..." and "/topom/{product}/...",
+# other options are the same as product_name above.
+# [[products]]
+# product_name = "codis-demo2"
+# product_auth = ""
 `
 
 type Config struct {
 	ConfigName string `toml:"-" json:"config_name"`
+	//配置文件使用了include或环境变量，不能被改写
+	ConfigTemplated bool `toml:"-" json:"config_templated,omitempty"`
 	
 	CoordinatorName string `toml:"coordinator_name" json:"-"`
 	CoordinatorAddr string `toml:"coordinator_addr" json:"-"`
@@ -94,14 +209,37 @@ type Config struct {
 
 	HostAdmin string `toml:"-" json:"-"`
 
+	AdminRateLimit      float64           `toml:"admin_rate_limit" json:"admin_rate_limit"`
+	AdminRateBurst      int               `toml:"admin_rate_burst" json:"admin_rate_burst"`
+	AdminMaxInflight    int               `toml:"admin_max_inflight" json:"admin_max_inflight"`
+	AdminIdempotencyTTL timesize.Duration `toml:"admin_idempotency_ttl" json:"admin_idempotency_ttl"`
+	AdminTrustedProxies string            `toml:"admin_trusted_proxies" json:"admin_trusted_proxies"`
+
 	ProductName string `toml:"product_name" json:"product_name"`
 	ProductAuth string `toml:"product_auth" json:"-"`
 
+	SlotHash string `toml:"slot_hash" json:"slot_hash"`
+	HashTag  string `toml:"hash_tag" json:"hash_tag"`
+
 	MetricsReportInfluxdbServer   string            `toml:"metrics_report_influxdb_server" json:"metrics_report_influxdb_server"`
 	MetricsReportInfluxdbPeriod   timesize.Duration `toml:"metrics_report_influxdb_period" json:"metrics_report_influxdb_period"`
 	MetricsReportInfluxdbUsername string            `toml:"metrics_report_influxdb_username" json:"metrics_report_influxdb_username"`
 	MetricsReportInfluxdbPassword string            `toml:"metrics_report_influxdb_password" json:"-"`
 	MetricsReportInfluxdbDatabase string            `toml:"metrics_report_influxdb_database" json:"metrics_report_influxdb_database"`
+	MetricsReportBackend          string            `toml:"metrics_report_backend" json:"metrics_report_backend"`
+	MetricsReportInfluxdbToken    string            `toml:"metrics_report_influxdb_token" json:"-"`
+	MetricsReportInfluxdbOrg      string            `toml:"metrics_report_influxdb_org" json:"metrics_report_influxdb_org"`
+	MetricsReportInfluxdbBucket   string            `toml:"metrics_report_influxdb_bucket" json:"metrics_report_influxdb_bucket"`
+	MetricsReportOtlpUrl          string            `toml:"metrics_report_otlp_url" json:"metrics_report_otlp_url"`
+	MetricsReportOtlpPeriod       timesize.Duration `toml:"metrics_report_otlp_period" json:"metrics_report_otlp_period"`
+	MetricsReportOtlpHeaders      string            `toml:"metrics_report_otlp_headers" json:"-"`
+	MetricsReportMysqlPeriod      timesize.Duration `toml:"metrics_report_mysql_period" json:"metrics_report_mysql_period"`
+	MetricsReportMysqlRetention   timesize.Duration `toml:"metrics_report_mysql_retention" json:"metrics_report_mysql_retention"`
+
+	MetricsReportRemoteWriteUrl      string            `toml:"metrics_report_remote_write_url" json:"metrics_report_remote_write_url"`
+	MetricsReportRemoteWritePeriod   timesize.Duration `toml:"metrics_report_remote_write_period" json:"metrics_report_remote_write_period"`
+	MetricsReportRemoteWriteUsername string            `toml:"metrics_report_remote_write_username" json:"metrics_report_remote_write_username"`
+	MetricsReportRemoteWritePassword string            `toml:"metrics_report_remote_write_password" json:"-"`
 
 	MigrationMethod        string            `toml:"migration_method" json:"migration_method"`
 	MigrationParallelSlots int               `toml:"migration_parallel_slots" json:"migration_parallel_slots"`
@@ -109,6 +247,29 @@ type Config struct {
 	MigrationAsyncMaxBytes bytesize.Int64    `toml:"migration_async_maxbytes" json:"migration_async_maxbytes"`
 	MigrationAsyncNumKeys  int               `toml:"migration_async_numkeys" json:"migration_async_numkeys"`
 	MigrationTimeout       timesize.Duration `toml:"migration_timeout" json:"migration_timeout"`
+	MigrationVerifyKeys    int               `toml:"migration_verify_keys" json:"migration_verify_keys"`
+	MigrationEstimateRate  bytesize.Int64    `toml:"migration_estimate_rate" json:"migration_estimate_rate"`
+	MigrationMaxBandwidth  bytesize.Int64    `toml:"migration_max_bandwidth" json:"migration_max_bandwidth"`
+	MigrationWindows       string            `toml:"migration_windows" json:"migration_windows"`
+
+	SlotHeatPeriod  timesize.Duration `toml:"slot_heat_period" json:"slot_heat_period"`
+	SlotHeatHistory int               `toml:"slot_heat_history" json:"slot_heat_history"`
+
+	DiscoveryConsulAddr   string            `toml:"discovery_consul_addr" json:"discovery_consul_addr"`
+	DiscoveryConsulToken  string            `toml:"discovery_consul_token" json:"-"`
+	DiscoveryConsulPeriod timesize.Duration `toml:"discovery_consul_period" json:"discovery_consul_period"`
+
+	ReportPeriod       string `toml:"report_period" json:"report_period"`
+	ReportHour         int    `toml:"report_hour" json:"report_hour"`
+	ReportWebhookUrl   string `toml:"report_webhook_url" json:"report_webhook_url"`
+	ReportSmtpAddr     string `toml:"report_smtp_addr" json:"report_smtp_addr"`
+	ReportSmtpUsername string `toml:"report_smtp_username" json:"report_smtp_username"`
+	ReportSmtpPassword string `toml:"report_smtp_password" json:"-"`
+	ReportSmtpFrom     string `toml:"report_smtp_from" json:"report_smtp_from"`
+	ReportSmtpTo       string `toml:"report_smtp_to" json:"report_smtp_to"`
+
+	ChaosEnabled     bool              `toml:"chaos_enabled" json:"chaos_enabled"`
+	ChaosMaxDuration timesize.Duration `toml:"chaos_max_duration" json:"chaos_max_duration"`
 
 	SentinelClientTimeout        timesize.Duration `toml:"sentinel_client_timeout" json:"sentinel_client_timeout"`
 	SentinelQuorum               int               `toml:"sentinel_quorum" json:"sentinel_quorum"`
@@ -118,6 +279,12 @@ type Config struct {
 	SentinelNotificationScript   string            `toml:"sentinel_notification_script" json:"sentinel_notification_script"`
 	SentinelClientReconfigScript string            `toml:"sentinel_client_reconfig_script" json:"sentinel_client_reconfig_script"`
 
+	SentinelUsername      string `toml:"sentinel_username" json:"sentinel_username"`
+	SentinelPassword      string `toml:"sentinel_password" json:"-"`
+	SentinelTLS           bool   `toml:"sentinel_tls" json:"sentinel_tls"`
+	SentinelTLSSkipVerify bool   `toml:"sentinel_tls_skip_verify" json:"sentinel_tls_skip_verify"`
+	SentinelTLSCAFile     string `toml:"sentinel_tls_ca_file" json:"sentinel_tls_ca_file"`
+
 	Ncpu           int     `toml:"ncpu"`
 	Log            string  `toml:"log"`
 	ExpireLogDays  int     `toml:"expire_log_days"`
@@ -134,6 +301,15 @@ type Config struct {
 	MasterMysqlUsername 	string 	`toml:"master_mysql_username" json:"master_mysql_username"`
 	MasterMysqlPassword 	string 	`toml:"master_mysql_password" json:"-"`
 	MasterMysqlDatabase 	string 	`toml:"master_mysql_database" json:"master_mysql_database"`
+	MasterAutoSync 		bool 	`toml:"master_auto_sync" json:"master_auto_sync"`
+
+	Products []*ProductConfig `toml:"products" json:"products,omitempty"`
+}
+
+//同一个dashboard管理的其他product，没有列出的配置与主product相同
+type ProductConfig struct {
+	ProductName string `toml:"product_name" json:"product_name"`
+	ProductAuth string `toml:"product_auth" json:"-"`
 }
 
 func NewDefaultConfig() *Config {
@@ -148,10 +324,14 @@ func NewDefaultConfig() *Config {
 }
 
 func (c *Config) LoadFromFile(path string) error {
-	_, err := toml.DecodeFile(path, c)
+	data, templated, err := utils.ReadConfigFile(path)
 	if err != nil {
+		return err
+	}
+	if _, err := toml.Decode(data, c); err != nil {
 		return errors.Trace(err)
 	}
+	c.ConfigTemplated = templated
 	return c.Validate()
 }
 
@@ -170,6 +350,9 @@ func (c *Config) Validate() error {
 	if c.ProductName == "" {
 		return errors.New("invalid product_name")
 	}
+	if err := models.ValidateSlotHash(c.SlotHash, c.HashTag); err != nil {
+		return err
+	}
 	if _, ok := models.ParseForwardMethod(c.MigrationMethod); !ok {
 		return errors.New("invalid migration_method")
 	}
@@ -188,6 +371,81 @@ func (c *Config) Validate() error {
 	if c.MigrationTimeout <= 0 {
 		return errors.New("invalid migration_timeout")
 	}
+	if c.MigrationVerifyKeys < 0 {
+		return errors.New("invalid migration_verify_keys")
+	}
+	if c.MigrationEstimateRate <= 0 {
+		return errors.New("invalid migration_estimate_rate")
+	}
+	if c.MigrationMaxBandwidth < 0 {
+		return errors.New("invalid migration_max_bandwidth")
+	}
+	if _, err := parseMigrationWindows(c.MigrationWindows); err != nil {
+		return errors.New("invalid migration_windows")
+	}
+	if c.AdminRateLimit < 0 {
+		return errors.New("invalid admin_rate_limit")
+	}
+	if c.AdminRateLimit > 0 && c.AdminRateBurst <= 0 {
+		return errors.New("invalid admin_rate_burst")
+	}
+	if c.AdminMaxInflight < 0 {
+		return errors.New("invalid admin_max_inflight")
+	}
+	if c.AdminIdempotencyTTL < 0 {
+		return errors.New("invalid admin_idempotency_ttl")
+	}
+	if _, err := parseTrustedProxies(c.AdminTrustedProxies); err != nil {
+		return errors.New("invalid admin_trusted_proxies")
+	}
+	if c.MetricsReportMysqlPeriod < 0 {
+		return errors.New("invalid metrics_report_mysql_period")
+	}
+	if c.MetricsReportMysqlRetention < 0 {
+		return errors.New("invalid metrics_report_mysql_retention")
+	}
+	if c.MetricsReportRemoteWriteUrl != "" && c.MetricsReportRemoteWritePeriod <= 0 {
+		return errors.New("invalid metrics_report_remote_write_period")
+	}
+	switch c.MetricsReportBackend {
+	case "", MetricsBackendInfluxdb:
+	case MetricsBackendInfluxdb2:
+		if c.MetricsReportInfluxdbServer != "" && c.MetricsReportInfluxdbBucket == "" {
+			return errors.New("invalid metrics_report_influxdb_bucket")
+		}
+	case MetricsBackendOtlp:
+		if c.MetricsReportOtlpUrl != "" && c.MetricsReportOtlpPeriod <= 0 {
+			return errors.New("invalid metrics_report_otlp_period")
+		}
+		if _, err := parseOtlpHeaders(c.MetricsReportOtlpHeaders); err != nil {
+			return errors.New("invalid metrics_report_otlp_headers")
+		}
+	default:
+		return errors.New("invalid metrics_report_backend")
+	}
+	if c.SlotHeatPeriod < 0 {
+		return errors.New("invalid slot_heat_period")
+	}
+	if c.SlotHeatHistory <= 0 {
+		return errors.New("invalid slot_heat_history")
+	}
+	if c.DiscoveryConsulAddr != "" && c.DiscoveryConsulPeriod <= 0 {
+		return errors.New("invalid discovery_consul_period")
+	}
+	switch c.ReportPeriod {
+	case "", ReportDaily, ReportWeekly:
+	default:
+		return errors.New("invalid report_period")
+	}
+	if c.ReportHour < 0 || c.ReportHour > 23 {
+		return errors.New("invalid report_hour")
+	}
+	if c.ReportSmtpAddr != "" && (c.ReportSmtpFrom == "" || c.ReportSmtpTo == "") {
+		return errors.New("invalid report_smtp_from or report_smtp_to")
+	}
+	if c.ChaosEnabled && c.ChaosMaxDuration <= 0 {
+		return errors.New("invalid chaos_max_duration")
+	}
 	if c.SentinelClientTimeout <= 0 {
 		return errors.New("invalid sentinel_client_timeout")
 	}
@@ -203,6 +461,16 @@ func (c *Config) Validate() error {
 	if c.SentinelFailoverTimeout <= 0 {
 		return errors.New("invalid sentinel_failover_timeout")
 	}
+	var products = map[string]bool{c.ProductName: true}
+	for _, p := range c.Products {
+		if p == nil || products[p.ProductName] {
+			return errors.New("invalid products")
+		}
+		if err := models.ValidateProduct(p.ProductName); err != nil {
+			return errors.Errorf("invalid products: %s", err)
+		}
+		products[p.ProductName] = true
+	}
 	if c.Ncpu <= 0 {
 		return errors.New("invalid ncpu")
 	}
@@ -220,3 +488,21 @@ func (c *Config) Validate() error {
 	}
 	return nil
 }
+
+//未配置认证与TLS时返回nil
+func (c *Config) SentinelDialOptions() (*redis.DialOptions, error) {
+	if c.SentinelUsername == "" && c.SentinelPassword == "" && !c.SentinelTLS {
+		return nil, nil
+	}
+	opts := &redis.DialOptions{
+		Username: c.SentinelUsername, Password: c.SentinelPassword,
+	}
+	if c.SentinelTLS {
+		config, err := redis.NewTLSConfig(c.SentinelTLSCAFile, c.SentinelTLSSkipVerify)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = config
+	}
+	return opts, nil
+}
@@ -83,6 +83,10 @@ type TopomOpStats struct {
 }
 
 func (p *Topom) startMetricsReporter(d time.Duration, do func(loops int64) error, cleanup func() error) {
+	var generation = p.metrics.generation.Int64()
+	var stopped = func() bool {
+		return p.IsClosed() || p.metrics.generation.Int64() != generation
+	}
 	go func() {
 		if cleanup != nil {
 			defer cleanup()
@@ -95,7 +99,7 @@ func (p *Topom) startMetricsReporter(d time.Duration, do func(loops int64) error
 		}
 		var loops int64 = 0
 
-		for !p.IsClosed() {
+		for !stopped() {
 			<-ticker.C
 			if loops >= proxy.IntervalMark[len(proxy.IntervalMark)-1] {
 				loops = 0
@@ -103,7 +107,7 @@ func (p *Topom) startMetricsReporter(d time.Duration, do func(loops int64) error
 			loops++
 			if err := do(loops); err != nil {
 				log.WarnErrorf(err, "report metrics failed")
-				delay.SleepWithCancel(p.IsClosed)
+				delay.SleepWithCancel(stopped)
 			} else {
 				delay.Reset()
 			}
@@ -114,24 +118,17 @@ func (p *Topom) startMetricsReporter(d time.Duration, do func(loops int64) error
 func (p *Topom) startMetricsInfluxdb() {
 	server := p.config.MetricsReportInfluxdbServer
 	period := p.config.MetricsReportInfluxdbPeriod.Duration()
-	if server == "" {
+	if server == "" || p.config.MetricsReportBackend == MetricsBackendOtlp {
 		return
 	}
 	period = math2.MaxDuration(time.Second, period)
 
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr:     server,
-		Username: p.config.MetricsReportInfluxdbUsername,
-		Password: p.config.MetricsReportInfluxdbPassword,
-		Timeout:  time.Second * 5,
-	})
+	c, database, err := p.newInfluxdbWriter()
 	if err != nil {
 		log.WarnErrorf(err, "create influxdb client failed")
 		return
 	}
 
-	database := p.config.MetricsReportInfluxdbDatabase
-
 	p.startMetricsReporter(period, func(loops int64) error {
 		batch, err := client.NewBatchPoints(client.BatchPointsConfig{
 			Database:  database,
@@ -402,6 +399,20 @@ func (p *Topom) GenProxyPoints(stats *Stats, batch client.BatchPoints, topomStat
 				"sessions_total":           p.Stats.Sessions.Total,
 				"sessions_alive":           p.Stats.Sessions.Alive,
 			}
+			//连接池统计按proxy汇总，避免每个后端一个序列
+			var inflight, queue, reconnects, alarms int64
+			for _, x := range p.Stats.Backend.Pools {
+				inflight += x.Inflight
+				queue += x.Queue
+				reconnects += x.Reconnects
+				if len(x.Alarms) != 0 {
+					alarms++
+				}
+			}
+			fields["backend_inflight"] = inflight
+			fields["backend_queue"] = queue
+			fields["backend_reconnects"] = reconnects
+			fields["backend_pool_alarms"] = alarms
 
 			table := getTableName("proxy_", Pmodels[i].ProxyAddr)
 			point, err := client.NewPoint(table, tags, fields, time.Now())
@@ -932,6 +943,10 @@ func (p *Topom) QueryInfluxdb(interval_str, sql string) (*client.Response, error
 
 	server := p.config.MetricsReportInfluxdbServer
 	database := p.config.MetricsReportInfluxdbDatabase + database_suffix
+	//influxdb 2.x通过兼容1.x的/query接口查询，需要为bucket配置dbrp映射和1.x的用户名密码
+	if p.config.MetricsReportBackend == MetricsBackendInfluxdb2 {
+		database = p.config.MetricsReportInfluxdbBucket + database_suffix
+	}
 	//log.Warnf("database is %s", database)
 	if server == "" {
 		return nil, fmt.Errorf("influxdb server is nil")
@@ -0,0 +1,117 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+
+	client "github.com/influxdata/influxdb/client/v2"
+)
+
+//metrics_report_backend的取值
+const (
+	MetricsBackendInfluxdb  = "influxdb"
+	MetricsBackendInfluxdb2 = "influxdb2"
+	MetricsBackendOtlp      = "otlp"
+)
+
+//influxdb 1.x的client和influxdb2Writer都实现了这个接口
+type influxdbWriter interface {
+	Write(bp client.BatchPoints) error
+	Close() error
+}
+
+//返回writer和写入的库名，influxdb2中库名即bucket
+func (p *Topom) newInfluxdbWriter() (influxdbWriter, string, error) {
+	if p.config.MetricsReportBackend != MetricsBackendInfluxdb2 {
+		c, err := client.NewHTTPClient(client.HTTPConfig{
+			Addr:     p.config.MetricsReportInfluxdbServer,
+			Username: p.config.MetricsReportInfluxdbUsername,
+			Password: p.config.MetricsReportInfluxdbPassword,
+			Timeout:  time.Second * 5,
+		})
+		if err != nil {
+			return nil, "", errors.Trace(err)
+		}
+		return c, p.config.MetricsReportInfluxdbDatabase, nil
+	}
+	w, err := newInfluxdb2Writer(p.config.MetricsReportInfluxdbServer,
+		p.config.MetricsReportInfluxdbOrg, p.config.MetricsReportInfluxdbToken)
+	if err != nil {
+		return nil, "", err
+	}
+	return w, p.config.MetricsReportInfluxdbBucket, nil
+}
+
+//通过influxdb 2.x的/api/v2/write接口写入，BatchPoints中的Database作为bucket
+type influxdb2Writer struct {
+	url   *url.URL
+	org   string
+	token string
+
+	client *http.Client
+}
+
+func newInfluxdb2Writer(server, org, token string) (*influxdb2Writer, error) {
+	u, err := url.Parse(server)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("unsupported influxdb protocol scheme: %s", u.Scheme)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+	return &influxdb2Writer{
+		url: u, org: org, token: token,
+		client: &http.Client{Timeout: time.Second * 5},
+	}, nil
+}
+
+func (w *influxdb2Writer) Write(bp client.BatchPoints) error {
+	if len(bp.Points()) == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	for _, pt := range bp.Points() {
+		b.WriteString(pt.PrecisionString(bp.Precision()))
+		b.WriteByte('\n')
+	}
+
+	u := *w.url
+	params := url.Values{}
+	params.Set("org", w.org)
+	params.Set("bucket", bp.Database())
+	params.Set("precision", bp.Precision())
+	u.RawQuery = params.Encode()
+
+	req, err := http.NewRequest("POST", u.String(), &b)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("influxdb2 write failed: %s, %s", resp.Status, bytes.TrimSpace(body))
+	}
+	return nil
+}
+
+func (w *influxdb2Writer) Close() error {
+	return nil
+}
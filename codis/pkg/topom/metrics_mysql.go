@@ -0,0 +1,196 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+
+	_ "github.com/go-sql-driver/mysql"
+)
+
+const (
+	MysqlProxyStatsTable    = "codis_proxy_stats"
+	MysqlProxyCmdStatsTable = "codis_proxy_cmd_stats"
+)
+
+var mysqlMetricsSchema = []string{
+	"create table if not exists " + MysqlProxyStatsTable + ` (
+	id bigint not null auto_increment,
+	product_name varchar(128) not null,
+	proxy_token varchar(64) not null,
+	proxy_addr varchar(128) not null,
+	report_time datetime not null,
+	ops_total bigint not null default 0,
+	ops_fails bigint not null default 0,
+	redis_errors bigint not null default 0,
+	qps bigint not null default 0,
+	sessions_total bigint not null default 0,
+	sessions_alive bigint not null default 0,
+	primary key (id),
+	key idx_product_time (product_name, report_time),
+	key idx_time (report_time)
+) engine=InnoDB default charset=utf8`,
+	"create table if not exists " + MysqlProxyCmdStatsTable + ` (
+	id bigint not null auto_increment,
+	product_name varchar(128) not null,
+	proxy_addr varchar(128) not null,
+	interval_sec int not null,
+	report_time datetime not null,
+	cmd varchar(64) not null,
+	calls bigint not null default 0,
+	usecs bigint not null default 0,
+	usecs_percall bigint not null default 0,
+	fails bigint not null default 0,
+	redis_errtype bigint not null default 0,
+	qps bigint not null default 0,
+	avg bigint not null default 0,
+	tp90 bigint not null default 0,
+	tp99 bigint not null default 0,
+	tp999 bigint not null default 0,
+	tp9999 bigint not null default 0,
+	tp100 bigint not null default 0,
+	delay50ms bigint not null default 0,
+	delay100ms bigint not null default 0,
+	delay200ms bigint not null default 0,
+	delay300ms bigint not null default 0,
+	delay500ms bigint not null default 0,
+	delay1s bigint not null default 0,
+	delay2s bigint not null default 0,
+	delay3s bigint not null default 0,
+	primary key (id),
+	key idx_product_interval_time (product_name, interval_sec, report_time),
+	key idx_time (report_time)
+) engine=InnoDB default charset=utf8`,
+}
+
+const mysqlInsertProxyStats = "insert into " + MysqlProxyStatsTable +
+	" (product_name, proxy_token, proxy_addr, report_time, ops_total, ops_fails, redis_errors, qps, sessions_total, sessions_alive)" +
+	" values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+const mysqlInsertProxyCmdStats = "insert into " + MysqlProxyCmdStatsTable +
+	" (product_name, proxy_addr, interval_sec, report_time, cmd, calls, usecs, usecs_percall, fails, redis_errtype, qps, avg," +
+	" tp90, tp99, tp999, tp9999, tp100, delay50ms, delay100ms, delay200ms, delay300ms, delay500ms, delay1s, delay2s, delay3s)" +
+	" values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"
+
+//将每个proxy的ops与各时间精度的命令延迟统计写入mysql，复用mysql_addr的配置
+func (p *Topom) startMetricsMysql() {
+	period := p.config.MetricsReportMysqlPeriod.Duration()
+	if period == 0 || p.config.MysqlAddr == "" {
+		return
+	}
+	period = math2.MaxDuration(time.Second, period)
+
+	var dsn = fmt.Sprintf("%s:%s@tcp(%s)/%s", p.config.MysqlUsername, p.config.MysqlPassword,
+		p.config.MysqlAddr, p.config.MysqlDatabase)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		log.WarnErrorf(err, "open mysql for metrics failed")
+		return
+	}
+	for _, schema := range mysqlMetricsSchema {
+		if _, err := db.Exec(schema); err != nil {
+			log.WarnErrorf(err, "create mysql metrics tables failed")
+			db.Close()
+			return
+		}
+	}
+
+	retention := p.config.MetricsReportMysqlRetention.Duration()
+
+	p.startMetricsReporter(period, func(loops int64) error {
+		stats, err := p.Stats()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if err := p.writeMysqlProxyStats(db, stats, mysqlReportIntervals(loops)); err != nil {
+			return err
+		}
+		//loops每到一个最大时间精度的周期重置一次，此时清理过期数据
+		if loops == 1 && retention > 0 {
+			return purgeMysqlMetrics(db, time.Now().Add(-retention))
+		}
+		return nil
+	}, func() error {
+		return db.Close()
+	})
+}
+
+//返回本次需要上报的时间精度，与influxdb的上报周期保持一致
+func mysqlReportIntervals(loops int64) []int {
+	var list []int
+	for i := 0; i < len(proxy.IntervalMark); i++ {
+		if loops%proxy.IntervalMark[i] == 0 {
+			list = append(list, i)
+		}
+	}
+	return list
+}
+
+func (p *Topom) writeMysqlProxyStats(db *sql.DB, stats *Stats, intervals []int) error {
+	var now = time.Now().Format("2006-01-02 15:04:05")
+	var product = p.config.ProductName
+
+	tx, err := db.Begin()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for _, m := range stats.Proxy.Models {
+		if m == nil {
+			continue
+		}
+		x := stats.Proxy.Stats[m.Token]
+		if x == nil || x.Stats == nil {
+			continue
+		}
+		_, err := tx.Exec(mysqlInsertProxyStats, product, m.Token, m.ProxyAddr, now,
+			x.Stats.Ops.Total, x.Stats.Ops.Fails, x.Stats.Ops.Redis.Errors, x.Stats.Ops.QPS,
+			x.Stats.Sessions.Total, x.Stats.Sessions.Alive)
+		if err != nil {
+			tx.Rollback()
+			return errors.Trace(err)
+		}
+
+		if x.CmdStats == nil {
+			continue
+		}
+		for _, i := range intervals {
+			if i >= len(x.CmdStats.CmdList) || x.CmdStats.CmdList[i] == nil {
+				continue
+			}
+			for _, c := range x.CmdStats.CmdList[i].Cmd {
+				_, err := tx.Exec(mysqlInsertProxyCmdStats, product, m.ProxyAddr, proxy.IntervalMark[i], now,
+					c.OpStr, c.Calls, c.Usecs, c.UsecsPercall, c.Fails, c.RedisErrType, c.QPS, c.AVG,
+					c.TP90, c.TP99, c.TP999, c.TP9999, c.TP100,
+					c.Delay50ms, c.Delay100ms, c.Delay200ms, c.Delay300ms, c.Delay500ms,
+					c.Delay1s, c.Delay2s, c.Delay3s)
+				if err != nil {
+					tx.Rollback()
+					return errors.Trace(err)
+				}
+			}
+		}
+	}
+	return errors.Trace(tx.Commit())
+}
+
+func purgeMysqlMetrics(db *sql.DB, before time.Time) error {
+	var deadline = before.Format("2006-01-02 15:04:05")
+	for _, table := range []string{MysqlProxyStatsTable, MysqlProxyCmdStatsTable} {
+		r, err := db.Exec("delete from "+table+" where report_time < ?", deadline)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if n, _ := r.RowsAffected(); n != 0 {
+			log.Warnf("purge %d rows of %s before %s", n, table, deadline)
+		}
+	}
+	return nil
+}
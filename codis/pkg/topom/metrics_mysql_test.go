@@ -0,0 +1,21 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestMysqlReportIntervals(x *testing.T) {
+	assert.Must(len(mysqlReportIntervals(1)) == 1)
+	assert.Must(len(mysqlReportIntervals(7)) == 1)
+
+	list := mysqlReportIntervals(60)
+	assert.Must(len(list) == 3 && list[2] == 2)
+
+	list = mysqlReportIntervals(3600)
+	assert.Must(len(list) == 5)
+}
@@ -0,0 +1,163 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+)
+
+//OTLP/HTTP的json编码，只用到gauge类型，字段名见opentelemetry-proto中metrics.proto的json映射
+type otlpMetricsRequest struct {
+	ResourceMetrics []*otlpResourceMetrics `json:"resourceMetrics"`
+}
+
+type otlpResourceMetrics struct {
+	Resource struct {
+		Attributes []*otlpKeyValue `json:"attributes"`
+	} `json:"resource"`
+	ScopeMetrics []*otlpScopeMetrics `json:"scopeMetrics"`
+}
+
+type otlpScopeMetrics struct {
+	Scope struct {
+		Name string `json:"name"`
+	} `json:"scope"`
+	Metrics []*otlpMetric `json:"metrics"`
+}
+
+type otlpMetric struct {
+	Name  string `json:"name"`
+	Gauge struct {
+		DataPoints []*otlpDataPoint `json:"dataPoints"`
+	} `json:"gauge"`
+}
+
+type otlpDataPoint struct {
+	Attributes []*otlpKeyValue `json:"attributes,omitempty"`
+	//uint64在json映射中编码为字符串
+	TimeUnixNano string  `json:"timeUnixNano"`
+	AsDouble     float64 `json:"asDouble"`
+}
+
+type otlpKeyValue struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func newOtlpKeyValue(key, value string) *otlpKeyValue {
+	kv := &otlpKeyValue{Key: key}
+	kv.Value.StringValue = value
+	return kv
+}
+
+//格式为k1=v1,k2=v2
+func parseOtlpHeaders(s string) (map[string]string, error) {
+	var headers = make(map[string]string)
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.TrimSpace(x); x == "" {
+			continue
+		}
+		kv := strings.SplitN(x, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, errors.Errorf("invalid header %q", x)
+		}
+		headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return headers, nil
+}
+
+//推送与remote-write相同的集群汇总指标，product作为resource的属性
+func (p *Topom) startMetricsOtlp() {
+	url := p.config.MetricsReportOtlpUrl
+	period := p.config.MetricsReportOtlpPeriod.Duration()
+	if url == "" || p.config.MetricsReportBackend != MetricsBackendOtlp {
+		return
+	}
+	period = math2.MaxDuration(time.Second, period)
+
+	headers, err := parseOtlpHeaders(p.config.MetricsReportOtlpHeaders)
+	if err != nil {
+		return
+	}
+	var client = &http.Client{Timeout: time.Second * 10}
+
+	p.startMetricsReporter(period, func(loops int64) error {
+		stats, err := p.Stats()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		series := remoteWriteClusterSeries(p.config.ProductName, stats)
+		body, err := json.Marshal(encodeOtlpMetrics(p.config.ProductName, series, time.Now()))
+		if err != nil {
+			return errors.Trace(err)
+		}
+
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode/100 != 2 {
+			return errors.Errorf("otlp export failed: %s, %s", resp.Status, bytes.TrimSpace(b))
+		}
+		return nil
+	}, nil)
+}
+
+//同名的时间序列合并为一个metric，除product以外的label作为数据点的属性
+func encodeOtlpMetrics(product string, list []*remoteWriteSeries, now time.Time) *otlpMetricsRequest {
+	var ts = strconv.FormatInt(now.UnixNano(), 10)
+
+	var scope = &otlpScopeMetrics{}
+	scope.Scope.Name = "codis-dashboard"
+	var metrics = make(map[string]*otlpMetric)
+	for _, x := range list {
+		m := metrics[x.Name]
+		if m == nil {
+			m = &otlpMetric{Name: x.Name}
+			metrics[x.Name] = m
+			scope.Metrics = append(scope.Metrics, m)
+		}
+		var names []string
+		for k := range x.Labels {
+			if k != "product" {
+				names = append(names, k)
+			}
+		}
+		sort.Strings(names)
+		dp := &otlpDataPoint{TimeUnixNano: ts, AsDouble: x.Value}
+		for _, k := range names {
+			dp.Attributes = append(dp.Attributes, newOtlpKeyValue(k, x.Labels[k]))
+		}
+		m.Gauge.DataPoints = append(m.Gauge.DataPoints, dp)
+	}
+
+	var rm = &otlpResourceMetrics{ScopeMetrics: []*otlpScopeMetrics{scope}}
+	rm.Resource.Attributes = []*otlpKeyValue{
+		newOtlpKeyValue("service.name", "codis-dashboard"),
+		newOtlpKeyValue("codis.product", product),
+	}
+	return &otlpMetricsRequest{ResourceMetrics: []*otlpResourceMetrics{rm}}
+}
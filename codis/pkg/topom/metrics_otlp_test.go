@@ -0,0 +1,85 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+
+	client "github.com/influxdata/influxdb/client/v2"
+)
+
+func TestEncodeOtlpMetrics(x *testing.T) {
+	series := []*remoteWriteSeries{
+		{Name: "codis_ops_qps", Labels: map[string]string{"product": "demo"}, Value: 1.5},
+		{Name: "codis_cmd_calls", Labels: map[string]string{"product": "demo", "cmd": "GET"}, Value: 10},
+		{Name: "codis_cmd_calls", Labels: map[string]string{"product": "demo", "cmd": "SET"}, Value: 20},
+	}
+	r := encodeOtlpMetrics("demo", series, time.Unix(1, 0))
+	assert.Must(len(r.ResourceMetrics) == 1)
+	rm := r.ResourceMetrics[0]
+	assert.Must(len(rm.Resource.Attributes) == 2)
+	assert.Must(rm.Resource.Attributes[1].Key == "codis.product" && rm.Resource.Attributes[1].Value.StringValue == "demo")
+
+	metrics := rm.ScopeMetrics[0].Metrics
+	assert.Must(len(metrics) == 2)
+	assert.Must(metrics[0].Name == "codis_ops_qps" && len(metrics[0].Gauge.DataPoints) == 1)
+	dp := metrics[0].Gauge.DataPoints[0]
+	assert.Must(dp.TimeUnixNano == "1000000000" && dp.AsDouble == 1.5 && len(dp.Attributes) == 0)
+	assert.Must(metrics[1].Name == "codis_cmd_calls" && len(metrics[1].Gauge.DataPoints) == 2)
+	dp = metrics[1].Gauge.DataPoints[1]
+	assert.Must(len(dp.Attributes) == 1 && dp.Attributes[0].Key == "cmd" && dp.Attributes[0].Value.StringValue == "SET")
+}
+
+func TestParseOtlpHeaders(x *testing.T) {
+	h, err := parseOtlpHeaders("")
+	assert.MustNoError(err)
+	assert.Must(len(h) == 0)
+
+	h, err = parseOtlpHeaders("Authorization=Bearer a=b, X-Scope-OrgID = codis ,")
+	assert.MustNoError(err)
+	assert.Must(len(h) == 2 && h["Authorization"] == "Bearer a=b" && h["X-Scope-OrgID"] == "codis")
+
+	_, err = parseOtlpHeaders("Authorization")
+	assert.Must(err != nil)
+	_, err = parseOtlpHeaders("=codis")
+	assert.Must(err != nil)
+}
+
+func TestInfluxdb2Writer(x *testing.T) {
+	var path, query, auth, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, query, auth = r.URL.Path, r.URL.RawQuery, r.Header.Get("Authorization")
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer ts.Close()
+
+	_, err := newInfluxdb2Writer("udp://localhost:8089", "org", "token")
+	assert.Must(err != nil)
+
+	w, err := newInfluxdb2Writer(ts.URL+"/", "org", "token")
+	assert.MustNoError(err)
+
+	bp, err := client.NewBatchPoints(client.BatchPointsConfig{Database: "codis", Precision: "ns"})
+	assert.MustNoError(err)
+	assert.MustNoError(w.Write(bp))
+	assert.Must(path == "")
+
+	pt, err := client.NewPoint("codis_usage", map[string]string{"addr": "proxy-1"},
+		map[string]interface{}{"ops_qps": int64(10)}, time.Unix(1, 0))
+	assert.MustNoError(err)
+	bp.AddPoint(pt)
+	assert.MustNoError(w.Write(bp))
+	assert.Must(path == "/api/v2/write" && auth == "Token token")
+	assert.Must(query == "bucket=codis&org=org&precision=ns")
+	assert.Must(strings.TrimSpace(body) == "codis_usage,addr=proxy-1 ops_qps=10i 1000000000")
+}
@@ -0,0 +1,250 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+)
+
+//一条时间序列，Labels中不包含__name__
+type remoteWriteSeries struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+}
+
+//按prometheus remote-write协议将集群的汇总指标推送到远端的tsdb，适用于不能跨网络区域抓取proxy和dashboard的环境
+func (p *Topom) startMetricsRemoteWrite() {
+	url := p.config.MetricsReportRemoteWriteUrl
+	period := p.config.MetricsReportRemoteWritePeriod.Duration()
+	if url == "" {
+		return
+	}
+	period = math2.MaxDuration(time.Second, period)
+
+	var client = &http.Client{Timeout: time.Second * 10}
+
+	p.startMetricsReporter(period, func(loops int64) error {
+		stats, err := p.Stats()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		series := remoteWriteClusterSeries(p.config.ProductName, stats)
+		body := snappyEncode(encodeWriteRequest(series, time.Now()))
+
+		req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+		if err != nil {
+			return errors.Trace(err)
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+		if username := p.config.MetricsReportRemoteWriteUsername; username != "" {
+			req.SetBasicAuth(username, p.config.MetricsReportRemoteWritePassword)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		defer resp.Body.Close()
+		b, _ := ioutil.ReadAll(resp.Body)
+		if resp.StatusCode/100 != 2 {
+			return errors.Errorf("remote write failed: %s, %s", resp.Status, bytes.TrimSpace(b))
+		}
+		return nil
+	}, nil)
+}
+
+//集群维度的汇总指标，以及按group的内存、key数和按命令的调用统计
+func remoteWriteClusterSeries(product string, stats *Stats) []*remoteWriteSeries {
+	var list []*remoteWriteSeries
+	add := func(name string, value float64, labels ...string) {
+		x := &remoteWriteSeries{
+			Name: name, Value: value,
+			Labels: map[string]string{"product": product},
+		}
+		for i := 0; i+1 < len(labels); i += 2 {
+			x.Labels[labels[i]] = labels[i+1]
+		}
+		list = append(list, x)
+	}
+
+	summary := summarizeStats(stats)
+	add("codis_ops_qps", float64(summary.Ops.QPS))
+	add("codis_ops_total", float64(summary.Ops.Total))
+	add("codis_ops_fails", float64(summary.Ops.Fails))
+	add("codis_ops_redis_errors", float64(summary.Ops.RedisErrors))
+	add("codis_proxy_total", float64(summary.Proxy.Total))
+	add("codis_proxy_healthy", float64(summary.Proxy.Healthy))
+	add("codis_group_total", float64(summary.Group.Total))
+	add("codis_slots_pending", float64(summary.Migration.Pending))
+	add("codis_slots_migrating", float64(summary.Migration.Migrating))
+	add("codis_sentinel_unreachable", float64(summary.HA.Unreachable))
+
+	var sessions int64
+	for _, v := range stats.Proxy.Stats {
+		if v != nil && v.Stats != nil {
+			sessions += v.Stats.Sessions.Alive
+		}
+	}
+	add("codis_sessions_alive", float64(sessions))
+	for _, x := range aggregateProxyCommands(stats) {
+		add("codis_cmd_calls", float64(x.Calls), "cmd", x.OpStr)
+		add("codis_cmd_fails", float64(x.Fails), "cmd", x.OpStr)
+	}
+	for _, x := range aggregateBackendPools(stats) {
+		add("codis_backend_pool_inflight", float64(x.Inflight), "backend", x.Addr)
+		add("codis_backend_pool_queue", float64(x.Queue), "backend", x.Addr)
+		add("codis_backend_pool_wait_usecs", float64(x.WaitUsecs), "backend", x.Addr)
+		add("codis_backend_pool_reconnects", float64(x.Reconnects), "backend", x.Addr)
+		add("codis_backend_pool_alarms", float64(x.Alarms), "backend", x.Addr)
+	}
+
+	r := &Report{}
+	reportCapacity(r, stats)
+	for _, g := range r.Capacity.Group {
+		gid := strconv.Itoa(g.Id)
+		add("codis_group_used_memory", float64(g.UsedMemory), "group", gid)
+		add("codis_group_max_memory", float64(g.MaxMemory), "group", gid)
+		add("codis_group_keys", float64(g.Keys), "group", gid)
+	}
+	for _, r := range stats.Replication {
+		for _, g := range r.Groups {
+			gid := strconv.Itoa(g.Id)
+			var up float64
+			if g.Error == "" && g.LinkStatus == "up" {
+				up = 1
+			}
+			add("codis_replication_link_up", up, "secondary", r.Secondary, "group", gid)
+			add("codis_replication_offset_lag", float64(g.OffsetLag), "secondary", r.Secondary, "group", gid)
+			add("codis_replication_last_io_seconds", float64(g.LastIOSeconds), "secondary", r.Secondary, "group", gid)
+		}
+	}
+	return list
+}
+
+type backendPoolSummary struct {
+	Addr       string
+	Inflight   int64
+	Queue      int64
+	WaitUsecs  int64
+	Reconnects int64
+	//处于告警状态的proxy数
+	Alarms int64
+}
+
+//按后端地址汇总所有proxy的连接池统计，等待时间取最大值
+func aggregateBackendPools(stats *Stats) map[string]*backendPoolSummary {
+	var m = make(map[string]*backendPoolSummary)
+	for _, v := range stats.Proxy.Stats {
+		if v == nil || v.Stats == nil {
+			continue
+		}
+		for _, p := range v.Stats.Backend.Pools {
+			x := m[p.Addr]
+			if x == nil {
+				x = &backendPoolSummary{Addr: p.Addr}
+				m[p.Addr] = x
+			}
+			x.Inflight += p.Inflight
+			x.Queue += p.Queue
+			x.WaitUsecs = math2.MaxInt64(x.WaitUsecs, p.WaitUsecs)
+			x.Reconnects += p.Reconnects
+			if len(p.Alarms) != 0 {
+				x.Alarms++
+			}
+		}
+	}
+	return m
+}
+
+//按remote-write的protobuf定义编码WriteRequest：
+//  WriteRequest { repeated TimeSeries timeseries = 1; }
+//  TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//  Label { string name = 1; string value = 2; }
+//  Sample { double value = 1; int64 timestamp = 2; }
+//label需要按名字排序
+func encodeWriteRequest(list []*remoteWriteSeries, now time.Time) []byte {
+	var ts = now.UnixNano() / int64(time.Millisecond)
+	var b []byte
+	for _, x := range list {
+		var names = make([]string, 0, len(x.Labels)+1)
+		var labels = make(map[string]string, len(x.Labels)+1)
+		for k, v := range x.Labels {
+			names, labels[k] = append(names, k), v
+		}
+		names, labels["__name__"] = append(names, "__name__"), x.Name
+		sort.Strings(names)
+
+		var series []byte
+		for _, k := range names {
+			var label []byte
+			label = appendProtoBytes(label, 1, []byte(k))
+			label = appendProtoBytes(label, 2, []byte(labels[k]))
+			series = appendProtoBytes(series, 1, label)
+		}
+		var sample []byte
+		sample = appendProtoVarint(sample, 1<<3|1)
+		sample = appendFixed64(sample, math.Float64bits(x.Value))
+		sample = appendProtoVarint(sample, 2<<3|0)
+		sample = appendProtoVarint(sample, uint64(ts))
+		series = appendProtoBytes(series, 2, sample)
+
+		b = appendProtoBytes(b, 1, series)
+	}
+	return b
+}
+
+func appendProtoVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v))
+}
+
+func appendProtoBytes(b []byte, field int, p []byte) []byte {
+	b = appendProtoVarint(b, uint64(field)<<3|2)
+	b = appendProtoVarint(b, uint64(len(p)))
+	return append(b, p...)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	var x [8]byte
+	binary.LittleEndian.PutUint64(x[:], v)
+	return append(b, x[:]...)
+}
+
+//snappy block格式，数据全部按literal写入不做压缩，所有snappy解码器都可以解码
+func snappyEncode(p []byte) []byte {
+	var b = appendProtoVarint(nil, uint64(len(p)))
+	const maxLiteral = 1 << 16
+	for len(p) != 0 {
+		n := len(p)
+		if n > maxLiteral {
+			n = maxLiteral
+		}
+		switch m := n - 1; {
+		case m < 60:
+			b = append(b, byte(m)<<2)
+		case m < 1<<8:
+			b = append(b, 60<<2, byte(m))
+		default:
+			b = append(b, 61<<2, byte(m), byte(m>>8))
+		}
+		b = append(b, p[:n]...)
+		p = p[n:]
+	}
+	return b
+}
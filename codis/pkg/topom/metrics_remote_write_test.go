@@ -0,0 +1,78 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSnappyEncode(x *testing.T) {
+	assert.Must(bytes.Equal(snappyEncode([]byte("abc")), []byte{3, 2 << 2, 'a', 'b', 'c'}))
+
+	p := bytes.Repeat([]byte{'x'}, 100)
+	b := snappyEncode(p)
+	assert.Must(bytes.Equal(b[:3], []byte{100, 60 << 2, 99}))
+	assert.Must(bytes.Equal(b[3:], p))
+
+	p = bytes.Repeat([]byte{'x'}, 1<<16+10)
+	b = snappyEncode(p)
+	assert.Must(bytes.Equal(b[:6], []byte{0x8a, 0x80, 0x04, 61 << 2, 0xff, 0xff}))
+	assert.Must(bytes.Equal(b[6+1<<16:], []byte{9 << 2, 'x', 'x', 'x', 'x', 'x', 'x', 'x', 'x', 'x', 'x'}))
+}
+
+func TestEncodeWriteRequest(x *testing.T) {
+	series := []*remoteWriteSeries{
+		{Name: "codis_ops_qps", Labels: map[string]string{"product": "demo"}, Value: 1.5},
+	}
+	b := encodeWriteRequest(series, time.Unix(1, 0))
+
+	label := func(k, v string) []byte {
+		p := append([]byte{0x0a, byte(len(k))}, k...)
+		p = append(p, 0x12, byte(len(v)))
+		return append(p, v...)
+	}
+	var ts []byte
+	for _, l := range [][]byte{label("__name__", "codis_ops_qps"), label("product", "demo")} {
+		ts = append(ts, 0x0a, byte(len(l)))
+		ts = append(ts, l...)
+	}
+	sample := []byte{0x09, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 0xe8, 0x07}
+	binary.LittleEndian.PutUint64(sample[1:9], math.Float64bits(1.5))
+	ts = append(ts, 0x12, byte(len(sample)))
+	ts = append(ts, sample...)
+
+	expect := append([]byte{0x0a, byte(len(ts))}, ts...)
+	assert.Must(bytes.Equal(b, expect))
+}
+
+func TestAggregateBackendPools(x *testing.T) {
+	newProxyStats := func(pools ...*proxy.BackendPoolStats) *ProxyStats {
+		p := &ProxyStats{Stats: &proxy.Stats{}}
+		p.Stats.Backend.Pools = pools
+		return p
+	}
+	stats := &Stats{}
+	stats.Proxy.Stats = map[string]*ProxyStats{
+		"t1": newProxyStats(
+			&proxy.BackendPoolStats{Addr: "s1:1", Inflight: 3, Queue: 1, WaitUsecs: 100},
+			&proxy.BackendPoolStats{Addr: "s2:1", Reconnects: 2},
+		),
+		"t2": newProxyStats(
+			&proxy.BackendPoolStats{Addr: "s1:1", Inflight: 2, WaitUsecs: 300, Alarms: []string{"wait"}},
+		),
+		"t3": {},
+	}
+	m := aggregateBackendPools(stats)
+	assert.Must(len(m) == 2)
+	assert.Must(m["s1:1"].Inflight == 5 && m["s1:1"].Queue == 1)
+	assert.Must(m["s1:1"].WaitUsecs == 300 && m["s1:1"].Alarms == 1)
+	assert.Must(m["s2:1"].Reconnects == 2 && m["s2:1"].Alarms == 0)
+}
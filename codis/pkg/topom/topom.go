@@ -60,6 +60,13 @@ type Topom struct {
 
 		progress struct {
 			status atomic.Value
+
+			sync.Mutex
+			slots map[int]*slotProgress
+		}
+		throttle struct {
+			sync.Mutex
+			next time.Time
 		}
 		executor atomic2.Int64
 	}
@@ -71,17 +78,143 @@ type Topom struct {
 		proxies map[string]*ProxyStats
 	}
 
+	switchover *models.Switchover
+
+	standby *models.Standby
+	quotas  *models.KeyQuotas
+	routes  *models.PrefixRoutes
+	ttls    *models.TTLRules
+	filter  *models.RequestFilter
+	tenants *models.Namespaces
+	acls    *models.ACLUsers
+
+	overrides *models.ConfigOverrides
+
+	scaling *models.ScalingWorkflow
+
+	//正在下线的group对应的job，读写需要持有topom锁
+	decommissions map[int]int
+
+	verify struct {
+		sync.Mutex
+		//slot最近一次的迁移校验结果
+		slots map[int]*models.SlotVerifyReport
+		//与目标product最近一次的比较结果
+		keyspace map[string]*KeyspaceDiffDetail
+	}
+
+	jobs jobList
+
+	//last为每个proxy上一次上报的累计值，history为最近的负载采样，summary为history的平均值并持久化到store
+	slotHeat struct {
+		sync.Mutex
+		last    map[string]*slotHeatCounter
+		history []*slotHeatSample
+		summary *models.SlotHeat
+	}
+
+	//已从store加载的slot历史，避免每次记录都读取store
+	slotHistory struct {
+		sync.Mutex
+		m map[int]*models.SlotHistory
+	}
+
+	//从store加载的审计记录
+	auditLog struct {
+		sync.Mutex
+		p *models.AuditLog
+	}
+
+	//正在生效的故障注入
+	chaosFaults struct {
+		sync.Mutex
+		nextId int
+		m      map[int]*ChaosFault
+
+		sentinelDelay atomic2.Int64
+	}
+
+	//每个group最近一次的备份校验结果
+	backupVerifyReports struct {
+		sync.Mutex
+		m map[int]*BackupVerifyDetail
+	}
+
+	//与每个备product的复制状态
+	replicationStatus struct {
+		sync.Mutex
+		m map[string]*ReplicationLinkStatus
+	}
+
+	//定期报告的采样和上一次发送时间
+	reporter struct {
+		sync.Mutex
+		samples   []*ReportSample
+		lastCalls int64
+		lastSent  time.Time
+	}
+
+	//最近生成的迁移计划
+	migrationPlans struct {
+		sync.Mutex
+		nextId int
+		list   []*MigrationPlan
+	}
+
+	//最近生成的拓扑计划
+	topologyPlans struct {
+		sync.Mutex
+		nextId int
+		list   []*TopologyPlan
+	}
+
 	ha struct {
-		redisp *redis.Pool
+		redisp  *redis.Pool
+		options *redis.DialOptions
 
 		monitor *redis.Sentinel
 		masters map[int]string
 	}
+
+	metrics struct {
+		//修改metrics配置时递增，正在运行的上报协程退出
+		generation atomic2.Int64
+	}
+
+	products struct {
+		sync.RWMutex
+		m map[string]*Topom
+		h map[string]http.Handler
+	}
 }
 
 var ErrClosedTopom = errors.New("use of closed topom")
 
 func New(client models.Client, config *Config) (*Topom, error) {
+	s, err := newTopom(client, config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.setup(config); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	log.Warnf("create new topom:\n%s", s.model.Encode())
+
+	go s.serveAdmin()
+
+	s.startMetricsInfluxdb()
+	s.startMetricsMysql()
+	s.startMetricsRemoteWrite()
+	s.startMetricsOtlp()
+
+	return s, nil
+}
+
+//不包括admin端口，由New和AddProduct分别设置
+func newTopom(client models.Client, config *Config) (*Topom, error) {
 	if err := config.Validate(); err != nil {
 		return nil, errors.Trace(err)
 	}
@@ -93,8 +226,21 @@ func New(client models.Client, config *Config) (*Topom, error) {
 	s.exit.C = make(chan struct{})
 	s.action.redisp = redis.NewPool(config.ProductAuth, config.MigrationTimeout.Duration())
 	s.action.progress.status.Store("")
-
-	s.ha.redisp = redis.NewPool("", time.Second*5)
+	s.verify.slots = make(map[int]*models.SlotVerifyReport)
+	s.verify.keyspace = make(map[string]*KeyspaceDiffDetail)
+	s.decommissions = make(map[int]int)
+	s.slotHeat.last = make(map[string]*slotHeatCounter)
+	s.slotHistory.m = make(map[int]*models.SlotHistory)
+	s.chaosFaults.m = make(map[int]*ChaosFault)
+	s.backupVerifyReports.m = make(map[int]*BackupVerifyDetail)
+	s.replicationStatus.m = make(map[string]*ReplicationLinkStatus)
+
+	options, err := config.SentinelDialOptions()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	s.ha.options = options
+	s.ha.redisp = redis.NewPoolOptions(options, time.Second*5)
 
 	s.model = &models.Topom{
 		StartTime: time.Now().String(),
@@ -126,17 +272,8 @@ func New(client models.Client, config *Config) (*Topom, error) {
 	s.stats.servers = make(map[string]*RedisStats)
 	s.stats.proxies = make(map[string]*ProxyStats)
 
-	if err := s.setup(config); err != nil {
-		s.Close()
-		return nil, err
-	}
-
-	log.Warnf("create new topom:\n%s", s.model.Encode())
-
-	go s.serveAdmin()
-
-	s.startMetricsInfluxdb()
-
+	s.products.m = make(map[string]*Topom)
+	s.products.h = make(map[string]http.Handler)
 	return s, nil
 }
 
@@ -171,6 +308,9 @@ func (s *Topom) Close() error {
 	s.closed = true
 	close(s.exit.C)
 
+	s.closeProducts()
+	s.chaosRecoverAll()
+
 	if s.ladmin != nil {
 		s.ladmin.Close()
 	}
@@ -193,6 +333,20 @@ func (s *Topom) Close() error {
 	return nil
 }
 
+//租约锁丢失后其他dashboard可能已经持有锁，不能再释放锁
+func (s *Topom) exitOnLockLost(lost <-chan struct{}) {
+	<-lost
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.online = false
+	s.mu.Unlock()
+	log.Errorf("store: lease lock of %s lost, dashboard exits", s.config.ProductName)
+	s.Close()
+}
+
 func (s *Topom) Start(routines bool) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -207,6 +361,98 @@ func (s *Topom) Start(routines bool) error {
 			return errors.Errorf("store: acquire lock of %s failed", s.config.ProductName)
 		}
 		s.online = true
+		if lost := s.store.LockLost(); lost != nil {
+			go s.exitOnLockLost(lost)
+		}
+	}
+
+	if p, err := s.store.LoadSwitchover(false); err != nil {
+		log.ErrorErrorf(err, "store: load switchover failed")
+		return errors.Errorf("store: load switchover failed")
+	} else {
+		s.switchover = p
+	}
+
+	if p, err := s.store.LoadStandby(false); err != nil {
+		log.ErrorErrorf(err, "store: load standby failed")
+		return errors.Errorf("store: load standby failed")
+	} else {
+		s.standby = p
+	}
+
+	if p, err := s.store.LoadKeyQuotas(false); err != nil {
+		log.ErrorErrorf(err, "store: load key quotas failed")
+		return errors.Errorf("store: load key quotas failed")
+	} else {
+		s.quotas = p
+	}
+
+	if p, err := s.store.LoadPrefixRoutes(false); err != nil {
+		log.ErrorErrorf(err, "store: load prefix routes failed")
+		return errors.Errorf("store: load prefix routes failed")
+	} else {
+		s.routes = p
+	}
+
+	if p, err := s.store.LoadTTLRules(false); err != nil {
+		log.ErrorErrorf(err, "store: load ttl rules failed")
+		return errors.Errorf("store: load ttl rules failed")
+	} else {
+		s.ttls = p
+	}
+
+	if p, err := s.store.LoadRequestFilter(false); err != nil {
+		log.ErrorErrorf(err, "store: load request filter failed")
+		return errors.Errorf("store: load request filter failed")
+	} else {
+		s.filter = p
+	}
+
+	if p, err := s.store.LoadNamespaces(false); err != nil {
+		log.ErrorErrorf(err, "store: load namespaces failed")
+		return errors.Errorf("store: load namespaces failed")
+	} else {
+		s.tenants = p
+	}
+
+	if p, err := s.store.LoadACLUsers(false); err != nil {
+		log.ErrorErrorf(err, "store: load acl users failed")
+		return errors.Errorf("store: load acl users failed")
+	} else {
+		s.acls = p
+	}
+
+	if p, err := s.store.LoadConfigOverrides(false); err != nil {
+		log.ErrorErrorf(err, "store: load config overrides failed")
+		return errors.Errorf("store: load config overrides failed")
+	} else if s.applyConfigOverrides(p) {
+		go s.restartMetrics()
+	}
+
+	if w, err := s.store.LoadScaling(false); err != nil {
+		log.ErrorErrorf(err, "store: load scaling failed")
+		return errors.Errorf("store: load scaling failed")
+	} else {
+		s.scaling = w
+	}
+
+	if p, err := s.store.LoadSlotVerifyFailures(false); err != nil {
+		log.ErrorErrorf(err, "store: load slot verify failures failed")
+		return errors.Errorf("store: load slot verify failures failed")
+	} else if p != nil {
+		s.verify.Lock()
+		for _, r := range p.Reports {
+			s.verify.slots[r.Slot] = r
+		}
+		s.verify.Unlock()
+	}
+
+	if p, err := s.store.LoadSlotHeat(false); err != nil {
+		log.WarnErrorf(err, "store: load slot heat failed")
+	} else if p != nil {
+		s.slotHeat.Lock()
+		s.slotHeat.summary = p
+		s.slotHeat.Unlock()
 	}
 
 	if !routines {
@@ -218,6 +464,12 @@ func (s *Topom) Start(routines bool) error {
 	}
 	s.rewatchSentinels(ctx.sentinel.Servers)
 
+	//dashboard重启后继续执行未完成的扩缩容
+	if w := s.scaling; w != nil && w.State == models.ScalingRunning && s.config.MasterProduct == "" && !s.isStandby() {
+		log.Warnf("scaling-[%d]: resume at step %s", w.Id, w.Step)
+		go s.runScaling(w.Id)
+	}
+
 	go func() {
 		for !s.IsClosed() {
 			if s.IsOnline() {
@@ -302,6 +554,43 @@ func (s *Topom) Start(routines bool) error {
 		}
 	}()
 
+	go func() {
+		for !s.IsClosed() {
+			period := s.config.SlotHeatPeriod.Duration()
+			if period <= 0 {
+				return
+			}
+			if s.IsOnline() {
+				if err := s.RefreshSlotHeat(); err != nil {
+					log.WarnErrorf(err, "refresh slot heat failed")
+				}
+			}
+			time.Sleep(period)
+		}
+	}()
+
+	s.startConsulRegistration()
+
+	go func() {
+		for !s.IsClosed() {
+			if s.IsOnline() {
+				s.refreshReport(time.Now())
+			}
+			time.Sleep(time.Minute)
+		}
+	}()
+
+	go func() {
+		for !s.IsClosed() {
+			if s.IsOnline() {
+				if err := s.RefreshReplicationLinks(); err != nil {
+					log.WarnErrorf(err, "refresh replication links failed")
+				}
+			}
+			time.Sleep(time.Second * 5)
+		}
+	}()
+
 	go func() {
 		for !s.IsClosed() {
 			if s.IsOnline() {
@@ -360,7 +649,7 @@ func (s *Topom) Stats() (*Stats, error) {
 	stats := &Stats{}
 	stats.Closed = s.closed
 
-	stats.Slots = ctx.slots
+	stats.Slots = s.slotsWithProgress(ctx.slots)
 
 	stats.Group.Models = models.SortGroup(ctx.group)
 	stats.Group.Stats = map[string]*RedisStats{}
@@ -372,6 +661,8 @@ func (s *Topom) Stats() (*Stats, error) {
 		}
 	}
 
+	stats.Group.Health = encodeGroupHealth(s.groupHealth(ctx))
+
 	stats.Proxy.Models = models.SortProxy(ctx.proxy)
 	stats.Proxy.Stats = s.stats.proxies
 
@@ -393,6 +684,7 @@ func (s *Topom) Stats() (*Stats, error) {
 			stats.HA.Masters[strconv.Itoa(gid)] = addr
 		}
 	}
+	stats.Replication = s.ReplicationLinks()
 	return stats, nil
 }
 
@@ -404,11 +696,14 @@ type Stats struct {
 	Group struct {
 		Models []*models.Group        `json:"models"`
 		Stats  map[string]*RedisStats `json:"stats"`
+		Health map[string]string      `json:"health"`
 	} `json:"group"`
 
 	Proxy struct {
 		Models []*models.Proxy        `json:"models"`
 		Stats  map[string]*ProxyStats `json:"stats"`
+		//分页时为proxy的总数
+		Total int `json:"total,omitempty"`
 	} `json:"proxy"`
 
 	SlotAction struct {
@@ -427,6 +722,8 @@ type Stats struct {
 		Stats   map[string]*RedisStats `json:"stats"`
 		Masters map[string]string      `json:"masters"`
 	} `json:"sentinels"`
+
+	Replication []*ReplicationLinkStatus `json:"replication,omitempty"`
 }
 
 func (s *Topom) Config() *Config {
@@ -504,7 +801,7 @@ func (s *Topom) serveAdmin() {
 	eh := make(chan error, 1)
 	go func(l net.Listener) {
 		h := http.NewServeMux()
-		h.Handle("/", newApiServer(s))
+		h.Handle("/", s.newProductRouter(newApiServer(s)))
 		hs := &http.Server{Handler: h}
 		eh <- hs.Serve(l)
 	}(s.ladmin)
@@ -539,127 +836,6 @@ func (s *Topom) Overview() (*Overview, error) {
 	}
 }
 
-func (s *Topom) SetConfig(key, value string) (string, error) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	var nextStep string
-	strings.ToLower(value)
-	strings.ToLower(key)
-	
-	switch key {
-	case "migration_method":
-		nextStep = "migrate"
-		if (value != "semi-async" && value != "sync") {
-			return "", errors.New("invalid value for migration_method")
-		}
-		s.config.MigrationMethod = value
-
-	case "migration_parallel_slots":
-		nextStep = "migrate"
-		ret,err := strconv.Atoi(value)
-		if err != nil {
-			return nextStep, err
-		}
-		s.config.MigrationParallelSlots = ret
-
-	case "migration_async_maxbulks":
-		nextStep = "migrate"
-		ret,err := strconv.Atoi(value)
-		if err != nil {
-			return nextStep, err
-		}
-		s.config.MigrationAsyncMaxBulks = ret
-
-	case "migration_async_maxbytes":
-		nextStep = "migrate"
-		p := &(s.config.MigrationAsyncMaxBytes)
-		err :=  p.UnmarshalText([]byte(value))
-		if err != nil {
-			return nextStep, err
-		}
-
-	case "migration_async_numkeys":
-		nextStep = "migrate"
-		ret,err := strconv.Atoi(value)
-		if err != nil {
-			return nextStep, err
-		}
-		s.config.MigrationAsyncNumKeys = ret
-
-	case "migration_timeout":
-		nextStep = "migrate"
-		p := &(s.config.MigrationTimeout)
-		err :=  p.UnmarshalText([]byte(value))
-		if err != nil {
-			return nextStep, err
-		}
-
-	case "sentinel_client_timeout":
-		nextStep = "sentinel"
-		p := &(s.config.SentinelClientTimeout)
-		err :=  p.UnmarshalText([]byte(value))
-		if err != nil {
-			return nextStep, err
-		}
-
-	case "sentinel_quorum":
-		nextStep = "sentinel"
-		ret,err := strconv.Atoi(value)
-		if err != nil {
-			return nextStep, err
-		}
-		s.config.SentinelQuorum = ret
-
-	case "sentinel_parallel_syncs":
-		nextStep = "sentinel"
-		ret,err := strconv.Atoi(value)
-		if err != nil {
-			return nextStep, err
-		}
-		s.config.SentinelParallelSyncs = ret
-
-	case "sentinel_down_after":
-		nextStep = "sentinel"
-		p := &(s.config.SentinelDownAfter)
-		err :=  p.UnmarshalText([]byte(value))
-		if err != nil {
-			return nextStep, err
-		}
-
-	case "sentinel_failover_timeout":
-		nextStep = "sentinel"
-		p := &(s.config.SentinelFailoverTimeout)
-		err :=  p.UnmarshalText([]byte(value))
-		if err != nil {
-			return nextStep, err
-		}
-
-	case "sentinel_notification_script":
-		nextStep = "sentinel"
-		s.config.SentinelNotificationScript = value
-
-	case "sentinel_client_reconfig_script":
-		nextStep = "sentinel"
-		s.config.SentinelClientReconfigScript = value
-
-	case "expire_log_days":
-		nextStep = "log"
-		intValue, err := strconv.Atoi(value)
-		if err != nil {
-			return nextStep, err
-		}
-		if intValue < 0 {
-			return nextStep, errors.New("invalid expire_log_days")
-		}
-		s.config.ExpireLogDays = intValue
-
-	default:
-		return "", errors.New("invalid key")
-	}
-	return nextStep, utils.RewriteConf(*(s.config), s.config.ConfigName, "=", true)
-}
-
 func NewZkToMysql(client models.Client, config *Config) (*Topom, error) {
 	if err := config.Validate(); err != nil {
 		return nil, errors.Trace(err)
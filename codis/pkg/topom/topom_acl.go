@@ -0,0 +1,115 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+func (s *Topom) aclUsers() *models.ACLUsers {
+	if s.acls == nil {
+		return &models.ACLUsers{}
+	}
+	return s.acls
+}
+
+func (s *Topom) ACLUsers() []*models.ACLUser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list = []*models.ACLUser{}
+	for _, u := range s.aclUsers().Users {
+		x := *u
+		list = append(list, &x)
+	}
+	return list
+}
+
+//新增或修改一个用户并下发给所有proxy
+func (s *Topom) UpdateACLUser(u *models.ACLUser) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+	if err := u.Validate(); err != nil {
+		return err
+	}
+
+	var p = &models.ACLUsers{}
+	for _, x := range s.aclUsers().Users {
+		if x.Name != u.Name {
+			p.Users = append(p.Users, x)
+		}
+	}
+	x := *u
+	p.Users = append(p.Users, &x)
+	sort.Sort(aclUserSorter(p.Users))
+
+	if err := s.storeUpdateACLUsers(p); err != nil {
+		return err
+	}
+	s.acls = p
+	return s.resyncACLUsers(ctx)
+}
+
+func (s *Topom) RemoveACLUser(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	var p = &models.ACLUsers{}
+	for _, x := range s.aclUsers().Users {
+		if x.Name != name {
+			p.Users = append(p.Users, x)
+		}
+	}
+	if len(p.Users) == len(s.aclUsers().Users) {
+		return errors.Errorf("acl user-[%s] doesn't exist", name)
+	}
+
+	if err := s.storeUpdateACLUsers(p); err != nil {
+		return err
+	}
+	s.acls = p
+	return s.resyncACLUsers(ctx)
+}
+
+func (s *Topom) resyncACLUsers(ctx *context) error {
+	for _, p := range ctx.proxy {
+		if err := s.newProxyClient(p).SetACLUsers(s.aclUsers()); err != nil {
+			log.ErrorErrorf(err, "proxy-[%s] set acl users failed", p.Token)
+			return errors.Errorf("proxy-[%s] set acl users failed", p.Token)
+		}
+	}
+	return nil
+}
+
+//日志中不打印密码
+func (s *Topom) storeUpdateACLUsers(p *models.ACLUsers) error {
+	var names []string
+	for _, u := range p.Users {
+		names = append(names, u.Name)
+	}
+	log.Warnf("update acl users: [%s]", strings.Join(names, ","))
+	if err := s.store.UpdateACLUsers(p); err != nil {
+		log.ErrorErrorf(err, "store: update acl users failed")
+		return errors.Errorf("store: update acl users failed")
+	}
+	return nil
+}
+
+type aclUserSorter []*models.ACLUser
+
+func (s aclUserSorter) Len() int           { return len(s) }
+func (s aclUserSorter) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s aclUserSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
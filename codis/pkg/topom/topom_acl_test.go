@@ -0,0 +1,36 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestACLUser(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	assert.Must(len(t.ACLUsers()) == 0)
+	assert.Must(t.UpdateACLUser(&models.ACLUser{Name: "app", Commands: []string{"+@all"}}) != nil)
+	assert.Must(t.UpdateACLUser(&models.ACLUser{Name: "app", Password: "pa", Commands: []string{"@all"}}) != nil)
+
+	assert.MustNoError(t.UpdateACLUser(&models.ACLUser{Name: "ops", Password: "po", Commands: []string{"+@all"}}))
+	assert.MustNoError(t.UpdateACLUser(&models.ACLUser{Name: "app", Password: "pa", Commands: []string{"+@read"}}))
+	assert.MustNoError(t.UpdateACLUser(&models.ACLUser{Name: "app", Password: "pa", Commands: []string{"+@all", "-flushdb"}}))
+
+	list := t.ACLUsers()
+	assert.Must(len(list) == 2)
+	assert.Must(list[0].Name == "app" && len(list[0].Commands) == 2 && list[1].Name == "ops")
+
+	p, err := t.store.LoadACLUsers(true)
+	assert.MustNoError(err)
+	assert.Must(len(p.Users) == 2)
+
+	assert.MustNoError(t.RemoveACLUser("app"))
+	assert.Must(t.RemoveACLUser("app") != nil)
+	assert.Must(len(t.ACLUsers()) == 1)
+}
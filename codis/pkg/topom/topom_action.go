@@ -12,6 +12,7 @@ import (
 	"os"
 
 	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
 	"github.com/CodisLabs/codis/pkg/utils/log"
 	"github.com/CodisLabs/codis/pkg/utils/math2"
 	"github.com/CodisLabs/codis/pkg/utils/sync2"
@@ -19,6 +20,13 @@ import (
 
 func (s *Topom) ProcessSlotAction() error {
 	for s.IsOnline() {
+		if s.isMigrationPaused(time.Now()) {
+			if s.hasSlotAction() {
+				s.pauseSlotAction(-1)
+			}
+			return nil
+		}
+		s.resumeSlotAction()
 		var (
 			marks = make(map[int]bool)
 			plans = make(map[int]bool)
@@ -79,7 +87,22 @@ func (s *Topom) ProcessSlotAction() error {
 
 func (s *Topom) processSlotAction(sid int) error {
 	var db int = 0
+	if r := s.slotVerifyFailed(sid); r != nil {
+		return errors.Errorf("slot-[%d] verify migration failed, missing = %d, mismatched = %d",
+			sid, len(r.Missing), len(r.Mismatched))
+	}
+	var sample *slotSample
+	if n := s.config.MigrationVerifyKeys; n > 0 {
+		sample = s.sampleSlotMigration(sid, n)
+	}
+	s.startSlotProgress(sid)
+	defer s.finishSlotProgress(sid)
 	for s.IsOnline() {
+		if s.isMigrationPaused(time.Now()) {
+			s.pauseSlotAction(sid)
+			time.Sleep(time.Second)
+			continue
+		}
 		if exec, err := s.newSlotActionExecutor(sid); err != nil {
 			return err
 		} else if exec == nil {
@@ -89,11 +112,27 @@ func (s *Topom) processSlotAction(sid int) error {
 			if err != nil {
 				return err
 			}
+			s.waitMigrationBandwidth(sid)
 			log.Debugf("slot-[%d] action executor %d", sid, n)
 
 			if n == 0 && nextdb == -1 {
+				if sample != nil {
+					r, err := s.finishSlotVerify(sample)
+					if err != nil {
+						return err
+					}
+					if r.Failed() {
+						return errors.Errorf("slot-[%d] verify migration failed, missing = %d, mismatched = %d",
+							sid, len(r.Missing), len(r.Mismatched))
+					}
+				}
 				return s.SlotActionComplete(sid)
 			}
+			if sample != nil {
+				if err := s.checkSlotMigration(sample); err != nil {
+					return err
+				}
+			}
 			status := fmt.Sprintf("[OK] Slot[%04d]@DB[%d]=%d", sid, db, n)
 			s.action.progress.status.Store(status)
 
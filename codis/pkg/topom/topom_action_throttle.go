@@ -0,0 +1,144 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//允许迁移的时间段，按一天中的分钟数表示，begin大于end时跨过零点
+type migrationWindow struct {
+	begin, end int
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, errors.Errorf("invalid time %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func parseMigrationWindows(s string) ([]migrationWindow, error) {
+	var windows []migrationWindow
+	for _, w := range strings.Split(s, ",") {
+		if w = strings.TrimSpace(w); w == "" {
+			continue
+		}
+		p := strings.Split(w, "-")
+		if len(p) != 2 {
+			return nil, errors.Errorf("invalid migration window %s", w)
+		}
+		begin, err := parseClock(p[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(p[1])
+		if err != nil {
+			return nil, err
+		}
+		if begin == end {
+			return nil, errors.Errorf("invalid migration window %s", w)
+		}
+		windows = append(windows, migrationWindow{begin, end})
+	}
+	return windows, nil
+}
+
+//没有配置时间段时总是允许迁移
+func inMigrationWindows(windows []migrationWindow, t time.Time) bool {
+	if len(windows) == 0 {
+		return true
+	}
+	var now = t.Hour()*60 + t.Minute()
+	for _, w := range windows {
+		if w.begin < w.end {
+			if now >= w.begin && now < w.end {
+				return true
+			}
+		} else if now >= w.begin || now < w.end {
+			return true
+		}
+	}
+	return false
+}
+
+//不在允许迁移的时间段内时暂停迁移，正在迁移的slot在当前一轮结束后等待
+func (s *Topom) isMigrationPaused(t time.Time) bool {
+	windows, err := parseMigrationWindows(s.config.MigrationWindows)
+	if err != nil {
+		return false
+	}
+	return !inMigrationWindows(windows, t)
+}
+
+func (s *Topom) pauseSlotAction(sid int) {
+	var status = "[PAUSED] outside migration windows"
+	if sid >= 0 {
+		status = fmt.Sprintf("[PAUSED] Slot[%04d]: outside migration windows", sid)
+	}
+	s.action.progress.status.Store(status)
+}
+
+func (s *Topom) resumeSlotAction() {
+	if status, _ := s.action.progress.status.Load().(string); strings.HasPrefix(status, "[PAUSED]") {
+		s.action.progress.status.Store("")
+	}
+}
+
+func (s *Topom) hasSlotAction() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return false
+	}
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing {
+			return true
+		}
+	}
+	return false
+}
+
+//按所有迁移中的slot共用的带宽预留时间，返回需要等待的时间
+func (s *Topom) reserveMigrationBandwidth(bytes float64, bandwidth int64) time.Duration {
+	s.action.throttle.Lock()
+	defer s.action.throttle.Unlock()
+	var now = time.Now()
+	if s.action.throttle.next.Before(now) {
+		s.action.throttle.next = now
+	}
+	s.action.throttle.next = s.action.throttle.next.Add(time.Duration(bytes / float64(bandwidth) * float64(time.Second)))
+	return s.action.throttle.next.Sub(now)
+}
+
+//每一轮迁移之后按估算的字节数限速，源group的平均key大小未知时不限速
+func (s *Topom) waitMigrationBandwidth(sid int) {
+	bandwidth := s.config.MigrationMaxBandwidth.Int64()
+
+	s.action.progress.Lock()
+	p := s.action.progress.slots[sid]
+	if p == nil {
+		s.action.progress.Unlock()
+		return
+	}
+	bytes := float64(p.keys-p.throttled) * p.bytesPerKey
+	p.throttled = p.keys
+	s.action.progress.Unlock()
+
+	if bandwidth <= 0 || bytes <= 0 {
+		return
+	}
+	if d := s.reserveMigrationBandwidth(bytes, bandwidth); d > 0 {
+		log.Debugf("slot-[%d] migration throttled for %s", sid, d)
+		time.Sleep(d)
+	}
+}
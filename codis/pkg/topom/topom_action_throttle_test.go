@@ -0,0 +1,71 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestMigrationWindows(x *testing.T) {
+	for _, s := range []string{"01:00", "01:00-01:00", "1-6", "01:00-25:00", "01:00-02:00-03:00"} {
+		_, err := parseMigrationWindows(s)
+		assert.Must(err != nil)
+	}
+	windows, err := parseMigrationWindows(" 01:00-06:00, 22:30-00:30 ")
+	assert.MustNoError(err)
+	assert.Must(len(windows) == 2)
+
+	var clock = func(h, m int) time.Time {
+		return time.Date(2020, 1, 1, h, m, 0, 0, time.Local)
+	}
+	assert.Must(inMigrationWindows(windows, clock(1, 0)))
+	assert.Must(inMigrationWindows(windows, clock(5, 59)))
+	assert.Must(!inMigrationWindows(windows, clock(6, 0)))
+	assert.Must(!inMigrationWindows(windows, clock(22, 29)))
+	assert.Must(inMigrationWindows(windows, clock(23, 0)))
+	assert.Must(inMigrationWindows(windows, clock(0, 15)))
+	assert.Must(!inMigrationWindows(windows, clock(0, 30)))
+
+	windows, err = parseMigrationWindows("")
+	assert.MustNoError(err)
+	assert.Must(inMigrationWindows(windows, clock(12, 0)))
+}
+
+func TestMigrationBandwidth(x *testing.T) {
+	c := *config
+	c.MigrationWindows = "00:00-00:01"
+	assert.Must(c.Validate() == nil)
+	c.MigrationWindows = "00:00"
+	assert.Must(c.Validate() != nil)
+
+	t := openTopom()
+	defer t.Close()
+
+	//两个slot共用带宽，每秒1000字节
+	d1 := t.reserveMigrationBandwidth(500, 1000)
+	d2 := t.reserveMigrationBandwidth(500, 1000)
+	assert.Must(d1 > time.Millisecond*400 && d1 <= time.Millisecond*500)
+	assert.Must(d2 > time.Millisecond*900 && d2 <= time.Second)
+
+	t.action.throttle.next = time.Time{}
+	t.startSlotProgress(1)
+	t.action.progress.slots[1].bytesPerKey = 10
+	t.updateSlotProgress(1, 10, 0)
+
+	var bandwidth = t.config.MigrationMaxBandwidth
+	defer func() {
+		t.config.MigrationMaxBandwidth = bandwidth
+	}()
+	t.config.MigrationMaxBandwidth = 1000
+	var start = time.Now()
+	t.waitMigrationBandwidth(1)
+	assert.Must(time.Since(start) >= time.Millisecond*90)
+	//已经计入的key不会重复限速
+	start = time.Now()
+	t.waitMigrationBandwidth(1)
+	assert.Must(time.Since(start) < time.Millisecond*50)
+}
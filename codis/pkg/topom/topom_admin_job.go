@@ -0,0 +1,207 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type RebalanceDetail struct {
+	ByLoad   bool        `json:"by_load,omitempty"`
+	Plans    map[int]int `json:"plans"`
+	Migrated int         `json:"migrated"`
+}
+
+type ResyncGroupDetail struct {
+	Groups []int `json:"groups"`
+	Synced int   `json:"synced"`
+}
+
+const (
+	JobTypeRebalance   = "rebalance"
+	JobTypeResyncGroup = "resync-group-all"
+
+	RebalanceStepMigrating = "migrating"
+	ResyncGroupStepSyncing = "syncing"
+)
+
+//创建rebalance的迁移任务并在后台跟踪迁移进度，取消时移除尚未开始的迁移
+func (s *Topom) SlotsRebalanceJob(byLoad bool) (int, error) {
+	var plans map[int]int
+	var err error
+	if byLoad {
+		plans, err = s.SlotsRebalanceByLoad(true)
+	} else {
+		plans, err = s.SlotsRebalance(true)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	detail := &RebalanceDetail{ByLoad: byLoad, Plans: plans}
+	j := s.newJob(JobTypeRebalance, detail)
+	if len(plans) == 0 {
+		j.finish(nil)
+		return j.Id, nil
+	}
+
+	var slots []int
+	for sid := range plans {
+		slots = append(slots, sid)
+	}
+	sort.Ints(slots)
+
+	j.onCancel(func() error {
+		n, err := s.removePendingSlotActions(slots)
+		if err != nil {
+			return err
+		}
+		log.Warnf("rebalance: job-[%d] cancelled, %d pending slot actions removed", j.Id, n)
+		return nil
+	})
+	j.update(RebalanceStepMigrating, 0)
+	log.Warnf("rebalance: job-[%d] created, migrate %d slots", j.Id, len(plans))
+
+	go s.trackRebalanceJob(j, detail)
+	return j.Id, nil
+}
+
+func (s *Topom) trackRebalanceJob(j *Job, detail *RebalanceDetail) {
+	var ticker = time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !j.done() {
+		select {
+		case <-s.exit.C:
+			return
+		case <-ticker.C:
+		}
+
+		migrated, failed, err := s.countPlannedSlots(detail.Plans)
+		if err != nil {
+			if err == ErrClosedTopom {
+				return
+			}
+			log.WarnErrorf(err, "rebalance: job-[%d] check slots failed", j.Id)
+			continue
+		}
+		if failed != 0 {
+			j.finish(errors.Errorf("%d slots are not migrated as planned", failed))
+			return
+		}
+		j.updateDetail(func() {
+			detail.Migrated = migrated
+		})
+		if migrated == len(detail.Plans) {
+			j.finish(nil)
+			log.Warnf("rebalance: job-[%d] done", j.Id)
+			return
+		}
+		j.update(RebalanceStepMigrating, migrated*100/len(detail.Plans))
+	}
+}
+
+//返回已迁移到计划group的slot数量，以及迁移被取消的slot数量
+func (s *Topom) countPlannedSlots(plans map[int]int) (migrated, failed int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, 0, err
+	}
+	for sid, gid := range plans {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case m.Action.State != models.ActionNothing:
+		case m.GroupId == gid:
+			migrated++
+		default:
+			failed++
+		}
+	}
+	return migrated, failed, nil
+}
+
+//移除slots中处于pending状态的迁移，正在迁移的slot不受影响
+func (s *Topom) removePendingSlotActions(slots []int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	for _, sid := range slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return n, err
+		}
+		if m.Action.State != models.ActionPending {
+			continue
+		}
+		defer s.dirtySlotsCache(m.Id)
+
+		m = &models.SlotMapping{
+			Id:      m.Id,
+			GroupId: m.GroupId,
+		}
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+//在后台逐个group下发slot信息，取消后不再处理剩余的group
+func (s *Topom) ResyncGroupAllJob() (int, error) {
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return 0, err
+	}
+	var groupIds []int
+	for _, g := range ctx.group {
+		if ctx.isGroupInUse(g.Id) {
+			groupIds = append(groupIds, g.Id)
+		}
+	}
+	s.mu.Unlock()
+	sort.Ints(groupIds)
+
+	detail := &ResyncGroupDetail{Groups: groupIds}
+	j := s.newJob(JobTypeResyncGroup, detail)
+	j.onCancel(func() error {
+		return nil
+	})
+	j.update(ResyncGroupStepSyncing, 0)
+
+	go func() {
+		for i, gid := range groupIds {
+			if j.done() {
+				log.Warnf("resync-group-all: job-[%d] cancelled, %d groups synced", j.Id, i)
+				return
+			}
+			if err := s.ResyncGroup(gid); err != nil {
+				log.WarnErrorf(err, "resync-group-all: job-[%d] group-[%d] failed", j.Id, gid)
+				j.finish(err)
+				return
+			}
+			j.updateDetail(func() {
+				detail.Synced = i + 1
+			})
+			j.update(ResyncGroupStepSyncing, (i+1)*100/len(groupIds))
+		}
+		j.finish(nil)
+	}()
+	return j.Id, nil
+}
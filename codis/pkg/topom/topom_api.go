@@ -6,6 +6,7 @@ package topom
 import (
 	"fmt"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -55,6 +56,8 @@ func newApiServer(t *Topom) http.Handler {
 	m.Use(func(c martini.Context, w http.ResponseWriter) {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	})
+	m.Use(newApiGuard(t))
+	m.Use(newApiAudit(t))
 
 	api := &apiServer{topom: t}
 
@@ -77,27 +80,36 @@ func newApiServer(t *Topom) http.Handler {
 		r.Get("/model", api.Model)
 		r.Get("/stats", api.StatsNoXAuth)
 		r.Get("/slots", api.SlotsNoXAuth)
+		r.Get("/discovery", api.DiscoveryNoXAuth)
+		r.Get("/summary", api.Summary)
+		r.Get("/products", api.Products)
 	})
 	r.Group("/api/topom", func(r martini.Router) {
 		r.Get("/model", api.Model)
+		r.Get("/openapi", api.OpenAPI)
 		r.Get("/xping/:xauth", api.XPing)
 		r.Get("/stats/:xauth", api.Stats)
 		r.Get("/slots/:xauth", api.Slots)
 		r.Put("/reload/:xauth", api.Reload)
 		r.Put("/shutdown/:xauth", api.Shutdown)
 		r.Put("/loglevel/:xauth/:value", api.LogLevel)
+		r.Get("/audit/:xauth", api.AuditLog)
 		r.Group("/proxy", func(r martini.Router) {
 			r.Put("/create/:xauth/:addr", api.CreateProxy)
 			r.Put("/online/:xauth/:addr", api.OnlineProxy)
 			r.Put("/reinit/:xauth/:token", api.ReinitProxy)
 			r.Put("/remove/:xauth/:token/:force", api.RemoveProxy)
 			r.Get("/cmdstats-all/:xauth/:token", api.CmdStatsAll)
+			r.Get("/discovery/:xauth", api.Discovery)
 		})
 		r.Group("/group", func(r martini.Router) {
 			r.Put("/create/:xauth/:gid", api.CreateGroup)
 			r.Put("/remove/:xauth/:gid", api.RemoveGroup)
+			r.Put("/decommission/:xauth/:gid", api.GroupDecommission)
+			r.Get("/health/:xauth", api.GroupHealth)
 			r.Put("/resync/:xauth/:gid", api.ResyncGroup)
 			r.Put("/resync-all/:xauth", api.ResyncGroupAll)
+			r.Put("/resync-all-async/:xauth", api.ResyncGroupAllJob)
 			r.Put("/add/:xauth/:gid/:addr", api.GroupAddServer)
 			r.Put("/add/:xauth/:gid/:addr/:datacenter", api.GroupAddServer)
 			r.Put("/del/:xauth/:gid/:addr", api.GroupDelServer)
@@ -105,6 +117,9 @@ func newApiServer(t *Topom) http.Handler {
 			r.Put("/promote/:xauth/:gid/:addr/:force", api.GroupPromoteServer)
 			r.Put("/replica-groups/:xauth/:gid/:addr/:value", api.EnableReplicaGroups)
 			r.Put("/replica-groups-all/:xauth/:value", api.EnableReplicaGroupsAll)
+			r.Get("/memory/:xauth/:gid", api.GroupGetMemoryPolicy)
+			r.Put("/memory/:xauth/:gid/:maxmemory/:policy", api.GroupSetMemoryPolicy)
+			r.Put("/template/:xauth/:gid/:name", api.GroupApplyTemplate)
 			r.Group("/action", func(r martini.Router) {
 				r.Put("/create/:xauth/:addr", api.SyncCreateAction)
 				r.Put("/remove/:xauth/:addr", api.SyncRemoveAction)
@@ -120,10 +135,31 @@ func newApiServer(t *Topom) http.Handler {
 				r.Put("/remove-all/:xauth", api.SlotRemoveActionAll)
 				r.Put("/interval/:xauth/:value", api.SetSlotActionInterval)
 				r.Put("/disabled/:xauth/:value", api.SetSlotActionDisabled)
+				r.Get("/queue/:xauth", api.SlotActionQueue)
+				r.Put("/priority/:xauth/:sid/:priority", api.SlotActionSetPriority)
+				r.Put("/priority-group/:xauth/:gid/:priority", api.SlotActionSetGroupPriority)
+				r.Put("/reorder/:xauth", binding.Json([]int{}), api.SlotActionReorder)
 			})
 			r.Put("/assign/:xauth", binding.Json([]*models.SlotMapping{}), api.SlotsAssignGroup)
 			r.Put("/assign/:xauth/offline", binding.Json([]*models.SlotMapping{}), api.SlotsAssignOffline)
+			r.Get("/rebalance/:xauth", api.SlotsRebalancePreview)
+			r.Put("/rebalance/:xauth", api.SlotsRebalancePreview)
 			r.Put("/rebalance/:xauth/:confirm", api.SlotsRebalance)
+			r.Put("/rebalance-load/:xauth/:confirm", api.SlotsRebalanceByLoad)
+			r.Put("/rebalance-async/:xauth", api.SlotsRebalanceJob)
+			r.Put("/rebalance-async/:xauth/:load", api.SlotsRebalanceJob)
+			r.Get("/heat/:xauth", api.SlotHeat)
+			r.Get("/progress/:xauth", api.SlotsProgress)
+			r.Get("/history/:xauth/:sid", api.SlotHistory)
+			r.Put("/plan/create/:xauth/:load", api.SlotsMigrationPlan)
+			r.Get("/plan/:xauth/:pid", api.GetMigrationPlan)
+			r.Put("/plan/apply/:xauth/:pid/:step", api.SlotsMigrationPlanApply)
+			r.Put("/plan/apply-all/:xauth/:pid", api.SlotsMigrationPlanApplyAll)
+			r.Put("/plan/weighted/:xauth", binding.Json(RebalanceWeights{}), api.SlotsWeightedPlan)
+			r.Put("/scale-out/:xauth", binding.Json(ScaleOutRequest{}), api.ScaleOut)
+			r.Get("/verify/:xauth", api.SlotVerifyReports)
+			r.Get("/verify/:xauth/:all", api.SlotVerifyReports)
+			r.Put("/verify/clear/:xauth/:sid", api.SlotVerifyClear)
 		})
 		r.Group("/sentinels", func(r martini.Router) {
 			r.Put("/add/:xauth/:addr", api.AddSentinel)
@@ -134,9 +170,112 @@ func newApiServer(t *Topom) http.Handler {
 			r.Get("/info/:addr", api.InfoSentinel)
 			r.Get("/info/:addr/monitored", api.InfoSentinelMonitored)
 		})
+		r.Group("/scaling", func(r martini.Router) {
+			r.Get("/status/:xauth", api.Scaling)
+			r.Put("/start/:xauth", binding.Json(ScalingRequest{}), api.StartScaling)
+			r.Put("/abort/:xauth", api.AbortScaling)
+			r.Put("/resume/:xauth", api.ResumeScaling)
+		})
+		r.Group("/jobs", func(r martini.Router) {
+			r.Get("/:xauth", api.ListJobs)
+			r.Get("/:xauth/:id", api.GetJob)
+			r.Put("/cancel/:xauth/:id", api.CancelJob)
+		})
+		r.Group("/clone", func(r martini.Router) {
+			r.Put("/create/:xauth", binding.Json(CloneRequest{}), api.CloneProduct)
+			r.Put("/finish/:xauth/:id", api.CloneFinish)
+		})
+		r.Group("/report", func(r martini.Router) {
+			r.Get("/json/:xauth/:period", api.Report)
+			r.Get("/html/:xauth/:period", api.ReportHTML)
+		})
+		r.Group("/backup", func(r martini.Router) {
+			r.Put("/verify/:xauth", binding.Json(BackupVerifyRequest{}), api.BackupVerifyJob)
+			r.Get("/verify/:xauth", api.BackupVerifyReports)
+		})
+		r.Group("/keyspace", func(r martini.Router) {
+			r.Put("/diff/:xauth", binding.Json(KeyspaceDiffRequest{}), api.KeyspaceDiffJob)
+			r.Get("/diff/:xauth", api.KeyspaceDiffReports)
+		})
+		r.Group("/topology", func(r martini.Router) {
+			r.Put("/plan/:xauth", binding.Json(DesiredState{}), api.TopologyPlan)
+			r.Get("/plan/:xauth/:pid", api.GetTopologyPlan)
+			r.Put("/apply/:xauth/:pid", api.TopologyApply)
+		})
+		r.Group("/chaos", func(r martini.Router) {
+			r.Get("/:xauth", api.ChaosFaults)
+			r.Put("/master-down/:xauth/:gid/:secs", api.ChaosMasterDown)
+			r.Put("/blackhole/:xauth/:token/:addr/:secs", api.ChaosBlackhole)
+			r.Put("/sentinel-delay/:xauth/:delay/:secs", api.ChaosSentinelDelay)
+			r.Put("/recover/:xauth/:id", api.ChaosRecover)
+		})
+		r.Group("/switchover", func(r martini.Router) {
+			r.Get("/status/:xauth", api.SwitchoverStatus)
+			r.Put("/start/:xauth/:product", api.SwitchoverStart)
+			r.Put("/rollback/:xauth", api.SwitchoverRollback)
+		})
+		r.Group("/standby", func(r martini.Router) {
+			r.Get("/status/:xauth", api.StandbyStatus)
+			r.Put("/set/:xauth/:product", api.SetStandby)
+			r.Put("/promote/:xauth", api.PromoteStandby)
+		})
+		r.Group("/master", func(r martini.Router) {
+			r.Get("/sync/:xauth", api.MasterSync)
+			r.Put("/sync/:xauth/:confirm", api.MasterSync)
+		})
+		r.Group("/replication", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ReplicationLinks)
+			r.Put("/create/:xauth/:product", api.CreateReplicationLink)
+			r.Put("/remove/:xauth/:product", api.RemoveReplicationLink)
+			r.Put("/promote/:xauth/:product", api.PromoteReplicationSecondary)
+			r.Put("/promote/:xauth/:product/:force", api.PromoteReplicationSecondary)
+			r.Put("/cutover/:xauth/:product", api.ReplicationCutover)
+			r.Put("/cutover/:xauth/:product/:timeout", api.ReplicationCutover)
+		})
+		r.Group("/template", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ListConfigTemplate)
+			r.Put("/update/:xauth", binding.Json(models.ConfigTemplate{}), api.UpdateConfigTemplate)
+			r.Put("/remove/:xauth/:name", api.RemoveConfigTemplate)
+			r.Get("/drift/:xauth", api.ConfigTemplateDrift)
+		})
+		r.Group("/quota", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ListKeyQuota)
+			r.Put("/update/:xauth", binding.Json(models.KeyQuota{}), api.UpdateKeyQuota)
+			r.Put("/remove/:xauth", binding.Json(models.KeyQuota{}), api.RemoveKeyQuota)
+		})
+		r.Group("/route", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ListPrefixRoute)
+			r.Put("/update/:xauth", binding.Json(models.PrefixRoute{}), api.UpdatePrefixRoute)
+			r.Put("/remove/:xauth", binding.Json(models.PrefixRoute{}), api.RemovePrefixRoute)
+		})
+		r.Group("/ttlrule", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ListTTLRule)
+			r.Put("/update/:xauth", binding.Json(models.TTLRule{}), api.UpdateTTLRule)
+			r.Put("/remove/:xauth", binding.Json(models.TTLRule{}), api.RemoveTTLRule)
+		})
+		r.Group("/namespace", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ListNamespace)
+			r.Get("/stats/:xauth", api.NamespaceStats)
+			r.Put("/update/:xauth", binding.Json(models.Namespace{}), api.UpdateNamespace)
+			r.Put("/remove/:xauth", binding.Json(models.Namespace{}), api.RemoveNamespace)
+		})
+		r.Group("/acl", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ListACLUser)
+			r.Put("/update/:xauth", binding.Json(models.ACLUser{}), api.UpdateACLUser)
+			r.Put("/remove/:xauth", binding.Json(models.ACLUser{}), api.RemoveACLUser)
+		})
+		r.Group("/filter", func(r martini.Router) {
+			r.Get("/get/:xauth", api.RequestFilter)
+			r.Put("/update/:xauth", binding.Json(models.RequestFilter{}), api.UpdateRequestFilter)
+		})
 		r.Group("/configset", func(r martini.Router) {
 			r.Put("/:xauth/:key/:value", api.SetConfig)
 		})
+		r.Group("/config", func(r martini.Router) {
+			r.Get("/list/:xauth", api.ListConfigOverride)
+			r.Put("/update/:xauth", binding.Json(models.ConfigOverrides{}), api.UpdateConfig)
+			r.Put("/set/:xauth/:key/:value", api.SetConfig)
+		})
 		r.Group("/docmd", func(r martini.Router) {
 			r.Get("/:xauth/:addr/:cmd", api.ExecCmd)
 		})
@@ -159,6 +298,8 @@ func newApiServer(t *Topom) http.Handler {
 		})
 	})
 
+	reserveProductNames(r.All())
+
 	m.MapTo(r, (*martini.Routes)(nil))
 	m.Action(r.Handle)
 	return m
@@ -178,13 +319,15 @@ func (s *apiServer) verifyXAuth(params martini.Params) error {
 	return nil
 }
 
-func (s *apiServer) Overview() (int, string) {
+func (s *apiServer) Overview(req *http.Request) (int, string) {
 	o, err := s.topom.Overview()
 	if err != nil {
 		return rpc.ApiResponseError(err)
-	} else {
-		return rpc.ApiResponseJson(o)
 	}
+	if err := pageProxyStats(o.Stats, req); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJsonFields(o, req)
 }
 
 func (s *apiServer) QueryInfluxdb(params martini.Params) (int, string) {
@@ -207,12 +350,37 @@ func (s *apiServer) Model() (int, string) {
 	return rpc.ApiResponseJson(s.topom.Model())
 }
 
-func (s *apiServer) StatsNoXAuth() (int, string) {
-	if stats, err := s.topom.Stats(); err != nil {
+func (s *apiServer) StatsNoXAuth(req *http.Request) (int, string) {
+	stats, err := s.topom.Stats()
+	if err != nil {
 		return rpc.ApiResponseError(err)
-	} else {
-		return rpc.ApiResponseJson(stats)
 	}
+	if err := pageProxyStats(stats, req); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJsonFields(stats, req)
+}
+
+//按proxy_offset、proxy_limit对proxy分页，只返回当前页proxy的统计
+func pageProxyStats(stats *Stats, req *http.Request) error {
+	offset, limit, err := rpc.ParsePage(req, "proxy_")
+	if err != nil {
+		return err
+	}
+	if offset == 0 && limit == 0 {
+		return nil
+	}
+	beg, end := rpc.PageBounds(len(stats.Proxy.Models), offset, limit)
+	var m = make(map[string]*ProxyStats)
+	for _, p := range stats.Proxy.Models[beg:end] {
+		if v := stats.Proxy.Stats[p.Token]; v != nil {
+			m[p.Token] = v
+		}
+	}
+	stats.Proxy.Total = len(stats.Proxy.Models)
+	stats.Proxy.Models = stats.Proxy.Models[beg:end]
+	stats.Proxy.Stats = m
+	return nil
 }
 
 func (s *apiServer) SlotsNoXAuth() (int, string) {
@@ -223,6 +391,31 @@ func (s *apiServer) SlotsNoXAuth() (int, string) {
 	}
 }
 
+//health与datacenter参数用于过滤，例如?health=healthy&datacenter=dc1
+func (s *apiServer) DiscoveryNoXAuth(req *http.Request) (int, string) {
+	if list, err := s.topom.DiscoverProxies(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		query := req.URL.Query()
+		return rpc.ApiResponseJson(filterDiscoveryProxies(list, query.Get("health"), query.Get("datacenter")))
+	}
+}
+
+func (s *apiServer) Summary() (int, string) {
+	if x, err := s.topom.Summary(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(x)
+	}
+}
+
+func (s *apiServer) Discovery(params martini.Params, req *http.Request) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return s.DiscoveryNoXAuth(req)
+}
+
 func (s *apiServer) XPing(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
@@ -231,11 +424,11 @@ func (s *apiServer) XPing(params martini.Params) (int, string) {
 	}
 }
 
-func (s *apiServer) Stats(params martini.Params) (int, string) {
+func (s *apiServer) Stats(params martini.Params, req *http.Request) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return s.StatsNoXAuth()
+		return s.StatsNoXAuth(req)
 	}
 }
 
@@ -274,6 +467,14 @@ func (s *apiServer) parseToken(params martini.Params) (string, error) {
 	return token, nil
 }
 
+func (s *apiServer) parseString(params martini.Params, entry string) (string, error) {
+	text := params[entry]
+	if text == "" {
+		return "", fmt.Errorf("missing %s", entry)
+	}
+	return text, nil
+}
+
 func (s *apiServer) parseInteger(params martini.Params, entry string) (int, error) {
 	text := params[entry]
 	if text == "" {
@@ -397,6 +598,32 @@ func (s *apiServer) RemoveGroup(params martini.Params) (int, string) {
 	}
 }
 
+func (s *apiServer) GroupDecommission(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	gid, err := s.parseInteger(params, "gid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if id, err := s.topom.GroupDecommission(gid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(id)
+	}
+}
+
+func (s *apiServer) GroupHealth(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if health, err := s.topom.GroupHealth(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(encodeGroupHealth(health))
+	}
+}
+
 func (s *apiServer) ResyncGroup(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
@@ -537,824 +764,2374 @@ func (s *apiServer) EnableReplicaGroupsAll(params martini.Params) (int, string)
 	}
 }
 
-func (s *apiServer) AddSentinel(params martini.Params) (int, string) {
+func (s *apiServer) GroupGetMemoryPolicy(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	addr, err := s.parseAddr(params)
+	gid, err := s.parseInteger(params, "gid")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.AddSentinel(addr); err != nil {
+	if p, err := s.topom.GroupGetMemoryPolicy(gid); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(p)
 	}
 }
 
-func (s *apiServer) DelSentinel(params martini.Params) (int, string) {
+func (s *apiServer) GroupSetMemoryPolicy(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	addr, err := s.parseAddr(params)
+	gid, err := s.parseInteger(params, "gid")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	force, err := s.parseInteger(params, "force")
+	maxmemory, err := s.parseString(params, "maxmemory")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.DelSentinel(addr, force != 0); err != nil {
+	policy, err := s.parseString(params, "policy")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.GroupSetMemoryPolicy(gid, maxmemory, policy); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) ResyncSentinels(params martini.Params) (int, string) {
+func (s *apiServer) GroupApplyTemplate(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.ResyncSentinels(); err != nil {
+	gid, err := s.parseInteger(params, "gid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	name, err := s.parseString(params, "name")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.GroupApplyTemplate(gid, name); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) SentinelRemoveGroupsAll(params martini.Params) (int, string) {
+func (s *apiServer) ListConfigTemplate(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SentinelRemoveGroupsAll(); err != nil {
+	if list, err := s.topom.ListConfigTemplate(); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(list)
 	}
 }
 
-func (s *apiServer) SentinelRemoveGroup(params martini.Params) (int, string) {
+func (s *apiServer) UpdateConfigTemplate(t models.ConfigTemplate, params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
+	if err := s.topom.UpdateConfigTemplate(&t); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
 
-	gid, err := s.parseInteger(params, "gid")
-	if err != nil {
+func (s *apiServer) ListKeyQuota(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
+	return rpc.ApiResponseJson(s.topom.KeyQuotas())
+}
 
-	if err := s.topom.SentinelRemoveGroup(gid); err != nil {
+func (s *apiServer) UpdateKeyQuota(q models.KeyQuota, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.UpdateKeyQuota(&q); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) InfoServer(params martini.Params) (int, string) {
-	addr, err := s.parseAddr(params)
-	if err != nil {
-		return rpc.ApiResponseError(err)
-	}
-	c, err := redis.NewClient(addr, s.topom.Config().ProductAuth, time.Second)
-	if err != nil {
-		log.WarnErrorf(err, "create redis client to %s failed", addr)
+func (s *apiServer) RemoveKeyQuota(q models.KeyQuota, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	defer c.Close()
-	if info, err := c.InfoFull(); err != nil {
+	if err := s.topom.RemoveKeyQuota(q.Prefix); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson(info)
+		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) InfoSentinel(params martini.Params) (int, string) {
-	addr, err := s.parseAddr(params)
-	if err != nil {
+func (s *apiServer) ListPrefixRoute(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	c, err := redis.NewClientNoAuth(addr, time.Second)
-	if err != nil {
-		log.WarnErrorf(err, "create redis client to %s failed", addr)
+	return rpc.ApiResponseJson(s.topom.PrefixRoutes())
+}
+
+func (s *apiServer) UpdatePrefixRoute(r models.PrefixRoute, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	defer c.Close()
-	if info, err := c.Info(); err != nil {
+	if err := s.topom.UpdatePrefixRoute(&r); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson(info)
+		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) InfoSentinelMonitored(params martini.Params) (int, string) {
-	addr, err := s.parseAddr(params)
-	if err != nil {
+func (s *apiServer) RemovePrefixRoute(r models.PrefixRoute, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	sentinel := redis.NewSentinel(s.topom.Config().ProductName, s.topom.Config().ProductAuth)
-	if info, err := sentinel.MastersAndSlaves(addr, s.topom.Config().SentinelClientTimeout.Duration()); err != nil {
+	if err := s.topom.RemovePrefixRoute(r.Prefix); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson(info)
+		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) SetConfig(params martini.Params) (int, string) {
+func (s *apiServer) ListTTLRule(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
+	return rpc.ApiResponseJson(s.topom.TTLRules())
+}
 
-	key := params["key"]
-	if key == "" {
-		return rpc.ApiResponseError(errors.New("invalid key"))
+func (s *apiServer) UpdateTTLRule(t models.TTLRule, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
 	}
-	value := params["value"]
-	
-	var nextStep string
-	var err error
-	if nextStep, err = s.topom.SetConfig(key, value); err != nil {
+	if err := s.topom.UpdateTTLRule(&t); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		switch nextStep {
-		case "influxdb":
-			break
-		case "migrate":
-			break
-		case "log":
-			break
-		case "sentinel":
-			if err = s.topom.ResyncSentinels(); err != nil {
-				return rpc.ApiResponseError(err)
-			}
-		default:
-			log.Warnf("invalid return value %s", nextStep)
-			return rpc.ApiResponseError(errors.New("missing nextStep"))
-		}
+		return rpc.ApiResponseJson("OK")
 	}
-	return rpc.ApiResponseJson("OK")
 }
 
-func (s *apiServer) ExecCmd(params martini.Params) (int, string) {
+func (s *apiServer) RemoveTTLRule(t models.TTLRule, params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	addr := params["addr"]
-	original_cmd := params["cmd"]
-	cmd := strings.ToUpper(original_cmd)
-	if addr == "" || cmd == "" {
-		return rpc.ApiResponseError(errors.New("invalid params"))
-	}
-	if (!cmdIsAllow(cmd)) {
-		return rpc.ApiResponseError(errors.New("CMD: " + original_cmd + " is not allowed"))
-	}
-
-	c, err := redis.NewClient(addr, s.topom.Config().ProductAuth, time.Second)
-	if err != nil {
-		log.WarnErrorf(err, "create redis client to %s failed", addr)
+	if err := s.topom.RemoveTTLRule(t.Prefix); err != nil {
 		return rpc.ApiResponseError(err)
-	}
-	defer c.Close()
-
-	if cmd == "INFO" || cmd == "CLIENT LIST" || cmd == "INFO DELAY" {
-		if text, err := c.DoCmd_string(original_cmd); err != nil {
-			return rpc.ApiResponseError(err)
-		} else {
-			return rpc.ApiResponseJson(text)
-		}
-	} else if (cmd == "SLOWLOG GET 1000" || cmd == "XSLOWLOG GET 1000") {
-		if text, err := c.SlowLog(original_cmd); err != nil {
-			return rpc.ApiResponseError(err)
-		} else {
-			return rpc.ApiResponseJson(text)
-		}
-	} else if (cmd == "CONFIG GET *" || cmd == "XCONFIG GET *") {
-		var resp = "";
-		if strings, err := c.DoCmd_strings(original_cmd); err != nil {
-			return rpc.ApiResponseError(err)
-		} else {
-			if cmd == "CONFIG GET *" {
-				for i:=0; i<len(strings)-1; i++ {
-					if (strings[i] == "requirepass" || strings[i] == "masterauth" || strings[i] == "userpass") {
-						strings[i+1] = "******"
-					}
-				}
-			}
-			for i:=0; i<len(strings); i++ {
-				if (i%2 == 0) {
-					resp += strings[i] + ": "
-				} else  {
-					resp += strings[i] + "\r\n"
-				}
-			}
-			return rpc.ApiResponseJson(resp)
-		}
 	} else {
-		return rpc.ApiResponseError(errors.New("Cmd not allowed."))
+		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func cmdIsAllow(cmd string) bool {
-	cmd = strings.ToUpper(cmd)
-	for _, val := range cmdWhiteList {
-		if (cmd == val || strings.Index(cmd, val) == 0) {
-			return true 
-		}
+func (s *apiServer) ListNamespace(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
 	}
-	return false
+	return rpc.ApiResponseJson(s.topom.Namespaces())
 }
 
-func (s *apiServer) ExpansionPullPlan(params martini.Params) (int, string) {
+func (s *apiServer) NamespaceStats(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	
-	resp := s.topom.ExpansionPullPlan()
-	return rpc.ApiResponseJson(resp)
+	if stats, err := s.topom.NamespaceStats(time.Second * 5); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(stats)
+	}
 }
 
-func (s *apiServer) ExpansionAddPlan(params martini.Params) (int, string) {
+func (s *apiServer) UpdateNamespace(ns models.Namespace, params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	newPlan := params["plan"]
-	if newPlan == "" {
-		return rpc.ApiResponseError(errors.New("plan is nil"))
+	if err := s.topom.UpdateNamespace(&ns); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
 	}
+}
 
-	if err := s.topom.ExpansionAddPlan(newPlan); err != nil {
+func (s *apiServer) RemoveNamespace(ns models.Namespace, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.RemoveNamespace(ns.Name); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) ExpansionDataSync(params martini.Params) (int, string) {
+func (s *apiServer) ListACLUser(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	planId, err := s.parseInteger(params, "planid")
-	if err != nil {
+	return rpc.ApiResponseJson(s.topom.ACLUsers())
+}
+
+func (s *apiServer) UpdateACLUser(u models.ACLUser, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	if err := s.topom.ExpansionDataSync(planId); err != nil {
+	if err := s.topom.UpdateACLUser(&u); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) ExpansionBackup(params martini.Params) (int, string) {
+func (s *apiServer) RemoveACLUser(u models.ACLUser, params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	force, err := s.parseInteger(params, "force")
-	if err != nil {
+	if err := s.topom.RemoveACLUser(u.Name); err != nil {
 		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
 	}
+}
 
-	planId, err := s.parseInteger(params, "planid")
-	if err != nil {
+func (s *apiServer) RequestFilter(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
+	return rpc.ApiResponseJson(s.topom.RequestFilter())
+}
 
-	if err := s.topom.ExpansionBackup(planId, force); err != nil {
+func (s *apiServer) UpdateRequestFilter(filter models.RequestFilter, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.UpdateRequestFilter(filter.Script); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) ExpansionSlotsMgrt(params martini.Params) (int, string) {
+func (s *apiServer) RemoveConfigTemplate(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	planId, err := s.parseInteger(params, "planid")
+	name, err := s.parseString(params, "name")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	if err := s.topom.ExpansionSlotsMgrt(planId); err != nil {
+	if err := s.topom.RemoveConfigTemplate(name); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) ExpansionDateClean(params martini.Params) (int, string) {
+func (s *apiServer) ConfigTemplateDrift(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	planId, err := s.parseInteger(params, "planid")
-	if err != nil {
+	if drifts, err := s.topom.ConfigTemplateDrift(); err != nil {
 		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(drifts)
 	}
+}
 
-	if err := s.topom.ExpansionDateClean(planId); err != nil {
+func (s *apiServer) ListJobs(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
-	} else {
-		return rpc.ApiResponseJson("OK")
 	}
+	return rpc.ApiResponseJson(s.topom.ListJobs())
 }
 
-func (s *apiServer) ExpansionGroupDateClean(params martini.Params) (int, string) {
+func (s *apiServer) GetJob(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	gid, err := s.parseInteger(params, "gid")
+	id, err := s.parseInteger(params, "id")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	if err := s.topom.ExpansionGroupDateClean(gid); err != nil {
+	if j, err := s.topom.GetJob(id); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(j)
 	}
 }
 
-func (s *apiServer) ExpansionDelPlan(params martini.Params) (int, string) {
+func (s *apiServer) CancelJob(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	gid, err := s.parseInteger(params, "planid")
+	id, err := s.parseInteger(params, "id")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	if err := s.topom.ExpansionDelPlan(gid); err != nil {
+	if err := s.topom.CancelJob(id); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) UpgrateUploadDashboard(r *http.Request, params martini.Params) (int, string) {
+func (s *apiServer) ResyncGroupAllJob(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	path := params["path"]
-	if err := DownLoadFile(r, path, "codis-dashboard"); err != nil {
+	if id, err := s.topom.ResyncGroupAllJob(); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(id)
 	}
 }
 
-func (s *apiServer) UpgrateUploadConfig(r *http.Request, params martini.Params) (int, string) {
+func (s *apiServer) SlotsRebalanceJob(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	path := params["path"]
-	if path == "" {
-		return rpc.ApiResponseError(errors.New("path is invalid!"))
+	load := 0
+	if params["load"] != "" {
+		n, err := s.parseInteger(params, "load")
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		load = n
 	}
-	if err := DownLoadFile(r, path, "dashboard.toml"); err != nil {
+	if id, err := s.topom.SlotsRebalanceJob(load != 0); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(id)
 	}
 }
 
-func DownLoadFile(r *http.Request, path, filename string) error {
-	if path == "" {
-		return errors.New("path is invalid!")
+func (s *apiServer) CloneProduct(req CloneRequest, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
 	}
-
-	//create a new path if not exist
-	_, err := os.Stat(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			err := os.Mkdir("./" + path, os.ModePerm)
-			if err != nil{
-				return err
-			}
-		} else {
-			return err
-		}
+	if id, err := s.topom.CloneProduct(&req); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(id)
 	}
+}
 
-	reader, err := r.MultipartReader()
-	if err != nil {
-		return err
+func (s *apiServer) Report(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
 	}
-
-	dst, _ := os.Create("./" + path + "/" + filename)
-	defer dst.Close()
-
-	for {
-		part, err := reader.NextPart()
-		if err == io.EOF {
-			break
-		}
-
-		log.Warnf("FileName=[%s], FormName=[%s]", part.FileName(), part.FormName())
-		if part.FileName() == "" {  // this is FormData
-			return errors.New("filename is invalid!")
-		} else {    // This is FileData
-			io.Copy(dst, part)
-		}
+	if r, err := s.topom.GenerateReport(params["period"]); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(r)
 	}
+}
 
-	if filename == "codis-dashboard" {
-		os.Chmod("./" + path + "/" + filename, 0775)
+func (s *apiServer) ReportHTML(w http.ResponseWriter, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
 	}
-
-	return nil
+	r, err := s.topom.GenerateReport(params["period"])
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	b, err := r.HTML()
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return 200, string(b)
 }
 
-func (s *apiServer) UpgrateStart(params martini.Params) (int, string) {
+func (s *apiServer) BackupVerifyJob(req BackupVerifyRequest, params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-
-	path := params["path"]
-	if path == "" {
-		return rpc.ApiResponseError(errors.New("path is invalid!"))
+	if id, err := s.topom.BackupVerifyJob(&req); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(id)
 	}
+}
 
-	_, err := os.Stat(path)
-	if err != nil {
+func (s *apiServer) BackupVerifyReports(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
+	return rpc.ApiResponseJson(s.topom.BackupVerifyReports())
+}
 
-	if err := s.topom.UpgrateStart(path); err != nil {
+func (s *apiServer) KeyspaceDiffJob(req KeyspaceDiffRequest, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if id, err := s.topom.KeyspaceDiffJob(&req); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(id)
 	}
 }
 
-func (s *apiServer) SyncCreateAction(params martini.Params) (int, string) {
+func (s *apiServer) KeyspaceDiffReports(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	addr, err := s.parseAddr(params)
-	if err != nil {
+	return rpc.ApiResponseJson(s.topom.KeyspaceDiffReports())
+}
+
+func (s *apiServer) TopologyPlan(desired DesiredState, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SyncCreateAction(addr); err != nil {
+	if p, err := s.topom.TopologyPlan(&desired); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(p)
 	}
 }
 
-func (s *apiServer) SyncRemoveAction(params martini.Params) (int, string) {
+func (s *apiServer) GetTopologyPlan(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	addr, err := s.parseAddr(params)
+	pid, err := s.parseInteger(params, "pid")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SyncRemoveAction(addr); err != nil {
+	if p, err := s.topom.GetTopologyPlan(pid); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(p)
 	}
 }
 
-func (s *apiServer) SlotCreateAction(params martini.Params) (int, string) {
+func (s *apiServer) ChaosFaults(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	sid, err := s.parseInteger(params, "sid")
-	if err != nil {
+	return rpc.ApiResponseJson(s.topom.ChaosFaults())
+}
+
+func (s *apiServer) ChaosMasterDown(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
 	gid, err := s.parseInteger(params, "gid")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SlotCreateAction(sid, gid); err != nil {
+	secs, err := s.parseInteger(params, "secs")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if f, err := s.topom.ChaosMasterDown(gid, time.Second*time.Duration(secs)); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(f)
 	}
 }
 
-func (s *apiServer) SlotCreateActionSome(params martini.Params) (int, string) {
+func (s *apiServer) ChaosBlackhole(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	groupFrom, err := s.parseInteger(params, "src")
+	token, err := s.parseToken(params)
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	groupTo, err := s.parseInteger(params, "dst")
+	addr, err := s.parseAddr(params)
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	numSlots, err := s.parseInteger(params, "num")
+	secs, err := s.parseInteger(params, "secs")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SlotCreateActionSome(groupFrom, groupTo, numSlots); err != nil {
+	if f, err := s.topom.ChaosBlackhole(token, addr, time.Second*time.Duration(secs)); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(f)
 	}
 }
 
-func (s *apiServer) SlotCreateActionRange(params martini.Params) (int, string) {
+//delay的单位为毫秒
+func (s *apiServer) ChaosSentinelDelay(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	beg, err := s.parseInteger(params, "beg")
+	delay, err := s.parseInteger(params, "delay")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	end, err := s.parseInteger(params, "end")
+	secs, err := s.parseInteger(params, "secs")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	gid, err := s.parseInteger(params, "gid")
+	if f, err := s.topom.ChaosSentinelDelay(time.Millisecond*time.Duration(delay), time.Second*time.Duration(secs)); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(f)
+	}
+}
+
+func (s *apiServer) ChaosRecover(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	id, err := s.parseInteger(params, "id")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SlotCreateActionRange(beg, end, gid, true); err != nil {
+	if err := s.topom.ChaosRecover(id); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) SlotRemoveAction(params martini.Params) (int, string) {
+//执行失败时通过GetTopologyPlan查看每个操作的结果
+func (s *apiServer) TopologyApply(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	sid, err := s.parseInteger(params, "sid")
+	pid, err := s.parseInteger(params, "pid")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SlotRemoveAction(sid); err != nil {
+	if p, err := s.topom.TopologyApply(pid); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		return rpc.ApiResponseJson("OK")
+		return rpc.ApiResponseJson(p)
 	}
 }
 
-func (s *apiServer) SlotRemoveActionAll(params martini.Params) (int, string) {
+func (s *apiServer) CloneFinish(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SlotRemoveActionAll(); err != nil {
+	id, err := s.parseInteger(params, "id")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.CloneFinish(id); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) LogLevel(params martini.Params) (int, string) {
+func (s *apiServer) SwitchoverStatus(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	v := params["value"]
-	if v == "" {
-		return rpc.ApiResponseError(errors.New("missing loglevel"))
+	return rpc.ApiResponseJson(s.topom.SwitchoverStatus())
+}
+
+func (s *apiServer) SwitchoverStart(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
 	}
-	if !log.SetLevelString(v) {
-		return rpc.ApiResponseError(errors.New("invalid loglevel"))
+	product, err := s.parseString(params, "product")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SwitchoverStart(product); err != nil {
+		return rpc.ApiResponseError(err)
 	} else {
-		log.Warnf("set loglevel to %s", v)
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) Shutdown(params martini.Params) (int, string) {
+func (s *apiServer) SwitchoverRollback(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.Close(); err != nil {
+	if err := s.topom.SwitchoverRollback(); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) SetSlotActionInterval(params martini.Params) (int, string) {
+func (s *apiServer) StandbyStatus(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	value, err := s.parseInteger(params, "value")
+	return rpc.ApiResponseJson(s.topom.StandbyStatus())
+}
+
+func (s *apiServer) SetStandby(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	product, err := s.parseString(params, "product")
 	if err != nil {
 		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SetStandby(product); err != nil {
+		return rpc.ApiResponseError(err)
 	} else {
-		s.topom.SetSlotActionInterval(value)
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) SetSlotActionDisabled(params martini.Params) (int, string) {
+func (s *apiServer) PromoteStandby(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	disabled, err := s.parseInteger(params, "value")
-	if err != nil {
+	if err := s.topom.PromoteStandby(); err != nil {
 		return rpc.ApiResponseError(err)
-	} else if (disabled < 0 || disabled > 2) {
-		return rpc.ApiResponseError(errors.New("invalid value to ActionDisabled (0 <= disabled <= 2)"))
 	} else {
-		s.topom.SetSlotActionDisabled(disabled)
 		return rpc.ApiResponseJson("OK")
 	}
 }
 
-func (s *apiServer) SlotsAssignGroup(slots []*models.SlotMapping, params martini.Params) (int, string) {
+func (s *apiServer) MasterSync(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SlotsAssignGroup(slots); err != nil {
+	var confirm int
+	if params["confirm"] != "" {
+		n, err := s.parseInteger(params, "confirm")
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		confirm = n
+	}
+	if diff, err := s.topom.MasterSync(confirm != 0); err != nil {
 		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(diff)
 	}
-	return rpc.ApiResponseJson("OK")
 }
 
-func (s *apiServer) SlotsAssignOffline(slots []*models.SlotMapping, params martini.Params) (int, string) {
+func (s *apiServer) ReplicationLinks(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if err := s.topom.SlotsAssignOffline(slots); err != nil {
+	return rpc.ApiResponseJson(s.topom.ReplicationLinks())
+}
+
+func (s *apiServer) CreateReplicationLink(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	return rpc.ApiResponseJson("OK")
+	product, err := s.parseString(params, "product")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.CreateReplicationLink(product); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
 }
 
-func (s *apiServer) SlotsRebalance(params martini.Params) (int, string) {
+func (s *apiServer) RemoveReplicationLink(params martini.Params) (int, string) {
 	if err := s.verifyXAuth(params); err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	confirm, err := s.parseInteger(params, "confirm")
+	product, err := s.parseString(params, "product")
 	if err != nil {
 		return rpc.ApiResponseError(err)
 	}
-	if plans, err := s.topom.SlotsRebalance(confirm != 0); err != nil {
+	if err := s.topom.RemoveReplicationLink(product); err != nil {
 		return rpc.ApiResponseError(err)
 	} else {
-		m := make(map[string]int)
-		for sid, gid := range plans {
-			m[strconv.Itoa(sid)] = gid
-		}
-		return rpc.ApiResponseJson(m)
+		return rpc.ApiResponseJson("OK")
 	}
 }
 
-type ApiClient struct {
-	addr  string
-	xauth string
+func (s *apiServer) PromoteReplicationSecondary(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	product, err := s.parseString(params, "product")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	force := 0
+	if params["force"] != "" {
+		if force, err = s.parseInteger(params, "force"); err != nil {
+			return rpc.ApiResponseError(err)
+		}
+	}
+	if err := s.topom.PromoteReplicationSecondary(product, force != 0); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
 }
 
-func NewApiClient(addr string) *ApiClient {
-	return &ApiClient{addr: addr}
-}
+func (s *apiServer) ReplicationCutover(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	product, err := s.parseString(params, "product")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	timeout := DefaultCutoverTimeout
+	if params["timeout"] != "" {
+		n, err := s.parseInteger(params, "timeout")
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		if n <= 0 {
+			return rpc.ApiResponseError(errors.Errorf("invalid timeout = %d", n))
+		}
+		timeout = time.Duration(n) * time.Second
+	}
+	if err := s.topom.ReplicationCutover(product, timeout); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) AddSentinel(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	addr, err := s.parseAddr(params)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.AddSentinel(addr); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) DelSentinel(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	addr, err := s.parseAddr(params)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	force, err := s.parseInteger(params, "force")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.DelSentinel(addr, force != 0); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ResyncSentinels(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.ResyncSentinels(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SentinelRemoveGroupsAll(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SentinelRemoveGroupsAll(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SentinelRemoveGroup(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	gid, err := s.parseInteger(params, "gid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.SentinelRemoveGroup(gid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) InfoServer(params martini.Params) (int, string) {
+	addr, err := s.parseAddr(params)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	c, err := redis.NewClient(addr, s.topom.Config().ProductAuth, time.Second)
+	if err != nil {
+		log.WarnErrorf(err, "create redis client to %s failed", addr)
+		return rpc.ApiResponseError(err)
+	}
+	defer c.Close()
+	if info, err := c.InfoFull(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(info)
+	}
+}
+
+func (s *apiServer) InfoSentinel(params martini.Params) (int, string) {
+	addr, err := s.parseAddr(params)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	c, err := redis.NewClientOptions(addr, s.topom.ha.options, time.Second)
+	if err != nil {
+		log.WarnErrorf(err, "create redis client to %s failed", addr)
+		return rpc.ApiResponseError(err)
+	}
+	defer c.Close()
+	if info, err := c.Info(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(info)
+	}
+}
+
+func (s *apiServer) InfoSentinelMonitored(params martini.Params) (int, string) {
+	addr, err := s.parseAddr(params)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	sentinel := s.topom.newSentinel()
+	if info, err := sentinel.MastersAndSlaves(addr, s.topom.Config().SentinelClientTimeout.Duration()); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(info)
+	}
+}
+
+func (s *apiServer) SetConfig(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	key := params["key"]
+	if key == "" {
+		return rpc.ApiResponseError(errors.New("invalid key"))
+	}
+	value := params["value"]
+
+	if steps, err := s.topom.SetConfigs(map[string]string{key: value}); err != nil {
+		return rpc.ApiResponseError(err)
+	} else if err := s.topom.afterSetConfig(steps); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) ListConfigOverride(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(s.topom.ConfigOverrides())
+}
+
+func (s *apiServer) UpdateConfig(p models.ConfigOverrides, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if steps, err := s.topom.SetConfigs(p.Values); err != nil {
+		return rpc.ApiResponseError(err)
+	} else if err := s.topom.afterSetConfig(steps); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) ExecCmd(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	addr := params["addr"]
+	original_cmd := params["cmd"]
+	cmd := strings.ToUpper(original_cmd)
+	if addr == "" || cmd == "" {
+		return rpc.ApiResponseError(errors.New("invalid params"))
+	}
+	if (!cmdIsAllow(cmd)) {
+		return rpc.ApiResponseError(errors.New("CMD: " + original_cmd + " is not allowed"))
+	}
+
+	c, err := redis.NewClient(addr, s.topom.Config().ProductAuth, time.Second)
+	if err != nil {
+		log.WarnErrorf(err, "create redis client to %s failed", addr)
+		return rpc.ApiResponseError(err)
+	}
+	defer c.Close()
+
+	if cmd == "INFO" || cmd == "CLIENT LIST" || cmd == "INFO DELAY" {
+		if text, err := c.DoCmd_string(original_cmd); err != nil {
+			return rpc.ApiResponseError(err)
+		} else {
+			return rpc.ApiResponseJson(text)
+		}
+	} else if (cmd == "SLOWLOG GET 1000" || cmd == "XSLOWLOG GET 1000") {
+		if text, err := c.SlowLog(original_cmd); err != nil {
+			return rpc.ApiResponseError(err)
+		} else {
+			return rpc.ApiResponseJson(text)
+		}
+	} else if (cmd == "CONFIG GET *" || cmd == "XCONFIG GET *") {
+		var resp = "";
+		if strings, err := c.DoCmd_strings(original_cmd); err != nil {
+			return rpc.ApiResponseError(err)
+		} else {
+			if cmd == "CONFIG GET *" {
+				for i:=0; i<len(strings)-1; i++ {
+					if (strings[i] == "requirepass" || strings[i] == "masterauth" || strings[i] == "userpass") {
+						strings[i+1] = "******"
+					}
+				}
+			}
+			for i:=0; i<len(strings); i++ {
+				if (i%2 == 0) {
+					resp += strings[i] + ": "
+				} else  {
+					resp += strings[i] + "\r\n"
+				}
+			}
+			return rpc.ApiResponseJson(resp)
+		}
+	} else {
+		return rpc.ApiResponseError(errors.New("Cmd not allowed."))
+	}
+}
+
+func cmdIsAllow(cmd string) bool {
+	cmd = strings.ToUpper(cmd)
+	for _, val := range cmdWhiteList {
+		if (cmd == val || strings.Index(cmd, val) == 0) {
+			return true 
+		}
+	}
+	return false
+}
+
+func (s *apiServer) ExpansionPullPlan(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	
+	resp := s.topom.ExpansionPullPlan()
+	return rpc.ApiResponseJson(resp)
+}
+
+func (s *apiServer) ExpansionAddPlan(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	newPlan := params["plan"]
+	if newPlan == "" {
+		return rpc.ApiResponseError(errors.New("plan is nil"))
+	}
+
+	if err := s.topom.ExpansionAddPlan(newPlan); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ExpansionDataSync(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	planId, err := s.parseInteger(params, "planid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.ExpansionDataSync(planId); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ExpansionBackup(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	force, err := s.parseInteger(params, "force")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	planId, err := s.parseInteger(params, "planid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.ExpansionBackup(planId, force); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ExpansionSlotsMgrt(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	planId, err := s.parseInteger(params, "planid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.ExpansionSlotsMgrt(planId); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ExpansionDateClean(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	planId, err := s.parseInteger(params, "planid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.ExpansionDateClean(planId); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ExpansionGroupDateClean(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	gid, err := s.parseInteger(params, "gid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.ExpansionGroupDateClean(gid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ExpansionDelPlan(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	gid, err := s.parseInteger(params, "planid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.ExpansionDelPlan(gid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) UpgrateUploadDashboard(r *http.Request, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	path := params["path"]
+	if err := DownLoadFile(r, path, "codis-dashboard"); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) UpgrateUploadConfig(r *http.Request, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	path := params["path"]
+	if path == "" {
+		return rpc.ApiResponseError(errors.New("path is invalid!"))
+	}
+	if err := DownLoadFile(r, path, "dashboard.toml"); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func DownLoadFile(r *http.Request, path, filename string) error {
+	if path == "" {
+		return errors.New("path is invalid!")
+	}
+
+	//create a new path if not exist
+	_, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			err := os.Mkdir("./" + path, os.ModePerm)
+			if err != nil{
+				return err
+			}
+		} else {
+			return err
+		}
+	}
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	dst, _ := os.Create("./" + path + "/" + filename)
+	defer dst.Close()
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+
+		log.Warnf("FileName=[%s], FormName=[%s]", part.FileName(), part.FormName())
+		if part.FileName() == "" {  // this is FormData
+			return errors.New("filename is invalid!")
+		} else {    // This is FileData
+			io.Copy(dst, part)
+		}
+	}
+
+	if filename == "codis-dashboard" {
+		os.Chmod("./" + path + "/" + filename, 0775)
+	}
+
+	return nil
+}
+
+func (s *apiServer) UpgrateStart(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	path := params["path"]
+	if path == "" {
+		return rpc.ApiResponseError(errors.New("path is invalid!"))
+	}
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+
+	if err := s.topom.UpgrateStart(path); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SyncCreateAction(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	addr, err := s.parseAddr(params)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SyncCreateAction(addr); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SyncRemoveAction(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	addr, err := s.parseAddr(params)
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SyncRemoveAction(addr); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotCreateAction(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	sid, err := s.parseInteger(params, "sid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	gid, err := s.parseInteger(params, "gid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotCreateAction(sid, gid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotCreateActionSome(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	groupFrom, err := s.parseInteger(params, "src")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	groupTo, err := s.parseInteger(params, "dst")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	numSlots, err := s.parseInteger(params, "num")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotCreateActionSome(groupFrom, groupTo, numSlots); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotCreateActionRange(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	beg, err := s.parseInteger(params, "beg")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	end, err := s.parseInteger(params, "end")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	gid, err := s.parseInteger(params, "gid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotCreateActionRange(beg, end, gid, true); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotRemoveAction(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	sid, err := s.parseInteger(params, "sid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotRemoveAction(sid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotActionQueue(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if slots, err := s.topom.SlotActionQueue(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(slots)
+	}
+}
+
+func (s *apiServer) SlotActionSetPriority(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	sid, err := s.parseInteger(params, "sid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	priority, err := s.parseInteger(params, "priority")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotActionSetPriority(sid, priority); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotActionSetGroupPriority(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	gid, err := s.parseInteger(params, "gid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	priority, err := s.parseInteger(params, "priority")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if n, err := s.topom.SlotActionSetGroupPriority(gid, priority); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(n)
+	}
+}
+
+func (s *apiServer) SlotActionReorder(slots []int, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotActionReorder(slots); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotRemoveActionAll(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotRemoveActionAll(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) LogLevel(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	v := params["value"]
+	if v == "" {
+		return rpc.ApiResponseError(errors.New("missing loglevel"))
+	}
+	if !log.SetLevelString(v) {
+		return rpc.ApiResponseError(errors.New("invalid loglevel"))
+	} else {
+		log.Warnf("set loglevel to %s", v)
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) Shutdown(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.Close(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SetSlotActionInterval(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	value, err := s.parseInteger(params, "value")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		s.topom.SetSlotActionInterval(value)
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SetSlotActionDisabled(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	disabled, err := s.parseInteger(params, "value")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	} else if (disabled < 0 || disabled > 2) {
+		return rpc.ApiResponseError(errors.New("invalid value to ActionDisabled (0 <= disabled <= 2)"))
+	} else {
+		s.topom.SetSlotActionDisabled(disabled)
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotsAssignGroup(slots []*models.SlotMapping, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotsAssignGroup(slots); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) SlotsAssignOffline(slots []*models.SlotMapping, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotsAssignOffline(slots); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson("OK")
+}
+
+func (s *apiServer) ScaleOut(req ScaleOutRequest, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if id, err := s.topom.ScaleOut(&req); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(id)
+	}
+}
+
+func (s *apiServer) Scaling(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(s.topom.Scaling())
+}
+
+func (s *apiServer) StartScaling(req ScalingRequest, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if w, err := s.topom.StartScaling(&req); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(w)
+	}
+}
+
+func (s *apiServer) AbortScaling(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.AbortScaling(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) ResumeScaling(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.ResumeScaling(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotVerifyReports(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	all := 0
+	if params["all"] != "" {
+		n, err := s.parseInteger(params, "all")
+		if err != nil {
+			return rpc.ApiResponseError(err)
+		}
+		all = n
+	}
+	return rpc.ApiResponseJson(s.topom.SlotVerifyReports(all != 0))
+}
+
+func (s *apiServer) SlotVerifyClear(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	sid, err := s.parseInteger(params, "sid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if err := s.topom.SlotVerifyClear(sid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson("OK")
+	}
+}
+
+func (s *apiServer) SlotsRebalance(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	confirm, err := s.parseInteger(params, "confirm")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if plans, err := s.topom.SlotsRebalance(confirm != 0); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		m := make(map[string]int)
+		for sid, gid := range plans {
+			m[strconv.Itoa(sid)] = gid
+		}
+		return rpc.ApiResponseJson(m)
+	}
+}
+
+//?dryrun=true时只返回迁移计划，GET总是dry-run；?load=1时按负载rebalance
+func (s *apiServer) SlotsRebalancePreview(req *http.Request, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	query := req.URL.Query()
+	var dryrun = req.Method == "GET"
+	if text := query.Get("dryrun"); text != "" {
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return rpc.ApiResponseError(errors.Errorf("invalid dryrun = %s", text))
+		}
+		dryrun = dryrun || b
+	}
+	var byLoad bool
+	if text := query.Get("load"); text != "" {
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return rpc.ApiResponseError(errors.Errorf("invalid load = %s", text))
+		}
+		byLoad = b
+	}
+	if p, err := s.topom.SlotsRebalancePreview(byLoad, dryrun); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(p)
+	}
+}
+
+func (s *apiServer) SlotsRebalanceByLoad(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	confirm, err := s.parseInteger(params, "confirm")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if plans, err := s.topom.SlotsRebalanceByLoad(confirm != 0); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		m := make(map[string]int)
+		for sid, gid := range plans {
+			m[strconv.Itoa(sid)] = gid
+		}
+		return rpc.ApiResponseJson(m)
+	}
+}
+
+//since为unix时间戳，只返回该时间之后的记录
+func (s *apiServer) SlotHistory(params martini.Params, req *http.Request) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	sid, err := s.parseInteger(params, "sid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	var since int64
+	if text := req.URL.Query().Get("since"); text != "" {
+		if since, err = strconv.ParseInt(text, 10, 64); err != nil {
+			return rpc.ApiResponseError(fmt.Errorf("invalid since"))
+		}
+	}
+	if h, err := s.topom.SlotHistory(sid, since); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(h)
+	}
+}
+
+func (s *apiServer) SlotsMigrationPlan(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	load, err := s.parseInteger(params, "load")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if p, err := s.topom.SlotsMigrationPlan(load != 0); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(p)
+	}
+}
+
+func (s *apiServer) GetMigrationPlan(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	pid, err := s.parseInteger(params, "pid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if p, err := s.topom.GetMigrationPlan(pid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(p)
+	}
+}
+
+func (s *apiServer) SlotsMigrationPlanApply(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	pid, err := s.parseInteger(params, "pid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	step, err := s.parseInteger(params, "step")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if x, err := s.topom.SlotsMigrationPlanApply(pid, step); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(x)
+	}
+}
+
+func (s *apiServer) SlotsMigrationPlanApplyAll(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	pid, err := s.parseInteger(params, "pid")
+	if err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if steps, err := s.topom.SlotsMigrationPlanApplyAll(pid); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(steps)
+	}
+}
+
+func (s *apiServer) SlotsWeightedPlan(w RebalanceWeights, params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if p, err := s.topom.SlotsWeightedPlan(&w); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(p)
+	}
+}
+
+//limit为返回的最大记录数，user不为空时只返回该用户的记录
+func (s *apiServer) AuditLog(params martini.Params, req *http.Request) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	var limit int
+	query := req.URL.Query()
+	if text := query.Get("limit"); text != "" {
+		n, err := strconv.Atoi(text)
+		if err != nil || n < 0 {
+			return rpc.ApiResponseError(fmt.Errorf("invalid limit"))
+		}
+		limit = n
+	}
+	if list, err := s.topom.AuditLog(limit, query.Get("user")); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(list)
+	}
+}
+
+func (s *apiServer) SlotHeat(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	return rpc.ApiResponseJson(s.topom.SlotHeat())
+}
+
+func (s *apiServer) SlotsProgress(params martini.Params) (int, string) {
+	if err := s.verifyXAuth(params); err != nil {
+		return rpc.ApiResponseError(err)
+	}
+	if p, err := s.topom.SlotsProgress(); err != nil {
+		return rpc.ApiResponseError(err)
+	} else {
+		return rpc.ApiResponseJson(p)
+	}
+}
+
+type ApiClient struct {
+	addr    string
+	xauth   string
+	product string
+}
+
+func NewApiClient(addr string) *ApiClient {
+	return &ApiClient{addr: addr}
+}
+
+func (c *ApiClient) SetXAuth(name string) {
+	c.xauth = rpc.NewXAuth(name)
+}
+
+//访问同一个dashboard管理的其他product，xauth仍然需要通过SetXAuth设置
+func (c *ApiClient) SetProduct(name string) {
+	c.product = name
+}
+
+func (c *ApiClient) encodeURL(format string, args ...interface{}) string {
+	if c.product != "" {
+		for _, prefix := range []string{"/topom", "/api/topom"} {
+			if format == prefix || strings.HasPrefix(format, prefix+"/") {
+				format = prefix + "/" + c.product + format[len(prefix):]
+				break
+			}
+		}
+	}
+	return rpc.EncodeURL(c.addr, format, args...)
+}
+
+func (c *ApiClient) Overview() (*Overview, error) {
+	url := c.encodeURL("/topom")
+	var o = &Overview{}
+	if err := rpc.ApiGetJson(url, o); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+func (c *ApiClient) Summary() (*Summary, error) {
+	url := c.encodeURL("/topom/summary")
+	var x = &Summary{}
+	if err := rpc.ApiGetJson(url, x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (c *ApiClient) Products() ([]string, error) {
+	url := c.encodeURL("/topom/products")
+	var names []string
+	if err := rpc.ApiGetJson(url, &names); err != nil {
+		return nil, err
+	}
+	return names, nil
+}
+
+func (c *ApiClient) Model() (*models.Topom, error) {
+	url := c.encodeURL("/api/topom/model")
+	model := &models.Topom{}
+	if err := rpc.ApiGetJson(url, model); err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func (c *ApiClient) OpenAPI() (map[string]interface{}, error) {
+	url := c.encodeURL("/api/topom/openapi")
+	var spec = make(map[string]interface{})
+	if err := rpc.ApiGetJson(url, &spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+func (c *ApiClient) XPing() error {
+	url := c.encodeURL("/api/topom/xping/%s", c.xauth)
+	return rpc.ApiGetJson(url, nil)
+}
+
+func (c *ApiClient) Stats() (*Stats, error) {
+	url := c.encodeURL("/api/topom/stats/%s", c.xauth)
+	stats := &Stats{}
+	if err := rpc.ApiGetJson(url, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func (c *ApiClient) Slots() ([]*models.Slot, error) {
+	url := c.encodeURL("/api/topom/slots/%s", c.xauth)
+	slots := []*models.Slot{}
+	if err := rpc.ApiGetJson(url, &slots); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+func (c *ApiClient) Reload() error {
+	url := c.encodeURL("/api/topom/reload/%s", c.xauth)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) LogLevel(level log.LogLevel) error {
+	url := c.encodeURL("/api/topom/loglevel/%s/%s", c.xauth, level)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) Shutdown() error {
+	url := c.encodeURL("/api/topom/shutdown/%s", c.xauth)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) CreateProxy(addr string) error {
+	url := c.encodeURL("/api/topom/proxy/create/%s/%s", c.xauth, addr)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) OnlineProxy(addr string) error {
+	url := c.encodeURL("/api/topom/proxy/online/%s/%s", c.xauth, addr)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) ReinitProxy(token string) error {
+	url := c.encodeURL("/api/topom/proxy/reinit/%s/%s", c.xauth, token)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) RemoveProxy(token string, force bool) error {
+	var value int
+	if force {
+		value = 1
+	}
+	url := c.encodeURL("/api/topom/proxy/remove/%s/%s/%d", c.xauth, token, value)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) CreateGroup(gid int) error {
+	url := c.encodeURL("/api/topom/group/create/%s/%d", c.xauth, gid)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) RemoveGroup(gid int) error {
+	url := c.encodeURL("/api/topom/group/remove/%s/%d", c.xauth, gid)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) GroupDecommission(gid int) (int, error) {
+	url := c.encodeURL("/api/topom/group/decommission/%s/%d", c.xauth, gid)
+	var id int
+	if err := rpc.ApiPutJson(url, nil, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *ApiClient) GroupHealth() (map[int]string, error) {
+	url := c.encodeURL("/api/topom/group/health/%s", c.xauth)
+	var health = make(map[string]string)
+	if err := rpc.ApiGetJson(url, &health); err != nil {
+		return nil, err
+	}
+	var m = make(map[int]string)
+	for gid, v := range health {
+		n, err := strconv.Atoi(gid)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		m[n] = v
+	}
+	return m, nil
+}
+
+func (c *ApiClient) ResyncGroup(gid int) error {
+	url := c.encodeURL("/api/topom/group/resync/%s/%d", c.xauth, gid)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) ResyncGroupAll() error {
+	url := c.encodeURL("/api/topom/group/resync-all/%s", c.xauth)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) GroupAddServer(gid int, dc, addr string) error {
+	var url string
+	if dc != "" {
+		url = c.encodeURL("/api/topom/group/add/%s/%d/%s/%s", c.xauth, gid, addr, dc)
+	} else {
+		url = c.encodeURL("/api/topom/group/add/%s/%d/%s", c.xauth, gid, addr)
+	}
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) GroupDelServer(gid int, addr string) error {
+	url := c.encodeURL("/api/topom/group/del/%s/%d/%s", c.xauth, gid, addr)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) GroupPromoteServer(gid int, addr string, force int) error {
+	url := c.encodeURL("/api/topom/group/promote/%s/%d/%s/%d", c.xauth, gid, addr, force)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) EnableReplicaGroups(gid int, addr string, value bool) error {
+	var n int
+	if value {
+		n = 1
+	}
+	url := c.encodeURL("/api/topom/group/replica-groups/%s/%d/%s/%d", c.xauth, gid, addr, n)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) EnableReplicaGroupsAll(value bool) error {
+	var n int
+	if value {
+		n = 1
+	}
+	url := c.encodeURL("/api/topom/group/replica-groups-all/%s/%d", c.xauth, n)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) GroupGetMemoryPolicy(gid int) (*GroupMemoryPolicy, error) {
+	url := c.encodeURL("/api/topom/group/memory/%s/%d", c.xauth, gid)
+	var p = &GroupMemoryPolicy{}
+	if err := rpc.ApiGetJson(url, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (c *ApiClient) GroupSetMemoryPolicy(gid int, maxmemory, policy string) error {
+	url := c.encodeURL("/api/topom/group/memory/%s/%d/%s/%s", c.xauth, gid, maxmemory, policy)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) GroupApplyTemplate(gid int, name string) error {
+	url := c.encodeURL("/api/topom/group/template/%s/%d/%s", c.xauth, gid, name)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) ListConfigTemplate() ([]*models.ConfigTemplate, error) {
+	url := c.encodeURL("/api/topom/template/list/%s", c.xauth)
+	var list = []*models.ConfigTemplate{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) UpdateConfigTemplate(t *models.ConfigTemplate) error {
+	url := c.encodeURL("/api/topom/template/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, t, nil)
+}
+
+func (c *ApiClient) RemoveConfigTemplate(name string) error {
+	url := c.encodeURL("/api/topom/template/remove/%s/%s", c.xauth, name)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) ListKeyQuota() ([]*models.KeyQuota, error) {
+	url := c.encodeURL("/api/topom/quota/list/%s", c.xauth)
+	var list = []*models.KeyQuota{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) UpdateKeyQuota(q *models.KeyQuota) error {
+	url := c.encodeURL("/api/topom/quota/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, q, nil)
+}
+
+func (c *ApiClient) RemoveKeyQuota(prefix string) error {
+	url := c.encodeURL("/api/topom/quota/remove/%s", c.xauth)
+	return rpc.ApiPutJson(url, &models.KeyQuota{Prefix: prefix}, nil)
+}
+
+func (c *ApiClient) ListPrefixRoute() ([]*models.PrefixRoute, error) {
+	url := c.encodeURL("/api/topom/route/list/%s", c.xauth)
+	var list = []*models.PrefixRoute{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) UpdatePrefixRoute(r *models.PrefixRoute) error {
+	url := c.encodeURL("/api/topom/route/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, r, nil)
+}
+
+func (c *ApiClient) RemovePrefixRoute(prefix string) error {
+	url := c.encodeURL("/api/topom/route/remove/%s", c.xauth)
+	return rpc.ApiPutJson(url, &models.PrefixRoute{Prefix: prefix}, nil)
+}
+
+func (c *ApiClient) ListTTLRule() ([]*models.TTLRule, error) {
+	url := c.encodeURL("/api/topom/ttlrule/list/%s", c.xauth)
+	var list = []*models.TTLRule{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) UpdateTTLRule(t *models.TTLRule) error {
+	url := c.encodeURL("/api/topom/ttlrule/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, t, nil)
+}
+
+func (c *ApiClient) RemoveTTLRule(prefix string) error {
+	url := c.encodeURL("/api/topom/ttlrule/remove/%s", c.xauth)
+	return rpc.ApiPutJson(url, &models.TTLRule{Prefix: prefix}, nil)
+}
+
+func (c *ApiClient) ListNamespace() ([]*models.Namespace, error) {
+	url := c.encodeURL("/api/topom/namespace/list/%s", c.xauth)
+	var list = []*models.Namespace{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) NamespaceStats() ([]*NamespaceStats, error) {
+	url := c.encodeURL("/api/topom/namespace/stats/%s", c.xauth)
+	var list = []*NamespaceStats{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) UpdateNamespace(ns *models.Namespace) error {
+	url := c.encodeURL("/api/topom/namespace/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, ns, nil)
+}
+
+func (c *ApiClient) RemoveNamespace(name string) error {
+	url := c.encodeURL("/api/topom/namespace/remove/%s", c.xauth)
+	return rpc.ApiPutJson(url, &models.Namespace{Name: name}, nil)
+}
+
+func (c *ApiClient) ListACLUser() ([]*models.ACLUser, error) {
+	url := c.encodeURL("/api/topom/acl/list/%s", c.xauth)
+	var list = []*models.ACLUser{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) UpdateACLUser(u *models.ACLUser) error {
+	url := c.encodeURL("/api/topom/acl/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, u, nil)
+}
+
+func (c *ApiClient) RemoveACLUser(name string) error {
+	url := c.encodeURL("/api/topom/acl/remove/%s", c.xauth)
+	return rpc.ApiPutJson(url, &models.ACLUser{Name: name}, nil)
+}
+
+func (c *ApiClient) RequestFilter() (*models.RequestFilter, error) {
+	url := c.encodeURL("/api/topom/filter/get/%s", c.xauth)
+	filter := &models.RequestFilter{}
+	if err := rpc.ApiGetJson(url, filter); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
 
-func (c *ApiClient) SetXAuth(name string) {
-	c.xauth = rpc.NewXAuth(name)
+func (c *ApiClient) UpdateRequestFilter(script string) error {
+	url := c.encodeURL("/api/topom/filter/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, &models.RequestFilter{Script: script}, nil)
 }
 
-func (c *ApiClient) encodeURL(format string, args ...interface{}) string {
-	return rpc.EncodeURL(c.addr, format, args...)
+func (c *ApiClient) ConfigTemplateDrift() ([]*GroupConfigDrift, error) {
+	url := c.encodeURL("/api/topom/template/drift/%s", c.xauth)
+	var drifts = []*GroupConfigDrift{}
+	if err := rpc.ApiGetJson(url, &drifts); err != nil {
+		return nil, err
+	}
+	return drifts, nil
 }
 
-func (c *ApiClient) Overview() (*Overview, error) {
-	url := c.encodeURL("/topom")
-	var o = &Overview{}
-	if err := rpc.ApiGetJson(url, o); err != nil {
+func (c *ApiClient) ListJobs() ([]*Job, error) {
+	url := c.encodeURL("/api/topom/jobs/%s", c.xauth)
+	var list = []*Job{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
 		return nil, err
 	}
-	return o, nil
+	return list, nil
 }
 
-func (c *ApiClient) Model() (*models.Topom, error) {
-	url := c.encodeURL("/api/topom/model")
-	model := &models.Topom{}
-	if err := rpc.ApiGetJson(url, model); err != nil {
+func (c *ApiClient) GetJob(id int) (*Job, error) {
+	url := c.encodeURL("/api/topom/jobs/%s/%d", c.xauth, id)
+	var j = &Job{}
+	if err := rpc.ApiGetJson(url, j); err != nil {
 		return nil, err
 	}
-	return model, nil
+	return j, nil
 }
 
-func (c *ApiClient) XPing() error {
-	url := c.encodeURL("/api/topom/xping/%s", c.xauth)
-	return rpc.ApiGetJson(url, nil)
+func (c *ApiClient) CancelJob(id int) error {
+	url := c.encodeURL("/api/topom/jobs/cancel/%s/%d", c.xauth, id)
+	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) Stats() (*Stats, error) {
-	url := c.encodeURL("/api/topom/stats/%s", c.xauth)
-	stats := &Stats{}
-	if err := rpc.ApiGetJson(url, stats); err != nil {
+func (c *ApiClient) ResyncGroupAllJob() (int, error) {
+	url := c.encodeURL("/api/topom/group/resync-all-async/%s", c.xauth)
+	var id int
+	if err := rpc.ApiPutJson(url, nil, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *ApiClient) SlotsRebalanceJob(byLoad bool) (int, error) {
+	var value int
+	if byLoad {
+		value = 1
+	}
+	url := c.encodeURL("/api/topom/slots/rebalance-async/%s/%d", c.xauth, value)
+	var id int
+	if err := rpc.ApiPutJson(url, nil, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *ApiClient) CloneProduct(req *CloneRequest) (int, error) {
+	url := c.encodeURL("/api/topom/clone/create/%s", c.xauth)
+	var id int
+	if err := rpc.ApiPutJson(url, req, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *ApiClient) CloneFinish(id int) error {
+	url := c.encodeURL("/api/topom/clone/finish/%s/%d", c.xauth, id)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) Report(period string) (*Report, error) {
+	url := c.encodeURL("/api/topom/report/json/%s/%s", c.xauth, period)
+	var r = &Report{}
+	if err := rpc.ApiGetJson(url, r); err != nil {
 		return nil, err
 	}
-	return stats, nil
+	return r, nil
 }
 
-func (c *ApiClient) Slots() ([]*models.Slot, error) {
-	url := c.encodeURL("/api/topom/slots/%s", c.xauth)
-	slots := []*models.Slot{}
-	if err := rpc.ApiGetJson(url, &slots); err != nil {
+func (c *ApiClient) BackupVerifyJob(req *BackupVerifyRequest) (int, error) {
+	url := c.encodeURL("/api/topom/backup/verify/%s", c.xauth)
+	var id int
+	if err := rpc.ApiPutJson(url, req, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (c *ApiClient) BackupVerifyReports() ([]*BackupVerifyDetail, error) {
+	url := c.encodeURL("/api/topom/backup/verify/%s", c.xauth)
+	var list = []*BackupVerifyDetail{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
 		return nil, err
 	}
-	return slots, nil
+	return list, nil
 }
 
-func (c *ApiClient) Reload() error {
-	url := c.encodeURL("/api/topom/reload/%s", c.xauth)
-	return rpc.ApiPutJson(url, nil, nil)
+func (c *ApiClient) KeyspaceDiffJob(req *KeyspaceDiffRequest) (int, error) {
+	url := c.encodeURL("/api/topom/keyspace/diff/%s", c.xauth)
+	var id int
+	if err := rpc.ApiPutJson(url, req, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
 }
 
-func (c *ApiClient) LogLevel(level log.LogLevel) error {
-	url := c.encodeURL("/api/topom/loglevel/%s/%s", c.xauth, level)
-	return rpc.ApiPutJson(url, nil, nil)
+func (c *ApiClient) KeyspaceDiffReports() ([]*KeyspaceDiffDetail, error) {
+	url := c.encodeURL("/api/topom/keyspace/diff/%s", c.xauth)
+	var list = []*KeyspaceDiffDetail{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
-func (c *ApiClient) Shutdown() error {
-	url := c.encodeURL("/api/topom/shutdown/%s", c.xauth)
-	return rpc.ApiPutJson(url, nil, nil)
+func (c *ApiClient) TopologyPlan(desired *DesiredState) (*TopologyPlan, error) {
+	url := c.encodeURL("/api/topom/topology/plan/%s", c.xauth)
+	var p = &TopologyPlan{}
+	if err := rpc.ApiPutJson(url, desired, p); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-func (c *ApiClient) CreateProxy(addr string) error {
-	url := c.encodeURL("/api/topom/proxy/create/%s/%s", c.xauth, addr)
-	return rpc.ApiPutJson(url, nil, nil)
+func (c *ApiClient) GetTopologyPlan(pid int) (*TopologyPlan, error) {
+	url := c.encodeURL("/api/topom/topology/plan/%s/%d", c.xauth, pid)
+	var p = &TopologyPlan{}
+	if err := rpc.ApiGetJson(url, p); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-func (c *ApiClient) OnlineProxy(addr string) error {
-	url := c.encodeURL("/api/topom/proxy/online/%s/%s", c.xauth, addr)
-	return rpc.ApiPutJson(url, nil, nil)
+func (c *ApiClient) TopologyApply(pid int) (*TopologyPlan, error) {
+	url := c.encodeURL("/api/topom/topology/apply/%s/%d", c.xauth, pid)
+	var p = &TopologyPlan{}
+	if err := rpc.ApiPutJson(url, nil, p); err != nil {
+		return nil, err
+	}
+	return p, nil
 }
 
-func (c *ApiClient) ReinitProxy(token string) error {
-	url := c.encodeURL("/api/topom/proxy/reinit/%s/%s", c.xauth, token)
-	return rpc.ApiPutJson(url, nil, nil)
+func (c *ApiClient) ChaosFaults() ([]*ChaosFault, error) {
+	url := c.encodeURL("/api/topom/chaos/%s", c.xauth)
+	var list = []*ChaosFault{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
 }
 
-func (c *ApiClient) RemoveProxy(token string, force bool) error {
-	var value int
-	if force {
-		value = 1
+func (c *ApiClient) ChaosMasterDown(gid int, d time.Duration) (*ChaosFault, error) {
+	url := c.encodeURL("/api/topom/chaos/master-down/%s/%d/%d", c.xauth, gid, int(d/time.Second))
+	var f = &ChaosFault{}
+	if err := rpc.ApiPutJson(url, nil, f); err != nil {
+		return nil, err
 	}
-	url := c.encodeURL("/api/topom/proxy/remove/%s/%s/%d", c.xauth, token, value)
+	return f, nil
+}
+
+func (c *ApiClient) ChaosBlackhole(token, addr string, d time.Duration) (*ChaosFault, error) {
+	url := c.encodeURL("/api/topom/chaos/blackhole/%s/%s/%s/%d", c.xauth, token, addr, int(d/time.Second))
+	var f = &ChaosFault{}
+	if err := rpc.ApiPutJson(url, nil, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (c *ApiClient) ChaosSentinelDelay(delay, d time.Duration) (*ChaosFault, error) {
+	url := c.encodeURL("/api/topom/chaos/sentinel-delay/%s/%d/%d", c.xauth, int(delay/time.Millisecond), int(d/time.Second))
+	var f = &ChaosFault{}
+	if err := rpc.ApiPutJson(url, nil, f); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (c *ApiClient) ChaosRecover(id int) error {
+	url := c.encodeURL("/api/topom/chaos/recover/%s/%d", c.xauth, id)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) CreateGroup(gid int) error {
-	url := c.encodeURL("/api/topom/group/create/%s/%d", c.xauth, gid)
+func (c *ApiClient) SwitchoverStatus() (*SwitchoverStatus, error) {
+	url := c.encodeURL("/api/topom/switchover/status/%s", c.xauth)
+	var status = &SwitchoverStatus{}
+	if err := rpc.ApiGetJson(url, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *ApiClient) SwitchoverStart(product string) error {
+	url := c.encodeURL("/api/topom/switchover/start/%s/%s", c.xauth, product)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) RemoveGroup(gid int) error {
-	url := c.encodeURL("/api/topom/group/remove/%s/%d", c.xauth, gid)
+func (c *ApiClient) SwitchoverRollback() error {
+	url := c.encodeURL("/api/topom/switchover/rollback/%s", c.xauth)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) ResyncGroup(gid int) error {
-	url := c.encodeURL("/api/topom/group/resync/%s/%d", c.xauth, gid)
+func (c *ApiClient) StandbyStatus() (*models.Standby, error) {
+	url := c.encodeURL("/api/topom/standby/status/%s", c.xauth)
+	var status = &models.Standby{}
+	if err := rpc.ApiGetJson(url, status); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+func (c *ApiClient) SetStandby(primary string) error {
+	url := c.encodeURL("/api/topom/standby/set/%s/%s", c.xauth, primary)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) ResyncGroupAll() error {
-	url := c.encodeURL("/api/topom/group/resync-all/%s", c.xauth)
+func (c *ApiClient) PromoteStandby() error {
+	url := c.encodeURL("/api/topom/standby/promote/%s", c.xauth)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) GroupAddServer(gid int, dc, addr string) error {
-	var url string
-	if dc != "" {
-		url = c.encodeURL("/api/topom/group/add/%s/%d/%s/%s", c.xauth, gid, addr, dc)
-	} else {
-		url = c.encodeURL("/api/topom/group/add/%s/%d/%s", c.xauth, gid, addr)
+func (c *ApiClient) MasterSync(confirm bool) (*MasterSyncDiff, error) {
+	var diff = &MasterSyncDiff{}
+	if !confirm {
+		url := c.encodeURL("/api/topom/master/sync/%s", c.xauth)
+		if err := rpc.ApiGetJson(url, diff); err != nil {
+			return nil, err
+		}
+		return diff, nil
 	}
-	return rpc.ApiPutJson(url, nil, nil)
+	url := c.encodeURL("/api/topom/master/sync/%s/1", c.xauth)
+	if err := rpc.ApiPutJson(url, nil, diff); err != nil {
+		return nil, err
+	}
+	return diff, nil
 }
 
-func (c *ApiClient) GroupDelServer(gid int, addr string) error {
-	url := c.encodeURL("/api/topom/group/del/%s/%d/%s", c.xauth, gid, addr)
+func (c *ApiClient) ReplicationLinks() ([]*ReplicationLinkStatus, error) {
+	url := c.encodeURL("/api/topom/replication/list/%s", c.xauth)
+	var list = []*ReplicationLinkStatus{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) CreateReplicationLink(product string) error {
+	url := c.encodeURL("/api/topom/replication/create/%s/%s", c.xauth, product)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) GroupPromoteServer(gid int, addr string, force int) error {
-	url := c.encodeURL("/api/topom/group/promote/%s/%d/%s/%d", c.xauth, gid, addr, force)
+func (c *ApiClient) RemoveReplicationLink(product string) error {
+	url := c.encodeURL("/api/topom/replication/remove/%s/%s", c.xauth, product)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) EnableReplicaGroups(gid int, addr string, value bool) error {
+func (c *ApiClient) PromoteReplicationSecondary(product string, force bool) error {
 	var n int
-	if value {
+	if force {
 		n = 1
 	}
-	url := c.encodeURL("/api/topom/group/replica-groups/%s/%d/%s/%d", c.xauth, gid, addr, n)
+	url := c.encodeURL("/api/topom/replication/promote/%s/%s/%d", c.xauth, product, n)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
-func (c *ApiClient) EnableReplicaGroupsAll(value bool) error {
-	var n int
-	if value {
-		n = 1
-	}
-	url := c.encodeURL("/api/topom/group/replica-groups-all/%s/%d", c.xauth, n)
+//timeout为等待备product追上的时间，按秒取整
+func (c *ApiClient) ReplicationCutover(product string, timeout time.Duration) error {
+	url := c.encodeURL("/api/topom/replication/cutover/%s/%s/%d", c.xauth, product, int(timeout/time.Second))
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
@@ -1417,6 +3194,34 @@ func (c *ApiClient) SlotRemoveAction(sid int) error {
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
+func (c *ApiClient) SlotActionQueue() ([]*models.SlotMapping, error) {
+	url := c.encodeURL("/api/topom/slots/action/queue/%s", c.xauth)
+	var slots []*models.SlotMapping
+	if err := rpc.ApiGetJson(url, &slots); err != nil {
+		return nil, err
+	}
+	return slots, nil
+}
+
+func (c *ApiClient) SlotActionSetPriority(sid int, priority int) error {
+	url := c.encodeURL("/api/topom/slots/action/priority/%s/%d/%d", c.xauth, sid, priority)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) SlotActionSetGroupPriority(gid int, priority int) (int, error) {
+	url := c.encodeURL("/api/topom/slots/action/priority-group/%s/%d/%d", c.xauth, gid, priority)
+	var n int
+	if err := rpc.ApiPutJson(url, nil, &n); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+func (c *ApiClient) SlotActionReorder(slots []int) error {
+	url := c.encodeURL("/api/topom/slots/action/reorder/%s", c.xauth)
+	return rpc.ApiPutJson(url, slots, nil)
+}
+
 func (c *ApiClient) SlotRemoveActionAll(sid int) error {
 	url := c.encodeURL("/api/topom/slots/action/remove-all/%s", c.xauth)
 	return rpc.ApiPutJson(url, nil, nil)
@@ -1442,6 +3247,62 @@ func (c *ApiClient) SlotsAssignOffline(slots []*models.SlotMapping) error {
 	return rpc.ApiPutJson(url, slots, nil)
 }
 
+func (c *ApiClient) ScaleOut(req *ScaleOutRequest) (int, error) {
+	url := c.encodeURL("/api/topom/slots/scale-out/%s", c.xauth)
+	var id int
+	if err := rpc.ApiPutJson(url, req, &id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+//没有执行过扩缩容时返回nil
+func (c *ApiClient) Scaling() (*models.ScalingWorkflow, error) {
+	url := c.encodeURL("/api/topom/scaling/status/%s", c.xauth)
+	var w *models.ScalingWorkflow
+	if err := rpc.ApiGetJson(url, &w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (c *ApiClient) StartScaling(req *ScalingRequest) (*models.ScalingWorkflow, error) {
+	url := c.encodeURL("/api/topom/scaling/start/%s", c.xauth)
+	var w = &models.ScalingWorkflow{}
+	if err := rpc.ApiPutJson(url, req, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (c *ApiClient) AbortScaling() error {
+	url := c.encodeURL("/api/topom/scaling/abort/%s", c.xauth)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) ResumeScaling() error {
+	url := c.encodeURL("/api/topom/scaling/resume/%s", c.xauth)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
+func (c *ApiClient) SlotVerifyReports(all bool) ([]*models.SlotVerifyReport, error) {
+	var n int
+	if all {
+		n = 1
+	}
+	url := c.encodeURL("/api/topom/slots/verify/%s/%d", c.xauth, n)
+	var list = []*models.SlotVerifyReport{}
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) SlotVerifyClear(sid int) error {
+	url := c.encodeURL("/api/topom/slots/verify/clear/%s/%d", c.xauth, sid)
+	return rpc.ApiPutJson(url, nil, nil)
+}
+
 func (c *ApiClient) SlotsRebalance(confirm bool) (map[int]int, error) {
 	var value int
 	if confirm {
@@ -1464,11 +3325,158 @@ func (c *ApiClient) SlotsRebalance(confirm bool) (map[int]int, error) {
 	}
 }
 
+func (c *ApiClient) DiscoverProxies() ([]*DiscoveryProxy, error) {
+	url := c.encodeURL("/api/topom/proxy/discovery/%s", c.xauth)
+	var list []*DiscoveryProxy
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) SlotHistory(sid int) (*models.SlotHistory, error) {
+	url := c.encodeURL("/api/topom/slots/history/%s/%d", c.xauth, sid)
+	var h = &models.SlotHistory{}
+	if err := rpc.ApiGetJson(url, h); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (c *ApiClient) SlotsMigrationPlan(byLoad bool) (*MigrationPlan, error) {
+	var value int
+	if byLoad {
+		value = 1
+	}
+	url := c.encodeURL("/api/topom/slots/plan/create/%s/%d", c.xauth, value)
+	var p = &MigrationPlan{}
+	if err := rpc.ApiPutJson(url, nil, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (c *ApiClient) GetMigrationPlan(pid int) (*MigrationPlan, error) {
+	url := c.encodeURL("/api/topom/slots/plan/%s/%d", c.xauth, pid)
+	var p = &MigrationPlan{}
+	if err := rpc.ApiGetJson(url, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (c *ApiClient) SlotsMigrationPlanApply(pid, step int) (*MigrationStep, error) {
+	url := c.encodeURL("/api/topom/slots/plan/apply/%s/%d/%d", c.xauth, pid, step)
+	var x = &MigrationStep{}
+	if err := rpc.ApiPutJson(url, nil, x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (c *ApiClient) SlotsMigrationPlanApplyAll(pid int) ([]*MigrationStep, error) {
+	url := c.encodeURL("/api/topom/slots/plan/apply-all/%s/%d", c.xauth, pid)
+	var steps []*MigrationStep
+	if err := rpc.ApiPutJson(url, nil, &steps); err != nil {
+		return nil, err
+	}
+	return steps, nil
+}
+
+func (c *ApiClient) SlotsWeightedPlan(w *RebalanceWeights) (*MigrationPlan, error) {
+	url := c.encodeURL("/api/topom/slots/plan/weighted/%s", c.xauth)
+	var p = &MigrationPlan{}
+	if err := rpc.ApiPutJson(url, w, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (c *ApiClient) AuditLog(limit int, user string) ([]*models.AuditEntry, error) {
+	query := neturl.Values{}
+	query.Set("limit", strconv.Itoa(limit))
+	if user != "" {
+		query.Set("user", user)
+	}
+	url := c.encodeURL("/api/topom/audit/%s", c.xauth) + "?" + query.Encode()
+	var list []*models.AuditEntry
+	if err := rpc.ApiGetJson(url, &list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (c *ApiClient) SlotsRebalanceDryRun(byLoad bool) (*MigrationPlan, error) {
+	query := neturl.Values{}
+	query.Set("dryrun", "true")
+	query.Set("load", strconv.FormatBool(byLoad))
+	url := c.encodeURL("/api/topom/slots/rebalance/%s", c.xauth) + "?" + query.Encode()
+	var p = &MigrationPlan{}
+	if err := rpc.ApiGetJson(url, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (c *ApiClient) SlotsRebalanceByLoad(confirm bool) (map[int]int, error) {
+	var value int
+	if confirm {
+		value = 1
+	}
+	url := c.encodeURL("/api/topom/slots/rebalance-load/%s/%d", c.xauth, value)
+	var plans = make(map[string]int)
+	if err := rpc.ApiPutJson(url, nil, &plans); err != nil {
+		return nil, err
+	} else {
+		var m = make(map[int]int)
+		for sid, gid := range plans {
+			n, err := strconv.Atoi(sid)
+			if err != nil {
+				return nil, errors.Trace(err)
+			}
+			m[n] = gid
+		}
+		return m, nil
+	}
+}
+
+func (c *ApiClient) SlotHeat() (*models.SlotHeat, error) {
+	url := c.encodeURL("/api/topom/slots/heat/%s", c.xauth)
+	var heat = &models.SlotHeat{}
+	if err := rpc.ApiGetJson(url, heat); err != nil {
+		return nil, err
+	}
+	return heat, nil
+}
+
+func (c *ApiClient) SlotsProgress() (*MigrationProgress, error) {
+	url := c.encodeURL("/api/topom/slots/progress/%s", c.xauth)
+	var p = &MigrationProgress{}
+	if err := rpc.ApiGetJson(url, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 func (c *ApiClient) SetConfig(key, value string) error {
 	url := c.encodeURL("/api/topom/config/set/%s/%s/%s", c.xauth, key, value)
 	return rpc.ApiPutJson(url, nil, nil)
 }
 
+func (c *ApiClient) UpdateConfig(values map[string]string) error {
+	url := c.encodeURL("/api/topom/config/update/%s", c.xauth)
+	return rpc.ApiPutJson(url, &models.ConfigOverrides{Values: values}, nil)
+}
+
+func (c *ApiClient) ConfigOverrides() (map[string]string, error) {
+	url := c.encodeURL("/api/topom/config/list/%s", c.xauth)
+	var m = make(map[string]string)
+	if err := rpc.ApiGetJson(url, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *ApiClient) ExecCmd(addr, cmd string) error {
 	url := c.encodeURL("/api/topom/docmd/%s/%s/%s", c.xauth, addr, cmd)
 	return rpc.ApiPutJson(url, nil, nil)
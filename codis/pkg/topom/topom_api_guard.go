@@ -0,0 +1,280 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-martini/martini"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/rpc"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+//令牌桶，每秒补充rate个令牌，最多积累burst个
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	clients map[string]*rateBucket
+}
+
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate: rate, burst: float64(burst),
+		clients: make(map[string]*rateBucket),
+	}
+}
+
+func (l *rateLimiter) allow(client string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.clients[client]
+	if b == nil {
+		//清理长时间没有请求的client，此时它们的令牌已经补满
+		if len(l.clients) >= 1024 {
+			for k, x := range l.clients {
+				if now.Sub(x.last).Seconds()*l.rate >= l.burst {
+					delete(l.clients, k)
+				}
+			}
+		}
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * l.rate
+	if b.tokens > l.burst {
+		b.tokens = l.burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+type idempotentResponse struct {
+	done   bool
+	status int
+	body   []byte
+	expire time.Time
+}
+
+//缓存带有Idempotency-Key的请求的响应，重试的请求直接返回第一次的结果
+type idempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]*idempotentResponse
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{ttl: ttl, entries: make(map[string]*idempotentResponse)}
+}
+
+//返回已完成的响应，或者在key不存在时占用该key；key对应的请求正在执行时返回错误
+func (c *idempotencyCache) acquire(key string, now time.Time) (*idempotentResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, x := range c.entries {
+		if x.done && now.After(x.expire) {
+			delete(c.entries, k)
+		}
+	}
+	if x := c.entries[key]; x != nil {
+		if !x.done {
+			return nil, errors.Errorf("request with idempotency key %s is in progress", key)
+		}
+		return x, nil
+	}
+	c.entries[key] = &idempotentResponse{}
+	return nil, nil
+}
+
+func (c *idempotencyCache) release(key string, r *idempotentResponse, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if r == nil {
+		delete(c.entries, key)
+		return
+	}
+	r.done = true
+	r.expire = now.Add(c.ttl)
+	c.entries[key] = r
+}
+
+type recordResponseWriter struct {
+	martini.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordResponseWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+//可信的反向代理，只有来自这些地址的请求才使用X-Real-IP/X-Forwarded-For
+type trustedProxies []*net.IPNet
+
+func parseTrustedProxies(s string) (trustedProxies, error) {
+	var proxies trustedProxies
+	for _, x := range strings.Split(s, ",") {
+		if x = strings.TrimSpace(x); x == "" {
+			continue
+		}
+		if !strings.Contains(x, "/") {
+			ip := net.ParseIP(x)
+			if ip == nil {
+				return nil, errors.Errorf("invalid trusted proxy %s", x)
+			}
+			proxies = append(proxies, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(x)
+		if err != nil {
+			return nil, errors.Errorf("invalid trusted proxy %s", x)
+		}
+		proxies = append(proxies, n)
+	}
+	return proxies, nil
+}
+
+func (p trustedProxies) contains(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//请求来自可信代理时使用代理记录的地址，X-Forwarded-For从右向左跳过可信代理，其他情况使用RemoteAddr
+func apiClientAddr(req *http.Request, proxies trustedProxies) string {
+	var remote = req.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !proxies.contains(remote) {
+		return remote
+	}
+	if val := strings.TrimSpace(req.Header.Get("X-Real-IP")); val != "" {
+		return val
+	}
+	if val := req.Header.Get("X-Forwarded-For"); val != "" {
+		addrs := strings.Split(val, ",")
+		for i := len(addrs) - 1; i >= 0; i-- {
+			addr := strings.TrimSpace(addrs[i])
+			if addr != "" && (i == 0 || !proxies.contains(addr)) {
+				return addr
+			}
+		}
+	}
+	return remote
+}
+
+func writeApiError(w http.ResponseWriter, err error) {
+	status, text := rpc.ApiResponseError(err)
+	w.WriteHeader(status)
+	w.Write([]byte(text))
+}
+
+//对修改类的admin api限流、限制并发，并处理Idempotency-Key
+func newApiGuard(t *Topom) martini.Handler {
+	var config = t.Config()
+	proxies, _ := parseTrustedProxies(config.AdminTrustedProxies)
+	var limiter *rateLimiter
+	if config.AdminRateLimit > 0 {
+		limiter = newRateLimiter(config.AdminRateLimit, config.AdminRateBurst)
+	}
+	var inflight chan struct{}
+	if config.AdminMaxInflight > 0 {
+		inflight = make(chan struct{}, config.AdminMaxInflight)
+	}
+	var cache *idempotencyCache
+	if config.AdminIdempotencyTTL > 0 {
+		cache = newIdempotencyCache(config.AdminIdempotencyTTL.Duration())
+	}
+
+	return func(w http.ResponseWriter, req *http.Request, c martini.Context) {
+		if req.Method == "GET" || !strings.HasPrefix(req.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+		client := apiClientAddr(req, proxies)
+
+		if limiter != nil && !limiter.allow(client, time.Now()) {
+			log.Warnf("[%p] API call %s from %s is rate limited", t, req.URL.Path, client)
+			writeApiError(w, errors.Errorf("too many requests from %s", client))
+			return
+		}
+
+		if inflight != nil {
+			select {
+			case inflight <- struct{}{}:
+				defer func() {
+					<-inflight
+				}()
+			default:
+				log.Warnf("[%p] API call %s from %s is rejected, too many inflight requests", t, req.URL.Path, client)
+				writeApiError(w, errors.Errorf("too many inflight requests"))
+				return
+			}
+		}
+
+		key := req.Header.Get(IdempotencyKeyHeader)
+		if cache == nil || key == "" {
+			c.Next()
+			return
+		}
+		key = req.Method + " " + req.URL.Path + " " + key
+
+		r, err := cache.acquire(key, time.Now())
+		if err != nil {
+			writeApiError(w, err)
+			return
+		}
+		if r != nil {
+			log.Warnf("[%p] API call %s from %s is replayed by idempotency key", t, req.URL.Path, client)
+			w.WriteHeader(r.status)
+			w.Write(r.body)
+			return
+		}
+
+		var done bool
+		defer func() {
+			//handler panic时释放key，允许重试
+			if !done {
+				cache.release(key, nil, time.Now())
+			}
+		}()
+		mw, ok := w.(martini.ResponseWriter)
+		if !ok {
+			mw = martini.NewResponseWriter(w)
+		}
+		rw := &recordResponseWriter{ResponseWriter: mw}
+		c.MapTo(rw, (*http.ResponseWriter)(nil))
+		c.Next()
+
+		r = &idempotentResponse{status: rw.Status(), body: rw.body.Bytes()}
+		cache.release(key, r, time.Now())
+		done = true
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestRateLimiter(x *testing.T) {
+	l := newRateLimiter(2, 3)
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		assert.Must(l.allow("a", now))
+	}
+	assert.Must(!l.allow("a", now))
+	assert.Must(l.allow("b", now))
+
+	now = now.Add(time.Millisecond * 500)
+	assert.Must(l.allow("a", now))
+	assert.Must(!l.allow("a", now))
+}
+
+func TestIdempotencyCache(x *testing.T) {
+	c := newIdempotencyCache(time.Minute)
+	now := time.Now()
+
+	r, err := c.acquire("k", now)
+	assert.MustNoError(err)
+	assert.Must(r == nil)
+
+	_, err = c.acquire("k", now)
+	assert.Must(err != nil)
+
+	c.release("k", &idempotentResponse{status: 200, body: []byte("OK")}, now)
+	r, err = c.acquire("k", now)
+	assert.MustNoError(err)
+	assert.Must(r != nil && r.status == 200 && string(r.body) == "OK")
+
+	r, err = c.acquire("k", now.Add(time.Minute*2))
+	assert.MustNoError(err)
+	assert.Must(r == nil)
+
+	c.release("k", nil, now)
+	r, err = c.acquire("k", now)
+	assert.MustNoError(err)
+	assert.Must(r == nil)
+}
+
+func TestApiClientAddr(x *testing.T) {
+	_, err := parseTrustedProxies("10.0.0.1,10.0.0.0/33")
+	assert.Must(err != nil)
+	proxies, err := parseTrustedProxies("10.0.0.1, 192.168.0.0/16")
+	assert.MustNoError(err)
+
+	newRequest := func(remote string, header ...string) *http.Request {
+		req := &http.Request{RemoteAddr: remote, Header: http.Header{}}
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		return req
+	}
+	//不可信的来源伪造的header被忽略
+	assert.Must(apiClientAddr(newRequest("1.2.3.4:5678", "X-Real-IP", "5.6.7.8"), proxies) == "1.2.3.4")
+	assert.Must(apiClientAddr(newRequest("1.2.3.4:5678", "X-Real-IP", "5.6.7.8"), nil) == "1.2.3.4")
+
+	assert.Must(apiClientAddr(newRequest("10.0.0.1:5678", "X-Real-IP", "5.6.7.8"), proxies) == "5.6.7.8")
+	assert.Must(apiClientAddr(newRequest("10.0.0.1:5678"), proxies) == "10.0.0.1")
+	//X-Forwarded-For中客户端伪造的地址在最左边，取最右边的不可信地址
+	req := newRequest("10.0.0.1:5678", "X-Forwarded-For", "5.6.7.8, 1.2.3.4, 192.168.1.1")
+	assert.Must(apiClientAddr(req, proxies) == "1.2.3.4")
+	req = newRequest("10.0.0.1:5678", "X-Forwarded-For", "192.168.1.2, 192.168.1.1")
+	assert.Must(apiClientAddr(req, proxies) == "192.168.1.2")
+}
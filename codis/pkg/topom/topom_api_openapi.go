@@ -0,0 +1,110 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"github.com/go-martini/martini"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils"
+	"github.com/CodisLabs/codis/pkg/utils/rpc"
+)
+
+//OpenAPI文档中接口的请求和响应类型，新增使用binding.Json的接口时需要在这里补充请求类型
+var topomApiOperations = map[string]*rpc.OpenAPIOperation{
+	"GET /topom":          {Response: Overview{}},
+	"GET /topom/model":    {Response: models.Topom{}},
+	"GET /topom/stats":    {Response: Stats{}},
+	"GET /topom/slots":    {Response: []*models.Slot{}},
+	"GET /topom/summary":  {Response: Summary{}},
+	"GET /topom/products": {Response: []string{}},
+
+	"GET /api/topom/model":        {Response: models.Topom{}},
+	"GET /api/topom/stats/:xauth": {Response: Stats{}},
+	"GET /api/topom/slots/:xauth": {Response: []*models.Slot{}},
+	"GET /api/topom/audit/:xauth": {Response: []*models.AuditEntry{}},
+
+	"GET /api/topom/proxy/discovery/:xauth":         {Response: []*DiscoveryProxy{}},
+	"GET /api/topom/group/health/:xauth":            {Response: map[int]string{}},
+	"GET /api/topom/group/memory/:xauth/:gid":       {Response: GroupMemoryPolicy{}},
+	"PUT /api/topom/group/decommission/:xauth/:gid": {Response: 0},
+
+	"GET /api/topom/slots/action/queue/:xauth":        {Response: []*models.SlotMapping{}},
+	"PUT /api/topom/slots/action/reorder/:xauth":      {Request: []int{}},
+	"PUT /api/topom/slots/assign/:xauth":              {Request: []*models.SlotMapping{}},
+	"PUT /api/topom/slots/assign/:xauth/offline":      {Request: []*models.SlotMapping{}},
+	"PUT /api/topom/slots/scale-out/:xauth":           {Request: ScaleOutRequest{}, Response: 0},
+	"GET /api/topom/slots/heat/:xauth":                {Response: models.SlotHeat{}},
+	"GET /api/topom/slots/progress/:xauth":            {Response: MigrationProgress{}},
+	"GET /api/topom/slots/history/:xauth/:sid":        {Response: models.SlotHistory{}},
+	"GET /api/topom/slots/rebalance/:xauth":           {Response: MigrationPlan{}},
+	"PUT /api/topom/slots/rebalance/:xauth":           {Response: MigrationPlan{}},
+	"GET /api/topom/slots/plan/:xauth/:pid":           {Response: MigrationPlan{}},
+	"PUT /api/topom/slots/plan/weighted/:xauth":       {Request: RebalanceWeights{}, Response: MigrationPlan{}},
+	"PUT /api/topom/slots/plan/apply-all/:xauth/:pid": {Response: []*MigrationStep{}},
+	"GET /api/topom/slots/verify/:xauth":              {Response: []*models.SlotVerifyReport{}},
+	"GET /api/topom/slots/verify/:xauth/:all":         {Response: []*models.SlotVerifyReport{}},
+
+	"GET /api/topom/scaling/status/:xauth": {Response: models.ScalingWorkflow{}},
+	"PUT /api/topom/scaling/start/:xauth":  {Request: ScalingRequest{}, Response: models.ScalingWorkflow{}},
+
+	"GET /api/topom/jobs/:xauth":     {Response: []*Job{}},
+	"GET /api/topom/jobs/:xauth/:id": {Response: Job{}},
+
+	"PUT /api/topom/clone/create/:xauth":        {Request: CloneRequest{}, Response: 0},
+	"GET /api/topom/report/json/:xauth/:period": {Response: Report{}},
+	"PUT /api/topom/backup/verify/:xauth":       {Request: BackupVerifyRequest{}, Response: 0},
+	"GET /api/topom/backup/verify/:xauth":       {Response: []*BackupVerifyDetail{}},
+	"PUT /api/topom/keyspace/diff/:xauth":       {Request: KeyspaceDiffRequest{}, Response: 0},
+	"GET /api/topom/keyspace/diff/:xauth":       {Response: []*KeyspaceDiffDetail{}},
+
+	"PUT /api/topom/topology/plan/:xauth":       {Request: DesiredState{}, Response: TopologyPlan{}},
+	"GET /api/topom/topology/plan/:xauth/:pid":  {Response: TopologyPlan{}},
+	"PUT /api/topom/topology/apply/:xauth/:pid": {Response: TopologyPlan{}},
+
+	"GET /api/topom/chaos/:xauth":                              {Response: []*ChaosFault{}},
+	"PUT /api/topom/chaos/master-down/:xauth/:gid/:secs":       {Response: ChaosFault{}},
+	"PUT /api/topom/chaos/blackhole/:xauth/:token/:addr/:secs": {Response: ChaosFault{}},
+	"PUT /api/topom/chaos/sentinel-delay/:xauth/:delay/:secs":  {Response: ChaosFault{}},
+
+	"GET /api/topom/switchover/status/:xauth": {Response: SwitchoverStatus{}},
+	"GET /api/topom/standby/status/:xauth":    {Response: models.Standby{}},
+	"GET /api/topom/master/sync/:xauth":       {Response: MasterSyncDiff{}},
+	"GET /api/topom/replication/list/:xauth":  {Response: []*ReplicationLinkStatus{}},
+
+	"GET /api/topom/template/list/:xauth":   {Response: []*models.ConfigTemplate{}},
+	"PUT /api/topom/template/update/:xauth": {Request: models.ConfigTemplate{}},
+	"GET /api/topom/template/drift/:xauth":  {Response: []*GroupConfigDrift{}},
+	"GET /api/topom/config/list/:xauth":     {Response: map[string]string{}},
+	"PUT /api/topom/config/update/:xauth":   {Request: models.ConfigOverrides{}},
+
+	"GET /api/topom/quota/list/:xauth":     {Response: []*models.KeyQuota{}},
+	"PUT /api/topom/quota/update/:xauth":   {Request: models.KeyQuota{}},
+	"PUT /api/topom/quota/remove/:xauth":   {Request: models.KeyQuota{}},
+	"GET /api/topom/route/list/:xauth":     {Response: []*models.PrefixRoute{}},
+	"PUT /api/topom/route/update/:xauth":   {Request: models.PrefixRoute{}},
+	"PUT /api/topom/route/remove/:xauth":   {Request: models.PrefixRoute{}},
+	"GET /api/topom/ttlrule/list/:xauth":   {Response: []*models.TTLRule{}},
+	"PUT /api/topom/ttlrule/update/:xauth": {Request: models.TTLRule{}},
+	"PUT /api/topom/ttlrule/remove/:xauth": {Request: models.TTLRule{}},
+
+	"GET /api/topom/namespace/list/:xauth":   {Response: []*models.Namespace{}},
+	"GET /api/topom/namespace/stats/:xauth":  {Response: []*NamespaceStats{}},
+	"PUT /api/topom/namespace/update/:xauth": {Request: models.Namespace{}},
+	"PUT /api/topom/namespace/remove/:xauth": {Request: models.Namespace{}},
+	"GET /api/topom/acl/list/:xauth":         {Response: []*models.ACLUser{}},
+	"PUT /api/topom/acl/update/:xauth":       {Request: models.ACLUser{}},
+	"PUT /api/topom/acl/remove/:xauth":       {Request: models.ACLUser{}},
+	"GET /api/topom/filter/get/:xauth":       {Response: models.RequestFilter{}},
+	"PUT /api/topom/filter/update/:xauth":    {Request: models.RequestFilter{}},
+}
+
+//由路由表生成，不需要xauth
+func (s *apiServer) OpenAPI(routes martini.Routes) (int, string) {
+	var list []rpc.OpenAPIRoute
+	for _, r := range routes.All() {
+		list = append(list, rpc.OpenAPIRoute{Method: r.Method(), Pattern: r.Pattern()})
+	}
+	return rpc.ApiResponseJson(rpc.NewOpenAPI("codis-dashboard", utils.Version, list, topomApiOperations))
+}
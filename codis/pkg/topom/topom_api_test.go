@@ -4,6 +4,7 @@
 package topom
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/CodisLabs/codis/pkg/models"
@@ -106,3 +107,24 @@ func TestApiProxy(x *testing.T) {
 	assert.MustNoError(c.ReinitProxy(p.Token))
 	assert.MustNoError(c.RemoveProxy(p.Token, false))
 }
+
+func TestApiOpenAPI(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	spec, err := newApiClient(t).OpenAPI()
+	assert.MustNoError(err)
+	paths := spec["paths"].(map[string]interface{})
+	for key := range topomApiOperations {
+		fields := strings.SplitN(key, " ", 2)
+		parts := strings.Split(fields[1], "/")
+		for i, s := range parts {
+			if strings.HasPrefix(s, ":") {
+				parts[i] = "{" + s[1:] + "}"
+			}
+		}
+		item, ok := paths[strings.Join(parts, "/")].(map[string]interface{})
+		assert.Must(ok)
+		assert.Must(item[strings.ToLower(fields[0])] != nil)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-martini/martini"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//fe转发请求时带上登录的用户名
+const AuditUserHeader = "X-Codis-User"
+
+const (
+	AuditSourceFe  = "fe"
+	AuditSourceApi = "api"
+)
+
+//最多保留的审计记录数
+const maxAuditEntries = 1000
+
+//调用者需要持有auditLog锁
+func (s *Topom) loadAuditLog() (*models.AuditLog, error) {
+	if s.auditLog.p != nil {
+		return s.auditLog.p, nil
+	}
+	p, err := s.store.LoadAuditLog(false)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = &models.AuditLog{}
+	}
+	s.auditLog.p = p
+	return p, nil
+}
+
+//记录一次修改操作，失败时只打印日志
+func (s *Topom) recordAudit(e *models.AuditEntry) {
+	log.Warnf("[%p] audit: %s %s %s from %s (%s), status = %d", s, e.User, e.Method, e.Path, e.Addr, e.Source, e.Status)
+
+	s.auditLog.Lock()
+	defer s.auditLog.Unlock()
+
+	p, err := s.loadAuditLog()
+	if err != nil {
+		log.WarnErrorf(err, "store: load audit log failed")
+		return
+	}
+	p.Entries = append(p.Entries, e)
+	if n := len(p.Entries) - maxAuditEntries; n > 0 {
+		p.Entries = p.Entries[n:]
+	}
+	if err := s.store.UpdateAuditLog(p); err != nil {
+		log.WarnErrorf(err, "store: update audit log failed")
+	}
+}
+
+//按时间倒序返回最近的审计记录，user不为空时只返回该用户的记录
+func (s *Topom) AuditLog(limit int, user string) ([]*models.AuditEntry, error) {
+	s.auditLog.Lock()
+	defer s.auditLog.Unlock()
+
+	p, err := s.loadAuditLog()
+	if err != nil {
+		log.ErrorErrorf(err, "store: load audit log failed")
+		return nil, errors.Errorf("store: load audit log failed")
+	}
+	return filterAuditEntries(p.Entries, limit, user), nil
+}
+
+func filterAuditEntries(entries []*models.AuditEntry, limit int, user string) []*models.AuditEntry {
+	var list = []*models.AuditEntry{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if limit > 0 && len(list) >= limit {
+			break
+		}
+		if e := entries[i]; user == "" || e.User == user {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+//记录所有修改类的admin api调用，路径中的xauth会被隐藏
+func newApiAudit(t *Topom) martini.Handler {
+	proxies, _ := parseTrustedProxies(t.Config().AdminTrustedProxies)
+	return func(w http.ResponseWriter, req *http.Request, c martini.Context) {
+		if req.Method == "GET" || !strings.HasPrefix(req.URL.Path, "/api/") {
+			c.Next()
+			return
+		}
+		c.Next()
+
+		e := &models.AuditEntry{
+			Time:   time.Now().Unix(),
+			User:   req.Header.Get(AuditUserHeader),
+			Source: AuditSourceApi,
+			Addr:   apiClientAddr(req, proxies),
+			Method: req.Method,
+			Path:   strings.Replace(req.URL.Path, t.XAuth(), "-", -1),
+		}
+		if e.User != "" {
+			e.Source = AuditSourceFe
+		}
+		if mw, ok := w.(martini.ResponseWriter); ok {
+			e.Status = mw.Status()
+		}
+		t.recordAudit(e)
+	}
+}
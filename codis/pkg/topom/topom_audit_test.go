@@ -0,0 +1,31 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestFilterAuditEntries(x *testing.T) {
+	entries := []*models.AuditEntry{
+		{Time: 1, User: "alice"},
+		{Time: 2, User: "bob"},
+		{Time: 3},
+		{Time: 4, User: "alice"},
+	}
+	list := filterAuditEntries(entries, 0, "")
+	assert.Must(len(list) == 4 && list[0].Time == 4 && list[3].Time == 1)
+
+	list = filterAuditEntries(entries, 2, "")
+	assert.Must(len(list) == 2 && list[0].Time == 4 && list[1].Time == 3)
+
+	list = filterAuditEntries(entries, 0, "alice")
+	assert.Must(len(list) == 2 && list[0].Time == 4 && list[1].Time == 1)
+
+	list = filterAuditEntries(nil, 10, "")
+	assert.Must(list != nil && len(list) == 0)
+}
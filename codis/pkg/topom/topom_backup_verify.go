@@ -0,0 +1,261 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/redis"
+)
+
+//备份校验请求，Scratch为已经加载了group备份文件的临时实例，由调用方在备份完成后恢复并启动
+//从Scratch中随机抽样Samples个key，与group当前的master比较类型和值，按类型读取，不依赖DUMP
+//备份之后源端仍有写入，不一致的比例不超过Tolerance时认为校验通过
+type BackupVerifyRequest struct {
+	GroupId   int     `json:"group_id"`
+	Scratch   string  `json:"scratch"`
+	Samples   int     `json:"samples"`
+	Tolerance float64 `json:"tolerance"`
+}
+
+type BackupVerifyDetail struct {
+	GroupId   int     `json:"group_id"`
+	Source    string  `json:"source"`
+	Scratch   string  `json:"scratch"`
+	Tolerance float64 `json:"tolerance"`
+
+	Sampled int `json:"sampled"`
+	Matched int `json:"matched"`
+	//备份中存在但源端已经删除的key
+	Missing    []string `json:"missing,omitempty"`
+	Mismatched []string `json:"mismatched,omitempty"`
+
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+
+	UpdateTime string `json:"update_time"`
+}
+
+const (
+	JobTypeBackupVerify = "backup-verify"
+
+	BackupVerifyStepSampling  = "sampling"
+	BackupVerifyStepComparing = "comparing"
+
+	defaultBackupVerifySamples = 1000
+	maxBackupVerifySamples     = 100000
+
+	//Missing和Mismatched最多记录的key数
+	maxBackupVerifyKeys = 100
+)
+
+//创建备份校验任务，返回任务id
+func (s *Topom) BackupVerifyJob(req *BackupVerifyRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, err
+	}
+
+	if req.Scratch == "" {
+		return 0, errors.Errorf("invalid scratch address")
+	}
+	if req.Samples <= 0 {
+		req.Samples = defaultBackupVerifySamples
+	}
+	if req.Samples > maxBackupVerifySamples {
+		return 0, errors.Errorf("invalid samples = %d", req.Samples)
+	}
+	if req.Tolerance < 0 || req.Tolerance > 1 {
+		return 0, errors.Errorf("invalid tolerance = %v", req.Tolerance)
+	}
+	g, err := ctx.getGroup(req.GroupId)
+	if err != nil {
+		return 0, err
+	}
+	source := ctx.getGroupMaster(g.Id)
+	if source == "" {
+		return 0, errors.Errorf("group-[%d] is empty", g.Id)
+	}
+	for _, x := range g.Servers {
+		if x.Addr == req.Scratch {
+			return 0, errors.Errorf("server-[%s] belongs to group-[%d]", req.Scratch, g.Id)
+		}
+	}
+
+	detail := &BackupVerifyDetail{
+		GroupId: g.Id, Source: source, Scratch: req.Scratch,
+		Tolerance: req.Tolerance,
+	}
+	j := s.newJob(JobTypeBackupVerify, detail)
+	j.onCancel(func() error {
+		return nil
+	})
+	j.update(BackupVerifyStepSampling, 0)
+	log.Warnf("backup-verify: job-[%d] group-[%d] %s -> %s created", j.Id, g.Id, source, req.Scratch)
+
+	go s.runBackupVerifyJob(j, detail, req.Samples)
+	return j.Id, nil
+}
+
+func (s *Topom) runBackupVerifyJob(j *Job, detail *BackupVerifyDetail, samples int) {
+	err := s.backupVerify(j, detail, samples)
+	j.updateDetail(func() {
+		if err != nil {
+			detail.Error = err.Error()
+		}
+		detail.Passed = err == nil && backupVerifyPassed(detail)
+		detail.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	})
+	if j.done() {
+		log.Warnf("backup-verify: job-[%d] cancelled", j.Id)
+		return
+	}
+
+	var report BackupVerifyDetail
+	j.updateDetail(func() {
+		report = *detail
+	})
+	s.backupVerifyReports.Lock()
+	s.backupVerifyReports.m[report.GroupId] = &report
+	s.backupVerifyReports.Unlock()
+
+	switch {
+	case err != nil:
+		log.WarnErrorf(err, "backup-verify: job-[%d] group-[%d] failed", j.Id, report.GroupId)
+	case !report.Passed:
+		err = errors.Errorf("backup of group-[%d] is inconsistent, sampled = %d, missing = %d, mismatched = %d",
+			report.GroupId, report.Sampled, len(report.Missing), len(report.Mismatched))
+		log.Errorf("backup-verify: job-[%d] %s", j.Id, err)
+	default:
+		log.Warnf("backup-verify: job-[%d] group-[%d] passed, sampled = %d", j.Id, report.GroupId, report.Sampled)
+	}
+	j.finish(err)
+}
+
+//用RANDOMKEY在临时实例上抽样，重复的key不计数，尝试次数有上限以免key数较少时不能结束
+func (s *Topom) backupVerify(j *Job, detail *BackupVerifyDetail, samples int) error {
+	scratch, err := redis.NewClient(detail.Scratch, s.config.ProductAuth, time.Second*5)
+	if err != nil {
+		return err
+	}
+	defer scratch.Close()
+
+	var values = make(map[string]*keyspaceDiffValue)
+	for i := 0; i < samples*2 && len(values) < samples; i++ {
+		if j.done() {
+			return nil
+		}
+		reply, err := scratch.Do("RANDOMKEY")
+		if err != nil {
+			return errors.Errorf("scratch-[%s] randomkey failed: %s", detail.Scratch, err)
+		}
+		b, ok := reply.([]byte)
+		if !ok {
+			break
+		}
+		key := string(b)
+		if _, ok := values[key]; ok {
+			continue
+		}
+		v, err := readKeyspaceDiffValue(scratch, key, true)
+		if err != nil {
+			return errors.Errorf("scratch-[%s] read key failed: %s", detail.Scratch, err)
+		}
+		if v.pttl != -2 {
+			values[key] = v
+		}
+		if i%100 == 0 {
+			j.update(BackupVerifyStepSampling, len(values)*50/samples)
+		}
+	}
+	if len(values) == 0 {
+		return errors.Errorf("scratch-[%s] has no key", detail.Scratch)
+	}
+
+	var keys = make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	j.update(BackupVerifyStepComparing, 50)
+
+	c, err := s.action.redisp.GetClient(detail.Source)
+	if err != nil {
+		return err
+	}
+	defer s.action.redisp.PutClient(c, err)
+
+	for i, key := range keys {
+		if j.done() {
+			return nil
+		}
+		var v *keyspaceDiffValue
+		v, err = readKeyspaceDiffValue(c, key, true)
+		if err != nil {
+			return errors.Errorf("server-[%s] read key failed: %s", detail.Source, err)
+		}
+		j.updateDetail(func() {
+			detail.Sampled++
+			switch x := values[key]; {
+			case v.pttl == -2:
+				detail.Missing = appendBackupVerifyKey(detail.Missing, key)
+			case v.typ != x.typ || v.crc != x.crc:
+				detail.Mismatched = appendBackupVerifyKey(detail.Mismatched, key)
+			default:
+				detail.Matched++
+			}
+		})
+		if i%100 == 0 {
+			j.update(BackupVerifyStepComparing, 50+i*50/len(keys))
+		}
+	}
+	return nil
+}
+
+func appendBackupVerifyKey(list []string, key string) []string {
+	if len(list) >= maxBackupVerifyKeys {
+		return list
+	}
+	return append(list, key)
+}
+
+//Missing和Mismatched只记录部分key，不一致的数量用Sampled-Matched计算
+func backupVerifyPassed(d *BackupVerifyDetail) bool {
+	if d.Sampled == 0 {
+		return false
+	}
+	return float64(d.Sampled-d.Matched) <= float64(d.Sampled)*d.Tolerance
+}
+
+//返回每个group最近一次的备份校验结果，按group id排序
+func (s *Topom) BackupVerifyReports() []*BackupVerifyDetail {
+	s.backupVerifyReports.Lock()
+	defer s.backupVerifyReports.Unlock()
+	var list = make([]*BackupVerifyDetail, 0, len(s.backupVerifyReports.m))
+	for _, r := range s.backupVerifyReports.m {
+		x := *r
+		list = append(list, &x)
+	}
+	sort.Sort(sliceBackupVerifyDetail(list))
+	return list
+}
+
+type sliceBackupVerifyDetail []*BackupVerifyDetail
+
+func (s sliceBackupVerifyDetail) Len() int {
+	return len(s)
+}
+
+func (s sliceBackupVerifyDetail) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceBackupVerifyDetail) Less(i, j int) bool {
+	return s[i].GroupId < s[j].GroupId
+}
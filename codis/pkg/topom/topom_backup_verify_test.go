@@ -0,0 +1,73 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestBackupVerifyPassed(x *testing.T) {
+	assert.Must(!backupVerifyPassed(&BackupVerifyDetail{}))
+	assert.Must(backupVerifyPassed(&BackupVerifyDetail{Sampled: 100, Matched: 100}))
+	assert.Must(!backupVerifyPassed(&BackupVerifyDetail{Sampled: 100, Matched: 99}))
+	assert.Must(backupVerifyPassed(&BackupVerifyDetail{Sampled: 100, Matched: 99, Tolerance: 0.01}))
+	assert.Must(!backupVerifyPassed(&BackupVerifyDetail{Sampled: 100, Matched: 98, Tolerance: 0.01}))
+	assert.Must(backupVerifyPassed(&BackupVerifyDetail{Sampled: 10, Matched: 0, Tolerance: 1}))
+}
+
+func TestAppendBackupVerifyKey(x *testing.T) {
+	var list []string
+	for i := 0; i < maxBackupVerifyKeys*2; i++ {
+		list = appendBackupVerifyKey(list, "key")
+	}
+	assert.Must(len(list) == maxBackupVerifyKeys)
+}
+
+func TestBackupVerifyJob(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	_, err := t.BackupVerifyJob(&BackupVerifyRequest{GroupId: 1})
+	assert.Must(err != nil)
+	_, err = t.BackupVerifyJob(&BackupVerifyRequest{GroupId: 1, Scratch: "127.0.0.1:6380", Tolerance: 2})
+	assert.Must(err != nil)
+	_, err = t.BackupVerifyJob(&BackupVerifyRequest{GroupId: 1, Scratch: "127.0.0.1:6380"})
+	assert.Must(err != nil)
+	assert.Must(len(t.BackupVerifyReports()) == 0)
+}
+
+func TestBackupVerifyCompare(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	source := newFakeServer()
+	defer source.Close()
+	scratch := newFakeServer()
+	defer scratch.Close()
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: source.Addr}}})
+
+	//备份之后a被删除，b被修改
+	scratch.SetKey("a", "1")
+	scratch.SetKey("b", "2")
+	scratch.SetKey("c", "3")
+	source.SetKey("b", "4")
+	source.SetKey("c", "3")
+
+	id, err := t.BackupVerifyJob(&BackupVerifyRequest{GroupId: 1, Scratch: scratch.Addr, Samples: 3})
+	assert.MustNoError(err)
+	j := t.getJob(id)
+	for i := 0; i < 50 && !j.done(); i++ {
+		time.Sleep(time.Millisecond * 100)
+	}
+	list := t.BackupVerifyReports()
+	assert.Must(j.done() && len(list) == 1)
+	r := list[0]
+	assert.Must(r.Error == "" && !r.Passed && r.Sampled == 3 && r.Matched == 1)
+	assert.Must(len(r.Missing) == 1 && r.Missing[0] == "a")
+	assert.Must(len(r.Mismatched) == 1 && r.Mismatched[0] == "b")
+}
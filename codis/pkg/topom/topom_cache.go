@@ -82,8 +82,10 @@ func (s *Topom) refillCache() error {
 
 func (s *Topom) refillCacheSlots(slots []*models.SlotMapping) ([]*models.SlotMapping, error) {
 	// 如果是备机房dashboard, slots的状态需要去主机房数据库中获取
+	// 关闭master_auto_sync后只通过MasterSync同步
+	var reload = s.Config().MasterProduct != "" && s.Config().MasterAutoSync
 	var store *models.Store
-	if s.Config().MasterProduct != "" {
+	if reload {
 		store = s.slaveStore
 	} else {
 		store = s.store
@@ -93,7 +95,7 @@ func (s *Topom) refillCacheSlots(slots []*models.SlotMapping) ([]*models.SlotMap
 		// return store.SlotMappings()
 		newSlots, err := store.SlotMappings()
 
-		if err == nil && s.Config().MasterProduct != "" {
+		if err == nil && reload {
 			for i, _ := range newSlots {
 				if err := s.storeUpdateSlotMapping(newSlots[i]); err != nil {
 					log.Warnf("reload slot-[%d] info to local failed", i)
@@ -118,7 +120,7 @@ func (s *Topom) refillCacheSlots(slots []*models.SlotMapping) ([]*models.SlotMap
 		}
 
 		// 备机房将读取到的主机房slots信息保存到本地
-		if s.Config().MasterProduct != "" {
+		if reload {
 			if err := s.storeUpdateSlotMapping(slots[i]); err != nil {
 				return nil, err
 			}
@@ -189,6 +191,7 @@ func (s *Topom) storeUpdateSlotMapping(m *models.SlotMapping) error {
 		log.ErrorErrorf(err, "store: update slot-[%d] failed", m.Id)
 		return errors.Errorf("store: update slot-[%d] failed", m.Id)
 	}
+	s.recordSlotTransition(m)
 	return nil
 }
 
@@ -254,3 +257,48 @@ func (s *Topom) storeUpdateSentinel(p *models.Sentinel) error {
 	}
 	return nil
 }
+
+func (s *Topom) storeUpdateTemplate(t *models.ConfigTemplate) error {
+	log.Warnf("update template-[%s]:\n%s", t.Name, t.Encode())
+	if err := s.store.UpdateTemplate(t); err != nil {
+		log.ErrorErrorf(err, "store: update template-[%s] failed", t.Name)
+		return errors.Errorf("store: update template-[%s] failed", t.Name)
+	}
+	return nil
+}
+
+func (s *Topom) storeRemoveTemplate(t *models.ConfigTemplate) error {
+	log.Warnf("remove template-[%s]:\n%s", t.Name, t.Encode())
+	if err := s.store.DeleteTemplate(t.Name); err != nil {
+		log.ErrorErrorf(err, "store: remove template-[%s] failed", t.Name)
+		return errors.Errorf("store: remove template-[%s] failed", t.Name)
+	}
+	return nil
+}
+
+func (s *Topom) storeUpdateReplication(r *models.ReplicationLink) error {
+	log.Warnf("update replication-[%s]:\n%s", r.Secondary, r.Encode())
+	if err := s.store.UpdateReplication(r); err != nil {
+		log.ErrorErrorf(err, "store: update replication-[%s] failed", r.Secondary)
+		return errors.Errorf("store: update replication-[%s] failed", r.Secondary)
+	}
+	return nil
+}
+
+func (s *Topom) storeUpdateStandby(p *models.Standby) error {
+	log.Warnf("update standby:\n%s", p.Encode())
+	if err := s.store.UpdateStandby(p); err != nil {
+		log.ErrorErrorf(err, "store: update standby failed")
+		return errors.Errorf("store: update standby failed")
+	}
+	return nil
+}
+
+func (s *Topom) storeRemoveReplication(r *models.ReplicationLink) error {
+	log.Warnf("remove replication-[%s]:\n%s", r.Secondary, r.Encode())
+	if err := s.store.DeleteReplication(r.Secondary); err != nil {
+		log.ErrorErrorf(err, "store: remove replication-[%s] failed", r.Secondary)
+		return errors.Errorf("store: remove replication-[%s] failed", r.Secondary)
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/redis"
+)
+
+const (
+	//暂停group master上所有客户端的请求，sentinel认为master下线后触发主从切换
+	ChaosMasterDown = "master-down"
+	//proxy不再向某个后端发送请求，模拟proxy与后端之间的网络分区
+	ChaosBlackhole = "blackhole"
+	//dashboard延迟处理sentinel的主从切换通知
+	ChaosSentinelDelay = "sentinel-delay"
+)
+
+//混沌测试注入的故障，到期后自动恢复
+type ChaosFault struct {
+	Id      int    `json:"id"`
+	Kind    string `json:"kind"`
+	GroupId int    `json:"group_id,omitempty"`
+	Addr    string `json:"addr,omitempty"`
+	Token   string `json:"token,omitempty"`
+	DelayMs int64  `json:"delay_ms,omitempty"`
+
+	CreateTime string `json:"create_time"`
+	ExpireTime string `json:"expire_time"`
+
+	recover func() error
+	timer   *time.Timer
+}
+
+func (s *Topom) checkChaos(d time.Duration) error {
+	if !s.config.ChaosEnabled {
+		return errors.Errorf("chaos operations are disabled")
+	}
+	if s.IsClosed() {
+		return ErrClosedTopom
+	}
+	if d <= 0 || d > s.config.ChaosMaxDuration.Duration() {
+		return errors.Errorf("invalid chaos duration = %s, max = %s", d, s.config.ChaosMaxDuration.Duration())
+	}
+	return nil
+}
+
+//登记故障并启动恢复定时器
+func (s *Topom) addChaosFault(f *ChaosFault, d time.Duration) *ChaosFault {
+	s.chaosFaults.Lock()
+	defer s.chaosFaults.Unlock()
+	s.chaosFaults.nextId++
+	f.Id = s.chaosFaults.nextId
+	now := time.Now()
+	f.CreateTime = now.Format("2006-01-02 15:04:05")
+	f.ExpireTime = now.Add(d).Format("2006-01-02 15:04:05")
+	s.chaosFaults.m[f.Id] = f
+
+	id := f.Id
+	f.timer = time.AfterFunc(d, func() {
+		if err := s.ChaosRecover(id); err != nil {
+			log.WarnErrorf(err, "chaos: fault-[%d] auto recover failed", id)
+		}
+	})
+	log.Warnf("chaos: fault-[%d] %s injected, expire at %s", f.Id, f.Kind, f.ExpireTime)
+	return f
+}
+
+//CLIENT PAUSE到期后master自动恢复，恢复时再发送CLIENT UNPAUSE以便提前结束
+func (s *Topom) ChaosMasterDown(gid int, d time.Duration) (*ChaosFault, error) {
+	if err := s.checkChaos(d); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if _, err := ctx.getGroup(gid); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	addr := ctx.getGroupMaster(gid)
+	s.mu.Unlock()
+	if addr == "" {
+		return nil, errors.Errorf("group-[%d] is empty", gid)
+	}
+
+	c, err := redis.NewClient(addr, s.config.ProductAuth, time.Second*5)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	if _, err := c.Do("CLIENT", "PAUSE", int64(d/time.Millisecond)); err != nil {
+		return nil, errors.Errorf("server-[%s] client pause failed: %s", addr, err)
+	}
+
+	f := &ChaosFault{Kind: ChaosMasterDown, GroupId: gid, Addr: addr}
+	f.recover = func() error {
+		c, err := redis.NewClient(addr, s.config.ProductAuth, time.Second*5)
+		if err != nil {
+			return err
+		}
+		defer c.Close()
+		if _, err := c.Do("CLIENT", "UNPAUSE"); err != nil {
+			return errors.Errorf("server-[%s] client unpause failed: %s", addr, err)
+		}
+		return nil
+	}
+	return s.addChaosFault(f, d), nil
+}
+
+//proxy端也会在到期后自动恢复，dashboard退出时不会残留
+func (s *Topom) ChaosBlackhole(token, addr string, d time.Duration) (*ChaosFault, error) {
+	if err := s.checkChaos(d); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	p, err := ctx.getProxy(token)
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	if _, _, err := ctx.getGroupByServer(addr); err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	s.mu.Unlock()
+
+	c := s.newProxyClient(p)
+	if err := c.SetBackendBlackhole(addr, d); err != nil {
+		return nil, errors.Errorf("proxy-[%s] blackhole backend-[%s] failed: %s", token, addr, err)
+	}
+
+	f := &ChaosFault{Kind: ChaosBlackhole, Token: token, Addr: addr}
+	f.recover = func() error {
+		return c.SetBackendBlackhole(addr, 0)
+	}
+	return s.addChaosFault(f, d), nil
+}
+
+//同一时间只能有一个sentinel延迟故障
+func (s *Topom) ChaosSentinelDelay(delay, d time.Duration) (*ChaosFault, error) {
+	if err := s.checkChaos(d); err != nil {
+		return nil, err
+	}
+	if delay <= 0 || delay > d {
+		return nil, errors.Errorf("invalid sentinel delay = %s", delay)
+	}
+	s.chaosFaults.Lock()
+	for _, f := range s.chaosFaults.m {
+		if f.Kind == ChaosSentinelDelay {
+			s.chaosFaults.Unlock()
+			return nil, errors.Errorf("chaos fault-[%d] %s already exists", f.Id, f.Kind)
+		}
+	}
+	s.chaosFaults.sentinelDelay.Set(int64(delay))
+	s.chaosFaults.Unlock()
+
+	f := &ChaosFault{Kind: ChaosSentinelDelay, DelayMs: int64(delay / time.Millisecond)}
+	f.recover = func() error {
+		s.chaosFaults.sentinelDelay.Set(0)
+		return nil
+	}
+	return s.addChaosFault(f, d), nil
+}
+
+func (s *Topom) chaosSentinelDelay() time.Duration {
+	return time.Duration(s.chaosFaults.sentinelDelay.Int64())
+}
+
+//立即恢复故障，不需要chaos_enabled，以便关闭开关后仍能清理
+func (s *Topom) ChaosRecover(id int) error {
+	s.chaosFaults.Lock()
+	f := s.chaosFaults.m[id]
+	delete(s.chaosFaults.m, id)
+	s.chaosFaults.Unlock()
+	if f == nil {
+		return errors.Errorf("chaos fault-[%d] doesn't exist", id)
+	}
+	f.timer.Stop()
+	if err := f.recover(); err != nil {
+		return err
+	}
+	log.Warnf("chaos: fault-[%d] %s recovered", f.Id, f.Kind)
+	return nil
+}
+
+//dashboard关闭时恢复所有故障
+func (s *Topom) chaosRecoverAll() {
+	for _, f := range s.ChaosFaults() {
+		if err := s.ChaosRecover(f.Id); err != nil {
+			log.WarnErrorf(err, "chaos: fault-[%d] recover failed", f.Id)
+		}
+	}
+}
+
+func (s *Topom) ChaosFaults() []*ChaosFault {
+	s.chaosFaults.Lock()
+	defer s.chaosFaults.Unlock()
+	var list = make([]*ChaosFault, 0, len(s.chaosFaults.m))
+	for _, f := range s.chaosFaults.m {
+		list = append(list, f)
+	}
+	sort.Sort(sliceChaosFault(list))
+	return list
+}
+
+type sliceChaosFault []*ChaosFault
+
+func (s sliceChaosFault) Len() int {
+	return len(s)
+}
+
+func (s sliceChaosFault) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceChaosFault) Less(i, j int) bool {
+	return s[i].Id < s[j].Id
+}
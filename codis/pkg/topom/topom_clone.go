@@ -0,0 +1,337 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//克隆请求，Servers为源group到目标product中server的映射，第一个server作为目标group的master
+type CloneRequest struct {
+	Product string           `json:"product"`
+	Servers map[int][]string `json:"servers"`
+}
+
+type CloneGroup struct {
+	Id         int      `json:"id"`
+	Source     string   `json:"source"`
+	Servers    []string `json:"servers"`
+	LinkStatus string   `json:"link_status"`
+	Synced     bool     `json:"synced"`
+}
+
+type CloneDetail struct {
+	Product string        `json:"product"`
+	Groups  []*CloneGroup `json:"groups"`
+	//是否已与源集群断开复制
+	Detached bool `json:"detached"`
+}
+
+const (
+	JobTypeClone = "clone"
+
+	CloneStepTopology = "topology"
+	CloneStepSyncing  = "syncing"
+	CloneStepSynced   = "synced"
+	CloneStepDetached = "detached"
+)
+
+//将当前product的拓扑复制到新的product，并通过主从复制同步数据，返回任务id
+//拓扑在锁内创建，SLAVEOF在锁外执行，任何一步失败都会删除已经创建的拓扑
+func (s *Topom) CloneProduct(req *CloneRequest) (int, error) {
+	j, detail, err := s.createCloneTopology(req)
+	if err != nil {
+		if j == nil {
+			return 0, err
+		}
+		j.finish(err)
+		return j.Id, err
+	}
+	log.Warnf("clone: job-[%d] create topology of product-[%s] done", j.Id, req.Product)
+
+	for i, cg := range detail.Groups {
+		if err := s.cloneGroupReplicate(cg); err != nil {
+			s.rollbackCloneReplicate(j, detail.Groups[:i+1])
+			s.rollbackCloneTopology(j, detail, len(detail.Groups), MaxSlotNum)
+			j.finish(err)
+			return j.Id, err
+		}
+	}
+	j.update(CloneStepSyncing, 0)
+
+	go s.trackCloneJob(j, detail)
+	return j.Id, nil
+}
+
+func (s *Topom) createCloneTopology(req *CloneRequest) (*Job, *CloneDetail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := models.ValidateProduct(req.Product); err != nil {
+		return nil, nil, err
+	}
+	if req.Product == s.config.ProductName {
+		return nil, nil, errors.Errorf("clone to the same product-[%s]", req.Product)
+	}
+
+	var servers = make(map[string]bool)
+	for _, g := range ctx.group {
+		for _, x := range g.Servers {
+			servers[x.Addr] = true
+		}
+	}
+
+	var detail = &CloneDetail{Product: req.Product}
+	for _, g := range models.SortGroup(ctx.group) {
+		if len(g.Servers) == 0 {
+			continue
+		}
+		if g.Promoting.State != models.ActionNothing {
+			return nil, nil, errors.Errorf("group-[%d] is promoting", g.Id)
+		}
+		addrs := req.Servers[g.Id]
+		if len(addrs) == 0 {
+			return nil, nil, errors.Errorf("group-[%d] has no target servers", g.Id)
+		}
+		for _, addr := range addrs {
+			if servers[addr] {
+				return nil, nil, errors.Errorf("server-[%s] already exists", addr)
+			}
+			servers[addr] = true
+		}
+		detail.Groups = append(detail.Groups, &CloneGroup{
+			Id: g.Id, Source: g.Servers[0].Addr, Servers: addrs,
+		})
+	}
+	if len(detail.Groups) == 0 {
+		return nil, nil, errors.Errorf("no group to clone")
+	}
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing {
+			return nil, nil, errors.Errorf("slot-[%d] action is not finished", m.Id)
+		}
+	}
+
+	store := models.NewStore(s.store.Client(), req.Product)
+	if t, err := store.LoadTopom(false); err != nil {
+		return nil, nil, err
+	} else if t != nil {
+		return nil, nil, errors.Errorf("product-[%s] is online", req.Product)
+	}
+	if group, err := store.ListGroup(); err != nil {
+		return nil, nil, err
+	} else if len(group) != 0 {
+		return nil, nil, errors.Errorf("product-[%s] already has groups", req.Product)
+	}
+
+	j := s.newJob(JobTypeClone, detail)
+	j.update(CloneStepTopology, 0)
+
+	for i, cg := range detail.Groups {
+		g := &models.Group{Id: cg.Id}
+		for _, addr := range cg.Servers {
+			g.Servers = append(g.Servers, &models.GroupServer{Addr: addr})
+		}
+		if err := store.UpdateGroup(g); err != nil {
+			s.rollbackCloneTopology(j, detail, i, 0)
+			return j, nil, errors.Errorf("store: create group-[%d] of product-[%s] failed: %s", g.Id, req.Product, err)
+		}
+	}
+	for i, m := range ctx.slots {
+		if err := store.UpdateSlotMapping(&models.SlotMapping{Id: m.Id, GroupId: m.GroupId}); err != nil {
+			s.rollbackCloneTopology(j, detail, len(detail.Groups), i)
+			return j, nil, errors.Errorf("store: create slot-[%d] of product-[%s] failed: %s", m.Id, req.Product, err)
+		}
+	}
+	return j, detail, nil
+}
+
+//删除已经创建的前groups个group和前slots个slot
+func (s *Topom) rollbackCloneTopology(j *Job, detail *CloneDetail, groups, slots int) {
+	store := models.NewStore(s.store.Client(), detail.Product)
+	for sid := 0; sid < slots; sid++ {
+		if err := store.DeleteSlotMapping(sid); err != nil {
+			log.WarnErrorf(err, "clone: job-[%d] remove slot-[%d] of product-[%s] failed", j.Id, sid, detail.Product)
+		}
+	}
+	for _, cg := range detail.Groups[:groups] {
+		if err := store.DeleteGroup(cg.Id); err != nil {
+			log.WarnErrorf(err, "clone: job-[%d] remove group-[%d] of product-[%s] failed", j.Id, cg.Id, detail.Product)
+		}
+	}
+	log.Warnf("clone: job-[%d] topology of product-[%s] removed", j.Id, detail.Product)
+}
+
+//已经执行过SLAVEOF的server断开复制，失败时只记录日志
+func (s *Topom) rollbackCloneReplicate(j *Job, groups []*CloneGroup) {
+	for _, cg := range groups {
+		for _, addr := range cg.Servers {
+			c, err := s.action.redisp.GetClient(addr)
+			if err != nil {
+				continue
+			}
+			err = c.SetMaster("NO:ONE")
+			s.action.redisp.PutClient(c, err)
+			if err != nil {
+				log.WarnErrorf(err, "clone: job-[%d] server-[%s] slaveof no one failed", j.Id, addr)
+			}
+		}
+	}
+}
+
+//目标master作为源master的从库，目标group中的其他server作为目标master的从库
+//这里直接使用SLAVEOF而不是按slot导入：目标server都不属于任何group，全量同步会覆盖上面的旧数据，
+//源master只是多了一个从库；目标product没有在线的dashboard，断开复制之前目标server只读，不会有双写
+//同步期间源集群的slot迁移同样会复制到目标server，断开复制时再按源集群当前的slot映射更新目标product
+func (s *Topom) cloneGroupReplicate(cg *CloneGroup) error {
+	for i, addr := range cg.Servers {
+		var master = cg.Source
+		if i != 0 {
+			master = cg.Servers[0]
+		}
+		c, err := s.action.redisp.GetClient(addr)
+		if err != nil {
+			return err
+		}
+		err = c.SetMaster(master)
+		s.action.redisp.PutClient(c, err)
+		if err != nil {
+			return errors.Errorf("server-[%s] slaveof %s failed: %s", addr, master, err)
+		}
+	}
+	return nil
+}
+
+func (s *Topom) trackCloneJob(j *Job, detail *CloneDetail) {
+	var ticker = time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.exit.C:
+			return
+		case <-ticker.C:
+		}
+
+		var detached bool
+		j.updateDetail(func() {
+			detached = detail.Detached
+		})
+		if detached {
+			return
+		}
+
+		var n int
+		for _, cg := range detail.Groups {
+			var status string
+			if m, err := s.action.redisp.Info(cg.Servers[0]); err != nil {
+				status = fmt.Sprintf("error: %s", err)
+			} else {
+				status = m["master_link_status"]
+			}
+			j.updateDetail(func() {
+				cg.LinkStatus = status
+				cg.Synced = status == "up"
+			})
+			if status == "up" {
+				n++
+			}
+		}
+		if n == len(detail.Groups) {
+			j.update(CloneStepSynced, 100)
+		} else {
+			j.update(CloneStepSyncing, n*100/len(detail.Groups))
+		}
+	}
+}
+
+//断开目标product与源集群的复制，克隆完成
+func (s *Topom) CloneFinish(id int) error {
+	j := s.getJob(id)
+	if j == nil || j.Type != JobTypeClone {
+		return errors.Errorf("clone job-[%d] doesn't exist", id)
+	}
+	detail := j.detail.(*CloneDetail)
+
+	var state, step string
+	j.updateDetail(func() {
+		state, step = j.State, j.Step
+	})
+	if state != JobRunning {
+		return errors.Errorf("clone job-[%d] is %s", id, state)
+	}
+	if step != CloneStepSynced {
+		return errors.Errorf("clone job-[%d] is not synced", id)
+	}
+
+	//持有锁直到断开复制，期间不会有新的slot迁移
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.syncCloneSlots(detail); err != nil {
+		return err
+	}
+
+	for _, cg := range detail.Groups {
+		c, err := s.action.redisp.GetClient(cg.Servers[0])
+		if err != nil {
+			return err
+		}
+		err = c.SetMaster("NO:ONE")
+		s.action.redisp.PutClient(c, err)
+		if err != nil {
+			return errors.Errorf("server-[%s] slaveof no one failed: %s", cg.Servers[0], err)
+		}
+	}
+	j.updateDetail(func() {
+		detail.Detached = true
+	})
+	j.update(CloneStepDetached, 100)
+	j.finish(nil)
+	log.Warnf("clone: job-[%d] product-[%s] detached", j.Id, detail.Product)
+	return nil
+}
+
+//按源集群当前的slot映射更新目标product，slot迁移未完成或者迁移到了新的group时不能断开复制
+func (s *Topom) syncCloneSlots(detail *CloneDetail) error {
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+	var groups = make(map[int]bool)
+	for _, cg := range detail.Groups {
+		groups[cg.Id] = true
+	}
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing {
+			return errors.Errorf("slot-[%d] action is not finished", m.Id)
+		}
+		if m.GroupId != 0 && !groups[m.GroupId] {
+			return errors.Errorf("slot-[%d] is served by group-[%d], which is not cloned", m.Id, m.GroupId)
+		}
+	}
+
+	store := models.NewStore(s.store.Client(), detail.Product)
+	for _, m := range ctx.slots {
+		x, err := store.LoadSlotMapping(m.Id, true)
+		if err != nil {
+			return err
+		}
+		if x.GroupId == m.GroupId {
+			continue
+		}
+		if err := store.UpdateSlotMapping(&models.SlotMapping{Id: m.Id, GroupId: m.GroupId}); err != nil {
+			return errors.Errorf("store: update slot-[%d] of product-[%s] failed: %s", m.Id, detail.Product, err)
+		}
+		log.Warnf("clone: slot-[%d] of product-[%s] moved to group-[%d]", m.Id, detail.Product, m.GroupId)
+	}
+	return nil
+}
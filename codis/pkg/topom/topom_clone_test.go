@@ -0,0 +1,111 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestCloneProduct(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{{Addr: s2.Addr}}})
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: sid%2 + 1})
+	}
+
+	d1 := newFakeServer()
+	defer d1.Close()
+	d2 := newFakeServer()
+	defer d2.Close()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.MustNoError(err)
+	unreachable := l.Addr().String()
+	l.Close()
+
+	for _, req := range []*CloneRequest{
+		{Product: t.config.ProductName, Servers: map[int][]string{1: {d1.Addr}, 2: {d2.Addr}}},
+		{Product: "codis-clone", Servers: map[int][]string{1: {d1.Addr}}},
+		{Product: "codis-clone", Servers: map[int][]string{1: {d1.Addr}, 2: {s1.Addr}}},
+	} {
+		_, err := t.CloneProduct(req)
+		assert.Must(err != nil)
+	}
+
+	//目标server不可达，删除已经创建的拓扑
+	id, err := t.CloneProduct(&CloneRequest{Product: "codis-clone", Servers: map[int][]string{1: {d1.Addr}, 2: {unreachable}}})
+	assert.Must(err != nil && id != 0)
+	j := t.getJob(id)
+	assert.Must(j != nil && j.State == JobFailed)
+
+	store := models.NewStore(t.store.Client(), "codis-clone")
+	group, err := store.ListGroup()
+	assert.MustNoError(err)
+	assert.Must(len(group) == 0)
+	m, err := store.LoadSlotMapping(0, false)
+	assert.MustNoError(err)
+	assert.Must(m == nil)
+
+	id, err = t.CloneProduct(&CloneRequest{Product: "codis-clone", Servers: map[int][]string{1: {d1.Addr}, 2: {d2.Addr}}})
+	assert.MustNoError(err)
+	j = t.getJob(id)
+	assert.Must(j != nil && j.State == JobRunning && j.Step == CloneStepSyncing)
+	defer j.finish(nil)
+
+	group, err = store.ListGroup()
+	assert.MustNoError(err)
+	assert.Must(len(group) == 2 && group[1].Servers[0].Addr == d1.Addr && group[2].Servers[0].Addr == d2.Addr)
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		m, err := store.LoadSlotMapping(sid, true)
+		assert.MustNoError(err)
+		assert.Must(m.GroupId == sid%2+1)
+	}
+
+	//已有group的product不能再次克隆，没有同步完成不能断开复制
+	_, err = t.CloneProduct(&CloneRequest{Product: "codis-clone", Servers: map[int][]string{1: {"127.0.0.1:1"}, 2: {"127.0.0.1:2"}}})
+	assert.Must(err != nil)
+	assert.Must(t.CloneFinish(id) != nil)
+
+	d1.SetInfo("master_link_status:up\r\n")
+	d2.SetInfo("master_link_status:up\r\n")
+	var step string
+	for i := 0; i < 50 && step != CloneStepSynced; i++ {
+		time.Sleep(time.Millisecond * 100)
+		j.updateDetail(func() {
+			step = j.Step
+		})
+	}
+	assert.Must(step == CloneStepSynced)
+
+	//slot迁移到了没有克隆的group，不能断开复制
+	s3 := newFakeServer()
+	defer s3.Close()
+	contextCreateGroup(t, &models.Group{Id: 3, Servers: []*models.GroupServer{{Addr: s3.Addr}}})
+	contextUpdateSlotMapping(t, &models.SlotMapping{Id: 1, GroupId: 3})
+	assert.Must(t.CloneFinish(id) != nil)
+
+	//同步期间迁移的slot在断开复制时更新到目标product
+	contextUpdateSlotMapping(t, &models.SlotMapping{Id: 1, GroupId: 1})
+	assert.MustNoError(t.CloneFinish(id))
+	assert.Must(j.State == JobFinished && j.Step == CloneStepDetached)
+	m, err = store.LoadSlotMapping(1, true)
+	assert.MustNoError(err)
+	assert.Must(m.GroupId == 1)
+	m, err = store.LoadSlotMapping(2, true)
+	assert.MustNoError(err)
+	assert.Must(m.GroupId == 1)
+}
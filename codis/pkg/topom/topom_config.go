@@ -0,0 +1,205 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"encoding"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//允许运行时修改的配置，返回修改后需要执行的动作
+func configNextStep(key string) string {
+	switch {
+	case strings.HasPrefix(key, "migration_"):
+		return "migrate"
+	case strings.HasPrefix(key, "sentinel_"):
+		return "sentinel"
+	case strings.HasPrefix(key, "metrics_"):
+		return "metrics"
+	case key == "expire_log_days":
+		return "log"
+	}
+	return ""
+}
+
+//按toml中的名字设置一个配置项
+func setConfigField(c *Config, key, value string) error {
+	var v = reflect.ValueOf(c).Elem()
+	var t = v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("toml") != key {
+			continue
+		}
+		f := v.Field(i)
+		if u, ok := f.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(value))
+		}
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(value)
+		case reflect.Int, reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return errors.Errorf("invalid value for %s", key)
+			}
+			f.SetInt(n)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.Errorf("invalid value for %s", key)
+			}
+			f.SetBool(b)
+		default:
+			return errors.Errorf("unsupported config %s", key)
+		}
+		return nil
+	}
+	return errors.Errorf("invalid key %s", key)
+}
+
+func (s *Topom) configOverrides() *models.ConfigOverrides {
+	if s.overrides == nil {
+		s.overrides = &models.ConfigOverrides{}
+	}
+	if s.overrides.Values == nil {
+		s.overrides.Values = make(map[string]string)
+	}
+	return s.overrides
+}
+
+func (s *Topom) ConfigOverrides() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var m = make(map[string]string)
+	for key, value := range s.configOverrides().Values {
+		m[key] = value
+	}
+	return m
+}
+
+//修改的配置保存到协调服务，重启或者切换dashboard之后仍然有效
+func (s *Topom) SetConfigs(values map[string]string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosedTopom
+	}
+	if len(values) == 0 {
+		return nil, errors.New("empty config")
+	}
+	var c = *s.config
+	var steps = make(map[string]bool)
+	var normalized = make(map[string]string)
+	for key, value := range values {
+		key = strings.ToLower(strings.TrimSpace(key))
+		step := configNextStep(key)
+		if step == "" {
+			return nil, errors.Errorf("config %s can't be modified at runtime", key)
+		}
+		if err := setConfigField(&c, key, value); err != nil {
+			return nil, err
+		}
+		steps[step], normalized[key] = true, value
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	p := &models.ConfigOverrides{Values: make(map[string]string)}
+	for key, value := range s.configOverrides().Values {
+		p.Values[key] = value
+	}
+	for key, value := range normalized {
+		p.Values[key] = value
+	}
+	if err := s.store.UpdateConfigOverrides(p); err != nil {
+		log.ErrorErrorf(err, "store: update config overrides failed")
+		return nil, errors.Errorf("store: update config overrides failed")
+	}
+	s.overrides = p
+	*s.config = c
+
+	var list []string
+	for step := range steps {
+		list = append(list, step)
+	}
+	log.Warnf("[%p] update config %v", s, normalized)
+
+	//使用了include或环境变量的配置文件由部署系统生成，改写会把展开后的值写回文件
+	if s.config.ConfigTemplated {
+		log.Warnf("[%p] config file %s uses includes or env vars, skip rewriting", s, s.config.ConfigName)
+		return list, nil
+	}
+	if s.config.ConfigName == "" {
+		return list, nil
+	}
+	return list, utils.RewriteConf(*(s.config), s.config.ConfigName, "=", true)
+}
+
+func (s *Topom) SetConfig(key, value string) (string, error) {
+	steps, err := s.SetConfigs(map[string]string{key: value})
+	if len(steps) == 0 {
+		return "", err
+	}
+	return steps[0], err
+}
+
+//启动时用协调服务中保存的配置覆盖配置文件，无效的配置被忽略
+func (s *Topom) applyConfigOverrides(p *models.ConfigOverrides) bool {
+	s.overrides = p
+	if p == nil || len(p.Values) == 0 {
+		return false
+	}
+	var c = *s.config
+	var metrics bool
+	for key, value := range p.Values {
+		if configNextStep(key) == "" {
+			log.Warnf("[%p] ignore config override %s", s, key)
+			continue
+		}
+		if err := setConfigField(&c, key, value); err != nil {
+			log.WarnErrorf(err, "[%p] ignore config override %s = %s", s, key, value)
+			continue
+		}
+		metrics = metrics || configNextStep(key) == "metrics"
+	}
+	if err := c.Validate(); err != nil {
+		log.WarnErrorf(err, "[%p] ignore config overrides", s)
+		return false
+	}
+	*s.config = c
+	log.Warnf("[%p] apply config overrides %v", s, p.Values)
+	return metrics
+}
+
+//停止正在运行的metrics上报，按新的配置重新启动
+func (s *Topom) restartMetrics() {
+	s.metrics.generation.Incr()
+	s.startMetricsInfluxdb()
+	s.startMetricsMysql()
+	s.startMetricsRemoteWrite()
+	s.startMetricsOtlp()
+}
+
+//修改配置之后执行，sentinel的配置需要重新下发
+func (s *Topom) afterSetConfig(steps []string) error {
+	for _, step := range steps {
+		switch step {
+		case "sentinel":
+			if err := s.ResyncSentinels(); err != nil {
+				return err
+			}
+		case "metrics":
+			s.restartMetrics()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestConfigOverrides(x *testing.T) {
+	c1 := *config
+	client := newDiskClient()
+	t, err := New(client, &c1)
+	assert.MustNoError(err)
+	assert.MustNoError(t.Start(false))
+
+	_, err = t.SetConfigs(map[string]string{"admin_addr": "0.0.0.0:1"})
+	assert.Must(err != nil)
+	_, err = t.SetConfig("migration_parallel_slots", "0")
+	assert.Must(err != nil)
+	_, err = t.SetConfig("migration_timeout", "x")
+	assert.Must(err != nil)
+	assert.Must(len(t.ConfigOverrides()) == 0)
+
+	steps, err := t.SetConfigs(map[string]string{
+		"migration_parallel_slots":       "4",
+		"migration_timeout":              "10s",
+		"metrics_report_mysql_retention": "1h",
+	})
+	assert.MustNoError(err)
+	assert.Must(len(steps) == 2)
+	assert.MustNoError(t.afterSetConfig(steps))
+	assert.Must(t.Config().MigrationParallelSlots == 4)
+	assert.Must(t.Config().MigrationTimeout.Duration() == time.Second*10)
+	assert.Must(t.Config().MetricsReportMysqlRetention.Duration() == time.Hour)
+
+	api := newApiClient(t)
+	assert.MustNoError(api.SetConfig("migration_verify_keys", "5"))
+	assert.MustNoError(api.UpdateConfig(map[string]string{"migration_parallel_slots": "8"}))
+	assert.Must(api.UpdateConfig(map[string]string{"product_name": "x"}) != nil)
+	m, err := api.ConfigOverrides()
+	assert.MustNoError(err)
+	assert.Must(len(m) == 4 && m["migration_parallel_slots"] == "8" && m["migration_verify_keys"] == "5")
+	t.Close()
+
+	//新的dashboard启动时从协调服务读取修改过的配置
+	c2 := *config
+	t, err = New(newForkClient(client), &c2)
+	assert.MustNoError(err)
+	defer t.Close()
+	assert.MustNoError(t.Start(false))
+	assert.Must(t.Config().MigrationParallelSlots == 8 && t.Config().MigrationVerifyKeys == 5)
+	assert.Must(t.Config().MigrationTimeout.Duration() == time.Second*10)
+}
@@ -0,0 +1,341 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type DecommissionDetail struct {
+	GroupId  int   `json:"group_id"`
+	Slots    []int `json:"slots"`
+	Migrated int   `json:"migrated"`
+}
+
+const (
+	JobTypeDecommission = "decommission"
+
+	DecommissionStepMigrating = "migrating"
+	DecommissionStepVerifying = "verifying"
+	DecommissionStepRemoving  = "removing"
+)
+
+//将group的所有slot迁移到其他group，确认group不再持有slot和key后从sentinel中移除并删除group
+func (s *Topom) GroupDecommission(gid int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return 0, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return 0, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, err
+	}
+
+	g, err := ctx.getGroup(gid)
+	if err != nil {
+		return 0, err
+	}
+	if g.Promoting.State != models.ActionNothing {
+		return 0, errors.Errorf("group-[%d] is promoting", g.Id)
+	}
+	if id, ok := s.decommissions[gid]; ok {
+		return 0, errors.Errorf("group-[%d] is being decommissioned by job-[%d]", g.Id, id)
+	}
+	if w := s.scaling; w != nil && w.State == models.ScalingRunning && w.GroupId == gid {
+		return 0, errors.Errorf("group-[%d] is being scaled by scaling-[%d]", g.Id, w.Id)
+	}
+
+	plans, err := planDecommissionSlots(ctx, gid)
+	if err != nil {
+		return 0, err
+	}
+
+	var slots []int
+	for sid := range plans {
+		slots = append(slots, sid)
+	}
+	sort.Ints(slots)
+
+	for _, sid := range slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return 0, err
+		}
+		defer s.dirtySlotsCache(m.Id)
+
+		m.Action.State = models.ActionPending
+		m.Action.Index = ctx.maxSlotActionIndex() + 1
+		m.Action.TargetId = plans[sid]
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return 0, err
+		}
+	}
+
+	detail := &DecommissionDetail{GroupId: gid, Slots: slots}
+	j := s.newJob(JobTypeDecommission, detail)
+	j.onCancel(func() error {
+		_, err := s.removePendingSlotActions(slots)
+		return err
+	})
+	j.update(DecommissionStepMigrating, 0)
+	s.decommissions[gid] = j.Id
+	log.Warnf("decommission: job-[%d] group-[%d] created, migrate %d slots", j.Id, gid, len(slots))
+
+	go s.trackDecommissionJob(j, detail)
+	return j.Id, nil
+}
+
+//将gid的slot依次分配给slot最少的group
+func planDecommissionSlots(ctx *context, gid int) (map[int]int, error) {
+	var size = make(map[int]int)
+	for _, g := range ctx.group {
+		if g.Id != gid && len(g.Servers) != 0 {
+			size[g.Id] = 0
+		}
+	}
+	var slots []int
+	for _, m := range ctx.slots {
+		switch {
+		case m.Action.State != models.ActionNothing:
+			if m.GroupId == gid || m.Action.TargetId == gid {
+				return nil, errors.Errorf("slot-[%d] action of group-[%d] is not finished", m.Id, gid)
+			}
+			if _, ok := size[m.Action.TargetId]; ok {
+				size[m.Action.TargetId]++
+			}
+		case m.GroupId == gid:
+			slots = append(slots, m.Id)
+		default:
+			if _, ok := size[m.GroupId]; ok {
+				size[m.GroupId]++
+			}
+		}
+	}
+	if len(slots) != 0 && len(size) == 0 {
+		return nil, errors.Errorf("no other group to take over slots of group-[%d]", gid)
+	}
+
+	var groupIds []int
+	for id := range size {
+		groupIds = append(groupIds, id)
+	}
+	sort.Ints(groupIds)
+
+	var plans = make(map[int]int)
+	for _, sid := range slots {
+		var dest = groupIds[0]
+		for _, id := range groupIds {
+			if size[id] < size[dest] {
+				dest = id
+			}
+		}
+		plans[sid] = dest
+		size[dest]++
+	}
+	return plans, nil
+}
+
+func (s *Topom) trackDecommissionJob(j *Job, detail *DecommissionDetail) {
+	var ticker = time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.exit.C:
+			return
+		case <-ticker.C:
+		}
+
+		//任务取消后不再删除group
+		if j.done() {
+			s.finishDecommissionJob(j, detail.GroupId, nil)
+			return
+		}
+
+		remaining, pending, err := s.countDecommissionSlots(detail.GroupId)
+		if err != nil {
+			if err == ErrClosedTopom {
+				return
+			}
+			log.WarnErrorf(err, "decommission: job-[%d] check slots failed", j.Id)
+			continue
+		}
+		if remaining != 0 && pending == 0 {
+			s.finishDecommissionJob(j, detail.GroupId,
+				errors.Errorf("group-[%d] still serves %d slots, migration may be cancelled", detail.GroupId, remaining))
+			return
+		}
+		if remaining != 0 {
+			j.updateDetail(func() {
+				detail.Migrated = len(detail.Slots) - remaining
+			})
+			if len(detail.Slots) != 0 {
+				j.update(DecommissionStepMigrating, (len(detail.Slots)-remaining)*100/len(detail.Slots))
+			}
+			continue
+		}
+		j.updateDetail(func() {
+			detail.Migrated = len(detail.Slots)
+		})
+
+		//迁移完成后不能再取消
+		j.onCancel(nil)
+		if j.done() {
+			s.finishDecommissionJob(j, detail.GroupId, nil)
+			return
+		}
+
+		j.update(DecommissionStepVerifying, 100)
+		if err := s.verifyGroupDrained(detail.GroupId); err != nil {
+			s.finishDecommissionJob(j, detail.GroupId, err)
+			return
+		}
+
+		j.update(DecommissionStepRemoving, 100)
+		err = s.removeDecommissionedGroup(detail.GroupId)
+		s.finishDecommissionJob(j, detail.GroupId, err)
+		if err == nil {
+			log.Warnf("decommission: job-[%d] group-[%d] removed", j.Id, detail.GroupId)
+		}
+		return
+	}
+}
+
+func (s *Topom) finishDecommissionJob(j *Job, gid int, err error) {
+	if err != nil {
+		log.ErrorErrorf(err, "decommission: job-[%d] group-[%d] failed", j.Id, gid)
+	}
+	j.finish(err)
+	s.mu.Lock()
+	delete(s.decommissions, gid)
+	s.mu.Unlock()
+}
+
+//remaining为仍由gid提供服务或迁入gid的slot数量，pending为其中正在迁出的数量
+func (s *Topom) countDecommissionSlots(gid int) (remaining, pending int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, m := range ctx.slots {
+		if m.GroupId != gid && m.Action.TargetId != gid {
+			continue
+		}
+		remaining++
+		if m.GroupId == gid && m.Action.State != models.ActionNothing && m.Action.TargetId != gid {
+			pending++
+		}
+	}
+	return remaining, pending, nil
+}
+
+//检查group中所有server的key数量都为0
+func (s *Topom) verifyGroupDrained(gid int) error {
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	g, err := ctx.getGroup(gid)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var addrs []string
+	for _, x := range g.Servers {
+		addrs = append(addrs, x.Addr)
+	}
+	s.mu.Unlock()
+
+	for _, addr := range addrs {
+		c, err := s.action.redisp.GetClient(addr)
+		if err != nil {
+			return errors.Errorf("server-[%s] is unreachable: %s", addr, err)
+		}
+		keyspace, err := c.InfoKeySpace()
+		s.action.redisp.PutClient(c, err)
+		if err != nil {
+			return errors.Errorf("server-[%s] info keyspace failed: %s", addr, err)
+		}
+		for db, info := range keyspace {
+			if n := keyspaceKeys(info); n != 0 {
+				return errors.Errorf("server-[%s] db%d still has %d keys", addr, db, n)
+			}
+		}
+	}
+	return nil
+}
+
+//解析keyspace中的keys=N，无法解析时返回-1
+func keyspaceKeys(info string) int64 {
+	for _, kv := range strings.Split(info, ",") {
+		kv = strings.TrimSpace(kv)
+		if strings.HasPrefix(kv, "keys=") {
+			n, err := strconv.ParseInt(kv[len("keys="):], 10, 64)
+			if err != nil {
+				return -1
+			}
+			return n
+		}
+	}
+	return -1
+}
+
+func (s *Topom) removeDecommissionedGroup(gid int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	g, err := ctx.getGroup(gid)
+	if err != nil {
+		return err
+	}
+	if ctx.isGroupInUse(g.Id) {
+		return errors.Errorf("group-[%d] is still in use", g.Id)
+	}
+	if g.Promoting.State != models.ActionNothing {
+		return errors.Errorf("group-[%d] is promoting", g.Id)
+	}
+
+	if len(ctx.sentinel.Servers) != 0 {
+		if err := s.SentinelRemoveGroupNoLock(g.Id); err != nil {
+			return err
+		}
+	}
+
+	defer s.dirtyGroupCache(g.Id)
+	if err := s.storeRemoveGroup(g); err != nil {
+		return err
+	}
+
+	if len(ctx.sentinel.Servers) != 0 {
+		ctx, err := s.newContext()
+		if err != nil {
+			return err
+		}
+		defer s.dirtySentinelCache()
+		p := ctx.sentinel
+		p.OutOfSync = false
+		return s.storeUpdateSentinel(p)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestKeyspaceKeys(x *testing.T) {
+	assert.Must(keyspaceKeys("keys=0,expires=0,avg_ttl=0") == 0)
+	assert.Must(keyspaceKeys("keys=12,expires=3,avg_ttl=0") == 12)
+	assert.Must(keyspaceKeys("expires=3") == -1)
+	assert.Must(keyspaceKeys("keys=abc") == -1)
+}
+
+func TestGroupDecommission(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+	s3 := newFakeServer()
+	defer s3.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{{Addr: s2.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 3, Servers: []*models.GroupServer{{Addr: s3.Addr}}})
+	for sid := 0; sid < 6; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: 1})
+	}
+	contextUpdateSlotMapping(t, &models.SlotMapping{Id: 6, GroupId: 2})
+
+	//group中还有key时不能删除
+	s1.SetKey("a", "1")
+	assert.Must(t.verifyGroupDrained(1) != nil)
+	assert.MustNoError(t.verifyGroupDrained(2))
+
+	id, err := t.GroupDecommission(1)
+	assert.MustNoError(err)
+	_, err = t.GroupDecommission(1)
+	assert.Must(err != nil)
+	_, err = t.StartScaling(&ScalingRequest{Type: models.ScalingRemoveGroup, GroupId: 1})
+	assert.Must(err != nil)
+
+	//slot优先分配给slot最少的group
+	var targets = make(map[int]int)
+	for sid := 0; sid < 6; sid++ {
+		m := getSlotMapping(t, sid)
+		assert.Must(m.GroupId == 1 && m.Action.State == models.ActionPending)
+		targets[m.Action.TargetId]++
+	}
+	assert.Must(targets[2] == 3 && targets[3] == 3)
+
+	j := t.getJob(id)
+	assert.Must(j != nil && j.Type == JobTypeDecommission)
+
+	assert.MustNoError(t.ProcessSlotAction())
+	for sid := 0; sid < 6; sid++ {
+		m := getSlotMapping(t, sid)
+		assert.Must(m.GroupId != 1 && m.Action.State == models.ActionNothing)
+	}
+
+	for i := 0; i < 50 && !j.done(); i++ {
+		time.Sleep(time.Millisecond * 100)
+	}
+	assert.Must(j.done() && j.State == JobFinished)
+
+	ctx, err := t.newContext()
+	assert.MustNoError(err)
+	_, err = ctx.getGroup(1)
+	assert.Must(err != nil)
+
+	t.mu.Lock()
+	assert.Must(len(t.decommissions) == 0)
+	t.mu.Unlock()
+}
@@ -0,0 +1,205 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+const (
+	ProxyHealthy     = "healthy"
+	ProxyUnreachable = "unreachable"
+	ProxyOffline     = "offline"
+	ProxyUnknown     = "unknown"
+)
+
+type DiscoveryProxy struct {
+	Token      string `json:"token"`
+	ProxyAddr  string `json:"proxy_addr"`
+	AdminAddr  string `json:"admin_addr"`
+	ProtoType  string `json:"proto_type"`
+	DataCenter string `json:"datacenter,omitempty"`
+	Health     string `json:"health"`
+	ReadOnly   bool   `json:"readonly,omitempty"`
+}
+
+//根据最近一次拉取的proxy状态判断健康状况
+func proxyHealth(x *ProxyStats) string {
+	switch {
+	case x == nil:
+		return ProxyUnknown
+	case x.Error != nil || x.Timeout || x.Stats == nil:
+		return ProxyUnreachable
+	case !x.Stats.Online || x.Stats.Closed:
+		return ProxyOffline
+	default:
+		return ProxyHealthy
+	}
+}
+
+//返回所有已注册的proxy及其健康状况，按token排序
+func (s *Topom) DiscoverProxies() ([]*DiscoveryProxy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+	var list []*DiscoveryProxy
+	for _, p := range ctx.proxy {
+		x := s.stats.proxies[p.Token]
+		d := &DiscoveryProxy{
+			Token: p.Token, ProxyAddr: p.ProxyAddr, AdminAddr: p.AdminAddr,
+			ProtoType: p.ProtoType, DataCenter: p.DataCenter,
+			Health: proxyHealth(x),
+		}
+		if x != nil && x.Stats != nil {
+			d.ReadOnly = x.Stats.ReadOnly
+		}
+		list = append(list, d)
+	}
+	sort.Sort(discoveryProxySorter(list))
+	return list, nil
+}
+
+type discoveryProxySorter []*DiscoveryProxy
+
+func (s discoveryProxySorter) Len() int           { return len(s) }
+func (s discoveryProxySorter) Less(i, j int) bool { return s[i].Token < s[j].Token }
+func (s discoveryProxySorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+//只保留满足条件的proxy，health与datacenter为空时不过滤
+func filterDiscoveryProxies(list []*DiscoveryProxy, health, datacenter string) []*DiscoveryProxy {
+	var out = make([]*DiscoveryProxy, 0, len(list))
+	for _, p := range list {
+		if health != "" && p.Health != health {
+			continue
+		}
+		if datacenter != "" && p.DataCenter != datacenter {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+type consulService struct {
+	ID      string   `json:"ID"`
+	Name    string   `json:"Name"`
+	Tags    []string `json:"Tags,omitempty"`
+	Address string   `json:"Address"`
+	Port    int      `json:"Port"`
+}
+
+func newConsulService(product string, p *DiscoveryProxy) (*consulService, error) {
+	host, port, err := net.SplitHostPort(p.ProxyAddr)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	x := &consulService{
+		ID:      "codis-" + product + "-" + p.Token,
+		Name:    "codis-" + product,
+		Address: host, Port: n,
+	}
+	if p.DataCenter != "" {
+		x.Tags = append(x.Tags, p.DataCenter)
+	}
+	return x, nil
+}
+
+func (s *Topom) consulRequest(method, path string, body interface{}) error {
+	var b []byte
+	if body != nil {
+		var err error
+		if b, err = json.Marshal(body); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	url := strings.TrimSuffix(s.config.DiscoveryConsulAddr, "/") + path
+	req, err := http.NewRequest(method, url, bytes.NewReader(b))
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if token := s.config.DiscoveryConsulToken; token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+	client := &http.Client{Timeout: time.Second * 5}
+	rsp, err := client.Do(req)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		b, _ := ioutil.ReadAll(rsp.Body)
+		return errors.Errorf("consul %s %s failed, [%d] %s", method, path, rsp.StatusCode, string(b))
+	}
+	return nil
+}
+
+//将健康的proxy注册到consul，并注销不再健康或已移除的proxy
+func (s *Topom) syncConsulServices(registered map[string]bool) error {
+	list, err := s.DiscoverProxies()
+	if err != nil {
+		return err
+	}
+	var healthy = make(map[string]bool)
+	for _, p := range filterDiscoveryProxies(list, ProxyHealthy, "") {
+		x, err := newConsulService(s.config.ProductName, p)
+		if err != nil {
+			log.WarnErrorf(err, "proxy-[%s] invalid address %s", p.Token, p.ProxyAddr)
+			continue
+		}
+		if err := s.consulRequest("PUT", "/v1/agent/service/register", x); err != nil {
+			return err
+		}
+		healthy[x.ID] = true
+		if !registered[x.ID] {
+			log.Warnf("consul: register service %s %s", x.ID, p.ProxyAddr)
+		}
+		registered[x.ID] = true
+	}
+	for id := range registered {
+		if healthy[id] {
+			continue
+		}
+		if err := s.consulRequest("PUT", "/v1/agent/service/deregister/"+id, nil); err != nil {
+			return err
+		}
+		log.Warnf("consul: deregister service %s", id)
+		delete(registered, id)
+	}
+	return nil
+}
+
+func (s *Topom) startConsulRegistration() {
+	if s.config.DiscoveryConsulAddr == "" {
+		return
+	}
+	go func() {
+		var registered = make(map[string]bool)
+		for !s.IsClosed() {
+			if s.IsOnline() {
+				if err := s.syncConsulServices(registered); err != nil {
+					log.WarnErrorf(err, "sync proxies to consul failed")
+				}
+			}
+			time.Sleep(s.config.DiscoveryConsulPeriod.Duration())
+		}
+	}()
+}
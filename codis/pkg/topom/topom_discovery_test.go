@@ -0,0 +1,36 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/rpc"
+)
+
+func TestProxyHealth(x *testing.T) {
+	assert.Must(proxyHealth(nil) == ProxyUnknown)
+	assert.Must(proxyHealth(&ProxyStats{Error: &rpc.RemoteError{}}) == ProxyUnreachable)
+	assert.Must(proxyHealth(&ProxyStats{Stats: &proxy.Stats{}}) == ProxyOffline)
+	assert.Must(proxyHealth(&ProxyStats{Stats: &proxy.Stats{Online: true}}) == ProxyHealthy)
+}
+
+func TestFilterDiscoveryProxies(x *testing.T) {
+	list := []*DiscoveryProxy{
+		{Token: "a", ProxyAddr: "10.0.0.1:19000", DataCenter: "dc1", Health: ProxyHealthy},
+		{Token: "b", ProxyAddr: "10.0.0.2:19000", DataCenter: "dc2", Health: ProxyHealthy},
+		{Token: "c", ProxyAddr: "10.0.0.3:19000", DataCenter: "dc1", Health: ProxyOffline},
+	}
+	assert.Must(len(filterDiscoveryProxies(list, "", "")) == 3)
+	assert.Must(len(filterDiscoveryProxies(list, ProxyHealthy, "")) == 2)
+	assert.Must(len(filterDiscoveryProxies(list, ProxyHealthy, "dc1")) == 1)
+
+	svc, err := newConsulService("demo", list[0])
+	assert.MustNoError(err)
+	assert.Must(svc.ID == "codis-demo-a" && svc.Name == "codis-demo")
+	assert.Must(svc.Address == "10.0.0.1" && svc.Port == 19000)
+	assert.Must(len(svc.Tags) == 1 && svc.Tags[0] == "dc1")
+}
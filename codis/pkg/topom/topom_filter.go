@@ -0,0 +1,65 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+func (s *Topom) requestFilter() *models.RequestFilter {
+	if s.filter == nil {
+		return &models.RequestFilter{}
+	}
+	return s.filter
+}
+
+func (s *Topom) RequestFilter() *models.RequestFilter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	x := *s.requestFilter()
+	return &x
+}
+
+//更新产品的过滤脚本并下发给所有proxy，空脚本表示关闭过滤
+func (s *Topom) UpdateRequestFilter(script string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if err := proxy.ValidateRequestFilter(script); err != nil {
+		return err
+	}
+
+	var p = &models.RequestFilter{Script: script}
+	if err := s.storeUpdateRequestFilter(p); err != nil {
+		return err
+	}
+	s.filter = p
+	return s.resyncRequestFilter(ctx)
+}
+
+func (s *Topom) resyncRequestFilter(ctx *context) error {
+	for _, p := range ctx.proxy {
+		if err := s.newProxyClient(p).SetRequestFilter(s.requestFilter()); err != nil {
+			log.ErrorErrorf(err, "proxy-[%s] set request filter failed", p.Token)
+			return errors.Errorf("proxy-[%s] set request filter failed", p.Token)
+		}
+	}
+	return nil
+}
+
+func (s *Topom) storeUpdateRequestFilter(p *models.RequestFilter) error {
+	log.Warnf("update request filter:\n%s", p.Script)
+	if err := s.store.UpdateRequestFilter(p); err != nil {
+		log.ErrorErrorf(err, "store: update request filter failed")
+		return errors.Errorf("store: update request filter failed")
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestRequestFilter(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	assert.Must(t.RequestFilter().Script == "")
+	assert.Must(t.UpdateRequestFilter("function filter(req") != nil)
+
+	script := `function filter(req) return "allow" end`
+	assert.MustNoError(t.UpdateRequestFilter(script))
+	assert.Must(t.RequestFilter().Script == script)
+
+	p, err := t.store.LoadRequestFilter(true)
+	assert.MustNoError(err)
+	assert.Must(p.Script == script)
+
+	assert.MustNoError(t.UpdateRequestFilter(""))
+	assert.Must(t.RequestFilter().Script == "")
+}
@@ -54,6 +54,11 @@ func (s *Topom) RemoveGroup(gid int) error {
 	if len(g.Servers) != 0 {
 		return errors.Errorf("group-[%d] isn't empty", gid)
 	}
+	for _, r := range s.prefixRoutes().Routes {
+		if r.GroupId == gid {
+			return errors.Errorf("group-[%d] is used by route prefix-[%s]", gid, r.Prefix)
+		}
+	}
 	defer s.dirtyGroupCache(g.Id)
 
 	return s.storeRemoveGroup(g)
@@ -398,7 +403,7 @@ func (s *Topom) GroupPromoteServer(gid int, addr string, force bool) error {
 				return err
 			}
 			groupIds := map[int]bool{g.Id: true}
-			sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+			sentinel := s.newSentinel()
 			if err := sentinel.RemoveGroups(p.Servers, s.config.SentinelClientTimeout.Duration(), groupIds); err != nil {
 				log.WarnErrorf(err, "group-[%d] remove sentinels failed", g.Id)
 			}
@@ -0,0 +1,209 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/bytesize"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type ServerConfig struct {
+	Server string            `json:"server"`
+	Config map[string]string `json:"config,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+type GroupMemoryPolicy struct {
+	Id      int             `json:"id"`
+	Drifted bool            `json:"drifted"`
+	Servers []*ServerConfig `json:"servers"`
+}
+
+var MemoryPolicyKeys = []string{"maxmemory", "maxmemory-policy"}
+
+var evictionPolicies = map[string]bool{
+	"noeviction":      true,
+	"allkeys-lru":     true,
+	"allkeys-lfu":     true,
+	"allkeys-random":  true,
+	"volatile-lru":    true,
+	"volatile-lfu":    true,
+	"volatile-random": true,
+	"volatile-ttl":    true,
+}
+
+//读取group内所有server的配置，单个server失败不影响其他server
+func (s *Topom) groupConfigGet(g *models.Group, keys []string) []*ServerConfig {
+	var servers = make([]*ServerConfig, 0, len(g.Servers))
+	for _, x := range g.Servers {
+		sc := &ServerConfig{Server: x.Addr, Config: make(map[string]string)}
+		for _, key := range keys {
+			v, err := s.action.redisp.ConfigGet(x.Addr, key)
+			if err != nil {
+				sc.Error = err.Error()
+				break
+			}
+			sc.Config[key] = v
+		}
+		servers = append(servers, sc)
+	}
+	return servers
+}
+
+//在group内所有server上执行CONFIG SET并校验结果，任一server失败则将已修改的server回滚为原值
+func (s *Topom) groupConfigSet(g *models.Group, keys []string, values map[string]string) error {
+	var olds = s.groupConfigGet(g, keys)
+	for _, sc := range olds {
+		if sc.Error != "" {
+			return errors.Errorf("server-[%s] config get failed: %s", sc.Server, sc.Error)
+		}
+	}
+
+	var rollback = func(n int) {
+		for _, sc := range olds[:n] {
+			for _, key := range keys {
+				if err := s.action.redisp.ConfigSet(sc.Server, key, sc.Config[key]); err != nil {
+					log.WarnErrorf(err, "group-[%d] rollback server-[%s] config %s failed", g.Id, sc.Server, key)
+				}
+			}
+		}
+	}
+
+	for i, x := range g.Servers {
+		for _, key := range keys {
+			if err := s.action.redisp.ConfigSet(x.Addr, key, values[key]); err != nil {
+				rollback(i + 1)
+				return errors.Errorf("server-[%s] config set %s failed: %s", x.Addr, key, err)
+			}
+			v, err := s.action.redisp.ConfigGet(x.Addr, key)
+			if err != nil {
+				rollback(i + 1)
+				return errors.Errorf("server-[%s] config get %s failed: %s", x.Addr, key, err)
+			}
+			if !isConfigEqual(v, values[key]) {
+				rollback(i + 1)
+				return errors.Errorf("server-[%s] config %s = %s, expected %s", x.Addr, key, v, values[key])
+			}
+		}
+	}
+	return nil
+}
+
+var configMemoryUnits = map[string]int64{
+	"b": 1, "k": 1000, "kb": 1 << 10, "m": 1000 * 1000, "mb": 1 << 20, "g": 1000 * 1000 * 1000, "gb": 1 << 30,
+}
+
+var configMemoryRegexp = regexp.MustCompile(`^(\d+)(b|k|kb|m|mb|g|gb)$`)
+
+//按redis的规则规范化配置值：忽略大小写和多余空白，内存单位换算为字节数，yes/no换算为1/0
+func normalizeConfigValue(v string) string {
+	var fields = strings.Fields(strings.ToLower(v))
+	for i, f := range fields {
+		switch f {
+		case "yes":
+			fields[i] = "1"
+		case "no":
+			fields[i] = "0"
+		default:
+			m := configMemoryRegexp.FindStringSubmatch(f)
+			if m == nil {
+				continue
+			}
+			n, err := strconv.ParseInt(m[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			unit := configMemoryUnits[m[2]]
+			if n > (1<<63-1)/unit {
+				continue
+			}
+			fields[i] = strconv.FormatInt(n*unit, 10)
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+//CONFIG GET返回的是redis规范化之后的值，比较时两边都需要规范化
+func isConfigEqual(a, b string) bool {
+	return a == b || normalizeConfigValue(a) == normalizeConfigValue(b)
+}
+
+//判断group内各server的配置是否一致
+func isConfigDrifted(servers []*ServerConfig, keys []string) bool {
+	for _, sc := range servers {
+		if sc.Error != "" {
+			return true
+		}
+		for _, key := range keys {
+			if sc.Config[key] != servers[0].Config[key] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *Topom) GroupGetMemoryPolicy(gid int) (*GroupMemoryPolicy, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := ctx.getGroup(gid)
+	if err != nil {
+		return nil, err
+	}
+	servers := s.groupConfigGet(g, MemoryPolicyKeys)
+	return &GroupMemoryPolicy{
+		Id: g.Id, Servers: servers,
+		Drifted: isConfigDrifted(servers, MemoryPolicyKeys),
+	}, nil
+}
+
+func (s *Topom) GroupSetMemoryPolicy(gid int, maxmemory, policy string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	g, err := ctx.getGroup(gid)
+	if err != nil {
+		return err
+	}
+	if len(g.Servers) == 0 {
+		return errors.Errorf("group-[%d] is empty", g.Id)
+	}
+	if g.Promoting.State != models.ActionNothing {
+		return errors.Errorf("group-[%d] is promoting", g.Id)
+	}
+
+	n, err := bytesize.Parse(strings.ToLower(maxmemory))
+	if err != nil || n < 0 {
+		return errors.Errorf("invalid maxmemory = %s", maxmemory)
+	}
+	policy = strings.ToLower(policy)
+	if !evictionPolicies[policy] {
+		return errors.Errorf("invalid maxmemory-policy = %s", policy)
+	}
+
+	if err := s.groupConfigSet(g, MemoryPolicyKeys, map[string]string{
+		"maxmemory":        strconv.FormatInt(n, 10),
+		"maxmemory-policy": policy,
+	}); err != nil {
+		log.ErrorErrorf(err, "group-[%d] set memory policy failed", g.Id)
+		return err
+	}
+	log.Warnf("group-[%d] set maxmemory = %d, maxmemory-policy = %s", g.Id, n, policy)
+	return nil
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestConfigDrifted(x *testing.T) {
+	newServer := func(maxmemory, policy string) *ServerConfig {
+		return &ServerConfig{Config: map[string]string{
+			"maxmemory": maxmemory, "maxmemory-policy": policy,
+		}}
+	}
+	servers := []*ServerConfig{
+		newServer("1073741824", "allkeys-lru"),
+		newServer("1073741824", "allkeys-lru"),
+	}
+	assert.Must(!isConfigDrifted(servers, MemoryPolicyKeys))
+
+	servers[1].Config["maxmemory-policy"] = "noeviction"
+	assert.Must(isConfigDrifted(servers, MemoryPolicyKeys))
+
+	servers[1] = &ServerConfig{Error: "connection refused"}
+	assert.Must(isConfigDrifted(servers, MemoryPolicyKeys))
+}
+
+func TestConfigEqual(x *testing.T) {
+	assert.Must(isConfigEqual("1gb", "1073741824"))
+	assert.Must(isConfigEqual("1g", "1000000000"))
+	assert.Must(isConfigEqual("100MB", "104857600"))
+	assert.Must(isConfigEqual("yes", "1"))
+	assert.Must(isConfigEqual("No", "no"))
+	assert.Must(isConfigEqual(" 3600 1  300 100 ", "3600 1 300 100"))
+	assert.Must(isConfigEqual("Allkeys-LRU", "allkeys-lru"))
+	assert.Must(!isConfigEqual("1gb", "1g"))
+	assert.Must(!isConfigEqual("3600 1", "3600 10"))
+}
@@ -0,0 +1,87 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"strconv"
+
+	"github.com/CodisLabs/codis/pkg/models"
+)
+
+const (
+	GroupHealthy           = "healthy"
+	GroupDegradedNoReplica = "degraded-no-replica"
+	GroupMasterDown        = "master-down"
+	GroupSplit             = "split"
+	GroupEmpty             = "empty"
+)
+
+//根据server的探测结果与sentinel记录的master判断group的健康状态
+//master-down: master无法访问；split: 出现多个master或sentinel记录的master与group不一致；
+//degraded-no-replica: master正常但没有与master同步的slave
+func classifyGroupHealth(g *models.Group, stats map[string]*RedisStats, haMaster string) string {
+	if len(g.Servers) == 0 {
+		return GroupEmpty
+	}
+	var alive = func(addr string) map[string]string {
+		x := stats[addr]
+		if x == nil || x.Error != nil || x.Timeout {
+			return nil
+		}
+		return x.Stats
+	}
+	master := g.Servers[0].Addr
+
+	m := alive(master)
+	if m == nil {
+		return GroupMasterDown
+	}
+	if m["role"] == "slave" || (haMaster != "" && haMaster != master) {
+		return GroupSplit
+	}
+	var replicas int
+	for _, x := range g.Servers[1:] {
+		r := alive(x.Addr)
+		if r == nil {
+			continue
+		}
+		if r["role"] != "slave" {
+			return GroupSplit
+		}
+		if r["master_addr"] == master && r["master_link_status"] == "up" {
+			replicas++
+		}
+	}
+	if replicas == 0 {
+		return GroupDegradedNoReplica
+	}
+	return GroupHealthy
+}
+
+//调用者需要持有topom锁
+func (s *Topom) groupHealth(ctx *context) map[int]string {
+	var health = make(map[int]string)
+	for _, g := range ctx.group {
+		health[g.Id] = classifyGroupHealth(g, s.stats.servers, s.ha.masters[g.Id])
+	}
+	return health
+}
+
+func (s *Topom) GroupHealth() (map[int]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+	return s.groupHealth(ctx), nil
+}
+
+func encodeGroupHealth(health map[int]string) map[string]string {
+	var m = make(map[string]string)
+	for gid, v := range health {
+		m[strconv.Itoa(gid)] = v
+	}
+	return m
+}
@@ -0,0 +1,38 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/rpc"
+)
+
+func TestClassifyGroupHealth(x *testing.T) {
+	g := &models.Group{Id: 1, Servers: []*models.GroupServer{
+		{Addr: "m:6379"}, {Addr: "s:6379"},
+	}}
+	master := &RedisStats{Stats: map[string]string{"role": "master"}}
+	slave := &RedisStats{Stats: map[string]string{
+		"role": "slave", "master_addr": "m:6379", "master_link_status": "up",
+	}}
+
+	stats := map[string]*RedisStats{"m:6379": master, "s:6379": slave}
+	assert.Must(classifyGroupHealth(g, stats, "") == GroupHealthy)
+	assert.Must(classifyGroupHealth(g, stats, "m:6379") == GroupHealthy)
+	assert.Must(classifyGroupHealth(g, stats, "s:6379") == GroupSplit)
+
+	stats = map[string]*RedisStats{"m:6379": master, "s:6379": {Timeout: true}}
+	assert.Must(classifyGroupHealth(g, stats, "") == GroupDegradedNoReplica)
+
+	stats = map[string]*RedisStats{"m:6379": {Error: &rpc.RemoteError{}}, "s:6379": slave}
+	assert.Must(classifyGroupHealth(g, stats, "") == GroupMasterDown)
+
+	stats = map[string]*RedisStats{"m:6379": master, "s:6379": master}
+	assert.Must(classifyGroupHealth(g, stats, "") == GroupSplit)
+
+	assert.Must(classifyGroupHealth(&models.Group{Id: 2}, stats, "") == GroupEmpty)
+}
@@ -0,0 +1,196 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+)
+
+const (
+	JobRunning  = "running"
+	JobFinished = "finished"
+	JobFailed   = "failed"
+
+	JobCancelled = "cancelled"
+)
+
+//后台任务，例如集群克隆、切换、扩容等，由dashboard在内存中跟踪进度
+type Job struct {
+	Id       int    `json:"id"`
+	Type     string `json:"type"`
+	State    string `json:"state"`
+	Step     string `json:"step"`
+	Progress int    `json:"progress"`
+	Error    string `json:"error,omitempty"`
+
+	Cancelable bool `json:"cancelable,omitempty"`
+
+	CreateTime string `json:"create_time"`
+	UpdateTime string `json:"update_time"`
+
+	//Detail为detail的json快照，每次修改detail后更新
+	Detail json.RawMessage `json:"detail,omitempty"`
+	detail interface{}
+
+	cancel func() error
+
+	jobs *jobList
+}
+
+const maxJobNum = 100
+
+//每个Topom各自的任务列表，job的状态和detail都由列表的锁保护
+type jobList struct {
+	sync.Mutex
+	nextId int
+	list   []*Job
+}
+
+func (s *Topom) newJob(typ string, detail interface{}) *Job {
+	s.jobs.Lock()
+	defer s.jobs.Unlock()
+	s.jobs.nextId++
+	now := time.Now().Format("2006-01-02 15:04:05")
+	j := &Job{
+		Id: s.jobs.nextId, Type: typ, State: JobRunning,
+		CreateTime: now, UpdateTime: now, detail: detail,
+		jobs: &s.jobs,
+	}
+	j.encodeDetail()
+	//只保留最近的任务，运行中的任务不会被清理
+	if len(s.jobs.list) >= maxJobNum {
+		var list = make([]*Job, 0, len(s.jobs.list))
+		for i, x := range s.jobs.list {
+			if x.State == JobRunning || i >= len(s.jobs.list)-maxJobNum/2 {
+				list = append(list, x)
+			}
+		}
+		s.jobs.list = list
+	}
+	s.jobs.list = append(s.jobs.list, j)
+	return j
+}
+
+func (j *Job) encodeDetail() {
+	if j.detail == nil {
+		return
+	}
+	if b, err := json.Marshal(j.detail); err == nil {
+		j.Detail = b
+	}
+}
+
+func (j *Job) update(step string, progress int) {
+	j.jobs.Lock()
+	defer j.jobs.Unlock()
+	if j.State != JobRunning {
+		return
+	}
+	j.Step, j.Progress = step, progress
+	j.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+}
+
+func (j *Job) finish(err error) {
+	j.jobs.Lock()
+	defer j.jobs.Unlock()
+	if j.State != JobRunning {
+		return
+	}
+	if err != nil {
+		j.State, j.Error = JobFailed, err.Error()
+	} else {
+		j.State, j.Progress = JobFinished, 100
+	}
+	j.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+}
+
+//设置取消任务时的回调，未设置的任务不能取消
+func (j *Job) onCancel(fn func() error) {
+	j.jobs.Lock()
+	defer j.jobs.Unlock()
+	j.cancel = fn
+	j.Cancelable = fn != nil
+}
+
+func (j *Job) done() bool {
+	j.jobs.Lock()
+	defer j.jobs.Unlock()
+	return j.State != JobRunning
+}
+
+//读写detail需要持有jobs锁
+func (j *Job) updateDetail(fn func()) {
+	j.jobs.Lock()
+	defer j.jobs.Unlock()
+	fn()
+	j.encodeDetail()
+	j.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+}
+
+func (s *Topom) getJob(id int) *Job {
+	s.jobs.Lock()
+	defer s.jobs.Unlock()
+	for _, j := range s.jobs.list {
+		if j.Id == id {
+			return j
+		}
+	}
+	return nil
+}
+
+func (s *Topom) ListJobs() []*Job {
+	s.jobs.Lock()
+	defer s.jobs.Unlock()
+	var list = make([]*Job, 0, len(s.jobs.list))
+	for _, j := range s.jobs.list {
+		x := *j
+		list = append(list, &x)
+	}
+	return list
+}
+
+func (s *Topom) GetJob(id int) (*Job, error) {
+	s.jobs.Lock()
+	defer s.jobs.Unlock()
+	for _, j := range s.jobs.list {
+		if j.Id == id {
+			x := *j
+			return &x, nil
+		}
+	}
+	return nil, errors.Errorf("job-[%d] doesn't exist", id)
+}
+
+//执行任务的取消回调，回调返回后任务不再更新进度
+func (s *Topom) CancelJob(id int) error {
+	j := s.getJob(id)
+	if j == nil {
+		return errors.Errorf("job-[%d] doesn't exist", id)
+	}
+	s.jobs.Lock()
+	state, cancel := j.State, j.cancel
+	s.jobs.Unlock()
+
+	if state != JobRunning {
+		return errors.Errorf("job-[%d] is %s", id, state)
+	}
+	if cancel == nil {
+		return errors.Errorf("job-[%d] can't be cancelled", id)
+	}
+	if err := cancel(); err != nil {
+		return err
+	}
+
+	s.jobs.Lock()
+	defer s.jobs.Unlock()
+	if j.State == JobRunning {
+		j.State = JobCancelled
+		j.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestJob(x *testing.T) {
+	var t = &Topom{}
+	detail := &CloneDetail{Product: "clone"}
+	j := t.newJob(JobTypeClone, detail)
+	assert.Must(t.getJob(j.Id) == j)
+
+	j.update(CloneStepSyncing, 50)
+	j.updateDetail(func() {
+		detail.Detached = true
+	})
+	assert.Must(string(j.Detail) == `{"product":"clone","groups":null,"detached":true}`)
+
+	j.finish(errors.New("failed"))
+	j.update(CloneStepSynced, 100)
+	assert.Must(j.State == JobFailed && j.Step == CloneStepSyncing && j.Progress == 50)
+}
+
+func TestCancelJob(x *testing.T) {
+	var t = &Topom{}
+	j := t.newJob(JobTypeRebalance, nil)
+	assert.Must(t.CancelJob(j.Id) != nil)
+
+	var cancelled bool
+	j.onCancel(func() error {
+		cancelled = true
+		return nil
+	})
+	assert.MustNoError(t.CancelJob(j.Id))
+	assert.Must(cancelled && j.done() && j.State == JobCancelled)
+
+	j.finish(nil)
+	assert.Must(j.State == JobCancelled)
+	assert.Must(t.CancelJob(j.Id) != nil)
+}
+
+//AddProduct创建的Topom之间任务互不可见
+func TestJobIsolation(x *testing.T) {
+	var t1, t2 = &Topom{}, &Topom{}
+	j := t1.newJob(JobTypeRebalance, nil)
+	j.onCancel(func() error {
+		return nil
+	})
+	assert.Must(len(t1.ListJobs()) == 1 && len(t2.ListJobs()) == 0)
+	_, err := t2.GetJob(j.Id)
+	assert.Must(err != nil)
+	assert.Must(t2.CancelJob(j.Id) != nil && !j.done())
+	assert.MustNoError(t1.CancelJob(j.Id))
+}
@@ -0,0 +1,429 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/redis"
+)
+
+//比较当前product与Product中的数据，两个product需要使用同一个协调服务
+//Full为false时每个slot在两端各抽样Samples个key，为true时扫描两端所有的key
+//两端的过期时间相差不超过TTLTolerance毫秒时认为一致
+//string、hash、list、set、zset按值比较，与RDB版本和内部编码无关
+//其他类型比较DUMP的结果，两端的RDB版本或者编码不同时会被认为不一致
+type KeyspaceDiffRequest struct {
+	Product      string `json:"product"`
+	Full         bool   `json:"full,omitempty"`
+	Samples      int    `json:"samples,omitempty"`
+	TTLTolerance int64  `json:"ttl_tolerance,omitempty"`
+}
+
+type KeyspaceDiffDetail struct {
+	Source       string `json:"source"`
+	Dest         string `json:"dest"`
+	Full         bool   `json:"full"`
+	Samples      int    `json:"samples,omitempty"`
+	TTLTolerance int64  `json:"ttl_tolerance"`
+
+	Slots int `json:"slots"`
+	//两端有slot正在迁移或者没有分配group，不参与比较
+	Skipped []int `json:"skipped,omitempty"`
+
+	Compared int64 `json:"compared"`
+	Matched  int64 `json:"matched"`
+
+	//源端存在而目标端不存在的key
+	Missing      []string `json:"missing,omitempty"`
+	MissingCount int64    `json:"missing_count"`
+	//目标端存在而源端不存在的key
+	Extra      []string `json:"extra,omitempty"`
+	ExtraCount int64    `json:"extra_count"`
+
+	//两端的类型不同
+	TypeMismatched []string `json:"type_mismatched,omitempty"`
+	TypeCount      int64    `json:"type_count"`
+
+	ValueMismatched []string `json:"value_mismatched,omitempty"`
+	ValueCount      int64    `json:"value_count"`
+	TTLMismatched   []string `json:"ttl_mismatched,omitempty"`
+	TTLCount        int64    `json:"ttl_count"`
+
+	Error      string `json:"error,omitempty"`
+	UpdateTime string `json:"update_time"`
+}
+
+const (
+	JobTypeKeyspaceDiff = "keyspace-diff"
+
+	KeyspaceDiffStepComparing = "comparing"
+
+	defaultKeyspaceDiffSamples = 10
+	maxKeyspaceDiffSamples     = 10000
+
+	defaultKeyspaceDiffTTLTolerance = 1000
+
+	keyspaceDiffScanCount = 100
+)
+
+//slot在两端的master
+type keyspaceDiffSlot struct {
+	sid        int
+	from, dest string
+}
+
+//key在一端的状态，pttl为-2时key不存在
+type keyspaceDiffValue struct {
+	typ  string
+	crc  uint32
+	pttl int64
+}
+
+func (s *Topom) KeyspaceDiffJob(req *KeyspaceDiffRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := models.ValidateProduct(req.Product); err != nil {
+		return 0, err
+	}
+	if req.Product == s.config.ProductName {
+		return 0, errors.Errorf("diff with the same product-[%s]", req.Product)
+	}
+	if req.Samples < 0 || req.Samples > maxKeyspaceDiffSamples {
+		return 0, errors.Errorf("invalid samples = %d", req.Samples)
+	}
+	if req.Samples == 0 {
+		req.Samples = defaultKeyspaceDiffSamples
+	}
+	if req.TTLTolerance < 0 {
+		return 0, errors.Errorf("invalid ttl tolerance = %d", req.TTLTolerance)
+	}
+	if req.TTLTolerance == 0 {
+		req.TTLTolerance = defaultKeyspaceDiffTTLTolerance
+	}
+
+	store := models.NewStore(s.store.Client(), req.Product)
+	slots, err := store.SlotMappings()
+	if err != nil {
+		return 0, err
+	}
+	group, err := store.ListGroup()
+	if err != nil {
+		return 0, err
+	}
+	if len(group) == 0 {
+		return 0, errors.Errorf("product-[%s] has no group", req.Product)
+	}
+	dest := &context{slots: slots, group: group}
+
+	pairs, skipped := keyspaceDiffSlots(ctx, dest)
+	if len(pairs) == 0 {
+		return 0, errors.Errorf("no slot could be compared with product-[%s]", req.Product)
+	}
+
+	detail := &KeyspaceDiffDetail{
+		Source: s.config.ProductName, Dest: req.Product, Full: req.Full,
+		TTLTolerance: req.TTLTolerance, Skipped: skipped,
+	}
+	if !req.Full {
+		detail.Samples = req.Samples
+	}
+	j := s.newJob(JobTypeKeyspaceDiff, detail)
+	j.onCancel(func() error {
+		return nil
+	})
+	j.update(KeyspaceDiffStepComparing, 0)
+	log.Warnf("keyspace-diff: job-[%d] %s -> %s created, full = %t, %d slots",
+		j.Id, detail.Source, detail.Dest, req.Full, len(pairs))
+
+	go s.runKeyspaceDiffJob(j, detail, pairs)
+	return j.Id, nil
+}
+
+//只比较两端都已分配group且没有迁移的slot
+func keyspaceDiffSlots(source, dest *context) ([]*keyspaceDiffSlot, []int) {
+	var pairs []*keyspaceDiffSlot
+	var skipped []int
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		var from, to string
+		if m, err := source.getSlotMapping(sid); err == nil && m.Action.State == models.ActionNothing {
+			from = source.getGroupMaster(m.GroupId)
+		}
+		if m, err := dest.getSlotMapping(sid); err == nil && m.Action.State == models.ActionNothing {
+			to = dest.getGroupMaster(m.GroupId)
+		}
+		if from == "" || to == "" {
+			skipped = append(skipped, sid)
+			continue
+		}
+		pairs = append(pairs, &keyspaceDiffSlot{sid: sid, from: from, dest: to})
+	}
+	return pairs, skipped
+}
+
+func (s *Topom) runKeyspaceDiffJob(j *Job, detail *KeyspaceDiffDetail, pairs []*keyspaceDiffSlot) {
+	var err error
+	for i, p := range pairs {
+		if j.done() {
+			break
+		}
+		if err = s.keyspaceDiffSlot(j, detail, p); err != nil {
+			break
+		}
+		j.updateDetail(func() {
+			detail.Slots++
+		})
+		j.update(KeyspaceDiffStepComparing, (i+1)*100/len(pairs))
+	}
+	if j.done() {
+		log.Warnf("keyspace-diff: job-[%d] cancelled", j.Id)
+		return
+	}
+
+	var report KeyspaceDiffDetail
+	j.updateDetail(func() {
+		if err != nil {
+			detail.Error = err.Error()
+		}
+		detail.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+		report = *detail
+	})
+	s.verify.Lock()
+	s.verify.keyspace[report.Dest] = &report
+	s.verify.Unlock()
+
+	switch diffs := report.MissingCount + report.ExtraCount + report.TypeCount + report.ValueCount + report.TTLCount; {
+	case err != nil:
+		log.WarnErrorf(err, "keyspace-diff: job-[%d] failed", j.Id)
+	case diffs != 0:
+		err = errors.Errorf("%d keys differ, missing = %d, extra = %d, type = %d, value = %d, ttl = %d",
+			diffs, report.MissingCount, report.ExtraCount, report.TypeCount, report.ValueCount, report.TTLCount)
+		log.Errorf("keyspace-diff: job-[%d] %s -> %s %s", j.Id, report.Source, report.Dest, err)
+	default:
+		log.Warnf("keyspace-diff: job-[%d] %s -> %s passed, compared = %d", j.Id, report.Source, report.Dest, report.Compared)
+	}
+	j.finish(err)
+}
+
+//先用源端的key比较值和过期时间，再用目标端的key找出源端不存在的key
+func (s *Topom) keyspaceDiffSlot(j *Job, detail *KeyspaceDiffDetail, p *keyspaceDiffSlot) error {
+	from, err := s.action.redisp.GetClient(p.from)
+	if err != nil {
+		return errors.Errorf("server-[%s] is unreachable: %s", p.from, err)
+	}
+	defer s.action.redisp.PutClient(from, err)
+
+	dest, err := s.action.redisp.GetClient(p.dest)
+	if err != nil {
+		return errors.Errorf("server-[%s] is unreachable: %s", p.dest, err)
+	}
+	defer s.action.redisp.PutClient(dest, err)
+
+	err = scanKeyspaceSlot(from, p.sid, detail, func(keys []string) error {
+		for _, key := range keys {
+			if j.done() {
+				return nil
+			}
+			src, err := readKeyspaceDiffValue(from, key, true)
+			if err != nil {
+				return errors.Errorf("server-[%s] read key failed: %s", p.from, err)
+			}
+			//扫描之后被删除或者已经过期
+			if src.pttl == -2 {
+				continue
+			}
+			dst, err := readKeyspaceDiffValue(dest, key, true)
+			if err != nil {
+				return errors.Errorf("server-[%s] read key failed: %s", p.dest, err)
+			}
+			j.updateDetail(func() {
+				compareKeyspaceValue(detail, key, src, dst)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return scanKeyspaceSlot(dest, p.sid, detail, func(keys []string) error {
+		for _, key := range keys {
+			if j.done() {
+				return nil
+			}
+			src, err := readKeyspaceDiffValue(from, key, false)
+			if err != nil {
+				return errors.Errorf("server-[%s] read key failed: %s", p.from, err)
+			}
+			if src.pttl != -2 {
+				continue
+			}
+			dst, err := readKeyspaceDiffValue(dest, key, false)
+			if err != nil {
+				return errors.Errorf("server-[%s] read key failed: %s", p.dest, err)
+			}
+			if dst.pttl == -2 {
+				continue
+			}
+			j.updateDetail(func() {
+				detail.ExtraCount++
+				detail.Extra = appendBackupVerifyKey(detail.Extra, key)
+			})
+		}
+		return nil
+	})
+}
+
+//抽样时只取slot开头的一批key
+func scanKeyspaceSlot(c *redis.Client, sid int, detail *KeyspaceDiffDetail, fn func(keys []string) error) error {
+	var count = keyspaceDiffScanCount
+	if !detail.Full {
+		count = detail.Samples
+	}
+	var cursor int
+	for {
+		next, keys, err := c.SlotsScanCursor(sid, cursor, count)
+		if err != nil {
+			return errors.Errorf("server-[%s] scan slot-[%d] failed: %s", c.Addr, sid, err)
+		}
+		if !detail.Full && len(keys) > count {
+			keys = keys[:count]
+		}
+		if err := fn(keys); err != nil {
+			return err
+		}
+		if next == 0 || !detail.Full {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+func readKeyspaceDiffValue(c *redis.Client, key string, dump bool) (*keyspaceDiffValue, error) {
+	pttl, err := c.PTTL(key)
+	if err != nil {
+		return nil, err
+	}
+	v := &keyspaceDiffValue{pttl: pttl}
+	if pttl == -2 || !dump {
+		return v, nil
+	}
+	if v.typ, err = c.Type(key); err != nil {
+		return nil, err
+	}
+	if v.typ == "none" {
+		v.pttl = -2
+		return v, nil
+	}
+	values, ok, err := c.TypedValues(key, v.typ)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		v.crc = keyspaceDiffDigest(v.typ, values)
+		return v, nil
+	}
+	b, err := c.Dump(key)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		v.pttl = -2
+	} else {
+		v.crc = crc32.ChecksumIEEE(b)
+	}
+	return v, nil
+}
+
+//hash按字段排序，set按成员排序，list和zset使用redis返回的顺序
+func keyspaceDiffDigest(typ string, values [][]byte) uint32 {
+	switch typ {
+	case "hash":
+		var pairs = make([][2][]byte, 0, len(values)/2)
+		for i := 0; i+1 < len(values); i += 2 {
+			pairs = append(pairs, [2][]byte{values[i], values[i+1]})
+		}
+		sort.Slice(pairs, func(i, j int) bool {
+			return bytes.Compare(pairs[i][0], pairs[j][0]) < 0
+		})
+		values = values[:0:0]
+		for _, p := range pairs {
+			values = append(values, p[0], p[1])
+		}
+	case "set":
+		values = append([][]byte(nil), values...)
+		sort.Slice(values, func(i, j int) bool {
+			return bytes.Compare(values[i], values[j]) < 0
+		})
+	}
+	//每个元素前写入长度，避免不同的切分得到相同的结果
+	var h = crc32.NewIEEE()
+	var buf [binary.MaxVarintLen64]byte
+	for _, b := range values {
+		n := binary.PutUvarint(buf[:], uint64(len(b)))
+		h.Write(buf[:n])
+		h.Write(b)
+	}
+	return h.Sum32()
+}
+
+//需要持有jobs锁
+func compareKeyspaceValue(detail *KeyspaceDiffDetail, key string, src, dst *keyspaceDiffValue) {
+	detail.Compared++
+	switch {
+	case dst.pttl == -2:
+		detail.MissingCount++
+		detail.Missing = appendBackupVerifyKey(detail.Missing, key)
+	case src.typ != dst.typ:
+		detail.TypeCount++
+		detail.TypeMismatched = appendBackupVerifyKey(detail.TypeMismatched, key)
+	case src.crc != dst.crc:
+		detail.ValueCount++
+		detail.ValueMismatched = appendBackupVerifyKey(detail.ValueMismatched, key)
+	case !keyspaceTTLMatched(src.pttl, dst.pttl, detail.TTLTolerance):
+		detail.TTLCount++
+		detail.TTLMismatched = appendBackupVerifyKey(detail.TTLMismatched, key)
+	default:
+		detail.Matched++
+	}
+}
+
+func keyspaceTTLMatched(src, dst int64, tolerance int64) bool {
+	if src < 0 || dst < 0 {
+		return src == dst
+	}
+	if src > dst {
+		return src-dst <= tolerance
+	}
+	return dst-src <= tolerance
+}
+
+//按目标product排序
+func (s *Topom) KeyspaceDiffReports() []*KeyspaceDiffDetail {
+	s.verify.Lock()
+	defer s.verify.Unlock()
+	var names []string
+	for name := range s.verify.keyspace {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list = make([]*KeyspaceDiffDetail, 0, len(names))
+	for _, name := range names {
+		x := *s.verify.keyspace[name]
+		list = append(list, &x)
+	}
+	return list
+}
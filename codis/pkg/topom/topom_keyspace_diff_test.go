@@ -0,0 +1,88 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestCompareKeyspaceValue(x *testing.T) {
+	detail := &KeyspaceDiffDetail{TTLTolerance: 1000}
+	compareKeyspaceValue(detail, "a", &keyspaceDiffValue{crc: 1, pttl: -1}, &keyspaceDiffValue{crc: 1, pttl: -1})
+	compareKeyspaceValue(detail, "b", &keyspaceDiffValue{crc: 1, pttl: 5000}, &keyspaceDiffValue{crc: 1, pttl: 4500})
+	compareKeyspaceValue(detail, "c", &keyspaceDiffValue{crc: 1, pttl: -1}, &keyspaceDiffValue{pttl: -2})
+	compareKeyspaceValue(detail, "d", &keyspaceDiffValue{crc: 1, pttl: -1}, &keyspaceDiffValue{crc: 2, pttl: -1})
+	compareKeyspaceValue(detail, "e", &keyspaceDiffValue{crc: 1, pttl: 5000}, &keyspaceDiffValue{crc: 1, pttl: -1})
+	compareKeyspaceValue(detail, "f", &keyspaceDiffValue{crc: 1, pttl: 5000}, &keyspaceDiffValue{crc: 1, pttl: 3000})
+	compareKeyspaceValue(detail, "g", &keyspaceDiffValue{typ: "set", crc: 1, pttl: -1}, &keyspaceDiffValue{typ: "zset", crc: 1, pttl: -1})
+
+	assert.Must(detail.Compared == 7 && detail.Matched == 2)
+	assert.Must(detail.TypeCount == 1 && detail.TypeMismatched[0] == "g")
+	assert.Must(detail.MissingCount == 1 && detail.Missing[0] == "c")
+	assert.Must(detail.ValueCount == 1 && detail.ValueMismatched[0] == "d")
+	assert.Must(detail.TTLCount == 2 && detail.TTLMismatched[0] == "e" && detail.TTLMismatched[1] == "f")
+}
+
+func TestKeyspaceDiffDigest(x *testing.T) {
+	var values = func(list ...string) [][]byte {
+		var b [][]byte
+		for _, s := range list {
+			b = append(b, []byte(s))
+		}
+		return b
+	}
+	//hash和set与返回的顺序无关
+	assert.Must(keyspaceDiffDigest("hash", values("a", "1", "b", "2")) == keyspaceDiffDigest("hash", values("b", "2", "a", "1")))
+	assert.Must(keyspaceDiffDigest("hash", values("a", "1", "b", "2")) != keyspaceDiffDigest("hash", values("a", "2", "b", "1")))
+	assert.Must(keyspaceDiffDigest("set", values("a", "b", "c")) == keyspaceDiffDigest("set", values("c", "a", "b")))
+	assert.Must(keyspaceDiffDigest("list", values("a", "b")) != keyspaceDiffDigest("list", values("b", "a")))
+	assert.Must(keyspaceDiffDigest("list", values("ab", "c")) != keyspaceDiffDigest("list", values("a", "bc")))
+	assert.Must(keyspaceDiffDigest("string", values("v")) == keyspaceDiffDigest("string", values("v")))
+}
+
+func TestKeyspaceDiffSlots(x *testing.T) {
+	var source, dest = &context{}, &context{}
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		source.slots = append(source.slots, &models.SlotMapping{Id: sid, GroupId: 1})
+		dest.slots = append(dest.slots, &models.SlotMapping{Id: sid, GroupId: 1})
+	}
+	source.group = map[int]*models.Group{1: {Id: 1, Servers: []*models.GroupServer{{Addr: "s1"}}}}
+	dest.group = map[int]*models.Group{1: {Id: 1, Servers: []*models.GroupServer{{Addr: "d1"}}}}
+
+	//迁移中或者没有分配group的slot不参与比较
+	source.slots[1].Action.State = models.ActionPending
+	dest.slots[2].GroupId = 0
+	pairs, skipped := keyspaceDiffSlots(source, dest)
+	assert.Must(len(pairs) == MaxSlotNum-2 && len(skipped) == 2)
+	assert.Must(skipped[0] == 1 && skipped[1] == 2)
+	assert.Must(pairs[0].sid == 0 && pairs[0].from == "s1" && pairs[0].dest == "d1")
+}
+
+func TestKeyspaceDiffJob(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	s1 := newFakeServer()
+	defer s1.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: 1})
+	}
+
+	for _, req := range []*KeyspaceDiffRequest{
+		{Product: ""},
+		{Product: t.config.ProductName},
+		{Product: "codis-dest", Samples: -1},
+		{Product: "codis-dest", TTLTolerance: -1},
+		{Product: "codis-dest"},
+	} {
+		_, err := t.KeyspaceDiffJob(req)
+		assert.Must(err != nil)
+	}
+	assert.Must(len(t.KeyspaceDiffReports()) == 0)
+}
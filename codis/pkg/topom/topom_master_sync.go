@@ -0,0 +1,135 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type MasterSlotDiff struct {
+	Id           int    `json:"id"`
+	LocalGroup   int    `json:"local_group"`
+	MasterGroup  int    `json:"master_group"`
+	LocalAction  string `json:"local_action,omitempty"`
+	MasterAction string `json:"master_action,omitempty"`
+	MasterTarget int    `json:"master_target,omitempty"`
+}
+
+type MasterSyncDiff struct {
+	MasterProduct string `json:"master_product"`
+
+	//slot的归属group不同
+	Conflicts []*MasterSlotDiff `json:"conflicts,omitempty"`
+	//slot的归属相同，但迁移状态不同
+	Changes []*MasterSlotDiff `json:"changes,omitempty"`
+	//主集群的slot引用了本地不存在或没有server的group
+	MissingGroups []int `json:"missing_groups,omitempty"`
+
+	Applied bool `json:"applied"`
+}
+
+//对比主集群与本地的slot拓扑，confirm为true时将差异写入本地并同步到proxy
+func (s *Topom) MasterSync(confirm bool) (*MasterSyncDiff, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct == "" || s.slaveStore == nil {
+		return nil, errors.Errorf("dashboard has no master product")
+	}
+	if _, err := s.newContext(); err != nil {
+		return nil, err
+	}
+
+	master, err := s.slaveStore.SlotMappings()
+	if err != nil {
+		log.ErrorErrorf(err, "store: load slots of master product %s failed", s.config.MasterProduct)
+		return nil, errors.Errorf("store: load slots of master product failed")
+	}
+	local, err := s.store.SlotMappings()
+	if err != nil {
+		log.ErrorErrorf(err, "store: load slots failed")
+		return nil, errors.Errorf("store: load slots failed")
+	}
+	group, err := s.store.ListGroup()
+	if err != nil {
+		log.ErrorErrorf(err, "store: load group failed")
+		return nil, errors.Errorf("store: load group failed")
+	}
+
+	diff := diffMasterSlots(master, local, group)
+	diff.MasterProduct = s.config.MasterProduct
+	if !confirm {
+		return diff, nil
+	}
+	if len(diff.MissingGroups) != 0 {
+		return diff, errors.Errorf("groups %v of master product don't exist", diff.MissingGroups)
+	}
+
+	var slots []int
+	for _, list := range [][]*MasterSlotDiff{diff.Conflicts, diff.Changes} {
+		for _, x := range list {
+			s.dirtySlotsCache(x.Id)
+			if err := s.storeUpdateSlotMapping(master[x.Id]); err != nil {
+				return diff, err
+			}
+			slots = append(slots, x.Id)
+		}
+	}
+	log.Warnf("master sync: %d slots synced from master product %s", len(slots), s.config.MasterProduct)
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return diff, err
+	}
+	var list []*models.SlotMapping
+	for _, sid := range slots {
+		list = append(list, ctx.slots[sid])
+	}
+	if err := s.resyncSlotMappings(ctx, list...); err != nil {
+		return diff, err
+	}
+	diff.Applied = true
+	return diff, nil
+}
+
+func diffMasterSlots(master, local []*models.SlotMapping, group map[int]*models.Group) *MasterSyncDiff {
+	var diff = &MasterSyncDiff{}
+	var missing = make(map[int]bool)
+	for i, m := range master {
+		var l = &models.SlotMapping{Id: m.Id}
+		if i < len(local) && local[i] != nil {
+			l = local[i]
+		}
+		for _, gid := range []int{m.GroupId, m.Action.TargetId} {
+			if gid == 0 {
+				continue
+			}
+			if g := group[gid]; g == nil || len(g.Servers) == 0 {
+				missing[gid] = true
+			}
+		}
+		x := &MasterSlotDiff{
+			Id:          m.Id,
+			LocalGroup:  l.GroupId,
+			MasterGroup: m.GroupId,
+			LocalAction: l.Action.State, MasterAction: m.Action.State,
+			MasterTarget: m.Action.TargetId,
+		}
+		switch {
+		case m.GroupId != l.GroupId:
+			diff.Conflicts = append(diff.Conflicts, x)
+		case m.Action.State != l.Action.State || m.Action.TargetId != l.Action.TargetId:
+			diff.Changes = append(diff.Changes, x)
+		}
+	}
+	for gid := range missing {
+		diff.MissingGroups = append(diff.MissingGroups, gid)
+	}
+	sort.Ints(diff.MissingGroups)
+	return diff
+}
@@ -0,0 +1,33 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestDiffMasterSlots(x *testing.T) {
+	group := map[int]*models.Group{
+		1: {Id: 1, Servers: []*models.GroupServer{{Addr: "a:6379"}}},
+		2: {Id: 2, Servers: []*models.GroupServer{{Addr: "b:6379"}}},
+	}
+	local := []*models.SlotMapping{
+		{Id: 0, GroupId: 1}, {Id: 1, GroupId: 1}, {Id: 2, GroupId: 1}, {Id: 3, GroupId: 2},
+	}
+	master := []*models.SlotMapping{
+		{Id: 0, GroupId: 1}, {Id: 1, GroupId: 2}, {Id: 2, GroupId: 1}, {Id: 3, GroupId: 3},
+	}
+	master[2].Action.State = models.ActionPending
+	master[2].Action.TargetId = 2
+
+	diff := diffMasterSlots(master, local, group)
+	assert.Must(len(diff.Conflicts) == 2)
+	assert.Must(diff.Conflicts[0].Id == 1 && diff.Conflicts[0].LocalGroup == 1 && diff.Conflicts[0].MasterGroup == 2)
+	assert.Must(diff.Conflicts[1].Id == 3)
+	assert.Must(len(diff.Changes) == 1 && diff.Changes[0].Id == 2)
+	assert.Must(len(diff.MissingGroups) == 1 && diff.MissingGroups[0] == 3)
+}
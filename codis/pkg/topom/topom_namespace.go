@@ -0,0 +1,206 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2"
+)
+
+func (s *Topom) namespaces() *models.Namespaces {
+	if s.tenants == nil {
+		return &models.Namespaces{}
+	}
+	return s.tenants
+}
+
+func (s *Topom) Namespaces() []*models.Namespace {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list = []*models.Namespace{}
+	for _, ns := range s.namespaces().Namespaces {
+		x := *ns
+		list = append(list, &x)
+	}
+	return list
+}
+
+//新增或修改一个租户并下发给所有proxy，租户之间的密码不能相同，前缀不能互相包含
+func (s *Topom) UpdateNamespace(ns *models.Namespace) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if ns.Name == "" {
+		return errors.Errorf("invalid namespace name")
+	}
+	if ns.Password == "" || ns.Prefix == "" {
+		return errors.Errorf("invalid password or prefix of namespace-[%s]", ns.Name)
+	}
+	if ns.Ops < 0 || ns.BytesPerDay < 0 {
+		return errors.Errorf("invalid quota of namespace-[%s]", ns.Name)
+	}
+
+	var p = &models.Namespaces{}
+	for _, x := range s.namespaces().Namespaces {
+		if x.Name == ns.Name {
+			continue
+		}
+		if x.Password == ns.Password {
+			return errors.Errorf("namespace-[%s] has the same password as namespace-[%s]", ns.Name, x.Name)
+		}
+		if strings.HasPrefix(x.Prefix, ns.Prefix) || strings.HasPrefix(ns.Prefix, x.Prefix) {
+			return errors.Errorf("prefix of namespace-[%s] overlaps with namespace-[%s]", ns.Name, x.Name)
+		}
+		p.Namespaces = append(p.Namespaces, x)
+	}
+	p.Namespaces = append(p.Namespaces, &models.Namespace{
+		Name: ns.Name, Password: ns.Password, Prefix: ns.Prefix,
+		Ops: ns.Ops, BytesPerDay: ns.BytesPerDay,
+	})
+	sort.Sort(namespaceSorter(p.Namespaces))
+
+	if err := s.storeUpdateNamespaces(p); err != nil {
+		return err
+	}
+	s.tenants = p
+	return s.resyncNamespaces(ctx)
+}
+
+func (s *Topom) RemoveNamespace(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	var p = &models.Namespaces{}
+	for _, x := range s.namespaces().Namespaces {
+		if x.Name != name {
+			p.Namespaces = append(p.Namespaces, x)
+		}
+	}
+	if len(p.Namespaces) == len(s.namespaces().Namespaces) {
+		return errors.Errorf("namespace-[%s] doesn't exist", name)
+	}
+
+	if err := s.storeUpdateNamespaces(p); err != nil {
+		return err
+	}
+	s.tenants = p
+	return s.resyncNamespaces(ctx)
+}
+
+func (s *Topom) resyncNamespaces(ctx *context) error {
+	for _, p := range ctx.proxy {
+		if err := s.newProxyClient(p).SetNamespaces(s.namespaces()); err != nil {
+			log.ErrorErrorf(err, "proxy-[%s] set namespaces failed", p.Token)
+			return errors.Errorf("proxy-[%s] set namespaces failed", p.Token)
+		}
+	}
+	return nil
+}
+
+//日志中不打印密码
+func (s *Topom) storeUpdateNamespaces(p *models.Namespaces) error {
+	var names []string
+	for _, ns := range p.Namespaces {
+		names = append(names, ns.Name+":"+ns.Prefix)
+	}
+	log.Warnf("update namespaces: [%s]", strings.Join(names, ","))
+	if err := s.store.UpdateNamespaces(p); err != nil {
+		log.ErrorErrorf(err, "store: update namespaces failed")
+		return errors.Errorf("store: update namespaces failed")
+	}
+	return nil
+}
+
+//所有proxy上租户统计的汇总，配额在每个proxy上独立计数，BytesUsage取各proxy中的最大值
+type NamespaceStats struct {
+	proxy.NamespaceStatus
+	Proxies int `json:"proxies"`
+}
+
+func (s *Topom) NamespaceStats(timeout time.Duration) ([]*NamespaceStats, error) {
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	var list = s.namespaces().Namespaces
+	var fut sync2.Future
+	for _, p := range ctx.proxy {
+		fut.Add()
+		go func(c *proxy.ApiClient, token string) {
+			var ch = make(chan []*proxy.NamespaceStatus, 1)
+			go func() {
+				x, err := c.Namespaces()
+				if err != nil {
+					log.WarnErrorf(err, "proxy-[%s] get namespaces failed", token)
+				}
+				ch <- x
+			}()
+			select {
+			case x := <-ch:
+				fut.Done(token, x)
+			case <-time.After(timeout):
+				fut.Done(token, nil)
+			}
+		}(s.newProxyClient(p), p.Token)
+	}
+	s.mu.Unlock()
+
+	var m = make(map[string]*NamespaceStats)
+	var all = make([]*NamespaceStats, 0, len(list))
+	for _, ns := range list {
+		o := &NamespaceStats{}
+		o.Name, o.Prefix, o.Ops, o.BytesPerDay = ns.Name, ns.Prefix, ns.Ops, ns.BytesPerDay
+		m[ns.Name] = o
+		all = append(all, o)
+	}
+	for _, v := range fut.Wait() {
+		x, _ := v.([]*proxy.NamespaceStatus)
+		for _, p := range x {
+			o := m[p.Name]
+			if o == nil {
+				continue
+			}
+			o.Proxies++
+			o.BytesToday += p.BytesToday
+			o.Rejected += p.Rejected
+			o.Calls += p.Calls
+			o.QPS += p.QPS
+			o.Hits += p.Hits
+			o.Misses += p.Misses
+			o.MemoryEstimate += p.MemoryEstimate
+			if p.BytesUsage > o.BytesUsage {
+				o.BytesUsage = p.BytesUsage
+			}
+		}
+	}
+	for _, o := range all {
+		if total := o.Hits + o.Misses; total != 0 {
+			o.HitRate = float64(o.Hits) / float64(total)
+		}
+	}
+	return all, nil
+}
+
+type namespaceSorter []*models.Namespace
+
+func (s namespaceSorter) Len() int           { return len(s) }
+func (s namespaceSorter) Less(i, j int) bool { return s[i].Name < s[j].Name }
+func (s namespaceSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
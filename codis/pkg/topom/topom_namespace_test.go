@@ -0,0 +1,42 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestNamespace(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	assert.Must(len(t.Namespaces()) == 0)
+	assert.Must(t.UpdateNamespace(&models.Namespace{Name: "a", Prefix: "a:"}) != nil)
+
+	assert.MustNoError(t.UpdateNamespace(&models.Namespace{Name: "a", Password: "pa", Prefix: "a:"}))
+	assert.MustNoError(t.UpdateNamespace(&models.Namespace{Name: "b", Password: "pb", Prefix: "b:"}))
+	assert.Must(t.UpdateNamespace(&models.Namespace{Name: "c", Password: "pa", Prefix: "c:"}) != nil)
+	assert.Must(t.UpdateNamespace(&models.Namespace{Name: "c", Password: "pc", Prefix: "a:c:"}) != nil)
+	assert.MustNoError(t.UpdateNamespace(&models.Namespace{Name: "a", Password: "pa", Prefix: "a:", Ops: 100}))
+
+	list := t.Namespaces()
+	assert.Must(len(list) == 2)
+	assert.Must(list[0].Name == "a" && list[0].Ops == 100 && list[1].Name == "b")
+
+	stats, err := t.NamespaceStats(time.Second)
+	assert.MustNoError(err)
+	assert.Must(len(stats) == 2 && stats[0].Name == "a" && stats[0].Proxies == 0)
+
+	p, err := t.store.LoadNamespaces(true)
+	assert.MustNoError(err)
+	assert.Must(len(p.Namespaces) == 2)
+
+	assert.MustNoError(t.RemoveNamespace("a"))
+	assert.Must(t.RemoveNamespace("a") != nil)
+	assert.Must(len(t.Namespaces()) == 1)
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-martini/martini"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/rpc"
+)
+
+//codis-fe转发请求时带上集群名，多个product共用admin端口时按这个header分发
+const ProductHeader = "X-Codis-Product"
+
+//product名不能和/topom、/api/topom下已有的路由冲突
+var reservedProducts struct {
+	sync.Once
+	m map[string]bool
+}
+
+func reserveProductNames(routes []martini.Route) {
+	reservedProducts.Do(func() {
+		reservedProducts.m = make(map[string]bool)
+		for _, r := range routes {
+			for _, prefix := range []string{"/topom/", "/api/topom/"} {
+				if name, _ := splitProductPath(r.Pattern(), prefix); name != "" {
+					reservedProducts.m[name] = true
+				}
+			}
+		}
+	})
+}
+
+//返回path中prefix之后的第一段和剩余部分
+func splitProductPath(path, prefix string) (string, string) {
+	if !strings.HasPrefix(path, prefix) {
+		return "", ""
+	}
+	path = path[len(prefix):]
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		return path[:i], path[i:]
+	}
+	return path, ""
+}
+
+//主product的配置副本，不能再嵌套products和master_product
+func (c *Config) productConfig(p *ProductConfig) *Config {
+	x := *c
+	x.ProductName = p.ProductName
+	x.ProductAuth = p.ProductAuth
+	x.MasterProduct = ""
+	x.Products = nil
+	return &x
+}
+
+//新的product共用主product的admin端口，client由调用方为每个product单独创建，随product一起关闭
+func (s *Topom) AddProduct(client models.Client, p *ProductConfig) (*Topom, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosedTopom
+	}
+	if s.ladmin == nil {
+		return nil, errors.New("topom has no admin listener")
+	}
+
+	s.products.Lock()
+	defer s.products.Unlock()
+	if p.ProductName == s.config.ProductName || s.products.m[p.ProductName] != nil {
+		return nil, errors.Errorf("product-[%s] already exists", p.ProductName)
+	}
+
+	t, err := newTopom(client, s.config.productConfig(p))
+	if err != nil {
+		return nil, err
+	}
+	h := newApiServer(t)
+	if reservedProducts.m[p.ProductName] {
+		t.Close()
+		return nil, errors.Errorf("product-[%s] conflicts with admin api", p.ProductName)
+	}
+
+	t.model.AdminAddr = s.model.AdminAddr
+	t.model.Token = rpc.NewToken(p.ProductName, s.ladmin.Addr().String())
+	t.xauth = rpc.NewXAuth(p.ProductName)
+
+	s.products.m[p.ProductName] = t
+	s.products.h[p.ProductName] = h
+
+	log.Warnf("create new topom of product-[%s]:\n%s", p.ProductName, t.model.Encode())
+
+	t.startMetricsInfluxdb()
+	t.startMetricsMysql()
+	t.startMetricsRemoteWrite()
+	t.startMetricsOtlp()
+
+	return t, nil
+}
+
+func (s *Topom) Product(name string) *Topom {
+	s.products.RLock()
+	defer s.products.RUnlock()
+	return s.products.m[name]
+}
+
+//不包括主product
+func (s *Topom) ProductNames() []string {
+	s.products.RLock()
+	defer s.products.RUnlock()
+	var names = make([]string, 0, len(s.products.m))
+	for name := range s.products.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//已经online的product直接跳过，返回第一个失败的错误，可以重复调用
+func (s *Topom) StartProducts(routines bool) error {
+	var err error
+	for _, name := range s.ProductNames() {
+		if e := s.Product(name).Start(routines); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (s *Topom) closeProducts() {
+	s.products.Lock()
+	defer s.products.Unlock()
+	for name, t := range s.products.m {
+		if err := t.Close(); err != nil {
+			log.WarnErrorf(err, "close product-[%s] failed", name)
+		}
+	}
+}
+
+func (s *Topom) productHandler(name string) http.Handler {
+	s.products.RLock()
+	defer s.products.RUnlock()
+	return s.products.h[name]
+}
+
+type productRouter struct {
+	topom   *Topom
+	handler http.Handler
+}
+
+func (s *Topom) newProductRouter(h http.Handler) http.Handler {
+	return &productRouter{topom: s, handler: h}
+}
+
+//按header或者/topom/{product}、/api/topom/{product}前缀分发到对应product，其余请求由主product处理
+func (r *productRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if h := r.topom.productHandler(req.Header.Get(ProductHeader)); h != nil {
+		h.ServeHTTP(w, req)
+		return
+	}
+	for _, prefix := range []string{"/topom/", "/api/topom/"} {
+		name, rest := splitProductPath(req.URL.Path, prefix)
+		if name == "" {
+			continue
+		}
+		if h := r.topom.productHandler(name); h != nil {
+			req.URL.Path = strings.TrimSuffix(prefix, "/") + rest
+			req.URL.RawPath = ""
+			h.ServeHTTP(w, req)
+			return
+		}
+	}
+	r.handler.ServeHTTP(w, req)
+}
+
+func (s *apiServer) Products() (int, string) {
+	return rpc.ApiResponseJson(append([]string{s.topom.Config().ProductName}, s.topom.ProductNames()...))
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestTopomProducts(x *testing.T) {
+	client := newDiskClient()
+	t, err := New(newForkClient(client), config)
+	assert.MustNoError(err)
+	defer t.Close()
+	assert.MustNoError(t.Start(false))
+
+	p, err := t.AddProduct(newForkClient(client), &ProductConfig{ProductName: "topom_test2", ProductAuth: "auth2"})
+	assert.MustNoError(err)
+	assert.Must(p.Config().ProductAuth == "auth2" && p.Config().Products == nil)
+	assert.Must(p.Model().AdminAddr == t.Model().AdminAddr)
+	assert.MustNoError(t.StartProducts(false))
+	assert.Must(p.IsOnline())
+
+	_, err = t.AddProduct(newForkClient(client), &ProductConfig{ProductName: "topom_test2"})
+	assert.Must(err != nil)
+	_, err = t.AddProduct(newForkClient(client), &ProductConfig{ProductName: config.ProductName})
+	assert.Must(err != nil)
+	_, err = t.AddProduct(newForkClient(client), &ProductConfig{ProductName: "model"})
+	assert.Must(err != nil)
+
+	c := NewApiClient(t.Model().AdminAddr)
+	c.SetXAuth(config.ProductName)
+	names, err := c.Products()
+	assert.MustNoError(err)
+	assert.Must(len(names) == 2 && names[0] == config.ProductName && names[1] == "topom_test2")
+	m, err := c.Model()
+	assert.MustNoError(err)
+	assert.Must(m.ProductName == config.ProductName)
+
+	c2 := NewApiClient(t.Model().AdminAddr)
+	c2.SetProduct("topom_test2")
+	c2.SetXAuth("topom_test2")
+	m, err = c2.Model()
+	assert.MustNoError(err)
+	assert.Must(m.ProductName == "topom_test2")
+	o, err := c2.Overview()
+	assert.MustNoError(err)
+	assert.Must(o.Config.ProductName == "topom_test2")
+	assert.MustNoError(c2.XPing())
+
+	//xauth属于其他product
+	c2.SetXAuth(config.ProductName)
+	assert.Must(c2.XPing() != nil)
+
+	req, err := http.NewRequest("GET", "http://"+t.Model().AdminAddr+"/topom/model", nil)
+	assert.MustNoError(err)
+	req.Header.Set(ProductHeader, "topom_test2")
+	rsp, err := http.DefaultClient.Do(req)
+	assert.MustNoError(err)
+	defer rsp.Body.Close()
+	var model = &models.Topom{}
+	assert.MustNoError(json.NewDecoder(rsp.Body).Decode(model))
+	assert.Must(model.ProductName == "topom_test2")
+
+	assert.MustNoError(t.Close())
+	assert.Must(p.IsClosed())
+}
+
+func TestProductsConfig(x *testing.T) {
+	c := NewDefaultConfig()
+	c.ProductName = "demo"
+	c.Products = []*ProductConfig{{ProductName: "demo2"}}
+	assert.MustNoError(c.Validate())
+	c.Products = append(c.Products, &ProductConfig{ProductName: "demo"})
+	assert.Must(c.Validate() != nil)
+	c.Products = []*ProductConfig{{ProductName: "bad/name"}}
+	assert.Must(c.Validate() != nil)
+}
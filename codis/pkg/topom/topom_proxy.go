@@ -28,6 +28,9 @@ func (s *Topom) CreateProxy(addr string) error {
 	if err := c.XPing(); err != nil {
 		return errors.Errorf("proxy@%s check xauth failed, %s", addr, err)
 	}
+	if err := s.verifySlotHash(p); err != nil {
+		return err
+	}
 	if ctx.proxy[p.Token] != nil {
 		return errors.Errorf("proxy-[%s] already exists", p.Token)
 	} else {
@@ -59,6 +62,9 @@ func (s *Topom) OnlineProxy(addr string) error {
 	if err := c.XPing(); err != nil {
 		return errors.Errorf("proxy@%s check xauth failed", addr)
 	}
+	if err := s.verifySlotHash(p); err != nil {
+		return err
+	}
 	defer s.dirtyProxyCache(p.Token)
 
 	if d := ctx.proxy[p.Token]; d != nil {
@@ -173,9 +179,26 @@ func (s *Topom) newProxyClient(p *models.Proxy) *proxy.ApiClient {
 	return c
 }
 
+//proxy和dashboard的slot哈希不同时key会被路由到错误的group
+func (s *Topom) verifySlotHash(p *models.Proxy) error {
+	hash, tag := p.SlotHash, p.HashTag
+	if hash == "" {
+		hash, tag = models.SlotHashCRC32, models.DefaultHashTag
+	}
+	if hash != s.config.SlotHash || tag != s.config.HashTag {
+		return errors.Errorf("proxy@%s slot hash %s%s doesn't match %s%s",
+			p.AdminAddr, hash, tag, s.config.SlotHash, s.config.HashTag)
+	}
+	return nil
+}
+
 func (s *Topom) reinitProxy(ctx *context, p *models.Proxy, c *proxy.ApiClient) error {
 	log.Warnf("proxy-[%s] reinit:\n%s", p.Token, p.Encode())
-	if err := c.FillSlots(ctx.toSlotSlice(ctx.slots, p)...); err != nil {
+	x, slots, err := s.proxyContext(ctx, ctx.slots)
+	if err != nil {
+		return err
+	}
+	if err := c.FillSlots(append(x.toSlotSlice(slots, p), s.toRouteSlots(x, p, 0)...)...); err != nil {
 		log.ErrorErrorf(err, "proxy-[%s] fillslots failed", p.Token)
 		return errors.Errorf("proxy-[%s] fillslots failed", p.Token)
 	}
@@ -187,14 +210,52 @@ func (s *Topom) reinitProxy(ctx *context, p *models.Proxy, c *proxy.ApiClient) e
 		log.ErrorErrorf(err, "proxy-[%s] set sentinels failed", p.Token)
 		return errors.Errorf("proxy-[%s] set sentinels failed", p.Token)
 	}
+	if err := c.SetReadOnly(s.isStandby()); err != nil {
+		log.ErrorErrorf(err, "proxy-[%s] set readonly failed", p.Token)
+		return errors.Errorf("proxy-[%s] set readonly failed", p.Token)
+	}
+	if err := c.SetKeyQuotas(s.keyQuotas()); err != nil {
+		log.ErrorErrorf(err, "proxy-[%s] set key quotas failed", p.Token)
+		return errors.Errorf("proxy-[%s] set key quotas failed", p.Token)
+	}
+	if err := c.SetTTLRules(s.ttlRules()); err != nil {
+		log.ErrorErrorf(err, "proxy-[%s] set ttl rules failed", p.Token)
+		return errors.Errorf("proxy-[%s] set ttl rules failed", p.Token)
+	}
+	if err := c.SetRequestFilter(s.requestFilter()); err != nil {
+		log.ErrorErrorf(err, "proxy-[%s] set request filter failed", p.Token)
+		return errors.Errorf("proxy-[%s] set request filter failed", p.Token)
+	}
+	if err := c.SetNamespaces(s.namespaces()); err != nil {
+		log.ErrorErrorf(err, "proxy-[%s] set namespaces failed", p.Token)
+		return errors.Errorf("proxy-[%s] set namespaces failed", p.Token)
+	}
+	if err := c.SetACLUsers(s.aclUsers()); err != nil {
+		log.ErrorErrorf(err, "proxy-[%s] set acl users failed", p.Token)
+		return errors.Errorf("proxy-[%s] set acl users failed", p.Token)
+	}
 	return nil
 }
 
 func (s *Topom) resyncSlotMappingsByGroupId(ctx *context, gid int) error {
-	return s.resyncSlotMappings(ctx, ctx.getSlotMappingsByGroupId(gid)...)
+	if err := s.resyncSlotMappings(ctx, ctx.getSlotMappingsByGroupId(gid)...); err != nil {
+		return err
+	}
+	return s.resyncPrefixRoutes(ctx, gid)
 }
 
 func (s *Topom) resyncSlotMappings(ctx *context, slots ...*models.SlotMapping) error {
+	if len(slots) == 0 {
+		return nil
+	}
+	ctx, slots, err := s.proxyContext(ctx, slots)
+	if err != nil {
+		return err
+	}
+	return s.resyncSlotMappingsDirect(ctx, slots...)
+}
+
+func (s *Topom) resyncSlotMappingsDirect(ctx *context, slots ...*models.SlotMapping) error {
 	if len(slots) == 0 {
 		return nil
 	}
@@ -0,0 +1,117 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+func (s *Topom) keyQuotas() *models.KeyQuotas {
+	if s.quotas == nil {
+		return &models.KeyQuotas{}
+	}
+	return s.quotas
+}
+
+func (s *Topom) KeyQuotas() []*models.KeyQuota {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list = []*models.KeyQuota{}
+	for _, q := range s.keyQuotas().Quotas {
+		x := *q
+		list = append(list, &x)
+	}
+	return list
+}
+
+//新增或修改一个前缀的写入配额，并下发给所有proxy
+func (s *Topom) UpdateKeyQuota(q *models.KeyQuota) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if q.Prefix == "" {
+		return errors.Errorf("invalid quota prefix")
+	}
+	if q.Ops < 0 || q.BytesPerDay < 0 {
+		return errors.Errorf("invalid quota of prefix-[%s]", q.Prefix)
+	}
+	if q.Ops == 0 && q.BytesPerDay == 0 {
+		return errors.Errorf("quota of prefix-[%s] has no limit", q.Prefix)
+	}
+
+	var p = &models.KeyQuotas{}
+	for _, x := range s.keyQuotas().Quotas {
+		if x.Prefix != q.Prefix {
+			p.Quotas = append(p.Quotas, x)
+		}
+	}
+	p.Quotas = append(p.Quotas, &models.KeyQuota{
+		Prefix: q.Prefix, Ops: q.Ops, BytesPerDay: q.BytesPerDay,
+	})
+	sort.Sort(keyQuotaSorter(p.Quotas))
+
+	if err := s.storeUpdateKeyQuotas(p); err != nil {
+		return err
+	}
+	s.quotas = p
+	return s.resyncKeyQuotas(ctx)
+}
+
+func (s *Topom) RemoveKeyQuota(prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	var p = &models.KeyQuotas{}
+	for _, x := range s.keyQuotas().Quotas {
+		if x.Prefix != prefix {
+			p.Quotas = append(p.Quotas, x)
+		}
+	}
+	if len(p.Quotas) == len(s.keyQuotas().Quotas) {
+		return errors.Errorf("quota of prefix-[%s] doesn't exist", prefix)
+	}
+
+	if err := s.storeUpdateKeyQuotas(p); err != nil {
+		return err
+	}
+	s.quotas = p
+	return s.resyncKeyQuotas(ctx)
+}
+
+func (s *Topom) resyncKeyQuotas(ctx *context) error {
+	for _, p := range ctx.proxy {
+		if err := s.newProxyClient(p).SetKeyQuotas(s.keyQuotas()); err != nil {
+			log.ErrorErrorf(err, "proxy-[%s] set key quotas failed", p.Token)
+			return errors.Errorf("proxy-[%s] set key quotas failed", p.Token)
+		}
+	}
+	return nil
+}
+
+func (s *Topom) storeUpdateKeyQuotas(p *models.KeyQuotas) error {
+	log.Warnf("update key quotas:\n%s", p.Encode())
+	if err := s.store.UpdateKeyQuotas(p); err != nil {
+		log.ErrorErrorf(err, "store: update key quotas failed")
+		return errors.Errorf("store: update key quotas failed")
+	}
+	return nil
+}
+
+type keyQuotaSorter []*models.KeyQuota
+
+func (s keyQuotaSorter) Len() int           { return len(s) }
+func (s keyQuotaSorter) Less(i, j int) bool { return s[i].Prefix < s[j].Prefix }
+func (s keyQuotaSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
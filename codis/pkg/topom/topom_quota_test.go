@@ -0,0 +1,36 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestKeyQuota(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	assert.Must(len(t.KeyQuotas()) == 0)
+	assert.Must(t.UpdateKeyQuota(&models.KeyQuota{Prefix: "user:"}) != nil)
+	assert.Must(t.UpdateKeyQuota(&models.KeyQuota{Prefix: "user:", Ops: -1}) != nil)
+
+	assert.MustNoError(t.UpdateKeyQuota(&models.KeyQuota{Prefix: "user:", Ops: 100}))
+	assert.MustNoError(t.UpdateKeyQuota(&models.KeyQuota{Prefix: "feed:", BytesPerDay: 1 << 30}))
+	assert.MustNoError(t.UpdateKeyQuota(&models.KeyQuota{Prefix: "user:", Ops: 200}))
+
+	list := t.KeyQuotas()
+	assert.Must(len(list) == 2)
+	assert.Must(list[0].Prefix == "feed:" && list[1].Prefix == "user:" && list[1].Ops == 200)
+
+	p, err := t.store.LoadKeyQuotas(true)
+	assert.MustNoError(err)
+	assert.Must(len(p.Quotas) == 2)
+
+	assert.MustNoError(t.RemoveKeyQuota("user:"))
+	assert.Must(t.RemoveKeyQuota("user:") != nil)
+	assert.Must(len(t.KeyQuotas()) == 1)
+}
@@ -0,0 +1,509 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type ReplicationGroupStatus struct {
+	Id        int    `json:"id"`
+	Primary   string `json:"primary"`
+	Secondary string `json:"secondary"`
+
+	//备product中master实际的复制源
+	Master     string `json:"master,omitempty"`
+	LinkStatus string `json:"link_status,omitempty"`
+	//-1表示无法计算
+	OffsetLag int64 `json:"offset_lag"`
+	//距离上次收到主product数据的秒数，-1表示未知
+	LastIOSeconds int64  `json:"last_io_seconds"`
+	Error         string `json:"error,omitempty"`
+}
+
+type ReplicationLinkStatus struct {
+	*models.ReplicationLink
+	Groups     []*ReplicationGroupStatus `json:"groups,omitempty"`
+	UpdateTime string                    `json:"update_time,omitempty"`
+}
+
+//返回备product中与主product group一一对应的master，主product中的空group会被忽略
+func (s *Topom) replicationPairs(ctx *context, secondary string) ([]*ReplicationGroupStatus, error) {
+	store := models.NewStore(s.store.Client(), secondary)
+	group, err := store.ListGroup()
+	if err != nil {
+		return nil, err
+	}
+	var pairs []*ReplicationGroupStatus
+	for _, g := range models.SortGroup(ctx.group) {
+		if len(g.Servers) == 0 {
+			continue
+		}
+		x := group[g.Id]
+		if x == nil || len(x.Servers) == 0 {
+			return nil, errors.Errorf("product-[%s] group-[%d] doesn't exist or is empty", secondary, g.Id)
+		}
+		pairs = append(pairs, &ReplicationGroupStatus{
+			Id: g.Id, Primary: g.Servers[0].Addr, Secondary: x.Servers[0].Addr,
+			OffsetLag: -1, LastIOSeconds: -1,
+		})
+	}
+	return pairs, nil
+}
+
+func (s *Topom) setReplicationMaster(addr, master string) error {
+	c, err := s.action.redisp.GetClient(addr)
+	if err != nil {
+		return err
+	}
+	err = c.SetMaster(master)
+	s.action.redisp.PutClient(c, err)
+	if err != nil {
+		return errors.Errorf("server-[%s] slaveof %s failed: %s", addr, master, err)
+	}
+	return nil
+}
+
+func (s *Topom) CreateReplicationLink(secondary string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if err := models.ValidateProduct(secondary); err != nil {
+		return err
+	}
+	if secondary == s.config.ProductName {
+		return errors.Errorf("replicate to the same product-[%s]", secondary)
+	}
+	links, err := s.store.ListReplication()
+	if err != nil {
+		return err
+	}
+	if links[secondary] != nil {
+		return errors.Errorf("replication-[%s] already exists", secondary)
+	}
+
+	pairs, err := s.replicationPairs(ctx, secondary)
+	if err != nil {
+		return err
+	}
+	if len(pairs) == 0 {
+		return errors.Errorf("no group to replicate")
+	}
+	for _, p := range pairs {
+		if err := s.setReplicationMaster(p.Secondary, p.Primary); err != nil {
+			return err
+		}
+	}
+	return s.storeUpdateReplication(&models.ReplicationLink{
+		Secondary: secondary, State: models.ReplicationSyncing,
+		CreateTime: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+//停止复制，备product中的master不再同步主product的数据
+func (s *Topom) RemoveReplicationLink(secondary string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	links, err := s.store.ListReplication()
+	if err != nil {
+		return err
+	}
+	r := links[secondary]
+	if r == nil {
+		return errors.Errorf("replication-[%s] doesn't exist", secondary)
+	}
+	if r.State == models.ReplicationSyncing {
+		pairs, err := s.replicationPairs(ctx, secondary)
+		if err != nil {
+			return err
+		}
+		for _, p := range pairs {
+			if err := s.setReplicationMaster(p.Secondary, "NO:ONE"); err != nil {
+				return err
+			}
+		}
+	}
+	s.replicationStatus.Lock()
+	delete(s.replicationStatus.m, secondary)
+	s.replicationStatus.Unlock()
+	return s.storeRemoveReplication(r)
+}
+
+//提升备product，默认要求所有group的复制链路正常且没有延迟，force为true时跳过检查
+func (s *Topom) PromoteReplicationSecondary(secondary string, force bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	links, err := s.store.ListReplication()
+	if err != nil {
+		return err
+	}
+	r := links[secondary]
+	if r == nil {
+		return errors.Errorf("replication-[%s] doesn't exist", secondary)
+	}
+	if r.State != models.ReplicationSyncing {
+		return errors.Errorf("replication-[%s] is %s", secondary, r.State)
+	}
+
+	pairs, err := s.replicationPairs(ctx, secondary)
+	if err != nil {
+		return err
+	}
+	if !force {
+		for _, p := range pairs {
+			s.probeReplicationGroup(p)
+			if err := replicationCaughtUp(p); err != nil {
+				return err
+			}
+		}
+	}
+	for _, p := range pairs {
+		if err := s.setReplicationMaster(p.Secondary, "NO:ONE"); err != nil {
+			return err
+		}
+	}
+	log.Warnf("replication-[%s] promoted, force = %t", secondary, force)
+
+	r.State = models.ReplicationPromoted
+	r.PromoteTime = time.Now().Format("2006-01-02 15:04:05")
+	return s.storeUpdateReplication(r)
+}
+
+func (s *Topom) probeReplicationGroup(p *ReplicationGroupStatus) {
+	master, err := s.stats.redisp.Info(p.Primary)
+	if err != nil {
+		p.Error = err.Error()
+		return
+	}
+	replica, err := s.stats.redisp.Info(p.Secondary)
+	if err != nil {
+		p.Error = err.Error()
+		return
+	}
+	if replica["master_host"] != "" {
+		p.Master = net.JoinHostPort(replica["master_host"], replica["master_port"])
+	}
+	p.LinkStatus = replica["master_link_status"]
+	p.OffsetLag = replicationOffsetLag(master, replica)
+	if n, err := strconv.ParseInt(replica["master_last_io_seconds_ago"], 10, 64); err == nil {
+		p.LastIOSeconds = n
+	}
+}
+
+func replicationCaughtUp(p *ReplicationGroupStatus) error {
+	if p.Error != "" {
+		return errors.Errorf("group-[%d] replication error: %s", p.Id, p.Error)
+	}
+	if p.Master != p.Primary || p.LinkStatus != "up" || p.OffsetLag != 0 {
+		return errors.Errorf("group-[%d] replication is not caught up, link = %s, lag = %d",
+			p.Id, p.LinkStatus, p.OffsetLag)
+	}
+	return nil
+}
+
+//redis为repl offset之差，pika为binlog_offset之差，binlog文件号不同时返回-1
+func replicationOffsetLag(master, replica map[string]string) int64 {
+	if s, ok := replica["slave_repl_offset"]; ok {
+		moffset, err1 := strconv.ParseInt(master["master_repl_offset"], 10, 64)
+		soffset, err2 := strconv.ParseInt(s, 10, 64)
+		if err1 != nil || err2 != nil {
+			return -1
+		}
+		return moffset - soffset
+	}
+	mfields := strings.Fields(master["binlog_offset"])
+	sfields := strings.Fields(replica["binlog_offset"])
+	if len(mfields) != 2 || len(sfields) != 2 || mfields[0] != sfields[0] {
+		return -1
+	}
+	moffset, err1 := strconv.ParseInt(mfields[1], 10, 64)
+	soffset, err2 := strconv.ParseInt(sfields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return -1
+	}
+	return moffset - soffset
+}
+
+//刷新所有复制链路的状态，主product的master切换后重新指定备product的复制源
+func (s *Topom) RefreshReplicationLinks() error {
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	links, err := s.store.ListReplication()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var pairs = make(map[string][]*ReplicationGroupStatus)
+	for _, r := range links {
+		if r.State != models.ReplicationSyncing {
+			continue
+		}
+		if pairs[r.Secondary], err = s.replicationPairs(ctx, r.Secondary); err != nil {
+			log.WarnErrorf(err, "replication-[%s] load groups failed", r.Secondary)
+		}
+	}
+	s.mu.Unlock()
+
+	var status = make(map[string]*ReplicationLinkStatus)
+	for _, r := range links {
+		x := &ReplicationLinkStatus{
+			ReplicationLink: r, Groups: pairs[r.Secondary],
+			UpdateTime: time.Now().Format("2006-01-02 15:04:05"),
+		}
+		for _, p := range x.Groups {
+			s.probeReplicationGroup(p)
+			if p.Error == "" && p.Master != p.Primary {
+				log.Warnf("replication-[%s] group-[%d] replicating from %s, reset to %s", r.Secondary, p.Id, p.Master, p.Primary)
+				if err := s.resetReplicationMaster(r.Secondary, p); err != nil {
+					log.WarnErrorf(err, "replication-[%s] group-[%d] reset master failed", r.Secondary, p.Id)
+				}
+			}
+		}
+		status[r.Secondary] = x
+	}
+	s.replicationStatus.Lock()
+	s.replicationStatus.m = status
+	s.replicationStatus.Unlock()
+	return nil
+}
+
+//持有锁并重新检查链路状态，避免与promote、remove并发时重新建立复制
+func (s *Topom) resetReplicationMaster(secondary string, p *ReplicationGroupStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	links, err := s.store.ListReplication()
+	if err != nil {
+		return err
+	}
+	if r := links[secondary]; r == nil || r.State != models.ReplicationSyncing {
+		return nil
+	}
+	return s.setReplicationMaster(p.Secondary, p.Primary)
+}
+
+func (s *Topom) ReplicationLinks() []*ReplicationLinkStatus {
+	s.replicationStatus.Lock()
+	defer s.replicationStatus.Unlock()
+	var names []string
+	for name := range s.replicationStatus.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list = make([]*ReplicationLinkStatus, 0, len(names))
+	for _, name := range names {
+		list = append(list, s.replicationStatus.m[name])
+	}
+	return list
+}
+
+const DefaultCutoverTimeout = time.Second * 30
+
+//计划内切换到备product: 当前product先变为只读，等待所有group追上后提升备product，
+//再反向建立复制，当前product成为备product的只读备集群，等待超时则恢复写入
+func (s *Topom) ReplicationCutover(secondary string, timeout time.Duration) error {
+	pairs, err := s.freezeForCutover(secondary)
+	if err != nil {
+		return err
+	}
+	if err := s.waitReplicationCaughtUp(pairs, timeout); err != nil {
+		log.WarnErrorf(err, "replication-[%s] cutover aborted, resume writing", secondary)
+		if err := s.unfreezeForCutover(); err != nil {
+			log.ErrorErrorf(err, "replication-[%s] cutover rollback failed", secondary)
+		}
+		return err
+	}
+	return s.finishCutover(secondary, pairs)
+}
+
+func (s *Topom) freezeForCutover(secondary string) ([]*ReplicationGroupStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.isStandby() {
+		return nil, errors.Errorf("already standby of product-[%s]", s.standby.Primary)
+	}
+	links, err := s.store.ListReplication()
+	if err != nil {
+		return nil, err
+	}
+	r := links[secondary]
+	if r == nil {
+		return nil, errors.Errorf("replication-[%s] doesn't exist", secondary)
+	}
+	if r.State != models.ReplicationSyncing {
+		return nil, errors.Errorf("replication-[%s] is %s", secondary, r.State)
+	}
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing {
+			return nil, errors.Errorf("slot-[%d] action is not finished", m.Id)
+		}
+	}
+	pairs, err := s.replicationPairs(ctx, secondary)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &models.Standby{
+		Enabled: true, Primary: secondary,
+		Since: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := s.storeUpdateStandby(p); err != nil {
+		return nil, err
+	}
+	s.standby = p
+	log.Warnf("replication-[%s] cutover: product-[%s] stops writing", secondary, s.config.ProductName)
+
+	if err := s.resyncReadOnly(ctx, true); err != nil {
+		s.standby = &models.Standby{}
+		if err := s.storeUpdateStandby(s.standby); err != nil {
+			log.WarnErrorf(err, "replication-[%s] cutover rollback standby failed", secondary)
+		}
+		s.resyncReadOnly(ctx, false)
+		return nil, err
+	}
+	return pairs, nil
+}
+
+//写入停止后等待所有group的复制延迟降为0
+func (s *Topom) waitReplicationCaughtUp(pairs []*ReplicationGroupStatus, timeout time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+	for {
+		var err error
+		for _, p := range pairs {
+			p.Master, p.LinkStatus, p.Error = "", "", ""
+			p.OffsetLag, p.LastIOSeconds = -1, -1
+			s.probeReplicationGroup(p)
+			if err = replicationCaughtUp(p); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			return nil
+		}
+		if s.IsClosed() {
+			return ErrClosedTopom
+		}
+		if time.Now().After(deadline) {
+			return errors.Errorf("timeout after %s: %s", timeout, err)
+		}
+		time.Sleep(time.Millisecond * 200)
+	}
+}
+
+func (s *Topom) unfreezeForCutover() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+	p := &models.Standby{}
+	if err := s.storeUpdateStandby(p); err != nil {
+		return err
+	}
+	s.standby = p
+	return s.resyncReadOnly(ctx, false)
+}
+
+func (s *Topom) finishCutover(secondary string, pairs []*ReplicationGroupStatus) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range pairs {
+		if err := s.setReplicationMaster(p.Secondary, "NO:ONE"); err != nil {
+			return err
+		}
+	}
+	links, err := s.store.ListReplication()
+	if err != nil {
+		return err
+	}
+	var now = time.Now().Format("2006-01-02 15:04:05")
+	if r := links[secondary]; r != nil {
+		r.State, r.PromoteTime = models.ReplicationPromoted, now
+		if err := s.storeUpdateReplication(r); err != nil {
+			return err
+		}
+	}
+
+	//反向复制，由备product的dashboard维护链路
+	for _, p := range pairs {
+		if err := s.setReplicationMaster(p.Primary, p.Secondary); err != nil {
+			return err
+		}
+	}
+	store := models.NewStore(s.store.Client(), secondary)
+	r := &models.ReplicationLink{
+		Secondary: s.config.ProductName, State: models.ReplicationSyncing, CreateTime: now,
+	}
+	if err := store.UpdateReplication(r); err != nil {
+		log.ErrorErrorf(err, "store: create replication of product-[%s] failed", secondary)
+		return errors.Errorf("store: create replication of product-[%s] failed", secondary)
+	}
+	log.Warnf("replication-[%s] cutover: product-[%s] promoted, product-[%s] becomes standby",
+		secondary, secondary, s.config.ProductName)
+
+	return s.promoteCutoverStandby(store, secondary)
+}
+
+//备product处于只读状态时恢复写入，dashboard不在线时只修改协调服务中的记录，启动后生效
+func (s *Topom) promoteCutoverStandby(store *models.Store, secondary string) error {
+	p, err := store.LoadStandby(false)
+	if err != nil {
+		return err
+	}
+	if p == nil || !p.Enabled {
+		return nil
+	}
+
+	s.products.RLock()
+	t := s.products.m[secondary]
+	s.products.RUnlock()
+	if t != nil {
+		return t.PromoteStandby()
+	}
+
+	if m, err := store.LoadTopom(false); err != nil {
+		return err
+	} else if m != nil {
+		c := NewApiClient(m.AdminAddr)
+		c.SetXAuth(secondary)
+		if err := c.PromoteStandby(); err != nil {
+			log.WarnErrorf(err, "promote standby of product-[%s] failed", secondary)
+			return errors.Errorf("promote standby of product-[%s] failed, please promote it manually", secondary)
+		}
+		return nil
+	}
+	return store.UpdateStandby(&models.Standby{})
+}
@@ -0,0 +1,77 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestReplicationOffsetLag(x *testing.T) {
+	master := map[string]string{"master_repl_offset": "1000"}
+	replica := map[string]string{"slave_repl_offset": "900"}
+	assert.Must(replicationOffsetLag(master, replica) == 100)
+
+	master = map[string]string{"binlog_offset": "3 2000"}
+	replica = map[string]string{"binlog_offset": "3 1500"}
+	assert.Must(replicationOffsetLag(master, replica) == 500)
+
+	replica = map[string]string{"binlog_offset": "2 1500"}
+	assert.Must(replicationOffsetLag(master, replica) == -1)
+}
+
+func TestReplicationCutover(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+
+	const secondary = "topom_test_dr"
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	store := models.NewStore(t.store.Client(), secondary)
+	assert.MustNoError(store.UpdateGroup(&models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s2.Addr}}}))
+
+	assert.Must(t.ReplicationCutover(secondary, time.Second) != nil)
+	assert.MustNoError(t.storeUpdateReplication(&models.ReplicationLink{
+		Secondary: secondary, State: models.ReplicationSyncing,
+	}))
+
+	//备product没有追上时超时，恢复写入
+	err := t.ReplicationCutover(secondary, time.Millisecond*500)
+	assert.Must(err != nil)
+	assert.Must(!t.StandbyStatus().Enabled)
+	links, err := t.store.ListReplication()
+	assert.MustNoError(err)
+	assert.Must(links[secondary].State == models.ReplicationSyncing)
+
+	assert.MustNoError(t.SetStandby(secondary))
+	assert.Must(t.ReplicationCutover(secondary, time.Second) != nil)
+}
+
+func TestReplicationSeries(x *testing.T) {
+	stats := &Stats{}
+	stats.Replication = []*ReplicationLinkStatus{{
+		ReplicationLink: &models.ReplicationLink{Secondary: "dr"},
+		Groups: []*ReplicationGroupStatus{
+			{Id: 1, LinkStatus: "up", OffsetLag: 100, LastIOSeconds: 1},
+			{Id: 2, LinkStatus: "down", OffsetLag: -1, LastIOSeconds: -1},
+		},
+	}}
+	var values = make(map[string]float64)
+	for _, x := range remoteWriteClusterSeries("demo", stats) {
+		if x.Labels["secondary"] == "dr" {
+			values[x.Name+"/"+x.Labels["group"]] = x.Value
+		}
+	}
+	assert.Must(len(values) == 6)
+	assert.Must(values["codis_replication_link_up/1"] == 1 && values["codis_replication_link_up/2"] == 0)
+	assert.Must(values["codis_replication_offset_lag/1"] == 100)
+	assert.Must(values["codis_replication_last_io_seconds/2"] == -1)
+}
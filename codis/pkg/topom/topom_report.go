@@ -0,0 +1,460 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+const (
+	ReportDaily  = "daily"
+	ReportWeekly = "weekly"
+)
+
+const (
+	reportMaxCommands   = 10
+	reportMaxMigrations = 100
+
+	//慢请求的采样间隔，保留一周的采样用于周报
+	reportSamplePeriod = time.Hour
+	reportMaxSamples   = 7 * 24
+)
+
+//集群的日报或周报，由dashboard按report_period定期生成并通过webhook或邮件发送
+type Report struct {
+	ProductName string `json:"product_name"`
+	Period      string `json:"period"`
+	Begin       string `json:"begin"`
+	End         string `json:"end"`
+
+	Capacity struct {
+		Groups     int            `json:"groups"`
+		UsedMemory int64          `json:"used_memory"`
+		MaxMemory  int64          `json:"max_memory"`
+		Keys       int64          `json:"keys"`
+		Group      []*ReportGroup `json:"group,omitempty"`
+	} `json:"capacity"`
+
+	//proxy启动以来调用次数最多的命令
+	TopCommands []*ReportCommand `json:"top_commands,omitempty"`
+
+	//每小时的请求数和慢请求数（延时100ms以上）
+	SlowTrend []*ReportSample `json:"slow_trend,omitempty"`
+
+	Migration struct {
+		Done      int     `json:"done"`
+		Cancelled int     `json:"cancelled"`
+		AvgSecs   float64 `json:"avg_secs"`
+		//最近完成的迁移，最多reportMaxMigrations条
+		Recent []*ReportMigration `json:"recent,omitempty"`
+	} `json:"migration"`
+}
+
+type ReportGroup struct {
+	Id         int    `json:"id"`
+	Master     string `json:"master"`
+	UsedMemory int64  `json:"used_memory"`
+	MaxMemory  int64  `json:"max_memory"`
+	Keys       int64  `json:"keys"`
+}
+
+type ReportCommand struct {
+	OpStr string `json:"opstr"`
+	Calls int64  `json:"calls"`
+	Fails int64  `json:"fails"`
+}
+
+type ReportSample struct {
+	Time  int64 `json:"time"`
+	Calls int64 `json:"calls"`
+	Slow  int64 `json:"slow"`
+}
+
+type ReportMigration struct {
+	Slot    int     `json:"slot"`
+	From    int     `json:"from"`
+	To      int     `json:"to"`
+	Time    int64   `json:"time"`
+	Elapsed float64 `json:"elapsed"`
+}
+
+//返回不晚于now的最近一次发送时间，日报为每天的hour点，周报为每周一的hour点
+func lastReportSchedule(period string, hour int, now time.Time) time.Time {
+	t := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if t.After(now) {
+		t = t.AddDate(0, 0, -1)
+	}
+	if period == ReportWeekly {
+		t = t.AddDate(0, 0, -((int(t.Weekday()) + 6) % 7))
+	}
+	return t
+}
+
+func reportWindow(period string) time.Duration {
+	if period == ReportWeekly {
+		return time.Hour * 24 * 7
+	}
+	return time.Hour * 24
+}
+
+//由后台协程每分钟调用，记录慢请求采样，到达发送时间后生成并发送报告
+func (s *Topom) refreshReport(now time.Time) {
+	s.reporter.Lock()
+	var sample = len(s.reporter.samples) == 0 ||
+		now.Sub(time.Unix(s.reporter.samples[len(s.reporter.samples)-1].Time, 0)) >= reportSamplePeriod
+	s.reporter.Unlock()
+	if sample {
+		if err := s.recordReportSample(now); err != nil {
+			log.WarnErrorf(err, "report: record sample failed")
+		}
+	}
+
+	period := s.config.ReportPeriod
+	if period == "" {
+		return
+	}
+	sched := lastReportSchedule(period, s.config.ReportHour, now)
+
+	s.reporter.Lock()
+	//dashboard启动后不补发之前的报告
+	if s.reporter.lastSent.IsZero() {
+		s.reporter.lastSent = sched
+	}
+	due := s.reporter.lastSent.Before(sched)
+	if due {
+		s.reporter.lastSent = sched
+	}
+	s.reporter.Unlock()
+	if !due {
+		return
+	}
+
+	r, err := s.GenerateReport(period)
+	if err != nil {
+		log.WarnErrorf(err, "report: generate %s report failed", period)
+		return
+	}
+	if err := s.deliverReport(r); err != nil {
+		log.WarnErrorf(err, "report: deliver %s report failed", period)
+		return
+	}
+	log.Warnf("report: %s report [%s, %s] delivered", period, r.Begin, r.End)
+}
+
+//慢请求数取所有proxy上各命令延时100ms以上的请求数之和
+func (s *Topom) recordReportSample(now time.Time) error {
+	stats, err := s.Stats()
+	if err != nil {
+		return err
+	}
+	var total, slow int64
+	for _, v := range stats.Proxy.Stats {
+		if v == nil || v.Stats == nil {
+			continue
+		}
+		total += v.Stats.Ops.Total
+		for _, c := range v.Stats.Ops.Cmd {
+			if c.OpStr == "ALL" {
+				continue
+			}
+			slow += c.Delay100ms + c.Delay200ms + c.Delay300ms + c.Delay500ms + c.Delay1s + c.Delay2s + c.Delay3s
+		}
+	}
+
+	s.reporter.Lock()
+	defer s.reporter.Unlock()
+	//proxy重启后计数会变小，此时只记录重启后的请求数
+	calls := total - s.reporter.lastCalls
+	if calls < 0 || len(s.reporter.samples) == 0 {
+		calls = 0
+	}
+	s.reporter.lastCalls = total
+	s.reporter.samples = append(s.reporter.samples, &ReportSample{Time: now.Unix(), Calls: calls, Slow: slow})
+	if n := len(s.reporter.samples) - reportMaxSamples; n > 0 {
+		s.reporter.samples = s.reporter.samples[n:]
+	}
+	return nil
+}
+
+func (s *Topom) GenerateReport(period string) (*Report, error) {
+	if period != ReportDaily && period != ReportWeekly {
+		return nil, errors.Errorf("invalid report period = %s", period)
+	}
+	stats, err := s.Stats()
+	if err != nil {
+		return nil, err
+	}
+	end := time.Now()
+	begin := end.Add(-reportWindow(period))
+
+	r := &Report{
+		ProductName: s.config.ProductName, Period: period,
+		Begin: begin.Format("2006-01-02 15:04:05"),
+		End:   end.Format("2006-01-02 15:04:05"),
+	}
+	reportCapacity(r, stats)
+	reportTopCommands(r, stats)
+
+	s.reporter.Lock()
+	for _, x := range s.reporter.samples {
+		if x.Time >= begin.Unix() {
+			p := *x
+			r.SlowTrend = append(r.SlowTrend, &p)
+		}
+	}
+	s.reporter.Unlock()
+
+	var histories []*models.SlotHistory
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		h, err := s.SlotHistory(sid, begin.Unix())
+		if err != nil {
+			return nil, err
+		}
+		histories = append(histories, h)
+	}
+	reportMigrations(r, histories)
+	return r, nil
+}
+
+//只统计每个group的master
+func reportCapacity(r *Report, stats *Stats) {
+	for _, g := range stats.Group.Models {
+		if len(g.Servers) == 0 {
+			continue
+		}
+		x := &ReportGroup{Id: g.Id, Master: g.Servers[0].Addr}
+		if v := stats.Group.Stats[x.Master]; v != nil && v.Stats != nil {
+			x.UsedMemory = getServerInt64Field(v.Stats, "used_memory")
+			x.MaxMemory = getServerInt64Field(v.Stats, "maxmemory")
+			if db0, ok := v.Stats["db0"]; ok {
+				x.Keys = getServerKeys(db0)
+			}
+		}
+		r.Capacity.Groups++
+		r.Capacity.UsedMemory += x.UsedMemory
+		r.Capacity.MaxMemory += x.MaxMemory
+		r.Capacity.Keys += x.Keys
+		r.Capacity.Group = append(r.Capacity.Group, x)
+	}
+}
+
+//汇总所有proxy上各命令的调用次数
+func aggregateProxyCommands(stats *Stats) map[string]*ReportCommand {
+	var m = make(map[string]*ReportCommand)
+	for _, v := range stats.Proxy.Stats {
+		if v == nil || v.Stats == nil {
+			continue
+		}
+		for _, c := range v.Stats.Ops.Cmd {
+			if c.OpStr == "ALL" {
+				continue
+			}
+			x := m[c.OpStr]
+			if x == nil {
+				x = &ReportCommand{OpStr: c.OpStr}
+				m[c.OpStr] = x
+			}
+			x.Calls += c.TotalCalls
+			x.Fails += c.Fails
+		}
+	}
+	return m
+}
+
+func reportTopCommands(r *Report, stats *Stats) {
+	var m = aggregateProxyCommands(stats)
+	var list = make([]*ReportCommand, 0, len(m))
+	for _, x := range m {
+		list = append(list, x)
+	}
+	sort.Sort(sliceReportCommand(list))
+	if len(list) > reportMaxCommands {
+		list = list[:reportMaxCommands]
+	}
+	r.TopCommands = list
+}
+
+func reportMigrations(r *Report, histories []*models.SlotHistory) {
+	var elapsed float64
+	var recent []*ReportMigration
+	for _, h := range histories {
+		for _, t := range h.Transitions {
+			switch t.State {
+			case models.SlotTransitionDone:
+				r.Migration.Done++
+				elapsed += t.Elapsed
+				recent = append(recent, &ReportMigration{
+					Slot: h.Id, From: t.GroupId, To: t.TargetId,
+					Time: t.Time, Elapsed: t.Elapsed,
+				})
+			case models.SlotTransitionCancelled:
+				r.Migration.Cancelled++
+			}
+		}
+	}
+	if r.Migration.Done != 0 {
+		r.Migration.AvgSecs = elapsed / float64(r.Migration.Done)
+	}
+	sort.Sort(sliceReportMigration(recent))
+	if len(recent) > reportMaxMigrations {
+		recent = recent[:reportMaxMigrations]
+	}
+	r.Migration.Recent = recent
+}
+
+var reportTemplate = template.Must(template.New("report").Parse(`<html>
+<body>
+<h2>Codis {{.ProductName}} {{.Period}} report</h2>
+<p>{{.Begin}} ~ {{.End}}</p>
+<h3>Capacity</h3>
+<p>groups: {{.Capacity.Groups}}, keys: {{.Capacity.Keys}}, used_memory: {{.Capacity.UsedMemory}}, max_memory: {{.Capacity.MaxMemory}}</p>
+<table border="1">
+<tr><th>group</th><th>master</th><th>keys</th><th>used_memory</th><th>max_memory</th></tr>
+{{range .Capacity.Group}}<tr><td>{{.Id}}</td><td>{{.Master}}</td><td>{{.Keys}}</td><td>{{.UsedMemory}}</td><td>{{.MaxMemory}}</td></tr>
+{{end}}</table>
+<h3>Top commands</h3>
+<table border="1">
+<tr><th>command</th><th>calls</th><th>fails</th></tr>
+{{range .TopCommands}}<tr><td>{{.OpStr}}</td><td>{{.Calls}}</td><td>{{.Fails}}</td></tr>
+{{end}}</table>
+<h3>Slow requests</h3>
+<table border="1">
+<tr><th>time</th><th>calls</th><th>slow</th></tr>
+{{range .SlowTrend}}<tr><td>{{.Time}}</td><td>{{.Calls}}</td><td>{{.Slow}}</td></tr>
+{{end}}</table>
+<h3>Migration</h3>
+<p>done: {{.Migration.Done}}, cancelled: {{.Migration.Cancelled}}, avg_secs: {{printf "%.1f" .Migration.AvgSecs}}</p>
+<table border="1">
+<tr><th>slot</th><th>from</th><th>to</th><th>time</th><th>elapsed</th></tr>
+{{range .Migration.Recent}}<tr><td>{{.Slot}}</td><td>{{.From}}</td><td>{{.To}}</td><td>{{.Time}}</td><td>{{printf "%.1f" .Elapsed}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (r *Report) HTML() ([]byte, error) {
+	var b bytes.Buffer
+	if err := reportTemplate.Execute(&b, r); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return b.Bytes(), nil
+}
+
+//webhook和邮件都配置时都会发送，任一失败时返回错误
+func (s *Topom) deliverReport(r *Report) error {
+	var errs []string
+	if s.config.ReportWebhookUrl != "" {
+		if err := s.postReportWebhook(r); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if s.config.ReportSmtpAddr != "" {
+		if err := s.sendReportMail(r); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) != 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (s *Topom) postReportWebhook(r *Report) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	client := &http.Client{Timeout: time.Second * 10}
+	resp, err := client.Post(s.config.ReportWebhookUrl, "application/json", bytes.NewReader(b))
+	if err != nil {
+		return errors.Errorf("post report to webhook failed: %s", err)
+	}
+	defer resp.Body.Close()
+	ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return errors.Errorf("post report to webhook failed: %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *Topom) sendReportMail(r *Report) error {
+	html, err := r.HTML()
+	if err != nil {
+		return err
+	}
+	var to []string
+	for _, x := range strings.Split(s.config.ReportSmtpTo, ",") {
+		if x = strings.TrimSpace(x); x != "" {
+			to = append(to, x)
+		}
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", s.config.ReportSmtpFrom)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: Codis %s %s report %s\r\n", r.ProductName, r.Period, r.End)
+	fmt.Fprintf(&b, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: text/html; charset=utf-8\r\n\r\n")
+	b.Write(html)
+
+	var auth smtp.Auth
+	if s.config.ReportSmtpUsername != "" {
+		host, _, err := net.SplitHostPort(s.config.ReportSmtpAddr)
+		if err != nil {
+			return errors.Trace(err)
+		}
+		auth = smtp.PlainAuth("", s.config.ReportSmtpUsername, s.config.ReportSmtpPassword, host)
+	}
+	if err := smtp.SendMail(s.config.ReportSmtpAddr, auth, s.config.ReportSmtpFrom, to, b.Bytes()); err != nil {
+		return errors.Errorf("send report mail failed: %s", err)
+	}
+	return nil
+}
+
+type sliceReportCommand []*ReportCommand
+
+func (s sliceReportCommand) Len() int {
+	return len(s)
+}
+
+func (s sliceReportCommand) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceReportCommand) Less(i, j int) bool {
+	if s[i].Calls != s[j].Calls {
+		return s[i].Calls > s[j].Calls
+	}
+	return s[i].OpStr < s[j].OpStr
+}
+
+type sliceReportMigration []*ReportMigration
+
+func (s sliceReportMigration) Len() int {
+	return len(s)
+}
+
+func (s sliceReportMigration) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceReportMigration) Less(i, j int) bool {
+	if s[i].Time != s[j].Time {
+		return s[i].Time > s[j].Time
+	}
+	return s[i].Slot < s[j].Slot
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestLastReportSchedule(x *testing.T) {
+	//2018-03-05是周一
+	at := func(day, hour int) time.Time {
+		return time.Date(2018, 3, day, hour, 30, 0, 0, time.Local)
+	}
+	sched := func(day, hour int) time.Time {
+		return time.Date(2018, 3, day, hour, 0, 0, 0, time.Local)
+	}
+	assert.Must(lastReportSchedule(ReportDaily, 8, at(7, 9)).Equal(sched(7, 8)))
+	assert.Must(lastReportSchedule(ReportDaily, 8, at(7, 7)).Equal(sched(6, 8)))
+	assert.Must(lastReportSchedule(ReportWeekly, 8, at(7, 9)).Equal(sched(5, 8)))
+	assert.Must(lastReportSchedule(ReportWeekly, 8, at(5, 9)).Equal(sched(5, 8)))
+	assert.Must(lastReportSchedule(ReportWeekly, 8, at(5, 7)).Equal(time.Date(2018, 2, 26, 8, 0, 0, 0, time.Local)))
+	assert.Must(lastReportSchedule(ReportWeekly, 8, at(11, 9)).Equal(sched(5, 8)))
+}
+
+func TestReportMigrations(x *testing.T) {
+	r := &Report{}
+	reportMigrations(r, []*models.SlotHistory{
+		{Id: 1, Transitions: []*models.SlotTransition{
+			{Time: 100, State: models.ActionPending, GroupId: 1, TargetId: 2},
+			{Time: 110, State: models.SlotTransitionDone, GroupId: 2, Elapsed: 10},
+		}},
+		{Id: 2, Transitions: []*models.SlotTransition{
+			{Time: 120, State: models.SlotTransitionDone, GroupId: 2, Elapsed: 20},
+			{Time: 130, State: models.SlotTransitionCancelled, GroupId: 2},
+		}},
+	})
+	assert.Must(r.Migration.Done == 2)
+	assert.Must(r.Migration.Cancelled == 1)
+	assert.Must(r.Migration.AvgSecs == 15)
+	assert.Must(len(r.Migration.Recent) == 2)
+	assert.Must(r.Migration.Recent[0].Slot == 2)
+}
+
+func TestReportHTML(x *testing.T) {
+	r := &Report{ProductName: "codis-demo", Period: ReportDaily}
+	r.TopCommands = []*ReportCommand{{OpStr: "GET", Calls: 10}}
+	b, err := r.HTML()
+	assert.MustNoError(err)
+	assert.Must(len(b) != 0)
+}
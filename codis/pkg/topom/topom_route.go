@@ -0,0 +1,189 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+	"github.com/CodisLabs/codis/pkg/utils/sync2"
+)
+
+func (s *Topom) prefixRoutes() *models.PrefixRoutes {
+	if s.routes == nil {
+		return &models.PrefixRoutes{}
+	}
+	return s.routes
+}
+
+func (s *Topom) PrefixRoutes() []*models.PrefixRoute {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list = []*models.PrefixRoute{}
+	for _, r := range s.prefixRoutes().Routes {
+		x := *r
+		list = append(list, &x)
+	}
+	return list
+}
+
+//新增或修改一个前缀的路由，通过fillslots下发给所有proxy
+//已有的key不会被迁移，添加路由之前需要先把该前缀的key导入到目标group，否则之后访问不到
+//slot迁移使用SLOTSMGRTTAGSLOT会把路由的key一起迁走，所以有迁移从目标group迁出时不能添加路由
+func (s *Topom) UpdatePrefixRoute(r *models.PrefixRoute) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if r.Prefix == "" {
+		return errors.Errorf("invalid route prefix")
+	}
+	g, err := ctx.getGroup(r.GroupId)
+	if err != nil {
+		return err
+	}
+	if len(g.Servers) == 0 {
+		return errors.Errorf("group-[%d] is empty", g.Id)
+	}
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing && m.GroupId == g.Id {
+			return errors.Errorf("slot-[%d] is migrating from group-[%d]", m.Id, g.Id)
+		}
+	}
+
+	var p = &models.PrefixRoutes{}
+	for _, x := range s.prefixRoutes().Routes {
+		if x.Prefix != r.Prefix {
+			p.Routes = append(p.Routes, x)
+		}
+	}
+	p.Routes = append(p.Routes, &models.PrefixRoute{
+		Prefix: r.Prefix, GroupId: r.GroupId,
+	})
+	sort.Sort(prefixRouteSorter(p.Routes))
+
+	if err := s.storeUpdatePrefixRoutes(p); err != nil {
+		return err
+	}
+	s.routes = p
+	return s.resyncPrefixRoutes(ctx, r.GroupId)
+}
+
+//proxy收到group id为0的路由时删除该前缀，之后的key重新按slot哈希
+func (s *Topom) RemovePrefixRoute(prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	var p = &models.PrefixRoutes{}
+	for _, x := range s.prefixRoutes().Routes {
+		if x.Prefix != prefix {
+			p.Routes = append(p.Routes, x)
+		}
+	}
+	if len(p.Routes) == len(s.prefixRoutes().Routes) {
+		return errors.Errorf("route of prefix-[%s] doesn't exist", prefix)
+	}
+
+	if err := s.storeUpdatePrefixRoutes(p); err != nil {
+		return err
+	}
+	s.routes = p
+	return s.fillRouteSlots(ctx, func(p *models.Proxy) []*models.Slot {
+		return []*models.Slot{{Prefix: prefix, ForwardMethod: ctx.method}}
+	})
+}
+
+//返回group上的一个路由前缀，没有路由时返回空
+func (s *Topom) groupRoutePrefix(gid int) string {
+	for _, r := range s.prefixRoutes().Routes {
+		if r.GroupId == gid {
+			return r.Prefix
+		}
+	}
+	return ""
+}
+
+//group上有路由时不能迁出slot，SLOTSMGRTTAGSLOT会把路由的key一起迁走
+func (s *Topom) checkRouteMigration(gid int) error {
+	if prefix := s.groupRoutePrefix(gid); prefix != "" {
+		return errors.Errorf("group-[%d] serves route of prefix-[%s], slots can't be migrated from it", gid, prefix)
+	}
+	return nil
+}
+
+//gid为0时返回所有路由
+func (s *Topom) toRouteSlots(ctx *context, p *models.Proxy, gid int) []*models.Slot {
+	var slots []*models.Slot
+	for _, r := range s.prefixRoutes().Routes {
+		if gid != 0 && r.GroupId != gid {
+			continue
+		}
+		slots = append(slots, &models.Slot{
+			Prefix:             r.Prefix,
+			BackendAddr:        ctx.getGroupMaster(r.GroupId),
+			BackendAddrGroupId: r.GroupId,
+			ReplicaGroups:      ctx.toReplicaGroups(r.GroupId, p),
+			ForwardMethod:      ctx.method,
+		})
+	}
+	return slots
+}
+
+//重新下发指向gid的路由，gid为0时下发所有路由
+func (s *Topom) resyncPrefixRoutes(ctx *context, gid int) error {
+	return s.fillRouteSlots(ctx, func(p *models.Proxy) []*models.Slot {
+		return s.toRouteSlots(ctx, p, gid)
+	})
+}
+
+func (s *Topom) fillRouteSlots(ctx *context, build func(p *models.Proxy) []*models.Slot) error {
+	var fut sync2.Future
+	for _, p := range ctx.proxy {
+		slots := build(p)
+		if len(slots) == 0 {
+			continue
+		}
+		fut.Add()
+		go func(p *models.Proxy) {
+			err := s.newProxyClient(p).FillSlots(slots...)
+			if err != nil {
+				log.ErrorErrorf(err, "proxy-[%s] resync routes failed", p.Token)
+			}
+			fut.Done(p.Token, err)
+		}(p)
+	}
+	for t, v := range fut.Wait() {
+		switch err := v.(type) {
+		case error:
+			if err != nil {
+				return errors.Errorf("proxy-[%s] resync routes failed", t)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Topom) storeUpdatePrefixRoutes(p *models.PrefixRoutes) error {
+	log.Warnf("update prefix routes:\n%s", p.Encode())
+	if err := s.store.UpdatePrefixRoutes(p); err != nil {
+		log.ErrorErrorf(err, "store: update prefix routes failed")
+		return errors.Errorf("store: update prefix routes failed")
+	}
+	return nil
+}
+
+type prefixRouteSorter []*models.PrefixRoute
+
+func (s prefixRouteSorter) Len() int           { return len(s) }
+func (s prefixRouteSorter) Less(i, j int) bool { return s[i].Prefix < s[j].Prefix }
+func (s prefixRouteSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,74 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestPrefixRoute(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{
+		{Addr: "127.0.0.1:16380"},
+	}})
+
+	p, c := openProxy()
+	defer c.Shutdown()
+	assert.MustNoError(t.CreateProxy(p.AdminAddr))
+
+	assert.Must(len(t.PrefixRoutes()) == 0)
+	assert.Must(t.UpdatePrefixRoute(&models.PrefixRoute{GroupId: 2}) != nil)
+	assert.Must(t.UpdatePrefixRoute(&models.PrefixRoute{Prefix: "a:", GroupId: 1}) != nil)
+	assert.Must(t.UpdatePrefixRoute(&models.PrefixRoute{Prefix: "a:", GroupId: 3}) != nil)
+
+	assert.MustNoError(t.UpdatePrefixRoute(&models.PrefixRoute{Prefix: "bigdata:", GroupId: 2}))
+	assert.MustNoError(t.UpdatePrefixRoute(&models.PrefixRoute{Prefix: "a:", GroupId: 2}))
+	list := t.PrefixRoutes()
+	assert.Must(len(list) == 2 && list[0].Prefix == "a:" && list[1].Prefix == "bigdata:")
+
+	routes, err := c.Routes()
+	assert.MustNoError(err)
+	assert.Must(len(routes) == 2 && routes[0].Prefix == "bigdata:")
+	assert.Must(routes[0].BackendAddr == "127.0.0.1:16380" && routes[0].BackendAddrGroupId == 2)
+
+	//有slot从group迁出时不能添加路由
+	contextCreateGroup(t, &models.Group{Id: 3, Servers: []*models.GroupServer{
+		{Addr: "127.0.0.1:16381"},
+	}})
+	var m = &models.SlotMapping{Id: 1, GroupId: 3}
+	m.Action.Index, m.Action.State, m.Action.TargetId = 1, models.ActionPending, 2
+	contextUpdateSlotMapping(t, m)
+	assert.Must(t.UpdatePrefixRoute(&models.PrefixRoute{Prefix: "c:", GroupId: 3}) != nil)
+
+	//路由所在的group不能迁出slot
+	contextUpdateSlotMapping(t, &models.SlotMapping{Id: 0, GroupId: 2})
+	assert.Must(t.SlotCreateAction(0, 3) != nil)
+	assert.Must(t.SlotCreateActionRange(0, 0, 3, true) != nil)
+	assert.Must(t.SlotCreateActionSome(2, 3, 1) != nil)
+	m.GroupId, m.Action.TargetId = 2, 3
+	contextUpdateSlotMapping(t, m)
+	_, _, err = t.SlotActionPrepare()
+	assert.Must(err != nil)
+	contextUpdateSlotMapping(t, &models.SlotMapping{Id: 1, GroupId: 3})
+
+	//被路由使用的group不能删除
+	contextUpdateGroup(t, &models.Group{Id: 2})
+	assert.Must(t.RemoveGroup(2) != nil)
+
+	assert.MustNoError(t.RemovePrefixRoute("a:"))
+	assert.Must(t.RemovePrefixRoute("a:") != nil)
+	routes, err = c.Routes()
+	assert.MustNoError(err)
+	assert.Must(len(routes) == 1 && routes[0].Prefix == "bigdata:")
+
+	r, err := t.store.LoadPrefixRoutes(true)
+	assert.MustNoError(err)
+	assert.Must(len(r.Routes) == 1)
+}
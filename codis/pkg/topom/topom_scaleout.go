@@ -0,0 +1,249 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//扩容请求，Servers中第一个server作为新group的master，MaxSlots限制本次最多迁移的slot数量，0表示不限制
+type ScaleOutRequest struct {
+	GroupId    int      `json:"group_id"`
+	Servers    []string `json:"servers"`
+	DataCenter string   `json:"datacenter,omitempty"`
+	MaxSlots   int      `json:"max_slots,omitempty"`
+}
+
+type ScaleOutDetail struct {
+	GroupId  int      `json:"group_id"`
+	Servers  []string `json:"servers"`
+	Slots    []int    `json:"slots"`
+	Migrated int      `json:"migrated"`
+}
+
+const (
+	JobTypeScaleOut = "scale-out"
+
+	ScaleOutStepMigrating = "migrating"
+)
+
+//创建group、添加server并创建迁移到新group的slot任务，整个过程持有topom锁，不会与其他操作交错
+func (s *Topom) ScaleOut(req *ScaleOutRequest) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return 0, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return 0, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, err
+	}
+
+	gid := req.GroupId
+	if gid <= 0 || gid > models.MaxGroupId {
+		return 0, errors.Errorf("invalid group id = %d, out of range", gid)
+	}
+	if ctx.group[gid] != nil {
+		return 0, errors.Errorf("group-[%d] already exists", gid)
+	}
+	if len(req.Servers) == 0 {
+		return 0, errors.Errorf("no server for group-[%d]", gid)
+	}
+	if req.MaxSlots < 0 {
+		return 0, errors.Errorf("invalid max slots = %d", req.MaxSlots)
+	}
+
+	var servers = make(map[string]bool)
+	for _, g := range ctx.group {
+		for _, x := range g.Servers {
+			servers[x.Addr] = true
+		}
+	}
+	for i, addr := range req.Servers {
+		if addr == "" {
+			return 0, errors.Errorf("invalid server address")
+		}
+		if servers[addr] {
+			return 0, errors.Errorf("server-[%s] already exists", addr)
+		}
+		servers[addr] = true
+		if err := s.verifyScaleOutServer(addr, i == 0); err != nil {
+			return 0, err
+		}
+	}
+
+	slots := planScaleOutSlots(ctx, req.MaxSlots)
+	if len(slots) == 0 {
+		return 0, errors.Errorf("no slot could be moved to group-[%d]", gid)
+	}
+
+	defer s.dirtyGroupCache(gid)
+
+	g := &models.Group{Id: gid}
+	for i, addr := range req.Servers {
+		x := &models.GroupServer{Addr: addr, DataCenter: req.DataCenter}
+		if i != 0 {
+			x.Action.Index = ctx.maxSyncActionIndex() + i
+			x.Action.State = models.ActionPending
+		}
+		g.Servers = append(g.Servers, x)
+	}
+	if err := s.storeCreateGroup(g); err != nil {
+		return 0, err
+	}
+
+	for _, sid := range slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return 0, err
+		}
+		defer s.dirtySlotsCache(m.Id)
+
+		m.Action.State = models.ActionPending
+		m.Action.Index = ctx.maxSlotActionIndex() + 1
+		m.Action.TargetId = gid
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return 0, err
+		}
+	}
+
+	detail := &ScaleOutDetail{GroupId: gid, Servers: req.Servers, Slots: slots}
+	j := s.newJob(JobTypeScaleOut, detail)
+	j.onCancel(func() error {
+		_, err := s.removePendingSlotActions(slots)
+		return err
+	})
+	j.update(ScaleOutStepMigrating, 0)
+	log.Warnf("scale-out: job-[%d] group-[%d] created, migrate %d slots", j.Id, gid, len(slots))
+
+	go s.trackScaleOutJob(j, detail)
+	return j.Id, nil
+}
+
+func (s *Topom) verifyScaleOutServer(addr string, master bool) error {
+	c, err := s.action.redisp.GetClient(addr)
+	if err != nil {
+		return errors.Errorf("server-[%s] is unreachable: %s", addr, err)
+	}
+	defer s.action.redisp.PutClient(c, err)
+	if master {
+		role, err := c.Role()
+		if err != nil {
+			return errors.Errorf("server-[%s] role failed: %s", addr, err)
+		}
+		if role != "MASTER" {
+			return errors.Errorf("server-[%s] is not master, role = %s", addr, role)
+		}
+	}
+	return nil
+}
+
+//从slot最多的group中依次取出slot，直到新group达到平均值或达到maxSlots
+func planScaleOutSlots(ctx *context, maxSlots int) []int {
+	var pendings = make(map[int][]int)
+	var groupIds []int
+	for _, g := range ctx.group {
+		if len(g.Servers) != 0 {
+			groupIds = append(groupIds, g.Id)
+		}
+	}
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing || m.GroupId == 0 {
+			continue
+		}
+		pendings[m.GroupId] = append(pendings[m.GroupId], m.Id)
+	}
+	sort.Ints(groupIds)
+
+	var target = MaxSlotNum / (len(groupIds) + 1)
+	if maxSlots != 0 && target > maxSlots {
+		target = maxSlots
+	}
+
+	var slots []int
+	for len(slots) < target {
+		var from = -1
+		for _, x := range groupIds {
+			if from == -1 || len(pendings[x]) > len(pendings[from]) {
+				from = x
+			}
+		}
+		if from == -1 || len(pendings[from]) <= len(slots)+1 {
+			break
+		}
+		n := len(pendings[from])
+		slots = append(slots, pendings[from][n-1])
+		pendings[from] = pendings[from][:n-1]
+	}
+	sort.Ints(slots)
+	return slots
+}
+
+func (s *Topom) trackScaleOutJob(j *Job, detail *ScaleOutDetail) {
+	var ticker = time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !j.done() {
+		select {
+		case <-s.exit.C:
+			return
+		case <-ticker.C:
+		}
+
+		migrated, failed, err := s.countMigratedSlots(detail.GroupId, detail.Slots)
+		if err != nil {
+			if err == ErrClosedTopom {
+				return
+			}
+			log.WarnErrorf(err, "scale-out: job-[%d] check slots failed", j.Id)
+			continue
+		}
+		if failed != 0 {
+			j.finish(errors.Errorf("%d slots are not migrated to group-[%d]", failed, detail.GroupId))
+			return
+		}
+		j.updateDetail(func() {
+			detail.Migrated = migrated
+		})
+		if migrated == len(detail.Slots) {
+			j.finish(nil)
+			log.Warnf("scale-out: job-[%d] group-[%d] done", j.Id, detail.GroupId)
+			return
+		}
+		j.update(ScaleOutStepMigrating, migrated*100/len(detail.Slots))
+	}
+}
+
+//返回已完成迁移到gid的slot数量，以及迁移被取消的slot数量
+func (s *Topom) countMigratedSlots(gid int, slots []int) (migrated, failed int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, sid := range slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return 0, 0, err
+		}
+		switch {
+		case m.Action.State != models.ActionNothing:
+		case m.GroupId == gid:
+			migrated++
+		default:
+			failed++
+		}
+	}
+	return migrated, failed, nil
+}
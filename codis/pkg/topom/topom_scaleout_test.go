@@ -0,0 +1,35 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestPlanScaleOutSlots(x *testing.T) {
+	ctx := &context{group: make(map[int]*models.Group)}
+	for gid := 1; gid <= 2; gid++ {
+		ctx.group[gid] = &models.Group{Id: gid, Servers: []*models.GroupServer{
+			&models.GroupServer{Addr: "server"},
+		}}
+	}
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		ctx.slots = append(ctx.slots, &models.SlotMapping{Id: sid, GroupId: sid%2 + 1})
+	}
+
+	slots := planScaleOutSlots(ctx, 0)
+	assert.Must(len(slots) == MaxSlotNum/3)
+
+	var moveout = make(map[int]int)
+	for _, sid := range slots {
+		moveout[ctx.slots[sid].GroupId]++
+	}
+	assert.Must(moveout[1]-moveout[2] <= 1 && moveout[2]-moveout[1] <= 1)
+
+	slots = planScaleOutSlots(ctx, 10)
+	assert.Must(len(slots) == 10)
+}
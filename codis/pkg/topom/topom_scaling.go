@@ -0,0 +1,578 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//扩容时Servers中第一个server作为新group的master，其余server作为slave，MaxSlots为0时迁移到平均值
+type ScalingRequest struct {
+	Type       string   `json:"type"`
+	GroupId    int      `json:"group_id"`
+	Servers    []string `json:"servers,omitempty"`
+	DataCenter string   `json:"datacenter,omitempty"`
+	MaxSlots   int      `json:"max_slots,omitempty"`
+}
+
+var errScalingStopped = errors.New("scaling is not running")
+
+//扩容: 创建group -> 添加slave -> 等待同步 -> 计算迁移计划 -> 迁移slot -> 校验 -> 同步sentinel
+//缩容: 计算迁移计划 -> 迁移slot -> 校验group中没有key -> 从sentinel中移除并删除group
+//同一时间只能有一个扩缩容流程
+func (s *Topom) StartScaling(req *ScalingRequest) (*models.ScalingWorkflow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return nil, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return nil, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+	if w := s.scaling; w != nil && w.State == models.ScalingRunning {
+		return nil, errors.Errorf("scaling-[%d] of group-[%d] is running", w.Id, w.GroupId)
+	}
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	gid := req.GroupId
+	if gid <= 0 || gid > models.MaxGroupId {
+		return nil, errors.Errorf("invalid group id = %d, out of range", gid)
+	}
+	if req.MaxSlots < 0 {
+		return nil, errors.Errorf("invalid max slots = %d", req.MaxSlots)
+	}
+
+	w := &models.ScalingWorkflow{
+		Type: req.Type, State: models.ScalingRunning, GroupId: gid,
+		DataCenter: req.DataCenter, MaxSlots: req.MaxSlots,
+	}
+	switch req.Type {
+	case models.ScalingAddGroup:
+		if err := s.verifyScalingServers(ctx, req); err != nil {
+			return nil, err
+		}
+		w.Servers = req.Servers
+		w.Step = models.ScalingStepCreate
+	case models.ScalingRemoveGroup:
+		g, err := ctx.getGroup(gid)
+		if err != nil {
+			return nil, err
+		}
+		if g.Promoting.State != models.ActionNothing {
+			return nil, errors.Errorf("group-[%d] is promoting", g.Id)
+		}
+		if id, ok := s.decommissions[gid]; ok {
+			return nil, errors.Errorf("group-[%d] is being decommissioned by job-[%d]", g.Id, id)
+		}
+		if _, err := planDecommissionSlots(ctx, gid); err != nil {
+			return nil, err
+		}
+		w.Step = models.ScalingStepPlan
+	default:
+		return nil, errors.Errorf("invalid scaling type %s", req.Type)
+	}
+
+	if s.scaling != nil {
+		w.Id = s.scaling.Id + 1
+	} else {
+		w.Id = 1
+	}
+	w.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	w.UpdateTime = w.CreateTime
+	if err := s.storeUpdateScaling(w); err != nil {
+		return nil, err
+	}
+	s.scaling = w
+	log.Warnf("scaling-[%d]: %s group-[%d] started", w.Id, w.Type, gid)
+
+	go s.runScaling(w.Id)
+	return cloneScaling(w), nil
+}
+
+func (s *Topom) verifyScalingServers(ctx *context, req *ScalingRequest) error {
+	if ctx.group[req.GroupId] != nil {
+		return errors.Errorf("group-[%d] already exists", req.GroupId)
+	}
+	if len(req.Servers) == 0 {
+		return errors.Errorf("no server for group-[%d]", req.GroupId)
+	}
+	var servers = make(map[string]bool)
+	for _, g := range ctx.group {
+		for _, x := range g.Servers {
+			servers[x.Addr] = true
+		}
+	}
+	for i, addr := range req.Servers {
+		if addr == "" {
+			return errors.Errorf("invalid server address")
+		}
+		if servers[addr] {
+			return errors.Errorf("server-[%s] already exists", addr)
+		}
+		servers[addr] = true
+		if err := s.verifyScaleOutServer(addr, i == 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Topom) Scaling() *models.ScalingWorkflow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scaling == nil {
+		return nil
+	}
+	return cloneScaling(s.scaling)
+}
+
+//停止流程并移除尚未开始的迁移，已经创建的group和正在迁移的slot不会回滚
+func (s *Topom) AbortScaling() error {
+	s.mu.Lock()
+	w := s.scaling
+	if w == nil || w.State != models.ScalingRunning {
+		s.mu.Unlock()
+		return errors.Errorf("no running scaling")
+	}
+	w = cloneScaling(w)
+	w.State = models.ScalingAborted
+	w.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	if err := s.storeUpdateScaling(w); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.scaling = w
+	s.mu.Unlock()
+
+	var slots []int
+	for sid := range w.Plans {
+		slots = append(slots, sid)
+	}
+	sort.Ints(slots)
+	n, err := s.removePendingSlotActions(slots)
+	if err != nil {
+		return err
+	}
+	log.Warnf("scaling-[%d]: aborted at step %s, %d pending slot actions removed", w.Id, w.Step, n)
+	return nil
+}
+
+//从失败的步骤重新执行，同步失败的slave会重新同步
+func (s *Topom) ResumeScaling() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
+	w := s.scaling
+	if w == nil || w.State != models.ScalingFailed {
+		return errors.Errorf("no failed scaling")
+	}
+	w = cloneScaling(w)
+	w.State, w.Error = models.ScalingRunning, ""
+	if w.Step == models.ScalingStepSync {
+		w.Step = models.ScalingStepAddServer
+	}
+	w.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	if err := s.storeUpdateScaling(w); err != nil {
+		return err
+	}
+	s.scaling = w
+	log.Warnf("scaling-[%d]: resumed at step %s", w.Id, w.Step)
+
+	go s.runScaling(w.Id)
+	return nil
+}
+
+//执行完一步后立即执行下一步，需要等待的步骤每秒检查一次
+func (s *Topom) runScaling(id int) {
+	var ticker = time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for !s.IsClosed() {
+		w := s.runningScaling(id)
+		if w == nil {
+			return
+		}
+		step, migrated := w.Step, w.Migrated
+
+		if err := s.execScalingStep(w); err != nil {
+			if err == ErrClosedTopom {
+				return
+			}
+			log.ErrorErrorf(err, "scaling-[%d]: step %s failed", id, step)
+			w.State, w.Error = models.ScalingFailed, err.Error()
+		}
+		if w.State != models.ScalingRunning || w.Step != step || w.Migrated != migrated {
+			if err := s.updateScaling(w); err != nil {
+				if err != errScalingStopped {
+					log.WarnErrorf(err, "scaling-[%d]: update failed", id)
+				}
+				return
+			}
+			if w.State != models.ScalingRunning {
+				log.Warnf("scaling-[%d]: %s group-[%d] %s", id, w.Type, w.GroupId, w.State)
+				return
+			}
+			if w.Step != step {
+				log.Warnf("scaling-[%d]: step %s done, next step %s", id, step, w.Step)
+				continue
+			}
+		}
+
+		select {
+		case <-s.exit.C:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Topom) execScalingStep(w *models.ScalingWorkflow) error {
+	switch w.Step {
+	case models.ScalingStepCreate:
+		if err := s.scalingCreateGroup(w); err != nil {
+			return err
+		}
+		w.Step = models.ScalingStepAddServer
+	case models.ScalingStepAddServer:
+		if err := s.scalingAddServers(w); err != nil {
+			return err
+		}
+		w.Step = models.ScalingStepSync
+	case models.ScalingStepSync:
+		synced, err := s.scalingSynced(w)
+		if err != nil || !synced {
+			return err
+		}
+		w.Step = models.ScalingStepPlan
+	case models.ScalingStepPlan:
+		plans, err := s.scalingPlan(w)
+		if err != nil {
+			return err
+		}
+		w.Plans, w.Migrated = plans, 0
+		w.Step = models.ScalingStepMigrate
+	case models.ScalingStepMigrate:
+		migrated, err := s.scalingMigrate(w.Plans)
+		if err != nil {
+			return err
+		}
+		w.Migrated = migrated
+		if migrated == len(w.Plans) {
+			w.Step = models.ScalingStepVerify
+		}
+	case models.ScalingStepVerify:
+		if err := s.scalingVerify(w); err != nil {
+			return err
+		}
+		w.Step = models.ScalingStepFinalize
+	case models.ScalingStepFinalize:
+		if err := s.scalingFinalize(w); err != nil {
+			return err
+		}
+		w.State = models.ScalingFinished
+	default:
+		return errors.Errorf("invalid scaling step %s", w.Step)
+	}
+	return nil
+}
+
+//重启前可能已经创建了group，master相同时直接进入下一步
+func (s *Topom) scalingCreateGroup(w *models.ScalingWorkflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	var master = w.Servers[0]
+	if g := ctx.group[w.GroupId]; g != nil {
+		if len(g.Servers) == 0 || g.Servers[0].Addr != master {
+			return errors.Errorf("group-[%d] already exists", w.GroupId)
+		}
+		return nil
+	}
+	if g, _, err := ctx.getGroupByServer(master); err == nil {
+		return errors.Errorf("server-[%s] already exists in group-[%d]", master, g.Id)
+	}
+	defer s.dirtyGroupCache(w.GroupId)
+
+	g := &models.Group{Id: w.GroupId, Servers: []*models.GroupServer{
+		&models.GroupServer{Addr: master, DataCenter: w.DataCenter},
+	}}
+	return s.storeCreateGroup(g)
+}
+
+//添加slave并创建同步任务，已经添加且没有同步失败的slave不会重复同步
+func (s *Topom) scalingAddServers(w *models.ScalingWorkflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	g, err := ctx.getGroup(w.GroupId)
+	if err != nil {
+		return err
+	}
+	if g.Promoting.State != models.ActionNothing {
+		return errors.Errorf("group-[%d] is promoting", g.Id)
+	}
+
+	var index = ctx.maxSyncActionIndex()
+	var dirty bool
+	for _, addr := range w.Servers[1:] {
+		x, i, err := ctx.getGroupByServer(addr)
+		switch {
+		case err != nil:
+			g.Servers = append(g.Servers, &models.GroupServer{Addr: addr, DataCenter: w.DataCenter})
+			i = len(g.Servers) - 1
+		case x.Id != g.Id:
+			return errors.Errorf("server-[%s] already exists in group-[%d]", addr, x.Id)
+		case g.Servers[i].Action.State != "synced_failed":
+			continue
+		}
+		index++
+		g.Servers[i].Action.Index = index
+		g.Servers[i].Action.State = models.ActionPending
+		dirty = true
+	}
+	if !dirty {
+		return nil
+	}
+
+	if p := ctx.sentinel; len(p.Servers) != 0 {
+		defer s.dirtySentinelCache()
+		p.OutOfSync = true
+		if err := s.storeUpdateSentinel(p); err != nil {
+			return err
+		}
+	}
+	defer s.dirtyGroupCache(g.Id)
+	return s.storeUpdateGroup(g)
+}
+
+func (s *Topom) scalingSynced(w *models.ScalingWorkflow) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return false, err
+	}
+
+	g, err := ctx.getGroup(w.GroupId)
+	if err != nil {
+		return false, err
+	}
+	var synced = true
+	for _, addr := range w.Servers[1:] {
+		i, err := ctx.getGroupIndex(g, addr)
+		if err != nil {
+			return false, err
+		}
+		switch g.Servers[i].Action.State {
+		case models.ActionPending, models.ActionSyncing:
+			synced = false
+		case "synced_failed":
+			return false, errors.Errorf("server-[%s] sync failed", addr)
+		}
+	}
+	return synced, nil
+}
+
+//扩容时新group不参与迁出，缩容时group的slot分配给slot最少的group
+func (s *Topom) scalingPlan(w *models.ScalingWorkflow) (map[int]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Type == models.ScalingRemoveGroup {
+		return planDecommissionSlots(ctx, w.GroupId)
+	}
+
+	var others = &context{group: make(map[int]*models.Group), slots: ctx.slots}
+	for gid, g := range ctx.group {
+		if gid != w.GroupId {
+			others.group[gid] = g
+		}
+	}
+	var plans = make(map[int]int)
+	for _, sid := range planScaleOutSlots(others, w.MaxSlots) {
+		plans[sid] = w.GroupId
+	}
+	if len(plans) == 0 {
+		return nil, errors.Errorf("no slot could be moved to group-[%d]", w.GroupId)
+	}
+	return plans, nil
+}
+
+//为尚未迁移的slot创建迁移任务，返回已经迁移到目标group的slot数量
+func (s *Topom) scalingMigrate(plans map[int]int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return 0, err
+	}
+
+	var slots []int
+	for sid := range plans {
+		slots = append(slots, sid)
+	}
+	sort.Ints(slots)
+
+	var migrated int
+	for _, sid := range slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return 0, err
+		}
+		gid := plans[sid]
+		switch {
+		case m.Action.State != models.ActionNothing:
+			if m.Action.TargetId != gid {
+				return 0, errors.Errorf("slot-[%d] is migrating to group-[%d]", sid, m.Action.TargetId)
+			}
+			continue
+		case m.GroupId == gid:
+			migrated++
+			continue
+		}
+		if g := ctx.group[gid]; g == nil || len(g.Servers) == 0 {
+			return 0, errors.Errorf("group-[%d] doesn't exist or is empty", gid)
+		}
+		defer s.dirtySlotsCache(m.Id)
+
+		m.Action.State = models.ActionPending
+		m.Action.Index = ctx.maxSlotActionIndex() + 1
+		m.Action.TargetId = gid
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return 0, err
+		}
+	}
+	return migrated, nil
+}
+
+func (s *Topom) scalingVerify(w *models.ScalingWorkflow) error {
+	migrated, failed, err := s.countPlannedSlots(w.Plans)
+	if err != nil {
+		return err
+	}
+	if failed != 0 || migrated != len(w.Plans) {
+		return errors.Errorf("%d slots are not migrated", len(w.Plans)-migrated)
+	}
+
+	for sid := range w.Plans {
+		if r := s.slotVerifyFailed(sid); r != nil {
+			return errors.Errorf("slot-[%d] verify failed, %d keys missing, %d keys mismatched",
+				sid, len(r.Missing), len(r.Mismatched))
+		}
+	}
+
+	if w.Type == models.ScalingRemoveGroup {
+		return s.verifyGroupDrained(w.GroupId)
+	}
+
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	master := ctx.getGroupMaster(w.GroupId)
+	s.mu.Unlock()
+
+	if master == "" {
+		return errors.Errorf("group-[%d] has no master", w.GroupId)
+	}
+	return s.verifyScaleOutServer(master, true)
+}
+
+//重启前可能已经删除了group
+func (s *Topom) scalingFinalize(w *models.ScalingWorkflow) error {
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var exists = ctx.group[w.GroupId] != nil
+	var sentinel = len(ctx.sentinel.Servers) != 0
+	s.mu.Unlock()
+
+	if w.Type == models.ScalingRemoveGroup {
+		if !exists {
+			return nil
+		}
+		return s.removeDecommissionedGroup(w.GroupId)
+	}
+	if sentinel {
+		return s.ResyncSentinels()
+	}
+	return nil
+}
+
+//返回运行中的流程的副本，流程已经结束或者被替换时返回nil
+func (s *Topom) runningScaling(id int) *models.ScalingWorkflow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if w := s.scaling; w != nil && w.Id == id && w.State == models.ScalingRunning {
+		return cloneScaling(w)
+	}
+	return nil
+}
+
+func (s *Topom) updateScaling(w *models.ScalingWorkflow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p := s.scaling; p == nil || p.Id != w.Id || p.State != models.ScalingRunning {
+		return errScalingStopped
+	}
+	w.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	if err := s.storeUpdateScaling(w); err != nil {
+		return err
+	}
+	s.scaling = w
+	return nil
+}
+
+func (s *Topom) storeUpdateScaling(w *models.ScalingWorkflow) error {
+	log.Warnf("update scaling-[%d]:\n%s", w.Id, w.Encode())
+	if err := s.store.UpdateScaling(w); err != nil {
+		log.ErrorErrorf(err, "store: update scaling-[%d] failed", w.Id)
+		return errors.Errorf("store: update scaling-[%d] failed", w.Id)
+	}
+	return nil
+}
+
+func cloneScaling(w *models.ScalingWorkflow) *models.ScalingWorkflow {
+	var p = *w
+	p.Servers = append([]string(nil), w.Servers...)
+	if w.Plans != nil {
+		p.Plans = make(map[int]int, len(w.Plans))
+		for sid, gid := range w.Plans {
+			p.Plans[sid] = gid
+		}
+	}
+	return &p
+}
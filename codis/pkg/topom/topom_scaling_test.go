@@ -0,0 +1,161 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func waitScaling(t *Topom, ok func(w *models.ScalingWorkflow) bool) *models.ScalingWorkflow {
+	for i := 0; i < 100; i++ {
+		if w := t.Scaling(); w != nil && ok(w) {
+			return w
+		}
+		time.Sleep(time.Millisecond * 100)
+	}
+	return t.Scaling()
+}
+
+func finishScalingSlots(t *Topom, plans map[int]int) {
+	for sid, gid := range plans {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: gid})
+	}
+}
+
+func TestScalingAddGroup(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+	s3 := newFakeServer()
+	defer s3.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{{Addr: s2.Addr}}})
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: sid%2 + 1})
+	}
+
+	assert.Must(t.Scaling() == nil)
+	for _, req := range []*ScalingRequest{
+		{Type: "resize", GroupId: 3, Servers: []string{s3.Addr}},
+		{Type: models.ScalingAddGroup, GroupId: 2, Servers: []string{s3.Addr}},
+		{Type: models.ScalingAddGroup, GroupId: 3, Servers: []string{s1.Addr}},
+		{Type: models.ScalingAddGroup, GroupId: 3},
+		{Type: models.ScalingRemoveGroup, GroupId: 3},
+	} {
+		_, err := t.StartScaling(req)
+		assert.Must(err != nil)
+	}
+
+	w, err := t.StartScaling(&ScalingRequest{Type: models.ScalingAddGroup, GroupId: 3, Servers: []string{s3.Addr}})
+	assert.MustNoError(err)
+	assert.Must(w.Id == 1 && w.State == models.ScalingRunning)
+	_, err = t.StartScaling(&ScalingRequest{Type: models.ScalingRemoveGroup, GroupId: 1})
+	assert.Must(err != nil)
+	_, err = t.GroupDecommission(3)
+	assert.Must(err != nil)
+
+	w = waitScaling(t, func(w *models.ScalingWorkflow) bool {
+		return w.Step == models.ScalingStepMigrate
+	})
+	assert.Must(w.Step == models.ScalingStepMigrate && len(w.Plans) == MaxSlotNum/3)
+
+	slots, err := t.store.SlotMappings()
+	assert.MustNoError(err)
+	var pending int
+	for _, m := range slots {
+		if m.Action.State == models.ActionPending {
+			assert.Must(m.Action.TargetId == 3 && w.Plans[m.Id] == 3)
+			pending++
+		}
+	}
+	assert.Must(pending == len(w.Plans))
+
+	finishScalingSlots(t, w.Plans)
+	w = waitScaling(t, func(w *models.ScalingWorkflow) bool {
+		return w.State != models.ScalingRunning
+	})
+	assert.Must(w.State == models.ScalingFinished && w.Migrated == len(w.Plans))
+
+	p, err := t.store.LoadScaling(true)
+	assert.MustNoError(err)
+	assert.Must(p.State == models.ScalingFinished)
+	g, err := t.store.LoadGroup(3, true)
+	assert.MustNoError(err)
+	assert.Must(len(g.Servers) == 1 && g.Servers[0].Addr == s3.Addr)
+}
+
+func TestScalingResume(x *testing.T) {
+	client := newDiskClient()
+	t, err := New(newForkClient(client), config)
+	assert.MustNoError(err)
+	assert.MustNoError(t.Start(false))
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{{Addr: s2.Addr}}})
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: sid%2 + 1})
+	}
+
+	//模拟dashboard在迁移过程中退出，重启后从迁移步骤继续
+	var plans = map[int]int{0: 2, 2: 2}
+	assert.MustNoError(t.store.UpdateScaling(&models.ScalingWorkflow{
+		Id: 1, Type: models.ScalingRemoveGroup, State: models.ScalingFailed,
+		Step: models.ScalingStepMigrate, GroupId: 1, Plans: plans,
+	}))
+	t.Close()
+
+	t, err = New(newForkClient(client), config)
+	assert.MustNoError(err)
+	defer t.Close()
+	assert.MustNoError(t.Start(false))
+	assert.Must(t.Scaling() != nil && t.Scaling().Step == models.ScalingStepMigrate)
+	assert.Must(t.AbortScaling() != nil)
+
+	assert.MustNoError(t.ResumeScaling())
+	assert.Must(t.ResumeScaling() != nil)
+	w := waitScaling(t, func(w *models.ScalingWorkflow) bool {
+		m, err := t.store.LoadSlotMapping(2, true)
+		return err == nil && m.Action.State == models.ActionPending
+	})
+	assert.Must(w.State == models.ScalingRunning && w.Step == models.ScalingStepMigrate)
+
+	//取消后移除尚未开始的迁移
+	assert.MustNoError(t.AbortScaling())
+	assert.Must(t.Scaling().State == models.ScalingAborted)
+	for sid := range plans {
+		m, err := t.store.LoadSlotMapping(sid, true)
+		assert.MustNoError(err)
+		assert.Must(m.Action.State == models.ActionNothing && m.GroupId == 1)
+	}
+
+	w, err = t.StartScaling(&ScalingRequest{Type: models.ScalingRemoveGroup, GroupId: 1})
+	assert.MustNoError(err)
+	assert.Must(w.Id == 2 && w.Step == models.ScalingStepPlan)
+	w = waitScaling(t, func(w *models.ScalingWorkflow) bool {
+		return w.Step == models.ScalingStepMigrate
+	})
+	assert.Must(len(w.Plans) == MaxSlotNum/2)
+	finishScalingSlots(t, w.Plans)
+	w = waitScaling(t, func(w *models.ScalingWorkflow) bool {
+		return w.State != models.ScalingRunning
+	})
+	assert.Must(w.State == models.ScalingFinished)
+	g, err := t.store.LoadGroup(1, false)
+	assert.MustNoError(err)
+	assert.Must(g == nil)
+}
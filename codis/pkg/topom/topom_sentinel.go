@@ -14,6 +14,12 @@ import (
 	"github.com/CodisLabs/codis/pkg/utils/sync2"
 )
 
+func (s *Topom) newSentinel() *redis.Sentinel {
+	sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+	sentinel.Options = s.ha.options
+	return sentinel
+}
+
 func (s *Topom) AddSentinel(addr string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -33,7 +39,7 @@ func (s *Topom) AddSentinel(addr string) error {
 		}
 	}
 
-	sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+	sentinel := s.newSentinel()
 	if err := sentinel.FlushConfig(addr, s.config.SentinelClientTimeout.Duration()); err != nil {
 		return err
 	}
@@ -73,7 +79,7 @@ func (s *Topom) DelSentinel(addr string, force bool) error {
 		return err
 	}
 
-	sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+	sentinel := s.newSentinel()
 	if err := sentinel.RemoveGroupsAll([]string{addr}, s.config.SentinelClientTimeout.Duration()); err != nil {
 		log.WarnErrorf(err, "remove sentinel %s failed", addr)
 		if !force {
@@ -86,6 +92,11 @@ func (s *Topom) DelSentinel(addr string, force bool) error {
 }
 
 func (s *Topom) SwitchMasters(masters map[int]string) error {
+	//混沌测试中延迟处理sentinel的通知
+	if d := s.chaosSentinelDelay(); d > 0 {
+		log.Warnf("chaos: delay sentinel masters %v for %s", masters, d)
+		time.Sleep(d)
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	if s.closed {
@@ -114,7 +125,7 @@ func (s *Topom) rewatchSentinels(servers []string) {
 	if len(servers) == 0 {
 		s.ha.masters = nil
 	} else {
-		s.ha.monitor = redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+		s.ha.monitor = s.newSentinel()
 		s.ha.monitor.LogFunc = log.Warnf
 		s.ha.monitor.ErrFunc = log.WarnErrorf
 		go func(p *redis.Sentinel) {
@@ -198,7 +209,7 @@ func (s *Topom) ResyncSentinelsNoLock() error {
 		ClientReconfigScript: s.config.SentinelClientReconfigScript,
 	}
 
-	sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+	sentinel := s.newSentinel()
 	if err := sentinel.RemoveGroupsAll(p.Servers, s.config.SentinelClientTimeout.Duration()); err != nil {
 		log.WarnErrorf(err, "remove sentinels failed")
 	}
@@ -247,7 +258,7 @@ func (s *Topom) SentinelRemoveGroupsAll() error {
 		return err
 	}
 
-	sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+	sentinel := s.newSentinel()
 	if err := sentinel.RemoveGroupsAll(p.Servers, s.config.SentinelClientTimeout.Duration()); err != nil {
 		log.WarnErrorf(err, "remove sentinels failed")
 		return err
@@ -280,7 +291,7 @@ func (s *Topom) SentinelRemoveGroupNoLock(gid int) error {
 	}
 
 	groupIds := map[int]bool{gid: true}
-	sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+	sentinel := s.newSentinel()
 	if err := sentinel.RemoveGroups(p.Servers, s.config.SentinelClientTimeout.Duration(), groupIds); err != nil {
 		log.WarnErrorf(err, "group-[%d] remove sentinels failed", gid)
 	}
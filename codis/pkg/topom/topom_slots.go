@@ -25,6 +25,9 @@ func (s *Topom) SlotCreateAction(sid int, gid int) error {
 	if s.config.MasterProduct != ""  {
 		return errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
@@ -49,6 +52,9 @@ func (s *Topom) SlotCreateAction(sid int, gid int) error {
 	if m.GroupId == gid {
 		return errors.Errorf("slot-[%d] already in group-[%d]", sid, gid)
 	}
+	if err := s.checkRouteMigration(m.GroupId); err != nil {
+		return err
+	}
 	defer s.dirtySlotsCache(m.Id)
 
 	m.Action.State = models.ActionPending
@@ -64,6 +70,9 @@ func (s *Topom) SlotCreateActionSome(groupFrom, groupTo int, numSlots int) error
 	if s.config.MasterProduct != ""  {
 		return errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
@@ -73,6 +82,9 @@ func (s *Topom) SlotCreateActionSome(groupFrom, groupTo int, numSlots int) error
 	if (groupFrom == groupTo) {
 		return errors.Errorf("Slots alreay on Group-[%d]!", groupTo)
 	}
+	if err := s.checkRouteMigration(groupFrom); err != nil {
+		return err
+	}
 
 	g, err := ctx.getGroup(groupTo)
 	if err != nil {
@@ -123,6 +135,9 @@ func (s *Topom) SlotCreateActionRange(beg, end int, gid int, must bool) error {
 	if s.config.MasterProduct != ""  {
 		return errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
@@ -159,6 +174,12 @@ func (s *Topom) SlotCreateActionRange(beg, end int, gid int, must bool) error {
 			}
 			return errors.Errorf("slot-[%d] already in group-[%d]", sid, g.Id)
 		}
+		if err := s.checkRouteMigration(m.GroupId); err != nil {
+			if !must {
+				continue
+			}
+			return err
+		}
 		pending = append(pending, m.Id)
 	}
 
@@ -186,6 +207,9 @@ func (s *Topom) SlotRemoveAction(sid int) error {
 	if s.config.MasterProduct != ""  {
 		return errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
@@ -218,6 +242,9 @@ func (s *Topom) SlotRemoveActionAll() error {
 	if s.config.MasterProduct != ""  {
 		return errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
@@ -259,7 +286,7 @@ func (s *Topom) SlotActionPrepareFilter(accept, update func(m *models.SlotMappin
 				continue
 			}
 			if filter(m) {
-				if picked != nil && picked.Action.Index < m.Action.Index {
+				if picked != nil && !slotActionBefore(m, picked) {
 					continue
 				}
 				if accept == nil || accept(m) {
@@ -299,6 +326,17 @@ func (s *Topom) SlotActionPrepareFilter(accept, update func(m *models.SlotMappin
 
 	case models.ActionPending:
 
+		//codis-server按crc32和{}计算slot，其他哈希函数或hash tag下迁移会漏掉key
+		if s.config.SlotHash != models.SlotHashCRC32 {
+			return 0, false, errors.Errorf("slot-[%d] can't be migrated with slot_hash = %s", m.Id, s.config.SlotHash)
+		}
+		if s.config.HashTag != models.DefaultHashTag {
+			return 0, false, errors.Errorf("slot-[%d] can't be migrated with hash_tag = %q", m.Id, s.config.HashTag)
+		}
+		if err := s.checkRouteMigration(m.GroupId); err != nil {
+			return 0, false, err
+		}
+
 		defer s.dirtySlotsCache(m.Id)
 
 		m.Action.State = models.ActionPreparing
@@ -479,12 +517,12 @@ func (s *Topom) newSlotActionExecutor(sid int) (func(db int) (remains int, nextd
 			if err := c.Select(db); err != nil {
 				return 0, -1, err
 			}
-			var do func() (int, error)
+			var do func() (int, int, error)
 
 			method, _ := models.ParseForwardMethod(s.config.MigrationMethod)
 			switch method {
 			case models.ForwardSync:
-				do = func() (int, error) {
+				do = func() (int, int, error) {
 					return c.MigrateSlot(sid, dest)
 				}
 			case models.ForwardSemiAsync:
@@ -495,17 +533,19 @@ func (s *Topom) newSlotActionExecutor(sid int) (func(db int) (remains int, nextd
 					Timeout: math2.MinDuration(time.Second*5,
 						s.config.MigrationTimeout.Duration()),
 				}
-				do = func() (int, error) {
+				do = func() (int, int, error) {
 					return c.MigrateSlotAsync(sid, dest, option)
 				}
 			default:
 				log.Panicf("unknown forward method %d", int(method))
 			}
 
-			n, err := do()
+			moved, n, err := do()
 			if err != nil {
 				return 0, -1, err
-			} else if n != 0 {
+			}
+			s.updateSlotProgress(sid, moved, n)
+			if n != 0 {
 				return n, db, nil
 			}
 
@@ -543,6 +583,9 @@ func (s *Topom) SlotsAssignGroup(slots []*models.SlotMapping) error {
 	if s.config.MasterProduct != ""  {
 		return errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
@@ -598,6 +641,9 @@ func (s *Topom) SlotsAssignOffline(slots []*models.SlotMapping) error {
 	if s.config.MasterProduct != ""  {
 		return errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
@@ -639,6 +685,9 @@ func (s *Topom) SlotsRebalance(confirm bool) (map[int]int, error) {
 	if s.config.MasterProduct != ""  {
 		return nil, errors.Errorf("dashboard cannot create slots action!")
 	}
+	if s.isStandby() {
+		return nil, errors.Errorf("standby dashboard cannot create slots action!")
+	}
 
 	ctx, err := s.newContext()
 	if err != nil {
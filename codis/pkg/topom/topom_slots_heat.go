@@ -0,0 +1,358 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type slotHeatCounter struct {
+	ops, bytes [MaxSlotNum]int64
+	time       time.Time
+}
+
+type slotHeatSample struct {
+	qps, bps [MaxSlotNum]float64
+}
+
+//拉取所有proxy上报的slot累计访问量，按差值计算本次采样的负载
+func (s *Topom) RefreshSlotHeat() error {
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	var proxies = make(map[string]*models.Proxy)
+	for _, p := range ctx.proxy {
+		proxies[p.Token] = p
+	}
+	s.mu.Unlock()
+
+	var counters = make(map[string]*slotHeatCounter)
+	for token, p := range proxies {
+		list, err := s.newProxyClient(p).SlotHeat()
+		if err != nil {
+			log.WarnErrorf(err, "proxy-[%s] get slot heat failed", token)
+			continue
+		}
+		c := &slotHeatCounter{time: time.Now()}
+		for _, x := range list {
+			if x.Id >= 0 && x.Id < MaxSlotNum {
+				c.ops[x.Id], c.bytes[x.Id] = x.Ops, x.Bytes
+			}
+		}
+		counters[token] = c
+	}
+
+	s.slotHeat.Lock()
+	defer s.slotHeat.Unlock()
+
+	var sample = &slotHeatSample{}
+	var valid bool
+	for token, c := range counters {
+		last := s.slotHeat.last[token]
+		s.slotHeat.last[token] = c
+		if last == nil {
+			continue
+		}
+		seconds := c.time.Sub(last.time).Seconds()
+		if seconds <= 0 {
+			continue
+		}
+		valid = true
+		for i := 0; i < MaxSlotNum; i++ {
+			//proxy重启后计数器清零，忽略该proxy本次的差值
+			if c.ops[i] < last.ops[i] || c.bytes[i] < last.bytes[i] {
+				continue
+			}
+			sample.qps[i] += float64(c.ops[i]-last.ops[i]) / seconds
+			sample.bps[i] += float64(c.bytes[i]-last.bytes[i]) / seconds
+		}
+	}
+	for token := range s.slotHeat.last {
+		if proxies[token] == nil {
+			delete(s.slotHeat.last, token)
+		}
+	}
+	if !valid {
+		return nil
+	}
+
+	s.slotHeat.history = append(s.slotHeat.history, sample)
+	if n := len(s.slotHeat.history) - s.config.SlotHeatHistory; n > 0 {
+		s.slotHeat.history = s.slotHeat.history[n:]
+	}
+	s.slotHeat.summary = summarizeSlotHeat(s.slotHeat.history)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.store.UpdateSlotHeat(s.slotHeat.summary); err != nil {
+		log.ErrorErrorf(err, "store: update slot heat failed")
+		return errors.Errorf("store: update slot heat failed")
+	}
+	return nil
+}
+
+func summarizeSlotHeat(history []*slotHeatSample) *models.SlotHeat {
+	var p = &models.SlotHeat{
+		Samples:    len(history),
+		UpdateTime: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if len(history) == 0 {
+		return p
+	}
+	for i := 0; i < MaxSlotNum; i++ {
+		var qps, bps float64
+		for _, x := range history {
+			qps += x.qps[i]
+			bps += x.bps[i]
+		}
+		if qps == 0 && bps == 0 {
+			continue
+		}
+		n := float64(len(history))
+		p.Slots = append(p.Slots, &models.SlotLoad{Id: i, QPS: qps / n, BPS: bps / n})
+	}
+	return p
+}
+
+func (s *Topom) SlotHeat() *models.SlotHeat {
+	s.slotHeat.Lock()
+	defer s.slotHeat.Unlock()
+	if s.slotHeat.summary == nil {
+		return &models.SlotHeat{}
+	}
+	return s.slotHeat.summary
+}
+
+//根据slot的访问负载与内存占用重新分布slot，而不是按slot数量平均分配
+func (s *Topom) SlotsRebalanceByLoad(confirm bool) (map[int]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return nil, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return nil, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	heat := s.SlotHeat()
+	if len(heat.Slots) == 0 {
+		return nil, errors.Errorf("no slot heat has been collected")
+	}
+
+	var groupIds []int
+	for _, g := range ctx.group {
+		if len(g.Servers) != 0 {
+			groupIds = append(groupIds, g.Id)
+		}
+	}
+	sort.Ints(groupIds)
+
+	if len(groupIds) < 2 {
+		return nil, errors.Errorf("no enough groups to rebalance")
+	}
+
+	var qps, mem [MaxSlotNum]float64
+	for _, x := range heat.Slots {
+		if x.Id >= 0 && x.Id < MaxSlotNum {
+			qps[x.Id] = x.QPS
+		}
+	}
+	for _, gid := range groupIds {
+		if err := s.estimateSlotMemory(ctx.getGroupMaster(gid), &mem); err != nil {
+			return nil, err
+		}
+	}
+	costs := slotLoadCosts(qps[:], mem[:])
+
+	var (
+		owner = make(map[int]int)
+		fixed = make(map[int]float64)
+	)
+	for _, m := range ctx.slots {
+		switch {
+		case m.Action.State != models.ActionNothing:
+			fixed[m.Action.TargetId] += costs[m.Id]
+		case m.GroupId != 0:
+			owner[m.Id] = m.GroupId
+		}
+	}
+	plans := planLoadRebalance(groupIds, owner, fixed, costs)
+
+	if !confirm {
+		return plans, nil
+	}
+
+	var slotIds []int
+	for sid := range plans {
+		slotIds = append(slotIds, sid)
+	}
+	sort.Ints(slotIds)
+
+	for _, sid := range slotIds {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return nil, err
+		}
+		defer s.dirtySlotsCache(m.Id)
+
+		m.Action.State = models.ActionPending
+		m.Action.Index = ctx.maxSlotActionIndex() + 1
+		m.Action.TargetId = plans[sid]
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return nil, err
+		}
+	}
+	return plans, nil
+}
+
+//按key数量将master的used_memory分摊到各个slot
+func (s *Topom) estimateSlotMemory(addr string, mem *[MaxSlotNum]float64) error {
+	return s.estimateSlotData(addr, nil, mem)
+}
+
+//同时返回每个slot的key数量，keys为nil时忽略
+func (s *Topom) estimateSlotData(addr string, keys *[MaxSlotNum]int64, mem *[MaxSlotNum]float64) error {
+	info, err := s.action.redisp.Info(addr)
+	if err != nil {
+		return errors.Errorf("server-[%s] info failed: %s", addr, err)
+	}
+	used, _ := strconv.ParseFloat(info["used_memory"], 64)
+
+	c, err := s.action.redisp.GetClient(addr)
+	if err != nil {
+		return errors.Errorf("server-[%s] is unreachable: %s", addr, err)
+	}
+	infos, err := c.SlotsInfo()
+	s.action.redisp.PutClient(c, err)
+	if err != nil {
+		return errors.Errorf("server-[%s] slotsinfo failed: %s", addr, err)
+	}
+
+	var total int
+	for _, n := range infos {
+		total += n
+	}
+	if total == 0 {
+		return nil
+	}
+	for sid, n := range infos {
+		if sid >= 0 && sid < MaxSlotNum {
+			mem[sid] += used * float64(n) / float64(total)
+			if keys != nil {
+				keys[sid] += int64(n)
+			}
+		}
+	}
+	return nil
+}
+
+//slot的代价为qps与内存占比的平均值，某一项全为0时只使用另一项
+func slotLoadCosts(qps, mem []float64) []float64 {
+	var sumQps, sumMem float64
+	for i := range qps {
+		sumQps += qps[i]
+		sumMem += mem[i]
+	}
+	var costs = make([]float64, len(qps))
+	for i := range costs {
+		var n float64
+		if sumQps != 0 {
+			costs[i] += qps[i] / sumQps
+			n++
+		}
+		if sumMem != 0 {
+			costs[i] += mem[i] / sumMem
+			n++
+		}
+		if n != 0 {
+			costs[i] /= n
+		}
+	}
+	return costs
+}
+
+//负载差异小于平均负载的该比例时停止迁移
+const loadRebalanceTolerance = 0.05
+
+//每次从负载最高的group选出一个slot迁移到负载最低的group，使两者的负载差距最小，直到无法继续改善
+func planLoadRebalance(groupIds []int, owner map[int]int, fixed map[int]float64, costs []float64) map[int]int {
+	var load = make(map[int]float64)
+	var slots = make(map[int][]int)
+	var total float64
+	for _, gid := range groupIds {
+		load[gid] = fixed[gid]
+		total += fixed[gid]
+	}
+	for sid, gid := range owner {
+		if _, ok := load[gid]; !ok {
+			continue
+		}
+		load[gid] += costs[sid]
+		slots[gid] = append(slots[gid], sid)
+		total += costs[sid]
+	}
+	for _, gid := range groupIds {
+		sort.Ints(slots[gid])
+	}
+
+	var plans = make(map[int]int)
+	if total == 0 {
+		return plans
+	}
+	var tolerance = total / float64(len(groupIds)) * loadRebalanceTolerance
+
+	for i := 0; i < len(owner); i++ {
+		var from, dest = groupIds[0], groupIds[0]
+		for _, gid := range groupIds {
+			if load[gid] > load[from] {
+				from = gid
+			}
+			if load[gid] < load[dest] {
+				dest = gid
+			}
+		}
+		diff := load[from] - load[dest]
+		if diff <= tolerance {
+			break
+		}
+		var pick = -1
+		var best = diff
+		for k, sid := range slots[from] {
+			if _, ok := plans[sid]; ok {
+				continue
+			}
+			if c := costs[sid]; c > 0 && c < diff {
+				if d := math.Abs(diff - 2*c); d < best {
+					pick, best = k, d
+				}
+			}
+		}
+		if pick < 0 {
+			break
+		}
+		sid := slots[from][pick]
+		slots[from] = append(slots[from][:pick], slots[from][pick+1:]...)
+		slots[dest] = append(slots[dest], sid)
+		load[from] -= costs[sid]
+		load[dest] += costs[sid]
+		plans[sid] = dest
+	}
+	return plans
+}
@@ -0,0 +1,41 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSlotLoadCosts(x *testing.T) {
+	costs := slotLoadCosts([]float64{3, 1, 0, 0}, []float64{0, 0, 0, 0})
+	assert.Must(costs[0] == 0.75 && costs[1] == 0.25 && costs[2] == 0)
+
+	costs = slotLoadCosts([]float64{1, 1}, []float64{3, 1})
+	assert.Must(costs[0] == 0.625 && costs[1] == 0.375)
+}
+
+func TestPlanLoadRebalance(x *testing.T) {
+	costs := []float64{0.4, 0.3, 0.1, 0.1, 0.05, 0.05}
+	owner := map[int]int{0: 1, 1: 1, 2: 1, 3: 1, 4: 2, 5: 2}
+	plans := planLoadRebalance([]int{1, 2}, owner, nil, costs)
+	assert.Must(len(plans) != 0)
+
+	var load = map[int]float64{}
+	for sid, gid := range owner {
+		if dest, ok := plans[sid]; ok {
+			gid = dest
+		}
+		load[gid] += costs[sid]
+	}
+	d := load[1] - load[2]
+	assert.Must(d < 0.2 && d > -0.2)
+
+	plans = planLoadRebalance([]int{1, 2}, map[int]int{0: 1, 1: 2}, nil, []float64{0.5, 0.5})
+	assert.Must(len(plans) == 0)
+
+	plans = planLoadRebalance([]int{1, 2}, map[int]int{0: 1}, map[int]float64{2: 0.5}, []float64{0.5})
+	assert.Must(len(plans) == 0)
+}
@@ -0,0 +1,128 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+const (
+	SlotActorApi       = "api"
+	SlotActorDashboard = "dashboard"
+)
+
+//每个slot最多保留的状态变化记录数
+const maxSlotTransitions = 512
+
+//根据slot的最新状态生成一条状态变化，状态没有变化时返回nil
+func nextSlotTransition(h *models.SlotHistory, m *models.SlotMapping, now time.Time) *models.SlotTransition {
+	var last *models.SlotTransition
+	if n := len(h.Transitions); n != 0 {
+		last = h.Transitions[n-1]
+	}
+	t := &models.SlotTransition{
+		Time:    now.Unix(),
+		GroupId: m.GroupId, TargetId: m.Action.TargetId,
+		Actor: SlotActorDashboard,
+	}
+
+	if m.Action.State != models.ActionNothing {
+		if last != nil && last.State == m.Action.State && last.GroupId == m.GroupId && last.TargetId == m.Action.TargetId {
+			return nil
+		}
+		t.State = m.Action.State
+		if t.State == models.ActionPending {
+			t.Actor = SlotActorApi
+		}
+		return t
+	}
+
+	switch {
+	case last == nil:
+		if m.GroupId == 0 {
+			return nil
+		}
+		t.State, t.Actor = models.SlotTransitionAssigned, SlotActorApi
+	case last.State == models.SlotTransitionDone || last.State == models.SlotTransitionCancelled || last.State == models.SlotTransitionAssigned:
+		if last.GroupId == m.GroupId {
+			return nil
+		}
+		t.State, t.Actor = models.SlotTransitionAssigned, SlotActorApi
+	case last.State != models.ActionPending && m.GroupId == last.TargetId:
+		t.State = models.SlotTransitionDone
+		for i := len(h.Transitions) - 1; i >= 0; i-- {
+			if x := h.Transitions[i]; x.State == models.ActionPending {
+				t.Elapsed = now.Sub(time.Unix(x.Time, 0)).Seconds()
+				break
+			}
+		}
+	default:
+		t.State, t.Actor = models.SlotTransitionCancelled, SlotActorApi
+	}
+	return t
+}
+
+func (s *Topom) loadSlotHistory(sid int) (*models.SlotHistory, error) {
+	if h := s.slotHistory.m[sid]; h != nil {
+		return h, nil
+	}
+	h, err := s.store.LoadSlotHistory(sid, false)
+	if err != nil {
+		return nil, err
+	}
+	if h == nil {
+		h = &models.SlotHistory{Id: sid}
+	}
+	s.slotHistory.m[sid] = h
+	return h, nil
+}
+
+//记录slot的状态变化，失败时只打印日志，不影响slot的更新
+func (s *Topom) recordSlotTransition(m *models.SlotMapping) {
+	s.slotHistory.Lock()
+	defer s.slotHistory.Unlock()
+
+	h, err := s.loadSlotHistory(m.Id)
+	if err != nil {
+		log.WarnErrorf(err, "store: load slot-[%d] history failed", m.Id)
+		return
+	}
+	t := nextSlotTransition(h, m, time.Now())
+	if t == nil {
+		return
+	}
+	h.Transitions = append(h.Transitions, t)
+	if n := len(h.Transitions) - maxSlotTransitions; n > 0 {
+		h.Transitions = h.Transitions[n:]
+	}
+	if err := s.store.UpdateSlotHistory(h); err != nil {
+		log.WarnErrorf(err, "store: update slot-[%d] history failed", m.Id)
+	}
+}
+
+//返回slot的状态变化，since不为0时只返回该时间之后的记录
+func (s *Topom) SlotHistory(sid int, since int64) (*models.SlotHistory, error) {
+	if sid < 0 || sid >= MaxSlotNum {
+		return nil, errors.Errorf("invalid slot id = %d", sid)
+	}
+	s.slotHistory.Lock()
+	defer s.slotHistory.Unlock()
+
+	h, err := s.loadSlotHistory(sid)
+	if err != nil {
+		log.ErrorErrorf(err, "store: load slot-[%d] history failed", sid)
+		return nil, errors.Errorf("store: load slot-[%d] history failed", sid)
+	}
+	var p = &models.SlotHistory{Id: sid, Transitions: []*models.SlotTransition{}}
+	for _, t := range h.Transitions {
+		if t.Time >= since {
+			p.Transitions = append(p.Transitions, t)
+		}
+	}
+	return p, nil
+}
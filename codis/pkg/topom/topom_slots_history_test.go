@@ -0,0 +1,52 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestNextSlotTransition(x *testing.T) {
+	h := &models.SlotHistory{Id: 731}
+	now := time.Unix(1000, 0)
+	m := &models.SlotMapping{Id: 731, GroupId: 1}
+
+	record := func(state string) *models.SlotTransition {
+		m.Action.State = state
+		t := nextSlotTransition(h, m, now)
+		if t != nil {
+			h.Transitions = append(h.Transitions, t)
+		}
+		return t
+	}
+
+	t := record(models.ActionNothing)
+	assert.Must(t != nil && t.State == models.SlotTransitionAssigned && t.GroupId == 1)
+	assert.Must(record(models.ActionNothing) == nil)
+
+	m.Action.TargetId = 2
+	t = record(models.ActionPending)
+	assert.Must(t != nil && t.Actor == SlotActorApi && t.TargetId == 2)
+	assert.Must(record(models.ActionPending) == nil)
+
+	now = now.Add(time.Second * 30)
+	for _, state := range []string{models.ActionPreparing, models.ActionPrepared, models.ActionMigrating, models.ActionFinished} {
+		t = record(state)
+		assert.Must(t != nil && t.State == state && t.Actor == SlotActorDashboard)
+	}
+
+	m.GroupId, m.Action.TargetId = 2, 0
+	t = record(models.ActionNothing)
+	assert.Must(t != nil && t.State == models.SlotTransitionDone && t.Elapsed == 30)
+
+	m.Action.TargetId = 3
+	record(models.ActionPending)
+	m.Action.TargetId = 0
+	t = record(models.ActionNothing)
+	assert.Must(t != nil && t.State == models.SlotTransitionCancelled && t.GroupId == 2)
+}
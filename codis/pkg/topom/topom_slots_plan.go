@@ -0,0 +1,343 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//迁移计划中的一步，包含从同一个group迁移到同一个group的所有slot
+type MigrationStep struct {
+	Id      int     `json:"id"`
+	From    int     `json:"from"`
+	To      int     `json:"to"`
+	Slots   []int   `json:"slots"`
+	Keys    int64   `json:"keys"`
+	Bytes   int64   `json:"bytes"`
+	Seconds float64 `json:"seconds"`
+	Applied bool    `json:"applied"`
+}
+
+//计划中单个slot的迁移
+type SlotMove struct {
+	Slot    int     `json:"slot"`
+	From    int     `json:"from"`
+	To      int     `json:"to"`
+	Keys    int64   `json:"keys"`
+	Bytes   int64   `json:"bytes"`
+	Seconds float64 `json:"seconds"`
+}
+
+//rebalance的dry-run结果，fe确认后逐步执行
+type MigrationPlan struct {
+	Id         int    `json:"id"`
+	ByLoad     bool   `json:"by_load"`
+	CreateTime string `json:"create_time"`
+
+	//按权重生成的计划使用的权重
+	WeightBy string          `json:"weight_by,omitempty"`
+	Weights  map[int]float64 `json:"weights,omitempty"`
+
+	Steps []*MigrationStep `json:"steps"`
+	//按slot编号排列
+	Moves []*SlotMove `json:"moves"`
+
+	Total struct {
+		Slots   int     `json:"slots"`
+		Keys    int64   `json:"keys"`
+		Bytes   int64   `json:"bytes"`
+		Seconds float64 `json:"seconds"`
+	} `json:"total"`
+}
+
+const maxMigrationPlans = 16
+
+func (s *Topom) storeMigrationPlan(p *MigrationPlan) {
+	s.migrationPlans.Lock()
+	defer s.migrationPlans.Unlock()
+	s.migrationPlans.nextId++
+	p.Id = s.migrationPlans.nextId
+	s.migrationPlans.list = append(s.migrationPlans.list, p)
+	if n := len(s.migrationPlans.list) - maxMigrationPlans; n > 0 {
+		s.migrationPlans.list = s.migrationPlans.list[n:]
+	}
+}
+
+//调用者需要持有migrationPlans锁
+func (s *Topom) findMigrationPlan(pid int) (*MigrationPlan, error) {
+	for _, p := range s.migrationPlans.list {
+		if p.Id == pid {
+			return p, nil
+		}
+	}
+	return nil, errors.Errorf("migration plan-[%d] doesn't exist", pid)
+}
+
+//生成rebalance的迁移计划并估算数据量与耗时，不会创建任何slot action
+func (s *Topom) SlotsMigrationPlan(byLoad bool) (*MigrationPlan, error) {
+	p, err := s.SlotsRebalancePreview(byLoad, true)
+	if err != nil {
+		return nil, err
+	}
+	s.storeMigrationPlan(p)
+
+	log.Warnf("migration plan-[%d] created, %d steps, %d slots", p.Id, len(p.Steps), p.Total.Slots)
+	return p, nil
+}
+
+//dryrun时只返回计划中每个slot的迁移与估算的数据量和耗时，不修改任何状态；
+//否则按计划创建slot action，返回的计划中所有步骤都已经执行
+func (s *Topom) SlotsRebalancePreview(byLoad, dryrun bool) (*MigrationPlan, error) {
+	var plans map[int]int
+	var err error
+	if byLoad {
+		plans, err = s.SlotsRebalanceByLoad(!dryrun)
+	} else {
+		plans, err = s.SlotsRebalance(!dryrun)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.newMigrationPlan(plans)
+	if err != nil {
+		return nil, err
+	}
+	p.ByLoad = byLoad
+	for _, x := range p.Steps {
+		x.Applied = !dryrun
+	}
+	return p, nil
+}
+
+//按源group的数据量估算迁移计划，调用者负责保存
+func (s *Topom) newMigrationPlan(plans map[int]int) (*MigrationPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [MaxSlotNum]int64
+	var mem [MaxSlotNum]float64
+	var sources = make(map[int]bool)
+	for sid := range plans {
+		if gid := ctx.slots[sid].GroupId; gid != 0 {
+			sources[gid] = true
+		}
+	}
+	for gid := range sources {
+		if addr := ctx.getGroupMaster(gid); addr != "" {
+			if err := s.estimateSlotData(addr, &keys, &mem); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	interval := time.Microsecond * time.Duration(s.action.interval.Int64())
+	p := buildMigrationPlan(ctx.slots, plans, keys[:], mem[:], float64(s.config.MigrationEstimateRate.Int64()), interval)
+	p.CreateTime = time.Now().Format("2006-01-02 15:04:05")
+	return p, nil
+}
+
+//按源group与目标group将slot分成多个步骤，耗时按数据量与slot action间隔估算
+func buildMigrationPlan(slots []*models.SlotMapping, plans map[int]int, keys []int64, mem []float64, rate float64, interval time.Duration) *MigrationPlan {
+	var steps = make(map[[2]int]*MigrationStep)
+	var p = &MigrationPlan{}
+	for sid, gid := range plans {
+		from := slots[sid].GroupId
+		x := steps[[2]int{from, gid}]
+		if x == nil {
+			x = &MigrationStep{From: from, To: gid}
+			steps[[2]int{from, gid}] = x
+			p.Steps = append(p.Steps, x)
+		}
+		x.Slots = append(x.Slots, sid)
+		if from != 0 {
+			x.Keys += keys[sid]
+			x.Bytes += int64(mem[sid])
+		}
+	}
+	sort.Sort(migrationStepSorter(p.Steps))
+
+	for sid, gid := range plans {
+		x := &SlotMove{Slot: sid, From: slots[sid].GroupId, To: gid}
+		if x.From != 0 {
+			x.Keys, x.Bytes = keys[sid], int64(mem[sid])
+		}
+		if rate > 0 {
+			x.Seconds = float64(x.Bytes) / rate
+		}
+		x.Seconds += interval.Seconds()
+		p.Moves = append(p.Moves, x)
+	}
+	sort.Slice(p.Moves, func(i, j int) bool {
+		return p.Moves[i].Slot < p.Moves[j].Slot
+	})
+
+	for i, x := range p.Steps {
+		x.Id = i + 1
+		sort.Ints(x.Slots)
+		if rate > 0 {
+			x.Seconds = float64(x.Bytes) / rate
+		}
+		x.Seconds += interval.Seconds() * float64(len(x.Slots))
+
+		p.Total.Slots += len(x.Slots)
+		p.Total.Keys += x.Keys
+		p.Total.Bytes += x.Bytes
+		p.Total.Seconds += x.Seconds
+	}
+	return p
+}
+
+type migrationStepSorter []*MigrationStep
+
+func (s migrationStepSorter) Len() int { return len(s) }
+func (s migrationStepSorter) Less(i, j int) bool {
+	if s[i].From != s[j].From {
+		return s[i].From < s[j].From
+	}
+	return s[i].To < s[j].To
+}
+func (s migrationStepSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s *Topom) GetMigrationPlan(pid int) (*MigrationPlan, error) {
+	s.migrationPlans.Lock()
+	defer s.migrationPlans.Unlock()
+	return s.findMigrationPlan(pid)
+}
+
+//执行迁移计划中的一步，slot在计划生成后发生变化时拒绝执行
+func (s *Topom) SlotsMigrationPlanApply(pid, step int) (*MigrationStep, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return nil, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return nil, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	s.migrationPlans.Lock()
+	defer s.migrationPlans.Unlock()
+
+	p, err := s.findMigrationPlan(pid)
+	if err != nil {
+		return nil, err
+	}
+	if step <= 0 || step > len(p.Steps) {
+		return nil, errors.Errorf("invalid step %d of migration plan-[%d]", step, pid)
+	}
+	x := p.Steps[step-1]
+	if x.Applied {
+		return nil, errors.Errorf("step %d of migration plan-[%d] has been applied", step, pid)
+	}
+	if err := s.checkMigrationStep(ctx, pid, x); err != nil {
+		return nil, err
+	}
+	if err := s.applyMigrationStep(ctx, pid, x); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+//一次执行迁移计划中所有还没有执行的步骤，先检查所有步骤，任何一步的slot发生变化时都不执行
+func (s *Topom) SlotsMigrationPlanApplyAll(pid int) ([]*MigrationStep, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return nil, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return nil, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	s.migrationPlans.Lock()
+	defer s.migrationPlans.Unlock()
+
+	p, err := s.findMigrationPlan(pid)
+	if err != nil {
+		return nil, err
+	}
+	var steps = []*MigrationStep{}
+	for _, x := range p.Steps {
+		if x.Applied {
+			continue
+		}
+		if err := s.checkMigrationStep(ctx, pid, x); err != nil {
+			return nil, err
+		}
+		steps = append(steps, x)
+	}
+	if len(steps) == 0 {
+		return nil, errors.Errorf("all steps of migration plan-[%d] have been applied", pid)
+	}
+	for _, x := range steps {
+		if err := s.applyMigrationStep(ctx, pid, x); err != nil {
+			return nil, err
+		}
+	}
+	return steps, nil
+}
+
+func (s *Topom) checkMigrationStep(ctx *context, pid int, x *MigrationStep) error {
+
+	g, err := ctx.getGroup(x.To)
+	if err != nil {
+		return err
+	}
+	if len(g.Servers) == 0 {
+		return errors.Errorf("group-[%d] is empty", g.Id)
+	}
+	for _, sid := range x.Slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return err
+		}
+		if m.Action.State != models.ActionNothing || m.GroupId != x.From {
+			return errors.Errorf("slot-[%d] has changed since migration plan-[%d] was created", sid, pid)
+		}
+	}
+	return nil
+}
+
+func (s *Topom) applyMigrationStep(ctx *context, pid int, x *MigrationStep) error {
+	for _, sid := range x.Slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return err
+		}
+		defer s.dirtySlotsCache(m.Id)
+
+		m.Action.State = models.ActionPending
+		m.Action.Index = ctx.maxSlotActionIndex() + 1
+		m.Action.TargetId = x.To
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return err
+		}
+	}
+	x.Applied = true
+
+	log.Warnf("migration plan-[%d] step %d applied, %d slots from group-[%d] to group-[%d]",
+		pid, x.Id, len(x.Slots), x.From, x.To)
+	return nil
+}
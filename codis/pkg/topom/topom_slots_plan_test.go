@@ -0,0 +1,86 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestBuildMigrationPlan(x *testing.T) {
+	var slots []*models.SlotMapping
+	for i := 0; i < 8; i++ {
+		slots = append(slots, &models.SlotMapping{Id: i, GroupId: 1})
+	}
+	slots[7].GroupId = 0
+
+	var keys = make([]int64, 8)
+	var mem = make([]float64, 8)
+	for i := range keys {
+		keys[i], mem[i] = 10, 1000
+	}
+	plans := map[int]int{3: 2, 1: 2, 2: 3, 7: 3}
+
+	p := buildMigrationPlan(slots, plans, keys, mem, 1000, time.Second)
+	assert.Must(len(p.Steps) == 3)
+
+	s := p.Steps[0]
+	assert.Must(s.Id == 1 && s.From == 0 && s.To == 3)
+	assert.Must(len(s.Slots) == 1 && s.Keys == 0 && s.Bytes == 0 && s.Seconds == 1)
+
+	s = p.Steps[1]
+	assert.Must(s.Id == 2 && s.From == 1 && s.To == 2)
+	assert.Must(len(s.Slots) == 2 && s.Slots[0] == 1 && s.Slots[1] == 3)
+	assert.Must(s.Keys == 20 && s.Bytes == 2000 && s.Seconds == 4)
+
+	assert.Must(p.Steps[2].From == 1 && p.Steps[2].To == 3)
+	assert.Must(p.Total.Slots == 4 && p.Total.Keys == 30 && p.Total.Bytes == 3000)
+	assert.Must(p.Total.Seconds == 1+4+2)
+
+	assert.Must(len(p.Moves) == 4 && p.Moves[0].Slot == 1 && p.Moves[3].Slot == 7)
+	assert.Must(p.Moves[0].From == 1 && p.Moves[0].To == 2 && p.Moves[0].Keys == 10 && p.Moves[0].Seconds == 2)
+	assert.Must(p.Moves[3].From == 0 && p.Moves[3].Bytes == 0)
+}
+
+func TestSlotsRebalanceDryRun(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{{Addr: s2.Addr}}})
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: 1})
+	}
+	var pending = func() int {
+		slots, err := t.store.SlotMappings()
+		assert.MustNoError(err)
+		var n int
+		for _, m := range slots {
+			if m.Action.State != models.ActionNothing {
+				n++
+			}
+		}
+		return n
+	}
+
+	c := newApiClient(t)
+	p, err := c.SlotsRebalanceDryRun(false)
+	assert.MustNoError(err)
+	assert.Must(p.Id == 0 && len(p.Moves) == MaxSlotNum/2 && len(p.Steps) == 1 && !p.Steps[0].Applied)
+	assert.Must(p.Moves[0].From == 1 && p.Moves[0].To == 2)
+	assert.Must(pending() == 0)
+
+	p, err = t.SlotsRebalancePreview(false, false)
+	assert.MustNoError(err)
+	assert.Must(len(p.Moves) == MaxSlotNum/2 && p.Steps[0].Applied)
+	assert.Must(pending() == MaxSlotNum/2)
+}
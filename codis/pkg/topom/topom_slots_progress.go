@@ -0,0 +1,173 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type slotProgress struct {
+	start time.Time
+	//开始迁移时源group中每个key的平均内存
+	bytesPerKey float64
+
+	keys    int64
+	remains int64
+	//已经计入迁移带宽的key数
+	throttled int64
+}
+
+func (p *slotProgress) snapshot(now time.Time) *models.SlotActionProgress {
+	x := &models.SlotActionProgress{
+		Keys: p.keys, Remains: p.remains,
+		Bytes:     int64(float64(p.keys) * p.bytesPerKey),
+		StartTime: p.start.Unix(),
+		Elapsed:   now.Sub(p.start).Seconds(),
+		ETA:       -1,
+	}
+	if x.Elapsed > 0 {
+		x.KeysPerSec = float64(x.Keys) / x.Elapsed
+		x.BytesPerSec = float64(x.Bytes) / x.Elapsed
+	}
+	if x.KeysPerSec > 0 {
+		x.ETA = float64(x.Remains) / x.KeysPerSec
+	}
+	return x
+}
+
+//迁移开始时按源group的SLOTSINFO和used_memory估算slot的key数与平均大小
+func (s *Topom) startSlotProgress(sid int) {
+	p := &slotProgress{start: time.Now()}
+	if from, _, ok := s.slotMigrationEndpoints(sid); ok {
+		var keys [MaxSlotNum]int64
+		var mem [MaxSlotNum]float64
+		if err := s.estimateSlotData(from, &keys, &mem); err != nil {
+			log.WarnErrorf(err, "slot-[%d] estimate migration data failed", sid)
+		} else if keys[sid] != 0 {
+			p.bytesPerKey = mem[sid] / float64(keys[sid])
+			p.remains = keys[sid]
+		}
+	}
+	s.action.progress.Lock()
+	defer s.action.progress.Unlock()
+	if s.action.progress.slots == nil {
+		s.action.progress.slots = make(map[int]*slotProgress)
+	}
+	s.action.progress.slots[sid] = p
+}
+
+func (s *Topom) updateSlotProgress(sid int, moved, remains int) {
+	s.action.progress.Lock()
+	defer s.action.progress.Unlock()
+	if p := s.action.progress.slots[sid]; p != nil {
+		p.keys += int64(moved)
+		p.remains = int64(remains)
+	}
+}
+
+func (s *Topom) finishSlotProgress(sid int) {
+	s.action.progress.Lock()
+	defer s.action.progress.Unlock()
+	delete(s.action.progress.slots, sid)
+}
+
+func (s *Topom) slotProgress(sid int, now time.Time) *models.SlotActionProgress {
+	s.action.progress.Lock()
+	defer s.action.progress.Unlock()
+	if p := s.action.progress.slots[sid]; p != nil {
+		return p.snapshot(now)
+	}
+	return nil
+}
+
+//正在迁移的slot返回带进度的副本，缓存中的slot不会被修改
+func (s *Topom) slotsWithProgress(slots []*models.SlotMapping) []*models.SlotMapping {
+	var now = time.Now()
+	var list []*models.SlotMapping
+	for i, m := range slots {
+		if m.Action.State != models.ActionMigrating {
+			continue
+		}
+		x := s.slotProgress(m.Id, now)
+		if x == nil {
+			continue
+		}
+		if list == nil {
+			list = make([]*models.SlotMapping, len(slots))
+			copy(list, slots)
+		}
+		c := *m
+		c.Action.Progress = x
+		list[i] = &c
+	}
+	if list == nil {
+		return slots
+	}
+	return list
+}
+
+type MigrationProgress struct {
+	Slots []*models.SlotMapping `json:"slots"`
+	//还没有开始迁移的slot数
+	Pending int `json:"pending"`
+	//不在允许迁移的时间段内
+	Paused bool `json:"paused"`
+
+	//pending的slot按正在迁移的slot的平均key数计入剩余的key数
+	Total models.SlotActionProgress `json:"total"`
+}
+
+func (s *Topom) SlotsProgress() (*MigrationProgress, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+	var now = time.Now()
+	var p = &MigrationProgress{Slots: []*models.SlotMapping{}}
+	p.Paused = s.isMigrationPaused(now)
+	p.Total.ETA = -1
+	for _, m := range ctx.slots {
+		switch m.Action.State {
+		case models.ActionNothing, models.ActionFinished:
+			continue
+		case models.ActionMigrating:
+			if x := s.slotProgress(m.Id, now); x != nil {
+				c := *m
+				c.Action.Progress = x
+				p.Slots = append(p.Slots, &c)
+				continue
+			}
+		}
+		p.Pending++
+	}
+	if len(p.Slots) == 0 {
+		return p, nil
+	}
+
+	var t = &p.Total
+	for i, m := range p.Slots {
+		x := m.Action.Progress
+		t.Keys += x.Keys
+		t.Remains += x.Remains
+		t.Bytes += x.Bytes
+		t.KeysPerSec += x.KeysPerSec
+		t.BytesPerSec += x.BytesPerSec
+		if i == 0 || x.StartTime < t.StartTime {
+			t.StartTime = x.StartTime
+		}
+		if x.Elapsed > t.Elapsed {
+			t.Elapsed = x.Elapsed
+		}
+	}
+	t.Remains += int64(p.Pending) * (t.Keys + t.Remains) / int64(len(p.Slots))
+	if t.KeysPerSec > 0 {
+		t.ETA = float64(t.Remains) / t.KeysPerSec
+	}
+	return p, nil
+}
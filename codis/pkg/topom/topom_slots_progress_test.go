@@ -0,0 +1,64 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSlotProgressSnapshot(x *testing.T) {
+	var now = time.Now()
+	p := &slotProgress{start: now.Add(-time.Second * 10), bytesPerKey: 100}
+	assert.Must(p.snapshot(now).ETA == -1)
+
+	p.keys, p.remains = 1000, 3000
+	o := p.snapshot(now)
+	assert.Must(o.Bytes == 100000 && o.KeysPerSec == 100 && o.BytesPerSec == 10000)
+	assert.Must(o.ETA == 30)
+}
+
+func TestSlotsProgress(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	for _, sid := range []int{1, 2, 3} {
+		m := &models.SlotMapping{Id: sid}
+		m.Action.State = models.ActionMigrating
+		if sid == 3 {
+			m.Action.State = models.ActionPending
+		}
+		contextUpdateSlotMapping(t, m)
+	}
+
+	t.startSlotProgress(1)
+	t.startSlotProgress(2)
+	t.updateSlotProgress(1, 100, 200)
+	t.updateSlotProgress(1, 100, 100)
+	t.updateSlotProgress(2, 50, 150)
+
+	p, err := t.SlotsProgress()
+	assert.MustNoError(err)
+	assert.Must(len(p.Slots) == 2 && p.Pending == 1)
+	assert.Must(p.Slots[0].Id == 1 && p.Slots[0].Action.Progress.Keys == 200)
+	assert.Must(p.Slots[0].Action.Progress.Remains == 100)
+	//pending的slot按平均每个slot的key数估算: (250+250)/2
+	assert.Must(p.Total.Keys == 250 && p.Total.Remains == 500 && p.Total.ETA > 0)
+
+	//进度不会写入协调服务
+	stats, err := t.Stats()
+	assert.MustNoError(err)
+	assert.Must(stats.Slots[1].Action.Progress != nil && stats.Slots[3].Action.Progress == nil)
+	m, err := t.store.LoadSlotMapping(1, true)
+	assert.MustNoError(err)
+	assert.Must(m.Action.Progress == nil)
+
+	t.finishSlotProgress(1)
+	p, err = t.SlotsProgress()
+	assert.MustNoError(err)
+	assert.Must(len(p.Slots) == 1 && p.Pending == 2)
+}
@@ -0,0 +1,164 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//优先级高的先执行，优先级相同时按index顺序执行
+func slotActionBefore(a, b *models.SlotMapping) bool {
+	if a.Action.Priority != b.Action.Priority {
+		return a.Action.Priority > b.Action.Priority
+	}
+	return a.Action.Index < b.Action.Index
+}
+
+type slotActionSorter []*models.SlotMapping
+
+func (s slotActionSorter) Len() int           { return len(s) }
+func (s slotActionSorter) Less(i, j int) bool { return slotActionBefore(s[i], s[j]) }
+func (s slotActionSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func pendingSlotActions(ctx *context) []*models.SlotMapping {
+	var list []*models.SlotMapping
+	for _, m := range ctx.slots {
+		if m.Action.State == models.ActionPending {
+			list = append(list, m)
+		}
+	}
+	sort.Sort(slotActionSorter(list))
+	return list
+}
+
+//按执行顺序返回所有pending的slot迁移
+func (s *Topom) SlotActionQueue() ([]*models.SlotMapping, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+	return pendingSlotActions(ctx), nil
+}
+
+func (s *Topom) checkSlotActionQueue() (*context, error) {
+	if s.config.MasterProduct != "" {
+		return nil, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return nil, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+	return s.newContext()
+}
+
+//修改filter选中的pending迁移的优先级，返回修改的数量
+func (s *Topom) setSlotActionPriority(ctx *context, priority int, filter func(m *models.SlotMapping) bool) (int, error) {
+	var n int
+	for _, m := range pendingSlotActions(ctx) {
+		if !filter(m) || m.Action.Priority == priority {
+			continue
+		}
+		defer s.dirtySlotsCache(m.Id)
+
+		m.Action.Priority = priority
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+func (s *Topom) SlotActionSetPriority(sid int, priority int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.checkSlotActionQueue()
+	if err != nil {
+		return err
+	}
+
+	m, err := ctx.getSlotMapping(sid)
+	if err != nil {
+		return err
+	}
+	if m.Action.State == models.ActionNothing {
+		return errors.Errorf("slot-[%d] action doesn't exist", sid)
+	}
+	if m.Action.State != models.ActionPending {
+		return errors.Errorf("slot-[%d] action isn't pending", sid)
+	}
+	_, err = s.setSlotActionPriority(ctx, priority, func(m *models.SlotMapping) bool {
+		return m.Id == sid
+	})
+	return err
+}
+
+//修改迁出gid的所有pending迁移的优先级，用于优先排空故障的group
+func (s *Topom) SlotActionSetGroupPriority(gid int, priority int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.checkSlotActionQueue()
+	if err != nil {
+		return 0, err
+	}
+	if _, err := ctx.getGroup(gid); err != nil {
+		return 0, err
+	}
+	n, err := s.setSlotActionPriority(ctx, priority, func(m *models.SlotMapping) bool {
+		return m.GroupId == gid
+	})
+	if err != nil {
+		return n, err
+	}
+	log.Warnf("set priority of %d pending slot actions of group-[%d] to %d", n, gid, priority)
+	return n, nil
+}
+
+//将slots按给定顺序排在同优先级的其他pending迁移之前，其余迁移保持原有顺序
+func (s *Topom) SlotActionReorder(slots []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.checkSlotActionQueue()
+	if err != nil {
+		return err
+	}
+
+	var front = make(map[int]bool)
+	var order []*models.SlotMapping
+	for _, sid := range slots {
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return err
+		}
+		if m.Action.State != models.ActionPending {
+			return errors.Errorf("slot-[%d] action isn't pending", sid)
+		}
+		if front[sid] {
+			return errors.Errorf("slot-[%d] is duplicated", sid)
+		}
+		front[sid] = true
+		order = append(order, m)
+	}
+	for _, m := range pendingSlotActions(ctx) {
+		if !front[m.Id] {
+			order = append(order, m)
+		}
+	}
+
+	var base = ctx.maxSlotActionIndex()
+	for i, m := range order {
+		defer s.dirtySlotsCache(m.Id)
+
+		m.Action.Index = base + i + 1
+		if err := s.storeUpdateSlotMapping(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSlotActionOrder(x *testing.T) {
+	var list []*models.SlotMapping
+	for i, p := range []int{0, 0, 5, 0, 5} {
+		m := &models.SlotMapping{Id: i}
+		m.Action.State = models.ActionPending
+		m.Action.Index = 10 - i
+		m.Action.Priority = p
+		list = append(list, m)
+	}
+	sort.Sort(slotActionSorter(list))
+
+	var order []int
+	for _, m := range list {
+		order = append(order, m.Id)
+	}
+	assert.Must(len(order) == 5)
+	assert.Must(order[0] == 4 && order[1] == 2)
+	assert.Must(order[2] == 3 && order[3] == 1 && order[4] == 0)
+}
@@ -139,6 +139,32 @@ func TestSlotActionSimple(x *testing.T) {
 	assert.Must(m2.Action.State == models.ActionNothing)
 }
 
+func TestSlotActionHashTag(x *testing.T) {
+	for _, hash := range [][2]string{
+		{models.SlotHashCRC16, models.DefaultHashTag},
+		{models.SlotHashCRC32, "<>"},
+		{models.SlotHashCRC32, ""},
+	} {
+		c := *config
+		c.SlotHash, c.HashTag = hash[0], hash[1]
+		t, err := New(newDiskClient(), &c)
+		assert.MustNoError(err)
+		assert.MustNoError(t.Start(false))
+
+		const sid = 100
+		m := &models.SlotMapping{Id: sid}
+		m.Action.State = models.ActionPending
+		m.Action.TargetId = 200
+		contextUpdateSlotMapping(t, m)
+
+		//proxy与codis-server计算的slot不同，不能迁移
+		_, _, err = t.SlotActionPrepare()
+		assert.Must(err != nil)
+		assert.Must(getSlotMapping(t, sid).Action.State == models.ActionPending)
+		t.Close()
+	}
+}
+
 func TestSlotActionPending(x *testing.T) {
 	t := openTopom()
 	defer t.Close()
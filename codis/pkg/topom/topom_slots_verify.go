@@ -0,0 +1,228 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type slotSample struct {
+	sid        int
+	from, dest string
+	//还没有迁移的key在源端最近一次读到的类型和值，按类型读取，不依赖DUMP
+	values map[string]*keyspaceDiffValue
+
+	report *models.SlotVerifyReport
+}
+
+func (s *Topom) slotMigrationEndpoints(sid int) (string, string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return "", "", false
+	}
+	m, err := ctx.getSlotMapping(sid)
+	if err != nil || m.Action.State != models.ActionMigrating {
+		return "", "", false
+	}
+	from := ctx.getGroupMaster(m.GroupId)
+	dest := ctx.getGroupMaster(m.Action.TargetId)
+	return from, dest, from != "" && dest != "" && from != dest
+}
+
+//迁移开始前在源端抽样，只抽样db0中的key
+func (s *Topom) sampleSlotMigration(sid int, n int) *slotSample {
+	from, dest, ok := s.slotMigrationEndpoints(sid)
+	if !ok {
+		return nil
+	}
+	sample := &slotSample{sid: sid, from: from, dest: dest, values: make(map[string]*keyspaceDiffValue)}
+
+	c, err := s.action.redisp.GetClient(from)
+	if err != nil {
+		log.WarnErrorf(err, "slot-[%d] sample keys from %s failed", sid, from)
+		return nil
+	}
+	defer s.action.redisp.PutClient(c, err)
+
+	keys, err := c.SlotsScan(sid, n)
+	if err != nil {
+		log.WarnErrorf(err, "slot-[%d] sample keys from %s failed", sid, from)
+		return nil
+	}
+	for _, key := range keys {
+		if len(sample.values) >= n {
+			break
+		}
+		v, err := readKeyspaceDiffValue(c, key, true)
+		if err != nil {
+			log.WarnErrorf(err, "slot-[%d] read key from %s failed", sid, from)
+			return nil
+		}
+		if v.pttl != -2 {
+			sample.values[key] = v
+		}
+	}
+	sample.report = &models.SlotVerifyReport{
+		Slot: sid, From: from, Dest: dest,
+		Sampled: len(sample.values),
+	}
+	return sample
+}
+
+//每轮迁移之后调用，源端仍然存在的key重新读取，源端已经不存在的key在目标端校验
+//迁移过程中key可能被修改，所以只和本轮迁移之前读到的值比较
+//SLOTSMGRT在目标端写入成功之后才删除源端的key，两端都不存在的key是被客户端删除或者过期了
+func (s *Topom) checkSlotMigration(sample *slotSample) error {
+	if len(sample.values) == 0 {
+		return nil
+	}
+	from, err := s.action.redisp.GetClient(sample.from)
+	if err != nil {
+		return err
+	}
+	defer s.action.redisp.PutClient(from, err)
+
+	dest, err := s.action.redisp.GetClient(sample.dest)
+	if err != nil {
+		return err
+	}
+	defer s.action.redisp.PutClient(dest, err)
+
+	var keys = make([]string, 0, len(sample.values))
+	for key := range sample.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v, err := readKeyspaceDiffValue(from, key, true)
+		if err != nil {
+			return err
+		}
+		if v.pttl != -2 {
+			sample.values[key] = v
+			continue
+		}
+		x, err := readKeyspaceDiffValue(dest, key, true)
+		if err != nil {
+			return err
+		}
+		r, last := sample.report, sample.values[key]
+		switch {
+		case x.pttl == -2:
+			r.Deleted++
+		case x.typ != last.typ || x.crc != last.crc:
+			r.Mismatched = append(r.Mismatched, key)
+		}
+		delete(sample.values, key)
+	}
+	return nil
+}
+
+//最后一轮迁移之后调用，源端仍然存在的key视为没有迁移到目标端
+//校验失败的结果写入store，dashboard重启之后仍然生效
+func (s *Topom) finishSlotVerify(sample *slotSample) (*models.SlotVerifyReport, error) {
+	if err := s.checkSlotMigration(sample); err != nil {
+		return nil, err
+	}
+	r := sample.report
+	for key := range sample.values {
+		r.Missing = append(r.Missing, key)
+	}
+	sort.Strings(r.Missing)
+	r.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+
+	s.verify.Lock()
+	defer s.verify.Unlock()
+	s.verify.slots[r.Slot] = r
+
+	if r.Failed() {
+		log.Errorf("slot-[%d] verify migration %s -> %s failed, missing = %d, mismatched = %d",
+			r.Slot, r.From, r.Dest, len(r.Missing), len(r.Mismatched))
+		if err := s.storeSlotVerifyFailures(); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+//需要持有verify锁
+func (s *Topom) storeSlotVerifyFailures() error {
+	var p = &models.SlotVerifyFailures{}
+	for _, r := range s.verify.slots {
+		if r.Failed() {
+			p.Reports = append(p.Reports, r)
+		}
+	}
+	sort.Sort(sliceSlotVerifyReport(p.Reports))
+	if err := s.store.UpdateSlotVerifyFailures(p); err != nil {
+		log.ErrorErrorf(err, "store: update slot verify failures failed")
+		return errors.Errorf("store: update slot verify failures failed")
+	}
+	return nil
+}
+
+//校验失败的slot保持迁移状态，直到调用SlotVerifyClear
+func (s *Topom) slotVerifyFailed(sid int) *models.SlotVerifyReport {
+	s.verify.Lock()
+	defer s.verify.Unlock()
+	if r := s.verify.slots[sid]; r != nil && r.Failed() {
+		return r
+	}
+	return nil
+}
+
+//确认校验失败的slot已经处理，下一轮迁移会完成该slot
+func (s *Topom) SlotVerifyClear(sid int) error {
+	if sid < 0 || sid >= MaxSlotNum {
+		return errors.Errorf("invalid slot id = %d", sid)
+	}
+	s.verify.Lock()
+	defer s.verify.Unlock()
+	r := s.verify.slots[sid]
+	if r == nil || !r.Failed() {
+		return errors.Errorf("slot-[%d] has no failed verify report", sid)
+	}
+	delete(s.verify.slots, sid)
+	if err := s.storeSlotVerifyFailures(); err != nil {
+		s.verify.slots[sid] = r
+		return err
+	}
+	log.Warnf("slot-[%d] verify report cleared", sid)
+	return nil
+}
+
+//返回slot最近一次的迁移校验结果，all为false时只返回校验失败的slot
+func (s *Topom) SlotVerifyReports(all bool) []*models.SlotVerifyReport {
+	s.verify.Lock()
+	defer s.verify.Unlock()
+	var list = make([]*models.SlotVerifyReport, 0, len(s.verify.slots))
+	for _, r := range s.verify.slots {
+		if all || r.Failed() {
+			list = append(list, r)
+		}
+	}
+	sort.Sort(sliceSlotVerifyReport(list))
+	return list
+}
+
+type sliceSlotVerifyReport []*models.SlotVerifyReport
+
+func (s sliceSlotVerifyReport) Len() int {
+	return len(s)
+}
+
+func (s sliceSlotVerifyReport) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+func (s sliceSlotVerifyReport) Less(i, j int) bool {
+	return s[i].Slot < s[j].Slot
+}
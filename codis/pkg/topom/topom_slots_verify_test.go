@@ -0,0 +1,108 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSlotVerifyMigration(x *testing.T) {
+	c := *config
+	c.MigrationVerifyKeys = 5
+	client := newDiskClient()
+	t, err := New(client, &c)
+	assert.MustNoError(err)
+	defer func() {
+		t.Close()
+	}()
+	assert.MustNoError(t.Start(false))
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{{Addr: s2.Addr}}})
+
+	const sid = 100
+	reset := func() {
+		m := &models.SlotMapping{Id: sid, GroupId: 1}
+		m.Action.State = models.ActionPending
+		m.Action.TargetId = 2
+		contextUpdateSlotMapping(t, m)
+	}
+
+	//key没有迁移到目标端，slot保持迁移状态
+	reset()
+	s1.SetKey("a", "1")
+	s1.PinKey("b", "2")
+	s2.SetKey("a", "1")
+	assert.Must(t.ProcessSlotAction() != nil)
+	m := getSlotMapping(t, sid)
+	assert.Must(m.Action.State == models.ActionMigrating && m.GroupId == 1)
+
+	list := t.SlotVerifyReports(false)
+	assert.Must(len(list) == 1 && list[0].Slot == sid && list[0].Sampled == 2)
+	assert.Must(len(list[0].Missing) == 1 && list[0].Missing[0] == "b")
+	assert.Must(len(list[0].Mismatched) == 0)
+
+	//校验失败的slot不会被重新校验后完成
+	assert.Must(t.ProcessSlotAction() != nil)
+	assert.Must(getSlotMapping(t, sid).Action.State == models.ActionMigrating)
+
+	//校验失败的结果保存在store中，重启之后仍然生效
+	p, err := t.store.LoadSlotVerifyFailures(true)
+	assert.MustNoError(err)
+	assert.Must(len(p.Reports) == 1 && p.Reports[0].Slot == sid)
+	assert.MustNoError(t.Close())
+	t, err = New(newForkClient(client), &c)
+	assert.MustNoError(err)
+	assert.MustNoError(t.Start(false))
+	assert.Must(t.slotVerifyFailed(sid) != nil)
+
+	assert.Must(t.SlotVerifyClear(sid + 1) != nil)
+	assert.MustNoError(t.SlotVerifyClear(sid))
+	p, err = t.store.LoadSlotVerifyFailures(true)
+	assert.MustNoError(err)
+	assert.Must(len(p.Reports) == 0)
+	s1.mu.Lock()
+	s1.pinned = nil
+	s1.mu.Unlock()
+	assert.MustNoError(t.ProcessSlotAction())
+	m = getSlotMapping(t, sid)
+	assert.Must(m.Action.State == models.ActionNothing && m.GroupId == 2)
+
+	//迁移期间被客户端删除的key不算缺失
+	reset()
+	s1.SetKey("a", "1")
+	s1.SetKey("b", "2")
+	s2.SetKey("a", "1")
+	assert.MustNoError(t.ProcessSlotAction())
+	list = t.SlotVerifyReports(true)
+	assert.Must(len(list) == 1 && !list[0].Failed() && list[0].Deleted == 1)
+
+	//值不一致
+	reset()
+	s2.SetKey("c", "1")
+	s2.SetKey("a", "1")
+	s1.SetKey("c", "3")
+	assert.Must(t.ProcessSlotAction() != nil)
+	list = t.SlotVerifyReports(false)
+	assert.Must(len(list) == 1 && len(list[0].Missing) == 0)
+	assert.Must(len(list[0].Mismatched) == 1 && list[0].Mismatched[0] == "c")
+	assert.MustNoError(t.SlotVerifyClear(sid))
+	assert.MustNoError(t.ProcessSlotAction())
+
+	//数据一致时直接完成
+	reset()
+	s1.SetKey("a", "1")
+	assert.MustNoError(t.ProcessSlotAction())
+	m = getSlotMapping(t, sid)
+	assert.Must(m.Action.State == models.ActionNothing && m.GroupId == 2)
+	assert.Must(len(t.SlotVerifyReports(false)) == 0 && len(t.SlotVerifyReports(true)) == 1)
+}
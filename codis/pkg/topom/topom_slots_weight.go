@@ -0,0 +1,218 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+const (
+	WeightByCustom = "custom"
+	WeightByMemory = "memory"
+	WeightByCPU    = "cpu"
+)
+
+//按权重分配slot，by为memory时使用master的maxmemory(没有设置时为物理内存)，
+//为cpu时使用master启动以来的cpu空闲比例，为custom时使用weights，没有指定的group权重为1
+//by为memory或cpu时weights中的值会乘到对应group的权重上，权重为0的group迁出所有slot
+type RebalanceWeights struct {
+	By      string          `json:"by"`
+	Weights map[int]float64 `json:"weights,omitempty"`
+}
+
+func (s *Topom) groupWeight(by string, addr string) (float64, error) {
+	if by == WeightByCustom || by == "" {
+		return 1, nil
+	}
+	info, err := s.action.redisp.InfoFull(addr)
+	if err != nil {
+		return 0, errors.Errorf("server-[%s] info failed: %s", addr, err)
+	}
+	var value = func(key string) float64 {
+		v, _ := strconv.ParseFloat(info[key], 64)
+		return v
+	}
+	switch by {
+	case WeightByMemory:
+		if v := value("maxmemory"); v > 0 {
+			return v, nil
+		}
+		if v := value("total_system_memory"); v > 0 {
+			return v, nil
+		}
+		return 0, errors.Errorf("server-[%s] has no maxmemory", addr)
+	case WeightByCPU:
+		var usage float64
+		if uptime := value("uptime_in_seconds"); uptime > 0 {
+			usage = (value("used_cpu_sys") + value("used_cpu_user")) / uptime
+		}
+		return math.Max(0.05, 1-usage), nil
+	}
+	return 0, errors.Errorf("invalid weight %s", by)
+}
+
+//按权重生成迁移计划，只迁出超出目标数量的slot，迁移的slot数最少
+func (s *Topom) SlotsWeightedPlan(w *RebalanceWeights) (*MigrationPlan, error) {
+	plans, weights, err := s.slotsRebalanceByWeight(w)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.newMigrationPlan(plans)
+	if err != nil {
+		return nil, err
+	}
+	p.WeightBy, p.Weights = w.By, weights
+	if p.WeightBy == "" {
+		p.WeightBy = WeightByCustom
+	}
+	s.storeMigrationPlan(p)
+
+	log.Warnf("weighted migration plan-[%d] created by %s, %d steps, %d slots", p.Id, p.WeightBy, len(p.Steps), p.Total.Slots)
+	return p, nil
+}
+
+func (s *Topom) slotsRebalanceByWeight(w *RebalanceWeights) (map[int]int, map[int]float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.MasterProduct != "" {
+		return nil, nil, errors.Errorf("dashboard cannot create slots action!")
+	}
+	if s.isStandby() {
+		return nil, nil, errors.Errorf("standby dashboard cannot create slots action!")
+	}
+
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var groupIds []int
+	for _, g := range ctx.group {
+		if len(g.Servers) != 0 {
+			groupIds = append(groupIds, g.Id)
+		}
+	}
+	sort.Ints(groupIds)
+
+	if len(groupIds) == 0 {
+		return nil, nil, errors.Errorf("no valid group could be found")
+	}
+	for gid := range w.Weights {
+		if g := ctx.group[gid]; g == nil || len(g.Servers) == 0 {
+			return nil, nil, errors.Errorf("group-[%d] doesn't exist or is empty", gid)
+		}
+	}
+
+	var weights = make(map[int]float64)
+	for _, gid := range groupIds {
+		weight, err := s.groupWeight(w.By, ctx.getGroupMaster(gid))
+		if err != nil {
+			return nil, nil, err
+		}
+		if v, ok := w.Weights[gid]; ok {
+			if v < 0 {
+				return nil, nil, errors.Errorf("invalid weight of group-[%d]", gid)
+			}
+			weight *= v
+		}
+		weights[gid] = weight
+	}
+
+	var (
+		owner = make(map[int]int)
+		fixed = make(map[int]int)
+	)
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing {
+			fixed[m.Action.TargetId]++
+		} else {
+			owner[m.Id] = m.GroupId
+		}
+	}
+	plans, err := planWeightedRebalance(groupIds, weights, owner, fixed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plans, weights, nil
+}
+
+//按权重计算每个group的slot数，小数部分按最大余数分配
+func weightedSlotTargets(groupIds []int, weights map[int]float64, total int) (map[int]int, error) {
+	var sum float64
+	for _, gid := range groupIds {
+		sum += weights[gid]
+	}
+	if sum <= 0 {
+		return nil, errors.Errorf("sum of group weights is zero")
+	}
+	var targets = make(map[int]int)
+	var order = make([]int, len(groupIds))
+	var fraction = make(map[int]float64)
+	var assigned int
+	for i, gid := range groupIds {
+		quota := float64(total) * weights[gid] / sum
+		targets[gid] = int(quota)
+		fraction[gid] = quota - float64(targets[gid])
+		assigned += targets[gid]
+		order[i] = gid
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return fraction[order[i]] > fraction[order[j]]
+	})
+	for i := 0; assigned < total; i++ {
+		targets[order[i%len(order)]]++
+		assigned++
+	}
+	return targets, nil
+}
+
+//超出目标数量的group迁出编号最大的slot，不属于任何group的slot也一起分配给不足的group
+func planWeightedRebalance(groupIds []int, weights map[int]float64, owner map[int]int, fixed map[int]int) (map[int]int, error) {
+	targets, err := weightedSlotTargets(groupIds, weights, MaxSlotNum)
+	if err != nil {
+		return nil, err
+	}
+	var slots = make(map[int][]int)
+	var docking []int
+	for sid, gid := range owner {
+		if _, ok := targets[gid]; ok {
+			slots[gid] = append(slots[gid], sid)
+		} else {
+			docking = append(docking, sid)
+		}
+	}
+
+	var current = make(map[int]int)
+	for _, gid := range groupIds {
+		current[gid] = fixed[gid] + len(slots[gid])
+		if n := current[gid] - targets[gid]; n > 0 {
+			sids := slots[gid]
+			sort.Sort(sort.Reverse(sort.IntSlice(sids)))
+			//正在迁入的slot不能再迁出
+			if n > len(sids) {
+				n = len(sids)
+			}
+			docking = append(docking, sids[:n]...)
+			current[gid] -= n
+		}
+	}
+	sort.Ints(docking)
+
+	var plans = make(map[int]int)
+	for _, gid := range groupIds {
+		for current[gid] < targets[gid] && len(docking) != 0 {
+			plans[docking[0]] = gid
+			docking = docking[1:]
+			current[gid]++
+		}
+	}
+	return plans, nil
+}
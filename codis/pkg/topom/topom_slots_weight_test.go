@@ -0,0 +1,101 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestWeightedSlotTargets(x *testing.T) {
+	targets, err := weightedSlotTargets([]int{1, 2, 3}, map[int]float64{1: 1, 2: 1, 3: 1}, MaxSlotNum)
+	assert.MustNoError(err)
+	assert.Must(targets[1]+targets[2]+targets[3] == MaxSlotNum)
+	assert.Must(targets[1] == 342 && targets[2] == 341 && targets[3] == 341)
+
+	targets, err = weightedSlotTargets([]int{1, 2}, map[int]float64{1: 1, 2: 3}, MaxSlotNum)
+	assert.MustNoError(err)
+	assert.Must(targets[1] == 256 && targets[2] == 768)
+
+	_, err = weightedSlotTargets([]int{1, 2}, map[int]float64{}, MaxSlotNum)
+	assert.Must(err != nil)
+}
+
+func TestPlanWeightedRebalance(x *testing.T) {
+	var owner = make(map[int]int)
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		owner[sid] = 1 + sid%2
+	}
+	//group 3权重为group 1和2的两倍，只迁出多出的slot
+	plans, err := planWeightedRebalance([]int{1, 2, 3}, map[int]float64{1: 1, 2: 1, 3: 2}, owner, nil)
+	assert.MustNoError(err)
+	assert.Must(len(plans) == 512)
+	var moved = make(map[int]int)
+	for sid, gid := range plans {
+		assert.Must(gid == 3)
+		moved[owner[sid]]++
+	}
+	assert.Must(moved[1] == 256 && moved[2] == 256)
+
+	//正在迁入的slot计入目标group，不属于任何group的slot也会分配
+	delete(owner, 0)
+	owner[1] = 0
+	plans, err = planWeightedRebalance([]int{1, 2}, map[int]float64{1: 1, 2: 1}, owner, map[int]int{1: 1})
+	assert.MustNoError(err)
+	assert.Must(len(plans) == 1 && plans[1] == 2)
+
+	//权重为0的group迁出所有slot
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		owner[sid] = 1 + sid%2
+	}
+	plans, err = planWeightedRebalance([]int{1, 2}, map[int]float64{1: 0, 2: 1}, owner, nil)
+	assert.MustNoError(err)
+	assert.Must(len(plans) == MaxSlotNum/2)
+}
+
+func TestSlotsWeightedPlan(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	contextCreateGroup(t, &models.Group{Id: 2, Servers: []*models.GroupServer{{Addr: s2.Addr}}})
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: 1})
+	}
+
+	_, err := t.SlotsWeightedPlan(&RebalanceWeights{By: "disk"})
+	assert.Must(err != nil)
+	_, err = t.SlotsWeightedPlan(&RebalanceWeights{Weights: map[int]float64{3: 1}})
+	assert.Must(err != nil)
+
+	p, err := t.SlotsWeightedPlan(&RebalanceWeights{Weights: map[int]float64{2: 3}})
+	assert.MustNoError(err)
+	assert.Must(p.WeightBy == WeightByCustom && p.Weights[1] == 1 && p.Weights[2] == 3)
+	assert.Must(len(p.Steps) == 1 && p.Steps[0].From == 1 && p.Steps[0].To == 2)
+	assert.Must(p.Total.Slots == 768)
+
+	steps, err := t.SlotsMigrationPlanApplyAll(p.Id)
+	assert.MustNoError(err)
+	assert.Must(len(steps) == 1 && steps[0].Applied)
+	_, err = t.SlotsMigrationPlanApplyAll(p.Id)
+	assert.Must(err != nil)
+
+	slots, err := t.store.SlotMappings()
+	assert.MustNoError(err)
+	var pending int
+	for _, m := range slots {
+		if m.Action.State == models.ActionPending {
+			assert.Must(m.Action.TargetId == 2 && m.Id >= 256)
+			pending++
+		}
+	}
+	assert.Must(pending == 768)
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+func (s *Topom) isStandby() bool {
+	return s.standby != nil && s.standby.Enabled
+}
+
+func (s *Topom) StandbyStatus() *models.Standby {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.standby == nil {
+		return &models.Standby{}
+	}
+	x := *s.standby
+	return &x
+}
+
+//将当前product标记为primary的只读备集群，proxy拒绝写命令，slot迁移被禁止直到promote
+func (s *Topom) SetStandby(primary string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if err := models.ValidateProduct(primary); err != nil {
+		return err
+	}
+	if primary == s.config.ProductName {
+		return errors.Errorf("standby of the same product-[%s]", primary)
+	}
+	if s.isStandby() {
+		return errors.Errorf("already standby of product-[%s]", s.standby.Primary)
+	}
+	for _, m := range ctx.slots {
+		if m.Action.State != models.ActionNothing {
+			return errors.Errorf("slot-[%d] action is not finished", m.Id)
+		}
+	}
+
+	p := &models.Standby{
+		Enabled: true, Primary: primary,
+		Since: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := s.storeUpdateStandby(p); err != nil {
+		return err
+	}
+	s.standby = p
+	log.Warnf("standby: product-[%s] becomes standby of product-[%s]", s.config.ProductName, primary)
+
+	return s.resyncReadOnly(ctx, true)
+}
+
+//提升为可写集群：停止所有group master的复制，proxy恢复写入
+func (s *Topom) PromoteStandby() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if !s.isStandby() {
+		return errors.Errorf("not standby")
+	}
+	primary := s.standby.Primary
+
+	for _, g := range models.SortGroup(ctx.group) {
+		if len(g.Servers) == 0 {
+			continue
+		}
+		if err := s.setReplicationMaster(g.Servers[0].Addr, "NO:ONE"); err != nil {
+			return err
+		}
+	}
+
+	//标记主product上的复制链路，避免主dashboard重新建立复制
+	store := models.NewStore(s.store.Client(), primary)
+	links, err := store.ListReplication()
+	if err != nil {
+		log.WarnErrorf(err, "standby: load replication of product-[%s] failed", primary)
+	} else if r := links[s.config.ProductName]; r != nil && r.State == models.ReplicationSyncing {
+		r.State = models.ReplicationPromoted
+		r.PromoteTime = time.Now().Format("2006-01-02 15:04:05")
+		if err := store.UpdateReplication(r); err != nil {
+			log.WarnErrorf(err, "standby: update replication of product-[%s] failed", primary)
+		}
+	}
+
+	p := &models.Standby{}
+	if err := s.storeUpdateStandby(p); err != nil {
+		return err
+	}
+	s.standby = p
+	log.Warnf("standby: product-[%s] promoted from standby of product-[%s]", s.config.ProductName, primary)
+
+	return s.resyncReadOnly(ctx, false)
+}
+
+func (s *Topom) resyncReadOnly(ctx *context, value bool) error {
+	for _, p := range ctx.proxy {
+		if err := s.newProxyClient(p).SetReadOnly(value); err != nil {
+			log.ErrorErrorf(err, "proxy-[%s] set readonly failed", p.Token)
+			return errors.Errorf("proxy-[%s] set readonly failed", p.Token)
+		}
+	}
+	return nil
+}
@@ -149,7 +149,7 @@ func (s *Topom) RefreshRedisStats(timeout time.Duration) (*sync2.Future, error)
 			if err != nil {
 				return nil, err
 			}
-			sentinel := redis.NewSentinel(s.config.ProductName, s.config.ProductAuth)
+			sentinel := s.newSentinel()
 			p, err := sentinel.MastersAndSlavesClient(c)
 			if err != nil {
 				return nil, err
@@ -5,8 +5,11 @@ package topom
 
 import (
 	"container/list"
+	"fmt"
 	"net"
+	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -125,6 +128,40 @@ type fakeServer struct {
 	net.Listener
 	list.List
 	Addr string
+
+	mu sync.Mutex
+	//SLOTSSCAN和DUMP使用的数据，SLOTSMGRTTAGSLOT会清空，pinned中的key除外
+	keys   map[string]string
+	pinned map[string]bool
+	random int
+	//追加到INFO的内容
+	info string
+}
+
+//模拟迁移失败留在源端的key
+func (s *fakeServer) PinKey(key, value string) {
+	s.SetKey(key, value)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pinned == nil {
+		s.pinned = make(map[string]bool)
+	}
+	s.pinned[key] = true
+}
+
+func (s *fakeServer) SetKey(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.keys == nil {
+		s.keys = make(map[string]string)
+	}
+	s.keys[key] = value
+}
+
+func (s *fakeServer) SetInfo(info string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info = info
 }
 
 func newFakeServer() *fakeServer {
@@ -166,16 +203,35 @@ func (s *fakeServer) Serve(c net.Conn) {
 		switch cmd := string(r.Array[0].Value); cmd {
 		case "SLOTSINFO":
 			resp = redis.NewArray([]*redis.Resp{})
-		case "AUTH":
+		case "AUTH", "SELECT":
 			resp = redis.NewBulkBytes([]byte("OK"))
+		case "PING":
+			resp = redis.NewString([]byte("PONG"))
 		case "INFO":
-			resp = redis.NewBulkBytes([]byte("#Fake Codis Server"))
+			if len(r.Array) == 2 && strings.ToLower(string(r.Array[1].Value)) == "keyspace" {
+				s.mu.Lock()
+				var n = len(s.keys)
+				s.mu.Unlock()
+				var text = "# Keyspace\r\n"
+				if n != 0 {
+					text += fmt.Sprintf("db0:keys=%d,expires=0,avg_ttl=0\r\n", n)
+				}
+				resp = redis.NewBulkBytes([]byte(text))
+			} else {
+				s.mu.Lock()
+				var text = "#Fake Codis Server\r\n" + s.info
+				s.mu.Unlock()
+				resp = redis.NewBulkBytes([]byte(text))
+			}
 		case "MULTI":
 			assert.Must(multi == 0)
 			multi++
 			continue
 		case "SLAVEOF", "CLIENT":
-			assert.Must(multi != 0)
+			if multi == 0 {
+				resp = redis.NewString([]byte("OK"))
+				break
+			}
 			multi++
 			continue
 		case "EXEC":
@@ -197,6 +253,8 @@ func (s *fakeServer) Serve(c net.Conn) {
 				key = string(r.Array[2].Value)
 			}
 			switch {
+			case sub == "SET" || sub == "REWRITE":
+				resp = redis.NewString([]byte("OK"))
 			case sub == "GET" && key == "maxmemory":
 				assert.Must(len(r.Array) == 3)
 				resp = redis.NewArray([]*redis.Resp{
@@ -206,11 +264,75 @@ func (s *fakeServer) Serve(c net.Conn) {
 			default:
 				log.Panicf("unknown subcommand of <%s>", cmd)
 			}
+		case "ROLE":
+			resp = redis.NewArray([]*redis.Resp{
+				redis.NewBulkBytes([]byte("master")),
+			})
 		case "SLOTSMGRTTAGSLOT":
+			s.mu.Lock()
+			for key := range s.keys {
+				if !s.pinned[key] {
+					delete(s.keys, key)
+				}
+			}
+			s.mu.Unlock()
 			resp = redis.NewArray([]*redis.Resp{
 				redis.NewInt([]byte("0")),
 				redis.NewInt([]byte("0")),
 			})
+		case "SLOTSSCAN":
+			var keys []*redis.Resp
+			s.mu.Lock()
+			for key := range s.keys {
+				keys = append(keys, redis.NewBulkBytes([]byte(key)))
+			}
+			s.mu.Unlock()
+			sort.Slice(keys, func(i, j int) bool {
+				return string(keys[i].Value) < string(keys[j].Value)
+			})
+			resp = redis.NewArray([]*redis.Resp{
+				redis.NewBulkBytes([]byte("0")),
+				redis.NewArray(keys),
+			})
+		case "RANDOMKEY":
+			//按顺序轮流返回，保证抽样能覆盖所有的key
+			resp = redis.NewBulkBytes(nil)
+			s.mu.Lock()
+			var keys []string
+			for key := range s.keys {
+				keys = append(keys, key)
+			}
+			if len(keys) != 0 {
+				sort.Strings(keys)
+				resp = redis.NewBulkBytes([]byte(keys[s.random%len(keys)]))
+				s.random++
+			}
+			s.mu.Unlock()
+		case "TYPE", "PTTL":
+			assert.Must(len(r.Array) == 2)
+			s.mu.Lock()
+			_, ok := s.keys[string(r.Array[1].Value)]
+			s.mu.Unlock()
+			switch {
+			case cmd == "TYPE" && ok:
+				resp = redis.NewString([]byte("string"))
+			case cmd == "TYPE":
+				resp = redis.NewString([]byte("none"))
+			case ok:
+				resp = redis.NewInt([]byte("-1"))
+			default:
+				resp = redis.NewInt([]byte("-2"))
+			}
+		case "DUMP", "GET":
+			assert.Must(len(r.Array) == 2)
+			s.mu.Lock()
+			value, ok := s.keys[string(r.Array[1].Value)]
+			s.mu.Unlock()
+			if ok {
+				resp = redis.NewBulkBytes([]byte(value))
+			} else {
+				resp = redis.NewBulkBytes(nil)
+			}
 		default:
 			log.Panicf("unknown command <%s>", cmd)
 		}
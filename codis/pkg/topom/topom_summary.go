@@ -0,0 +1,114 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"github.com/CodisLabs/codis/pkg/models"
+)
+
+//集群关键健康指标的摘要，供fe在一个页面中展示多个集群
+type Summary struct {
+	ProductName string `json:"product_name"`
+	Online      bool   `json:"online"`
+	Closed      bool   `json:"closed"`
+
+	Ops struct {
+		QPS         int64   `json:"qps"`
+		Total       int64   `json:"total"`
+		Fails       int64   `json:"fails"`
+		RedisErrors int64   `json:"redis_errors"`
+		ErrorRate   float64 `json:"error_rate"`
+	} `json:"ops"`
+
+	Proxy struct {
+		Total   int `json:"total"`
+		Healthy int `json:"healthy"`
+	} `json:"proxy"`
+
+	Group struct {
+		Total int `json:"total"`
+		//非healthy的group，按健康状态计数
+		Unhealthy map[string]int `json:"unhealthy,omitempty"`
+	} `json:"group"`
+
+	Migration struct {
+		Pending   int    `json:"pending"`
+		Migrating int    `json:"migrating"`
+		Disabled  bool   `json:"disabled"`
+		Status    string `json:"status,omitempty"`
+	} `json:"migration"`
+
+	HA struct {
+		Sentinels   int  `json:"sentinels"`
+		Unreachable int  `json:"unreachable"`
+		Masters     int  `json:"masters"`
+		OutOfSync   bool `json:"out_of_sync"`
+	} `json:"ha"`
+}
+
+func (s *Topom) Summary() (*Summary, error) {
+	stats, err := s.Stats()
+	if err != nil {
+		return nil, err
+	}
+	x := summarizeStats(stats)
+	x.ProductName = s.config.ProductName
+	x.Online = s.IsOnline()
+	return x, nil
+}
+
+func summarizeStats(stats *Stats) *Summary {
+	var x = &Summary{Closed: stats.Closed}
+
+	for _, p := range stats.Proxy.Models {
+		v := stats.Proxy.Stats[p.Token]
+		x.Proxy.Total++
+		if proxyHealth(v) != ProxyHealthy {
+			continue
+		}
+		x.Proxy.Healthy++
+		x.Ops.QPS += v.Stats.Ops.QPS
+		x.Ops.Total += v.Stats.Ops.Total
+		x.Ops.Fails += v.Stats.Ops.Fails
+		x.Ops.RedisErrors += v.Stats.Ops.Redis.Errors
+	}
+	if x.Ops.Total != 0 {
+		x.Ops.ErrorRate = float64(x.Ops.Fails) / float64(x.Ops.Total)
+	}
+
+	x.Group.Total = len(stats.Group.Models)
+	for _, h := range stats.Group.Health {
+		if h == GroupHealthy {
+			continue
+		}
+		if x.Group.Unhealthy == nil {
+			x.Group.Unhealthy = make(map[string]int)
+		}
+		x.Group.Unhealthy[h]++
+	}
+
+	for _, m := range stats.Slots {
+		switch m.Action.State {
+		case models.ActionNothing:
+		case models.ActionPending:
+			x.Migration.Pending++
+		default:
+			x.Migration.Migrating++
+		}
+	}
+	x.Migration.Disabled = stats.SlotAction.Disabled != 0
+	x.Migration.Status = stats.SlotAction.Progress.Status
+
+	if m := stats.HA.Model; m != nil {
+		x.HA.Sentinels = len(m.Servers)
+		x.HA.OutOfSync = m.OutOfSync
+		for _, server := range m.Servers {
+			if v := stats.HA.Stats[server]; v == nil || v.Error != nil || v.Timeout {
+				x.HA.Unreachable++
+			}
+		}
+	}
+	x.HA.Masters = len(stats.HA.Masters)
+	return x
+}
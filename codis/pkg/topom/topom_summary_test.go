@@ -0,0 +1,42 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+	"github.com/CodisLabs/codis/pkg/utils/rpc"
+)
+
+func TestSummarizeStats(x *testing.T) {
+	stats := &Stats{}
+	stats.Proxy.Models = []*models.Proxy{{Token: "a"}, {Token: "b"}, {Token: "c"}}
+	stats.Proxy.Stats = map[string]*ProxyStats{
+		"a": {Stats: &proxy.Stats{Online: true}},
+		"b": {Error: &rpc.RemoteError{}},
+	}
+	stats.Proxy.Stats["a"].Stats.Ops.Total = 100
+	stats.Proxy.Stats["a"].Stats.Ops.Fails = 5
+	stats.Proxy.Stats["a"].Stats.Ops.QPS = 10
+
+	stats.Group.Models = []*models.Group{{Id: 1}, {Id: 2}}
+	stats.Group.Health = map[string]string{"1": GroupHealthy, "2": GroupMasterDown}
+
+	stats.Slots = []*models.SlotMapping{{Id: 0}, {Id: 1}, {Id: 2}}
+	stats.Slots[1].Action.State = models.ActionPending
+	stats.Slots[2].Action.State = models.ActionMigrating
+
+	stats.HA.Model = &models.Sentinel{Servers: []string{"s1", "s2"}}
+	stats.HA.Stats = map[string]*RedisStats{"s1": {}}
+
+	s := summarizeStats(stats)
+	assert.Must(s.Proxy.Total == 3 && s.Proxy.Healthy == 1)
+	assert.Must(s.Ops.QPS == 10 && s.Ops.Total == 100 && s.Ops.ErrorRate == 0.05)
+	assert.Must(s.Group.Total == 2 && s.Group.Unhealthy[GroupMasterDown] == 1)
+	assert.Must(s.Migration.Pending == 1 && s.Migration.Migrating == 1)
+	assert.Must(s.HA.Sentinels == 2 && s.HA.Unreachable == 1)
+}
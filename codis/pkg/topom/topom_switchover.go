@@ -0,0 +1,215 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"net"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type SwitchoverStatus struct {
+	Product    string `json:"product,omitempty"`
+	SwitchTime string `json:"switch_time,omitempty"`
+}
+
+//加载目标product的拓扑，生成用于下发给proxy的context，proxy仍为当前product的proxy
+func (s *Topom) newSwitchoverContext(ctx *context, product string) (*context, error) {
+	store := models.NewStore(s.store.Client(), product)
+	slots, err := store.SlotMappings()
+	if err != nil {
+		return nil, err
+	}
+	group, err := store.ListGroup()
+	if err != nil {
+		return nil, err
+	}
+	x := &context{}
+	x.slots = slots
+	x.group = group
+	x.proxy = ctx.proxy
+	x.sentinel = ctx.sentinel
+	x.hosts.m = make(map[string]net.IP)
+	x.method = ctx.method
+	return x, nil
+}
+
+func (s *Topom) switchoverProduct() string {
+	if s.switchover == nil {
+		return ""
+	}
+	return s.switchover.Product
+}
+
+func (s *Topom) storeUpdateSwitchover(p *models.Switchover) error {
+	if err := s.store.UpdateSwitchover(p); err != nil {
+		log.ErrorErrorf(err, "store: update switchover failed")
+		return errors.Errorf("store: update switchover failed")
+	}
+	s.switchover = p
+	return nil
+}
+
+//切换期间下发给proxy的slot均来自目标product
+func (s *Topom) proxyContext(ctx *context, slots []*models.SlotMapping) (*context, []*models.SlotMapping, error) {
+	product := s.switchoverProduct()
+	if product == "" {
+		return ctx, slots, nil
+	}
+	x, err := s.newSwitchoverContext(ctx, product)
+	if err != nil {
+		return nil, nil, err
+	}
+	var mappings = make([]*models.SlotMapping, 0, len(slots))
+	for _, m := range slots {
+		mappings = append(mappings, x.slots[m.Id])
+	}
+	return x, mappings, nil
+}
+
+//检查目标product的所有slot都已分配，且每个group的master可用
+func (s *Topom) verifySwitchoverTarget(x *context, product string) error {
+	for _, m := range x.slots {
+		if m.GroupId == 0 {
+			return errors.Errorf("product-[%s] slot-[%d] is offline", product, m.Id)
+		}
+		if m.Action.State != models.ActionNothing {
+			return errors.Errorf("product-[%s] slot-[%d] action is not finished", product, m.Id)
+		}
+	}
+	for _, g := range x.group {
+		if len(g.Servers) == 0 {
+			continue
+		}
+		addr := g.Servers[0].Addr
+		c, err := s.action.redisp.GetClient(addr)
+		if err != nil {
+			return errors.Errorf("product-[%s] server-[%s] is unreachable: %s", product, addr, err)
+		}
+		role, err := c.Role()
+		s.action.redisp.PutClient(c, err)
+		if err != nil {
+			return errors.Errorf("product-[%s] server-[%s] role failed: %s", product, addr, err)
+		}
+		if role != "MASTER" {
+			return errors.Errorf("product-[%s] server-[%s] is not master, role = %s", product, addr, role)
+		}
+	}
+	return nil
+}
+
+//下发slot后从proxy读回，校验所有proxy的后端地址与期望一致
+func (s *Topom) fillAndVerifyProxies(x *context) error {
+	if err := s.resyncSlotMappingsDirect(x, x.slots...); err != nil {
+		return err
+	}
+	for _, p := range x.proxy {
+		slots, err := s.newProxyClient(p).Slots()
+		if err != nil {
+			return errors.Errorf("proxy-[%s] get slots failed: %s", p.Token, err)
+		}
+		if len(slots) != len(x.slots) {
+			return errors.Errorf("proxy-[%s] has %d slots, expected %d", p.Token, len(slots), len(x.slots))
+		}
+		for _, m := range x.slots {
+			if addr := x.toSlot(m, p).BackendAddr; slots[m.Id].BackendAddr != addr {
+				return errors.Errorf("proxy-[%s] slot-[%d] backend = %s, expected %s",
+					p.Token, m.Id, slots[m.Id].BackendAddr, addr)
+			}
+		}
+	}
+	return nil
+}
+
+//将proxy切换到目标product的后端，校验失败时自动回滚。切换状态保存在协调服务中，dashboard重启后继续使用目标product的拓扑
+func (s *Topom) SwitchoverStart(product string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if err := models.ValidateProduct(product); err != nil {
+		return err
+	}
+	if product == s.config.ProductName {
+		return errors.Errorf("switchover to the same product-[%s]", product)
+	}
+	if current := s.switchoverProduct(); current != "" {
+		return errors.Errorf("already switched to product-[%s]", current)
+	}
+	if len(ctx.proxy) == 0 {
+		return errors.Errorf("no proxy to switch")
+	}
+
+	x, err := s.newSwitchoverContext(ctx, product)
+	if err != nil {
+		return err
+	}
+	if err := s.verifySwitchoverTarget(x, product); err != nil {
+		return err
+	}
+
+	log.Warnf("switchover: switch proxies to product-[%s]", product)
+	//先保存切换状态，下发过程中dashboard退出时重启后仍使用目标product
+	p := &models.Switchover{
+		Product: product, SwitchTime: time.Now().Format("2006-01-02 15:04:05"),
+	}
+	if err := s.storeUpdateSwitchover(p); err != nil {
+		return err
+	}
+	if err := s.fillAndVerifyProxies(x); err != nil {
+		log.ErrorErrorf(err, "switchover: switch to product-[%s] failed, rollback", product)
+		if err := s.storeUpdateSwitchover(&models.Switchover{}); err != nil {
+			return err
+		}
+		if err := s.fillAndVerifyProxies(ctx); err != nil {
+			log.ErrorErrorf(err, "switchover: rollback failed")
+		}
+		return err
+	}
+	log.Warnf("switchover: switch proxies to product-[%s] done", product)
+	return nil
+}
+
+//将proxy恢复为当前product的后端
+func (s *Topom) SwitchoverRollback() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	product := s.switchoverProduct()
+	if product == "" {
+		return errors.Errorf("not switched")
+	}
+	log.Warnf("switchover: rollback proxies from product-[%s]", product)
+	if err := s.storeUpdateSwitchover(&models.Switchover{}); err != nil {
+		return err
+	}
+	if err := s.fillAndVerifyProxies(ctx); err != nil {
+		log.ErrorErrorf(err, "switchover: rollback failed")
+		return err
+	}
+	log.Warnf("switchover: rollback done")
+	return nil
+}
+
+func (s *Topom) SwitchoverStatus() *SwitchoverStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.switchoverProduct() == "" {
+		return &SwitchoverStatus{}
+	}
+	return &SwitchoverStatus{
+		Product:    s.switchover.Product,
+		SwitchTime: s.switchover.SwitchTime,
+	}
+}
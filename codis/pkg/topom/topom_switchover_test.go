@@ -0,0 +1,76 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/proxy"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func checkProxyBackend(c *proxy.ApiClient, addr string) {
+	slots, err := c.Slots()
+	assert.MustNoError(err)
+	assert.Must(len(slots) == MaxSlotNum)
+	for _, slot := range slots {
+		assert.Must(slot.BackendAddr == addr)
+	}
+}
+
+func TestSwitchover(x *testing.T) {
+	client := newDiskClient()
+	t, err := New(newForkClient(client), config)
+	assert.MustNoError(err)
+	assert.MustNoError(t.Start(false))
+
+	s1 := newFakeServer()
+	defer s1.Close()
+	s2 := newFakeServer()
+	defer s2.Close()
+
+	contextCreateGroup(t, &models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s1.Addr}}})
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		contextUpdateSlotMapping(t, &models.SlotMapping{Id: sid, GroupId: 1})
+	}
+
+	const product = "topom_switchover"
+	store := models.NewStore(client, product)
+	assert.MustNoError(store.UpdateGroup(&models.Group{Id: 1, Servers: []*models.GroupServer{{Addr: s2.Addr}}}))
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		assert.MustNoError(store.UpdateSlotMapping(&models.SlotMapping{Id: sid, GroupId: 1}))
+	}
+
+	assert.Must(t.SwitchoverStart(product) != nil)
+
+	p, c := openProxy()
+	defer c.Shutdown()
+	assert.MustNoError(t.CreateProxy(p.AdminAddr))
+	checkProxyBackend(c, s1.Addr)
+
+	assert.Must(t.SwitchoverStart(config.ProductName) != nil)
+	assert.Must(t.SwitchoverRollback() != nil)
+	assert.MustNoError(t.SwitchoverStart(product))
+	assert.Must(t.SwitchoverStatus().Product == product)
+	assert.Must(t.SwitchoverStart(product) != nil)
+	checkProxyBackend(c, s2.Addr)
+
+	//dashboard重启后继续使用目标product的拓扑
+	t.Close()
+	t, err = New(newForkClient(client), config)
+	assert.MustNoError(err)
+	defer t.Close()
+	assert.MustNoError(t.Start(false))
+	assert.Must(t.SwitchoverStatus().Product == product)
+	assert.MustNoError(t.ReinitProxy(p.Token))
+	checkProxyBackend(c, s2.Addr)
+
+	assert.MustNoError(t.SwitchoverRollback())
+	assert.Must(t.SwitchoverStatus().Product == "")
+	checkProxyBackend(c, s1.Addr)
+	w, err := t.store.LoadSwitchover(true)
+	assert.MustNoError(err)
+	assert.Must(w.Product == "")
+}
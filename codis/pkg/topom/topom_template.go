@@ -0,0 +1,195 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+type GroupConfigDrift struct {
+	Id       int    `json:"id"`
+	Template string `json:"template"`
+	//只包含与模板不一致的server，Config中为实际值
+	Servers []*ServerConfig `json:"servers"`
+}
+
+func (s *Topom) ListConfigTemplate() ([]*models.ConfigTemplate, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, ErrClosedTopom
+	}
+	m, err := s.store.ListTemplate()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var list = make([]*models.ConfigTemplate, 0, len(names))
+	for _, name := range names {
+		list = append(list, m[name])
+	}
+	return list, nil
+}
+
+func (s *Topom) UpdateConfigTemplate(t *models.ConfigTemplate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrClosedTopom
+	}
+	if !regexp.MustCompile(`^\w[\w\.\-]*$`).MatchString(t.Name) {
+		return errors.Errorf("invalid template name = %s", t.Name)
+	}
+	if len(t.Config) == 0 {
+		return errors.Errorf("template-[%s] is empty", t.Name)
+	}
+	var config = make(map[string]string, len(t.Config))
+	for key, value := range t.Config {
+		key = strings.ToLower(strings.TrimSpace(key))
+		if key == "" {
+			return errors.Errorf("template-[%s] has empty config key", t.Name)
+		}
+		config[key] = value
+	}
+	return s.storeUpdateTemplate(&models.ConfigTemplate{Name: t.Name, Config: config})
+}
+
+func (s *Topom) RemoveConfigTemplate(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	t, err := s.store.LoadTemplate(name, false)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return errors.Errorf("template-[%s] doesn't exist", name)
+	}
+	for _, g := range ctx.group {
+		if g.Template == name {
+			return errors.Errorf("template-[%s] is still used by group-[%d]", name, g.Id)
+		}
+	}
+	return s.storeRemoveTemplate(t)
+}
+
+//将模板应用到group内的所有server，全部成功后记录group使用的模板
+func (s *Topom) GroupApplyTemplate(gid int, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	g, err := ctx.getGroup(gid)
+	if err != nil {
+		return err
+	}
+	if len(g.Servers) == 0 {
+		return errors.Errorf("group-[%d] is empty", g.Id)
+	}
+	if g.Promoting.State != models.ActionNothing {
+		return errors.Errorf("group-[%d] is promoting", g.Id)
+	}
+
+	t, err := s.store.LoadTemplate(name, false)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		return errors.Errorf("template-[%s] doesn't exist", name)
+	}
+
+	if err := s.groupConfigSet(g, templateKeys(t), t.Config); err != nil {
+		log.ErrorErrorf(err, "group-[%d] apply template-[%s] failed", g.Id, t.Name)
+		return err
+	}
+	defer s.dirtyGroupCache(g.Id)
+
+	g.Template = t.Name
+	return s.storeUpdateGroup(g)
+}
+
+//检查所有使用了模板的group，返回与模板配置不一致的server
+func (s *Topom) ConfigTemplateDrift() ([]*GroupConfigDrift, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+
+	templates, err := s.store.ListTemplate()
+	if err != nil {
+		return nil, err
+	}
+
+	var drifts []*GroupConfigDrift
+	for _, g := range models.SortGroup(ctx.group) {
+		if g.Template == "" {
+			continue
+		}
+		d := &GroupConfigDrift{Id: g.Id, Template: g.Template}
+		t := templates[g.Template]
+		if t == nil {
+			for _, x := range g.Servers {
+				d.Servers = append(d.Servers, &ServerConfig{
+					Server: x.Addr, Error: "template doesn't exist",
+				})
+			}
+		} else {
+			keys := templateKeys(t)
+			for _, sc := range s.groupConfigGet(g, keys) {
+				if diff := diffTemplateConfig(t, sc); diff != nil {
+					d.Servers = append(d.Servers, diff)
+				}
+			}
+		}
+		if len(d.Servers) != 0 {
+			drifts = append(drifts, d)
+		}
+	}
+	return drifts, nil
+}
+
+func templateKeys(t *models.ConfigTemplate) []string {
+	var keys = make([]string, 0, len(t.Config))
+	for key := range t.Config {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//返回server与模板不一致的配置项，一致则返回nil
+func diffTemplateConfig(t *models.ConfigTemplate, sc *ServerConfig) *ServerConfig {
+	if sc.Error != "" {
+		return sc
+	}
+	var diff = make(map[string]string)
+	for key, value := range t.Config {
+		if v := sc.Config[key]; !isConfigEqual(v, value) {
+			diff[key] = v
+		}
+	}
+	if len(diff) == 0 {
+		return nil
+	}
+	return &ServerConfig{Server: sc.Server, Config: diff}
+}
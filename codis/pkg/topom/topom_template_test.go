@@ -0,0 +1,27 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestDiffTemplateConfig(x *testing.T) {
+	t := &models.ConfigTemplate{Name: "default", Config: map[string]string{
+		"appendonly": "yes", "timeout": "300",
+	}}
+	assert.Must(templateKeys(t)[0] == "appendonly")
+
+	sc := &ServerConfig{Server: "127.0.0.1:6379", Config: map[string]string{
+		"appendonly": "yes", "timeout": "300",
+	}}
+	assert.Must(diffTemplateConfig(t, sc) == nil)
+
+	sc.Config["timeout"] = "0"
+	d := diffTemplateConfig(t, sc)
+	assert.Must(d != nil && len(d.Config) == 1 && d.Config["timeout"] == "0")
+}
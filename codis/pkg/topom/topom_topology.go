@@ -0,0 +1,440 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+//集群拓扑的期望状态，为nil的部分不做管理，保持现状；为空列表时表示删除全部
+type DesiredState struct {
+	//每个group的第一个server为master
+	Groups []*DesiredGroup `json:"groups"`
+	//没有覆盖到的slot保持现状
+	Slots []*DesiredSlotRange `json:"slots"`
+	//proxy的admin地址
+	Proxies   []string `json:"proxies"`
+	Sentinels []string `json:"sentinels"`
+}
+
+type DesiredGroup struct {
+	Id      int      `json:"id"`
+	Servers []string `json:"servers"`
+}
+
+type DesiredSlotRange struct {
+	Begin   int `json:"begin"`
+	End     int `json:"end"`
+	GroupId int `json:"group_id"`
+}
+
+const (
+	TopologyCreateGroup   = "create-group"
+	TopologyAddServer     = "add-server"
+	TopologyPromoteServer = "promote-server"
+	TopologyCreateProxy   = "create-proxy"
+	TopologyAddSentinel   = "add-sentinel"
+	TopologyAssignSlots   = "assign-slots"
+	TopologyMigrateSlots  = "migrate-slots"
+	TopologyDelServer     = "del-server"
+	TopologyRemoveProxy   = "remove-proxy"
+	TopologyDelSentinel   = "del-sentinel"
+	TopologyRemoveGroup   = "remove-group"
+)
+
+//收敛到期望状态的一个操作，按Id的顺序执行
+type TopologyOp struct {
+	Id      int    `json:"id"`
+	Action  string `json:"action"`
+	GroupId int    `json:"group_id,omitempty"`
+	Addr    string `json:"addr,omitempty"`
+	Token   string `json:"token,omitempty"`
+	From    int    `json:"from,omitempty"`
+	Slots   []int  `json:"slots,omitempty"`
+
+	Applied bool   `json:"applied"`
+	Error   string `json:"error,omitempty"`
+}
+
+//期望状态与当前拓扑的差异，确认后通过apply执行
+//slot迁移是异步的，迁出slot的group需要等迁移完成后重新plan、apply才能删除
+type TopologyPlan struct {
+	Id         int    `json:"id"`
+	CreateTime string `json:"create_time"`
+	ApplyTime  string `json:"apply_time,omitempty"`
+
+	Desired *DesiredState `json:"desired"`
+	Ops     []*TopologyOp `json:"ops"`
+}
+
+const maxTopologyPlans = 16
+
+//生成收敛到期望状态的操作列表，不会修改拓扑
+func (s *Topom) TopologyPlan(d *DesiredState) (*TopologyPlan, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return nil, err
+	}
+	ops, err := planTopology(ctx, d)
+	if err != nil {
+		return nil, err
+	}
+	p := &TopologyPlan{
+		CreateTime: time.Now().Format("2006-01-02 15:04:05"),
+		Desired:    d, Ops: ops,
+	}
+	s.storeTopologyPlan(p)
+
+	log.Warnf("topology plan-[%d] created, %d ops", p.Id, len(p.Ops))
+	return p, nil
+}
+
+func (s *Topom) storeTopologyPlan(p *TopologyPlan) {
+	s.topologyPlans.Lock()
+	defer s.topologyPlans.Unlock()
+	s.topologyPlans.nextId++
+	p.Id = s.topologyPlans.nextId
+	s.topologyPlans.list = append(s.topologyPlans.list, p)
+	if n := len(s.topologyPlans.list) - maxTopologyPlans; n > 0 {
+		s.topologyPlans.list = s.topologyPlans.list[n:]
+	}
+}
+
+//调用者需要持有topologyPlans锁
+func (s *Topom) findTopologyPlan(pid int) (*TopologyPlan, error) {
+	for _, p := range s.topologyPlans.list {
+		if p.Id == pid {
+			return p, nil
+		}
+	}
+	return nil, errors.Errorf("topology plan-[%d] doesn't exist", pid)
+}
+
+func (s *Topom) GetTopologyPlan(pid int) (*TopologyPlan, error) {
+	s.topologyPlans.Lock()
+	defer s.topologyPlans.Unlock()
+	return s.findTopologyPlan(pid)
+}
+
+//按顺序执行计划中的操作，遇到错误时停止，拓扑在计划生成后发生变化时拒绝执行
+func (s *Topom) TopologyApply(pid int) (*TopologyPlan, error) {
+	s.topologyPlans.Lock()
+	defer s.topologyPlans.Unlock()
+
+	p, err := s.findTopologyPlan(pid)
+	if err != nil {
+		return nil, err
+	}
+	if p.ApplyTime != "" {
+		return nil, errors.Errorf("topology plan-[%d] has been applied", pid)
+	}
+
+	s.mu.Lock()
+	ctx, err := s.newContext()
+	if err != nil {
+		s.mu.Unlock()
+		return nil, err
+	}
+	ops, err := planTopology(ctx, p.Desired)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	a, _ := json.Marshal(ops)
+	b, _ := json.Marshal(p.Ops)
+	if string(a) != string(b) {
+		return nil, errors.Errorf("topology has changed since plan-[%d] was created", pid)
+	}
+
+	p.ApplyTime = time.Now().Format("2006-01-02 15:04:05")
+	for _, op := range p.Ops {
+		if err := s.applyTopologyOp(op); err != nil {
+			op.Error = err.Error()
+			log.WarnErrorf(err, "topology plan-[%d] op %d %s failed", pid, op.Id, op.Action)
+			return p, errors.Errorf("op %d of topology plan-[%d] failed: %s", op.Id, pid, err)
+		}
+		op.Applied = true
+		log.Warnf("topology plan-[%d] op %d %s applied", pid, op.Id, op.Action)
+	}
+	return p, nil
+}
+
+func (s *Topom) applyTopologyOp(op *TopologyOp) error {
+	switch op.Action {
+	case TopologyCreateGroup:
+		return s.CreateGroup(op.GroupId)
+	case TopologyAddServer:
+		return s.GroupAddServer(op.GroupId, "", op.Addr)
+	case TopologyPromoteServer:
+		return s.GroupPromoteServer(op.GroupId, op.Addr, false)
+	case TopologyCreateProxy:
+		return s.CreateProxy(op.Addr)
+	case TopologyAddSentinel:
+		return s.AddSentinel(op.Addr)
+	case TopologyAssignSlots:
+		var slots []*models.SlotMapping
+		for _, sid := range op.Slots {
+			slots = append(slots, &models.SlotMapping{Id: sid, GroupId: op.GroupId})
+		}
+		return s.SlotsAssignGroup(slots)
+	case TopologyMigrateSlots:
+		for _, sid := range op.Slots {
+			if err := s.SlotCreateAction(sid, op.GroupId); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TopologyDelServer:
+		return s.GroupDelServer(op.GroupId, op.Addr)
+	case TopologyRemoveProxy:
+		return s.RemoveProxy(op.Token, false)
+	case TopologyDelSentinel:
+		return s.DelSentinel(op.Addr, false)
+	case TopologyRemoveGroup:
+		return s.RemoveGroup(op.GroupId)
+	default:
+		return errors.Errorf("invalid topology action = %s", op.Action)
+	}
+}
+
+//操作顺序：先创建group、server、proxy、sentinel，再切换master、分配或迁移slot，最后删除多余的部分
+func planTopology(ctx *context, d *DesiredState) ([]*TopologyOp, error) {
+	var ops []*TopologyOp
+	add := func(op *TopologyOp) {
+		op.Id = len(ops) + 1
+		ops = append(ops, op)
+	}
+
+	var groups = make(map[int]*DesiredGroup)
+	var servers = make(map[string]int)
+	for _, g := range d.Groups {
+		if g.Id <= 0 || g.Id > models.MaxGroupId {
+			return nil, errors.Errorf("invalid group id = %d", g.Id)
+		}
+		if groups[g.Id] != nil {
+			return nil, errors.Errorf("duplicate group-[%d]", g.Id)
+		}
+		groups[g.Id] = g
+		for _, addr := range g.Servers {
+			if gid, ok := servers[addr]; ok {
+				return nil, errors.Errorf("server-[%s] belongs to group-[%d] and group-[%d]", addr, gid, g.Id)
+			}
+			servers[addr] = g.Id
+		}
+	}
+	for addr, gid := range servers {
+		if g, _, err := ctx.getGroupByServer(addr); err == nil && g.Id != gid {
+			return nil, errors.Errorf("server-[%s] already exists in group-[%d]", addr, g.Id)
+		}
+	}
+
+	var target = make(map[int]int)
+	for _, r := range d.Slots {
+		if r.Begin < 0 || r.End >= MaxSlotNum || r.Begin > r.End {
+			return nil, errors.Errorf("invalid slot range [%d, %d]", r.Begin, r.End)
+		}
+		if _, ok := groups[r.GroupId]; !ok && (d.Groups != nil || ctx.group[r.GroupId] == nil) {
+			return nil, errors.Errorf("slot range [%d, %d] assigned to unknown group-[%d]", r.Begin, r.End, r.GroupId)
+		}
+		if g := groups[r.GroupId]; g != nil && len(g.Servers) == 0 {
+			return nil, errors.Errorf("slot range [%d, %d] assigned to empty group-[%d]", r.Begin, r.End, r.GroupId)
+		}
+		for sid := r.Begin; sid <= r.End; sid++ {
+			if _, ok := target[sid]; ok {
+				return nil, errors.Errorf("slot-[%d] is assigned more than once", sid)
+			}
+			target[sid] = r.GroupId
+		}
+	}
+
+	//创建group与server，新server加入后按期望的顺序切换master
+	var gids []int
+	for gid := range groups {
+		gids = append(gids, gid)
+	}
+	sort.Ints(gids)
+	for _, gid := range gids {
+		g, current := groups[gid], ctx.group[gid]
+		if current == nil {
+			add(&TopologyOp{Action: TopologyCreateGroup, GroupId: gid})
+		}
+		var exists = make(map[string]bool)
+		if current != nil {
+			for _, x := range current.Servers {
+				exists[x.Addr] = true
+			}
+		}
+		for _, addr := range g.Servers {
+			if !exists[addr] {
+				add(&TopologyOp{Action: TopologyAddServer, GroupId: gid, Addr: addr})
+			}
+		}
+		if current != nil && len(current.Servers) != 0 && len(g.Servers) != 0 {
+			if master := g.Servers[0]; current.Servers[0].Addr != master {
+				add(&TopologyOp{Action: TopologyPromoteServer, GroupId: gid, Addr: master})
+			}
+		}
+	}
+
+	var proxies = make(map[string]*models.Proxy)
+	for _, p := range ctx.proxy {
+		proxies[p.AdminAddr] = p
+	}
+	var desiredProxies = make(map[string]bool)
+	for _, addr := range d.Proxies {
+		desiredProxies[addr] = true
+	}
+	for _, addr := range sortedKeys(desiredProxies) {
+		if proxies[addr] == nil {
+			add(&TopologyOp{Action: TopologyCreateProxy, Addr: addr})
+		}
+	}
+
+	var sentinels = make(map[string]bool)
+	if ctx.sentinel != nil {
+		for _, addr := range ctx.sentinel.Servers {
+			sentinels[addr] = true
+		}
+	}
+	var desiredSentinels = make(map[string]bool)
+	for _, addr := range d.Sentinels {
+		desiredSentinels[addr] = true
+	}
+	for _, addr := range sortedKeys(desiredSentinels) {
+		if !sentinels[addr] {
+			add(&TopologyOp{Action: TopologyAddSentinel, Addr: addr})
+		}
+	}
+
+	//按源group与目标group合并slot，未分配的slot直接分配，其他的slot创建迁移
+	var moves = make(map[[2]int][]int)
+	for sid := 0; sid < MaxSlotNum; sid++ {
+		gid, ok := target[sid]
+		if !ok {
+			continue
+		}
+		m, err := ctx.getSlotMapping(sid)
+		if err != nil {
+			return nil, err
+		}
+		if m.Action.State != models.ActionNothing {
+			if m.Action.TargetId == gid {
+				continue
+			}
+			return nil, errors.Errorf("slot-[%d] action is not finished", sid)
+		}
+		if m.GroupId != gid {
+			moves[[2]int{m.GroupId, gid}] = append(moves[[2]int{m.GroupId, gid}], sid)
+		}
+	}
+	var keys [][2]int
+	for k := range moves {
+		keys = append(keys, k)
+	}
+	sort.Sort(topologyMoveSorter(keys))
+	for _, k := range keys {
+		if k[0] == 0 {
+			add(&TopologyOp{Action: TopologyAssignSlots, GroupId: k[1], Slots: moves[k]})
+		} else {
+			add(&TopologyOp{Action: TopologyMigrateSlots, From: k[0], GroupId: k[1], Slots: moves[k]})
+		}
+	}
+
+	//不在期望状态中的group需要先迁走全部slot，迁移完成之前不删除，重新plan、apply时再删除
+	var retired = make(map[int]bool)
+	if d.Groups != nil {
+		for _, g := range models.SortGroup(ctx.group) {
+			if groups[g.Id] != nil {
+				continue
+			}
+			var inUse bool
+			for _, m := range ctx.slots {
+				if m.Action.TargetId == g.Id {
+					return nil, errors.Errorf("slot-[%d] is migrating to group-[%d]", m.Id, g.Id)
+				}
+				if m.GroupId != g.Id {
+					continue
+				}
+				if gid, ok := target[m.Id]; !ok || gid == g.Id {
+					return nil, errors.Errorf("group-[%d] still owns slot-[%d]", g.Id, m.Id)
+				}
+				inUse = true
+			}
+			retired[g.Id] = !inUse
+		}
+	}
+
+	//删除多余的server时先删除slave，master最后删除
+	if d.Groups != nil {
+		for _, g := range models.SortGroup(ctx.group) {
+			desired := groups[g.Id]
+			if desired == nil && !retired[g.Id] {
+				continue
+			}
+			for i := len(g.Servers) - 1; i >= 0; i-- {
+				addr := g.Servers[i].Addr
+				if desired == nil || servers[addr] != g.Id {
+					add(&TopologyOp{Action: TopologyDelServer, GroupId: g.Id, Addr: addr})
+				}
+			}
+		}
+	}
+	if d.Proxies != nil {
+		var tokens []string
+		var addrs = make(map[string]string)
+		for addr, p := range proxies {
+			if !desiredProxies[addr] {
+				tokens = append(tokens, p.Token)
+				addrs[p.Token] = addr
+			}
+		}
+		sort.Strings(tokens)
+		for _, token := range tokens {
+			add(&TopologyOp{Action: TopologyRemoveProxy, Token: token, Addr: addrs[token]})
+		}
+	}
+	if d.Sentinels != nil {
+		for _, addr := range sortedKeys(sentinels) {
+			if !desiredSentinels[addr] {
+				add(&TopologyOp{Action: TopologyDelSentinel, Addr: addr})
+			}
+		}
+	}
+	if d.Groups != nil {
+		for _, g := range models.SortGroup(ctx.group) {
+			if groups[g.Id] == nil && retired[g.Id] {
+				add(&TopologyOp{Action: TopologyRemoveGroup, GroupId: g.Id})
+			}
+		}
+	}
+	return ops, nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var list = make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
+
+type topologyMoveSorter [][2]int
+
+func (s topologyMoveSorter) Len() int { return len(s) }
+func (s topologyMoveSorter) Less(i, j int) bool {
+	if s[i][0] != s[j][0] {
+		return s[i][0] < s[j][0]
+	}
+	return s[i][1] < s[j][1]
+}
+func (s topologyMoveSorter) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,127 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func newTopologyContext() *context {
+	ctx := &context{
+		group: map[int]*models.Group{
+			1: {Id: 1, Servers: []*models.GroupServer{{Addr: "s1:1"}, {Addr: "s1:2"}}},
+			2: {Id: 2, Servers: []*models.GroupServer{{Addr: "s2:1"}}},
+		},
+		proxy: map[string]*models.Proxy{
+			"t1": {Token: "t1", AdminAddr: "p1:1"},
+			"t2": {Token: "t2", AdminAddr: "p2:1"},
+		},
+		sentinel: &models.Sentinel{Servers: []string{"st1:1"}},
+	}
+	for i := 0; i < MaxSlotNum; i++ {
+		m := &models.SlotMapping{Id: i, GroupId: 1}
+		if i >= MaxSlotNum/2 {
+			m.GroupId = 2
+		}
+		ctx.slots = append(ctx.slots, m)
+	}
+	return ctx
+}
+
+func TestPlanTopologyUnmanaged(x *testing.T) {
+	ops, err := planTopology(newTopologyContext(), &DesiredState{})
+	assert.MustNoError(err)
+	assert.Must(len(ops) == 0)
+}
+
+func TestPlanTopology(x *testing.T) {
+	ctx := newTopologyContext()
+	ctx.slots[MaxSlotNum-1].GroupId = 0
+
+	d := &DesiredState{
+		Groups: []*DesiredGroup{
+			{Id: 1, Servers: []string{"s1:2", "s1:3"}},
+			{Id: 2, Servers: []string{"s2:1"}},
+			{Id: 3, Servers: []string{"s3:1"}},
+		},
+		Slots: []*DesiredSlotRange{
+			{Begin: 0, End: 9, GroupId: 3},
+			{Begin: MaxSlotNum - 1, End: MaxSlotNum - 1, GroupId: 2},
+		},
+		Proxies:   []string{"p1:1", "p3:1"},
+		Sentinels: []string{},
+	}
+	ops, err := planTopology(ctx, d)
+	assert.MustNoError(err)
+
+	var actions []string
+	for i, op := range ops {
+		assert.Must(op.Id == i+1)
+		actions = append(actions, op.Action)
+	}
+	assert.Must(len(ops) == 10)
+	assert.Must(actions[0] == TopologyAddServer && ops[0].GroupId == 1 && ops[0].Addr == "s1:3")
+	assert.Must(actions[1] == TopologyPromoteServer && ops[1].GroupId == 1 && ops[1].Addr == "s1:2")
+	assert.Must(actions[2] == TopologyCreateGroup && ops[2].GroupId == 3)
+	assert.Must(actions[3] == TopologyAddServer && ops[3].Addr == "s3:1")
+	assert.Must(actions[4] == TopologyCreateProxy && ops[4].Addr == "p3:1")
+	assert.Must(actions[5] == TopologyAssignSlots && ops[5].GroupId == 2)
+	assert.Must(len(ops[5].Slots) == 1 && ops[5].Slots[0] == MaxSlotNum-1)
+	assert.Must(actions[6] == TopologyMigrateSlots && ops[6].From == 1 && ops[6].GroupId == 3)
+	assert.Must(len(ops[6].Slots) == 10 && ops[6].Slots[9] == 9)
+	assert.Must(actions[7] == TopologyDelServer && ops[7].GroupId == 1 && ops[7].Addr == "s1:1")
+	assert.Must(actions[8] == TopologyRemoveProxy && ops[8].Token == "t2")
+	assert.Must(actions[9] == TopologyDelSentinel && ops[9].Addr == "st1:1")
+}
+
+func TestPlanTopologyRemoveGroup(x *testing.T) {
+	ctx := newTopologyContext()
+	d := &DesiredState{
+		Groups: []*DesiredGroup{
+			{Id: 1, Servers: []string{"s1:1", "s1:2"}},
+		},
+	}
+	_, err := planTopology(ctx, d)
+	assert.Must(err != nil)
+
+	//迁移完成之前不删除group
+	d.Slots = []*DesiredSlotRange{{Begin: 0, End: MaxSlotNum - 1, GroupId: 1}}
+	ops, err := planTopology(ctx, d)
+	assert.MustNoError(err)
+	assert.Must(len(ops) == 1 && ops[0].Action == TopologyMigrateSlots)
+	assert.Must(len(ops[0].Slots) == MaxSlotNum/2)
+
+	for _, m := range ctx.slots {
+		m.GroupId = 1
+	}
+	ops, err = planTopology(ctx, d)
+	assert.MustNoError(err)
+	assert.Must(len(ops) == 2)
+	assert.Must(ops[0].Action == TopologyDelServer && ops[0].GroupId == 2 && ops[0].Addr == "s2:1")
+	assert.Must(ops[1].Action == TopologyRemoveGroup && ops[1].GroupId == 2)
+}
+
+func TestPlanTopologyInvalid(x *testing.T) {
+	ctx := newTopologyContext()
+	for _, d := range []*DesiredState{
+		{Groups: []*DesiredGroup{{Id: 3, Servers: []string{"s1:1"}}}},
+		{Groups: []*DesiredGroup{{Id: 1}, {Id: 1}}},
+		{Slots: []*DesiredSlotRange{{Begin: 0, End: 1, GroupId: 5}}},
+		{Slots: []*DesiredSlotRange{{Begin: 2, End: 1, GroupId: 1}}},
+		{Slots: []*DesiredSlotRange{{Begin: 0, End: 3, GroupId: 1}, {Begin: 3, End: 4, GroupId: 2}}},
+	} {
+		_, err := planTopology(ctx, d)
+		assert.Must(err != nil)
+	}
+
+	ctx.slots[0].Action.State = models.ActionPending
+	ctx.slots[0].Action.TargetId = 2
+	_, err := planTopology(ctx, &DesiredState{Slots: []*DesiredSlotRange{{Begin: 0, End: 0, GroupId: 2}}})
+	assert.MustNoError(err)
+	_, err = planTopology(ctx, &DesiredState{Slots: []*DesiredSlotRange{{Begin: 0, End: 0, GroupId: 1}}})
+	assert.Must(err != nil)
+}
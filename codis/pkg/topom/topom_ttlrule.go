@@ -0,0 +1,132 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"sort"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/log"
+)
+
+func (s *Topom) ttlRules() *models.TTLRules {
+	if s.ttls == nil {
+		return &models.TTLRules{}
+	}
+	return s.ttls
+}
+
+func (s *Topom) TTLRules() []*models.TTLRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var list = []*models.TTLRule{}
+	for _, t := range s.ttlRules().Rules {
+		x := *t
+		list = append(list, &x)
+	}
+	return list
+}
+
+func validateTTLRule(t *models.TTLRule) error {
+	if t.Prefix == "" {
+		return errors.Errorf("invalid ttl rule prefix")
+	}
+	if t.MinTTL < 0 || t.MaxTTL < 0 || t.DefaultTTL < 0 {
+		return errors.Errorf("invalid ttl rule of prefix-[%s]", t.Prefix)
+	}
+	if t.MinTTL == 0 && t.MaxTTL == 0 && t.DefaultTTL == 0 {
+		return errors.Errorf("ttl rule of prefix-[%s] has no limit", t.Prefix)
+	}
+	if t.MaxTTL != 0 && t.MinTTL > t.MaxTTL {
+		return errors.Errorf("ttl rule of prefix-[%s]: min_ttl > max_ttl", t.Prefix)
+	}
+	if t.DefaultTTL != 0 {
+		if t.DefaultTTL < t.MinTTL || (t.MaxTTL != 0 && t.DefaultTTL > t.MaxTTL) {
+			return errors.Errorf("ttl rule of prefix-[%s]: default_ttl is out of range", t.Prefix)
+		}
+	}
+	return nil
+}
+
+//新增或修改一个前缀的过期时间规则，并下发给所有proxy
+func (s *Topom) UpdateTTLRule(t *models.TTLRule) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	if err := validateTTLRule(t); err != nil {
+		return err
+	}
+
+	var p = &models.TTLRules{}
+	for _, x := range s.ttlRules().Rules {
+		if x.Prefix != t.Prefix {
+			p.Rules = append(p.Rules, x)
+		}
+	}
+	p.Rules = append(p.Rules, &models.TTLRule{
+		Prefix: t.Prefix, MinTTL: t.MinTTL, MaxTTL: t.MaxTTL, DefaultTTL: t.DefaultTTL,
+	})
+	sort.Sort(ttlRuleSorter(p.Rules))
+
+	if err := s.storeUpdateTTLRules(p); err != nil {
+		return err
+	}
+	s.ttls = p
+	return s.resyncTTLRules(ctx)
+}
+
+func (s *Topom) RemoveTTLRule(prefix string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ctx, err := s.newContext()
+	if err != nil {
+		return err
+	}
+
+	var p = &models.TTLRules{}
+	for _, x := range s.ttlRules().Rules {
+		if x.Prefix != prefix {
+			p.Rules = append(p.Rules, x)
+		}
+	}
+	if len(p.Rules) == len(s.ttlRules().Rules) {
+		return errors.Errorf("ttl rule of prefix-[%s] doesn't exist", prefix)
+	}
+
+	if err := s.storeUpdateTTLRules(p); err != nil {
+		return err
+	}
+	s.ttls = p
+	return s.resyncTTLRules(ctx)
+}
+
+func (s *Topom) resyncTTLRules(ctx *context) error {
+	for _, p := range ctx.proxy {
+		if err := s.newProxyClient(p).SetTTLRules(s.ttlRules()); err != nil {
+			log.ErrorErrorf(err, "proxy-[%s] set ttl rules failed", p.Token)
+			return errors.Errorf("proxy-[%s] set ttl rules failed", p.Token)
+		}
+	}
+	return nil
+}
+
+func (s *Topom) storeUpdateTTLRules(p *models.TTLRules) error {
+	log.Warnf("update ttl rules:\n%s", p.Encode())
+	if err := s.store.UpdateTTLRules(p); err != nil {
+		log.ErrorErrorf(err, "store: update ttl rules failed")
+		return errors.Errorf("store: update ttl rules failed")
+	}
+	return nil
+}
+
+type ttlRuleSorter []*models.TTLRule
+
+func (s ttlRuleSorter) Len() int           { return len(s) }
+func (s ttlRuleSorter) Less(i, j int) bool { return s[i].Prefix < s[j].Prefix }
+func (s ttlRuleSorter) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
@@ -0,0 +1,37 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package topom
+
+import (
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/models"
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestTTLRule(x *testing.T) {
+	t := openTopom()
+	defer t.Close()
+
+	assert.Must(len(t.TTLRules()) == 0)
+	assert.Must(t.UpdateTTLRule(&models.TTLRule{Prefix: "session:"}) != nil)
+	assert.Must(t.UpdateTTLRule(&models.TTLRule{Prefix: "session:", MinTTL: 100, MaxTTL: 10}) != nil)
+	assert.Must(t.UpdateTTLRule(&models.TTLRule{Prefix: "session:", MaxTTL: 10, DefaultTTL: 20}) != nil)
+
+	assert.MustNoError(t.UpdateTTLRule(&models.TTLRule{Prefix: "session:", MaxTTL: 3600}))
+	assert.MustNoError(t.UpdateTTLRule(&models.TTLRule{Prefix: "cache:", DefaultTTL: 600}))
+	assert.MustNoError(t.UpdateTTLRule(&models.TTLRule{Prefix: "session:", MinTTL: 60, MaxTTL: 7200}))
+
+	list := t.TTLRules()
+	assert.Must(len(list) == 2)
+	assert.Must(list[0].Prefix == "cache:" && list[1].Prefix == "session:" && list[1].MaxTTL == 7200)
+
+	p, err := t.store.LoadTTLRules(true)
+	assert.MustNoError(err)
+	assert.Must(len(p.Rules) == 2)
+
+	assert.MustNoError(t.RemoveTTLRule("session:"))
+	assert.Must(t.RemoveTTLRule("session:") != nil)
+	assert.Must(len(t.TTLRules()) == 1)
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package utils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+)
+
+//include的最大嵌套层数
+const maxConfigIncludeDepth = 8
+
+//${NAME}或${NAME:-default}，$${...}表示不做替换
+var configEnvPattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+//读取配置文件，展开`include "path"`指令并替换${ENV}环境变量，
+//templated表示文件中使用了include或环境变量，这样的文件不应该被改写
+func ReadConfigFile(path string) (data string, templated bool, err error) {
+	var b bytes.Buffer
+	if err := readConfigFile(&b, path, 0, make(map[string]bool), &templated); err != nil {
+		return "", false, err
+	}
+	return b.String(), templated, nil
+}
+
+func readConfigFile(b *bytes.Buffer, path string, depth int, visiting map[string]bool, templated *bool) error {
+	if depth > maxConfigIncludeDepth {
+		return errors.Errorf("include %s: too many nested includes", path)
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if visiting[abs] {
+		return errors.Errorf("include %s: circular include", path)
+	}
+	visiting[abs] = true
+	defer delete(visiting, abs)
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	for i, line := range strings.Split(string(content), "\n") {
+		if name, ok := parseConfigInclude(line); ok {
+			*templated = true
+			if !filepath.IsAbs(name) {
+				name = filepath.Join(filepath.Dir(path), name)
+			}
+			if err := readConfigFile(b, name, depth+1, visiting, templated); err != nil {
+				return err
+			}
+			continue
+		}
+		line, expanded, err := expandConfigEnv(line, os.LookupEnv)
+		if err != nil {
+			return errors.Errorf("%s:%d: %s", path, i+1, err)
+		}
+		if expanded {
+			*templated = true
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return nil
+}
+
+//include指令独占一行，例如 include "common.toml"
+func parseConfigInclude(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "include ") && !strings.HasPrefix(line, "include\t") {
+		return "", false
+	}
+	name, err := strconv.Unquote(strings.TrimSpace(line[len("include"):]))
+	if err != nil || name == "" {
+		return "", false
+	}
+	return name, true
+}
+
+//替换一行中的环境变量，未设置且没有默认值的变量返回错误，注释行不做替换
+func expandConfigEnv(line string, lookup func(string) (string, bool)) (string, bool, error) {
+	if strings.HasPrefix(strings.TrimSpace(line), "#") {
+		return line, false, nil
+	}
+	var expanded bool
+	var missing string
+	line = configEnvPattern.ReplaceAllStringFunc(line, func(s string) string {
+		if strings.HasPrefix(s, "$$") {
+			return s[1:]
+		}
+		m := configEnvPattern.FindStringSubmatch(s)
+		expanded = true
+		if v, ok := lookup(m[1]); ok {
+			return v
+		}
+		if m[2] != "" {
+			return m[3]
+		}
+		if missing == "" {
+			missing = m[1]
+		}
+		return s
+	})
+	if missing != "" {
+		return "", false, errors.Errorf("environment variable %s is not set", missing)
+	}
+	return line, expanded, nil
+}
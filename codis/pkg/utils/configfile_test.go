@@ -0,0 +1,77 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestExpandConfigEnv(t *testing.T) {
+	lookup := func(name string) (string, bool) {
+		if name == "AUTH" {
+			return "secret", true
+		}
+		return "", false
+	}
+	line, expanded, err := expandConfigEnv(`product_auth = "${AUTH}"`, lookup)
+	assert.MustNoError(err)
+	assert.Must(expanded && line == `product_auth = "secret"`)
+
+	line, expanded, err = expandConfigEnv(`proxy_addr = "${ADDR:-0.0.0.0:19000}"`, lookup)
+	assert.MustNoError(err)
+	assert.Must(expanded && line == `proxy_addr = "0.0.0.0:19000"`)
+
+	line, expanded, err = expandConfigEnv(`x = "$${AUTH}"`, lookup)
+	assert.MustNoError(err)
+	assert.Must(!expanded && line == `x = "${AUTH}"`)
+
+	line, expanded, err = expandConfigEnv(`# ${MISSING}`, lookup)
+	assert.MustNoError(err)
+	assert.Must(!expanded && line == `# ${MISSING}`)
+
+	_, _, err = expandConfigEnv(`x = "${MISSING}"`, lookup)
+	assert.Must(err != nil)
+}
+
+func TestParseConfigInclude(t *testing.T) {
+	name, ok := parseConfigInclude(`  include "common.toml"`)
+	assert.Must(ok && name == "common.toml")
+
+	_, ok = parseConfigInclude(`include_path = "x"`)
+	assert.Must(!ok)
+	_, ok = parseConfigInclude(`include common.toml`)
+	assert.Must(!ok)
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "codis-config")
+	assert.MustNoError(err)
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		assert.MustNoError(ioutil.WriteFile(path, []byte(data), 0644))
+		return path
+	}
+
+	plain := write("plain.toml", "a = 1\n")
+	data, templated, err := ReadConfigFile(plain)
+	assert.MustNoError(err)
+	assert.Must(!templated && data == "a = 1\n\n")
+
+	write("common.toml", "b = \"${CODIS_TEST_B:-2}\"\n")
+	main := write("main.toml", "a = 1\ninclude \"common.toml\"\n")
+	data, templated, err = ReadConfigFile(main)
+	assert.MustNoError(err)
+	assert.Must(templated && data == "a = 1\nb = \"2\"\n\n\n")
+
+	loop := write("loop.toml", "include \"loop.toml\"\n")
+	_, _, err = ReadConfigFile(loop)
+	assert.Must(err != nil)
+}
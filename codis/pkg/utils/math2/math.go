@@ -24,6 +24,14 @@ func MinInt(a, b int) int {
 	}
 }
 
+func MaxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	} else {
+		return b
+	}
+}
+
 func MinMaxInt(v, min, max int) int {
 	if min <= max {
 		v = MaxInt(v, min)
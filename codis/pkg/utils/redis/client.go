@@ -373,20 +373,21 @@ func (c *Client) SetMaster(master string) error {
 	return nil
 }*/
 
-func (c *Client) MigrateSlot(slot int, target string) (int, error) {
+//返回本次迁移的key数和slot中剩余的key数
+func (c *Client) MigrateSlot(slot int, target string) (int, int, error) {
 	host, port, err := net.SplitHostPort(target)
 	if err != nil {
-		return 0, errors.Trace(err)
+		return 0, 0, errors.Trace(err)
 	}
 	mseconds := int(c.Timeout / time.Millisecond)
 	if reply, err := c.Do("SLOTSMGRTTAGSLOT", host, port, mseconds, slot); err != nil {
-		return 0, errors.Trace(err)
+		return 0, 0, errors.Trace(err)
 	} else {
 		p, err := redigo.Ints(redigo.Values(reply, nil))
 		if err != nil || len(p) != 2 {
-			return 0, errors.Errorf("invalid response = %v", reply)
+			return 0, 0, errors.Errorf("invalid response = %v", reply)
 		}
-		return p[1], nil
+		return p[0], p[1], nil
 	}
 }
 
@@ -397,20 +398,21 @@ type MigrateSlotAsyncOption struct {
 	Timeout  time.Duration
 }
 
-func (c *Client) MigrateSlotAsync(slot int, target string, option *MigrateSlotAsyncOption) (int, error) {
+//返回本次迁移的key数和slot中剩余的key数
+func (c *Client) MigrateSlotAsync(slot int, target string, option *MigrateSlotAsyncOption) (int, int, error) {
 	host, port, err := net.SplitHostPort(target)
 	if err != nil {
-		return 0, errors.Trace(err)
+		return 0, 0, errors.Trace(err)
 	}
 	if reply, err := c.Do("SLOTSMGRTTAGSLOT-ASYNC", host, port, int(option.Timeout/time.Millisecond),
 		option.MaxBulks, option.MaxBytes, slot, option.NumKeys); err != nil {
-		return 0, errors.Trace(err)
+		return 0, 0, errors.Trace(err)
 	} else {
 		p, err := redigo.Ints(redigo.Values(reply, nil))
 		if err != nil || len(p) != 2 {
-			return 0, errors.Errorf("invalid response = %v", reply)
+			return 0, 0, errors.Errorf("invalid response = %v", reply)
 		}
-		return p[1], nil
+		return p[0], p[1], nil
 	}
 }
 
@@ -434,6 +436,121 @@ func (c *Client) SlotsInfo() (map[int]int, error) {
 	}
 }
 
+func (c *Client) SlotsScan(slot int, count int) ([]string, error) {
+	_, keys, err := c.SlotsScanCursor(slot, 0, count)
+	return keys, err
+}
+
+//从cursor开始扫描slot，返回下一次扫描的cursor，为0时扫描结束
+func (c *Client) SlotsScanCursor(slot int, cursor int, count int) (int, []string, error) {
+	reply, err := redigo.Values(c.Do("SLOTSSCAN", slot, cursor, "COUNT", count))
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	if len(reply) != 2 {
+		return 0, nil, errors.Errorf("invalid response = %v", reply)
+	}
+	next, err := redigo.Int(reply[0], nil)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	keys, err := redigo.Strings(reply[1], nil)
+	if err != nil {
+		return 0, nil, errors.Trace(err)
+	}
+	return next, keys, nil
+}
+
+//key不存在时返回nil
+func (c *Client) Dump(key string) ([]byte, error) {
+	reply, err := c.Do("DUMP", key)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if reply == nil {
+		return nil, nil
+	}
+	b, err := redigo.Bytes(reply, nil)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return b, nil
+}
+
+//毫秒，key不存在时返回-2，没有过期时间时返回-1
+func (c *Client) PTTL(key string) (int64, error) {
+	n, err := redigo.Int64(c.Do("PTTL", key))
+	if err != nil {
+		return 0, errors.Trace(err)
+	}
+	return n, nil
+}
+
+//key不存在时返回none
+func (c *Client) Type(key string) (string, error) {
+	text, err := redigo.String(c.Do("TYPE", key))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return text, nil
+}
+
+//按类型读取key的值，返回redis回复的原始顺序，不支持的类型返回false
+func (c *Client) TypedValues(key, typ string) ([][]byte, bool, error) {
+	var reply interface{}
+	var err error
+	switch typ {
+	case "string":
+		reply, err = c.Do("GET", key)
+	case "hash":
+		reply, err = c.Do("HGETALL", key)
+	case "list":
+		reply, err = c.Do("LRANGE", key, 0, -1)
+	case "set":
+		reply, err = c.Do("SMEMBERS", key)
+	case "zset":
+		reply, err = c.Do("ZRANGE", key, 0, -1, "WITHSCORES")
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	if typ == "string" {
+		if reply == nil {
+			return nil, true, nil
+		}
+		b, err := redigo.Bytes(reply, nil)
+		if err != nil {
+			return nil, false, errors.Trace(err)
+		}
+		return [][]byte{b}, true, nil
+	}
+	values, err := redigo.ByteSlices(reply, nil)
+	if err != nil {
+		return nil, false, errors.Trace(err)
+	}
+	return values, true, nil
+}
+
+func (c *Client) ConfigGet(key string) (string, error) {
+	values, err := redigo.Strings(c.Do("CONFIG", "GET", key))
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	if len(values) != 2 {
+		return "", errors.Errorf("invalid config get %s response = %v", key, values)
+	}
+	return values[1], nil
+}
+
+func (c *Client) ConfigSet(key, value string) error {
+	if _, err := redigo.String(c.Do("CONFIG", "SET", key, value)); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
 func (c *Client) Role() (string, error) {
 	if reply, err := c.Do("ROLE"); err != nil {
 		return "", err
@@ -459,6 +576,7 @@ type Pool struct {
 	mu sync.Mutex
 
 	auth string
+	opts *DialOptions
 	pool map[string]*list.List
 
 	timeout time.Duration
@@ -470,6 +588,13 @@ type Pool struct {
 	closed bool
 }
 
+//使用opts中的认证与TLS配置创建连接
+func NewPoolOptions(opts *DialOptions, timeout time.Duration) *Pool {
+	p := NewPool("", timeout)
+	p.opts = opts
+	return p
+}
+
 func NewPool(auth string, timeout time.Duration) *Pool {
 	p := &Pool{
 		auth: auth, timeout: timeout,
@@ -549,6 +674,9 @@ func (p *Pool) GetClient(addr string) (*Client, error) {
 	if err != nil || c != nil {
 		return c, err
 	}
+	if p.opts != nil {
+		return NewClientOptions(addr, p.opts, p.timeout)
+	}
 	return NewClient(addr, p.auth, p.timeout)
 }
 
@@ -625,6 +753,28 @@ func (p *Pool) InfoDelay(addr string, interval int64) (string, error) {
 	return m, nil
 }
 
+func (p *Pool) ConfigGet(addr string, key string) (_ string, err error) {
+	c, err := p.GetClient(addr)
+	if err != nil {
+		return "", err
+	}
+	defer p.PutClient(c, err)
+	v, err := c.ConfigGet(key)
+	if err != nil {
+		return "", err
+	}
+	return v, nil
+}
+
+func (p *Pool) ConfigSet(addr string, key, value string) (err error) {
+	c, err := p.GetClient(addr)
+	if err != nil {
+		return err
+	}
+	defer p.PutClient(c, err)
+	return c.ConfigSet(key, value)
+}
+
 type InfoCache struct {
 	mu sync.Mutex
 
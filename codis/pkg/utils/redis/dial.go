@@ -0,0 +1,100 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package redis
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"time"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+	"github.com/CodisLabs/codis/pkg/utils/math2"
+
+	redigo "github.com/garyburd/redigo/redis"
+)
+
+//连接需要认证或TLS的server，例如开启了requirepass/ACL的sentinel
+//Username不为空时使用AUTH <username> <password>，TLSConfig不为nil时使用TLS连接
+type DialOptions struct {
+	Username  string
+	Password  string
+	TLSConfig *tls.Config
+}
+
+//caFile为空时使用系统的根证书
+func NewTLSConfig(caFile string, skipVerify bool) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: skipVerify}
+	if caFile != "" {
+		b, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, errors.Errorf("invalid ca file %s", caFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
+
+func NewClientOptions(addr string, opts *DialOptions, timeout time.Duration) (*Client, error) {
+	if opts == nil {
+		return NewClientNoAuth(addr, timeout)
+	}
+	var connectTimeout = math2.MinDuration(time.Second, timeout)
+	var options = []redigo.DialOption{
+		redigo.DialConnectTimeout(connectTimeout),
+		redigo.DialReadTimeout(timeout), redigo.DialWriteTimeout(timeout),
+	}
+	if opts.TLSConfig != nil {
+		options = append(options, redigo.DialNetDial(func(network, addr string) (net.Conn, error) {
+			return dialTLS(network, addr, opts.TLSConfig, connectTimeout)
+		}))
+	}
+	c, err := redigo.Dial("tcp", addr, options...)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if opts.Password != "" {
+		var args = []interface{}{opts.Password}
+		if opts.Username != "" {
+			args = []interface{}{opts.Username, opts.Password}
+		}
+		if _, err := c.Do("AUTH", args...); err != nil {
+			c.Close()
+			return nil, errors.Trace(err)
+		}
+	}
+	return &Client{
+		conn: c, Addr: addr, Auth: opts.Password,
+		LastUse: time.Now(), Timeout: timeout,
+	}, nil
+}
+
+func dialTLS(network, addr string, config *tls.Config, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil {
+		return nil, err
+	}
+	config = config.Clone()
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		config.ServerName = host
+	}
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
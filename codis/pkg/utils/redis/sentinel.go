@@ -24,6 +24,9 @@ type Sentinel struct {
 
 	Product, Auth string
 
+	//连接sentinel时使用的认证与TLS配置，为nil时不认证
+	Options *DialOptions
+
 	LogFunc func(format string, args ...interface{})
 	ErrFunc func(err error, format string, args ...interface{})
 }
@@ -79,7 +82,7 @@ func (s *Sentinel) errorf(err error, format string, args ...interface{}) {
 
 func (s *Sentinel) do(sentinel string, timeout time.Duration,
 	fn func(client *Client) error) error {
-	c, err := NewClientNoAuth(sentinel, timeout)
+	c, err := NewClientOptions(sentinel, s.Options, timeout)
 	if err != nil {
 		return err
 	}
@@ -89,7 +92,7 @@ func (s *Sentinel) do(sentinel string, timeout time.Duration,
 
 func (s *Sentinel) dispatch(ctx context.Context, sentinel string, timeout time.Duration,
 	fn func(client *Client) error) error {
-	c, err := NewClientNoAuth(sentinel, timeout)
+	c, err := NewClientOptions(sentinel, s.Options, timeout)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,218 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package rpc
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type OpenAPIRoute struct {
+	Method  string
+	Pattern string
+}
+
+//接口的请求和响应类型，用于生成schema，为nil时不描述
+type OpenAPIOperation struct {
+	Request  interface{}
+	Response interface{}
+}
+
+//根据路由表生成OpenAPI 3.0文档，ops的key为"METHOD pattern"，例如"PUT /api/proxy/fillslots/:xauth"
+//路由中的:name转换为路径参数，包含*的路由被忽略；接口出错时返回800和RemoteError
+func NewOpenAPI(title, version string, routes []OpenAPIRoute, ops map[string]*OpenAPIOperation) map[string]interface{} {
+	var g = &openAPISchemas{defs: make(map[string]interface{})}
+	var errorSchema = g.schemaOf(reflect.TypeOf(RemoteError{}))
+
+	var paths = make(map[string]interface{})
+	for _, r := range routes {
+		if r.Pattern == "" || r.Pattern == "/" || strings.Contains(r.Pattern, "*") {
+			continue
+		}
+		method := strings.ToLower(r.Method)
+		if method == "any" {
+			continue
+		}
+		path, params := openAPIPath(r.Pattern)
+
+		var parameters = []interface{}{}
+		for _, name := range params {
+			parameters = append(parameters, map[string]interface{}{
+				"name": name, "in": "path", "required": true,
+				"schema": map[string]interface{}{"type": "string"},
+			})
+		}
+		var response = map[string]interface{}{}
+		op := map[string]interface{}{
+			"operationId": openAPIOperationId(method, r.Pattern),
+			"parameters":  parameters,
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OK",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": response},
+					},
+				},
+				"800": map[string]interface{}{
+					"description": "Error",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{"schema": errorSchema},
+					},
+				},
+			},
+		}
+		if x := ops[r.Method+" "+r.Pattern]; x != nil {
+			if x.Request != nil {
+				op["requestBody"] = map[string]interface{}{
+					"required": true,
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": g.schemaOf(reflect.TypeOf(x.Request)),
+						},
+					},
+				}
+			}
+			if x.Response != nil {
+				for k, v := range g.schemaOf(reflect.TypeOf(x.Response)) {
+					response[k] = v
+				}
+			}
+		}
+
+		item, _ := paths[path].(map[string]interface{})
+		if item == nil {
+			item = make(map[string]interface{})
+			paths[path] = item
+		}
+		item[method] = op
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title": title, "version": version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.defs,
+		},
+	}
+}
+
+func openAPIPath(pattern string) (string, []string) {
+	var params []string
+	var parts = strings.Split(pattern, "/")
+	for i, s := range parts {
+		if strings.HasPrefix(s, ":") {
+			params = append(params, s[1:])
+			parts[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+//由方法和路由生成，例如put_api_topom_group_add_xauth_gid
+func openAPIOperationId(method, pattern string) string {
+	var parts = []string{method}
+	for _, s := range strings.Split(pattern, "/") {
+		s = strings.TrimPrefix(s, ":")
+		if s != "" {
+			parts = append(parts, strings.Replace(s, "-", "_", -1))
+		}
+	}
+	return strings.Join(parts, "_")
+}
+
+type openAPISchemas struct {
+	defs map[string]interface{}
+}
+
+var (
+	typeOfTime       = reflect.TypeOf(time.Time{})
+	typeOfRawMessage = reflect.TypeOf(json.RawMessage{})
+)
+
+//具名的struct放在components中引用，匿名的struct直接展开
+func (g *openAPISchemas) schemaOf(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == typeOfTime:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t == typeOfRawMessage:
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := t.Name()
+		if i := strings.LastIndex(t.PkgPath(), "/"); i >= 0 {
+			name = t.PkgPath()[i+1:] + "." + name
+		}
+		ref := map[string]interface{}{"$ref": "#/components/schemas/" + name}
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = map[string]interface{}{}
+			g.defs[name] = g.structSchema(t)
+		}
+		return ref
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func (g *openAPISchemas) structSchema(t reflect.Type) map[string]interface{} {
+	var props = make(map[string]interface{})
+	g.addFields(props, t)
+	return map[string]interface{}{"type": "object", "properties": props}
+}
+
+//按encoding/json的规则处理字段名，没有json tag的匿名字段展开到外层
+func (g *openAPISchemas) addFields(props map[string]interface{}, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				g.addFields(props, ft)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = g.schemaOf(f.Type)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+type openAPINode struct {
+	Name     string         `json:"name"`
+	Children []*openAPINode `json:"children,omitempty"`
+	Secret   string         `json:"-"`
+}
+
+type openAPIRequest struct {
+	openAPINode
+	Servers map[int][]string `json:"servers"`
+	Limit   int
+	Nested  struct {
+		Ok bool `json:"ok"`
+	} `json:"nested"`
+}
+
+func TestNewOpenAPI(t *testing.T) {
+	routes := []OpenAPIRoute{
+		{"GET", "/"},
+		{"GET", "/api/x/model"},
+		{"PUT", "/api/x/create/:xauth/:gid"},
+		{"GET", "/api/x/create/:xauth/:gid"},
+		{"ANY", "/debug/**"},
+	}
+	ops := map[string]*OpenAPIOperation{
+		"PUT /api/x/create/:xauth/:gid": {Request: openAPIRequest{}, Response: []*openAPINode{}},
+	}
+	spec := NewOpenAPI("test", "1.0", routes, ops)
+
+	b, err := json.Marshal(spec)
+	assert.MustNoError(err)
+	var v struct {
+		Paths map[string]map[string]struct {
+			OperationId string `json:"operationId"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody *struct{} `json:"requestBody"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	assert.MustNoError(json.Unmarshal(b, &v))
+
+	assert.Must(len(v.Paths) == 2)
+	assert.Must(len(v.Paths["/api/x/model"]) == 1)
+
+	item := v.Paths["/api/x/create/{xauth}/{gid}"]
+	assert.Must(len(item) == 2)
+	put := item["put"]
+	assert.Must(put.OperationId == "put_api_x_create_xauth_gid")
+	assert.Must(len(put.Parameters) == 2)
+	assert.Must(put.Parameters[0].Name == "xauth" && put.Parameters[0].In == "path")
+	assert.Must(put.Parameters[1].Name == "gid")
+	assert.Must(put.RequestBody != nil)
+	assert.Must(item["get"].RequestBody == nil)
+
+	req := v.Components.Schemas["rpc.openAPIRequest"]
+	assert.Must(len(req.Properties) == 5)
+	for _, name := range []string{"name", "children", "servers", "Limit", "nested"} {
+		assert.Must(req.Properties[name] != nil)
+	}
+	node := v.Components.Schemas["rpc.openAPINode"]
+	assert.Must(len(node.Properties) == 2)
+	assert.Must(v.Components.Schemas["rpc.RemoteError"].Properties != nil)
+}
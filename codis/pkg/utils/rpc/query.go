@@ -0,0 +1,132 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/CodisLabs/codis/pkg/utils/errors"
+)
+
+type fieldTrie struct {
+	leaf bool
+	next map[string]*fieldTrie
+}
+
+func newFieldTrie(fields []string) *fieldTrie {
+	var root = &fieldTrie{}
+	for _, field := range fields {
+		var t = root
+		for _, key := range strings.Split(field, ".") {
+			if t.next == nil {
+				t.next = make(map[string]*fieldTrie)
+			}
+			if t.next[key] == nil {
+				t.next[key] = &fieldTrie{}
+			}
+			t = t.next[key]
+		}
+		t.leaf = true
+	}
+	return root
+}
+
+func (t *fieldTrie) prune(v interface{}) (interface{}, bool) {
+	if t.leaf {
+		return v, true
+	}
+	switch x := v.(type) {
+	case map[string]interface{}:
+		var m = make(map[string]interface{})
+		for key, val := range x {
+			sub := t.next[key]
+			if sub == nil {
+				sub = t.next["*"]
+			}
+			if sub == nil {
+				continue
+			}
+			if y, ok := sub.prune(val); ok {
+				m[key] = y
+			}
+		}
+		return m, len(m) != 0
+	case []interface{}:
+		//数组中的每个元素都按相同的路径裁剪
+		var list = make([]interface{}, 0, len(x))
+		for _, val := range x {
+			y, _ := t.prune(val)
+			list = append(list, y)
+		}
+		return list, true
+	default:
+		return nil, false
+	}
+}
+
+//按字段路径裁剪v的json，路径以.分隔，*匹配任意key，数组中的元素按相同的路径裁剪
+func SelectFields(v interface{}, fields []string) (interface{}, error) {
+	if len(fields) == 0 {
+		return v, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	var x interface{}
+	if err := json.Unmarshal(b, &x); err != nil {
+		return nil, errors.Trace(err)
+	}
+	y, _ := newFieldTrie(fields).prune(x)
+	return y, nil
+}
+
+//fields=a.b,c，为空时返回nil
+func ParseFields(req *http.Request) []string {
+	var fields []string
+	for _, s := range strings.Split(req.URL.Query().Get("fields"), ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			fields = append(fields, s)
+		}
+	}
+	return fields
+}
+
+//offset与limit从请求参数中读取，limit为0表示不分页
+func ParsePage(req *http.Request, prefix string) (offset, limit int, err error) {
+	query := req.URL.Query()
+	if s := query.Get(prefix + "offset"); s != "" {
+		if offset, err = strconv.Atoi(s); err != nil || offset < 0 {
+			return 0, 0, errors.Errorf("invalid %soffset = %s", prefix, s)
+		}
+	}
+	if s := query.Get(prefix + "limit"); s != "" {
+		if limit, err = strconv.Atoi(s); err != nil || limit < 0 {
+			return 0, 0, errors.Errorf("invalid %slimit = %s", prefix, s)
+		}
+	}
+	return offset, limit, nil
+}
+
+//返回长度为n的列表分页后的区间[beg, end)
+func PageBounds(n, offset, limit int) (beg, end int) {
+	if offset > n {
+		offset = n
+	}
+	if limit == 0 || offset+limit > n {
+		return offset, n
+	}
+	return offset, offset + limit
+}
+
+func ApiResponseJsonFields(v interface{}, req *http.Request) (int, string) {
+	x, err := SelectFields(v, ParseFields(req))
+	if err != nil {
+		return ApiResponseError(err)
+	}
+	return ApiResponseJson(x)
+}
@@ -0,0 +1,54 @@
+// Copyright 2016 CodisLabs. All Rights Reserved.
+// Licensed under the MIT (MIT-LICENSE.txt) license.
+
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/CodisLabs/codis/pkg/utils/assert"
+)
+
+func TestSelectFields(t *testing.T) {
+	v := map[string]interface{}{
+		"closed": false,
+		"proxy": map[string]interface{}{
+			"models": []interface{}{
+				map[string]interface{}{"token": "t1", "addr": "a1"},
+				map[string]interface{}{"token": "t2", "addr": "a2"},
+			},
+			"stats": map[string]interface{}{
+				"t1": map[string]interface{}{"qps": 1, "cmd": []interface{}{1, 2}},
+				"t2": map[string]interface{}{"qps": 2, "cmd": []interface{}{3}},
+			},
+		},
+	}
+	x, err := SelectFields(v, []string{"closed", "proxy.models.token", "proxy.stats.*.qps"})
+	assert.MustNoError(err)
+	b, err := json.Marshal(x)
+	assert.MustNoError(err)
+	assert.Must(string(b) == `{"closed":false,"proxy":{"models":[{"token":"t1"},{"token":"t2"}],"stats":{"t1":{"qps":1},"t2":{"qps":2}}}}`)
+
+	x, err = SelectFields(v, nil)
+	assert.MustNoError(err)
+	assert.Must(x != nil)
+}
+
+func TestParsePage(t *testing.T) {
+	req, err := http.NewRequest("GET", "http://localhost/topom?offset=10&limit=5&cmd_limit=x", nil)
+	assert.MustNoError(err)
+	offset, limit, err := ParsePage(req, "")
+	assert.MustNoError(err)
+	assert.Must(offset == 10 && limit == 5)
+	_, _, err = ParsePage(req, "cmd_")
+	assert.Must(err != nil)
+
+	beg, end := PageBounds(12, 10, 5)
+	assert.Must(beg == 10 && end == 12)
+	beg, end = PageBounds(12, 20, 5)
+	assert.Must(beg == 12 && end == 12)
+	beg, end = PageBounds(12, 0, 0)
+	assert.Must(beg == 0 && end == 12)
+}